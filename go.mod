@@ -5,6 +5,8 @@ go 1.18
 require (
 	github.com/gorilla/websocket v1.5.0
 	github.com/quic-go/quic-go v0.37.5
+	go.etcd.io/bbolt v1.3.9
+	golang.org/x/crypto v0.23.0
 	golang.org/x/net v0.25.0
 )
 
@@ -15,7 +17,6 @@ require (
 	github.com/onsi/ginkgo/v2 v2.9.5 // indirect
 	github.com/quic-go/qpack v0.4.0 // indirect
 	github.com/quic-go/qtls-go1-20 v0.3.1 // indirect
-	golang.org/x/crypto v0.23.0 // indirect
 	golang.org/x/exp v0.0.0-20221205204356-47842c84f3db // indirect
 	golang.org/x/mod v0.10.0 // indirect
 	golang.org/x/sys v0.20.0 // indirect
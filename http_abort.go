@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	abortAborted   = "aborted"   // the download was cancelled by the client partway
+	abortCompleted = "completed" // the response was read before the abort point
+)
+
+// StressAbort downloads cancelled by the client of -abort-after-bytes or -abort-after, the aborted
+// responses are not failures
+type StressAbort struct {
+	Bytes     int64 `json:"bytes"`      // bytes of the body read before abort, 0 is disabled
+	After     int64 `json:"after"`      // ms after the response headers to abort, 0 is disabled
+	Aborted   int64 `json:"aborted"`    // downloads aborted partway
+	Completed int64 `json:"completed"`  // responses read before the abort point
+	BytesRead int64 `json:"bytes_read"` // bytes of the aborted downloads on the wire
+}
+
+// abortTrace cancel of the download of a request, the request is cancelled on abort, otherwise the
+// transport of http1 reads the rest of a short remainder on close to reuse the connection
+type abortTrace struct {
+	bytes  int64
+	after  time.Duration
+	cancel context.CancelFunc
+	timer  *time.Timer
+}
+
+// traceAbort attach a cancel to the request, the download is read up to the bytes of -abort-after-bytes
+// or cancelled by the timer of -abort-after
+func traceAbort(req *http.Request, bytes, after int64) (*http.Request, *abortTrace) {
+	trace := &abortTrace{bytes: bytes, after: time.Duration(after) * time.Millisecond}
+	ctx, cancel := context.WithCancel(req.Context())
+	trace.cancel = cancel
+	return req.WithContext(ctx), trace
+}
+
+// start start the timer of -abort-after when the response headers are received
+func (trace *abortTrace) start() {
+	if trace.after > 0 {
+		trace.timer = time.AfterFunc(trace.after, trace.cancel)
+	}
+}
+
+// maxRead limit of the body to read of -max-body-read and -abort-after-bytes, 0 is unlimited
+func (trace *abortTrace) maxRead(maxRead int64) int64 {
+	if trace.bytes > 0 && (maxRead <= 0 || trace.bytes < maxRead) {
+		return trace.bytes
+	}
+	return maxRead
+}
+
+// classify the download after the body is read, a body truncated at -abort-after-bytes is aborted
+// rather than truncated by -max-body-read
+func (trace *abortTrace) classify(res *result) {
+	aborted := trace.timer != nil && !trace.timer.Stop()
+	if trace.bytes > 0 && res.truncated && res.wireLength >= trace.bytes {
+		aborted, res.truncated = true, false
+	}
+	res.abort = abortCompleted
+	if aborted {
+		res.abort = abortAborted
+		trace.cancel() // close the connection of http1 or reset the stream of http2 before the body
+	}
+}
+
+// done release the context of the request
+func (trace *abortTrace) done() {
+	trace.cancel()
+}
+
+func (a *StressAbort) append(res *result) {
+	switch res.abort {
+	case abortAborted:
+		a.Aborted++
+		a.BytesRead += res.wireLength
+	case abortCompleted:
+		a.Completed++
+	}
+}
+
+func (a *StressAbort) merge(v *StressAbort) {
+	a.Bytes, a.After = v.Bytes, v.After
+	a.Aborted += v.Aborted
+	a.Completed += v.Completed
+	a.BytesRead += v.BytesRead
+}
+
+// printAbort Print downloads aborted by the client and responses read before the abort point
+func (result *StressResult) printAbort(w io.Writer) {
+	a := result.Abort
+	var after []string
+	if a.Bytes > 0 {
+		after = append(after, toByteSizeStr(float64(a.Bytes)))
+	}
+	if a.After > 0 {
+		after = append(after, (time.Duration(a.After) * time.Millisecond).String())
+	}
+	fprintln(w, "\nClient aborts (after %s):", strings.Join(after, " or "))
+	fprintln(w, "  Aborted:\t%d downloads, %s read", a.Aborted, toByteSizeStr(float64(a.BytesRead)))
+	fprintln(w, "  Completed:\t%d responses before the abort", a.Completed)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStressAbort(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small":
+			w.Write([]byte("ok"))
+		case "/slow":
+			w.Write([]byte("first"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+			w.Write([]byte("rest"))
+		default:
+			w.Write(bytes.Repeat([]byte("a"), 256*1024))
+		}
+	}))
+	defer srv.Close()
+
+	for _, v := range []struct {
+		path              string
+		bytes, after      int64
+		aborted, complete bool
+	}{
+		{path: "/large", bytes: 64 * 1024, aborted: true},
+		{path: "/small", bytes: 64 * 1024, complete: true},
+		{path: "/slow", after: 50, aborted: true},
+	} {
+		start := time.Now()
+		_, result := executeStress(StressParameters{
+			SequenceId:         time.Now().UnixNano(),
+			Cmd:                cmdStart,
+			RequestType:        typeHttp1,
+			RequestMethod:      "GET",
+			Url:                srv.URL + v.path,
+			C:                  1,
+			N:                  4,
+			Timeout:            3000,
+			AbortAfterBytes:    v.bytes,
+			AbortAfter:         v.after,
+			DisableCompression: true,
+		})
+		if result == nil || len(result.ErrorDist) > 0 || result.Abort == nil {
+			t.Fatalf("result of %s = %+v", v.path, result)
+		}
+		a := result.Abort
+		if (a.Aborted == result.LatsTotal) != v.aborted || (a.Completed == result.LatsTotal) != v.complete ||
+			result.TruncatedTotal != 0 || a.Bytes != v.bytes || a.After != v.after {
+			t.Errorf("abort of %s = %+v, responses: %d, truncated: %d", v.path, a, result.LatsTotal, result.TruncatedTotal)
+		}
+		if v.bytes > 0 && v.aborted && a.BytesRead > a.Aborted*(v.bytes+1) {
+			t.Errorf("abort of %s read %d bytes of %d downloads, expect at most %d each", v.path, a.BytesRead, a.Aborted, v.bytes)
+		}
+		if v.after > 0 && time.Since(start) > time.Second {
+			t.Errorf("abort of %s after %dms took %v", v.path, v.after, time.Since(start))
+		}
+		// aborted connections are not reused
+		if v.aborted && result.ConnReuse != nil && result.ConnReuse.Reused > 0 {
+			t.Errorf("conn reuse of %s = %+v, expect no reuse after aborts, abort: %+v, wire: %d", v.path, result.ConnReuse, a, result.WireSizeTotal)
+		}
+	}
+
+	result := &StressResult{Abort: &StressAbort{Bytes: 64 * 1024, After: 200, Aborted: 3, Completed: 1, BytesRead: 3 * 64 * 1024}}
+	var buf bytes.Buffer
+	result.printAbort(&buf)
+	for _, line := range []string{"Client aborts (after 64.000 KB or 200ms):", "  Aborted:\t3 downloads, 192.000 KB read",
+		"  Completed:\t1 responses before the abort"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printAbort expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
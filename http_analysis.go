@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+const (
+	hintColdStartSecs    = 5    // errors in first seconds is cold start
+	hintColdStartRatio   = 0.8  // ratio of errors in cold start seconds
+	hintMinErrors        = 10   // minimum errors to analysis
+	hintMinPoints        = 10   // minimum seconds to analysis trend
+	hintDegradeCorrel    = 0.8  // correlation of p99 and time
+	hintDegradeRatio     = 1.5  // p99 at the end compare to the beginning
+	hintRpsCappedCV      = 0.05 // coefficient of variation of rps
+	hintRpsCappedCpuIdle = 50   // generator cpu usage(%) considered idle
+)
+
+// analysis heuristic findings from time series to guide users
+func (result *StressResult) analysis() []string {
+	var (
+		hints   []string
+		seconds = make([]int64, 0, len(result.TimeSeries))
+	)
+
+	for sec := range result.TimeSeries {
+		seconds = append(seconds, sec)
+	}
+	if len(seconds) <= 0 {
+		return hints
+	}
+	sort.Slice(seconds, func(i, j int) bool { return seconds[i] < seconds[j] })
+
+	// errors concentrated in first seconds
+	var errTotal, errColdStart int64
+	for _, sec := range seconds {
+		errTotal += result.TimeSeries[sec].ErrTotal
+		if sec-seconds[0] < hintColdStartSecs {
+			errColdStart += result.TimeSeries[sec].ErrTotal
+		}
+	}
+	if errTotal >= hintMinErrors && seconds[len(seconds)-1]-seconds[0] >= 2*hintColdStartSecs &&
+		float64(errColdStart) >= hintColdStartRatio*float64(errTotal) {
+		hints = append(hints, fmt.Sprintf("errors concentrated in first %ds (%d of %d), cold start?",
+			hintColdStartSecs, errColdStart, errTotal))
+	}
+
+	// the first and last second are partial, ignore them
+	if len(seconds) < hintMinPoints+2 {
+		return hints
+	}
+	steady := seconds[1 : len(seconds)-1]
+
+	// p99 degrades linearly with time
+	var xs, ys []float64
+	for _, sec := range steady {
+		point := result.TimeSeries[sec]
+		if point.LatsTotal <= 0 {
+			continue
+		}
+		xs = append(xs, float64(sec-seconds[0]))
+		ys = append(ys, latsPercentiles(point.Lats, point.LatsTotal, []int{99})[0])
+	}
+	if len(xs) >= hintMinPoints {
+		slope, intercept, r := linearRegression(xs, ys)
+		begin, end := intercept+slope*xs[0], intercept+slope*xs[len(xs)-1]
+		if slope > 0 && r >= hintDegradeCorrel && begin > 0 && end >= hintDegradeRatio*begin {
+			hints = append(hints, fmt.Sprintf("p99 degrades linearly with time (%4.3f -> %4.3f secs), leak?",
+				begin, end))
+		}
+	}
+
+	// rps capped with generator cpu idle, unless the rate is limited on purpose
+	if result.Paced {
+		return hints
+	}
+	rps := make([]float64, 0, len(steady))
+	for _, sec := range steady {
+		rps = append(rps, float64(result.TimeSeries[sec].LatsTotal))
+	}
+	mean, stddev := meanStddev(rps)
+	if mean > 0 && stddev/mean < hintRpsCappedCV && result.CpuUsage > 0 && result.CpuUsage < hintRpsCappedCpuIdle {
+		hints = append(hints, fmt.Sprintf("RPS capped at ~%d with generator CPU %d%% (rate limit or server connection limit?)",
+			int64(mean), result.CpuUsage))
+	}
+
+	return hints
+}
+
+// printHints Print analysis hints
+func (result *StressResult) printHints(w io.Writer) {
+	hints := result.analysis()
+	if len(hints) <= 0 {
+		return
+	}
+
+	fprintln(w, "\nAnalysis hints:")
+	for _, hint := range hints {
+		fprintln(w, "  - %s", hint)
+	}
+}
+
+func meanStddev(vs []float64) (float64, float64) {
+	if len(vs) <= 0 {
+		return 0, 0
+	}
+
+	var sum, sq float64
+	for _, v := range vs {
+		sum += v
+	}
+	mean := sum / float64(len(vs))
+	for _, v := range vs {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(vs)))
+}
+
+// linearRegression return slope, intercept and correlation coefficient
+func linearRegression(xs, ys []float64) (float64, float64, float64) {
+	mx, sx := meanStddev(xs)
+	my, sy := meanStddev(ys)
+	if sx == 0 || sy == 0 {
+		return 0, my, 0
+	}
+
+	var cov float64
+	for i := range xs {
+		cov += (xs[i] - mx) * (ys[i] - my)
+	}
+	cov /= float64(len(xs))
+
+	slope := cov / (sx * sx)
+	return slope, my - slope*mx, cov / (sx * sy)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestStressResultAnalysis(t *testing.T) {
+	coldStart := GetStressResult()
+	degrade := GetStressResult()
+	for sec := int64(0); sec < 30; sec++ {
+		point := &StressPoint{Lats: map[string]int64{"0.010": 100}, LatsTotal: 100}
+		if sec < 3 {
+			point.ErrTotal = 10
+		}
+		coldStart.TimeSeries[1700000000+sec] = point
+
+		lats := fmt.Sprintf("%4.3f", 0.010*float64(sec+1))
+		degrade.TimeSeries[1700000000+sec] = &StressPoint{Lats: map[string]int64{lats: 100}, LatsTotal: 100}
+	}
+	coldStart.CpuUsage = 10
+	paced := GetStressResult()
+	for sec, point := range coldStart.TimeSeries {
+		paced.TimeSeries[sec] = point
+	}
+	paced.CpuUsage, paced.Paced = 10, true
+
+	for _, v := range []struct {
+		result *StressResult
+		hints  []string
+	}{
+		{result: GetStressResult(), hints: nil},
+		{result: coldStart, hints: []string{"cold start", "RPS capped at ~100"}},
+		{result: degrade, hints: []string{"p99 degrades linearly"}},
+		{result: paced, hints: []string{"cold start"}},
+	} {
+		hints := v.result.analysis()
+		if len(hints) != len(v.hints) {
+			t.Fatalf("analysis hints: %v, expect: %v", hints, v.hints)
+		}
+		for i := range hints {
+			if !strings.Contains(hints[i], v.hints[i]) {
+				t.Errorf("analysis hint: %s, expect contains: %s", hints[i], v.hints[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// annotateRequest body of POST /api/annotate
+type annotateRequest struct {
+	SequenceId int64  `json:"sequence_id"` // 0 is all running stress tests
+	Time       int64  `json:"time"`        // unix ms, default now
+	Msg        string `json:"msg"`
+}
+
+// annotate mark external event to running stress tests, and forward to workers
+func annotate(req annotateRequest) int {
+	if req.Time <= 0 {
+		req.Time = time.Now().UnixMilli()
+	}
+
+	var count int
+	stressList.Range(func(k, v interface{}) bool {
+		b := v.(*StressWorker)
+		if req.SequenceId != 0 && req.SequenceId != k.(int64) {
+			return true
+		}
+		resultRdMutex.Lock()
+		if b.curResult != nil {
+			b.curResult.Annotations = append(b.curResult.Annotations, StressAnnotation{Time: req.Time, Msg: req.Msg})
+			count++
+		}
+		resultRdMutex.Unlock()
+		return true
+	})
+
+	if len(workerList) > 0 {
+		body, _ := json.Marshal(req)
+		for _, v := range workerList {
+			addr := workerUrl(v, httpWorkerAnnotatePath)
+			resp, err := doWorker(context.Background(), "POST", addr, body)
+			if err != nil {
+				verbosePrint(vERROR, "annotate addr(%s) err: %s", redactWorker(addr), err.Error())
+				continue
+			}
+			resp.Body.Close()
+			count++
+		}
+	}
+
+	verbosePrint(vINFO, "annotate %d stress tests: %s", count, req.Msg)
+	return count
+}
+
+func serveAnnotate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "POST":
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req annotateRequest
+	result := map[string]interface{}{"err_code": 0, "err_msg": ""}
+	if reqStr, err := io.ReadAll(r.Body); err != nil {
+		result["err_code"], result["err_msg"] = -1, err.Error()
+	} else if err := json.Unmarshal(reqStr, &req); err != nil {
+		result["err_code"], result["err_msg"] = -1, err.Error()
+	} else if req.Msg = strings.TrimSpace(req.Msg); req.Msg == "" {
+		result["err_code"], result["err_msg"] = -1, "msg is empty"
+	} else {
+		result["annotated"] = annotate(req)
+	}
+
+	wbody, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", httpContentTypeJSON)
+	w.Write(wbody)
+}
+
+// parseAnnotateLine parse line of annotate file, "[RFC3339 time] msg"
+func parseAnnotateLine(line string) annotateRequest {
+	line = strings.TrimSpace(line)
+	if first, msg, ok := strings.Cut(line, " "); ok {
+		if t, err := time.Parse(time.RFC3339, first); err == nil {
+			return annotateRequest{Time: t.UnixMilli(), Msg: strings.TrimSpace(msg)}
+		}
+	}
+	return annotateRequest{Msg: line}
+}
+
+// watchAnnotateFile annotate the lines appended to file until stop is closed
+func watchAnnotateFile(fileName string, stop chan struct{}) {
+	var offset int64
+	if fi, err := os.Stat(fileName); err == nil {
+		offset = fi.Size() // only the appended lines
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		f, err := os.Open(fileName)
+		if err != nil {
+			continue
+		}
+		if fi, err := f.Stat(); err == nil && fi.Size() < offset {
+			offset = 0 // truncated
+		}
+		f.Seek(offset, io.SeekStart)
+		reader := bufio.NewReader(f)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				break // wait for the complete line
+			}
+			offset += int64(len(line))
+			if req := parseAnnotateLine(line); req.Msg != "" {
+				annotate(req)
+			}
+		}
+		f.Close()
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stressTest body of GET /api/tests/{sequence id}, and of POST /api/tests without result
+type stressTest struct {
+	stressJob
+	Result *StressResult `json:"result,omitempty"` // live metrics while running, the final result once finished
+}
+
+// findTest the running, finished or persisted stress test of sequence id
+func findTest(seqId int64) (stressTest, bool) {
+	expireCollectResults()
+	if v, ok := collectResults.Load(seqId); ok {
+		c := v.(*collectResult)
+		return stressTest{newStressJob(seqId, jobFinished, c.url, c.startTime, c.finishTime, c.result), c.result}, true
+	}
+	if v, ok := stressList.Load(seqId); ok {
+		b := v.(*StressWorker)
+		_, result := executeStress(StressParameters{Cmd: cmdMetrics, SequenceId: seqId})
+		if result == nil { // not started yet
+			result = GetStressResult()
+		}
+		return stressTest{newStressJob(seqId, jobRunning, b.RequestParams.Url, b.started(), time.Time{}, result), result}, true
+	}
+	if run, err := loadHistory(seqId); err == nil {
+		return stressTest{newStressJob(seqId, jobFinished, run.Url, run.StartTime, run.FinishTime, run.Result), run.Result}, true
+	}
+	return stressTest{}, false
+}
+
+// startTest start the stress test of params in background, the sequence id is generated if 0
+func startTest(params StressParameters) (stressTest, int, error) {
+	if params.Url == "" && len(params.Flow) <= 0 {
+		return stressTest{}, http.StatusBadRequest, fmt.Errorf("url is required")
+	}
+	if params.C < 0 || params.N < 0 || params.Duration < 0 || params.Qps < 0 || params.Timeout < 0 {
+		return stressTest{}, http.StatusBadRequest, fmt.Errorf("c, n, duration, qps and timeout cannot be negative")
+	}
+	if err := checkRemoteStream(params.BodyStream); err != nil {
+		return stressTest{}, http.StatusForbidden, err
+	}
+	if params.C == 0 {
+		params.C = 1
+	}
+	if params.RequestMethod == "" {
+		params.RequestMethod = "GET"
+	}
+	if params.RequestType == "" {
+		params.RequestType = typeHttp1
+	}
+	if params.Timeout == 0 {
+		params.Timeout = 3000
+	}
+	if params.SequenceId == 0 {
+		params.SequenceId = time.Now().UnixNano()
+	}
+	params.Cmd = cmdStart
+
+	if _, ok := collectResults.Load(params.SequenceId); ok {
+		return stressTest{}, http.StatusConflict, fmt.Errorf("sequence id %d is finished", params.SequenceId)
+	}
+	// stored before the start, so that the test is found right after the reply
+	b := &StressWorker{RequestParams: &params}
+	if _, loaded := stressList.LoadOrStore(params.SequenceId, b); loaded {
+		return stressTest{}, http.StatusConflict, fmt.Errorf("sequence id %d is running", params.SequenceId)
+	}
+	go func() {
+		if _, result := executeStress(params); result != nil {
+			result.print()
+		}
+	}()
+	verbosePrint(vINFO, "start stress test %d by api: %s", params.SequenceId, params.Url)
+	return stressTest{stressJob: newStressJob(params.SequenceId, jobRunning, params.Url, time.Time{}, time.Time{},
+		GetStressResult())}, http.StatusCreated, nil
+}
+
+// serveTests REST API of stress tests:
+//
+//	GET    /api/tests                list running and finished stress tests
+//	POST   /api/tests                start a stress test of the parameters, 201 with Location of the test
+//	GET    /api/tests/{sequence id}  summary and live metrics or final result
+//	DELETE /api/tests/{sequence id}  stop the running stress test
+//	GET    /api/tests/schema         json schemas of the request and responses
+func serveTests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Access-Control-Expose-Headers", "Location")
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	reply := func(code int, v interface{}) {
+		wbody, err := json.Marshal(v)
+		if err != nil {
+			verbosePrint(vERROR, "marshal tests reply: %v", err)
+			code, wbody = http.StatusInternalServerError, []byte(`{"err_code": -1, "err_msg": "marshal error"}`)
+		}
+		w.Header().Set("Content-Type", httpContentTypeJSON)
+		w.WriteHeader(code)
+		w.Write(wbody)
+	}
+	fail := func(code int, err error) {
+		reply(code, map[string]interface{}{"err_code": -1, "err_msg": err.Error()})
+	}
+
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, httpTestsPath), "/")
+	switch {
+	case id == "" && r.Method == "GET":
+		reply(http.StatusOK, listJobs())
+	case id == "" && r.Method == "POST":
+		// unknown fields are rejected, so that a typo is not ignored silently
+		var params StressParameters
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&params); err != nil {
+			fail(http.StatusBadRequest, fmt.Errorf("invalid parameters: %v", err))
+			return
+		}
+		test, code, err := startTest(params)
+		if err != nil {
+			fail(code, err)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("%s/%d", httpTestsPath, test.SequenceId))
+		reply(code, test)
+	case id == "schema" && r.Method == "GET":
+		reply(http.StatusOK, testsSchema())
+	case id == "" || id == "schema":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	default:
+		seqId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		test, ok := findTest(seqId)
+		switch {
+		case !ok:
+			fail(http.StatusNotFound, fmt.Errorf("sequence id %d not found", seqId))
+		case r.Method == "GET":
+			reply(http.StatusOK, test)
+		case r.Method != "DELETE":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case test.State != jobRunning:
+			fail(http.StatusConflict, fmt.Errorf("sequence id %d is finished", seqId))
+		default:
+			executeStress(StressParameters{Cmd: cmdStop, SequenceId: seqId})
+			verbosePrint(vINFO, "stop stress test %d by api", seqId)
+			// the final result is held once the test exits, GET it later
+			reply(http.StatusAccepted, map[string]interface{}{"err_code": 0, "err_msg": "", "sequence_id": seqId})
+		}
+	}
+}
+
+// testsSchema json schemas of the request and responses of /api/tests, generated from the types
+func testsSchema() map[string]interface{} {
+	defs := make(map[string]interface{})
+	request := typeSchema(reflect.TypeOf(StressParameters{}), defs)
+	test := typeSchema(reflect.TypeOf(stressTest{}), defs)
+	return map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$defs":   defs,
+		"request": request, // POST /api/tests
+		"test":    test,    // POST and GET /api/tests/{sequence id}
+		"list":    map[string]interface{}{"type": "array", "items": typeSchema(reflect.TypeOf(stressJob{}), defs)},
+	}
+}
+
+// typeSchema json schema of the values of t marshaled by encoding/json, named structs are defined in
+// defs and referenced, nil pointers, slices and maps are null
+func typeSchema(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	nullable := func(s map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"anyOf": []interface{}{s, map[string]interface{}{"type": "null"}}}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullable(typeSchema(t.Elem(), defs))
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string"} // base64
+		}
+		return nullable(map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), defs)})
+	case reflect.Map:
+		return nullable(map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), defs)})
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		if t.Name() == "" {
+			return structSchema(t, defs)
+		}
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = true // recursive types refer to the definition in progress
+			defs[t.Name()] = structSchema(t, defs)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	}
+	return map[string]interface{}{} // any
+}
+
+func structSchema(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var fields func(t reflect.Type)
+	fields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				fields(f.Type) // embedded fields are promoted
+				continue
+			}
+			if f.PkgPath != "" || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			properties[name] = typeSchema(f.Type, defs)
+		}
+	}
+	fields(t)
+	return map[string]interface{}{"type": "object", "properties": properties, "additionalProperties": false}
+}
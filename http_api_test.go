@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTestsApi(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	srv := httptest.NewServer(http.HandlerFunc(serveTests))
+	defer srv.Close()
+
+	do := func(method, path, body string) (*http.Response, string) {
+		req, _ := http.NewRequest(method, srv.URL+httpTestsPath+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s err: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp, string(b)
+	}
+
+	// the responses conform to the schemas
+	_, body := do("GET", "/schema", "")
+	var schemas map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &schemas); err != nil {
+		t.Fatalf("schema = %s, err: %v", body, err)
+	}
+	schemaOf := func(name string) *jsonSchema {
+		doc, _ := json.Marshal(map[string]interface{}{"$defs": schemas["$defs"], "allOf": []interface{}{schemas[name]}})
+		s, err := parseJsonSchema(doc)
+		if err != nil {
+			t.Fatalf("schema of %s err: %v", name, err)
+		}
+		return s
+	}
+	conform := func(name, body string) {
+		var v interface{}
+		json.Unmarshal([]byte(body), &v)
+		if path, reason, ok := schemaOf(name).validate(v); !ok {
+			t.Errorf("%s violates the schema of %s at %s: %s", body, name, path, reason)
+		}
+	}
+
+	seqId := time.Now().UnixNano()
+	start := fmt.Sprintf(`{"sequence_id": %d, "url": "%s", "duration": 10, "qps": 50}`, seqId, target.URL)
+	conform("request", start)
+	if _, _, ok := schemaOf("request").validate(map[string]interface{}{"concurrency": 2.0}); ok {
+		t.Errorf("schema of request expect unknown fields rejected")
+	}
+	resp, body := do("POST", "", start)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Location") != fmt.Sprintf("%s/%d", httpTestsPath, seqId) {
+		t.Fatalf("start = %d %s, location %q", resp.StatusCode, body, resp.Header.Get("Location"))
+	}
+	conform("test", body)
+
+	// found right after the start
+	path := fmt.Sprintf("/%d", seqId)
+	var test stressTest
+	if resp, body = do("GET", path, ""); resp.StatusCode != http.StatusOK || json.Unmarshal([]byte(body), &test) != nil ||
+		test.State != jobRunning || test.Url != target.URL {
+		t.Errorf("running test = %d %s", resp.StatusCode, body)
+	}
+	if resp, body = do("POST", "", start); resp.StatusCode != http.StatusConflict {
+		t.Errorf("start of running sequence id = %d %s, expect 409", resp.StatusCode, body)
+	}
+	_, body = do("GET", "", "")
+	conform("list", body)
+
+	time.Sleep(300 * time.Millisecond)
+	if resp, body = do("DELETE", path, ""); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("stop = %d %s", resp.StatusCode, body)
+	}
+	for i := 0; i < 50 && test.State != jobFinished; i++ {
+		time.Sleep(20 * time.Millisecond)
+		_, body = do("GET", path, "")
+		json.Unmarshal([]byte(body), &test)
+	}
+	if test.State != jobFinished || test.Result == nil || test.Result.LatsTotal <= 0 || test.FinishTime <= 0 {
+		t.Fatalf("stopped test = %s", body)
+	}
+	conform("test", body)
+
+	for _, tt := range []struct {
+		method, path, body string
+		code               int
+	}{
+		{"DELETE", path, "", http.StatusConflict},
+		{"GET", "/1", "", http.StatusNotFound},
+		{"GET", "/x", "", http.StatusNotFound},
+		{"POST", "", `{"url": "` + target.URL + `", "concurrency": 2}`, http.StatusBadRequest},
+		{"POST", "", `{"c": 2}`, http.StatusBadRequest},
+		{"PUT", "", `{}`, http.StatusMethodNotAllowed},
+	} {
+		if resp, body := do(tt.method, tt.path, tt.body); resp.StatusCode != tt.code {
+			t.Errorf("%s %s = %d %s, expect %d", tt.method, tt.path, resp.StatusCode, body, tt.code)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StressArrival requests of the open model of -rate, started on schedule regardless of responses
+type StressArrival struct {
+	Rate    float64 `json:"rate"`     // target arrivals per second
+	Started int64   `json:"started"`  // requests started
+	Dropped int64   `json:"dropped"`  // arrivals not started as all connections were busy
+	MaxBusy int64   `json:"max_busy"` // max requests in flight at the same time
+	Clients int64   `json:"clients"`  // connections created, -c are created before the first arrival
+}
+
+// parseRate parse -rate as arrivals per second, e.g. 500/s, 30/m or 500
+func parseRate(s string) (float64, error) {
+	num, unit, ok := strings.Cut(s, "/")
+	per := time.Second
+	if ok {
+		d, err := time.ParseDuration("1" + unit)
+		if err != nil || d <= 0 {
+			return 0, fmt.Errorf("invalid -rate %s, expect arrivals per unit, e.g. 500/s or 30/m", s)
+		}
+		per = d
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v <= 0 || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid -rate %s, expect arrivals per unit, e.g. 500/s or 30/m", s)
+	}
+	return v * float64(time.Second) / float64(per), nil
+}
+
+// maxArrivalClients connections of the open model, a request holds a connection no longer than the
+// timeout, so more than the arrivals within a timeout are only needed by a stuck generator
+func maxArrivalClients(rate float64, timeout, c int) int {
+	return int(math.Ceil(rate*float64(timeout)/1e3)) + c
+}
+
+// runArrivals start requests at the constant arrival rate of -rate until stop or -n requests, the
+// idle connections are reused and new connections are created when all are busy, the latency of a
+// request is measured from its scheduled start so that the delay of a busy generator is included
+func (b *StressWorker) runArrivals() {
+	var (
+		p        = b.RequestParams
+		interval = float64(time.Second) / p.ArrivalRate
+		max      = maxArrivalClients(p.ArrivalRate, p.Timeout, p.C)
+		idle     = make(chan *StressClient, max)
+		stats    = &StressArrival{Rate: p.ArrivalRate}
+		busy     int64
+		wg       sync.WaitGroup
+	)
+	for id := 0; id < p.C; id++ {
+		if client := b.newClient(id); client != nil {
+			idle <- client
+			stats.Clients++
+		}
+	}
+
+	start := time.Now()
+	for i := int64(0); !b.IsStop() && (p.N <= 0 || i < int64(p.N)); i++ {
+		at := start.Add(time.Duration(float64(i) * interval))
+		for wait := time.Until(at); wait > 0 && !b.IsStop(); wait = time.Until(at) {
+			if wait > 100*time.Millisecond {
+				wait = 100 * time.Millisecond // check stop in time
+			}
+			time.Sleep(wait)
+		}
+		if b.IsStop() {
+			break
+		}
+
+		var client *StressClient
+		select {
+		case client = <-idle:
+		default:
+			if stats.Clients < int64(max) {
+				if client = b.newClient(int(stats.Clients)); client != nil {
+					stats.Clients++
+				}
+			}
+		}
+		if client == nil {
+			stats.Dropped++
+			continue
+		}
+		stats.Started++
+		if n := atomic.AddInt64(&busy, 1); n > stats.MaxBusy {
+			stats.MaxBusy = n
+		}
+
+		wg.Add(1)
+		go func(client *StressClient, at time.Time) {
+			defer func() {
+				if r := recover(); r != nil {
+					verbosePrint(vERROR, "internal err: %v", r)
+				}
+				atomic.AddInt64(&busy, -1)
+				idle <- client
+				wg.Done()
+			}()
+			b.send(client, &result{start: at, family: b.ipFamily(client.id)})
+		}(client, at)
+	}
+	wg.Wait()
+
+	close(idle)
+	for client := range idle {
+		b.closeClient(client)
+	}
+	b.arrival = stats
+}
+
+func (a *StressArrival) merge(v *StressArrival) {
+	a.Rate += v.Rate
+	a.Started += v.Started
+	a.Dropped += v.Dropped
+	a.MaxBusy += v.MaxBusy
+	a.Clients += v.Clients
+}
+
+// printArrival Print offered and started load of the open model
+func (result *StressResult) printArrival(w io.Writer) {
+	a := result.Arrival
+	fprintln(w, "\nArrival rate (open model):")
+	fprintln(w, "  Target:\t%4.3f req/s", a.Rate)
+	if result.Duration > 0 {
+		fprintln(w, "  Started:\t%d requests, %4.3f req/s", a.Started, float64(a.Started)/float64(result.Duration))
+	} else {
+		fprintln(w, "  Started:\t%d requests", a.Started)
+	}
+	if a.Dropped > 0 {
+		fprintln(w, "  Dropped:\t%d arrivals, all connections busy", a.Dropped)
+	}
+	fprintln(w, "  Max in flight:\t%d requests", a.MaxBusy)
+	fprintln(w, "  Connections:\t%d", a.Clients)
+}
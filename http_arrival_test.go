@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	for s, expect := range map[string]float64{
+		"500/s":  500,
+		"500":    500,
+		"30/m":   0.5,
+		"1/ms":   1000,
+		"0.5/s":  0.5,
+		"500/x":  0,
+		"0/s":    0,
+		"-1/s":   0,
+		"fast/s": 0,
+	} {
+		rate, err := parseRate(s)
+		if (expect == 0) != (err != nil) || rate != expect {
+			t.Errorf("parseRate(%s) = %v, %v, expect: %v", s, rate, err, expect)
+		}
+	}
+}
+
+func TestStressArrivalRate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL,
+		C:             1,
+		N:             40,
+		Timeout:       3000,
+		ArrivalRate:   200,
+	})
+	elapsed := time.Since(start)
+	if result == nil || len(result.ErrorDist) > 0 || result.LatsTotal != 40 {
+		t.Fatalf("result of arrival rate = %+v", result)
+	}
+	// closed-loop with one connection takes 40 * 50ms
+	if elapsed > time.Second {
+		t.Errorf("40 arrivals at 200/s took %v, offered load is reduced by the latency", elapsed)
+	}
+	a := result.Arrival
+	if a == nil || a.Rate != 200 || a.Started != 40 || a.Dropped != 0 || a.Clients <= 1 || a.MaxBusy <= 1 {
+		t.Errorf("arrival = %+v", a)
+	}
+
+	var buf bytes.Buffer
+	result.printArrival(&buf)
+	for _, line := range []string{"Arrival rate (open model):", "  Target:\t200.000 req/s", "  Started:\t40 requests"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printArrival expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
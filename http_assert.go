@@ -0,0 +1,393 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	assertPassed   int8 = iota
+	assertViolated      // the response violated the assertion
+	assertMissing       // header, directive or json path of the assertion is missing
+)
+
+const assertBodyLimit = 1 << 20 // max response body bytes kept for body and json path assertions
+
+var (
+	assertOps = []string{" contains ", "<=", ">=", "==", "!=", "<", ">", "~"} // two chars first
+
+	// cacheDirectives directives of Cache-Control, which can be compared with headers, e.g. "age <= max-age"
+	cacheDirectives = map[string]bool{
+		"max-age":                true,
+		"s-maxage":               true,
+		"stale-while-revalidate": true,
+		"stale-if-error":         true,
+	}
+)
+
+// responseAssert assertion on response of -assert, e.g. "age < 60", "x-cache ~ HIT" and "age <= max-age" on
+// headers, "status == 200", "body contains ok" and "jsonpath $.code == 0" on status and body
+type responseAssert struct {
+	expr        string
+	left, right assertOperand
+	op          string
+}
+
+// assertOperand status, body, json path of body, response header, directive of Cache-Control, or literal
+type assertOperand struct {
+	status    bool
+	body      bool
+	jsonPath  []interface{} // keys and indexes of json path, e.g. $.data.items[0]
+	header    string        // canonical header name
+	directive string        // directive of Cache-Control, e.g. max-age
+	literal   string
+	isLiteral bool
+}
+
+// assertResponse response checked by assertions, json of body is parsed once
+type assertResponse struct {
+	status   int
+	header   http.Header
+	body     []byte
+	json     interface{}
+	jsonErr  error
+	jsonDone bool
+}
+
+// StressAssert responses checked by -assert on headers, violations are counted separately from errors
+type StressAssert struct {
+	Expr     string `json:"expr"`
+	Checked  int64  `json:"checked"`  // responses checked
+	Violated int64  `json:"violated"` // responses violated the assertion
+	Missing  int64  `json:"missing"`  // responses without the header or directive
+}
+
+// assertCheck state of the header assertion checked on one response
+type assertCheck struct {
+	expr  string
+	state int8
+}
+
+// parseAssert parse "<operand> <op> <number, string or directive>", operand is status, body,
+// "jsonpath <path>", a response header or a directive of Cache-Control, op is one of <, <=, >, >=,
+// ==, !=, ~ (contains, case-insensitive) and contains
+func parseAssert(expr string) (*responseAssert, error) {
+	a := &responseAssert{expr: strings.TrimSpace(expr)}
+	invalid := fmt.Errorf("invalid -assert %q, expect \"<operand> <op> <value>\", e.g. \"age < 60\" or \"status == 200\"", expr)
+	pos := -1
+	for i := 0; i < len(a.expr) && pos < 0; i++ {
+		for _, op := range assertOps {
+			if strings.HasPrefix(a.expr[i:], op) {
+				pos, a.op = i, op
+				break
+			}
+		}
+	}
+	if pos < 0 {
+		return nil, invalid
+	}
+
+	left, right := strings.TrimSpace(a.expr[:pos]), strings.TrimSpace(a.expr[pos+len(a.op):])
+	a.op = strings.TrimSpace(a.op)
+	if left == "" || right == "" {
+		return nil, invalid
+	}
+	switch name := strings.ToLower(left); {
+	case name == "status":
+		a.left.status = true
+	case name == "body":
+		a.left.body = true
+	case strings.HasPrefix(name, "jsonpath "):
+		path, err := parseJsonPath(strings.TrimSpace(left[len("jsonpath "):]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid -assert %q, %v", expr, err)
+		}
+		a.left.jsonPath = path
+	case strings.ContainsAny(left, " \t"):
+		return nil, invalid
+	case cacheDirectives[name]:
+		a.left.directive = name
+	default:
+		a.left.header = http.CanonicalHeaderKey(left)
+	}
+
+	switch name := strings.ToLower(right); {
+	case cacheDirectives[name]:
+		a.right.directive = name
+	case len(right) >= 2 && (right[0] == '"' || right[0] == '\'') && right[len(right)-1] == right[0]:
+		a.right.literal, a.right.isLiteral = right[1:len(right)-1], true
+	default:
+		a.right.literal, a.right.isLiteral = right, true
+	}
+
+	if a.right.isLiteral && isOrderOp(a.op) {
+		if _, err := strconv.ParseFloat(a.right.literal, 64); err != nil {
+			return nil, fmt.Errorf("invalid -assert %q, %s expects a number or directive of Cache-Control", expr, a.op)
+		}
+	}
+	if a.left.body && isOrderOp(a.op) {
+		return nil, fmt.Errorf("invalid -assert %q, body supports ==, !=, ~ and contains", expr)
+	}
+	return a, nil
+}
+
+// parseJsonPath parse json path of keys and indexes, e.g. $.data.items[0].id, $['key']
+func parseJsonPath(path string) ([]interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("json path starts with $, e.g. $.code")
+	}
+	var steps []interface{}
+	for rest := path[1:]; rest != ""; {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key of json path %s", path)
+			}
+			steps, rest = append(steps, rest[1:end+1]), rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ of json path %s", path)
+			}
+			key := rest[1:end]
+			if index, err := strconv.Atoi(key); err == nil {
+				steps = append(steps, index)
+			} else if len(key) >= 2 && (key[0] == '\'' || key[0] == '"') && key[len(key)-1] == key[0] {
+				steps = append(steps, key[1:len(key)-1])
+			} else {
+				return nil, fmt.Errorf("invalid index %s of json path %s", key, path)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid json path %s", path)
+		}
+	}
+	return steps, nil
+}
+
+func isOrderOp(op string) bool {
+	return op == "<" || op == "<=" || op == ">" || op == ">="
+}
+
+// onHeaders the assertion checks headers, whose violations are counted separately, the others on
+// status and body turn the response into an error
+func (a *responseAssert) onHeaders() bool {
+	return a.left.header != "" || a.left.directive != ""
+}
+
+// onBody the assertion needs the response body
+func (a *responseAssert) onBody() bool {
+	return a.left.body || a.left.jsonPath != nil
+}
+
+// value of the operand in the response, a missing Age is 0 which is fresh from the origin
+func (o assertOperand) value(r *assertResponse) (string, bool) {
+	switch {
+	case o.isLiteral:
+		return o.literal, true
+	case o.status:
+		return strconv.Itoa(r.status), true
+	case o.body:
+		return string(r.body), true
+	case o.jsonPath != nil:
+		return r.jsonValue(o.jsonPath)
+	case o.directive != "":
+		return cacheDirective(r.header, o.directive)
+	}
+	if v := r.header.Get(o.header); v != "" {
+		return v, true
+	}
+	if o.header == "Age" {
+		return "0", true
+	}
+	return "", false
+}
+
+// jsonValue value of json path in body, strings are unquoted and the others are json
+func (r *assertResponse) jsonValue(path []interface{}) (string, bool) {
+	if !r.jsonDone {
+		r.jsonDone, r.jsonErr = true, json.Unmarshal(r.body, &r.json)
+	}
+	if r.jsonErr != nil {
+		return "", false
+	}
+	v := r.json
+	for _, step := range path {
+		switch s := step.(type) {
+		case string:
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return "", false
+			}
+			if v, ok = m[s]; !ok {
+				return "", false
+			}
+		case int:
+			items, ok := v.([]interface{})
+			if !ok || s < 0 || s >= len(items) {
+				return "", false
+			}
+			v = items[s]
+		}
+	}
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	data, _ := json.Marshal(v)
+	return string(data), true
+}
+
+// cacheDirective value of directive of Cache-Control, e.g. max-age=60
+func cacheDirective(header http.Header, name string) (string, bool) {
+	for _, cc := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(cc, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(directive), "=")
+			if ok && strings.EqualFold(key, name) {
+				return strings.Trim(value, "\""), true
+			}
+		}
+	}
+	return "", false
+}
+
+// check the assertion on the response
+func (a *responseAssert) check(r *assertResponse) int8 {
+	left, ok := a.left.value(r)
+	if !ok {
+		return assertMissing
+	}
+	right, ok := a.right.value(r)
+	if !ok {
+		return assertMissing
+	}
+
+	l, lErr := strconv.ParseFloat(strings.TrimSpace(left), 64)
+	rv, rErr := strconv.ParseFloat(strings.TrimSpace(right), 64)
+	numeric := lErr == nil && rErr == nil
+	var passed bool
+	switch a.op {
+	case "<":
+		passed = numeric && l < rv
+	case "<=":
+		passed = numeric && l <= rv
+	case ">":
+		passed = numeric && l > rv
+	case ">=":
+		passed = numeric && l >= rv
+	case "==":
+		passed = (numeric && l == rv) || (!numeric && strings.EqualFold(left, right))
+	case "!=":
+		passed = (numeric && l != rv) || (!numeric && !strings.EqualFold(left, right))
+	case "~":
+		passed = strings.Contains(strings.ToLower(left), strings.ToLower(right))
+	case "contains":
+		passed = strings.Contains(left, right)
+	}
+	if !passed {
+		return assertViolated
+	}
+	return assertPassed
+}
+
+// checkAsserts check assertions on the response, return checks of header assertions, and the error
+// of the first failed assertion on status or body
+func checkAsserts(asserts []*responseAssert, r *assertResponse) ([]assertCheck, error) {
+	var (
+		checks []assertCheck
+		err    error
+	)
+	for _, a := range asserts {
+		state := a.check(r)
+		if a.onHeaders() {
+			checks = append(checks, assertCheck{expr: a.expr, state: state})
+		} else if state != assertPassed && err == nil {
+			err = fmt.Errorf("assertion failed: %s", a.expr)
+		}
+	}
+	return checks, err
+}
+
+// limitWriter keep at most max bytes written, the rest is dropped
+type limitWriter struct {
+	buf []byte
+	max int
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if n := w.max - len(w.buf); n > 0 {
+		if n > len(p) {
+			n = len(p)
+		}
+		w.buf = append(w.buf, p[:n]...)
+	}
+	return len(p), nil
+}
+
+// appendAsserts count checks of the response, assertions are in the order of -assert
+func (result *StressResult) appendAsserts(checks []assertCheck) {
+	for i, c := range checks {
+		if i >= len(result.Asserts) {
+			result.Asserts = append(result.Asserts, StressAssert{Expr: c.expr})
+		}
+		a := &result.Asserts[i]
+		a.Checked++
+		switch c.state {
+		case assertViolated:
+			a.Violated++
+		case assertMissing:
+			a.Missing++
+		}
+	}
+}
+
+// mergeAsserts merge assertions of worker by expression
+func (result *StressResult) mergeAsserts(asserts []StressAssert) {
+	for _, v := range asserts {
+		found := false
+		for i := range result.Asserts {
+			if a := &result.Asserts[i]; a.Expr == v.Expr {
+				a.Checked += v.Checked
+				a.Violated += v.Violated
+				a.Missing += v.Missing
+				found = true
+				break
+			}
+		}
+		if !found {
+			result.Asserts = append(result.Asserts, v)
+		}
+	}
+}
+
+// assertsViolated any response violated header assertions of -assert
+func (result *StressResult) assertsViolated() bool {
+	for _, a := range result.Asserts {
+		if a.Violated > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// printAsserts Print responses checked by -assert on headers
+func (result *StressResult) printAsserts(w io.Writer) {
+	fprintln(w, "\nHeader assertions:")
+	for _, a := range result.Asserts {
+		line := fmt.Sprintf("  [%s]\t%d checked, %d violated", a.Expr, a.Checked, a.Violated)
+		if a.Checked > 0 {
+			line += fmt.Sprintf(" (%.2f%%)", float64(a.Violated)*100/float64(a.Checked))
+		}
+		if a.Missing > 0 {
+			line += fmt.Sprintf(", %d missing the header", a.Missing)
+		}
+		fprintln(w, "%s", line)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAssert(t *testing.T) {
+	r := &assertResponse{
+		status: 200,
+		header: http.Header{
+			"Age":           {"30"},
+			"X-Cache":       {"Hit from cloudfront"},
+			"Cache-Control": {"public, max-age=60, s-maxage=\"20\""},
+		},
+		body: []byte(`{"code": 0, "msg": "ok", "data": {"items": [{"id": 7}], "name": "a b"}}`),
+	}
+	for _, tt := range []struct {
+		expr  string
+		state int8
+	}{
+		{"age < 60", assertPassed},
+		{"Age>=60", assertViolated},
+		{"age <= max-age", assertPassed},
+		{"age <= s-maxage", assertViolated},
+		{"max-age == 60", assertPassed},
+		{"x-cache ~ hit", assertPassed},
+		{"x-cache == 'Hit from cloudfront'", assertPassed},
+		{"x-cache != MISS", assertPassed},
+		{"x-served-by == edge", assertMissing},
+		{"age < stale-if-error", assertMissing},
+		{"status == 200", assertPassed},
+		{"status >= 400", assertViolated},
+		{"body contains \"msg\": \"ok\"", assertPassed},
+		{"body contains OK", assertViolated},
+		{"body ~ OK", assertPassed},
+		{"jsonpath $.code == 0", assertPassed},
+		{"jsonpath $.msg != ok", assertViolated},
+		{"jsonpath $.data.items[0].id > 5", assertPassed},
+		{"jsonpath $['data'].name == 'a b'", assertPassed},
+		{"jsonpath $.data.items[1].id == 7", assertMissing},
+	} {
+		a, err := parseAssert(tt.expr)
+		if err != nil {
+			t.Fatalf("parseAssert(%q) err: %v", tt.expr, err)
+		}
+		if state := a.check(r); state != tt.state {
+			t.Errorf("%q = %d, expect %d", tt.expr, state, tt.state)
+		}
+	}
+
+	// a missing Age is fresh from the origin
+	if a, _ := parseAssert("age < 1"); a.check(&assertResponse{header: http.Header{}}) != assertPassed {
+		t.Errorf("age < 1 without Age, expect passed")
+	}
+
+	for _, expr := range []string{"age", "< 60", "age < ", "age < fresh", "x cache == HIT", "body < 1",
+		"jsonpath code == 0", "jsonpath $.items[x] == 1", "jsonpath $..code == 0"} {
+		if _, err := parseAssert(expr); err == nil {
+			t.Errorf("parseAssert(%q) expect err", expr)
+		}
+	}
+}
+
+func TestAssertResult(t *testing.T) {
+	var requests int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// every other response is stale
+		age := 10
+		if atomic.AddInt64(&requests, 1)%2 == 0 {
+			age = 120
+		}
+		w.Header().Set("Age", strconv.Itoa(age))
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL,
+		C:             1,
+		N:             10,
+		Duration:      10,
+		Timeout:       2000,
+		Asserts:       []string{"age <= max-age", "x-cache ~ HIT"},
+	})
+	if len(result.Asserts) != 2 || result.ErrTotal() > 0 {
+		t.Fatalf("asserts = %+v, errors: %v", result.Asserts, result.ErrorDist)
+	}
+	fresh, cache := result.Asserts[0], result.Asserts[1]
+	if fresh.Expr != "age <= max-age" || fresh.Checked != result.LatsTotal || fresh.Violated != result.LatsTotal/2 {
+		t.Errorf("assert = %+v of %d responses, expect half violated", fresh, result.LatsTotal)
+	}
+	if cache.Missing != result.LatsTotal || cache.Violated != 0 {
+		t.Errorf("assert = %+v of %d responses, expect all missing", cache, result.LatsTotal)
+	}
+	if code := result.exitCode(); code != exitAssertion {
+		t.Errorf("exit code = %d, expect %d", code, exitAssertion)
+	}
+}
+
+func TestAssertErrors(t *testing.T) {
+	var requests int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// every other response is 200 with an error payload
+		if atomic.AddInt64(&requests, 1)%2 == 0 {
+			w.Write([]byte(`{"code": 500, "msg": "internal"}`))
+			return
+		}
+		w.Write([]byte(`{"code": 0, "msg": "ok"}`))
+	}))
+	defer srv.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL,
+		C:             1,
+		N:             10,
+		Duration:      10,
+		Timeout:       2000,
+		Asserts:       []string{"status == 200", "jsonpath $.code == 0"},
+	})
+	failed := int64(result.ErrorDist["assertion failed: jsonpath $.code == 0"])
+	if failed != 5 || result.LatsTotal != 6 || len(result.Asserts) != 0 {
+		t.Errorf("errors = %v, %d responses, expect 5 failed assertions of 11", result.ErrorDist, result.LatsTotal)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestListenAuth(t *testing.T) {
+	var verified int64
+	userinfo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&verified, 1)
+		switch r.Header.Get("Authorization") {
+		case "Bearer alice-token":
+			w.Write([]byte(`{"sub": "1001", "email": "alice@example.com"}`))
+		case "Bearer bob-token":
+			w.Write([]byte(`{"sub": "1002", "email": "bob@example.com"}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer userinfo.Close()
+
+	auth, err := newListenAuth([]string{"admin:s3cr:et", "guest:pass:viewer"}, userinfo.URL, "alice@example.com", false)
+	if err != nil {
+		t.Fatalf("newListenAuth err: %v", err)
+	}
+	handler := auth.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	metrics, start := `{"cmd": 2, "sequence_id": 1}`, `{"cmd": 0, "url": "http://127.0.0.1/"}`
+	for _, tt := range []struct {
+		method, path, body string
+		user, password     string
+		bearer             string
+		code               int
+	}{
+		{"GET", "/", "", "", "", "", http.StatusUnauthorized},
+		{"GET", "/", "", "admin", "s3cr", "", http.StatusUnauthorized},
+		{"GET", "/", "", "admin", "s3cr:et", "", http.StatusOK},
+		{"POST", httpWorkerApiPath, start, "admin", "s3cr:et", "", http.StatusOK},
+		{"GET", httpWorkerJobsPath, "", "guest", "pass", "", http.StatusOK},
+		{"POST", httpWorkerApiPath, metrics, "guest", "pass", "", http.StatusOK},
+		{"POST", httpWorkerApiPath, start, "guest", "pass", "", http.StatusForbidden},
+		{"POST", httpWorkerAnnotatePath, `{"msg": "deploy"}`, "guest", "pass", "", http.StatusForbidden},
+		{"PUT", httpWorkerJobsPath + "/1/rate", `{"qps": 1}`, "guest", "pass", "", http.StatusForbidden},
+		{"POST", httpWorkerApiPath, start, "", "", "alice-token", http.StatusOK},
+		{"POST", httpWorkerApiPath, start, "", "", "bob-token", http.StatusForbidden},
+		{"POST", httpWorkerApiPath, metrics, "", "", "bob-token", http.StatusOK},
+		{"GET", "/", "", "", "", "eve-token", http.StatusUnauthorized},
+		{"GET", httpSharePath + "0123", "", "", "", "", http.StatusOK},
+		{"OPTIONS", httpWorkerApiPath, "", "", "", "", http.StatusOK},
+	} {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.user != "" {
+			req.SetBasicAuth(tt.user, tt.password)
+		}
+		if tt.bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.bearer)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s %s %s%s = %d, expect: %d", tt.method, tt.path, tt.user, tt.bearer, w.Code, tt.code)
+		}
+	}
+	// alice and bob are verified once, eve is not cached
+	if v := atomic.LoadInt64(&verified); v != 3 {
+		t.Errorf("userinfo verified %d times, expect 3", v)
+	}
+
+	for _, v := range []string{"admin", ":pass", "admin:"} {
+		if _, _, err := parseAuthUser(v); err == nil {
+			t.Errorf("parseAuthUser(%q) expect err", v)
+		}
+	}
+	if a, _ := newListenAuth(nil, "", "", false); a != nil {
+		t.Errorf("newListenAuth without users and oidc = %v, expect nil", a)
+	}
+}
+
+func TestWorkerAuth(t *testing.T) {
+	auth, _ := newListenAuth([]string{"ctl:pass"}, "", "", false)
+	srv := httptest.NewServer(auth.wrap(http.HandlerFunc(serveWorker)))
+	defer srv.Close()
+
+	worker := strings.Replace(srv.URL, "http://", "http://ctl:pass@", 1)
+	if clock := probeClock(worker); clock == nil || strings.Contains(clock.Worker, "pass") {
+		t.Errorf("probeClock of worker with user = %+v, expect clock with the password hidden", clock)
+	}
+	if _, err := executeWorkerReq(srv.URL+httpWorkerApiPath, []byte(`{"cmd": 0}`)); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("executeWorkerReq without user err = %v, expect 401", err)
+	}
+	if v := redactWorker("eu=" + worker); v != "eu="+strings.Replace(worker, "pass", "xxxxx", 1) {
+		t.Errorf("redactWorker = %s", v)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+// StressBatch items of -batch, each request carries a json array of items
+type StressBatch struct {
+	Size  int   `json:"size"`  // items per request
+	Items int64 `json:"items"` // items of successful requests
+}
+
+// renderBatch compose a json array of size items, render writes the item of index
+func renderBatch(w *bytes.Buffer, size int, render func(w *bytes.Buffer, item int)) {
+	w.WriteByte('[')
+	for i := 0; i < size; i++ {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		render(w, i)
+	}
+	w.WriteByte(']')
+}
+
+func (batch *StressBatch) merge(v *StressBatch) {
+	if batch.Size < v.Size {
+		batch.Size = v.Size
+	}
+	batch.Items += v.Items
+}
+
+// printBatch Print effective throughput and latency per item of -batch
+func (result *StressResult) printBatch(w io.Writer) {
+	var itemsRps float64
+	if result.Duration > 0 {
+		itemsRps = float64(result.Batch.Items) / float64(result.Duration)
+	}
+	fprintln(w, "  Items/sec:\t%4.3f (%d items/request)", itemsRps, result.Batch.Size)
+	if result.Batch.Size > 0 {
+		fprintln(w, "  Average/item:\t%4.6f secs", float64(result.Average)/scaleNum/float64(result.Batch.Size))
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	bad := make(chan string, 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var items []struct {
+			Id int `json:"id"`
+		}
+		if err := json.Unmarshal(body, &items); err != nil || len(items) != 5 || items[4].Id != 4 {
+			select {
+			case bad <- string(body):
+			default:
+			}
+		}
+	}))
+	defer srv.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "POST",
+		RequestBody:   `{"id":{{ .Item }}}`,
+		Url:           srv.URL,
+		C:             2,
+		N:             20,
+		Duration:      10,
+		Timeout:       2000,
+		Batch:         5,
+	})
+	close(bad)
+	for body := range bad {
+		t.Errorf("batch body = %s, expect json array of 5 items", body)
+	}
+	if result.Batch == nil || result.Batch.Size != 5 || result.Batch.Items != result.LatsTotal*5 {
+		t.Errorf("batch = %+v of %d requests", result.Batch, result.LatsTotal)
+	}
+}
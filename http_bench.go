@@ -13,17 +13,20 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"mime/multipart"
 	"net"
 	"net/http"
 	_ "net/http/pprof"
 	gourl "net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"text/template"
 	"time"
@@ -37,22 +40,31 @@ import (
 
 //go:embed index.html
 var dashboardHtml string
-var globalStop int
+var globalStop int32 // set to 1 by the stop signal, read atomically
 
 const (
 	cmdStart int = iota
 	cmdStop
 	cmdMetrics
+	cmdCollect
+	cmdValidate
+	cmdClock
 
 	typeHttp1 = "http1"
 	typeHttp2 = "http2"
 	typeHttp3 = "http3"
 	typeWs    = "ws"
 	typeWss   = "wss"
-	typeTCP   = "tcp"  // TODO: fix next version
+	typeTCP   = "tcp"  // body written and response read over a kept-alive connection
+	typeUDP   = "udp"  // datagram and optional response datagram per request
 	typeGrpc  = "grpc" // TODO: next version to support
 
-	bodyHex = "hex" // hex body to request
+	bodyString   = "string"   // default body type
+	bodyHex      = "hex"      // hex body to request
+	bodyJson     = "json"     // json body with default content type
+	bodyXml      = "xml"      // xml body with default content type
+	bodyForm     = "form"     // form-urlencoded body with default content type
+	bodyProtobuf = "protobuf" // json body encoded to protobuf binary
 
 	vTRACE = 0
 	vDEBUG = 1
@@ -68,17 +80,93 @@ type StressParameters struct {
 	RequestBody        string              `json:"request_body"`        // Request Body.
 	RequestBodyType    string              `json:"request_bodytype"`    // Request BodyType, default string.
 	RequestScriptBody  string              `json:"request_script_body"` // Request Script Body.
+	RequestProto       string              `json:"request_proto"`       // Request .proto content for protobuf body.
+	RequestProtoMsg    string              `json:"request_proto_msg"`   // Request protobuf message full name.
+	RequestForm        []string            `json:"request_form"`        // Request form-urlencoded fields, "key=value template".
+	MultipartForm      []MultipartField    `json:"multipart_form"`      // Parts of the multipart/form-data body of -form.
+	BodyStream         string              `json:"body_stream"`         // File streamed from disk as the body of each request, read by each worker.
+	Chunked            bool                `json:"chunked"`             // Send the body with Transfer-Encoding: chunked instead of Content-Length.
+	QueryParams        []string            `json:"query_params"`        // Query parameters appended to the url, "key=value template".
 	RequestType        string              `json:"request_type"`        // Request Type
 	N                  int                 `json:"n"`                   // N is the total number of requests to make.
 	C                  int                 `json:"c"`                   // C is the concurrency level, the number of concurrent workers to run.
-	Duration           int64               `json:"duration"`            // D is the duration for stress test
+	Duration           int64               `json:"duration"`            // D is the duration for stress test, 0 runs until stopped.
+	Window             int64               `json:"window"`              // Rolling window(ms) of continuous mode.
 	Timeout            int                 `json:"timeout"`             // Timeout in ms.
 	Qps                int                 `json:"qps"`                 // Qps is the rate limit.
+	ArrivalRate        float64             `json:"arrival_rate"`        // Requests started per second independently of responses, 0 is closed-loop.
+	ThinkTime          int64               `json:"think_time"`          // Pause of a connection between requests in ms, the min of a random pause.
+	ThinkTimeMax       int64               `json:"think_time_max"`      // Max of a random pause in ms, equal to think_time if fixed, 0 is disabled.
+	Weight             int                 `json:"weight"`              // Share of -worker-max-c and -worker-max-q of workers against concurrent jobs, 0 is 1.
+	Mix                []MixOp             `json:"mix"`                 // Verbs picked randomly by weight for each request, empty is the method.
+	UrlStrategy        string              `json:"url_strategy"`        // Strategy to pick the url of targets for each request, random, roundrobin or weighted.
+	Targets            []UrlTarget         `json:"targets"`             // Urls of -url-file picked by the strategy in one run, empty is the url.
+	Data               []map[string]string `json:"data"`                // Rows of -data referenced by .Data of templates.
+	DataMode           string              `json:"data_mode"`           // Row selection of -data, sequential or random.
 	DisableCompression bool                `json:"disable_compression"` // DisableCompression is an option to disable compression in response
+	NoRedirects        bool                `json:"no_redirects"`        // Return 3xx responses instead of following the redirects.
+	MaxRedirects       int                 `json:"max_redirects"`       // Redirects followed by a request at most, 0 is 10.
+	EnableCookies      bool                `json:"enable_cookies"`      // Cookie jar per connection, the cookies of Set-Cookie are sent by its following requests.
 	DisableKeepAlives  bool                `json:"disable_keepalives"`  // DisableKeepAlives is an option to prevents re-use of TCP connections between different HTTP requests
+	DisableNoDelay     bool                `json:"disable_nodelay"`     // Enable Nagle's algorithm, TCP_NODELAY is set by default.
+	TcpKeepAlive       int64               `json:"tcp_keepalive"`       // TCP keepalive period in ms, 0 is default, -1 is disabled.
+	SendBuffer         int64               `json:"send_buffer"`         // Socket send buffer in bytes, 0 is default.
+	RecvBuffer         int64               `json:"recv_buffer"`         // Socket receive buffer in bytes, 0 is default.
 	Headers            map[string][]string `json:"headers"`             // Custom HTTP header.
 	Url                string              `json:"url"`                 // Request url.
 	Output             string              `json:"output"`              // Output represents the output type. If "csv" is provided, the output will be dumped as a csv stream.
+	OutputTemplate     string              `json:"output_template"`     // Output template content for "template" output type.
+	MaxBodyRead        int64               `json:"max_body_read"`       // Max response body bytes to read, 0 is unlimited.
+	FallbackUrl        string              `json:"fallback_url"`        // Fallback url used when the target is connection refused.
+	FallbackAfter      int64               `json:"fallback_after"`      // Switch to fallback url after connection refused in ms.
+	StartJitter        int64               `json:"start_jitter"`        // Stagger start of clients randomly within the window in ms.
+	Warmup             int64               `json:"warmup"`              // Requests started within the warm-up in ms are excluded from the result.
+	SignHmac           *hmacSign           `json:"sign_hmac"`           // Sign request with hmac after templates rendering.
+	TlsVerify          bool                `json:"tls_verify"`          // Verify server certificates and stapled OCSP.
+	TlsMinVersion      uint16              `json:"tls_min_version"`     // Min tls version, 0 is default of crypto/tls.
+	TlsCiphers         []uint16            `json:"tls_ciphers"`         // Cipher suites of TLS 1.2 and lower, empty is default.
+	TlsServerName      string              `json:"sni"`                 // Server name of SNI and verification, empty is the url host.
+	MinSamples         int64               `json:"min_samples"`         // Min successful responses to report latency percentiles.
+	LatencyResolution  int                 `json:"latency_resolution"`  // Significant digits of latency buckets, 0 is default.
+	AbortAfterErrors   int64               `json:"abort_after_errors"`  // Stop after the number of errors, 0 is unlimited.
+	SpoofCidr          []string            `json:"spoof_cidr"`          // Networks of randomIP, default any IPv4.
+	Interfaces         []string            `json:"interfaces"`          // Local interfaces or ips to bind connections round-robin.
+	LocalIps           []string            `json:"local_ips"`           // Source ips and cidr ranges rotated by dials of connections.
+	AutoHeaders        []string            `json:"auto_headers"`        // Headers computed from the rendered body, e.g. content-md5.
+	DedupHeader        string              `json:"dedup_header"`        // Header of unique request id.
+	DedupRepeat        int                 `json:"dedup_repeat"`        // Times each request id is sent.
+	DedupVerify        string              `json:"dedup_verify"`        // Url template to query times processed of request id.
+	Pipeline           int                 `json:"pipeline"`            // Requests pipelined per http1 connection, 0 is not pipelined.
+	ExpectContinue     int64               `json:"expect_continue"`     // Wait for 100 Continue before sending the body in ms, 0 is disabled.
+	LongPoll           int64               `json:"longpoll"`            // Server timeout of long-poll cycles in ms, 0 is not long-poll.
+	HoldConnections    int                 `json:"hold_connections"`    // Idle connections held without requests alongside the load.
+	HoldDuration       int64               `json:"hold_duration"`       // Hold idle connections in ms, 0 is until the load ends.
+	WsSubprotocols     []string            `json:"ws_subprotocols"`     // Websocket subprotocols offered in preference order.
+	WsCompression      bool                `json:"ws_compression"`      // Offer permessage-deflate of websocket.
+	WsOrigin           string              `json:"ws_origin"`           // Origin header of websocket handshake.
+	Flow               []FlowStep          `json:"flow"`                // Steps sent in order by each connection, empty is not a flow.
+	Asserts            []string            `json:"asserts"`             // Assertions on response headers, e.g. "age < 60".
+	Extracts           []string            `json:"extracts"`            // Values captured from responses into variables, e.g. "token=jsonpath:$.data.token".
+	JsonSchema         string              `json:"json_schema"`         // JSON Schema content to validate 2xx responses.
+	JsonSchemaSample   float64             `json:"schema_sample"`       // Fraction of 2xx responses validated, 0 is all.
+	JsonSchemaExamples int                 `json:"schema_examples"`     // Violations kept as examples per worker.
+	BodyBase           string              `json:"body_base"`           // Base json document the body is merged into as a JSON merge patch.
+	Resume             int64               `json:"resume"`              // Bytes read before each download is aborted and resumed, 0 is disabled.
+	AbortAfterBytes    int64               `json:"abort_after_bytes"`   // Bytes of the body read before the download is cancelled, 0 is disabled.
+	AbortAfter         int64               `json:"abort_after"`         // Cancel the download after the response headers in ms, 0 is disabled.
+	UdpNoResponse      bool                `json:"udp_no_response"`     // Send udp datagrams without reading responses.
+	TcpResponseSize    int64               `json:"tcp_response_size"`   // Bytes of a response of -p tcp, 0 reads what one read returns.
+	CompareIpFamily    bool                `json:"compare_ip_family"`   // Alternate connections between IPv4 and IPv6 of the host.
+	Resolve            []string            `json:"resolve"`             // Addresses pinned to host:port, e.g. "example.com:443:10.0.0.1".
+	DnsEachRequest     bool                `json:"dns_each_request"`    // Dial and resolve the host for every request.
+	Batch              int                 `json:"batch"`               // Items of body template composed into a json array per request, 0 is not batched.
+	Seed               int64               `json:"seed"`                // Seed of random functions of templates, 0 is random.
+	WorkerIndex        int                 `json:"worker_index"`        // Index of distributed worker to partition feeds.
+	WorkerCount        int                 `json:"worker_count"`        // Number of distributed workers, 0 is not distributed.
+	Polite             bool                `json:"polite"`              // Ramp down load when errors or 429/503 exceed thresholds.
+	PoliteErrors       float64             `json:"polite_errors"`       // Error rate threshold of polite mode.
+	PoliteOverload     float64             `json:"polite_overload"`     // 429/503 rate threshold of polite mode.
+	GcTuning           string              `json:"gc_tuning"`           // GOGC, ballast and memory limit tuning of the run, empty is not tuned.
 }
 
 func (p *StressParameters) String() string {
@@ -93,8 +181,53 @@ type (
 	result struct {
 		err           error
 		statusCode    int
+		start         time.Time
 		duration      time.Duration
-		contentLength int64
+		contentLength int64 // decompressed body size
+		wireLength    int64 // on-the-wire body size
+		truncated     bool  // body exceeds max read size
+		endpoint      string
+		tls           *StressTls    // captured once per host
+		retryAfter    time.Duration // Retry-After of 429/503 in polite mode
+		timeout       bool          // aborted by timeout, the duration is capped at the timeout
+		nearMiss      bool          // successful but close to the timeout
+		pipelined     bool          // sent on pipelined connection of -pipeline
+		pipelinePos   int           // requests ahead in the pipeline
+		expect        string        // reply of server to "Expect: 100-continue"
+		expectWait    time.Duration // time to 100 Continue after the headers written
+		asserts       []assertCheck // assertions of -assert checked on the response
+		items         int           // items in the request of -batch
+		longpoll      string        // answer of the poll of -longpoll
+		pollConnect   time.Duration // time until the poll written
+		pollWait      time.Duration // time waited for data after the poll written
+		flowStep      string        // step of -flow
+		flowIndex     int           // order of the step of -flow
+
+		schemaChecked bool                 // validated by -assert-json-schema
+		schemaExample *StressSchemaExample // violation of -assert-json-schema
+		resume        string               // outcome of the resumed download of -resume
+		abort         string               // outcome of the download of -abort-after-bytes or -abort-after
+		resumeWait    time.Duration        // time to the response of the resumed request
+		udp           bool                 // datagram of -p udp
+		udpSent       int64                // bytes of the datagram sent
+		udpReply      bool                 // response datagram received
+		tcp           bool                 // request of -p tcp
+		tcpSent       int64                // bytes of the request sent
+		tcpReconnect  bool                 // connection dialed again for the request
+		family        string               // ip family of the connection of -compare-ip-family
+		timings       [5]time.Duration     // dns, connect, tls, ttfb and transfer of the request, 0 if not happened
+		conn          int8                 // connNew, connReused or connNone, 0 if not traced
+		connIdle      bool                 // the reused connection was idle in the pool
+		rateLimit     *rateLimit           // quota advertised by rate limit headers of the response
+		mixMethod     string               // verb of -mix
+		target        string               // url of -url-strategy
+		cookies       bool                 // sent with the cookie jar of -enable-cookies
+		cookieSent    bool                 // the request has cookies
+		cookieSet     bool                 // the response has Set-Cookie
+		newSession    bool                 // the first cookie of the connection or the iteration of -flow
+		hops          []int                // 3xx status of the redirects followed
+		hopsExceeded  bool                 // failed after -max-redirects
+		unfollowed    bool                 // 3xx returned without following
 	}
 
 	StressWorker struct {
@@ -104,21 +237,125 @@ type (
 		workersResult             []StressResult // multi workers result
 		resultWg                  sync.WaitGroup // Wait some task finish
 		totalTime                 time.Duration
+		cpuUsage                  int64          // generator cpu usage(%)
+		gc                        *StressGC      // gc of the generator during the run
+		arrival                   *StressArrival // requests of the open model of -rate
+		warmup                    *StressWarmup  // requests excluded by -warmup
 		err                       error
+		stopped                   int32 // set to 1 by Stop, read atomically by IsStop
 		bodyTemplate, urlTemplate *template.Template
+		isStaticBody, isStaticUrl bool   // template without actions, rendered only once
+		staticBody                []byte // pre-encoded static body
+		staticUrl                 string // pre-rendered static url
+		isStaticParams            bool   // query parameters without actions, rendered into the static url
+		protoMessage              *protoMessage
+		formFields                []formField
+		paramFields               []formField      // query parameters of -param
+		multipartFields           []multipartField // parts of -form
+		multipartBoundary         string           // boundary of the multipart body of the worker
+		headerTemplates           []headerTemplate
+		fallbackUrl               *gourl.URL
+		startTime                 time.Time
+		windowStart               time.Time // start of current window of continuous mode
+		refusedSince              int64     // unix nano of the first connection refused, 0 is not refused
+		failover                  int32     // switched to fallback url
+		failoverAfter             int64     // ms from start to switch to fallback url
+		tlsHosts                  sync.Map
+		polite                    politeStats
+		politeRps                 int64 // rate limit of polite mode, 0 is unlimited
+		retryUntil                int64 // unix nano, pause sending until Retry-After
+		rateCurve                 []StressRate
+		sequence                  func() int64 // shared by connections
+		spoofNets                 []*net.IPNet // networks of randomIP
+		interfaces                []localInterface
+		localIps                  *localIpPool      // source addresses of -local-ip and -local-ip-range
+		preflightNotes            *StressPreflight  // limits of the generator raised or too low, nil if none
+		contention                *StressContention // share of the worker against concurrent jobs, guarded by shareMu
+		families                  []string          // ip families alternated by connections of -compare-ip-family
+		resolves                  map[string]string // addresses to dial of host:port pinned by -resolve
+		bodyBase                  *bodyBase         // base document of -body-base
+		bodyStream                *bodyStream       // file of -body-stream, nil if not opened
+		bodyStreamErr             error             // reason the file of -body-stream is not opened
+		hold                      *holdConns        // idle connections of -hold-connections
+		ws                        *wsConns          // handshakes of websocket connections
+		asserts                   []*responseAssert // assertions of -assert
+		assertBody                bool              // assertions need the response body
+		flowSteps                 []flowStep        // steps of -flow
+		mixOps                    []mixOp           // verbs of -mix
+		targets                   []urlTarget       // urls picked by -url-strategy
+		targetNext                uint64            // next url of roundrobin
+		dataNext                  uint64            // next row of -data in sequential mode
+		thinks                    int64             // pauses of -think-time
+		thinkTotal                int64             // ns paused of -think-time
+		errTotal                  int64             // errors counted by the result collector
+		dedupSent                 int64             // unique request ids sent
+		dedupIds                  []string          // request ids to verify
+		dedupMismatched           int64             // responses echo a different request id
+		dedupMu                   sync.Mutex
+		liveQps                   int64         // rate limit changed while running, 0 is -q, -1 is unlimited
+		inflight                  int64         // requests sent and not yet answered, for /metrics
+		concurrency               int64         // connections, changed while running
+		clients                   map[int]int64 // generation of running connections
+		clientGen                 int64
+		clientsDone               bool // all connections exited
+		clientsMu                 sync.Mutex
+		clientWg                  sync.WaitGroup
+
+		extracts    []*responseExtract // values captured from responses of -extract
+		extractBody bool               // extracts need the response body
+		schema      *schemaChecker     // validate responses of -assert-json-schema
+	}
+
+	// formField form-urlencoded field with value template
+	formField struct {
+		key      string
+		template *template.Template
 	}
 
 	StressClient struct {
-		httpClient *http.Client
-		wsClient   *websocket.Conn
-		tcpClient  *tcpConn
+		httpClient                *http.Client
+		wsClient                  *websocket.Conn
+		tcpClient                 *tcpConn
+		udpClient                 *udpConn
+		bodyTemplate, urlTemplate *template.Template // templates with per worker functions
+		formFields                []formField
+		paramFields               []formField      // query parameters of -param
+		multipartFields           []multipartField // parts of -form with per worker functions
+		headerTemplates           []headerTemplate
+		id                        int   // index of connection
+		iteration                 int64 // requests sent
+		lastSend                  time.Time
+		dedupId                   string // request id sent -dedup-repeat times
+		dedupLeft                 int
+		pipelinePos               int // requests ahead of the last request in the pipeline
+
+		flowSteps []flowStep        // steps of -flow with per worker functions
+		flowPos   int               // next step of -flow
+		vars      map[string]string // variables extracted from responses by -extract or the steps of -flow
+		mixOps    []mixOp           // verbs of -mix with per worker functions
+		targets   []urlTarget       // urls of -url-strategy with per worker functions
+		row       map[string]string // row of -data of the current iteration of -flow
+		random    *rand.Rand        // random source of the connection, seeded by -seed
+		session   bool              // the cookie jar of -enable-cookies received a cookie
+		hops      []int             // 3xx status of the redirects of the request sent
+		exceeded  bool              // the request sent failed after -max-redirects
 	}
 )
 
 func (b *StressWorker) Start() {
+	// read by the handlers of running stress tests
+	resultRdMutex.Lock()
+	b.startTime = time.Now()
+	b.windowStart = b.startTime
+	if b.RequestParams.Warmup > 0 {
+		b.warmup = &StressWarmup{Duration: b.RequestParams.Warmup}
+		b.windowStart = b.warmupEnd()
+	}
+	b.curResult = GetStressResult()
+	b.curResult.digits = b.RequestParams.LatencyResolution
+	resultRdMutex.Unlock()
 	b.resultChan = make(chan *result, 2*b.RequestParams.C+1)
 	b.workersResult = make([]StressResult, 0)
-	b.curResult = GetStressResult()
 	b.asyncCollectResult()
 	b.startClients()
 	verbosePrint(vINFO, "worker finished and waiting result")
@@ -126,15 +363,17 @@ func (b *StressWorker) Start() {
 
 // Stop stop stress worker and wait coroutine finish
 func (b *StressWorker) Stop(wait bool, err error) {
-	b.RequestParams.Cmd = cmdStop
-	b.err = err
+	if err != nil {
+		b.err = err // keep the reason of the first stop
+	}
+	atomic.StoreInt32(&b.stopped, 1)
 	if wait {
 		b.resultWg.Wait()
 	}
 }
 
 func (b *StressWorker) IsStop() bool {
-	return b.RequestParams.Cmd == cmdStop || globalStop == cmdStop
+	return atomic.LoadInt32(&b.stopped) == 1 || atomic.LoadInt32(&globalStop) == 1
 }
 
 func (b *StressWorker) WaitResult() *StressResult {
@@ -157,68 +396,90 @@ func (b *StressWorker) execute(n, sleep int, client *StressClient) {
 			return
 		}
 
+		if b.leave(client.id) {
+			return
+		}
+
+		if runCounts > 0 {
+			b.think(client) // between the requests of the virtual user
+			if b.IsStop() {
+				return
+			}
+		}
 		runCounts++
-		time.Sleep(time.Duration(sleep) * time.Microsecond)
+		if atomic.LoadInt64(&b.liveQps) == 0 {
+			time.Sleep(time.Duration(sleep) * time.Microsecond)
+		}
+		if b.RequestParams.Polite || atomic.LoadInt64(&b.liveQps) > 0 {
+			b.pace(client)
+		}
 
-		t := time.Now()
-		code, size, err := b.doClient(client)
+		b.send(client, &result{start: time.Now(), family: b.ipFamily(client.id)})
+	}
+}
 
-		b.resultChan <- &result{
-			statusCode:    code,
-			duration:      time.Now().Sub(t),
-			err:           err,
-			contentLength: size,
-		}
+// send do the request of client and collect its result, the latency is measured from res.start
+func (b *StressWorker) send(client *StressClient, res *result) {
+	atomic.AddInt64(&b.inflight, 1)
+	b.doClient(client, res)
+	atomic.AddInt64(&b.inflight, -1)
+	res.duration = time.Now().Sub(res.start)
+	if res.longpoll != "" {
+		res.duration = res.pollConnect // the wait is reported by -longpoll separately
+	}
+	b.markTimeout(res)
+	if b.RequestParams.Pipeline > 1 {
+		res.pipelined, res.pipelinePos = true, client.pipelinePos
+	}
+	b.resultChan <- res
 
-		if err != nil {
-			verbosePrint(vERROR, "err: %v", err)
-			b.Stop(false, err)
-			return
-		}
+	if res.err != nil {
+		verbosePrint(vDEBUG, "err: %v", res.err)
 	}
 }
 
-func (b *StressWorker) getClient() *StressClient {
+func (b *StressWorker) getClient(id int) *StressClient {
 	client := &StressClient{}
 	switch b.RequestParams.RequestType {
 	case typeHttp3:
+		tlsConfig := b.tlsConfig()
+		tlsConfig.RootCAs = http3Pool
 		client.httpClient = &http.Client{
 			Timeout: time.Duration(b.RequestParams.Timeout) * time.Millisecond,
 			Transport: &http3.RoundTripper{
-				TLSClientConfig: &tls.Config{
-					RootCAs:            http3Pool,
-					InsecureSkipVerify: true,
-				},
+				TLSClientConfig: tlsConfig,
 			},
 		}
 	case typeHttp2:
 		client.httpClient = &http.Client{
 			Timeout: time.Duration(b.RequestParams.Timeout) * time.Millisecond,
 			Transport: &http2.Transport{
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: true,
-				},
+				TLSClientConfig:    b.tlsConfig(),
 				DisableCompression: b.RequestParams.DisableCompression,
+				DialTLSContext: b.dialTLSContext(id, &net.Dialer{
+					Timeout: time.Duration(b.RequestParams.Timeout) * time.Millisecond,
+				}),
 			},
 		}
 	case typeHttp1:
 		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
+			TLSClientConfig:     b.tlsConfig(),
 			DisableCompression:  b.RequestParams.DisableCompression,
-			DisableKeepAlives:   b.RequestParams.DisableKeepAlives,
+			DisableKeepAlives:   b.RequestParams.DisableKeepAlives || b.RequestParams.DnsEachRequest,
 			TLSHandshakeTimeout: time.Duration(b.RequestParams.Timeout) * time.Millisecond,
 			TLSNextProto:        make(map[string]func(string, *tls.Conn) http.RoundTripper),
-			DialContext: (&net.Dialer{
+			DialContext: b.dialContext(id, &net.Dialer{
 				Timeout:   time.Duration(b.RequestParams.Timeout) * time.Second,
 				KeepAlive: time.Duration(60) * time.Second,
-			}).DialContext,
+			}),
 			MaxIdleConns:        10,
 			MaxIdleConnsPerHost: 10,
 			MaxConnsPerHost:     10,
 			IdleConnTimeout:     time.Duration(90) * time.Second,
 		}
+		if b.RequestParams.ExpectContinue > 0 {
+			tr.ExpectContinueTimeout = time.Duration(b.RequestParams.ExpectContinue) * time.Millisecond
+		}
 		if proxyUrl != nil {
 			tr.Proxy = http.ProxyURL(proxyUrl)
 		}
@@ -227,104 +488,479 @@ func (b *StressWorker) getClient() *StressClient {
 			Transport: tr,
 		}
 	case typeWs, typeWss:
-		c, _, err := websocket.DefaultDialer.Dial(b.RequestParams.Url, b.RequestParams.Headers)
+		c, err := b.dialWs(id)
 		if err != nil || c == nil {
 			verbosePrint(vERROR, "websocket err: %v", err)
 			return nil
 		}
 		client.wsClient = c
 	case typeTCP:
+		timeout := time.Duration(b.RequestParams.Timeout) * time.Millisecond
 		c, err := DialTCP(b.RequestParams.Url, ConnOption{
-			timeout:           time.Duration(b.RequestParams.Duration) * time.Second,
+			timeout:           timeout,
 			disableKeepAlives: b.RequestParams.DisableKeepAlives,
+			responseSize:      b.RequestParams.TcpResponseSize,
+			dial:              b.dialContext(id, &net.Dialer{Timeout: timeout}),
 		})
 		if err != nil || c == nil {
 			verbosePrint(vERROR, "tcp err: %s", err)
 			return nil
 		}
 		client.tcpClient = c
+	case typeUDP:
+		c, err := DialUDP(b.RequestParams.Url, time.Duration(b.RequestParams.Timeout)*time.Millisecond, b.RequestParams.UdpNoResponse)
+		if err != nil {
+			verbosePrint(vERROR, "udp err: %s", err)
+			return nil
+		}
+		client.udpClient = c
 	default:
 		verbosePrint(vERROR, "not support %s", b.RequestParams.RequestType)
 		return nil
 	}
+	if client.httpClient != nil {
+		client.httpClient.CheckRedirect = b.checkRedirect(client)
+		if b.RequestParams.EnableCookies {
+			client.httpClient.Jar = newCookieJar()
+		}
+	}
 
 	return client
 }
 
-func (b *StressWorker) doClient(client *StressClient) (code int, size int64, err error) {
+func (b *StressWorker) doClient(client *StressClient, res *result) {
+	if len(client.flowSteps) > 0 {
+		b.doFlow(client, res)
+		return
+	}
+
 	var urlBytes, bodyBytes bytes.Buffer
 	var url = b.RequestParams.Url
-
-	if b.urlTemplate != nil && len(url) > 0 {
-		b.urlTemplate.Execute(&urlBytes, nil)
+	var body []byte
+	var method = b.RequestParams.RequestMethod
+	var op *mixOp
+	if len(client.mixOps) > 0 {
+		op = pickMix(client.mixOps, client.random)
+		method, res.mixMethod = op.method, op.method
+	}
+	var target *urlTarget
+	if len(client.targets) > 0 {
+		target = b.pickTarget(client)
+		res.target = target.name
+		if target.method != "" {
+			method = target.method
+		}
+	}
+	var ctx = &requestContext{
+		URL:       url,
+		Method:    method,
+		WorkerID:  client.id,
+		Iteration: client.iteration,
+		Now:       time.Now(),
+
+		WorkerIndex: b.RequestParams.WorkerIndex,
+		WorkerCount: b.RequestParams.WorkerCount,
+		Vars:        client.vars,
+		Data:        b.pickRow(client),
+	}
+	if ctx.WorkerCount < 1 {
+		ctx.WorkerCount = 1
+	}
+	if b.isDedup() {
+		ctx.RequestId = b.nextRequestId(client)
+	}
+	client.iteration++
+
+	if op != nil && op.url != nil {
+		op.url.Execute(&urlBytes, ctx)
+	} else if target != nil {
+		target.url.Execute(&urlBytes, ctx)
+	} else if b.isStaticUrl {
+		urlBytes.WriteString(b.staticUrl)
+	} else if client.urlTemplate != nil && len(url) > 0 {
+		client.urlTemplate.Execute(&urlBytes, ctx)
 	} else {
 		urlBytes.WriteString(url)
 	}
+	ctx.URL = urlBytes.String()
+	if len(client.paramFields) > 0 && (op != nil || target != nil || !b.isStaticParams) {
+		ctx.URL = renderParams(ctx.URL, client.paramFields, ctx)
+		urlBytes.Reset()
+		urlBytes.WriteString(ctx.URL)
+	}
 
-	switch b.RequestParams.RequestBodyType {
-	case bodyHex:
-		hexb, hexbErr := hex.DecodeString(b.RequestParams.RequestBody)
-		if hexbErr != nil {
-			return -1, 0, errors.New("invalid hex: " + hexbErr.Error())
+	if op != nil && (op.noBody || op.body != nil) {
+		if op.body != nil {
+			op.body.Execute(&bodyBytes, ctx)
+			body = bodyBytes.Bytes()
 		}
-		bodyBytes.Write(hexb)
-	default:
-		if len(b.RequestParams.RequestBody) > 0 && b.bodyTemplate != nil {
-			b.bodyTemplate.Execute(&bodyBytes, nil)
-		} else {
-			bodyBytes.WriteString(b.RequestParams.RequestBody)
+	} else if target != nil && target.body != nil {
+		target.body.Execute(&bodyBytes, ctx)
+		body = bodyBytes.Bytes()
+	} else if b.RequestParams.BodyStream != "" {
+		// streamed from the file when the request is created
+	} else if b.isStaticBody {
+		body = b.staticBody
+	} else {
+		switch b.RequestParams.RequestBodyType {
+		case bodyHex:
+			hexb, hexbErr := hex.DecodeString(b.RequestParams.RequestBody)
+			if hexbErr != nil {
+				res.statusCode, res.err = -1, errors.New("invalid hex: "+hexbErr.Error())
+				return
+			}
+			bodyBytes.Write(hexb)
+		case bodyMultipart:
+			if err := renderMultipart(&bodyBytes, b.multipartBoundary, client.multipartFields, ctx); err != nil {
+				res.statusCode, res.err = -1, errors.New("multipart err: "+err.Error())
+				return
+			}
+		case bodyForm:
+			if len(client.formFields) > 0 {
+				renderForm(&bodyBytes, client.formFields, ctx)
+			} else if len(b.RequestParams.RequestBody) > 0 && client.bodyTemplate != nil {
+				client.bodyTemplate.Execute(&bodyBytes, ctx)
+			} else {
+				bodyBytes.WriteString(b.RequestParams.RequestBody)
+			}
+		default:
+			if b.RequestParams.Batch > 1 {
+				renderBatch(&bodyBytes, b.RequestParams.Batch, func(w *bytes.Buffer, item int) {
+					if client.bodyTemplate != nil {
+						itemCtx := *ctx
+						itemCtx.Item = item
+						client.bodyTemplate.Execute(w, &itemCtx)
+					} else {
+						w.WriteString(b.RequestParams.RequestBody)
+					}
+				})
+			} else if len(b.RequestParams.RequestBody) > 0 && client.bodyTemplate != nil {
+				client.bodyTemplate.Execute(&bodyBytes, ctx)
+			} else {
+				bodyBytes.WriteString(b.RequestParams.RequestBody)
+			}
+		}
+		body = bodyBytes.Bytes()
+
+		if b.bodyBase != nil {
+			var patchErr error
+			if body, patchErr = b.bodyBase.patch(body); patchErr != nil {
+				res.statusCode, res.err = -1, patchErr
+				return
+			}
+		}
+		if b.RequestParams.RequestBodyType == bodyProtobuf {
+			if b.protoMessage == nil {
+				res.statusCode, res.err = -1, errors.New("invalid protobuf message: "+b.RequestParams.RequestProtoMsg)
+				return
+			}
+			var protoErr error
+			if body, protoErr = b.protoMessage.encodeJSON(body); protoErr != nil {
+				res.statusCode, res.err = -1, protoErr
+				return
+			}
 		}
 	}
 
 	verbosePrint(vTRACE, "request url: %s, request type: %s, request bodytype: %s",
 		urlBytes.String(), b.RequestParams.RequestType, b.RequestParams.RequestBodyType)
-	verbosePrint(vTRACE, "request body: %s", bodyBytes.String())
+	verbosePrint(vTRACE, "request body: %s", string(body))
 
 	switch b.RequestParams.RequestType {
 	case typeHttp1, typeHttp2, typeHttp3:
-		req, reqErr := http.NewRequest(b.RequestParams.RequestMethod, urlBytes.String(), strings.NewReader(bodyBytes.String()))
+		req, reqErr := http.NewRequest(method, urlBytes.String(), bytes.NewReader(body))
 		if reqErr != nil || req == nil {
-			err = errors.New("request err: " + err.Error())
-			code = -1 // has errors
+			res.err = errors.New("request err: " + reqErr.Error())
+			res.statusCode = -1 // has errors
 			return
 		}
+		if b.RequestParams.BodyStream != "" {
+			if b.bodyStream == nil {
+				res.statusCode, res.err = -1, errors.New("body stream err: "+b.bodyStreamErr.Error())
+				return
+			}
+			b.bodyStream.attach(req)
+		}
+		if b.RequestParams.Chunked {
+			chunkBody(req)
+		}
+		if b.fallbackUrl != nil {
+			if atomic.LoadInt32(&b.failover) == 1 {
+				req.URL.Scheme, req.URL.Host, req.Host = b.fallbackUrl.Scheme, b.fallbackUrl.Host, b.fallbackUrl.Host
+			}
+			res.endpoint = req.URL.Scheme + "://" + req.URL.Host
+		}
+		headerTemplates := client.headerTemplates
 		req.Header = b.RequestParams.Headers
+		if target != nil {
+			req.Header, headerTemplates = target.header, target.headerTemplates
+		}
+		if len(headerTemplates) > 0 {
+			ctx.URL = req.URL.String()
+			req.Header = renderHeaders(req.Header, headerTemplates, ctx)
+		}
+		if b.RequestParams.DedupHeader != "" {
+			req.Header = req.Header.Clone()
+			if req.Header == nil {
+				req.Header = make(http.Header)
+			}
+			req.Header.Set(b.RequestParams.DedupHeader, ctx.RequestId)
+		}
+		if len(b.RequestParams.AutoHeaders) > 0 {
+			req.Header = req.Header.Clone()
+			if req.Header == nil {
+				req.Header = make(http.Header)
+			}
+			setAutoHeaders(req.Header, b.RequestParams.AutoHeaders, body)
+		}
+		if b.RequestParams.SignHmac != nil {
+			req.Header = req.Header.Clone()
+			if req.Header == nil {
+				req.Header = make(http.Header)
+			}
+			req.Header.Set(b.RequestParams.SignHmac.Header, b.RequestParams.SignHmac.sign(req, body))
+		}
+		if !b.RequestParams.DisableCompression && req.Header.Get("Accept-Encoding") == "" {
+			// request gzip explicitly, so that both wire and decompressed size are known
+			req.Header = req.Header.Clone()
+			if req.Header == nil {
+				req.Header = make(http.Header)
+			}
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+		var trace *expectTrace
+		if b.RequestParams.ExpectContinue > 0 && req.ContentLength != 0 {
+			req, trace = traceExpect(req)
+		}
+		var poll *pollTrace
+		if b.RequestParams.LongPoll > 0 {
+			req, poll = tracePoll(req)
+		}
+		var abort *abortTrace
+		if b.RequestParams.AbortAfterBytes > 0 || b.RequestParams.AbortAfter > 0 {
+			req, abort = traceAbort(req, b.RequestParams.AbortAfterBytes, b.RequestParams.AbortAfter)
+			defer abort.done()
+		}
+		var timing *timingTrace
+		if b.RequestParams.RequestType != typeHttp3 {
+			req, timing = traceTiming(req)
+		}
+		if client.httpClient.Jar != nil {
+			withCookies(req)
+		}
 		resp, respErr := client.httpClient.Do(req)
+		b.takeRedirects(client, resp, res)
+		if client.httpClient.Jar != nil {
+			client.trackCookies(req, resp, res)
+		}
+		if timing != nil {
+			timing.recordConn(res)
+		}
+		var download *resumeTrace
+		if b.RequestParams.Resume > 0 && respErr == nil {
+			download = &resumeTrace{offset: b.RequestParams.Resume}
+			if resp, res.err = download.resume(client.httpClient, req, resp, res); res.err != nil {
+				return
+			}
+		}
+		if b.fallbackUrl != nil && req.URL.Host != b.fallbackUrl.Host {
+			b.checkFailover(respErr)
+		}
 		if respErr != nil {
-			err = respErr
-			code = -99 // has errors
+			res.err = respErr
+			if category, ok := classifyTlsError(respErr); ok {
+				res.err = errors.New("tls certificate error: " + category)
+			}
+			res.statusCode = -99 // has errors
 			return
 		}
-		size = resp.ContentLength
-		code = resp.StatusCode
+		res.statusCode = resp.StatusCode
+		if b.RequestParams.Batch > 1 {
+			res.items = b.RequestParams.Batch
+		}
+		if trace != nil {
+			trace.classify(res, time.Duration(b.RequestParams.ExpectContinue)*time.Millisecond)
+		}
+		if h := b.RequestParams.DedupHeader; h != "" {
+			if echo := resp.Header.Get(h); echo != "" && echo != ctx.RequestId {
+				atomic.AddInt64(&b.dedupMismatched, 1)
+			}
+		}
+		if rl, ok := parseRateLimit(resp.Header, time.Now()); ok {
+			res.rateLimit = &rl
+		}
+		if b.RequestParams.Polite && isOverload(resp.StatusCode) {
+			res.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		if resp.TLS != nil {
+			res.tls = b.captureTls(req.URL.Host, resp.TLS)
+		}
 
 		defer resp.Body.Close()
-		if n, _ := fastRead(resp.Body, true); size <= 0 {
-			size = n
+		var (
+			capture = limitWriter{max: assertBodyLimit}
+			w       io.Writer
+		)
+		if b.assertBody || b.extractBody || b.schema != nil {
+			w = &capture
+		}
+		maxRead := b.RequestParams.MaxBodyRead
+		if abort != nil {
+			abort.start()
+			maxRead = abort.maxRead(maxRead)
+		}
+		res.contentLength, res.wireLength, res.truncated = readBody(resp, maxRead, w)
+		if abort != nil {
+			abort.classify(res)
+		}
+		if timing != nil {
+			timing.record(res)
+		}
+		if poll != nil {
+			poll.classify(res)
+		}
+		if download != nil {
+			download.classify(res, resp)
+		}
+		r := &assertResponse{status: resp.StatusCode, header: resp.Header, body: capture.buf}
+		if len(b.extracts) > 0 && resp.StatusCode < http.StatusBadRequest {
+			extractVars(b.extracts, r, client.vars)
+		}
+		if len(b.asserts) > 0 {
+			res.asserts, res.err = checkAsserts(b.asserts, r)
+		}
+		if b.schema != nil && res.contentLength <= int64(len(capture.buf)) {
+			if res.schemaChecked, res.schemaExample = b.schema.check(r); res.schemaExample != nil && res.err == nil {
+				res.err = errors.New("json schema violation")
+			}
 		}
 	case typeWs:
-		if err = client.wsClient.WriteMessage(websocket.TextMessage, bodyBytes.Bytes()); err != nil {
+		if res.err = client.wsClient.WriteMessage(websocket.TextMessage, body); res.err != nil {
 			return
 		}
 		messageType, message, readErr := client.wsClient.ReadMessage()
 		if readErr != nil {
-			err = readErr
-			code = -99 // has errors
+			res.err = readErr
+			res.statusCode = -99 // has errors
 			return
 		}
-		size = int64(len(message))
-		code = messageType
+		res.contentLength = int64(len(message))
+		res.wireLength = res.contentLength
+		res.statusCode = messageType
 	case typeTCP:
-		if size, err = client.tcpClient.Do(bodyBytes.Bytes()); err != nil {
-			code = -99 // has errors
+		res.tcp = true
+		dials := client.tcpClient.dials
+		res.tcpSent, res.contentLength, res.err = client.tcpClient.Do(body)
+		res.tcpReconnect = client.tcpClient.dials > dials
+		if res.err != nil {
+			res.statusCode = -99 // has errors
+			return
+		}
+		res.wireLength = res.contentLength
+		res.statusCode = http.StatusOK
+	case typeUDP:
+		res.udp = true
+		if res.udpSent, res.contentLength, res.err = client.udpClient.Do(body); res.err != nil {
+			res.statusCode = -99 // has errors
 			return
 		}
-		code = http.StatusOK
+		res.wireLength = res.contentLength
+		res.udpReply = !b.RequestParams.UdpNoResponse
+		res.statusCode = http.StatusOK
+	default:
+		res.statusCode = -98 // invalid type
+	}
+}
+
+// prepareStatic detect url and body without template actions, and render them once
+func (b *StressWorker) prepareStatic() {
+	var urlBytes, bodyBytes bytes.Buffer
+
+	if b.urlTemplate != nil && isStaticTemplate(b.urlTemplate) && b.urlTemplate.Execute(&urlBytes, nil) == nil {
+		b.isStaticUrl, b.staticUrl = true, urlBytes.String()
+		b.isStaticParams = true
+		for _, field := range b.paramFields {
+			b.isStaticParams = b.isStaticParams && isStaticTemplate(field.template)
+		}
+		if b.isStaticParams {
+			b.staticUrl = renderParams(b.staticUrl, b.paramFields, nil)
+		}
+	}
+
+	switch b.RequestParams.RequestBodyType {
+	case bodyHex:
+		if hexb, err := hex.DecodeString(b.RequestParams.RequestBody); err == nil {
+			b.isStaticBody, b.staticBody = true, hexb
+		}
+	case bodyMultipart:
+		if isStaticMultipart(b.multipartFields) && renderMultipart(&bodyBytes, b.multipartBoundary, b.multipartFields, nil) == nil {
+			b.isStaticBody, b.staticBody = true, bodyBytes.Bytes()
+		}
+	case bodyForm:
+		if len(b.formFields) > 0 {
+			for _, field := range b.formFields {
+				if !isStaticTemplate(field.template) {
+					return
+				}
+			}
+			renderForm(&bodyBytes, b.formFields, nil)
+			b.isStaticBody, b.staticBody = true, bodyBytes.Bytes()
+		} else if b.bodyTemplate != nil && isStaticTemplate(b.bodyTemplate) && b.bodyTemplate.Execute(&bodyBytes, nil) == nil {
+			b.isStaticBody, b.staticBody = true, bodyBytes.Bytes()
+		}
+	case bodyProtobuf:
+		if b.protoMessage != nil && b.bodyTemplate != nil && isStaticTemplate(b.bodyTemplate) && b.bodyTemplate.Execute(&bodyBytes, nil) == nil {
+			if protoBody, err := b.protoMessage.encodeJSON(bodyBytes.Bytes()); err == nil {
+				b.isStaticBody, b.staticBody = true, protoBody
+			}
+		}
 	default:
-		code = -98 // invalid type
+		if b.bodyTemplate != nil && isStaticTemplate(b.bodyTemplate) && b.bodyTemplate.Execute(&bodyBytes, nil) == nil {
+			b.isStaticBody, b.staticBody = true, bodyBytes.Bytes()
+			if b.bodyBase != nil {
+				patched, err := b.bodyBase.patch(b.staticBody)
+				b.isStaticBody, b.staticBody = err == nil, patched
+			}
+			if b.RequestParams.Batch > 1 {
+				var batch bytes.Buffer
+				renderBatch(&batch, b.RequestParams.Batch, func(w *bytes.Buffer, item int) { w.Write(b.staticBody) })
+				b.staticBody = batch.Bytes()
+			}
+		}
+	}
+
+	verbosePrint(vDEBUG, "static url: %v, static body: %v", b.isStaticUrl, b.isStaticBody)
+}
+
+// renderForm render form fields to form-urlencoded body, keys and values are escaped
+func renderForm(w *bytes.Buffer, fields []formField, ctx *requestContext) {
+	var value bytes.Buffer
+	for i, field := range fields {
+		if i > 0 {
+			w.WriteByte('&')
+		}
+		value.Reset()
+		field.template.Execute(&value, ctx)
+		w.WriteString(gourl.QueryEscape(field.key))
+		w.WriteByte('=')
+		w.WriteString(gourl.QueryEscape(value.String()))
 	}
+}
 
-	return
+// defaultContentType return Content-Type header of body type
+func defaultContentType(bodyType string) string {
+	switch bodyType {
+	case bodyJson:
+		return "application/json"
+	case bodyXml:
+		return "application/xml"
+	case bodyForm:
+		return "application/x-www-form-urlencoded"
+	case bodyProtobuf:
+		return "application/x-protobuf"
+	default:
+		return ""
+	}
 }
 
 func (b *StressWorker) closeClient(client *StressClient) {
@@ -335,33 +971,165 @@ func (b *StressWorker) closeClient(client *StressClient) {
 		client.wsClient.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 	case typeTCP:
 		client.tcpClient.Close()
+	case typeUDP:
+		client.udpClient.Close()
 	default:
 		// pass
 	}
 }
 
+// checkFailover switch to fallback url when the target keeps connection refused longer than the threshold
+func (b *StressWorker) checkFailover(err error) {
+	if err == nil || !errors.Is(err, syscall.ECONNREFUSED) {
+		atomic.StoreInt64(&b.refusedSince, 0)
+		return
+	}
+
+	now := time.Now().UnixNano()
+	atomic.CompareAndSwapInt64(&b.refusedSince, 0, now)
+	if now-atomic.LoadInt64(&b.refusedSince) >= b.RequestParams.FallbackAfter*int64(time.Millisecond) &&
+		atomic.CompareAndSwapInt32(&b.failover, 0, 1) {
+		atomic.StoreInt64(&b.failoverAfter, int64(time.Since(b.startTime)/time.Millisecond))
+		verbosePrint(vINFO, "connection refused, switch to fallback url: %s", b.RequestParams.FallbackUrl)
+	}
+}
+
+var errAborted = errors.New("aborted")
+
+// abortAfterErrors error threshold of the worker, the threshold is split evenly across distributed workers
+func (b *StressWorker) abortAfterErrors() int64 {
+	limit, count := b.RequestParams.AbortAfterErrors, int64(b.RequestParams.WorkerCount)
+	if count > 1 {
+		limit = (limit + count - 1) / count
+	}
+	return limit
+}
+
+// finishResult set the stats and options reported with the result of the run or of a window of continuous
+// mode, called under resultRdMutex, stats of the whole run only, e.g. of -dedup-verify, are set by the collector
+func (b *StressWorker) finishResult(r *StressResult) {
+	r.RateCurve = b.rateCurve
+	r.Paced = b.RequestParams.Qps > 0 || b.RequestParams.ArrivalRate > 0 || b.RequestParams.Polite ||
+		atomic.LoadInt64(&b.liveQps) != 0
+	r.Socket = b.socket()
+	r.Hold = b.holdStats()
+	r.WebSocket = b.wsStats()
+	if r.Expect != nil {
+		r.Expect.Threshold = b.RequestParams.ExpectContinue
+	}
+	if r.LongPoll != nil {
+		r.LongPoll.Timeout = b.RequestParams.LongPoll
+	}
+	if r.Schema != nil {
+		r.Schema.Sample = b.schema.sample
+	}
+	if r.Resume != nil {
+		r.Resume.Offset = b.RequestParams.Resume
+	}
+	if r.Abort != nil {
+		r.Abort.Bytes, r.Abort.After = b.RequestParams.AbortAfterBytes, b.RequestParams.AbortAfter
+	}
+	if len(r.UrlDist) > 0 {
+		r.UrlStrategy = b.RequestParams.UrlStrategy
+	}
+	if b.RequestParams.ThinkTimeMax > 0 {
+		r.Think = b.thinkStats()
+	}
+	if r.Redirects != nil {
+		r.Redirects.Follow, r.Redirects.Max = !b.RequestParams.NoRedirects, b.maxRedirects()
+	}
+}
+
 func (b *StressWorker) asyncCollectResult() {
 	b.resultWg.Add(1)
 
 	go func() {
-		timeTicker := time.NewTicker(time.Duration(b.RequestParams.Duration) * time.Second)
-		defer func() {
-			timeTicker.Stop()
-			b.resultWg.Done()
-		}()
+		defer b.resultWg.Done()
+
+		var timeTick, windowTick, politeTick <-chan time.Time // nil channel blocks when the ticker is off
+		if b.isContinuous() {
+			windowTicker := time.NewTicker(b.window())
+			defer windowTicker.Stop()
+			windowTick = windowTicker.C
+		} else {
+			timeTicker := time.NewTicker(time.Duration(b.RequestParams.Duration)*time.Second + time.Duration(b.RequestParams.Warmup)*time.Millisecond)
+			defer timeTicker.Stop()
+			timeTick = timeTicker.C
+		}
+		if b.RequestParams.Polite {
+			politeTicker := time.NewTicker(politeWindow)
+			defer politeTicker.Stop()
+			politeTick = politeTicker.C
+		}
 
 		for {
 			select {
 			case res, ok := <-b.resultChan:
-				if !ok || (res != nil && res.err != nil) {
+				if !ok {
+					if recorder != nil {
+						recorder.flush()
+					}
+					resultRdMutex.Lock()
 					b.curResult.Duration = int64(b.totalTime.Seconds())
-					if res != nil && res.err != nil {
-						b.err = res.err
+					if b.isContinuous() || b.warmup != nil {
+						b.curResult.Duration = int64(time.Since(b.windowStart).Seconds())
+					}
+					if b.curResult.Duration < 0 {
+						b.curResult.Duration = 0 // stopped within -warmup
+					}
+					b.curResult.CpuUsage = b.cpuUsage
+					b.curResult.GC = b.gc
+					b.curResult.Arrival = b.arrival
+					b.curResult.Warmup = b.warmup
+					if atomic.LoadInt32(&b.failover) == 1 {
+						b.curResult.FailoverUrl = b.RequestParams.FallbackUrl
+						b.curResult.FailoverAfter = atomic.LoadInt64(&b.failoverAfter)
 					}
+					if b.isDedup() {
+						b.curResult.Dedup = b.finishDedup()
+					}
+					if len(b.interfaces) > 0 {
+						b.curResult.InterfaceDist = b.interfaceDist()
+					}
+					b.curResult.LocalIp = b.localIpStats()
+					if b.preflightNotes != nil {
+						b.curResult.Preflight = []StressPreflight{*b.preflightNotes}
+					}
+					if c := b.contentionStats(); c != nil {
+						b.curResult.Contention = []StressContention{*c}
+					}
+					b.finishResult(b.curResult)
+					resultRdMutex.Unlock()
+					// probes of the diagnosis take time, the running result is not locked meanwhile
+					diagnosis := b.diagnose(b.curResult)
+					resultRdMutex.Lock()
+					b.curResult.Diagnosis = diagnosis
+					resultRdMutex.Unlock()
 					return
 				}
-				b.curResult.append(res)
-			case <-timeTicker.C:
+				warmup := b.inWarmup(res)
+				if warmup {
+					b.warmup.append(res)
+				} else {
+					b.curResult.append(res)
+				}
+				if recorder != nil {
+					recorder.record(b.RequestParams.SequenceId, res, warmup)
+				}
+				if res.err != nil && b.RequestParams.AbortAfterErrors > 0 {
+					if b.errTotal++; b.errTotal == b.abortAfterErrors() {
+						eprintln("stop after %d failed requests", b.errTotal)
+						b.Stop(false, fmt.Errorf("%w after %d errors (-abort-after-errors)", errAborted, b.errTotal))
+					}
+				}
+				if b.RequestParams.Polite {
+					b.politeCount(res)
+				}
+			case now := <-politeTick:
+				b.politeAdjust(now)
+			case now := <-windowTick:
+				b.printWindow(now)
+			case <-timeTick:
 				verbosePrint(vINFO, "time ticker upcoming, duration: %ds", b.RequestParams.Duration)
 				b.Stop(false, nil) // Time ticker exec Stop commands
 			}
@@ -370,116 +1138,398 @@ func (b *StressWorker) asyncCollectResult() {
 }
 
 func (b *StressWorker) startClients() {
-	println("running %d connections, @ %s", b.RequestParams.C, b.RequestParams.Url)
+	b.joinShare()
+	eprintln("running %d connections, @ %s", b.RequestParams.C, b.RequestParams.Url)
 
 	var (
-		wg               sync.WaitGroup
-		err              error
-		startTime        = time.Now()
-		bodyTemplateName = fmt.Sprintf("BODY-%d", b.RequestParams.SequenceId)
-		urlTemplateName  = fmt.Sprintf("URL-%d", b.RequestParams.SequenceId)
+		startTime    = b.startTime
+		startCPUTime = processCPUTime()
 	)
+	b.gc = b.tuneGC()
 
-	if b.urlTemplate, err = template.New(urlTemplateName).Funcs(fnMap).Parse(b.RequestParams.Url); err != nil {
-		verbosePrint(vERROR, "parse urls function err: "+err.Error())
+	b.prepare()
+	b.preflightNotes = b.preflight()
+	if b.RequestParams.HoldConnections > 0 {
+		b.startHold()
 	}
-
-	if b.bodyTemplate, err = template.New(bodyTemplateName).Funcs(fnMap).Parse(b.RequestParams.RequestBody); err != nil {
-		verbosePrint(vERROR, "parse request body function err: "+err.Error())
+	if t := b.RequestParams.RequestType; t == typeWs || t == typeWss {
+		b.startWs()
 	}
 
-	// ignore the case where b.RequestParams.N % b.RequestParams.C != 0.
-	for i := 0; i < b.RequestParams.C && !b.IsStop(); i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+	b.clients = make(map[int]int64, b.RequestParams.C)
+	if b.RequestParams.ArrivalRate > 0 {
+		b.runArrivals()
+	} else {
+		b.setConcurrency(b.RequestParams.C)
+		b.clientWg.Wait()
+	}
+	b.Stop(false, nil)
+	b.stopHold()
+	b.leaveShare()
+	b.bodyStream.close()
 
-			client := b.getClient()
-			if client == nil {
-				return
-			}
+	b.totalTime = time.Now().Sub(startTime)
+	if b.totalTime > 0 {
+		b.cpuUsage = int64((processCPUTime() - startCPUTime) * 100 / (b.totalTime * time.Duration(runtime.GOMAXPROCS(-1))))
+	}
+	b.gc.finish()
+	close(b.resultChan)
+}
 
-			defer func() {
-				b.closeClient(client)
-				if r := recover(); r != nil {
-					verbosePrint(vERROR, "internal err: %v", r)
-				}
-			}()
+// runClient run requests of connection id until stop, n is reached or the concurrency is decreased
+func (b *StressWorker) runClient(id int, gen int64) {
+	defer b.clientWg.Done()
+	defer b.clientExit(id, gen)
 
-			sleep := 0
-			if b.RequestParams.Qps > 0 {
-				sleep = 1e6 / (b.RequestParams.C * b.RequestParams.Qps) // sleep XXus send request
-			}
+	if b.RequestParams.StartJitter > 0 {
+		time.Sleep(time.Duration(rand.Int63n(b.RequestParams.StartJitter)) * time.Millisecond)
+		if b.IsStop() {
+			return
+		}
+	}
 
-			b.execute(b.RequestParams.N/b.RequestParams.C, sleep, client)
-		}()
+	client := b.newClient(id)
+	if client == nil {
+		return
 	}
 
-	wg.Wait()
-	b.Stop(false, nil)
+	defer func() {
+		b.closeClient(client)
+		if r := recover(); r != nil {
+			verbosePrint(vERROR, "internal err: %v", r)
+		}
+	}()
 
-	b.totalTime = time.Now().Sub(startTime)
-	close(b.resultChan)
+	sleep := 0
+	if b.RequestParams.Qps > 0 {
+		sleep = 1e6 / (b.RequestParams.C * b.RequestParams.Qps) // sleep XXus send request
+	}
+
+	// ignore the case where b.RequestParams.N % b.RequestParams.C != 0.
+	if b.RequestParams.Pipeline > 1 {
+		b.executePipeline(b.RequestParams.N/b.RequestParams.C, sleep, client)
+		return
+	}
+	b.execute(b.RequestParams.N/b.RequestParams.C, sleep, client)
 }
 
-func executeStress(params StressParameters) (*StressWorker, *StressResult) {
+// prepare parse templates of url, body, form and headers, and render the static ones
+func (b *StressWorker) prepare() {
 	var (
-		stressTesting        *StressWorker
-		stressResult         *StressResult
-		isDistributedTesting bool
+		err              error
+		bodyTemplateName = fmt.Sprintf("BODY-%d", b.RequestParams.SequenceId)
+		urlTemplateName  = fmt.Sprintf("URL-%d", b.RequestParams.SequenceId)
 	)
 
-	if len(workerList) > 0 {
-		isDistributedTesting = true
+	if b.urlTemplate, err = template.New(urlTemplateName).Funcs(fnMap).Parse(b.RequestParams.Url); err != nil {
+		verbosePrint(vERROR, "parse urls function err: "+err.Error())
 	}
 
-	if v, ok := stressList.Load(params.SequenceId); ok && v != nil {
-		stressTesting = v.(*StressWorker)
-	} else {
-		stressTesting = &StressWorker{RequestParams: &params}
-		stressList.Store(params.SequenceId, stressTesting)
+	if b.bodyTemplate, err = template.New(bodyTemplateName).Funcs(fnMap).Parse(b.RequestParams.RequestBody); err != nil {
+		verbosePrint(vERROR, "parse request body function err: "+err.Error())
 	}
 
-	jsonBody, err := json.Marshal(params)
-	if err != nil {
-		verbosePrint(vERROR, "invalid stress testing params!")
+	for i, field := range b.RequestParams.RequestForm {
+		key, value, _ := strings.Cut(field, "=")
+		tpl, err := template.New(fmt.Sprintf("FORM-%d-%d", b.RequestParams.SequenceId, i)).Funcs(fnMap).Parse(value)
+		if err != nil {
+			verbosePrint(vERROR, "parse form function err: "+err.Error())
+			continue
+		}
+		b.formFields = append(b.formFields, formField{key: key, template: tpl})
 	}
 
-	switch params.Cmd {
-	case cmdStart:
-		if isDistributedTesting {
-			stressTesting.workersResult = waitWorkerListReq(jsonBody)
-			stressResult = stressTesting.WaitWorkersResult()
-		} else {
-			stressTesting.Start()
-			stressResult = stressTesting.WaitResult()
+	for i, field := range b.RequestParams.QueryParams {
+		key, value, _ := strings.Cut(field, "=")
+		tpl, err := template.New(fmt.Sprintf("PARAM-%d-%d", b.RequestParams.SequenceId, i)).Funcs(fnMap).Parse(value)
+		if err != nil {
+			verbosePrint(vERROR, "parse param function err: "+err.Error())
+			continue
 		}
-		if stressResult != nil && !isDistributedTesting {
-			stressResult.print()
+		b.paramFields = append(b.paramFields, formField{key: key, template: tpl})
+	}
+
+	if contentType := defaultContentType(b.RequestParams.RequestBodyType); contentType != "" &&
+		http.Header(b.RequestParams.Headers).Get("Content-Type") == "" {
+		headers := http.Header(b.RequestParams.Headers).Clone()
+		if headers == nil {
+			headers = make(http.Header)
 		}
-		stressList.Delete(params.SequenceId)
-	case cmdStop:
-		if isDistributedTesting {
-			waitWorkerListReq(jsonBody)
+		headers.Set("Content-Type", contentType)
+		b.RequestParams.Headers = headers
+	}
+
+	if len(b.RequestParams.MultipartForm) > 0 {
+		if b.multipartFields, err = parseMultipartFields(b.RequestParams.MultipartForm, b.RequestParams.SequenceId); err != nil {
+			verbosePrint(vERROR, "parse form err: "+err.Error())
 		}
-		stressTesting.Stop(true, nil)
+		// the boundary is in the Content-Type, so it is fixed for the requests of the worker
+		mw := multipart.NewWriter(io.Discard)
+		b.multipartBoundary = mw.Boundary()
+		headers := http.Header(b.RequestParams.Headers).Clone()
+		if headers == nil {
+			headers = make(http.Header)
+		}
+		headers.Set("Content-Type", mw.FormDataContentType())
+		b.RequestParams.Headers = headers
+	}
+
+	if b.RequestParams.RequestBodyType == bodyProtobuf {
+		if b.protoMessage, err = parseProto(b.RequestParams.RequestProto, b.RequestParams.RequestProtoMsg); err != nil {
+			verbosePrint(vERROR, "parse protobuf err: "+err.Error())
+		}
+	}
+
+	if b.RequestParams.FallbackUrl != "" {
+		if b.fallbackUrl, err = gourl.Parse(b.RequestParams.FallbackUrl); err != nil {
+			verbosePrint(vERROR, "parse fallback url err: "+err.Error())
+		}
+	}
+
+	if b.headerTemplates, err = parseHeaderTemplates(b.RequestParams.Headers); err != nil {
+		verbosePrint(vERROR, "parse header function err: "+err.Error())
+	}
+
+	if len(b.RequestParams.Flow) > 0 {
+		if b.flowSteps, err = parseFlowSteps(b.RequestParams.Flow, b.RequestParams.Headers); err != nil {
+			verbosePrint(vERROR, "parse flow err: "+err.Error())
+		}
+	}
+	if b.mixOps, err = parseMixOps(b.RequestParams.Mix); err != nil {
+		verbosePrint(vERROR, "parse mix err: "+err.Error())
+	}
+	if b.targets, err = parseUrlTargets(b.RequestParams.Targets, b.RequestParams.Headers); err != nil {
+		verbosePrint(vERROR, "parse urls err: "+err.Error())
+	}
+
+	b.sequence = newSequence(b.RequestParams.WorkerIndex, b.RequestParams.WorkerCount)
+	if b.spoofNets, err = parseCidrs(b.RequestParams.SpoofCidr); err != nil {
+		verbosePrint(vERROR, "parse spoof cidr err: "+err.Error())
+	}
+	b.interfaces = nil
+	for _, name := range b.RequestParams.Interfaces {
+		ip, err := resolveInterface(name)
+		if err != nil {
+			verbosePrint(vERROR, "resolve interface err: "+err.Error())
+			continue
+		}
+		b.interfaces = append(b.interfaces, localInterface{name: name, ip: ip, stats: &StressInterface{}})
+	}
+	b.localIps = nil
+	if len(b.RequestParams.LocalIps) > 0 {
+		ips, err := parseLocalIps(b.RequestParams.LocalIps)
+		if err == nil {
+			err = checkLocalIps(ips)
+		}
+		if err != nil {
+			verbosePrint(vERROR, "local ip err: "+err.Error())
+		} else {
+			b.localIps = newLocalIpPool(ips)
+		}
+	}
+	if b.resolves, err = parseResolves(b.RequestParams.Resolve); err != nil {
+		verbosePrint(vERROR, "parse resolve err: "+err.Error())
+	}
+	b.families = nil
+	if b.RequestParams.CompareIpFamily {
+		if b.families, err = resolveFamilies(b.RequestParams.Url); err != nil {
+			verbosePrint(vERROR, "compare ip family err: "+err.Error())
+		}
+	}
+	b.asserts, b.assertBody = nil, false
+	for _, expr := range b.RequestParams.Asserts {
+		a, err := parseAssert(expr)
+		if err != nil {
+			verbosePrint(vERROR, "parse assert err: "+err.Error())
+			continue
+		}
+		b.asserts, b.assertBody = append(b.asserts, a), b.assertBody || a.onBody()
+	}
+	b.bodyBase = nil
+	if b.RequestParams.BodyBase != "" {
+		if b.bodyBase, err = parseBodyBase(b.RequestParams.BodyBase); err != nil {
+			verbosePrint(vERROR, "parse body base err: "+err.Error())
+		}
+	}
+	b.bodyStream, b.bodyStreamErr = nil, nil
+	if b.RequestParams.BodyStream != "" {
+		if b.bodyStream, b.bodyStreamErr = openBodyStream(b.RequestParams.BodyStream); b.bodyStreamErr != nil {
+			verbosePrint(vERROR, "open body stream err: "+b.bodyStreamErr.Error())
+		}
+	}
+	if b.schema, err = newSchemaChecker(b.RequestParams); err != nil {
+		verbosePrint(vERROR, "parse json schema err: "+err.Error())
+	}
+	b.extracts, b.extractBody = nil, false
+	for _, expr := range b.RequestParams.Extracts {
+		e, err := parseExtract(expr)
+		if err != nil {
+			verbosePrint(vERROR, "parse extract err: "+err.Error())
+			continue
+		}
+		b.extracts, b.extractBody = append(b.extracts, e), b.extractBody || e.onBody()
+	}
+
+	b.prepareStatic()
+}
+
+// clientSeed seed of random functions of connection id, unique across connections and distributed
+// workers, the same -seed generates the same data of each connection
+func (b *StressWorker) clientSeed(id int) int64 {
+	if b.RequestParams.Seed == 0 {
+		return time.Now().UnixNano() + int64(id)
+	}
+	return b.RequestParams.Seed + int64(b.RequestParams.WorkerIndex)<<32 + int64(id)
+}
+
+// newClient create client with templates of per worker functions
+func (b *StressWorker) newClient(id int) *StressClient {
+	client := b.getClient(id)
+	if client == nil {
+		return nil
+	}
+	client.id = id
+
+	r := rand.New(rand.NewSource(b.clientSeed(id)))
+	fnWorker := workerFnMap(b.RequestParams.WorkerIndex, b.RequestParams.WorkerCount, r)
+	fnWorker["sequence"] = b.sequence
+	fnWorker["elapsed"], fnWorker["phase"] = b.elapsed, b.phase
+	randomIP := fnWorker["randomIP"].(func(...string) (string, error))
+	fnWorker["randomIP"] = func(cidrs ...string) (string, error) {
+		if len(cidrs) > 0 {
+			return randomIP(cidrs...)
+		}
+		return randomIPIn(r, b.spoofNets), nil
+	}
+	client.urlTemplate = cloneTemplate(b.urlTemplate, fnWorker)
+	client.bodyTemplate = cloneTemplate(b.bodyTemplate, fnWorker)
+	for _, field := range b.formFields {
+		client.formFields = append(client.formFields, formField{key: field.key, template: cloneTemplate(field.template, fnWorker)})
+	}
+	for _, field := range b.paramFields {
+		client.paramFields = append(client.paramFields, formField{key: field.key, template: cloneTemplate(field.template, fnWorker)})
+	}
+	for _, field := range b.multipartFields {
+		field.template = cloneTemplate(field.template, fnWorker)
+		client.multipartFields = append(client.multipartFields, field)
+	}
+	for _, ht := range b.headerTemplates {
+		clientHt := headerTemplate{key: ht.key}
+		for _, tpl := range ht.values {
+			clientHt.values = append(clientHt.values, cloneTemplate(tpl, fnWorker))
+		}
+		client.headerTemplates = append(client.headerTemplates, clientHt)
+	}
+	for _, step := range b.flowSteps {
+		client.flowSteps = append(client.flowSteps, step.clone(fnWorker))
+	}
+	for _, op := range b.mixOps {
+		op.url, op.body = cloneTemplate(op.url, fnWorker), cloneTemplate(op.body, fnWorker)
+		client.mixOps = append(client.mixOps, op)
+	}
+	for _, target := range b.targets {
+		client.targets = append(client.targets, target.clone(fnWorker))
+	}
+	client.random = r
+	if len(b.extracts) > 0 {
+		client.vars = make(map[string]string, len(b.extracts))
+		for _, e := range b.extracts {
+			client.vars[e.name] = "" // empty until extracted from a response
+		}
+	}
+	return client
+}
+
+func executeStress(params StressParameters) (*StressWorker, *StressResult) {
+	var (
+		stressTesting        *StressWorker
+		stressResult         *StressResult
+		isDistributedTesting bool
+	)
+
+	if len(workerList) > 0 {
+		isDistributedTesting = true
+	}
+
+	if params.Cmd == cmdCollect {
+		stressResult = collectStress(params)
+		stressResult.Output, stressResult.OutputTemplate = params.Output, params.OutputTemplate
+		stressResult.checkSamples(params.MinSamples)
+		return nil, stressResult
+	}
+
+	if params.Cmd == cmdValidate {
+		stressResult = GetStressResult()
+		stressResult.Effective = (&StressWorker{RequestParams: &params}).effective()
+		return nil, stressResult
+	}
+
+	if params.Cmd == cmdClock {
+		return nil, replyClock()
+	}
+
+	if v, ok := stressList.Load(params.SequenceId); ok && v != nil {
+		stressTesting = v.(*StressWorker)
+	} else {
+		stressTesting = &StressWorker{RequestParams: &params}
+		if params.Cmd == cmdStart {
+			stressList.Store(params.SequenceId, stressTesting)
+		}
+	}
+
+	jsonBody, err := json.Marshal(params)
+	if err != nil {
+		verbosePrint(vERROR, "invalid stress testing params!")
+	}
+
+	switch params.Cmd {
+	case cmdStart:
+		if isDistributedTesting {
+			stressTesting.workersResult = waitWorkerListReq(jsonBody)
+			stressResult = stressTesting.WaitWorkersResult()
+			dead := takeDeadWorkers(params.SequenceId)
+			stressResult.DeadWorkers = append(stressResult.DeadWorkers, dead...)
+			if len(stressTesting.workersResult) <= 0 {
+				stressResult.ErrCode, stressResult.ErrMsg = -1, "no worker responded"
+			} else if len(dead) > 0 && failurePolicy == failureAbort {
+				stressResult.ErrCode = exitInternal
+				stressResult.ErrMsg = fmt.Sprintf("worker %s is dead, stopped by -worker-failure-policy abort", dead[0].Worker)
+			}
+		} else {
+			stressTesting.Start()
+			stressResult = stressTesting.WaitResult()
+		}
+		storeCollectResult(stressTesting, stressResult)
+		stressList.Delete(params.SequenceId)
+	case cmdStop:
+		if isDistributedTesting {
+			waitWorkerListReq(jsonBody)
+		}
+		stressTesting.Stop(true, nil)
 		stressList.Delete(params.SequenceId)
 	case cmdMetrics:
 		if isDistributedTesting {
 			workersResult := waitWorkerListReq(jsonBody)
 			stressResult = calMutliStressResult(nil, workersResult...)
+			sortWorkerMetrics(stressResult.WorkerMetrics)
 		} else {
-			if stressTesting.curResult != nil {
-				stressResult = calMutliStressResult(nil, *stressTesting.curResult)
-			}
+			stressResult = stressTesting.runningResult()
 		}
 	}
 
 	if stressTesting.err != nil {
 		stressResult.ErrCode = -1
+		if errors.Is(stressTesting.err, errAborted) {
+			stressResult.ErrCode = exitCircuitBreaker
+		}
 		stressResult.ErrMsg = stressTesting.err.Error()
 	}
 
+	if stressResult != nil {
+		stressResult.Output, stressResult.OutputTemplate = params.Output, params.OutputTemplate
+		stressResult.checkSamples(params.MinSamples)
+	}
+
 	return stressTesting, stressResult
 }
 
@@ -492,64 +1542,143 @@ func serveWorker(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if reqStr, err := io.ReadAll(r.Body); err == nil {
-		var params StressParameters
-		var result *StressResult
-		if err := json.Unmarshal(reqStr, &params); err != nil {
-			verbosePrint(vERROR, "unmarshal body err: %s", err.Error())
-			result = &StressResult{
-				ErrCode: -1,
-				ErrMsg:  err.Error(),
-			}
-		} else {
-			verbosePrint(vDEBUG, "request params: %s", params.String())
-			_, result = executeStress(params)
+	reqStr, err := io.ReadAll(http.MaxBytesReader(w, r.Body, workerMaxBody))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("request body exceeds %d bytes", workerMaxBody), http.StatusRequestEntityTooLarge)
+		return
+	}
+	var params StressParameters
+	var result *StressResult
+	if err := json.Unmarshal(reqStr, &params); err != nil {
+		verbosePrint(vERROR, "unmarshal body err: %s", err.Error())
+		result = &StressResult{
+			ErrCode: -1,
+			ErrMsg:  err.Error(),
+		}
+	} else if err := checkRemoteStream(params.BodyStream); err != nil {
+		verbosePrint(vERROR, "%v", err)
+		result = &StressResult{
+			ErrCode: -1,
+			ErrMsg:  err.Error(),
+		}
+	} else {
+		verbosePrint(vDEBUG, "request params: %s", params.String())
+		if _, result = executeStress(params); result != nil && params.Cmd == cmdStart {
+			result.print()
 		}
+	}
 
-		if result != nil {
-			wbody, err := result.marshal()
-			if err != nil {
-				verbosePrint(vERROR, "marshal result: %v", err)
-				return
-			}
-			w.Write(wbody)
+	if result != nil {
+		wbody, err := result.marshal()
+		if err != nil {
+			verbosePrint(vERROR, "marshal result: %v", err)
+			return
 		}
+		w.Write(wbody)
 	}
 }
 
 var waitWorkerListReq = func(paramsJson []byte) []StressResult {
 	var wg sync.WaitGroup
+	var mu sync.Mutex
 	var stressResult []StressResult
+	var params StressParameters
+	json.Unmarshal(paramsJson, &params)
 
-	for _, v := range workerList {
+	var abort sync.Once
+	for i, v := range workerList {
 		wg.Add(1)
 
-		addr := fmt.Sprintf("http://%s%s", v, httpWorkerApiPath)
-		if strings.Contains(v, "http://") || strings.Contains(v, "https://") {
-			addr = fmt.Sprintf("%s%s", v, httpWorkerApiPath)
-		}
-
-		go func(workerAddr string) {
+		region, _ := splitWorkerRegion(v)
+		go func(worker, workerAddr, region string, body []byte) {
 			defer wg.Done()
-			result, err := executeWorkerReq(workerAddr, paramsJson)
+			ctx := context.Background()
+			var heartbeat *workerHeartbeat
+			if params.Cmd == cmdStart {
+				probeClock(worker)
+				if heartbeatEvery > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithCancel(ctx)
+					defer cancel()
+					heartbeat = startHeartbeat(worker, params, cancel)
+				}
+			}
+			result, err := executeWorkerReqContext(ctx, workerAddr, body)
+			if heartbeat != nil {
+				if dead, partial := heartbeat.finish(err); dead != nil {
+					markDeadWorker(params.SequenceId, *dead)
+					if failurePolicy == failureAbort {
+						abort.Do(func() { stopWorkers(params) })
+					}
+					result, err = partial, nil
+				}
+			}
 			if err == nil && result != nil {
+				if params.Cmd == cmdMetrics {
+					tagWorkerMetrics(result, worker)
+				}
+				if region != "" {
+					regionResult(result, region)
+				}
+				if clock, ok := workerClocks.Load(worker); ok {
+					result.adjustClock(clock.(*StressClock))
+				}
+				mu.Lock()
 				stressResult = append(stressResult, *result)
+				mu.Unlock()
 			}
-		}(addr)
+		}(v, workerUrl(v, httpWorkerApiPath), region, workerBody(paramsJson, i))
 	}
 
 	wg.Wait()
 	return stressResult
 }
 
+// workerParams set index of worker to partition feeds, workers which have workers again partition
+// their own partition
+func workerParams(paramsJson []byte, index, count int) []byte {
+	var params StressParameters
+	if err := json.Unmarshal(paramsJson, &params); err != nil {
+		return paramsJson
+	}
+	if params.WorkerCount > 1 {
+		index, count = params.WorkerIndex*count+index, params.WorkerCount*count
+	}
+	params.WorkerIndex, params.WorkerCount = index, count
+	body, err := json.Marshal(params)
+	if err != nil {
+		return paramsJson
+	}
+	return body
+}
+
+// workerUrl url of api path of worker, the region label of worker is removed
+func workerUrl(worker, path string) string {
+	_, addr := splitWorkerRegion(worker)
+	if strings.Contains(addr, "http://") || strings.Contains(addr, "https://") {
+		return addr + path
+	}
+	return "http://" + addr + path
+}
+
 func executeWorkerReq(uri string, body []byte) (*StressResult, error) {
+	return executeWorkerReqContext(context.Background(), uri, body) // default not timeout
+}
+
+// executeWorkerReqContext send the command to the worker, cancelled by ctx
+func executeWorkerReqContext(ctx context.Context, uri string, body []byte) (*StressResult, error) {
 	verbosePrint(vDEBUG, "request body: %s", string(body))
-	resp, err := http.Post(uri, httpContentTypeJSON, bytes.NewBuffer(body)) // default not timeout
+	resp, err := doWorker(ctx, "POST", uri, body)
 	if err != nil {
-		verbosePrint(vERROR, "executeWorkerReq addr(%s) err: %s", uri, err.Error())
+		verbosePrint(vERROR, "executeWorkerReq addr(%s) err: %s", redactWorker(uri), err.Error())
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		err = fmt.Errorf("worker %s: %s, set the user of worker by -W \"http://user:password@IP:PORT\" or the same -worker-token", redactWorker(uri), resp.Status)
+		verbosePrint(vERROR, "executeWorkerReq %s", err.Error())
+		return nil, err
+	}
 
 	var result StressResult
 	respStr, _ := io.ReadAll(resp.Body)
@@ -561,7 +1690,7 @@ var (
 	stressList sync.Map
 	workerList flagSlice // Worker mechine addr list.
 
-	headerRegexp = `^([\w-]+):\s*(.+)`
+	headerRegexp = `^([^:\s]+):\s*(.+)`
 	authRegexp   = `^(.+):([^\s].+)`
 
 	proxyUrl   *gourl.URL
@@ -570,13 +1699,79 @@ var (
 	m          = flag.String("m", "GET", "")
 	body       = flag.String("body", "", "")
 	bodyType   = flag.String("bodytype", "", "")
+	protoFile  = flag.String("proto", "", "")
+	protoMsg   = flag.String("proto-msg", "", "")
 	authHeader = flag.String("a", "", "")
 
-	output = flag.String("o", "", "") // Output type
+	outputTplFile = flag.String("output-template", "", "") // Output template file for "-o template"
+	baselineFile  = flag.String("baseline", "", "")        // Previous json result to compare for "-o markdown"
+
+	maxBodyRead = flag.String("max-body-read", "", "") // Max response body size to read
+
+	seqId     = flag.Int64("seqid", 0, "")           // Sequence id of distributed stress test to collect
+	resultTTL = flag.String("result-ttl", "24h", "") // Keep finished results on worker
+
+	historyFile = flag.String("history-db", "", "") // Bolt file to persist finished results
+
+	recordFile   = flag.String("record", "", "")        // Ndjson file of the raw sample of every request
+	replayReport = flag.String("replay-report", "", "") // Report of the samples of -record without running
+
+	workerMaxC = flag.Int("worker-max-c", 0, "") // Connections of worker shared by concurrent jobs
+	workerMaxQ = flag.Int("worker-max-q", 0, "") // Rate limit of worker shared by concurrent jobs
+	jobWeight  = flag.Int("job-weight", 1, "")   // Share of the job on workers
+
+	latsDigits = flag.Int("latency-resolution", defaultLatsDigits, "") // Significant digits of latency buckets
+
+	abortErrors = flag.Int64("abort-after-errors", 0, "") // Stop after the number of errors
+	minSamples  = flag.Int64("min-samples", 0, "")        // Min successful responses to report percentiles
+	startJitter = flag.String("start-jitter", "", "")     // Stagger start of clients
+	warmup      = flag.String("warmup", "", "")           // Exclude requests of the warm-up from the result
+	seed        = flag.Int64("seed", 0, "")               // Seed of random functions of templates
+	pipeline    = flag.Int("pipeline", 0, "")             // Requests pipelined per http1 connection
+	expectWait  = flag.String("expect-continue", "", "")  // Wait for 100 Continue before sending the body
+	holdCount   = flag.Int("hold-connections", 0, "")     // Idle connections held alongside the load
+	holdTime    = flag.String("hold-duration", "", "")    // Hold idle connections, default until the load ends
+	batch       = flag.Int("batch", 0, "")                // Items composed into a json array per request
+	signHmac    = flag.String("sign-hmac", "", "")        // Sign request with hmac
+	window      = flag.String("window", "1m", "")         // Rolling window of continuous mode
+
+	dedupHeader = flag.String("dedup-header", "", "") // Header of unique request id
+	dedupRepeat = flag.Int("dedup-repeat", 1, "")     // Times each request id is sent
+	dedupVerify = flag.String("dedup-verify", "", "") // Url template to query times processed of request id
+
+	wsOrigin      = flag.String("ws-origin", "", "")       // Origin header of websocket handshake
+	wsCompression = flag.Bool("ws-compression", false, "") // Offer permessage-deflate of websocket
+
+	longPoll    = flag.Bool("longpoll", false, "")           // Long-poll cycles re-issued immediately
+	pollTimeout = flag.String("longpoll-timeout", "30s", "") // Server timeout of long-poll
+
+	flowFile = flag.String("flow", "", "") // Steps of requests sent in order by each connection as a virtual user
+
+	resume = flag.String("resume", "", "") // Bytes read before each download is aborted and resumed with Range
+
+	abortBytes = flag.String("abort-after-bytes", "", "") // Cancel the download after the bytes of the body
+	abortAfter = flag.String("abort-after", "", "")       // Cancel the download after the response headers
+
+	udpResponse = flag.Bool("udp-response", true, "") // Read a response datagram per request of -p udp
+
+	tcpResponseSize = flag.String("tcp-response-size", "", "") // Bytes of a response of -p tcp
+
+	compareFamily = flag.Bool("compare-ip-family", false, "") // Alternate connections between IPv4 and IPv6 of the host
+
+	dnsEachRequest = flag.Bool("dns-each-request", false, "") // Dial and resolve the host for every request
+
+	schemaFile     = flag.String("assert-json-schema", "", "")            // JSON Schema to validate 2xx responses
+	schemaSample   = flag.String("assert-json-schema-sample", "100%", "") // Fraction of 2xx responses validated
+	schemaExamples = flag.Int("assert-json-schema-examples", 5, "")       // Violations kept as examples per worker
+
+	fallbackUrl   = flag.String("fallback-url", "", "")     // Fallback url when target connection refused
+	fallbackAfter = flag.String("fallback-after", "3s", "") // Connection refused duration before fallback
 
 	c        = flag.Int("c", 50, "")              // Number of requests to run concurrently
 	n        = flag.Int("n", 0, "")               // Number of requests to run
 	q        = flag.Int("q", 0, "")               // Rate limit, in seconds (QPS)
+	rate     = flag.String("rate", "", "")        // Constant arrival rate of the open model, e.g. 500/s
+	mix      = flag.String("mix", "", "")         // Verbs picked randomly by weight, e.g. GET:80,POST:20
 	d        = flag.String("d", "10s", "")        // Duration for stress test
 	t        = flag.Int("t", 3000, "")            // Timeout in ms
 	httpType = flag.String("http", typeHttp1, "") // HTTP Version
@@ -584,10 +1779,29 @@ var (
 
 	printExample = flag.Bool("example", false, "")
 
-	cpus = flag.Int("cpus", runtime.GOMAXPROCS(-1), "")
+	cpus     = flag.Int("cpus", runtime.GOMAXPROCS(-1), "")
+	gcTuning = flag.String("gc-tuning", "", "") // Tune GOGC, heap ballast and memory limit of the run
+
+	thinkTime       = flag.String("think-time", "", "")        // Pause of a connection between requests, e.g. 200ms
+	thinkTimeRandom = flag.String("think-time-random", "", "") // Random pause between requests, e.g. 100ms-500ms
 
 	disableCompression = flag.Bool("disable-compression", false, "")
 	disableKeepAlives  = flag.Bool("disable-keepalive", false, "")
+	enableCookies      = flag.Bool("enable-cookies", false, "")             // Cookie jar per connection
+	followRedirects    = flag.Bool("follow-redirects", true, "")            // Follow 3xx responses of http
+	maxRedirects       = flag.Int("max-redirects", defaultMaxRedirects, "") // Redirects followed by a request at most
+	tcpNoDelay         = flag.Bool("tcp-nodelay", true, "")
+	tcpKeepAlive       = flag.String("tcp-keepalive", "", "") // Keepalive period of TCP connections, 0 disables
+	sendBuffer         = flag.String("send-buffer", "", "")   // Socket send buffer size
+	recvBuffer         = flag.String("recv-buffer", "", "")   // Socket receive buffer size
+	tlsVerify          = flag.Bool("tls-verify", false, "")
+	tlsMinVersion      = flag.String("tls-min-version", "", "")
+	tlsCiphers         = flag.String("tls-ciphers", "", "")
+	sni                = flag.String("sni", "", "")
+	insecure           = flag.Bool("insecure", true, "")
+	polite             = flag.Bool("polite", false, "")
+	politeErrors       = flag.String("polite-errors", "5%", "")   // Error rate threshold of polite mode
+	politeOverload     = flag.String("polite-overload", "1%", "") // 429/503 rate threshold of polite mode
 	proxyAddr          = flag.String("x", "", "")
 
 	urlstr    = flag.String("url", "", "")
@@ -595,45 +1809,457 @@ var (
 	listen    = flag.String("listen", "", "")
 	dashboard = flag.String("dashboard", "", "")
 
+	listenOidc      = flag.String("listen-oidc", "", "")      // Userinfo endpoint to verify bearer tokens of -listen
+	listenOperators = flag.String("listen-operators", "", "") // OIDC users with operator role of -listen
+
+	workerSecret  = flag.String("worker-token", "", "")    // Secret shared by the controller and workers
+	listenTlsCert = flag.String("listen-tls-cert", "", "") // Certificate to serve -listen over https
+	listenTlsKey  = flag.String("listen-tls-key", "", "")  // Private key of -listen-tls-cert
+	workerCA      = flag.String("worker-ca", "", "")       // CA to verify https workers
+
+	heartbeat     = flag.String("worker-heartbeat", "5s", "")                 // Interval of heartbeats to workers
+	failureAction = flag.String("worker-failure-policy", failureContinue, "") // continue or abort when a worker dies
+	workerConfig  = flag.String("worker-config", "", "")                      // Workers of static list, DNS SRV or etcd
+	splitMode     = flag.String("worker-split", splitPerWorker, "")           // -c, -n, -q and -rate per worker or in total
+
 	urlFile    = flag.String("url-file", "", "")
 	bodyFile   = flag.String("body-file", "", "")
 	scriptFile = flag.String("script", "", "")
 
+	urlStrategy = flag.String("url-strategy", urlSequential, "") // Pick the url of -url-file for each request
+
+	streamBody = flag.Bool("body-stream", false, "") // Stream -body-file from disk instead of loading it
+	chunked    = flag.Bool("chunked", false, "")     // Send the body with chunked transfer encoding
+
+	dataFile = flag.String("data", "", "")                  // Rows of csv or json file for templates
+	dataMode = flag.String("data-mode", dataSequential, "") // Row selection of -data
+
+	bodyBaseFile = flag.String("body-base", "", "")  // Base json document the body patch is merged into
+	bodyPatch    = flag.String("body-patch", "", "") // JSON merge patch template of each request
+
+	targetMetricsUrl      = flag.String("target-metrics-url", "", "")         // Prometheus endpoint of the target scraped while running
+	targetMetricsInterval = flag.String("target-metrics-interval", "10s", "") // Interval of -target-metrics-url scrapes
+
+	annotateFile = flag.String("annotate-file", "", "") // Lines appended while running are annotations
+	progress     = flag.String("progress", "", "")      // Print interval metrics while running
+	sloFile      = flag.String("slo", "", "")           // Targets per url pattern evaluated after all urls
+	bundlePath   = flag.String("bundle", "", "")        // Archive of config, inputs and results of the run
+
+	slaP99       = flag.String("sla-p99", "", "")        // Max p99 latency, exit with 3 if exceeded
+	slaErrorRate = flag.String("sla-error-rate", "", "") // Max error rate, exit with 3 if exceeded
+
+	setupFile    = flag.String("setup", "", "")            // Requests run once before the measured stage
+	teardownFile = flag.String("teardown", "", "")         // Requests run once after the measured stage
+	stageTimeout = flag.String("stage-timeout", "30s", "") // Time box of setup and teardown stage
+
+	region = flag.String("region", "", "") // Load weights of worker regions, e.g. eu=50%,us=30%,ap=20%
+
 	http3Pool *x509.CertPool
 )
 
 const (
 	usage = `Usage: http_bench [options...] <url>
+       http_bench collect -W <worker>... -seqid <sequence id>
+       http_bench init <project directory>
+       http_bench run <project directory> [options...]
+       http_bench compare [-max-latency-increase 10%%] [-max-rps-decrease 10%%] [-max-error-increase 1] [-o markdown] <old.json> <new.json>
 Options:
 	-n  Number of requests to run.
+		Failed requests are counted in the error distribution and do not stop the run, use
+		-abort-after-errors to stop it early.
 	-c  Number of requests to run concurrently. Total number of requests cannot
 		be smaller than the concurency level.
+		Before the load, the soft limit of open files is raised to the hard limit when too low for the
+		connections, and the run warns with advice when the limit or the ephemeral ports are not enough,
+		or -disable-keepalive would exhaust the ports with TIME_WAIT, in "Preflight" of the result.
 	-q  Rate limit, in seconds (QPS).
+	-rate  Constant arrival rate of the open model, e.g. 500/s, 30/m, requests are started on schedule
+		independently of the responses, so a slower server does not reduce the offered load as with -q.
+		-c connections are created before the start, and more are created when all are busy, at most the
+		arrivals within -t plus -c, arrivals without a connection are dropped. The latency is measured from
+		the scheduled start, and the result reports the target and started rate, dropped arrivals, max
+		requests in flight and connections. -n is the total requests of each worker.
 	-d  Duration of the stress test, e.g. 2s, 2m, 2h
+		0 runs indefinitely as a permanent synthetic load or canary until stopped by signal or the dashboard api,
+		a summary of each rolling -window is printed and the statistics are reset per window.
+	-window  Rolling window of "-d 0", e.g. 30s, 5m (default 1m).
 	-t  Timeout in ms (default 3000ms).
-	-o  Output type. If none provided, a summary is printed.
-		"csv" is the only supported alternative. Dumps the response
-		metrics in comma-seperated values format.
+		A run with 100 or more dial timeouts prints a connection diagnosis of the generator instead of the
+		repeated errors: the resolved ips and TCP connect probes of the target, open files and ulimit,
+		ephemeral ports, TIME_WAIT and conntrack usage, and the likely causes.
+	-abort-after-errors  Stop the run after the number of failed requests, e.g. 1000 (default 0, unlimited),
+		the threshold is split evenly across distributed workers.
+	-start-jitter  Stagger the start of each connection randomly within the window, e.g. 500ms,
+		avoids the synchronized burst at start which trips rate limiters.
+	-warmup  Run the load for the duration before measuring, e.g. 10s, requests started within the warm-up
+		are executed but excluded from the result, so cold connections and tls handshakes do not skew the
+		latency percentiles of short runs. -d is measured after the warm-up, -n includes the warm-up requests.
+	-o  Output type and optional file as "type:file", repeat the flag to write more outputs of one run,
+		e.g. -o summary -o json:run.json -o csv:lat.csv -o html:report.html. Outputs without file are
+		printed on stdout, and a summary is printed if none of them is. Each url of -url-file is appended to the files.
+		"summary" prints the summary, latency distribution and errors.
+		"csv" dumps the response metrics in comma-seperated values format.
+		"latencies-over-time" dumps per second latency bucket counts in csv format,
+		e.g. -o latencies-over-time > latencies-over-time.csv
+		"template" prints the result with Go text/template of -output-template, e.g. markdown for PRs.
+		"markdown" prints a compact table of rps, p50/p95/p99 and error rate for pull request comments.
+		"json" prints the full result, which can be used as -baseline later.
+		"html" writes a self-contained html report of the summary, latencies, latency phases, status codes and
+		requests over time.
+	-min-samples  Min successful responses to report latency percentiles, e.g. 100 (default 0, no check),
+		fewer samples flag the run as statistically invalid in all output types, percentiles are not printed
+		in the summary and markdown, and "invalid" is set in json and template data.
+	-latency-resolution  Significant digits of latency buckets, 1 to 5 (default 3, 0.1%% precision), latencies
+		are bucketed per order of magnitude as HDR histogram, e.g. 0.000123 and 1.23 secs with 3 digits,
+		the summary prints percentiles and a histogram of the buckets.
+	-output-template  Template file for "-o template", the data is the full result, e.g. {{ .LatsTotal }},
+		{{ .Percentile 99 }}, {{ secs .Average }}, {{ byteSize .SizeTotal }}, {{ .ErrTotal }}, {{ .StatusCodeDist }}.
+	-baseline   Result file of "-o json" to compare with in "-o markdown", e.g. -o markdown -baseline main.json.
 	-m  HTTP method, one of GET, POST, PUT, DELETE, HEAD, OPTIONS.
+	-mix  Verbs picked randomly by weight for each request, e.g. -mix GET:80,POST:20, so that one run has a
+		realistic read/write ratio, and the result reports requests, errors and latency per verb besides the
+		aggregate. Each verb uses -url and -body (GET, HEAD, DELETE and OPTIONS are sent without -body) unless set by
+		-mix-url "POST=http://127.0.0.1/orders" or -mix-body 'POST={"id": "{{ randomNum 8 }}"}', a body of
+		"@file" is read from the file, e.g. -mix-body PUT=@order.json. Supports http1, http2 and http3.
 	-H  Custom HTTP header. You can specify as many as needed by repeating the flag.
 		for example, -H "Accept: text/html" -H "Content-Type: application/xml", 
-		but "Host: ***", replace that with -host.
+		but "Host: ***", replace that with -host. Repeated keys send all values.
+		Values support functions and request context, e.g. -H "X-Request: {{ .Method }} {{ .URL }}".
+	-H-replace  Custom HTTP header overwriting the values of the same key set by -H.
+	-spoof-header  Client IP header sent with a random IP per request for per-IP rate limits and geo logic,
+		e.g. -spoof-header X-Forwarded-For, the value is {{ randomIP }} if not set, e.g.
+		-spoof-header "Forwarded: for={{ randomIP }}", repeat the flag for more headers.
+	-interface  Local network interface or ip to bind connections, e.g. -interface eth1 -interface eth2, connections
+		are round-robined across the interfaces to spread traffic of multi-NIC hosts, and the result reports
+		connections and bytes per interface. The source ip of the interface is bound, the routes of the host
+		should send it through the same link. Distributed workers resolve their own interfaces.
+	-local-ip  Source ip to bind connections, e.g. -local-ip 10.0.0.5, repeat the flag for more addresses.
+	-local-ip-range  Source ips of a cidr to bind connections, e.g. -local-ip-range 10.0.0.0/24 (at most 65536
+		addresses), with -local-ip the dials of connections rotate through the addresses, so that one host
+		generates load from many source ips, and each source ip has its own ephemeral ports, e.g. beyond
+		28k connections to one target. The addresses must be assigned to the host or routed to it, e.g.
+		ip route add local 10.0.0.0/24 dev lo, each worker binds the addresses on its own host. Supports
+		http1, http2, ws, wss and -p tcp, the result reports the addresses used and bind errors.
+	-compare-ip-family  Run the same load over IPv4 and IPv6 of a dual-stack host, connections alternate between
+		the families so that both are interleaved under the same conditions, and the result compares responses,
+		errors and latency percentiles of IPv4 and IPv6 side by side. The host must resolve to both families,
+		supports http1, http2, ws, wss and -p tcp, and not -x or -interface.
+	-resolve  Pin host:port to an address like curl, e.g. -resolve example.com:443:10.0.0.1, repeat the flag for
+		more hosts, the url, Host header and SNI keep the host name. Supports http1, http2, ws, wss and -p tcp.
+	-dns-each-request  Resolve the host for every request of http1, each request dials a new connection without
+		keep-alive so that no lookup is reused, the DNS phase of the latency shows the resolver under load.
+		The summary of http1 and http2 breaks the latency into phases: DNS lookup, TCP connect and TLS
+		handshake of new connections, TTFB from the start of the request to the first response byte, and
+		transfer from the first byte to the end of the body, with p50, p90 and p99 of each phase.
+	-auto-header  Header computed from the final rendered body of each request, repeat the flag for more headers,
+		e.g. -auto-header content-md5 -auto-header digest-sha256. "content-md5" sets Content-MD5,
+		"digest-sha256" and "digest-sha512" set Digest (RFC 3230), "content-digest-sha256" sets Content-Digest
+		(RFC 9530), and "content-sha256" sets X-Amz-Content-Sha256. The headers are set before -sign-hmac.
+	-dedup-header  Header of unique request id for idempotency testing, e.g. -dedup-header Idempotency-Key,
+		the id is also {{ .RequestId }} of url, body and header templates. Responses echoing a different
+		id in the header are counted as mismatched.
+	-dedup-repeat  Times each request id is sent, e.g. 3 simulates client retries (default 1).
+	-dedup-verify  Url template to query times processed of each request id after the run,
+		e.g. "http://127.0.0.1:8080/processed?id={{ .Id }}", the response is a number or {"count": number},
+		and 404 is not processed. Duplicated, lost or mismatched request ids exit with 3.
+	-spoof-cidr  Networks of {{ randomIP }}, e.g. -spoof-cidr 10.0.0.0/8 -spoof-cidr 2001:db8::/32
+		(default any IPv4 address), a network is picked randomly for each IP.
 	-http  		Support protocol http1, http2, ws, wss (default http1).
+	-p  		Raw socket protocol instead of http, tcp or udp, the url is host:port, e.g. -p udp -url 127.0.0.1:53.
+		Each request of udp sends the body as one datagram and reads one response datagram within -t,
+		and the result reports datagrams and bytes sent and received, and responses timed out.
+		Each request of tcp writes the body, e.g. -body 2a310d0a -bodytype hex, and reads the response within
+		-t over the connection kept alive, the connection is dialed again after an error, and every request
+		with -disable-keepalive.
+	-tcp-response-size  Bytes of a response of -p tcp, e.g. -tcp-response-size 1KB, default reads what arrives
+		in one read, set it for responses larger than a segment.
+	-udp-response  Read a response datagram per request of -p udp (default true), -udp-response=false only
+		sends, e.g. for statsd or syslog.
 	-body  		Request body, default empty.
-	-bodytype   Request body type, support string, hex (default string).
+	-bodytype   Request body type, support string, hex, json, xml, form, protobuf (default string),
+			json, xml, form and protobuf set the default Content-Type header if it's not set by -H.
+	-proto      The .proto file for protobuf body type, the JSON body is encoded to protobuf binary per request.
+	-proto-msg  Full name of protobuf message, e.g. my.pkg.Request.
 	-a  		Basic authentication, username:password.
+	-sign-hmac  Sign each request after templates rendering and set the signature header, e.g.
+			"header=X-Signature;key=env:SECRET;payload=method+path+body;algo=sha256",
+			key supports env:NAME, file:path or literal, payload parts are method, host, path, query, url, body
+			(default body) joined by sep (default empty, e.g. sep=\n), algo is md5, sha1, sha256 (default), sha512,
+			encoding is hex (default) or base64, and prefix is prepended to the signature, e.g. prefix=sha256=.
 	-x  		HTTP Proxy address as host:port.
-	-disable-compression  Disable compression.
+	-disable-compression  Disable compression, otherwise gzip is requested and both wire and decompressed size are reported.
+	-max-body-read  Max response body size to read, e.g. 1MB, truncated responses are counted (default unlimited).
+	-fallback-url   Fallback url used when the target keeps connection refused, only scheme and host
+			of the request url are replaced, per endpoint stats and the switchover time are reported.
+	-fallback-after Connection refused duration before switching to -fallback-url, e.g. 500ms, 3s (default 3s).
 	-disable-keepalive    Disable keep-alive, prevents re-use of TCP connections between different HTTP requests.
+			The result of http1 and http2 reports requests on new and reused connections, the connections opened,
+			and requests failed before getting a connection, e.g. dial timeouts of too many -c.
+	-enable-cookies  Each connection is a virtual user with its own cookie jar, the cookies of Set-Cookie are sent
+			by its following requests (and redirects), for session based apps and sticky session load balancers.
+			Each iteration of -flow starts with an empty jar. The sessions, Set-Cookie responses and requests
+			sent with cookies are reported in "Cookies" of the result.
+	-follow-redirects  Follow 3xx responses of http requests (default true), -follow-redirects=false returns the
+			3xx responses, which are counted in the status codes. The latency of a followed request includes
+			its hops, and "Redirects" of the result reports the hops of each 3xx status apart from the final
+			responses, or the 3xx responses returned.
+	-max-redirects  Redirects followed by a request at most (default 10), a request redirected more fails with
+			"stopped after N redirects".
+	-think-time  Pause of each connection between its requests, e.g. 200ms, to model users reading a page
+		instead of a closed loop which sends the next request at once. The result reports the pauses, and
+		the offered rate of -c connections with instant responses against the achieved rate.
+	-think-time-random  Random pause between requests in a range, e.g. 100ms-500ms, not with -think-time,
+		-rate or -pipeline.
+	-tcp-nodelay  Set TCP_NODELAY on connections (default true), -tcp-nodelay=false enables Nagle's algorithm
+		which batches small writes and can add tens of milliseconds to small requests.
+	-tcp-keepalive  TCP keepalive probe period, e.g. 30s, 0 disables probes (default 15s, 60s for http1).
+	-send-buffer  Socket send buffer size (SO_SNDBUF), e.g. 64KB (default of the system).
+	-recv-buffer  Socket receive buffer size (SO_RCVBUF), e.g. 256KB (default of the system).
+		The socket options apply to http1, http2, ws and tcp connections, and are reported with the result.
+	-tls-verify  Verify server certificates and stapled OCSP (default skip), certificate errors are counted
+		by category: expired, hostname mismatch, unknown CA, revoked (stapled OCSP) and invalid certificate.
+	-insecure  Skip verification of server certificates (default true), -insecure=false is the same as -tls-verify.
+	-tls-min-version  Min TLS version of connections, 1.0, 1.1, 1.2 or 1.3 (default TLS 1.2 of Go).
+	-tls-ciphers  Comma separated cipher suites of TLS 1.2 and lower, e.g.
+		TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		TLS 1.3 suites are not configurable. The TLS options apply to http1, http2, http3 and wss.
+	-sni  Server name sent in SNI and verified in the certificate, e.g. -sni api.example.com to test an
+		origin by ip, default is the host of url.
+	-polite  Ramp down the offered load by half every second the error rate exceeds -polite-errors (default 5%%)
+		or the 429/503 rate exceeds -polite-overload (default 1%%), ramp up again by 10%% when healthy,
+		and pause on Retry-After of 429/503 (at most 60s). The adaptive rate curve is reported.
+		With or without -polite, the quota advertised by X-RateLimit-*, RateLimit-* and RateLimit headers
+		of responses (limit and window, min remaining, exhausted responses and max reset) and the 429 responses are
+		reported, so that API quotas are quantified instead of appearing as 429 spikes.
 	-cpus		Number of used cpu cores. (default for current machine is %d cores).
+	-gc-tuning  "auto" tunes the garbage collector of the generator for the run to reduce GC pauses in the
+		measured latencies: GOGC 400 when -q is 0 or at least 10000 (otherwise 200), a heap ballast of 256KB
+		per -c connection (64MB to 1GB) and a soft memory limit of 8 times the ballast (at least 2GB).
+		The summary reports GC cycles and total pause time of the generator when tuned, or when the pauses
+		exceed 1%% of the run, and -bundle records them in the generator telemetry. Default "off".
 	-url		Request single url.
 	-verbose 	Print detail logs, default 3(0:TRACE, 1:DEBUG, 2:INFO, 3:ERROR).
-	-url-file 	Read url list from file and random stress test.
+	-url-file 	Read url list from file and random stress test, each line is
+			[METHOD] URL [WEIGHT] [-H "Key: Value"]... [-body 'body'], all lines are validated before running.
+	-url-strategy  Pick the url of -url-file for each request, sequential runs the urls one after another as
+			separate runs (default), random picks a url randomly, roundrobin takes the urls in turn across all
+			connections, and weighted picks randomly by the WEIGHT of the line (default 1), e.g. "http://host/a 5".
+			The urls are mixed in one run, and the result reports hits, errors and latency per url.
+	-setup		Requests run once in order before the measured run, e.g. create test data, in the -url-file
+			format. Responses are not counted, and the run is aborted when one fails or gets status >= 400.
+	-teardown	Requests run once in order after the measured run, e.g. cleanup, in the -url-file format.
+			It also runs when the setup fails.
+	-stage-timeout  Time box of the setup and teardown stage each (default 30s).
+	-flow		Steps of requests in a yaml or json file, sent in order by each connection as a virtual user,
+			e.g. login then call the api. Variables extracted from responses, e.g. token={{ jsonGet .Body "$.token" }},
+			are {{ .Vars.token }} in the templates of the following steps. The flow starts over after the
+			last step or a failed step (error, status >= 400 or extract failed), and the result reports
+			requests, average latency and failures per step.
 	-body-file	Request body from file.
-	-listen 	Listen IP:PORT for distributed stress test and worker node (default empty). e.g. "127.0.0.1:12710".
+	-body-stream  Stream -body-file from disk for each request instead of loading it into memory, e.g.
+		-body-file big.bin -body-stream, to benchmark multi-GB upload endpoints. The file is sent as is (not a
+		template) with Content-Length, and each distributed worker reads the file at the same path, which
+		the worker must allow by -listen-body-stream.
+	-chunked  Send the request body with Transfer-Encoding: chunked instead of Content-Length (http2 and
+		http3 omit Content-Length), for the bodies of -body, -body-file and -body-stream.
+	-body-base  Base json document file of -body-patch, e.g. -body-base order.json.
+	-body-patch  JSON merge patch (RFC 7386) template rendered for each request and deep-merged into a copy of
+		-body-base, e.g. -body-patch '{"user":{"id":"{{ randomNum 8 }}"}}', so large payloads only specify their
+		dynamic fields. Objects are merged recursively, null removes a key and other values replace the base,
+		the body type is json.
+	Running load can be changed by PUT /api/jobs/{sequence id}/rate {"qps": 200, "c": 20} of -listen
+		(qps is requests per second of each worker, -1 is unlimited), or each SIGUSR2 adds -c connections,
+		the changes are annotated.
+	-progress  Print requests/sec, failures and latency percentiles of each interval while running, e.g. 5s
+		(default off), the metrics are collected from workers when distributed with a line of each worker
+		following the consolidated line, and go to stderr.
+	-target-metrics-url  Prometheus endpoint of the target scraped while running, e.g.
+		http://target:9100/metrics of node_exporter, the cpu, memory and network usage (and process cpu and
+		rss of the process collector) are printed and embedded as "target_metrics" in the json and html reports.
+	-target-metrics-interval  Interval of -target-metrics-url scrapes (default 10s, at least 1s), it is also
+		the timeout of a scrape, and a final scrape is taken when the run ends.
+	-slo  SLO file of url patterns and targets, evaluated on the results of the matched urls after all urls
+		run, and a pass/fail matrix is printed to stderr, a failed target exits with 3. Patterns starting
+		with "/" match the url path, others match the whole url, "*" matches any characters, targets are
+		pNN (max latency), max-error-rate and availability (min rate of requests neither failed nor 5xx):
+			"/api/users/*":
+			  - p99: 300ms
+			  - max-error-rate: 1%%
+			  - availability: 99.9%%
+	-sla-p99  Max p99 latency of each url, e.g. 200ms, checked on the final result, the process prints the
+		failed SLA and exits with 3 when exceeded, so that http_bench gates the performance in CI.
+	-sla-error-rate  Max error rate of each url, e.g. 1%%, checked and failed the same as -sla-p99.
+	-bundle  Archive of the run into a .tar.gz or .tgz file, e.g. run.tar.gz, with the command line and input
+		files (hashed with sha256 in manifest.json), the parameters and results of each url in all output types,
+		and telemetry of the load generator, credentials are redacted and key files are not packaged.
+	-record  Write the raw sample of every request into a ndjson file, e.g. samples.ndjson, one line of
+		{"seq", "t" (unix nano start), "d" (latency nano), "code", "size", "err", "timeout", "warmup"}, for offline
+		analysis of long runs, workers of -W record with their own -record.
+	-replay-report  Regenerate the summary and percentiles of a -record file without running, the same as the
+		run in -o outputs, -seqid selects the run if the file has several, samples of -warmup are skipped.
+	-annotate-file  Lines appended to the file while running are annotations of external events, e.g. deploys,
+			each line is "[RFC3339 time] message", annotations can also be posted to /api/annotate
+			with {"msg": "deployed build 1.2.3"} when listening.
+	-batch  Compose the number of items rendered from the body template into a json array per request, e.g. 50,
+		for APIs which accept arrays of operations (default 0, not batched). {{ .Item }} is the index of item
+		in the batch, and the result reports items/sec and average latency per item alongside requests/sec.
+	-pipeline  Pipeline the number of http1 requests on each connection, e.g. 4, for legacy servers and proxies
+		which support pipelining (default 0, not pipelined). The requests are written without waiting for
+		the responses ahead, and the result reports latency by requests ahead on the connection, no request
+		ahead is like a keep-alive request, and the others show the head-of-line blocking.
+	-expect-continue  Send "Expect: 100-continue" with http1 request bodies, and wait for 100 Continue at most
+		the threshold before sending the body anyway, e.g. 1s (default disabled). The result counts how often
+		the server replied 100 Continue, replied the final status immediately (the body is not sent), or did
+		not reply within the threshold, e.g. upload endpoints behind proxies that mishandle the handshake.
+	-longpoll  Treat each request as a long-poll cycle, which waits until data arrives or the server times out,
+		and is re-issued immediately. The latency distribution is the connection latency until the poll is
+		written, and the result reports notifications/sec, empty polls timed out on server (204, 304 or an
+		empty body) and the distribution of the wait until data arrived separately.
+	-longpoll-timeout  Timeout of server to hold a poll of -longpoll, e.g. 30s (default 30s), the requests time
+		out after the server timeout plus -t.
+	-resume  Simulate interrupted downloads, e.g. -resume 1MB reads the first 1MB of each GET, aborts it and
+		requests the rest with "Range: bytes=1048576-" and If-Range, the content is requested without
+		compression. The latency distribution is the whole download, and the result reports the downloads
+		resumed with 206, Range ignored with 200, mismatched Content-Range or length and content not longer
+		than the offset, which are errors except resumed, and the latency distribution of the resume.
+	-abort-after-bytes  Cancel each download after reading the bytes of the body on the wire, e.g. 64KB, to test how
+		the server handles client aborts at scale. Responses not longer are read completely. The result reports
+		the downloads aborted and the responses completed before the abort, aborted downloads are not failures.
+	-abort-after  Cancel each download after the duration since the response headers, e.g. 200ms, like
+		-abort-after-bytes, both can be set and the first reached aborts.
+	-hold-connections  Open and hold the number of idle connections without requests alongside the load, e.g. 5000,
+		to test idle connection management and memory of server under many mostly-idle clients, e.g. mobile
+		backends. The connections are opened at most 64 at a time with tls handshake for https and wss, and
+		the result reports the connections opened, failed and closed by server while held.
+	-hold-duration  Hold the idle connections of -hold-connections for the duration, e.g. 10m (default until the
+		load ends), the connections are closed when the load ends.
+	-ws-subprotocol  Websocket subprotocol offered in the handshake, repeatable in preference order, e.g. graphql-ws,
+		for servers which reject connections without the right subprotocol.
+	-ws-compression  Offer permessage-deflate compression of websocket (default disabled).
+	-ws-origin  Origin header of websocket handshake, e.g. https://example.com, for servers which check the origin.
+		The result reports the websocket connections opened and failed, and the subprotocol and extensions
+		negotiated by the server.
+	-assert  Assertion on responses "<operand> <op> <value>", repeatable, e.g. -assert "age < 60"
+		-assert "x-cache ~ HIT" -assert "status == 200" -assert "body contains ok" -assert "jsonpath $.code == 0".
+		The operand is a response header, a directive of Cache-Control (max-age, s-maxage, stale-while-revalidate
+		or stale-if-error), status, body, or jsonpath of the json body, e.g. $.data.items[0].id. Op is one of <,
+		<=, >, >=, ==, !=, ~ (contains, case-insensitive) and contains, and the value is a number, a string or
+		a directive. A missing Age is 0. Violations of headers are counted per assertion separately from errors,
+		and exit with 3, so that cache freshness of CDN is verified under load. A response failed assertions
+		on status, body or jsonpath is an error, even with status 2xx.
+	-extract  Capture a value from responses into a variable of the connection, repeatable, e.g.
+		-extract "token=jsonpath:$.data.token" -extract "session=header:X-Session", the variable is
+		{{ .Vars.token }} in the url, body and header templates of the following requests of the connection,
+		empty until extracted, and keeps the last value when a response has none or status >= 400, e.g. for
+		token based auth flows without scripting.
+	-assert-json-schema  Validate 2xx responses against the JSON Schema file, e.g. -assert-json-schema user.json,
+		a violation is a content error "json schema violation" and exits with 3, so serialization regressions
+		are caught even when the status is 200. The schema supports type, enum, const, properties, required,
+		additionalProperties, items, minItems, maxItems, minimum, maximum, exclusiveMinimum, exclusiveMaximum,
+		minLength, maxLength, pattern, allOf, anyOf, oneOf, not and local $ref, other keywords are ignored.
+	-assert-json-schema-sample  Fraction of 2xx responses validated, e.g. 10%% (default 100%%).
+	-assert-json-schema-examples  Violations kept with the path, reason and body per worker, printed in the
+		summary and saved in the json output (default 5).
+	-seed  Seed of random functions of templates, e.g. randomString, random and UUID (default 0, random seed).
+		Each connection has its own random source seeded from it, so the same seed generates the same
+		data per connection, and UUID is unique per connection.
+	-form-urlencoded  Form-urlencoded body field "key=value", value supports functions and is escaped per request,
+			repeat the flag for more fields, e.g. -form-urlencoded "a=1" -form-urlencoded "b={{ randomNum 4 }}".
+	-form  Multipart/form-data body part, a text field "name=value" whose value supports functions, or a file
+			"name=@path" with an optional content type "name=@path;type=image/png" (default by the extension),
+			repeat the flag for more parts, e.g. -form "title={{ randomString 8 }}" -form "file=@photo.png".
+			The boundary and Content-Type are set per worker, and files are read once and sent to workers.
+	-data  Rows of a csv file with the column names in the first line, or a json file of an array of objects, for
+			the url, body and header templates, e.g. -data users.csv with {{ .Data.username }}, one row is used by
+			all templates of a request, and by all steps of an iteration of -flow. Distributed workers take their
+			own part of the rows.
+	-data-mode  Row selection of -data, sequential takes the rows in order and starts over after the last one,
+			random picks a row for each request (default sequential).
+	-param  Query parameter "key=value" appended to the url, value supports functions and is escaped per request,
+			repeat the flag for more parameters, e.g. -param "page={{ random 1 100 }}" -param "sort=asc".
+	-listen 	Listen IP:PORT for distributed stress test and worker node (default empty). e.g. "127.0.0.1:12710",
+			GET /api/jobs lists the running and finished stress tests with summary, and GET /metrics exports
+			requests, errors, status codes, live rps, in-flight requests and latency histogram of the running
+			stress tests in Prometheus text format, e.g. to scrape long soak tests.
+			REST API: POST /api/tests starts a stress test of the json parameters, GET /api/tests lists,
+			GET /api/tests/{sequence id} is the live metrics or final result, DELETE stops, and
+			GET /api/tests/schema is the json schemas of the request and responses.
+	-result-ttl Keep finished results on worker node for collect and GET /api/jobs, e.g. 30m, 2h (default 24h).
+	-history-db  Bolt file to persist the finished results beyond -result-ttl and restarts, e.g. history.db, the file
+			is locked by one process. GET /api/history lists the runs, /api/history/{sequence id} is the html report
+			("?output=json" for json), and /api/history/compare?base=A&target=B compares two runs like -baseline,
+			the dashboard lists, views and compares them in "History".
+	-listen-body-stream  File of the worker node that controllers may stream by -body-stream, repeatable, e.g.
+			-listen-body-stream /data/big.bin, body_stream of other files is rejected by /api and /api/tests.
+	-worker-max-c  Connections of the worker node shared by concurrent jobs by -job-weight (default 0, unlimited).
+			A job gets at most its share of -c when it starts, and jobs of -d are adjusted as jobs come and go.
+	-worker-max-q  Rate limit of the worker node shared by concurrent jobs by -job-weight (default 0, unlimited).
+			Jobs overlapping on a worker or capped by the share are reported in "Contention" of the result.
+	-job-weight  Share of the job on workers against concurrent jobs of other controllers (default 1).
 	-dashboard 	Listen dashboard IP:PORT and operate stress params on browser.
+			The qps, latency percentiles and error rate are streamed live by the websocket of
+			/api/live?sequence_id=N&interval=ms, with -W workers, the dashboard also charts the qps and p99 of each worker.
+	-listen-auth  Basic auth user "user:password[:role]" of -listen and -dashboard, repeatable, role is viewer
+			or operator (default), e.g. -listen-auth "ops:secret" -listen-auth "team:secret2:viewer". Viewers
+			open the dashboard, metrics, jobs and collect, operators also start, stop, annotate, adjust and
+			share the load. Share links of /share/<token> are not authenticated. The controller sets the user
+			of worker by -W "http://user:password@IP:PORT".
+	-listen-oidc  Userinfo endpoint of OIDC provider to verify bearer tokens of -listen and -dashboard, e.g.
+			-listen-oidc "https://accounts.example.com/userinfo", verified tokens are cached for 1m.
+	-listen-operators  Subjects or emails of OIDC users with operator role, separated by comma, the others
+			are viewers (default all OIDC users are operators).
+	-worker-token  Secret shared by the controller and workers, at least 16 characters, or the environment
+			variable STRESS_WORKER_TOKEN to keep it out of the process list. The controller signs each request
+			to workers with the time, a nonce and the body, the token itself is never sent, and the worker
+			proves the token in the response. A worker with -worker-token rejects unsigned, tampered and
+			replayed requests unless authenticated by -listen-auth or -listen-oidc, and the controller
+			rejects a worker which does not prove the token. Clocks must agree within 5m.
+	-listen-tls-cert  Certificate PEM file to serve -listen and -dashboard over https, with -listen-tls-key.
+	-listen-tls-key  Private key PEM file of -listen-tls-cert.
+	-worker-ca  CA certificate PEM file to verify workers of -W "https://IP:PORT" (default system roots).
+	-worker-heartbeat  Interval of heartbeats to the workers of -W while running (default 5s, 0 disables).
+			A worker is dead when its start command fails or 3 heartbeats in a row are missed, e.g. the
+			machine is gone without closing the connection. The metrics of its last heartbeat are merged
+			as the partial result, and the dead workers are reported in the result.
+	-worker-failure-policy  continue (default) waits for the other workers when a worker is dead, abort
+			stops the other workers and fails the run with exit code 5.
+	-worker-config  File of the worker fleet, resolved at start together with -W, and again before the run
+			of each following url and when workers do not respond before a run, the previous workers are kept
+			if resolving fails or the fleet exceeds -c of -worker-split total. The keys are workers
+			(list like -W), srv (list of DNS SRV names, "region=name" labels the targets), etcd (endpoint
+			of etcd v3), etcd-prefix (keys whose values are workers, default /http_bench/workers/) and
+			scheme (of SRV targets, http or https, default http), e.g.
+				workers:
+				  - 10.0.0.1:12710
+				srv:
+				  - eu=_http-bench._tcp.eu.example.com
+				etcd: http://127.0.0.1:2379
+	-worker-split  How -c, -n, -q and -rate apply to the workers of -W, per-worker (default) is the load of
+			each worker, total is the load of all workers divided evenly across them (by the weight of the
+			region with -region), e.g. -worker-split total -c 100 -q 1000 with 4 workers runs 25 connections
+			and 250 QPS on each. The load of each worker is printed in the "Workers" table before the start.
 	-w/W		Running distributed stress test worker node list. e.g. -w "127.0.0.1:12710" -W "127.0.0.1:12711".
-	-example 	Print some stress test examples (default false).`
+			Label the worker with a region by "region=IP:PORT", e.g. -W "eu=127.0.0.1:12710".
+			Before load starts, each worker echoes the parameters it will actually run with, e.g. -q after
+			rounding of the send interval and resolved -interface, and a table of workers is printed to stderr
+			with mismatches marked by "*". The clock offset of each worker is estimated by round trips before
+			load starts, the time series of workers are aligned to the clock of the controller, and the offsets
+			are reported in the result.
+	-region		Split the load of workers by region weights, e.g. -region "eu=50%%,us=30%%,ap=20%%",
+			the total -c, -n and -q of all workers is kept, the weight of a region is split evenly by its workers,
+			and the result reports per region latency and errors.
+	-seqid		Sequence id of distributed stress test for collect, the controller prints it when running.
+	-example 	Print some stress test examples (default false).
+
+Exit codes:
+	0  Success.
+	1  Usage error, invalid options.
+	2  Target unreachable, all requests failed or setup/teardown request failed.
+	3  SLO or assertion failure, e.g. fewer successful responses than -min-samples or
+	   setup/teardown status code >= 400, or duplicated, lost or mismatched request ids of -dedup-verify,
+	   or a failed assertion of the project, -slo or -sla-p99/-sla-error-rate, or a response violated -assert.
+	4  Circuit breaker, stopped by -abort-after-errors.
+	5  Internal error, e.g. listen failure or no worker responded.`
 
 	examples = `
 1.Example stress test:
@@ -652,13 +2278,27 @@ Options:
 
 5.Example dashboard test:
 	./http_bench -dashboard "127.0.0.1:12345" -verbose 1
+	Click "Share" for a read-only link of /share/<token> to the live metrics and final report of the run.
 
 6.Example support function and variable test:
 	./http_bench -c 1 -n 1 "https://127.0.0.1:18090?data={{ randomString 10}}" -verbose 0
 
 7.Example distributed stress test:
 	(1) ./http_bench -listen "127.0.0.1:12710" -verbose 1
-	(2) ./http_bench -c 1 -d 10s "http://127.0.0.1:18090/test1" -body "{}" -verbose 1 -W "127.0.0.1:12710"`
+	(2) ./http_bench -c 1 -d 10s "http://127.0.0.1:18090/test1" -body "{}" -verbose 1 -W "127.0.0.1:12710"
+	(3) ./http_bench -c 10 -d 10s "http://127.0.0.1:18090/test1" -region "eu=70%%,us=30%%" -W "eu=127.0.0.1:12710" -W "us=127.0.0.1:12711"
+
+8.Example collect results of interrupted distributed stress test:
+	./http_bench collect -W "127.0.0.1:12710" -W "127.0.0.1:12711" -seqid 1700000000
+9.Example benchmark project:
+	./http_bench init bench/api
+	./http_bench run bench/api -c 20
+10.Example compare results of "-o json", exit with 3 if p50, p90, p95, p99 or the average increases by more than
+	10%%, requests/sec decreases by more than 5%% or the error rate increases by more than 1 percentage point:
+	./http_bench compare -max-latency-increase 10%% -max-rps-decrease 5%% -max-error-increase 1 main.json pr.json
+11.Example record the samples of a long run and report them offline:
+	./http_bench -c 20 -d 2h -record samples.ndjson http://127.0.0.1:8000/
+	./http_bench -replay-report samples.ndjson -o json`
 )
 
 func main() {
@@ -666,12 +2306,65 @@ func main() {
 		fmt.Println(fmt.Sprintf(usage, runtime.NumCPU()))
 	}
 
+	commandLine := append([]string(nil), os.Args...) // before changed by projects and parsing
 	var params StressParameters
-	var headerslice flagSlice
-
-	flag.Var(&headerslice, "H", "") // Custom HTTP header
-	flag.Var(&workerList, "W", "")  // Worker mechine, support W/w
+	var headerslice, headerReplaceSlice, formUrlencodedSlice, spoofHeaderSlice, spoofCidrSlice, outputSlice, autoHeaderSlice, interfaceSlice, assertSlice, listenAuthSlice, listenFileSlice, wsSubprotocolSlice, extractSlice, resolveSlice, mixUrlSlice, mixBodySlice, localIpSlice, localIpRangeSlice, paramSlice, formSlice flagSlice
+
+	flag.Var(&headerslice, "H", "")                       // Custom HTTP header
+	flag.Var(&headerReplaceSlice, "H-replace", "")        // Custom HTTP header, overwrite the same key
+	flag.Var(&formUrlencodedSlice, "form-urlencoded", "") // Form-urlencoded body field
+	flag.Var(&formSlice, "form", "")                      // Multipart form-data body field or file
+	flag.Var(&paramSlice, "param", "")                    // Query parameter appended to the url
+	flag.Var(&outputSlice, "o", "")                       // Output type and file
+	flag.Var(&autoHeaderSlice, "auto-header", "")         // Headers computed from the rendered body
+	flag.Var(&interfaceSlice, "interface", "")            // Local interfaces to bind connections
+	flag.Var(&localIpSlice, "local-ip", "")               // Source ips to bind connections
+	flag.Var(&localIpRangeSlice, "local-ip-range", "")    // Source ip ranges to bind connections
+	flag.Var(&resolveSlice, "resolve", "")                // Addresses pinned to host:port
+	flag.Var(&assertSlice, "assert", "")                  // Assertions on responses
+	flag.Var(&extractSlice, "extract", "")                // Values captured from responses into variables
+	flag.Var(&mixUrlSlice, "mix-url", "")                 // Url of a verb of -mix
+	flag.Var(&mixBodySlice, "mix-body", "")               // Body of a verb of -mix
+	flag.Var(&listenAuthSlice, "listen-auth", "")         // Basic auth users of -listen
+	flag.Var(&listenFileSlice, "listen-body-stream", "")  // Files of -body-stream controllers may stream
+	flag.Var(&wsSubprotocolSlice, "ws-subprotocol", "")   // Websocket subprotocols offered
+	flag.Var(&spoofHeaderSlice, "spoof-header", "")       // Client ip header, default value {{ randomIP }}
+	flag.Var(&spoofCidrSlice, "spoof-cidr", "")           // Networks of randomIP
+	flag.Var(&workerList, "W", "")                        // Worker mechine, support W/w
 	flag.Var(&workerList, "w", "")
+
+	// scaffold and run benchmark project directory
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		if len(os.Args) != 3 {
+			usageAndExit("init requires a project directory.")
+		}
+		if err := initProject(os.Args[2]); err != nil {
+			usageAndExit("init project err: " + err.Error())
+		}
+		eprintln("project created, edit %s and run \"http_bench run %s\"",
+			filepath.Join(os.Args[2], projectScenario), os.Args[2])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		os.Exit(runCompare(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		if len(os.Args) < 3 {
+			usageAndExit("run requires a project directory.")
+		}
+		args, err := loadProject(os.Args[2])
+		if err != nil {
+			usageAndExit("load project err: " + err.Error())
+		}
+		os.Args = append(append([]string{os.Args[0]}, args...), os.Args[3:]...)
+	}
+
+	// collect results from workers of interrupted distributed stress test
+	var isCollect bool
+	if len(os.Args) > 1 && os.Args[1] == "collect" {
+		isCollect = true
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
 	flag.Parse()
 
 	for flag.NArg() > 0 {
@@ -691,33 +2384,92 @@ func main() {
 	params.N = *n
 	params.C = *c
 	params.Qps = *q
+	params.Weight = *jobWeight
 	params.Duration = parseTime(*d)
 
 	if params.C <= 0 {
 		usageAndExit("n and c cannot be smaller than 1.")
 	}
+	if params.Weight < 1 {
+		usageAndExit("-job-weight cannot be smaller than 1.")
+	}
 
 	if (params.N < params.C) && (params.Duration < 0) {
 		usageAndExit("n cannot be less than c.")
 	}
 
-	var requestUrls []string
+	var requestUrls []urlEntry
+	var validateErrs []string
 	var err error
 	if *urlFile == "" && len(*urlstr) > 0 {
-		requestUrls = append(requestUrls, *urlstr)
+		requestUrls = append(requestUrls, urlEntry{url: *urlstr})
 	} else if len(*urlFile) > 0 {
-		if requestUrls, err = parseFile(*urlFile, []rune{'\r', '\n'}); err != nil {
+		if requestUrls, validateErrs, err = parseUrlFile(*urlFile); err != nil {
 			usageAndExit(*urlFile + " file read error(" + err.Error() + ").")
 		}
 	}
+	if *flowFile != "" {
+		if len(requestUrls) > 0 {
+			usageAndExit("-flow can't be used with -url or -url-file, the urls are in the steps.")
+		}
+		if params.Flow, err = parseFlow(*flowFile); err != nil {
+			usageAndExit(*flowFile + " file read error(" + err.Error() + ").")
+		}
+		requestUrls = append(requestUrls, urlEntry{url: params.Flow[0].Url})
+	}
 
 	params.RequestMethod = strings.ToUpper(*m)
 	params.DisableCompression = *disableCompression
 	params.DisableKeepAlives = *disableKeepAlives
+	params.EnableCookies = *enableCookies
+	if *maxRedirects < 1 {
+		usageAndExit("-max-redirects must be at least 1.")
+	}
+	params.NoRedirects, params.MaxRedirects = !*followRedirects, *maxRedirects
+	params.TlsVerify = *tlsVerify || !*insecure
+	if *tlsMinVersion != "" {
+		if params.TlsMinVersion, err = parseTlsVersion(*tlsMinVersion); err != nil {
+			usageAndExit("invalid -tls-min-version: " + err.Error())
+		}
+	}
+	if params.TlsCiphers, err = parseTlsCiphers(*tlsCiphers); err != nil {
+		usageAndExit("invalid -tls-ciphers: " + err.Error())
+	}
+	params.TlsServerName = strings.TrimSpace(*sni)
+	if params.MinSamples = *minSamples; params.MinSamples < 0 {
+		usageAndExit("-min-samples cannot be smaller than 0.")
+	}
+	if params.LatencyResolution = *latsDigits; params.LatencyResolution < 1 || params.LatencyResolution > maxLatsDigits {
+		usageAndExit("-latency-resolution must be between 1 and 5.")
+	}
+	if params.AbortAfterErrors = *abortErrors; params.AbortAfterErrors < 0 {
+		usageAndExit("-abort-after-errors cannot be smaller than 0.")
+	}
+	params.Seed = *seed
 	params.RequestBody = *body
-	params.RequestBodyType = *bodyType
+	params.RequestBodyType = strings.ToLower(*bodyType)
+	switch params.RequestBodyType {
+	case "", bodyString, bodyHex, bodyJson, bodyXml, bodyForm, bodyProtobuf:
+	default:
+		usageAndExit("not support -bodytype: " + *bodyType)
+	}
 
-	if *bodyFile != "" {
+	if *bodyFile != "" && *streamBody {
+		switch {
+		case *body != "":
+			usageAndExit("-body-stream can't be used with -body.")
+		case params.RequestBodyType == bodyHex || params.RequestBodyType == bodyForm || params.RequestBodyType == bodyProtobuf:
+			usageAndExit("-body-stream sends the file as is, only supports -bodytype string, json or xml.")
+		case *batch > 0 || *signHmac != "" || len(autoHeaderSlice) > 0:
+			usageAndExit("-body-stream can't be used with -batch, -sign-hmac or -auto-header, they need the whole body.")
+		}
+		stream, err := openBodyStream(*bodyFile)
+		if err != nil {
+			usageAndExit(*bodyFile + " file read error(" + err.Error() + ").")
+		}
+		stream.close()
+		params.BodyStream = *bodyFile
+	} else if *bodyFile != "" {
 		readBody, err := parseFile(*bodyFile, nil)
 		if err != nil {
 			usageAndExit(*bodyFile + " file read error(" + err.Error() + ").")
@@ -725,6 +2477,83 @@ func main() {
 		if len(readBody) > 0 {
 			params.RequestBody = readBody[0]
 		}
+	} else if *streamBody {
+		usageAndExit("-body-stream requires -body-file.")
+	}
+
+	if *bodyBaseFile != "" || *bodyPatch != "" {
+		switch {
+		case *bodyBaseFile == "" || *bodyPatch == "":
+			usageAndExit("-body-base and -body-patch must be used together.")
+		case params.RequestBody != "" || params.BodyStream != "" || len(formUrlencodedSlice) > 0 || len(formSlice) > 0:
+			usageAndExit("-body-patch can't be used with -body, -body-file, -form-urlencoded or -form.")
+		case params.RequestBodyType != "" && params.RequestBodyType != bodyJson:
+			usageAndExit("-body-patch only supports -bodytype json.")
+		case *batch > 0:
+			usageAndExit("-body-patch can't be used with -batch.")
+		}
+		content, err := os.ReadFile(*bodyBaseFile)
+		if err != nil {
+			usageAndExit(*bodyBaseFile + " file read error(" + err.Error() + ").")
+		}
+		if _, err := parseBodyBase(string(content)); err != nil {
+			usageAndExit(*bodyBaseFile + ": " + err.Error())
+		}
+		params.BodyBase, params.RequestBody, params.RequestBodyType = string(content), *bodyPatch, bodyJson
+	}
+
+	if len(formUrlencodedSlice) > 0 {
+		if params.RequestBody != "" || params.BodyStream != "" {
+			usageAndExit("-form-urlencoded can't be used with -body or -body-file.")
+		}
+		for _, field := range formUrlencodedSlice {
+			if !strings.Contains(field, "=") {
+				usageAndExit("invalid -form-urlencoded: " + field + ", expect key=value.")
+			}
+		}
+		params.RequestForm = formUrlencodedSlice
+		params.RequestBodyType = bodyForm
+	}
+
+	if len(formSlice) > 0 {
+		switch {
+		case params.RequestBody != "" || params.BodyStream != "" || len(formUrlencodedSlice) > 0:
+			usageAndExit("-form can't be used with -body, -body-file or -form-urlencoded.")
+		case *batch > 0:
+			usageAndExit("-form can't be used with -batch.")
+		}
+		for _, field := range formSlice {
+			f, err := parseMultipartField(field)
+			if err != nil {
+				usageAndExit(err.Error())
+			}
+			params.MultipartForm = append(params.MultipartForm, f)
+		}
+		params.RequestBodyType = bodyMultipart
+	}
+
+	if *dataFile != "" {
+		if params.Data, err = parseData(*dataFile); err != nil {
+			usageAndExit("invalid -data " + *dataFile + ": " + err.Error())
+		}
+		switch *dataMode {
+		case dataSequential, dataRandom:
+			params.DataMode = *dataMode
+		default:
+			usageAndExit("invalid -data-mode: " + *dataMode + ", expect sequential or random.")
+		}
+		sampleContext.Data = params.Data[0] // validate the templates with the first row
+	}
+
+	if *protoFile != "" {
+		protoBody, err := parseFile(*protoFile, nil)
+		if err != nil {
+			usageAndExit(*protoFile + " file read error(" + err.Error() + ").")
+		}
+		if len(protoBody) > 0 {
+			params.RequestProto = protoBody[0]
+		}
+		params.RequestProtoMsg = *protoMsg
 	}
 
 	if *scriptFile != "" {
@@ -738,7 +2567,25 @@ func main() {
 	}
 
 	if strings.ToLower(*pType) != "" {
-		params.RequestType = strings.ToLower(*pType)
+		switch t := strings.ToLower(*pType); t {
+		case typeTCP, typeUDP:
+			params.RequestType = t
+		default:
+			usageAndExit("not support -p: " + *pType)
+		}
+		params.UdpNoResponse = !*udpResponse
+		switch params.RequestBodyType {
+		case "", bodyString, bodyHex:
+		default:
+			usageAndExit("-p only supports -bodytype string and hex.")
+		}
+		if *tcpResponseSize != "" {
+			size, err := parseSize(*tcpResponseSize)
+			if err != nil || size <= 0 || params.RequestType != typeTCP {
+				usageAndExit("invalid -tcp-response-size, a size of -p tcp: " + *tcpResponseSize)
+			}
+			params.TcpResponseSize = size
+		}
 	} else {
 		switch t := strings.ToLower(*httpType); t {
 		case typeHttp1, typeHttp2, typeWs, typeWss:
@@ -753,19 +2600,38 @@ func main() {
 		}
 	}
 
-	// set any other additional repeatable headers
-	for _, h := range headerslice {
-		match, err := parseInputWithRegexp(h, headerRegexp)
-		if err != nil {
-			usageAndExit(err.Error())
-		}
-		if params.Headers == nil {
-			params.Headers = make(map[string][]string, 0)
+	// set any other additional repeatable headers, the same key sends all values
+	params.Headers = make(map[string][]string, 0)
+	if err := parseHeaders(params.Headers, headerslice, false); err != nil {
+		usageAndExit(err.Error())
+	}
+	if err := parseHeaders(params.Headers, headerReplaceSlice, true); err != nil {
+		usageAndExit(err.Error())
+	}
+	for i, header := range spoofHeaderSlice {
+		if !strings.Contains(header, ":") {
+			spoofHeaderSlice[i] = header + ": {{ randomIP }}"
 		}
-		params.Headers[match[1]] = []string{match[2]}
 	}
+	if err := parseHeaders(params.Headers, spoofHeaderSlice, true); err != nil {
+		usageAndExit(err.Error())
+	}
+	if _, err := parseCidrs(spoofCidrSlice); err != nil {
+		usageAndExit("invalid -spoof-cidr: " + err.Error())
+	}
+	params.SpoofCidr = spoofCidrSlice
 
-	// set basic auth if set
+	if *dedupRepeat < 1 {
+		usageAndExit("-dedup-repeat cannot be smaller than 1.")
+	}
+	if *dedupVerify != "" {
+		if _, err := parseDedupVerify(*dedupVerify); err != nil {
+			usageAndExit("invalid -dedup-verify: " + err.Error())
+		}
+	}
+	params.DedupHeader, params.DedupRepeat, params.DedupVerify = *dedupHeader, *dedupRepeat, *dedupVerify
+
+	// set basic auth if set
 	if *authHeader != "" {
 		match, err := parseInputWithRegexp(*authHeader, authRegexp)
 		if err != nil {
@@ -776,13 +2642,637 @@ func main() {
 		}
 	}
 
-	if *output != "" && *output != "csv" {
-		usageAndExit("invalid output type; only csv is supported.")
+	for _, v := range outputSlice {
+		spec, err := parseOutputSpec(v)
+		if err != nil {
+			usageAndExit(err.Error())
+		}
+		switch spec.output {
+		case outputMarkdown:
+			if *baselineFile != "" && baselineResult == nil {
+				if baselineResult, err = loadResultFile(*baselineFile); err != nil {
+					usageAndExit("invalid -baseline: " + err.Error())
+				}
+			}
+		case outputTemplate:
+			if *outputTplFile == "" {
+				usageAndExit("-o template requires -output-template.")
+			}
+			content, err := os.ReadFile(*outputTplFile)
+			if err != nil {
+				usageAndExit(*outputTplFile + " file read error(" + err.Error() + ").")
+			}
+			if _, err := parseOutputTemplate(string(content)); err != nil {
+				usageAndExit("invalid -output-template: " + err.Error())
+			}
+			params.OutputTemplate = string(content)
+		}
+		outputSpecs = append(outputSpecs, spec)
+	}
+	params.Output = stdoutOutput(outputSpecs)
+
+	if *workerConfig != "" {
+		d, err := loadWorkerConfig(*workerConfig, workerList)
+		if err != nil {
+			usageAndExit("invalid -worker-config: " + err.Error())
+		}
+		if workerList, err = d.resolve(); err != nil {
+			usageAndExit("resolve workers of -worker-config: " + err.Error())
+		}
+		discovery = d
+	}
+
+	switch *splitMode {
+	case splitPerWorker:
+	case splitTotal:
+		if len(workerList) > 0 && params.C < len(workerList) {
+			usageAndExit(fmt.Sprintf("-c %d is fewer than the %d workers of -worker-split total.", params.C, len(workerList)))
+		}
+		workerSplit = *splitMode
+	default:
+		usageAndExit("invalid -worker-split: " + *splitMode + ", expect per-worker or total.")
+	}
+
+	if *region != "" {
+		weights, err := parseRegionWeights(*region)
+		if err != nil {
+			usageAndExit(err.Error())
+		}
+		if err := checkWorkerRegions(weights, workerList); err != nil {
+			usageAndExit(err.Error())
+		}
+		regionWeights = weights
+	}
+
+	if *workerSecret == "" {
+		*workerSecret = getEnv("STRESS_WORKER_TOKEN")
+	}
+	if *workerSecret != "" {
+		if len(*workerSecret) < 16 {
+			usageAndExit("-worker-token must be at least 16 characters.")
+		}
+		workerToken = []byte(*workerSecret)
+		go sweepWorkerNonces()
+	}
+	if *workerCA != "" {
+		if err := loadWorkerCA(*workerCA); err != nil {
+			usageAndExit(err.Error())
+		}
+	}
+	if heartbeatEvery, err = time.ParseDuration(*heartbeat); err != nil || heartbeatEvery < 0 {
+		usageAndExit("invalid -worker-heartbeat: " + *heartbeat + ", e.g. 5s, 0 disables.")
+	}
+	switch *failureAction {
+	case failureContinue, failureAbort:
+		failurePolicy = *failureAction
+	default:
+		usageAndExit("invalid -worker-failure-policy: " + *failureAction + ", expect continue or abort.")
+	}
+	if (*listenTlsCert == "") != (*listenTlsKey == "") {
+		usageAndExit("-listen-tls-cert and -listen-tls-key must be set together.")
+	}
+
+	if isCollect {
+		if len(workerList) <= 0 || *seqId <= 0 {
+			usageAndExit("collect requires -W worker list and -seqid.")
+		}
+		params.Cmd = cmdCollect
+		params.SequenceId = *seqId
+		if _, stressResult := executeStress(params); stressResult != nil {
+			stressResult.writeOutputs(outputSpecs)
+			code := stressResult.exitCode()
+			if !stressResult.checkSla(os.Stderr) && code == exitOK {
+				code = exitAssertion
+			}
+			os.Exit(code)
+		}
+		return
+	}
+
+	if *replayReport != "" {
+		stressResult, err := replayRecord(*replayReport, *seqId, params.LatencyResolution)
+		if err != nil {
+			usageAndExit(err.Error())
+		}
+		stressResult.checkSamples(params.MinSamples)
+		stressResult.writeOutputs(outputSpecs)
+		code := stressResult.exitCode()
+		if !stressResult.checkSla(os.Stderr) && code == exitOK {
+			code = exitAssertion
+		}
+		os.Exit(code)
 	}
 
 	// set request timeout
 	params.Timeout = *t
 
+	if *signHmac != "" {
+		switch params.RequestType {
+		case typeHttp1, typeHttp2, typeHttp3:
+		default:
+			usageAndExit("-sign-hmac only supports http1, http2 and http3.")
+		}
+		if params.SignHmac, err = parseHmacSign(*signHmac); err != nil {
+			usageAndExit("invalid -sign-hmac: " + err.Error())
+		}
+	}
+
+	if len(interfaceSlice) > 0 {
+		if params.RequestType == typeHttp3 {
+			usageAndExit("-interface does not support http3.")
+		}
+		for _, name := range interfaceSlice {
+			if _, err := resolveInterface(name); err != nil && len(workerList) <= 0 {
+				usageAndExit("invalid -interface: " + err.Error())
+			}
+		}
+		params.Interfaces = interfaceSlice
+	}
+
+	if len(localIpSlice) > 0 || len(localIpRangeSlice) > 0 {
+		switch params.RequestType {
+		case typeHttp1, typeHttp2, typeWs, typeWss, typeTCP:
+		default:
+			usageAndExit("-local-ip and -local-ip-range only support http1, http2, ws, wss and -p tcp.")
+		}
+		if *proxyAddr != "" || len(interfaceSlice) > 0 || *compareFamily {
+			usageAndExit("-local-ip and -local-ip-range can't be used with -x, -interface or -compare-ip-family.")
+		}
+		params.LocalIps = append(append([]string{}, localIpSlice...), localIpRangeSlice...)
+		for _, s := range localIpRangeSlice {
+			if !strings.Contains(s, "/") {
+				usageAndExit("invalid -local-ip-range " + s + ", expect a cidr, e.g. 10.0.0.0/24.")
+			}
+		}
+		ips, err := parseLocalIps(params.LocalIps)
+		if err == nil && len(workerList) <= 0 {
+			err = checkLocalIps(ips)
+		}
+		if err != nil {
+			usageAndExit("invalid -local-ip: " + err.Error())
+		}
+	}
+
+	if *compareFamily {
+		switch params.RequestType {
+		case typeHttp1, typeHttp2, typeWs, typeWss, typeTCP:
+		default:
+			usageAndExit("-compare-ip-family only supports http1, http2, ws, wss and -p tcp.")
+		}
+		if *proxyAddr != "" || len(interfaceSlice) > 0 {
+			usageAndExit("-compare-ip-family does not support -x or -interface.")
+		}
+		for _, entry := range requestUrls {
+			if _, err := resolveFamilies(entry.url); err != nil && len(workerList) <= 0 {
+				usageAndExit("invalid -compare-ip-family: " + err.Error())
+			}
+		}
+		params.CompareIpFamily = true
+	}
+
+	if len(resolveSlice) > 0 {
+		if params.RequestType == typeHttp3 || params.RequestType == typeUDP {
+			usageAndExit("-resolve does not support http3 and -p udp.")
+		}
+		if _, err := parseResolves(resolveSlice); err != nil {
+			usageAndExit(err.Error())
+		}
+		params.Resolve = resolveSlice
+	}
+	if *dnsEachRequest {
+		if params.RequestType != typeHttp1 {
+			usageAndExit("-dns-each-request only supports http1.")
+		}
+		params.DnsEachRequest = true
+	}
+
+	if len(autoHeaderSlice) > 0 {
+		switch params.RequestType {
+		case typeHttp1, typeHttp2, typeHttp3:
+		default:
+			usageAndExit("-auto-header only supports http1, http2 and http3.")
+		}
+		if err := checkAutoHeaders(autoHeaderSlice); err != nil {
+			usageAndExit(err.Error())
+		}
+		params.AutoHeaders = autoHeaderSlice
+	}
+
+	if len(assertSlice) > 0 {
+		switch params.RequestType {
+		case typeHttp1, typeHttp2, typeHttp3:
+		default:
+			usageAndExit("-assert only supports http1, http2 and http3.")
+		}
+		for _, expr := range assertSlice {
+			if _, err := parseAssert(expr); err != nil {
+				usageAndExit(err.Error())
+			}
+		}
+		params.Asserts = assertSlice
+	}
+
+	if len(extractSlice) > 0 {
+		switch params.RequestType {
+		case typeHttp1, typeHttp2, typeHttp3:
+		default:
+			usageAndExit("-extract only supports http1, http2 and http3.")
+		}
+		if *pipeline > 0 || len(params.Flow) > 0 {
+			usageAndExit("-extract can't be used with -pipeline or -flow, the steps of -flow extract variables themselves.")
+		}
+		for _, expr := range extractSlice {
+			e, err := parseExtract(expr)
+			if err != nil {
+				usageAndExit(err.Error())
+			}
+			sampleContext.Vars[e.name] = ""
+		}
+		params.Extracts = extractSlice
+	}
+
+	if *mix != "" {
+		switch params.RequestType {
+		case typeHttp1, typeHttp2, typeHttp3:
+		default:
+			usageAndExit("-mix only supports http1, http2 and http3.")
+		}
+		switch {
+		case len(params.Flow) > 0 || *pipeline > 0 || *batch > 0:
+			usageAndExit("-mix can't be used with -flow, -pipeline or -batch.")
+		case params.BodyBase != "" || len(params.RequestForm) > 0 || len(params.MultipartForm) > 0 ||
+			params.RequestBodyType == bodyHex || params.RequestBodyType == bodyProtobuf:
+			usageAndExit("-mix can't be used with -body-patch, -form-urlencoded, -form, or hex and protobuf bodies.")
+		}
+		if params.Mix, err = parseMix(*mix, mixUrlSlice, mixBodySlice); err != nil {
+			usageAndExit(err.Error())
+		}
+	} else if len(mixUrlSlice) > 0 || len(mixBodySlice) > 0 {
+		usageAndExit("-mix-url and -mix-body require -mix.")
+	}
+
+	switch *urlStrategy {
+	case urlSequential:
+	case urlRandom, urlRoundRobin, urlWeighted:
+		switch {
+		case *urlFile == "":
+			usageAndExit("-url-strategy requires -url-file.")
+		case params.RequestType != typeHttp1 && params.RequestType != typeHttp2 && params.RequestType != typeHttp3:
+			usageAndExit("-url-strategy only supports http1, http2 and http3.")
+		case len(params.Mix) > 0:
+			usageAndExit("-url-strategy can't be used with -mix.")
+		}
+		params.UrlStrategy, params.Targets = *urlStrategy, newUrlTargets(requestUrls, params.RequestMethod)
+	default:
+		usageAndExit("invalid -url-strategy: " + *urlStrategy + ", expect sequential, random, roundrobin or weighted.")
+	}
+
+	if *schemaFile != "" {
+		switch params.RequestType {
+		case typeHttp1, typeHttp2, typeHttp3:
+		default:
+			usageAndExit("-assert-json-schema only supports http1, http2 and http3.")
+		}
+		content, err := os.ReadFile(*schemaFile)
+		if err != nil {
+			usageAndExit(*schemaFile + " file read error(" + err.Error() + ").")
+		}
+		if _, err := parseJsonSchema(content); err != nil {
+			usageAndExit(*schemaFile + ": " + err.Error())
+		}
+		sample, err := parsePercent(*schemaSample)
+		switch {
+		case err != nil || sample <= 0:
+			usageAndExit("invalid -assert-json-schema-sample: " + *schemaSample)
+		case *schemaExamples < 0:
+			usageAndExit("-assert-json-schema-examples cannot be smaller than 0.")
+		case *pipeline > 0 || len(params.Flow) > 0:
+			usageAndExit("-assert-json-schema can't be used with -pipeline or -flow.")
+		}
+		params.JsonSchema, params.JsonSchemaSample, params.JsonSchemaExamples = string(content), sample, *schemaExamples
+	}
+
+	if *batch > 0 {
+		switch {
+		case *batch < 2:
+			usageAndExit("-batch must be at least 2.")
+		case params.RequestType != typeHttp1 && params.RequestType != typeHttp2 && params.RequestType != typeHttp3:
+			usageAndExit("-batch only supports http1, http2 and http3.")
+		case params.RequestBodyType != "" && params.RequestBodyType != bodyString && params.RequestBodyType != bodyJson:
+			usageAndExit("-batch only supports string and json body.")
+		}
+		params.Batch = *batch
+	}
+
+	if *pipeline > 0 {
+		switch {
+		case *pipeline < 2:
+			usageAndExit("-pipeline must be at least 2.")
+		case params.RequestType != typeHttp1:
+			usageAndExit("-pipeline only supports http1.")
+		case *proxyAddr != "" || *fallbackUrl != "" || params.DisableKeepAlives:
+			usageAndExit("-pipeline can't be used with -x, -fallback-url or -disable-keepalive.")
+		}
+		params.Pipeline = *pipeline
+	}
+
+	if *expectWait != "" {
+		threshold, err := time.ParseDuration(*expectWait)
+		switch {
+		case err != nil || threshold < time.Millisecond:
+			usageAndExit("-expect-continue must be a duration of at least 1ms, e.g. 1s.")
+		case params.RequestType != typeHttp1:
+			usageAndExit("-expect-continue only supports http1.")
+		case params.Pipeline > 0:
+			usageAndExit("-expect-continue can't be used with -pipeline.")
+		}
+		params.ExpectContinue = threshold.Milliseconds()
+	}
+
+	if *longPoll {
+		timeout, err := time.ParseDuration(*pollTimeout)
+		switch {
+		case err != nil || timeout < time.Millisecond:
+			usageAndExit("-longpoll-timeout must be a duration of at least 1ms, e.g. 30s.")
+		case params.RequestType != typeHttp1 && params.RequestType != typeHttp2:
+			usageAndExit("-longpoll only supports http1 and http2.")
+		case params.Qps > 0 || params.Pipeline > 0:
+			usageAndExit("-longpoll re-issues polls immediately, can't be used with -q or -pipeline.")
+		}
+		params.LongPoll = timeout.Milliseconds()
+		params.Timeout += int(params.LongPoll) // the server holds the poll before the usual timeout
+	}
+
+	if len(params.Flow) > 0 {
+		switch {
+		case params.RequestType != typeHttp1 && params.RequestType != typeHttp2 && params.RequestType != typeHttp3:
+			usageAndExit("-flow only supports http1, http2 and http3.")
+		case params.Pipeline > 0 || params.LongPoll > 0 || params.Batch > 0:
+			usageAndExit("-flow can't be used with -pipeline, -longpoll or -batch.")
+		}
+	}
+
+	if len(paramSlice) > 0 {
+		switch {
+		case params.RequestType != typeHttp1 && params.RequestType != typeHttp2 && params.RequestType != typeHttp3:
+			usageAndExit("-param only supports http1, http2 and http3.")
+		case len(params.Flow) > 0:
+			usageAndExit("-param can't be used with -flow, the urls are in the steps.")
+		}
+		for _, field := range paramSlice {
+			if !strings.Contains(field, "=") {
+				usageAndExit("invalid -param: " + field + ", expect key=value.")
+			}
+		}
+		params.QueryParams = paramSlice
+	}
+
+	if params.EnableCookies && params.RequestType != typeHttp1 && params.RequestType != typeHttp2 && params.RequestType != typeHttp3 {
+		usageAndExit("-enable-cookies only supports http1, http2 and http3.")
+	}
+
+	if params.BodyStream != "" || *chunked {
+		switch {
+		case params.RequestType != typeHttp1 && params.RequestType != typeHttp2 && params.RequestType != typeHttp3:
+			usageAndExit("-body-stream and -chunked only support http1, http2 and http3.")
+		case params.BodyStream != "" && (len(params.Flow) > 0 || len(params.Mix) > 0 || *urlStrategy != urlSequential):
+			usageAndExit("-body-stream can't be used with -flow, -mix or -url-strategy, their bodies are templates.")
+		}
+		params.Chunked = *chunked
+	}
+
+	if *resume != "" {
+		offset, err := parseSize(*resume)
+		switch {
+		case err != nil || offset <= 0:
+			usageAndExit("invalid -resume: " + *resume + ", expect a size of at least 1 byte, e.g. 1MB.")
+		case params.RequestType != typeHttp1 && params.RequestType != typeHttp2 && params.RequestType != typeHttp3:
+			usageAndExit("-resume only supports http1, http2 and http3.")
+		case params.RequestMethod != "GET":
+			usageAndExit("-resume only supports GET downloads.")
+		case params.Pipeline > 0 || params.LongPoll > 0 || params.Batch > 0 || len(params.Flow) > 0 || params.JsonSchema != "":
+			usageAndExit("-resume can't be used with -pipeline, -longpoll, -batch, -flow or -assert-json-schema.")
+		}
+		params.Resume = offset
+		params.DisableCompression = true // Range of the identity content
+	}
+
+	if *abortBytes != "" {
+		size, err := parseSize(*abortBytes)
+		if err != nil || size <= 0 {
+			usageAndExit("invalid -abort-after-bytes: " + *abortBytes + ", expect a size of at least 1 byte, e.g. 64KB.")
+		}
+		params.AbortAfterBytes = size
+	}
+	if *abortAfter != "" {
+		after, err := time.ParseDuration(*abortAfter)
+		if err != nil || after < time.Millisecond {
+			usageAndExit("invalid -abort-after: " + *abortAfter + ", expect a duration of at least 1ms, e.g. 200ms.")
+		}
+		params.AbortAfter = after.Milliseconds()
+	}
+	if params.AbortAfterBytes > 0 || params.AbortAfter > 0 {
+		switch {
+		case params.RequestType != typeHttp1 && params.RequestType != typeHttp2 && params.RequestType != typeHttp3:
+			usageAndExit("-abort-after-bytes and -abort-after only support http1, http2 and http3.")
+		case params.Resume > 0 || params.Pipeline > 0 || params.LongPoll > 0:
+			usageAndExit("-abort-after-bytes and -abort-after can't be used with -resume, -pipeline or -longpoll.")
+		}
+	}
+
+	if *holdCount > 0 {
+		switch params.RequestType {
+		case typeHttp1, typeHttp2, typeWs, typeWss:
+		default:
+			usageAndExit("-hold-connections only supports http1, http2, ws and wss.")
+		}
+		params.HoldConnections = *holdCount
+	}
+
+	if len(wsSubprotocolSlice) > 0 || *wsCompression || *wsOrigin != "" {
+		if params.RequestType != typeWs && params.RequestType != typeWss {
+			usageAndExit("-ws-subprotocol, -ws-compression and -ws-origin only support ws and wss.")
+		}
+		for _, v := range wsSubprotocolSlice {
+			if v == "" || strings.ContainsAny(v, " ,;\t") {
+				usageAndExit("invalid -ws-subprotocol: " + v)
+			}
+		}
+		params.WsSubprotocols, params.WsCompression, params.WsOrigin = wsSubprotocolSlice, *wsCompression, *wsOrigin
+	}
+
+	if *holdTime != "" {
+		hold, err := time.ParseDuration(*holdTime)
+		switch {
+		case err != nil || hold <= 0:
+			usageAndExit("invalid -hold-duration: " + *holdTime)
+		case params.HoldConnections <= 0:
+			usageAndExit("-hold-duration requires -hold-connections.")
+		}
+		params.HoldDuration = hold.Milliseconds()
+	}
+
+	if *sloFile != "" {
+		targets, err := parseSlo(*sloFile)
+		if err != nil {
+			usageAndExit(err.Error())
+		}
+		sloTargets = targets
+	}
+
+	if slaAsserts, err = parseSla(*slaP99, *slaErrorRate); err != nil {
+		usageAndExit(err.Error())
+	}
+
+	if *bundlePath != "" {
+		if err := checkBundlePath(*bundlePath); err != nil {
+			usageAndExit(err.Error())
+		}
+	}
+
+	var progressEvery time.Duration
+	if *progress != "" {
+		every, err := time.ParseDuration(*progress)
+		if err != nil || every < 100*time.Millisecond {
+			usageAndExit("invalid -progress: " + *progress + ", at least 100ms.")
+		}
+		progressEvery = every
+	}
+
+	var scrapeEvery time.Duration
+	if *targetMetricsUrl != "" {
+		if u, err := gourl.Parse(*targetMetricsUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			usageAndExit("invalid -target-metrics-url: " + *targetMetricsUrl)
+		}
+		every, err := time.ParseDuration(*targetMetricsInterval)
+		if err != nil || every < time.Second {
+			usageAndExit("invalid -target-metrics-interval: " + *targetMetricsInterval + ", at least 1s.")
+		}
+		scrapeEvery = every
+	}
+
+	if *startJitter != "" {
+		jitter, err := time.ParseDuration(*startJitter)
+		if err != nil || jitter < 0 {
+			usageAndExit("invalid -start-jitter: " + *startJitter)
+		}
+		params.StartJitter = jitter.Milliseconds()
+	}
+
+	if *warmup != "" {
+		wu, err := time.ParseDuration(*warmup)
+		if err != nil || wu < 0 {
+			usageAndExit("invalid -warmup: " + *warmup)
+		}
+		params.Warmup = wu.Milliseconds()
+	}
+
+	if params.Duration == 0 {
+		w, err := time.ParseDuration(*window)
+		if err != nil || w < time.Second {
+			usageAndExit("invalid -window: " + *window + ", at least 1s.")
+		}
+		params.Window = w.Milliseconds()
+	}
+
+	if *rate != "" {
+		arrivalRate, err := parseRate(*rate)
+		switch {
+		case err != nil:
+			usageAndExit(err.Error())
+		case params.Qps > 0 || *polite || params.Pipeline > 0:
+			usageAndExit("-rate starts requests on schedule, can't be used with -q, -polite or -pipeline.")
+		}
+		params.ArrivalRate = arrivalRate
+	}
+
+	if *thinkTime != "" || *thinkTimeRandom != "" {
+		switch {
+		case *thinkTime != "" && *thinkTimeRandom != "":
+			usageAndExit("-think-time and -think-time-random can't be used together.")
+		case params.ArrivalRate > 0 || params.Pipeline > 0:
+			usageAndExit("-think-time pauses the connections between requests, can't be used with -rate or -pipeline.")
+		}
+		if *thinkTime != "" {
+			d, err := time.ParseDuration(*thinkTime)
+			if err != nil || d < time.Millisecond {
+				usageAndExit("invalid -think-time: " + *thinkTime + ", at least 1ms.")
+			}
+			params.ThinkTime, params.ThinkTimeMax = d.Milliseconds(), d.Milliseconds()
+		} else {
+			min, max, err := parseThinkRange(*thinkTimeRandom)
+			if err == nil && max < time.Millisecond {
+				err = errors.New("invalid -think-time-random: " + *thinkTimeRandom + ", the max is at least 1ms.")
+			}
+			if err != nil {
+				usageAndExit(err.Error())
+			}
+			params.ThinkTime, params.ThinkTimeMax = min.Milliseconds(), max.Milliseconds()
+		}
+	}
+
+	if err := checkGCTuning(*gcTuning); err != nil {
+		usageAndExit(err.Error())
+	}
+	if *gcTuning == gcTuningAuto {
+		params.GcTuning = gcTuningAuto
+	}
+
+	if *polite {
+		if params.PoliteErrors, err = parsePercent(*politeErrors); err != nil {
+			usageAndExit("invalid -polite-errors: " + *politeErrors)
+		}
+		if params.PoliteOverload, err = parsePercent(*politeOverload); err != nil {
+			usageAndExit("invalid -polite-overload: " + *politeOverload)
+		}
+		params.Polite = true
+	}
+
+	params.DisableNoDelay = !*tcpNoDelay
+	if *tcpKeepAlive != "" {
+		keepAlive, err := time.ParseDuration(*tcpKeepAlive)
+		if err != nil || keepAlive < 0 || (keepAlive > 0 && keepAlive < time.Second) {
+			usageAndExit("invalid -tcp-keepalive: " + *tcpKeepAlive + ", 0 or at least 1s.")
+		}
+		params.TcpKeepAlive = keepAlive.Milliseconds()
+		if keepAlive == 0 {
+			params.TcpKeepAlive = -1
+		}
+	}
+	if *sendBuffer != "" {
+		if params.SendBuffer, err = parseSize(*sendBuffer); err != nil {
+			usageAndExit("invalid -send-buffer: " + err.Error())
+		}
+	}
+	if *recvBuffer != "" {
+		if params.RecvBuffer, err = parseSize(*recvBuffer); err != nil {
+			usageAndExit("invalid -recv-buffer: " + err.Error())
+		}
+	}
+
+	if *maxBodyRead != "" {
+		if params.MaxBodyRead, err = parseSize(*maxBodyRead); err != nil {
+			usageAndExit(err.Error())
+		}
+	}
+
+	if *fallbackUrl != "" {
+		switch params.RequestType {
+		case typeHttp1, typeHttp2, typeHttp3:
+		default:
+			usageAndExit("-fallback-url only supports http1, http2 and http3.")
+		}
+		if u, err := gourl.Parse(*fallbackUrl); err != nil || u.Scheme == "" || u.Host == "" {
+			usageAndExit("invalid -fallback-url: " + *fallbackUrl)
+		}
+		fallbackDuration, err := time.ParseDuration(*fallbackAfter)
+		if err != nil || fallbackDuration < 0 {
+			usageAndExit("invalid -fallback-after: " + *fallbackAfter)
+		}
+		params.FallbackUrl = *fallbackUrl
+		params.FallbackAfter = fallbackDuration.Milliseconds()
+	}
+
 	if *proxyAddr != "" {
 		if proxyUrl, err = gourl.Parse(*proxyAddr); err != nil {
 			usageAndExit(err.Error())
@@ -804,37 +3294,239 @@ func main() {
 		dashboardHtml = strings.ReplaceAll(dashboardHtml, "/api", stressWorkerAPI)
 	}
 
+	if collectKeepTime, err = time.ParseDuration(*resultTTL); err != nil || collectKeepTime <= 0 {
+		usageAndExit("invalid -result-ttl: " + *resultTTL)
+	}
+	if *historyFile != "" {
+		if err := openHistory(*historyFile); err != nil {
+			usageAndExit(err.Error())
+		}
+	}
+	if *recordFile != "" {
+		if len(workerList) > 0 {
+			usageAndExit("-record cannot be used with -W, run the workers with -record instead.")
+		}
+		if err := openRecord(*recordFile); err != nil {
+			usageAndExit(err.Error())
+		}
+	}
+	if *workerMaxC < 0 || *workerMaxQ < 0 {
+		usageAndExit("-worker-max-c and -worker-max-q cannot be negative.")
+	}
+	shareMaxConns, shareMaxQps = *workerMaxC, *workerMaxQ
+
 	if len(*dashboard) > 0 {
 		*listen = *dashboard
 	}
 
 	if len(*listen) > 0 {
+		if *listenOperators != "" && *listenOidc == "" {
+			usageAndExit("-listen-operators requires -listen-oidc.")
+		}
+		if u, err := gourl.Parse(*listenOidc); *listenOidc != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https")) {
+			usageAndExit("invalid -listen-oidc, expect the userinfo endpoint url: " + *listenOidc)
+		}
+		auth, err := newListenAuth(listenAuthSlice, *listenOidc, *listenOperators, len(workerToken) > 0)
+		if err != nil {
+			usageAndExit(err.Error())
+		}
+		listenStreamFiles = listenFileSlice
+
 		mux := http.NewServeMux()
 		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte(dashboardHtml)) // export dashboard index.html
 		})
 		mux.HandleFunc(httpWorkerApiPath, serveWorker)
+		mux.HandleFunc(httpWorkerJobsPath, serveJobs)
+		mux.HandleFunc(httpWorkerJobsPath+"/", serveJobRate)
+		mux.HandleFunc(httpWorkerAnnotatePath, serveAnnotate)
+		mux.HandleFunc(httpWorkerSharePath, serveShare)
+		mux.HandleFunc(httpWorkerLivePath, serveLive)
+		mux.HandleFunc(httpWorkerHistoryPath, serveHistory)
+		mux.HandleFunc(httpWorkerHistoryPath+"/", serveHistory)
+		mux.HandleFunc(httpTestsPath, serveTests)
+		mux.HandleFunc(httpTestsPath+"/", serveTests)
+		mux.HandleFunc(httpSharePath, serveShareView)
+		mux.HandleFunc(httpMetricsPath, serveMetrics)
+		var handler http.Handler = mux
+		if auth != nil {
+			handler = auth.wrap(mux)
+		}
 		mainServer = &http.Server{
 			Addr:    *listen,
-			Handler: mux,
+			Handler: handler,
+		}
+		if *listenTlsCert != "" {
+			println("listen %s, and you can open https://%s/index.html on browser", *listen, *listen)
+			err = mainServer.ListenAndServeTLS(*listenTlsCert, *listenTlsKey)
+		} else {
+			println("listen %s, and you can open http://%s/index.html on browser", *listen, *listen)
+			err = mainServer.ListenAndServe()
 		}
-		println("listen %s, and you can open http://%s/index.html on browser", *listen, *listen)
-		if err := mainServer.ListenAndServe(); err != nil {
+		if err != nil {
 			verbosePrint(vERROR, "listen err: %s", err.Error())
+			os.Exit(exitInternal)
 		}
 		return
 	}
 
-	if len(requestUrls) <= 0 {
+	if len(requestUrls) <= 0 && len(validateErrs) <= 0 {
 		usageAndExit("url or url-file empty.")
 	}
 
-	for _, url := range requestUrls {
-		params.Url = url
+	// validate all urls and templates before running, and report all errors at once
+	for _, entry := range requestUrls {
+		prefix := "url: "
+		if entry.line > 0 {
+			prefix = fmt.Sprintf("%s:%d: ", *urlFile, entry.line)
+		}
+		for _, e := range validateUrlEntry(entry, params.RequestType) {
+			validateErrs = append(validateErrs, prefix+e)
+		}
+	}
+	if params.RequestBodyType != bodyHex {
+		var bodyBytes bytes.Buffer
+		if tpl, err := template.New("BODY").Funcs(fnMap).Parse(params.RequestBody); err != nil {
+			validateErrs = append(validateErrs, "body: "+err.Error())
+		} else if err := tpl.Execute(&bodyBytes, sampleContext); err != nil {
+			validateErrs = append(validateErrs, "body: "+err.Error())
+		} else if params.RequestBodyType == bodyProtobuf {
+			if msg, err := parseProto(params.RequestProto, params.RequestProtoMsg); err != nil {
+				validateErrs = append(validateErrs, "proto: "+err.Error())
+			} else if _, err := msg.encodeJSON(bodyBytes.Bytes()); err != nil {
+				validateErrs = append(validateErrs, "body: "+err.Error())
+			}
+		}
+	}
+	for _, e := range validateHeaderTemplates(params.Headers) {
+		validateErrs = append(validateErrs, "header: "+e)
+	}
+	for _, e := range validateFlow(params.Flow, params.Headers) {
+		validateErrs = append(validateErrs, *flowFile+": "+e)
+	}
+	for _, field := range params.RequestForm {
+		_, value, _ := strings.Cut(field, "=")
+		if tpl, err := template.New("FORM").Funcs(fnMap).Parse(value); err != nil {
+			validateErrs = append(validateErrs, "form: "+err.Error())
+		} else if err := tpl.Execute(io.Discard, sampleContext); err != nil {
+			validateErrs = append(validateErrs, "form: "+err.Error())
+		}
+	}
+	for _, field := range params.MultipartForm {
+		if field.FileName != "" {
+			continue
+		}
+		if tpl, err := template.New("FORM").Funcs(fnMap).Parse(field.Value); err != nil {
+			validateErrs = append(validateErrs, "form: "+err.Error())
+		} else if err := tpl.Execute(io.Discard, sampleContext); err != nil {
+			validateErrs = append(validateErrs, "form: "+err.Error())
+		}
+	}
+	for _, field := range params.QueryParams {
+		_, value, _ := strings.Cut(field, "=")
+		if tpl, err := template.New("PARAM").Funcs(fnMap).Parse(value); err != nil {
+			validateErrs = append(validateErrs, "param: "+err.Error())
+		} else if err := tpl.Execute(io.Discard, sampleContext); err != nil {
+			validateErrs = append(validateErrs, "param: "+err.Error())
+		}
+	}
+	validateErrs = append(validateErrs, validateMix(params.Mix)...)
+	var stageEntries = make(map[string][]urlEntry, 0)
+	for name, fileName := range map[string]string{stageSetup: *setupFile, stageTeardown: *teardownFile} {
+		if fileName == "" {
+			continue
+		}
+		switch params.RequestType {
+		case typeHttp1, typeHttp2, typeHttp3:
+		default:
+			usageAndExit("-setup and -teardown only support http1, http2 and http3.")
+		}
+		entries, errs, err := parseUrlFile(fileName)
+		if err != nil {
+			usageAndExit(fileName + " file read error(" + err.Error() + ").")
+		}
+		validateErrs = append(validateErrs, errs...)
+		for _, entry := range entries {
+			for _, e := range validateUrlEntry(entry, params.RequestType) {
+				validateErrs = append(validateErrs, fmt.Sprintf("%s:%d: %s", fileName, entry.line, e))
+			}
+		}
+		stageEntries[name] = entries
+	}
+	stageTimebox, err := time.ParseDuration(*stageTimeout)
+	if err != nil || stageTimebox <= 0 {
+		validateErrs = append(validateErrs, "invalid -stage-timeout: "+*stageTimeout)
+	}
+	if len(validateErrs) > 0 {
+		usageAndExit(strings.Join(validateErrs, "\n"))
+	}
+
+	var bundle *runBundle
+	if *bundlePath != "" {
+		bundle = newRunBundle(*bundlePath, commandLine)
+	}
+	exit := func(code int) {
+		if recorder != nil {
+			if err := recorder.close(); err != nil {
+				verbosePrint(vERROR, "write -record err: %v", err)
+			} else {
+				eprintln("samples recorded to %s", *recordFile)
+			}
+		}
+		if bundle != nil {
+			if err := bundle.write(code); err != nil {
+				verbosePrint(vERROR, "write bundle err: %v", err)
+			} else {
+				eprintln("bundle written to %s", *bundlePath)
+			}
+		}
+		os.Exit(code)
+	}
+
+	exitCode, stageParams := exitOK, params // before changed by url entries
+	if err := runStage(stageSetup, stageEntries[stageSetup], stageParams, stageTimebox); err != nil {
+		verbosePrint(vERROR, "%v", err)
+		if err := runStage(stageTeardown, stageEntries[stageTeardown], stageParams, stageTimebox); err != nil {
+			verbosePrint(vERROR, "%v", err)
+		}
+		exit(stageExitCode(err))
+	}
+
+	if len(params.Targets) > 0 {
+		// the urls are mixed in one run, the inline method, headers and body apply per url
+		requestUrls = []urlEntry{{line: requestUrls[0].line, url: requestUrls[0].url}}
+	}
+	requestMethod, requestHeaders, requestBody, requestStream := params.RequestMethod, params.Headers, params.RequestBody, params.BodyStream
+	for i, entry := range requestUrls {
+		if i > 0 {
+			refreshWorkers(params.C)
+		}
+		params.Url = entry.url
+		params.RequestMethod = requestMethod
+		if entry.method != "" {
+			params.RequestMethod = entry.method
+		}
+		params.RequestBody, params.BodyStream = requestBody, requestStream
+		if entry.body != "" {
+			params.RequestBody, params.BodyStream = entry.body, "" // the inline body replaces -body-stream
+		}
+		params.Headers = requestHeaders
+		if len(entry.headers) > 0 {
+			params.Headers = http.Header(requestHeaders).Clone()
+			parseHeaders(params.Headers, entry.headers, true) // inline headers overwrite -H
+		}
 		params.SequenceId = time.Now().Unix()
 		params.Cmd = cmdStart
 
 		verbosePrint(vDEBUG, "request params: %s", params.String())
+		if len(workerList) > 0 {
+			// unresponsive workers may have left the fleet, retry once with the fleet resolved again
+			if validateWorkers(params) > 0 && refreshWorkers(params.C) {
+				validateWorkers(params)
+			}
+			eprintln("sequence id: %d, run \"http_bench collect -seqid %d -W ...\" to collect results if the run is cut off",
+				params.SequenceId, params.SequenceId)
+		}
 		stopSignal = make(chan os.Signal)
 		signal.Notify(stopSignal, syscall.SIGINT, syscall.SIGTERM)
 
@@ -842,19 +3534,86 @@ func main() {
 		var stressResult *StressResult
 
 		go func() {
-			<-stopSignal
+			if _, ok := <-stopSignal; !ok {
+				return // the url finished, keep running the next urls
+			}
 			verbosePrint(vINFO, "recv stop signal")
-			params.Cmd = cmdStop // stop workers
-			globalStop = cmdStop // stop all
+			params.Cmd = cmdStop              // stop workers
+			atomic.StoreInt32(&globalStop, 1) // stop all
 			jsonBody, _ := json.Marshal(params)
 			waitWorkerListReq(jsonBody)
 			mainCancel()
 		}()
 
-		if stressTesting, stressResult = executeStress(params); stressResult != nil {
+		annotateStop := make(chan struct{})
+		if *annotateFile != "" {
+			go watchAnnotateFile(*annotateFile, annotateStop)
+		}
+
+		stepSignal := make(chan os.Signal, 1)
+		notifyStepSignal(stepSignal)
+		go watchStepSignal(params.SequenceId, params.C, stepSignal)
+
+		progressStop := make(chan struct{})
+		if progressEvery > 0 {
+			go watchProgress(params, progressEvery, progressStop)
+		}
+
+		var scraper *metricsScraper
+		if *targetMetricsUrl != "" {
+			scraper = newMetricsScraper(*targetMetricsUrl, scrapeEvery)
+			go scraper.run()
+		}
+
+		stressTesting, stressResult = executeStress(params)
+		close(progressStop)
+		if scraper != nil {
+			targetMetrics := scraper.finish()
+			if stressResult != nil {
+				stressResult.TargetMetrics = targetMetrics
+			}
+		}
+		close(annotateStop)
+		signal.Stop(stepSignal)
+		close(stepSignal)
+		if stressResult != nil {
+			signal.Stop(stopSignal)
 			close(stopSignal)
 			stressTesting.Stop(true, nil) // recv stop signal and stop commands
-			stressResult.print()
+			stressResult.writeOutputs(outputSpecs)
+			if exitCode == exitOK {
+				exitCode = stressResult.exitCode()
+			}
+			for _, failed := range stressResult.checkAssertions(projectAsserts) {
+				eprintln("assertion failed: %s", failed)
+				if exitCode == exitOK {
+					exitCode = exitAssertion
+				}
+			}
+			if !stressResult.checkSla(os.Stderr) && exitCode == exitOK {
+				exitCode = exitAssertion
+			}
+			recordSlo(sloTargets, entry.url, stressResult)
+			if bundle != nil {
+				bundle.addRun(params, stressResult)
+			}
+		}
+	}
+
+	if len(sloTargets) > 0 && !printSlo(os.Stderr, sloTargets) && exitCode == exitOK {
+		exitCode = exitAssertion
+	}
+	if len(sloTargets) > 0 && bundle != nil {
+		var slo bytes.Buffer
+		printSlo(&slo, sloTargets)
+		bundle.addFile("slo.txt", slo.Bytes())
+	}
+
+	if err := runStage(stageTeardown, stageEntries[stageTeardown], stageParams, stageTimebox); err != nil {
+		verbosePrint(vERROR, "%v", err)
+		if exitCode == exitOK {
+			exitCode = stageExitCode(err)
 		}
 	}
+	exit(exitCode)
 }
@@ -1,15 +1,18 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"strings"
 	"sync"
 	"testing"
+	"text/template"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -113,8 +116,8 @@ func TestStressHTTP2(t *testing.T) {
 	})
 	srv := &http.Server{Addr: listen, Handler: mux}
 
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		defer wg.Done()
 		if err := srv.ListenAndServeTLS("./test/server.crt", "./test/server.key"); err != nil {
 			fmt.Fprintf(os.Stderr, name+" ListenAndServe err: %s\n", err.Error())
@@ -157,8 +160,8 @@ func TestStressHTTP3(t *testing.T) {
 	})
 	srv := &http3.Server{Addr: listen, Handler: mux}
 
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		defer wg.Done()
 		if err := srv.ListenAndServeTLS("./test/server.crt", "./test/server.key"); err != nil {
 			fmt.Fprintf(os.Stderr, name+" ListenAndServe err: %s\n", err.Error())
@@ -216,8 +219,8 @@ func TestStressWS(t *testing.T) {
 	})
 	srv := &http.Server{Addr: listen, Handler: mux}
 
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		defer wg.Done()
 		if err := srv.ListenAndServe(); err != nil {
 			fmt.Fprintf(os.Stderr, name+" ListenAndServe err: %s\n", err.Error())
@@ -261,8 +264,8 @@ func TestStressHTTP1MultipleWorker(t *testing.T) {
 	})
 	srv := &http.Server{Addr: listen, Handler: mux}
 
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		defer wg.Done()
 		if err := srv.ListenAndServe(); err != nil {
 			fmt.Fprintf(os.Stderr, name+" ListenAndServe err: %s\n", err.Error())
@@ -407,3 +410,115 @@ func TestStressTCP(t *testing.T) {
 	srv.Close()
 	wg.Wait()
 }
+
+func TestAbortAfterErrors(t *testing.T) {
+	start := time.Now()
+	_, result := executeStress(StressParameters{
+		SequenceId:       time.Now().UnixNano(),
+		Cmd:              cmdStart,
+		RequestType:      typeHttp1,
+		RequestMethod:    "GET",
+		Url:              "http://127.0.0.1:1/",
+		C:                2,
+		Duration:         duration,
+		Timeout:          1000,
+		AbortAfterErrors: 20,
+	})
+	if result == nil || !strings.Contains(result.ErrMsg, "aborted after 20 errors") {
+		t.Fatalf("executeStress result: %+v, expect aborted", result)
+	}
+	if elapsed := time.Since(start); elapsed > duration*time.Second/2 {
+		t.Errorf("aborted after %s, expect stop quickly", elapsed)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer fallback.Close()
+	// the port of a closed listener refuses connections
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen err: %v", err)
+	}
+	primary := "http://" + ln.Addr().String()
+	ln.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           primary + "/health",
+		C:             1,
+		Qps:           50,
+		Duration:      1,
+		Timeout:       1000,
+		FallbackUrl:   fallback.URL,
+		FallbackAfter: 200,
+	})
+	if result == nil || result.FailoverUrl != fallback.URL || result.FailoverAfter < 200 || result.FailoverAfter > 900 {
+		t.Fatalf("failover result = %+v", result)
+	}
+	refused, served := result.EndpointDist[primary], result.EndpointDist[fallback.URL]
+	if refused == nil || refused.ErrTotal <= 0 || refused.LatsTotal != 0 {
+		t.Errorf("primary endpoint = %+v, expect refused", refused)
+	}
+	if served == nil || served.LatsTotal <= 0 || served.ErrTotal != 0 || int64(result.StatusCodeDist[200]) != served.LatsTotal {
+		t.Errorf("fallback endpoint = %+v, status codes %v", served, result.StatusCodeDist)
+	}
+}
+
+func TestRenderForm(t *testing.T) {
+	var fields []formField
+	for _, field := range []string{"user name=a&b=c", "q=1+1 = 2", "id={{ intSum 3 4 }}"} {
+		key, value, _ := strings.Cut(field, "=")
+		fields = append(fields, formField{key: key, template: template.Must(template.New(key).Funcs(fnMap).Parse(value))})
+	}
+	var body bytes.Buffer
+	renderForm(&body, fields, nil)
+	if expect := "user+name=a%26b%3Dc&q=1%2B1+%3D+2&id=7"; body.String() != expect {
+		t.Errorf("renderForm = %q, expect %q", body.String(), expect)
+	}
+}
+
+func TestStressForm(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		names = make(map[string]bool)
+		bad   []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := r.ParseForm(); err != nil || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" ||
+			r.PostForm.Get("q") != "a b&c=d" || !strings.HasPrefix(r.PostForm.Get("name"), "user &") {
+			bad = append(bad, fmt.Sprintf("%s %v", r.Header.Get("Content-Type"), r.PostForm))
+		}
+		names[r.PostForm.Get("name")] = true
+	}))
+	defer srv.Close()
+
+	// the templates are rendered and escaped per request
+	_, result := executeStress(StressParameters{
+		SequenceId:      time.Now().UnixNano(),
+		Cmd:             cmdStart,
+		RequestType:     typeHttp1,
+		RequestMethod:   "POST",
+		RequestBodyType: bodyForm,
+		Url:             srv.URL,
+		RequestForm:     []string{"name=user &{{ random 1 1000 }}", "q=a b&c=d"},
+		C:               2,
+		N:               20,
+		Timeout:         3000,
+	})
+	if result == nil || len(result.ErrorDist) > 0 || result.LatsTotal <= 0 {
+		t.Fatalf("result = %+v", result)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bad) > 0 || len(names) < 2 {
+		t.Errorf("forms, bad: %v, names: %d", bad, len(names))
+	}
+}
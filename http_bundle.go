@@ -0,0 +1,246 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const bundleRedacted = "xxxxx" // the same as passwords of url.Redacted
+
+var (
+	// bundleFileFlags flags of input files packaged by -bundle, secret key files are not packaged
+	bundleFileFlags = []string{"url-file", "body-file", "script", "setup", "teardown", "proto", "slo", "flow",
+		"output-template", "baseline", "annotate-file", "assert-json-schema"}
+
+	// bundleSecretFlags values of the flags are redacted in the command line of -bundle
+	bundleSecretFlags = map[string]bool{"a": true, "sign-hmac": true, "listen-auth": true}
+)
+
+// runBundle archive of -bundle, which packages everything of the run to audit and reproduce it later
+type runBundle struct {
+	path     string
+	start    time.Time
+	manifest bundleManifest
+	entries  []bundleEntry
+}
+
+type bundleEntry struct {
+	name string
+	data []byte
+}
+
+// bundleManifest manifest.json of the archive
+type bundleManifest struct {
+	Command    []string        `json:"command"` // secrets are redacted
+	StartTime  string          `json:"start_time"`
+	FinishTime string          `json:"finish_time"`
+	ExitCode   int             `json:"exit_code"`
+	Inputs     []bundleInput   `json:"inputs"`
+	Runs       []bundleRun     `json:"runs"`
+	Generator  bundleGenerator `json:"generator"`
+}
+
+// bundleInput input file with its hash, e.g. feeds of -url-file
+type bundleInput struct {
+	Flag   string `json:"flag"`
+	Path   string `json:"path"`
+	Entry  string `json:"entry"` // name in the archive
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// bundleRun url of the run and the directory of its results in the archive
+type bundleRun struct {
+	Url        string `json:"url"`
+	SequenceId int64  `json:"sequence_id"`
+	Dir        string `json:"dir"`
+}
+
+// bundleGenerator telemetry of the load generator
+type bundleGenerator struct {
+	Hostname   string `json:"hostname"`
+	GoVersion  string `json:"go_version"`
+	Os         string `json:"os"`
+	Arch       string `json:"arch"`
+	NumCpu     int    `json:"num_cpu"`
+	Gomaxprocs int    `json:"gomaxprocs"`
+	Goroutines int    `json:"goroutines"`
+	HeapAlloc  uint64 `json:"heap_alloc"` // bytes
+	Sys        uint64 `json:"sys"`        // bytes obtained from the os
+	NumGC      uint32 `json:"num_gc"`
+	GcPause    uint64 `json:"gc_pause"`  // ns of total gc pauses
+	CpuUsage   int64  `json:"cpu_usage"` // max cpu usage(%) of the runs
+	Workers    int    `json:"workers"`   // distributed workers of -W
+}
+
+// checkBundlePath -bundle is a gzipped tar archive
+func checkBundlePath(path string) error {
+	if !strings.HasSuffix(path, ".tar.gz") && !strings.HasSuffix(path, ".tgz") {
+		return fmt.Errorf("invalid -bundle %q, expect a .tar.gz or .tgz file", path)
+	}
+	return nil
+}
+
+// newRunBundle start the archive, the command line and input files are packaged before the run
+// as the files may change while running
+func newRunBundle(path string, args []string) *runBundle {
+	b := &runBundle{path: path, start: time.Now()}
+	b.manifest.Command = redactArgs(args)
+	b.manifest.StartTime = b.start.Format(time.RFC3339)
+
+	flag.Visit(func(f *flag.Flag) {
+		for _, name := range bundleFileFlags {
+			if f.Name == name && f.Value.String() != "" {
+				b.addInput(name, f.Value.String())
+			}
+		}
+	})
+	if len(args) > 1 && args[1] == "run" {
+		b.addInput("project", projectScenario)
+		b.addInput("project", projectAssertions)
+	}
+	return b
+}
+
+// addInput package input file and its hash, missing files are recorded without hash
+func (b *runBundle) addInput(flagName, path string) {
+	input := bundleInput{Flag: flagName, Path: path}
+	if data, err := os.ReadFile(path); err == nil {
+		sum := sha256.Sum256(data)
+		input.Entry = fmt.Sprintf("inputs/%s-%s", flagName, filepath.Base(path))
+		input.Size, input.Sha256 = int64(len(data)), hex.EncodeToString(sum[:])
+		b.entries = append(b.entries, bundleEntry{name: input.Entry, data: data})
+	}
+	b.manifest.Inputs = append(b.manifest.Inputs, input)
+}
+
+// redactArgs hide values of secret flags in the command line
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+	for i := 1; i < len(redacted); i++ {
+		name := strings.TrimLeft(redacted[i], "-")
+		if !strings.HasPrefix(redacted[i], "-") || name == "" {
+			continue
+		}
+		if key, _, ok := strings.Cut(name, "="); ok {
+			if bundleSecretFlags[key] {
+				redacted[i] = redacted[i][:len(redacted[i])-len(name)] + key + "=" + bundleRedacted
+			}
+		} else if bundleSecretFlags[name] && i+1 < len(redacted) {
+			i++
+			redacted[i] = bundleRedacted
+		}
+	}
+	return redacted
+}
+
+// redactParams hide credentials of the parameters of the run
+func redactParams(params StressParameters) StressParameters {
+	if len(params.Headers) > 0 {
+		headers := http.Header(params.Headers).Clone()
+		for _, key := range []string{"Authorization", "Proxy-Authorization"} {
+			if len(headers[key]) > 0 {
+				headers[key] = []string{bundleRedacted}
+			}
+		}
+		params.Headers = headers
+	}
+	if params.SignHmac != nil {
+		sign := *params.SignHmac
+		sign.Key = bundleRedacted
+		params.SignHmac = &sign
+	}
+	return params
+}
+
+// addRun package the parameters and the results of url in all output types
+func (b *runBundle) addRun(params StressParameters, result *StressResult) {
+	run := bundleRun{Url: params.Url, SequenceId: params.SequenceId, Dir: fmt.Sprintf("runs/%d", len(b.manifest.Runs)+1)}
+	b.manifest.Runs = append(b.manifest.Runs, run)
+	if result.CpuUsage > b.manifest.Generator.CpuUsage {
+		b.manifest.Generator.CpuUsage = result.CpuUsage
+	}
+
+	paramsJson, _ := json.MarshalIndent(redactParams(params), "", "  ")
+	b.entries = append(b.entries, bundleEntry{name: run.Dir + "/params.json", data: paramsJson})
+
+	resultRdMutex.RLock()
+	defer resultRdMutex.RUnlock()
+	for _, output := range []struct{ name, output string }{
+		{"summary.txt", ""},
+		{"result.json", outputJson},
+		{"latencies.csv", outputCsv},
+		{"latencies-over-time.csv", outputLatsOverTime},
+		{"report.md", outputMarkdown},
+		{"report.html", outputHtml},
+		{"output.txt", outputTemplate},
+	} {
+		if output.output == outputTemplate && result.OutputTemplate == "" {
+			continue
+		}
+		var buf bytes.Buffer
+		result.write(&buf, output.output)
+		b.entries = append(b.entries, bundleEntry{name: run.Dir + "/" + output.name, data: buf.Bytes()})
+	}
+}
+
+// addFile package extra file of the run, e.g. the slo matrix
+func (b *runBundle) addFile(name string, data []byte) {
+	b.entries = append(b.entries, bundleEntry{name: name, data: data})
+}
+
+// write the archive with the manifest and generator telemetry at the end of the run
+func (b *runBundle) write(exitCode int) error {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	g := &b.manifest.Generator
+	g.Hostname, _ = os.Hostname()
+	g.GoVersion, g.Os, g.Arch = runtime.Version(), runtime.GOOS, runtime.GOARCH
+	g.NumCpu, g.Gomaxprocs, g.Goroutines = runtime.NumCPU(), runtime.GOMAXPROCS(-1), runtime.NumGoroutine()
+	g.HeapAlloc, g.Sys, g.NumGC, g.GcPause = mem.HeapAlloc, mem.Sys, mem.NumGC, mem.PauseTotalNs
+	g.Workers = len(workerList)
+	b.manifest.FinishTime = time.Now().Format(time.RFC3339)
+	b.manifest.ExitCode = exitCode
+	manifest, err := json.MarshalIndent(b.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(b.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	prefix := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(b.path), ".tgz"), ".tar.gz") + "/"
+	for _, entry := range append([]bundleEntry{{name: "manifest.json", data: manifest}}, b.entries...) {
+		hdr := &tar.Header{Name: prefix + entry.name, Mode: 0644, Size: int64(len(entry.data)), ModTime: time.Now()}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(entry.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedactArgs(t *testing.T) {
+	args := []string{"http_bench", "-a", "user:pass", "--sign-hmac=key=k", "-H", "a: b", "-listen-auth", "x:y", "-c", "1"}
+	expect := []string{"http_bench", "-a", "xxxxx", "--sign-hmac=xxxxx", "-H", "a: b", "-listen-auth", "xxxxx", "-c", "1"}
+	if v := redactArgs(args); !reflect.DeepEqual(v, expect) {
+		t.Errorf("redactArgs = %v, expect: %v", v, expect)
+	}
+	if args[2] != "user:pass" {
+		t.Errorf("redactArgs changed the args: %v", args)
+	}
+
+	params := StressParameters{
+		Headers:  map[string][]string{"Authorization": {"Bearer t"}, "Accept": {"*/*"}},
+		SignHmac: &hmacSign{Key: "secret"},
+	}
+	redacted := redactParams(params)
+	if redacted.Headers["Authorization"][0] != "xxxxx" || redacted.Headers["Accept"][0] != "*/*" || redacted.SignHmac.Key != "xxxxx" {
+		t.Errorf("redactParams = %+v", redacted)
+	}
+	if params.Headers["Authorization"][0] != "Bearer t" || params.SignHmac.Key != "secret" {
+		t.Errorf("redactParams changed the params: %+v", params)
+	}
+}
+
+func TestRunBundle(t *testing.T) {
+	dir := t.TempDir()
+	feed := filepath.Join(dir, "urls.txt")
+	os.WriteFile(feed, []byte("http://127.0.0.1/\n"), 0644)
+
+	b := newRunBundle(filepath.Join(dir, "run-1.tar.gz"), []string{"http_bench", "-url-file", feed})
+	b.addInput("url-file", feed)
+	b.addInput("body-file", filepath.Join(dir, "missing.txt"))
+	result := GetStressResult()
+	result.LatsTotal, result.CpuUsage = 1, 42
+	result.Lats["0.001"] = 1
+	b.addRun(StressParameters{Url: "http://127.0.0.1/", SequenceId: 7}, result)
+	b.addFile("slo.txt", []byte("SLO:\n"))
+	if err := b.write(3); err != nil {
+		t.Fatalf("write bundle err: %v", err)
+	}
+
+	f, err := os.Open(b.path)
+	if err != nil {
+		t.Fatalf("open bundle err: %v", err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip err: %v", err)
+	}
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("tar err: %v", err)
+		}
+		entries[hdr.Name], _ = io.ReadAll(tr)
+	}
+	for _, name := range []string{"manifest.json", "inputs/url-file-urls.txt", "slo.txt", "runs/1/params.json",
+		"runs/1/summary.txt", "runs/1/result.json", "runs/1/latencies.csv", "runs/1/latencies-over-time.csv",
+		"runs/1/report.md", "runs/1/report.html"} {
+		if _, ok := entries["run-1/"+name]; !ok {
+			t.Errorf("bundle expect entry %s", name)
+		}
+	}
+	if _, ok := entries["run-1/runs/1/output.txt"]; ok {
+		t.Errorf("bundle without output template has output.txt")
+	}
+	if !strings.Contains(string(entries["run-1/runs/1/summary.txt"]), "Summary:") {
+		t.Errorf("summary = %q", entries["run-1/runs/1/summary.txt"])
+	}
+
+	var manifest bundleManifest
+	if err := json.Unmarshal(entries["run-1/manifest.json"], &manifest); err != nil {
+		t.Fatalf("manifest err: %v", err)
+	}
+	if len(manifest.Inputs) != 2 || manifest.Inputs[0].Sha256 == "" || manifest.Inputs[0].Size != 18 || manifest.Inputs[1].Sha256 != "" {
+		t.Errorf("manifest inputs = %+v", manifest.Inputs)
+	}
+	if len(manifest.Runs) != 1 || manifest.Runs[0].SequenceId != 7 || manifest.ExitCode != 3 ||
+		manifest.Generator.CpuUsage != 42 || manifest.Generator.GoVersion == "" {
+		t.Errorf("manifest = %+v", manifest)
+	}
+
+	if err := checkBundlePath("run.zip"); err == nil {
+		t.Errorf("checkBundlePath(run.zip) expect err")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const clockProbes = 3 // round trips to estimate clock offset of worker, the fastest is used
+
+var workerClocks sync.Map // clock offsets of workers by address, estimated before load starts
+
+// StressClock clock offset of worker to the controller, estimated by round trips before load starts,
+// time series and rate curve of worker are shifted by the offset to the clock of the controller
+type StressClock struct {
+	Worker string `json:"worker"`
+	Time   int64  `json:"time,omitempty"` // unix ns of worker replied the probe
+	Offset int64  `json:"offset"`         // ms the clock of worker is ahead of the controller
+	Rtt    int64  `json:"rtt"`            // ms of the round trip, the error of offset is at most half
+}
+
+// replyClock reply the probe of controller with the clock of worker
+func replyClock() *StressResult {
+	result := GetStressResult()
+	result.Clocks = []StressClock{{Time: time.Now().UnixNano()}}
+	return result
+}
+
+// probeClock estimate clock offset of worker by the round trip with the fastest reply, the worker
+// is assumed to reply in the middle of the round trip
+func probeClock(worker string) *StressClock {
+	body, _ := json.Marshal(StressParameters{Cmd: cmdClock})
+	var (
+		clock   *StressClock
+		fastest time.Duration
+	)
+	for i := 0; i < clockProbes; i++ {
+		sent := time.Now()
+		result, err := executeWorkerReq(workerUrl(worker, httpWorkerApiPath), body)
+		rtt := time.Since(sent)
+		if err != nil || len(result.Clocks) <= 0 || result.Clocks[0].Time <= 0 {
+			break // unreachable or worker of old version
+		}
+		if clock == nil || rtt < fastest {
+			offset := time.Unix(0, result.Clocks[0].Time).Sub(sent.Add(rtt / 2))
+			clock = &StressClock{Worker: redactWorker(worker), Offset: offset.Round(time.Millisecond).Milliseconds(), Rtt: rtt.Milliseconds()}
+			fastest = rtt
+		}
+	}
+	if clock != nil {
+		workerClocks.Store(worker, clock)
+	} else {
+		workerClocks.Delete(worker)
+	}
+	verbosePrint(vDEBUG, "clock of worker %s: %+v", worker, clock)
+	return clock
+}
+
+// adjustClock shift times of worker to the clock of the controller, annotations are forwarded
+// with the time of the controller and are not shifted
+func (result *StressResult) adjustClock(clock *StressClock) {
+	result.Clocks = append(result.Clocks, StressClock{Worker: clock.Worker, Offset: clock.Offset, Rtt: clock.Rtt})
+	if clock.Offset == 0 {
+		return
+	}
+	secs := int64(math.Round(float64(clock.Offset) / 1000))
+	if secs != 0 && len(result.TimeSeries) > 0 {
+		series := make(map[int64]*StressPoint, len(result.TimeSeries))
+		for sec, p := range result.TimeSeries {
+			series[sec-secs] = p
+		}
+		result.TimeSeries = series
+	}
+	for i := range result.RateCurve {
+		result.RateCurve[i].Time -= clock.Offset
+	}
+}
+
+// printClocks Print clock offsets of workers to the controller
+func (result *StressResult) printClocks(w io.Writer) {
+	clocks := append([]StressClock(nil), result.Clocks...)
+	sort.Slice(clocks, func(i, j int) bool { return clocks[i].Worker < clocks[j].Worker })
+	fprintln(w, "\nWorker clocks (offset to controller):")
+	for _, c := range clocks {
+		fprintln(w, "  [%s]\t%+dms (±%dms)", c.Worker, c.Offset, (c.Rtt+1)/2)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbeClock(t *testing.T) {
+	// worker with the clock 5s ahead of the controller
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := replyClock()
+		result.Clocks[0].Time += int64(5 * time.Second)
+		body, _ := json.Marshal(result)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	clock := probeClock(srv.URL)
+	if clock == nil {
+		t.Fatalf("probeClock expect clock of worker")
+	}
+	if clock.Offset < 4999-clock.Rtt || clock.Offset > 5001+clock.Rtt {
+		t.Errorf("offset = %dms (rtt %dms), expect 5000ms", clock.Offset, clock.Rtt)
+	}
+	if v, ok := workerClocks.Load(srv.URL); !ok || v.(*StressClock) != clock {
+		t.Errorf("clock of worker is not stored")
+	}
+
+	// worker of old version without the clock probe
+	old := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer old.Close()
+	if clock := probeClock(old.URL); clock != nil {
+		t.Errorf("probeClock of old worker = %+v, expect nil", clock)
+	}
+}
+
+func TestAdjustClock(t *testing.T) {
+	result := GetStressResult()
+	result.TimeSeries[100] = &StressPoint{LatsTotal: 1}
+	result.TimeSeries[101] = &StressPoint{LatsTotal: 2}
+	result.RateCurve = []StressRate{{Time: 100500, Rps: 10}}
+	result.Annotations = []StressAnnotation{{Time: 100200, Msg: "deploy"}}
+
+	result.adjustClock(&StressClock{Worker: "w1", Offset: 2600, Rtt: 4})
+	if result.TimeSeries[97] == nil || result.TimeSeries[97].LatsTotal != 1 || result.TimeSeries[98].LatsTotal != 2 {
+		t.Errorf("time series = %v, expect shifted by 3s", result.TimeSeries)
+	}
+	if result.RateCurve[0].Time != 97900 {
+		t.Errorf("rate curve time = %d, expect 97900", result.RateCurve[0].Time)
+	}
+	if result.Annotations[0].Time != 100200 {
+		t.Errorf("annotation time = %d, expect not shifted", result.Annotations[0].Time)
+	}
+
+	merged := calMutliStressResult(nil, *result, StressResult{Clocks: []StressClock{{Worker: "w0", Offset: -3}}})
+	var buf bytes.Buffer
+	merged.printClocks(&buf)
+	if out := buf.String(); !strings.Contains(out, "[w0]\t-3ms (±0ms)") || !strings.Contains(out, "[w1]\t+2600ms (±2ms)") ||
+		strings.Index(out, "w0") > strings.Index(out, "w1") {
+		t.Errorf("printClocks = %q", out)
+	}
+}
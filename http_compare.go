@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// compareThresholds regressions failing "http_bench compare", negative is unchecked
+type compareThresholds struct {
+	Latency float64 // max increase of latency percentiles and average in percent
+	Rps     float64 // max decrease of requests/sec in percent
+	ErrRate float64 // max increase of error rate in percentage points
+}
+
+// compareRow metric of the new result against the baseline
+type compareRow struct {
+	name      string
+	baseline  float64
+	value     float64
+	format    string
+	change    string
+	regressed string // the threshold exceeded, empty if not regressed
+}
+
+// parseThreshold parse "10%" or "10" of the compare thresholds, empty is unchecked
+func parseThreshold(name, v string) (float64, error) {
+	if v == "" {
+		return -1, nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid -%s: %s, expect a percentage, e.g. 10%%", name, v)
+	}
+	return f, nil
+}
+
+// loadResultFile load the result of "-o json"
+func loadResultFile(file string) (*StressResult, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s file read error(%v)", file, err)
+	}
+	var result StressResult
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, fmt.Errorf("invalid result %s: %v", file, err)
+	}
+	return &result, nil
+}
+
+// compareResults deltas of rps, latency and error rate of result against baseline, percentiles of
+// statistically invalid results are skipped as in the markdown output
+func compareResults(baseline, result *StressResult, th compareThresholds) []compareRow {
+	latency := func(r *StressResult, p int) float64 {
+		if p == 0 {
+			return float64(r.Average) / scaleNum
+		}
+		return r.Percentile(p)
+	}
+	rows := []compareRow{{name: "Requests/sec", baseline: float64(baseline.Rps) / scaleNum,
+		value: float64(result.Rps) / scaleNum, format: "%.1f"}}
+	if result.Invalid == "" && baseline.Invalid == "" {
+		for _, p := range []int{0, 50, 90, 95, 99} {
+			name := fmt.Sprintf("p%d", p)
+			if p == 0 {
+				name = "Average"
+			}
+			rows = append(rows, compareRow{name: name, baseline: latency(baseline, p), value: latency(result, p),
+				format: "%4.3f secs"})
+		}
+	}
+	rows = append(rows, compareRow{name: "Error rate", baseline: baseline.ErrRate() * 100, value: result.ErrRate() * 100,
+		format: "%.2f%%"})
+
+	for i := range rows {
+		row := &rows[i]
+		if row.name == "Error rate" {
+			row.change = fmt.Sprintf("%+.2fpp", row.value-row.baseline)
+			if th.ErrRate >= 0 && row.value-row.baseline > th.ErrRate {
+				row.regressed = fmt.Sprintf("increase > %gpp", th.ErrRate)
+			}
+			continue
+		}
+		if row.baseline == 0 {
+			row.change = "-"
+			continue
+		}
+		change := (row.value - row.baseline) * 100 / row.baseline
+		row.change = fmt.Sprintf("%+.1f%%", change)
+		switch {
+		case row.name == "Requests/sec" && th.Rps >= 0 && -change > th.Rps:
+			row.regressed = fmt.Sprintf("decrease > %g%%", th.Rps)
+		case row.name != "Requests/sec" && th.Latency >= 0 && change > th.Latency:
+			row.regressed = fmt.Sprintf("increase > %g%%", th.Latency)
+		}
+	}
+	return rows
+}
+
+// printCompare print the rows as text, or as markdown table for pull request comments
+func printCompare(w io.Writer, rows []compareRow, markdown bool) {
+	if markdown {
+		fmt.Fprintln(w, "| Metric | Baseline | Value | Change | |")
+		fmt.Fprintln(w, "|---|---:|---:|---:|---|")
+		for _, row := range rows {
+			mark := ""
+			if row.regressed != "" {
+				mark = "regression, " + row.regressed
+			}
+			fmt.Fprintf(w, "| %s | "+row.format+" | "+row.format+" | %s | %s |\n", row.name, row.baseline, row.value,
+				row.change, mark)
+		}
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  Metric\tBaseline\tValue\tChange\t")
+	for _, row := range rows {
+		mark := ""
+		if row.regressed != "" {
+			mark = "REGRESSION, " + row.regressed
+		}
+		fmt.Fprintf(tw, "  %s\t"+row.format+"\t"+row.format+"\t%s\t%s\n", row.name, row.baseline, row.value, row.change, mark)
+	}
+	tw.Flush()
+}
+
+// runCompare "http_bench compare [options] old.json new.json" print the deltas of the results of
+// "-o json", exit code is exitAssertion if a threshold is exceeded
+func runCompare(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	latency := fs.String("max-latency-increase", "", "")
+	rps := fs.String("max-rps-decrease", "", "")
+	errRate := fs.String("max-error-increase", "", "")
+	output := fs.String("o", "", "")
+
+	// options can be after the files
+	var files []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			usageAndExit("compare: " + err.Error())
+		}
+		if fs.NArg() <= 0 {
+			break
+		}
+		files, args = append(files, fs.Arg(0)), fs.Args()[1:]
+	}
+	if len(files) != 2 {
+		usageAndExit("compare requires the baseline and new result files of \"-o json\".")
+	}
+	if *output != "" && *output != outputMarkdown {
+		usageAndExit("invalid compare -o: " + *output + ", expect markdown.")
+	}
+
+	var th compareThresholds
+	var err error
+	if th.Latency, err = parseThreshold("max-latency-increase", *latency); err != nil {
+		usageAndExit(err.Error())
+	}
+	if th.Rps, err = parseThreshold("max-rps-decrease", *rps); err != nil {
+		usageAndExit(err.Error())
+	}
+	if th.ErrRate, err = parseThreshold("max-error-increase", *errRate); err != nil {
+		usageAndExit(err.Error())
+	}
+	baseline, err := loadResultFile(files[0])
+	if err != nil {
+		usageAndExit(err.Error())
+	}
+	result, err := loadResultFile(files[1])
+	if err != nil {
+		usageAndExit(err.Error())
+	}
+
+	rows := compareResults(baseline, result, th)
+	if *output != outputMarkdown {
+		println("Comparison of %s with baseline %s:", files[1], files[0])
+	}
+	printCompare(os.Stdout, rows, *output == outputMarkdown)
+	for i, r := range []*StressResult{baseline, result} {
+		if r.Invalid != "" {
+			println("\nLatency is not compared, %s is statistically invalid: %s", files[i], r.Invalid)
+		}
+	}
+
+	var regressions []string
+	for _, row := range rows {
+		if row.regressed != "" {
+			regressions = append(regressions, fmt.Sprintf("%s %s (%s)", row.name, row.change, row.regressed))
+		}
+	}
+	if len(regressions) > 0 {
+		eprintln("Regression: %s", strings.Join(regressions, ", "))
+		return exitAssertion
+	}
+	return exitOK
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompareResults(t *testing.T) {
+	baseline := GetStressResult()
+	baseline.LatsTotal, baseline.Rps, baseline.Average = 100, 1000*scaleNum, 0.010*scaleNum
+	baseline.Lats = map[string]int64{"0.010": 100}
+	result := GetStressResult()
+	result.LatsTotal, result.Rps, result.Average = 98, 850*scaleNum, 0.011*scaleNum
+	result.Lats = map[string]int64{"0.010": 90, "0.020": 8}
+	result.ErrorDist["timeout"] = 2
+
+	unchecked := compareThresholds{Latency: -1, Rps: -1, ErrRate: -1}
+	for _, row := range compareResults(baseline, result, unchecked) {
+		if row.regressed != "" {
+			t.Errorf("unchecked %s regressed: %s", row.name, row.regressed)
+		}
+	}
+
+	rows := compareResults(baseline, result, compareThresholds{Latency: 50, Rps: 10, ErrRate: 1})
+	regressed := make(map[string]string)
+	for _, row := range rows {
+		if row.regressed != "" {
+			regressed[row.name] = row.change + " " + row.regressed
+		}
+	}
+	// p95 and p99 double, p50, p90 and the average are within 50%
+	expect := map[string]string{
+		"Requests/sec": "-15.0% decrease > 10%",
+		"p95":          "+100.0% increase > 50%",
+		"p99":          "+100.0% increase > 50%",
+		"Error rate":   "+2.00pp increase > 1pp",
+	}
+	if len(regressed) != len(expect) {
+		t.Errorf("regressed = %v, expect %v", regressed, expect)
+	}
+	for name, v := range expect {
+		if regressed[name] != v {
+			t.Errorf("%s regressed = %q, expect %q", name, regressed[name], v)
+		}
+	}
+
+	var buf bytes.Buffer
+	printCompare(&buf, rows, false)
+	for _, line := range []string{"Metric", "Requests/sec  1000.0", "850.0", "-15.0%", "REGRESSION, decrease > 10%"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printCompare expect %q, got:\n%s", line, buf.String())
+		}
+	}
+	buf.Reset()
+	printCompare(&buf, rows, true)
+	if !strings.Contains(buf.String(), "| p99 | 0.010 secs | 0.020 secs | +100.0% | regression, increase > 50% |") {
+		t.Errorf("printCompare markdown:\n%s", buf.String())
+	}
+
+	// percentiles of too few samples are not compared
+	result.Invalid = "98 samples"
+	for _, row := range compareResults(baseline, result, unchecked) {
+		if strings.HasPrefix(row.name, "p") || row.name == "Average" {
+			t.Errorf("latency %s compared of invalid result", row.name)
+		}
+	}
+
+	for _, tt := range []struct {
+		v      string
+		expect float64
+		err    bool
+	}{{"", -1, false}, {"10%", 10, false}, {"2.5", 2.5, false}, {"-1%", 0, true}, {"ten", 0, true}} {
+		if v, err := parseThreshold("max-rps-decrease", tt.v); v != tt.expect || (err != nil) != tt.err {
+			t.Errorf("parseThreshold(%q) = %v, %v", tt.v, v, err)
+		}
+	}
+}
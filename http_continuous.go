@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+const defaultWindow = time.Minute // rolling window of continuous mode
+
+// isContinuous -d 0 runs until stopped, and reports and resets the result per window
+func (b *StressWorker) isContinuous() bool {
+	return b.RequestParams.Duration == 0
+}
+
+func (b *StressWorker) window() time.Duration {
+	if b.RequestParams.Window > 0 {
+		return time.Duration(b.RequestParams.Window) * time.Millisecond
+	}
+	return defaultWindow
+}
+
+// rollWindow start a new window with empty result, and return the result of the finished window
+func (b *StressWorker) rollWindow(now time.Time) *StressResult {
+	resultRdMutex.Lock()
+	window, start := b.curResult, b.windowStart
+	window.Duration = int64(now.Sub(start).Seconds())
+	b.finishResult(window)
+	b.curResult, b.windowStart, b.rateCurve = GetStressResult(), now, nil
+	b.curResult.digits = window.digits
+	resultRdMutex.Unlock()
+	window.Diagnosis = b.diagnose(window)
+
+	result := calMutliStressResult(nil, *window)
+	result.Output, result.OutputTemplate = b.RequestParams.Output, b.RequestParams.OutputTemplate
+	return result
+}
+
+// printWindow Print result of the finished window, the header goes to stderr to keep json lines parseable
+func (b *StressWorker) printWindow(now time.Time) {
+	start := b.windowStart
+	result := b.rollWindow(now)
+	eprintln("\nWindow [%s, %s]:", start.Format("15:04:05"), now.Format("15:04:05"))
+	resultRdMutex.RLock()
+	result.write(os.Stdout, result.Output)
+	resultRdMutex.RUnlock()
+}
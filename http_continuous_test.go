@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRollWindow(t *testing.T) {
+	start := time.Now()
+	b := &StressWorker{RequestParams: &StressParameters{Output: outputJson}, curResult: GetStressResult(), windowStart: start}
+	if !b.isContinuous() || b.window() != defaultWindow {
+		t.Errorf("isContinuous = %v, window = %s, expect continuous with %s", b.isContinuous(), b.window(), defaultWindow)
+	}
+
+	b.curResult.append(&result{statusCode: 200, duration: 10 * time.Millisecond, start: start})
+	b.curResult.append(&result{statusCode: 200, duration: 20 * time.Millisecond, start: start})
+	window := b.rollWindow(start.Add(2 * time.Second))
+	if window.LatsTotal != 2 || window.Duration != 2 || window.Rps != scaleNum || window.Output != outputJson {
+		t.Errorf("rollWindow = %d requests in %ds, %d rps, output %q", window.LatsTotal, window.Duration, window.Rps, window.Output)
+	}
+	if b.curResult.LatsTotal != 0 || len(b.curResult.Lats) != 0 || !b.windowStart.Equal(start.Add(2*time.Second)) {
+		t.Errorf("rollWindow not reset, %d requests from %s", b.curResult.LatsTotal, b.windowStart)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+)
+
+// StressCookies sessions of -enable-cookies, each connection is a virtual user with its own cookie jar
+type StressCookies struct {
+	Requests   int64 `json:"requests"`    // requests sent with a cookie jar
+	Sent       int64 `json:"sent"`        // requests sent with cookies
+	SetCookies int64 `json:"set_cookies"` // responses with Set-Cookie
+	Sessions   int64 `json:"sessions"`    // connections, or iterations of -flow, which received a cookie
+}
+
+// newCookieJar cookie jar of a virtual user, the cookies of Set-Cookie are sent by the following requests
+// of the connection
+func newCookieJar() http.CookieJar {
+	jar, _ := cookiejar.New(nil) // never fails without options
+	return jar
+}
+
+// withCookies copy the header of req, the jar adds the cookies to the header of the request in place
+func withCookies(req *http.Request) {
+	if req.Header = req.Header.Clone(); req.Header == nil {
+		req.Header = make(http.Header)
+	}
+}
+
+// trackCookies count the cookies sent by req and set by resp in the session of the connection, resp is
+// nil if the request failed
+func (client *StressClient) trackCookies(req *http.Request, resp *http.Response, res *result) {
+	res.cookies = true
+	res.cookieSent = req.Header.Get("Cookie") != ""
+	if resp != nil && len(resp.Header.Values("Set-Cookie")) > 0 {
+		res.cookieSet = true
+		res.newSession = !client.session
+		client.session = true
+	}
+}
+
+// resetCookies start a new session of the virtual user with an empty jar
+func (client *StressClient) resetCookies() {
+	client.httpClient.Jar = newCookieJar()
+	client.session = false
+}
+
+func (c *StressCookies) append(res *result) {
+	c.Requests++
+	if res.cookieSent {
+		c.Sent++
+	}
+	if res.cookieSet {
+		c.SetCookies++
+	}
+	if res.newSession {
+		c.Sessions++
+	}
+}
+
+func (c *StressCookies) merge(v *StressCookies) {
+	c.Requests += v.Requests
+	c.Sent += v.Sent
+	c.SetCookies += v.SetCookies
+	c.Sessions += v.Sessions
+}
+
+// printCookies Print sessions and cookies of -enable-cookies
+func (result *StressResult) printCookies(w io.Writer) {
+	c := result.Cookies
+	fprintln(w, "\nCookies:")
+	fprintln(w, "  Sessions:\t%d", c.Sessions)
+	fprintln(w, "  Set-Cookie:\t%d responses", c.SetCookies)
+	fprintln(w, "  Sent:\t%d requests (%.2f%%) with cookies", c.Sent, float64(c.Sent)*100/float64(c.Requests))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStressCookies(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		next     int64
+		sessions = make(map[string]int) // requests of each session after its cookie was set
+		bad      []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("session")
+		if err != nil {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: fmt.Sprint(atomic.AddInt64(&next, 1)), Path: "/"})
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		sessions[c.Value]++
+		if len(r.Cookies()) != 1 {
+			bad = append(bad, r.Header.Get("Cookie"))
+		}
+	}))
+	defer srv.Close()
+
+	for _, enable := range []bool{true, false} {
+		atomic.StoreInt64(&next, 0)
+		mu.Lock()
+		sessions, bad = make(map[string]int), nil
+		mu.Unlock()
+		_, result := executeStress(StressParameters{
+			SequenceId:    time.Now().UnixNano(),
+			Cmd:           cmdStart,
+			RequestType:   typeHttp1,
+			RequestMethod: "GET",
+			Url:           srv.URL,
+			Headers:       map[string][]string{"X-Test": {"1"}},
+			EnableCookies: enable,
+			C:             3,
+			N:             60,
+			Timeout:       3000,
+		})
+		if result == nil || result.ErrTotal() > 0 {
+			t.Fatalf("result of cookies %v = %+v", enable, result)
+		}
+		mu.Lock()
+		if !enable {
+			if len(sessions) > 0 || result.Cookies != nil || atomic.LoadInt64(&next) < 60 {
+				t.Errorf("sessions without cookies = %v, set %d, result %+v", sessions, next, result.Cookies)
+			}
+			mu.Unlock()
+			continue
+		}
+		// each connection keeps the session of its first response
+		if len(bad) > 0 || len(sessions) != 3 || atomic.LoadInt64(&next) != 3 {
+			t.Errorf("sessions = %v, set %d, bad: %v", sessions, next, bad)
+		}
+		mu.Unlock()
+		if c := result.Cookies; c == nil || c.Sessions != 3 || c.SetCookies != 3 || c.Requests != result.LatsTotal ||
+			c.Sent != c.Requests-3 {
+			t.Errorf("cookies = %+v, requests %d", result.Cookies, result.LatsTotal)
+		}
+	}
+
+	var buf bytes.Buffer
+	(&StressResult{Cookies: &StressCookies{Requests: 10, Sent: 8, SetCookies: 2, Sessions: 2}}).printCookies(&buf)
+	for _, line := range []string{"Cookies:", "  Sessions:\t2", "  Set-Cookie:\t2 responses", "  Sent:\t8 requests (80.00%) with cookies"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printCookies expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	liveInterval     = 2 * time.Second        // default interval of the live frames
+	liveMinInterval  = 100 * time.Millisecond // shortest interval of "?interval=" accepted
+	liveStartTimeout = 10 * time.Second       // wait for the stress test to start before closing
+)
+
+// the api accepts any origin, so does the live stream
+var liveUpgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+// StressLiveFrame metrics of an interval streamed to the dashboard
+type StressLiveFrame struct {
+	Time        int64              `json:"time"`    // unix ms
+	Running     bool               `json:"running"` // false in the last frame of the finished stress test
+	Qps         float64            `json:"qps"`     // responses and failures per second
+	P50         float64            `json:"p50"`     // latency percentiles(ms) of the interval
+	P90         float64            `json:"p90"`
+	P99         float64            `json:"p99"`
+	ErrorRate   float64            `json:"error_rate"`  // failures of the requests, 0 to 1
+	StatusCode  map[string]float64 `json:"status_code"` // responses per second of each status code
+	LatsTotal   int64              `json:"lats_total"`  // responses since the start
+	ErrTotal    int64              `json:"err_total"`   // failures since the start
+	Annotations []StressAnnotation `json:"annotations"` // events of the stress test since the start
+	Workers     []StressLiveWorker `json:"workers,omitempty"`
+}
+
+// StressLiveWorker interval metrics of a worker of the controller
+type StressLiveWorker struct {
+	Worker string  `json:"worker"`
+	Qps    float64 `json:"qps"`
+	P99    float64 `json:"p99"` // ms, 0 without responses
+}
+
+// liveFeed turn the cumulative metrics of a stress test into frames of intervals
+type liveFeed struct {
+	prev     *StressResult
+	prevTime time.Time
+	workers  map[string]*StressResult
+}
+
+func newLiveFeed(start time.Time) *liveFeed {
+	return &liveFeed{prev: GetStressResult(), prevTime: start, workers: make(map[string]*StressResult)}
+}
+
+// next frame of the metrics cur at now
+func (f *liveFeed) next(cur *StressResult, now time.Time, running bool) StressLiveFrame {
+	secs := now.Sub(f.prevTime).Seconds()
+	if secs <= 0 {
+		secs = liveInterval.Seconds()
+	}
+	prev := f.prev
+	if cur.LatsTotal < prev.LatsTotal || cur.ErrTotal() < prev.ErrTotal() {
+		prev = GetStressResult()
+	}
+
+	requests, failed, lats := intervalDelta(prev, cur)
+	frame := StressLiveFrame{
+		Time:        now.UnixMilli(),
+		Running:     running,
+		Qps:         float64(requests+failed) / secs,
+		StatusCode:  make(map[string]float64, len(cur.StatusCodeDist)),
+		LatsTotal:   cur.LatsTotal,
+		ErrTotal:    cur.ErrTotal(),
+		Annotations: cur.Annotations,
+	}
+	if requests > 0 {
+		data := latsPercentiles(lats, requests, []int{50, 90, 99})
+		frame.P50, frame.P90, frame.P99 = data[0]*1000, data[1]*1000, data[2]*1000
+	}
+	if requests+failed > 0 {
+		frame.ErrorRate = float64(failed) / float64(requests+failed)
+	}
+	for code, c := range cur.StatusCodeDist {
+		frame.StatusCode[strconv.Itoa(code)] = float64(c-prev.StatusCodeDist[code]) / secs
+	}
+
+	for i := range cur.WorkerMetrics {
+		m := &cur.WorkerMetrics[i]
+		workerPrev, ok := f.workers[m.Worker]
+		if !ok {
+			workerPrev = GetStressResult()
+		}
+		workerCur := m.result()
+		requests, failed, lats := intervalDelta(workerPrev, workerCur)
+		worker := StressLiveWorker{Worker: m.Worker, Qps: float64(requests+failed) / secs}
+		if requests > 0 {
+			worker.P99 = latsPercentiles(lats, requests, []int{99})[0] * 1000
+		}
+		frame.Workers = append(frame.Workers, worker)
+		f.workers[m.Worker] = workerCur
+	}
+
+	f.prev, f.prevTime = cur, now
+	return frame
+}
+
+// serveLive GET /api/live?sequence_id=N&interval=ms upgrade to websocket and stream a frame of the
+// stress test every interval until it finishes, the stream waits for the stress test to start
+func serveLive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	seqId, err := strconv.ParseInt(r.URL.Query().Get("sequence_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid sequence_id", http.StatusBadRequest)
+		return
+	}
+	every := liveInterval
+	if ms, err := strconv.Atoi(r.URL.Query().Get("interval")); err == nil {
+		if every = time.Duration(ms) * time.Millisecond; every < liveMinInterval {
+			every = liveMinInterval
+		}
+	}
+
+	conn, err := liveUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		verbosePrint(vERROR, "live upgrade err: %v", err) // the upgrader replied the error
+		return
+	}
+	defer conn.Close()
+
+	// read the control frames, and notice the close of the browser
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	finish := func(reason string) {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason),
+			time.Now().Add(every))
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	start, seen := time.Now(), false
+	feed := newLiveFeed(start)
+	for {
+		var now time.Time
+		select {
+		case <-closed:
+			return
+		case now = <-ticker.C:
+		}
+
+		result, running, ok := sharedResult(seqId)
+		if !ok {
+			if seen {
+				finish("finished")
+				return
+			} else if now.Sub(start) > liveStartTimeout {
+				finish(fmt.Sprintf("sequence id %d not found", seqId))
+				return
+			}
+			continue
+		}
+		seen = true
+
+		conn.SetWriteDeadline(now.Add(every))
+		if err := conn.WriteJSON(feed.next(result, now, running)); err != nil {
+			verbosePrint(vDEBUG, "live write err: %v", err)
+			return
+		}
+		if !running {
+			finish("finished")
+			return
+		}
+	}
+}
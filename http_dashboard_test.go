@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestLiveFeed(t *testing.T) {
+	start := time.Now()
+	feed := newLiveFeed(start)
+
+	cur := GetStressResult()
+	cur.LatsTotal, cur.Lats = 100, map[string]int64{"0.010": 90, "0.100": 10}
+	cur.ErrorDist["timeout"] = 25
+	cur.StatusCodeDist[200] = 100
+	cur.WorkerMetrics = []StressWorkerMetrics{{Worker: "10.0.0.1:12710",
+		StressPoint: StressPoint{LatsTotal: 100, ErrTotal: 25, Lats: cur.Lats}}}
+	frame := feed.next(cur, start.Add(time.Second), true)
+	if frame.Qps != 125 || frame.P50 != 10 || frame.P99 != 100 || frame.ErrorRate != 0.2 || frame.StatusCode["200"] != 100 {
+		t.Errorf("first frame = %+v", frame)
+	}
+	if len(frame.Workers) != 1 || frame.Workers[0].Qps != 125 || frame.Workers[0].P99 != 100 {
+		t.Errorf("first frame workers = %+v", frame.Workers)
+	}
+
+	// only the interval since the previous frame is counted
+	next := GetStressResult()
+	next.LatsTotal, next.Lats = 150, map[string]int64{"0.010": 90, "0.100": 60}
+	next.ErrorDist["timeout"] = 25
+	next.StatusCodeDist[200] = 150
+	frame = feed.next(next, start.Add(3*time.Second), false)
+	if frame.Qps != 25 || frame.P50 != 100 || frame.ErrorRate != 0 || frame.StatusCode["200"] != 25 || frame.Running {
+		t.Errorf("second frame = %+v", frame)
+	}
+
+	// the window of continuous mode restarts the counts
+	restart := GetStressResult()
+	restart.LatsTotal, restart.Lats = 10, map[string]int64{"0.001": 10}
+	restart.StatusCodeDist[200] = 10
+	frame = feed.next(restart, start.Add(4*time.Second), true)
+	if frame.Qps != 10 || math.Abs(frame.P99-1) > 1e-9 || frame.StatusCode["200"] != 10 {
+		t.Errorf("restarted frame = %+v", frame)
+	}
+}
+
+func TestServeLive(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	srv := httptest.NewServer(http.HandlerFunc(serveLive))
+	defer srv.Close()
+	wsUrl := "ws" + strings.TrimPrefix(srv.URL, "http") + httpWorkerLivePath
+
+	params := StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           target.URL,
+		C:             1,
+		Qps:           50,
+		Duration:      1,
+		Timeout:       1000,
+	}
+	// the stream waits for the stress test to start
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("%s?interval=200&sequence_id=%d", wsUrl, params.SequenceId), nil)
+	if err != nil {
+		t.Fatalf("dial live err: %v", err)
+	}
+	defer conn.Close()
+	done := make(chan struct{})
+	go func() {
+		executeStress(params)
+		close(done)
+	}()
+	defer func() { <-done }() // the result is stored after the stream closes
+
+	var frames []StressLiveFrame
+	for {
+		var frame StressLiveFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				t.Errorf("read live err: %v", err)
+			}
+			break
+		}
+		frames = append(frames, frame)
+	}
+	if len(frames) < 3 {
+		t.Fatalf("live frames = %+v, expect one every 200ms", frames)
+	}
+	last := frames[len(frames)-1]
+	if last.Running || last.LatsTotal <= 0 || frames[0].Qps <= 0 || frames[0].P99 <= 0 {
+		t.Errorf("first frame %+v, last frame %+v", frames[0], last)
+	}
+
+	if _, resp, err := websocket.DefaultDialer.Dial(wsUrl+"?sequence_id=x", nil); err == nil ||
+		resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("live of invalid sequence id expect 400, err: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	dataSequential = "sequential" // rows in order, starting over after the last one
+	dataRandom     = "random"     // a random row for each request
+)
+
+// parseData read the rows of -data, a .json file is an array of objects, otherwise a csv file with
+// the column names in the first line
+func parseData(fileName string) ([]map[string]string, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")) // utf-8 BOM of spreadsheets
+
+	var rows []map[string]string
+	if strings.EqualFold(filepath.Ext(fileName), ".json") {
+		rows, err = parseJsonData(content)
+	} else {
+		rows, err = parseCsvData(content)
+	}
+	if err == nil && len(rows) <= 0 {
+		err = errors.New("no rows")
+	}
+	return rows, err
+}
+
+func parseCsvData(content []byte) ([]map[string]string, error) {
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) <= 0 {
+		return nil, nil
+	}
+	columns := records[0]
+	for i, name := range columns {
+		if columns[i] = strings.TrimSpace(name); columns[i] == "" {
+			return nil, fmt.Errorf("empty name of column %d", i+1)
+		}
+	}
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(columns))
+		for i, name := range columns {
+			row[name] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseJsonData parse an array of objects, the values which are not strings are kept as json, e.g. 1 or true
+func parseJsonData(content []byte) ([]map[string]string, error) {
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(content, &objects); err != nil {
+		return nil, fmt.Errorf("expect an array of objects: %v", err)
+	}
+	rows := make([]map[string]string, 0, len(objects))
+	for _, object := range objects {
+		row := make(map[string]string, len(object))
+		for name, raw := range object {
+			var s string
+			if json.Unmarshal(raw, &s) == nil {
+				row[name] = s
+			} else {
+				row[name] = string(raw)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// pickRow pick the row of -data for the request, in the partition of the distributed worker, so that
+// rows never collide across machines
+func (b *StressWorker) pickRow(client *StressClient) map[string]string {
+	rows := b.RequestParams.Data
+	if len(rows) <= 0 {
+		return nil
+	}
+	start, end := partitionRange(len(rows), b.RequestParams.WorkerIndex, b.RequestParams.WorkerCount)
+	if b.RequestParams.DataMode == dataRandom {
+		return rows[start+client.random.Intn(end-start)]
+	}
+	i := (atomic.AddUint64(&b.dataNext, 1) - 1) % uint64(end-start)
+	return rows[start+int(i)]
+}
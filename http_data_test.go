@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseData(t *testing.T) {
+	dir := t.TempDir()
+	for _, v := range []struct {
+		name, content string
+		rows          []map[string]string
+		isErr         bool
+	}{
+		{name: "users.csv", content: "\xef\xbb\xbfusername, password\nalice,a1\n\"bob, jr\",b2\n",
+			rows: []map[string]string{{"username": "alice", "password": "a1"}, {"username": "bob, jr", "password": "b2"}}},
+		{name: "users.json", content: `[{"username": "alice", "id": 1, "admin": true}, {"username": "bob", "tags": ["a"]}]`,
+			rows: []map[string]string{{"username": "alice", "id": "1", "admin": "true"}, {"username": "bob", "tags": `["a"]`}}},
+		{name: "short.csv", content: "username,password\nalice\n", isErr: true},
+		{name: "header.csv", content: "username,password\n", isErr: true},
+		{name: "blank.csv", content: "username,\nalice,a1\n", isErr: true},
+		{name: "object.json", content: `{"username": "alice"}`, isErr: true},
+	} {
+		fileName := filepath.Join(dir, v.name)
+		os.WriteFile(fileName, []byte(v.content), 0644)
+		rows, err := parseData(fileName)
+		if (err != nil) != v.isErr {
+			t.Fatalf("parseData(%s) err: %v, expect err: %v", v.name, err, v.isErr)
+		}
+		if len(rows) != len(v.rows) {
+			t.Fatalf("parseData(%s) = %v, expect %v", v.name, rows, v.rows)
+		}
+		for i, row := range rows {
+			for k, value := range v.rows[i] {
+				if row[k] != value || len(row) != len(v.rows[i]) {
+					t.Errorf("row %d of %s = %v, expect %v", i, v.name, row, v.rows[i])
+				}
+			}
+		}
+	}
+}
+
+func TestStressData(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		hits = make(map[string]int)
+		bad  []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		user := r.URL.Query().Get("user")
+		hits[user]++
+		// the url and header of a request use the same row
+		if r.Header.Get("X-Password") != user+"-pw" {
+			bad = append(bad, user+" "+r.Header.Get("X-Password"))
+		}
+	}))
+	defer srv.Close()
+
+	rows := []map[string]string{
+		{"user": "a", "password": "a-pw"}, {"user": "b", "password": "b-pw"},
+		{"user": "c", "password": "c-pw"}, {"user": "d", "password": "d-pw"},
+	}
+	for _, v := range []struct {
+		mode         string
+		index, count int
+		users        []string
+	}{
+		{mode: dataSequential, count: 1, users: []string{"a", "b", "c", "d"}},
+		{mode: dataRandom, count: 1, users: []string{"a", "b", "c", "d"}},
+		{mode: dataSequential, index: 1, count: 2, users: []string{"c", "d"}},
+	} {
+		mu.Lock()
+		hits, bad = make(map[string]int), nil
+		mu.Unlock()
+		_, result := executeStress(StressParameters{
+			SequenceId:    time.Now().UnixNano(),
+			Cmd:           cmdStart,
+			RequestType:   typeHttp1,
+			RequestMethod: "GET",
+			Url:           srv.URL + "/?user={{ .Data.user }}",
+			Headers:       map[string][]string{"X-Password": {"{{ .Data.password }}"}},
+			Data:          rows,
+			DataMode:      v.mode,
+			WorkerIndex:   v.index,
+			WorkerCount:   v.count,
+			C:             2,
+			N:             200,
+			Timeout:       3000,
+		})
+		if result == nil || result.ErrTotal() > 0 {
+			t.Fatalf("result of %s = %+v", v.mode, result)
+		}
+		mu.Lock()
+		if len(bad) > 0 || len(hits) != len(v.users) {
+			t.Errorf("rows of %s worker %d/%d = %v, bad: %v, expect %v", v.mode, v.index, v.count, hits, bad, v.users)
+		}
+		for _, user := range v.users {
+			if hits[user] <= 0 {
+				t.Errorf("rows of %s worker %d/%d = %v, expect %s", v.mode, v.index, v.count, hits, user)
+			}
+		}
+		if v.mode == dataSequential {
+			for _, user := range v.users {
+				if n := hits[user] - hits[v.users[0]]; n > 1 || n < -1 {
+					t.Errorf("rows of sequential = %v, expect in turn", hits)
+				}
+			}
+		}
+		mu.Unlock()
+	}
+}
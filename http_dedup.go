@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// StressDedup duplicate processing of request ids for idempotency testing
+type StressDedup struct {
+	Ids          int64 `json:"ids"`           // unique request ids sent
+	Repeat       int   `json:"repeat"`        // times each request id is sent
+	Mismatched   int64 `json:"mismatched"`    // responses echo a different request id
+	Verified     int64 `json:"verified"`      // request ids queried on the verification endpoint
+	Once         int64 `json:"once"`          // request ids processed exactly once
+	Duplicated   int64 `json:"duplicated"`    // request ids processed more than once
+	Lost         int64 `json:"lost"`          // request ids not processed
+	VerifyFailed int64 `json:"verify_failed"` // verification requests failed
+}
+
+// dedupContext data of -dedup-verify template
+type dedupContext struct {
+	Id string
+}
+
+func (b *StressWorker) isDedup() bool {
+	return b.RequestParams.DedupHeader != "" || b.RequestParams.DedupVerify != ""
+}
+
+// nextRequestId unique request id of the sequence id, each id is sent -dedup-repeat times by the connection
+func (b *StressWorker) nextRequestId(client *StressClient) string {
+	if client.dedupLeft > 0 {
+		client.dedupLeft--
+		return client.dedupId
+	}
+
+	client.dedupId = fmt.Sprintf("%d-%d", b.RequestParams.SequenceId, b.sequence())
+	if b.RequestParams.DedupRepeat > 1 {
+		client.dedupLeft = b.RequestParams.DedupRepeat - 1
+	}
+	atomic.AddInt64(&b.dedupSent, 1)
+	if b.RequestParams.DedupVerify != "" {
+		b.dedupMu.Lock()
+		b.dedupIds = append(b.dedupIds, client.dedupId)
+		b.dedupMu.Unlock()
+	}
+	return client.dedupId
+}
+
+// parseDedupVerify parse template of verification endpoint, e.g. http://127.0.0.1/processed?id={{ .Id }}
+func parseDedupVerify(text string) (*template.Template, error) {
+	tpl, err := template.New("dedup").Funcs(fnMap).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tpl.Execute(io.Discard, dedupContext{Id: "0-0"}); err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// parseProcessedCount parse times processed from the body of verification endpoint,
+// a number or json object with "count", e.g. 1 or {"count": 1}
+func parseProcessedCount(body []byte) (int64, error) {
+	body = bytes.TrimSpace(body)
+	if count, err := strconv.ParseInt(string(body), 10, 64); err == nil {
+		return count, nil
+	}
+	var v struct {
+		Count *int64 `json:"count"`
+	}
+	if err := json.Unmarshal(body, &v); err != nil || v.Count == nil {
+		return 0, fmt.Errorf("expect number or {\"count\": number}, got: %.64s", body)
+	}
+	return *v.Count, nil
+}
+
+// verifyDedup query the verification endpoint of each request id with -c connections,
+// 404 is not processed
+func (b *StressWorker) verifyDedup() *StressDedup {
+	b.dedupMu.Lock()
+	ids := b.dedupIds
+	b.dedupMu.Unlock()
+
+	dedup := &StressDedup{Ids: int64(len(ids)), Repeat: b.RequestParams.DedupRepeat}
+	tpl, err := parseDedupVerify(b.RequestParams.DedupVerify)
+	if err != nil {
+		verbosePrint(vERROR, "parse dedup verify err: %v", err)
+		dedup.VerifyFailed = dedup.Ids
+		return dedup
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		idChan = make(chan string)
+		client = &http.Client{Timeout: time.Duration(b.RequestParams.Timeout) * time.Millisecond}
+	)
+	eprintln("verifying %d request ids", len(ids))
+	for i := 0; i < b.RequestParams.C; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range idChan {
+				count, err := queryProcessedCount(client, tpl, id)
+				mu.Lock()
+				dedup.Verified++
+				switch {
+				case err != nil:
+					verbosePrint(vDEBUG, "verify request id %s: %v", id, err)
+					dedup.VerifyFailed++
+				case count == 0:
+					dedup.Lost++
+				case count == 1:
+					dedup.Once++
+				default:
+					dedup.Duplicated++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, id := range ids {
+		idChan <- id
+	}
+	close(idChan)
+	wg.Wait()
+	return dedup
+}
+
+func queryProcessedCount(client *http.Client, tpl *template.Template, id string) (int64, error) {
+	var url strings.Builder
+	if err := tpl.Execute(&url, dedupContext{Id: id}); err != nil {
+		return 0, err
+	}
+	resp, err := client.Get(url.String())
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return 0, nil
+	case resp.StatusCode >= 300:
+		return 0, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return parseProcessedCount(body)
+}
+
+// finishDedup count request ids and verify them when -dedup-verify is set
+func (b *StressWorker) finishDedup() *StressDedup {
+	dedup := &StressDedup{Ids: atomic.LoadInt64(&b.dedupSent), Repeat: b.RequestParams.DedupRepeat}
+	if b.RequestParams.DedupVerify != "" {
+		dedup = b.verifyDedup()
+	}
+	dedup.Mismatched = atomic.LoadInt64(&b.dedupMismatched)
+	return dedup
+}
+
+func (dedup *StressDedup) merge(v *StressDedup) {
+	dedup.Ids += v.Ids
+	dedup.Mismatched += v.Mismatched
+	dedup.Verified += v.Verified
+	dedup.Once += v.Once
+	dedup.Duplicated += v.Duplicated
+	dedup.Lost += v.Lost
+	dedup.VerifyFailed += v.VerifyFailed
+	if dedup.Repeat < v.Repeat {
+		dedup.Repeat = v.Repeat
+	}
+}
+
+// violated duplicate or lost processing, or mixed up responses
+func (dedup *StressDedup) violated() bool {
+	return dedup.Duplicated > 0 || dedup.Lost > 0 || dedup.Mismatched > 0
+}
+
+// printDedup Print duplicate processing of request ids
+func (result *StressResult) printDedup(w io.Writer) {
+	d := result.Dedup
+	fprintln(w, "\nDeduplication:")
+	fprintln(w, "  Request ids:\t%d, each sent %d times", d.Ids, d.Repeat)
+	if d.Verified > 0 {
+		fprintln(w, "  Processed once:\t%d", d.Once)
+		fprintln(w, "  Duplicated:\t%d request ids", d.Duplicated)
+		fprintln(w, "  Lost:\t%d request ids", d.Lost)
+		if d.VerifyFailed > 0 {
+			fprintln(w, "  Unverified:\t%d request ids, the verification endpoint failed", d.VerifyFailed)
+		}
+	}
+	if d.Mismatched > 0 {
+		fprintln(w, "  Mismatched:\t%d responses echo a different request id", d.Mismatched)
+	}
+}
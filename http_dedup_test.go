@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		processed = make(map[string]int)
+		first     string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/processed" {
+			id := r.URL.Query().Get("id")
+			if _, ok := processed[id]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprintf(w, `{"count": %d}`, processed[id])
+			return
+		}
+		id := r.Header.Get("Idempotency-Key")
+		if first == "" {
+			first = id
+		}
+		if _, ok := processed[id]; !ok || id == first { // not idempotent for the first id
+			processed[id]++
+		}
+		w.Header().Set("Idempotency-Key", id)
+	}))
+	defer srv.Close()
+
+	seqId := time.Now().UnixNano()
+	_, result := executeStress(StressParameters{
+		SequenceId:    seqId,
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL + "/orders",
+		C:             2,
+		N:             10,
+		Duration:      duration,
+		Timeout:       1000,
+		DedupHeader:   "Idempotency-Key",
+		DedupRepeat:   2,
+		DedupVerify:   srv.URL + "/processed?id={{ .Id }}",
+	})
+	d := result.Dedup
+	if d == nil || d.Ids == 0 || d.Verified != d.Ids || d.Duplicated != 1 || d.Once != d.Ids-1 || d.Lost != 0 || d.Mismatched != 0 {
+		t.Fatalf("dedup result: %+v, expect 1 duplicated", d)
+	}
+	if code := result.exitCode(); code != exitAssertion {
+		t.Errorf("exitCode = %d, expect: %d", code, exitAssertion)
+	}
+	if !strings.HasPrefix(first, fmt.Sprintf("%d-", seqId)) {
+		t.Errorf("request id = %s, expect prefix of sequence id %d", first, seqId)
+	}
+
+	for _, v := range []struct {
+		body   string
+		expect int64
+	}{{"2\n", 2}, {`{"count": 0}`, 0}} {
+		if count, err := parseProcessedCount([]byte(v.body)); err != nil || count != v.expect {
+			t.Errorf("parseProcessedCount(%q) = %d, %v", v.body, count, err)
+		}
+	}
+	if _, err := parseProcessedCount([]byte(`{"processed": true}`)); err == nil {
+		t.Errorf("parseProcessedCount without count expect err")
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	gourl "net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	diagnoseDialTimeouts = 100 // dial timeouts of a run to diagnose the connections
+	diagnoseProbes       = 3   // tcp connect probes to the target
+	diagnoseFdReserve    = 64  // file descriptors besides the connections, e.g. stdio, files and dns
+)
+
+// StressDiagnosis diagnostics of the load generator gathered when a run has many dial timeouts
+type StressDiagnosis struct {
+	Generator    string    `json:"generator"`             // hostname of the load generator
+	DialTimeouts int64     `json:"dial_timeouts"`         // errors of dial timeout
+	Concurrency  int       `json:"concurrency"`           // connections of the run
+	Target       string    `json:"target"`                // host:port dialed, the proxy with -x
+	ResolvedIPs  []string  `json:"resolved_ips"`          // addresses of the target host
+	ResolveErr   string    `json:"resolve_err,omitempty"` // error resolving the target host
+	Probes       []float64 `json:"probes_ms"`             // ms of tcp connect probes after the load, -1 is failed
+	ProbeErr     string    `json:"probe_err,omitempty"`   // error of a failed probe
+	OpenFiles    int64     `json:"open_files"`            // open file descriptors of the process, -1 is unknown
+	FileLimit    int64     `json:"file_limit"`            // soft limit of open files, -1 is unknown
+	PortRange    string    `json:"port_range,omitempty"`  // ephemeral ports of ip_local_port_range
+	Ports        int64     `json:"ports"`                 // ephemeral ports in the range, -1 is unknown
+	TcpInUse     int64     `json:"tcp_inuse"`             // tcp sockets in use of the system, -1 is unknown
+	TimeWait     int64     `json:"time_wait"`             // tcp sockets in TIME_WAIT of the system, -1 is unknown
+	Conntrack    int64     `json:"conntrack"`             // entries of conntrack table, -1 is unknown
+	ConntrackMax int64     `json:"conntrack_max"`         // size of conntrack table, -1 is unknown
+	Findings     []string  `json:"findings"`              // likely causes of the timeouts
+}
+
+// isDialTimeout error message of a connection not established within the timeout, e.g.
+// dial tcp 10.0.0.1:443: i/o timeout or dial tcp 10.0.0.1:443: connect: connection timed out
+func isDialTimeout(msg string) bool {
+	return strings.Contains(msg, "dial tcp") && (strings.Contains(msg, "timeout") || strings.Contains(msg, "timed out"))
+}
+
+// dialTimeouts errors of dial timeout in the error distribution
+func dialTimeouts(errorDist map[string]int) int64 {
+	var n int64
+	for msg, num := range errorDist {
+		if isDialTimeout(msg) {
+			n += int64(num)
+		}
+	}
+	return n
+}
+
+// diagnose gather diagnostics of the generator and the target when the result has many dial timeouts,
+// nil if not, so that the summary points at the likely cause instead of repeating the errors
+func (b *StressWorker) diagnose(result *StressResult) []StressDiagnosis {
+	n := dialTimeouts(result.ErrorDist)
+	if n < diagnoseDialTimeouts {
+		return nil
+	}
+	d := StressDiagnosis{DialTimeouts: n, Concurrency: b.RequestParams.C}
+	d.Generator, _ = os.Hostname()
+	d.Target = dialTarget(b.RequestParams.Url)
+	if d.Target != "" {
+		host, _, _ := net.SplitHostPort(d.Target)
+		if ips, err := net.LookupHost(host); err != nil {
+			d.ResolveErr = err.Error()
+		} else {
+			d.ResolvedIPs = ips
+		}
+		timeout := time.Duration(b.RequestParams.Timeout) * time.Millisecond
+		d.Probes, d.ProbeErr = probeConnect(d.Target, timeout)
+	}
+	d.OpenFiles, d.FileLimit = openFiles(), fileLimit()
+	d.PortRange, d.Ports = portRange(readProc("/proc/sys/net/ipv4/ip_local_port_range"))
+	d.TcpInUse, d.TimeWait = sockstat(readProc("/proc/net/sockstat"))
+	d.Conntrack = procInt("/proc/sys/net/netfilter/nf_conntrack_count")
+	d.ConntrackMax = procInt("/proc/sys/net/netfilter/nf_conntrack_max")
+	d.Findings = d.findings()
+	return []StressDiagnosis{d}
+}
+
+// dialTarget host:port the connections dial, the proxy of -x if set
+func dialTarget(url string) string {
+	if proxyUrl != nil {
+		url = proxyUrl.String()
+	}
+	u, err := gourl.Parse(url)
+	if err != nil || u.Hostname() == "" || strings.Contains(u.Host, "{{") {
+		return ""
+	}
+	if port := u.Port(); port != "" {
+		return u.Host
+	}
+	port := "80"
+	if u.Scheme == "https" || u.Scheme == "wss" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// probeConnect tcp connect probes to the target concurrently, the latency in ms or -1 if failed
+func probeConnect(target string, timeout time.Duration) ([]float64, string) {
+	var (
+		probes = make([]float64, diagnoseProbes)
+		errs   = make([]error, diagnoseProbes)
+		wg     sync.WaitGroup
+	)
+	for i := range probes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			start := time.Now()
+			conn, err := net.DialTimeout("tcp", target, timeout)
+			if err != nil {
+				probes[i], errs[i] = -1, err
+				return
+			}
+			probes[i] = float64(time.Since(start).Microseconds()) / 1000
+			conn.Close()
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return probes, err.Error()
+		}
+	}
+	return probes, ""
+}
+
+func readProc(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+func procInt(path string) int64 {
+	n, err := strconv.ParseInt(readProc(path), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// openFiles open file descriptors of the process, -1 if /proc is not readable
+func openFiles() int64 {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return int64(len(fds))
+}
+
+// portRange ephemeral ports of ip_local_port_range, e.g. "32768	60999"
+func portRange(content string) (string, int64) {
+	fields := strings.Fields(content)
+	if len(fields) != 2 {
+		return "", -1
+	}
+	low, err1 := strconv.ParseInt(fields[0], 10, 64)
+	high, err2 := strconv.ParseInt(fields[1], 10, 64)
+	if err1 != nil || err2 != nil || high < low {
+		return "", -1
+	}
+	return fmt.Sprintf("%d-%d", low, high), high - low + 1
+}
+
+// sockstat tcp sockets in use and in TIME_WAIT of /proc/net/sockstat, e.g.
+// TCP: inuse 5 orphan 0 tw 2 alloc 7 mem 1
+func sockstat(content string) (int64, int64) {
+	inuse, tw := int64(-1), int64(-1)
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) <= 0 || fields[0] != "TCP:" {
+			continue
+		}
+		for i := 1; i+1 < len(fields); i += 2 {
+			n, err := strconv.ParseInt(fields[i+1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch fields[i] {
+			case "inuse":
+				inuse = n
+			case "tw":
+				tw = n
+			}
+		}
+	}
+	return inuse, tw
+}
+
+// findings likely causes of the dial timeouts from the diagnostics
+func (d *StressDiagnosis) findings() []string {
+	var findings []string
+	if d.ResolveErr != "" {
+		findings = append(findings, "resolving the target failed, check DNS of the generator: "+d.ResolveErr)
+	}
+	var failed int
+	var sum float64
+	for _, p := range d.Probes {
+		if p < 0 {
+			failed++
+		} else {
+			sum += p
+		}
+	}
+	switch {
+	case len(d.Probes) <= 0:
+	case failed == len(d.Probes):
+		findings = append(findings, fmt.Sprintf("TCP connect to %s fails from the generator (%s), firewall, security group or server down?",
+			d.Target, d.ProbeErr))
+	case failed > 0:
+		findings = append(findings, fmt.Sprintf("%d of %d TCP connect probes to %s failed after the load, SYN dropped by server or load balancer?",
+			failed, len(d.Probes), d.Target))
+	default:
+		findings = append(findings, fmt.Sprintf("TCP connect probes to %s succeed in %.1fms after the load, the timeouts happen under load: listen backlog (somaxconn) of server or connection limit of load balancer?",
+			d.Target, sum/float64(len(d.Probes))))
+	}
+	if d.FileLimit > 0 && (float64(d.OpenFiles) >= 0.9*float64(d.FileLimit) || d.FileLimit < int64(d.Concurrency)+diagnoseFdReserve) {
+		findings = append(findings, fmt.Sprintf("open files limit %d is low for %d connections, raise it with ulimit -n",
+			d.FileLimit, d.Concurrency))
+	}
+	if d.Ports > 0 && d.TimeWait >= d.Ports/2 {
+		findings = append(findings, fmt.Sprintf("%d sockets in TIME_WAIT of %d ephemeral ports, keep-alive or a wider ip_local_port_range avoids port exhaustion",
+			d.TimeWait, d.Ports))
+	} else if d.Ports > 0 && int64(d.Concurrency) > d.Ports {
+		findings = append(findings, fmt.Sprintf("%d connections exceed %d ephemeral ports to one destination", d.Concurrency, d.Ports))
+	}
+	if d.ConntrackMax > 0 && float64(d.Conntrack) >= 0.9*float64(d.ConntrackMax) {
+		findings = append(findings, fmt.Sprintf("conntrack table nearly full (%d of %d), new connections are dropped, raise nf_conntrack_max",
+			d.Conntrack, d.ConntrackMax))
+	}
+	return findings
+}
+
+// printDiagnosis Print diagnostics of generators with many dial timeouts
+func (result *StressResult) printDiagnosis(w io.Writer) {
+	unknown := func(n int64) string {
+		if n < 0 {
+			return "unknown"
+		}
+		return strconv.FormatInt(n, 10)
+	}
+	for _, d := range result.Diagnosis {
+		fprintln(w, "\nConnection diagnosis of %s (%d dial timeouts):", d.Generator, d.DialTimeouts)
+		if d.Target != "" {
+			ips := strings.Join(d.ResolvedIPs, ", ")
+			if d.ResolveErr != "" {
+				ips = "resolve failed"
+			}
+			fprintln(w, "  Target:\t%s -> %s", d.Target, ips)
+		}
+		if len(d.Probes) > 0 {
+			probes := make([]string, 0, len(d.Probes))
+			for _, p := range d.Probes {
+				if p < 0 {
+					probes = append(probes, "failed")
+				} else {
+					probes = append(probes, fmt.Sprintf("%.1fms", p))
+				}
+			}
+			fprintln(w, "  Connect probes:\t%s", strings.Join(probes, ", "))
+		}
+		fprintln(w, "  Open files:\t%s of limit %s", unknown(d.OpenFiles), unknown(d.FileLimit))
+		if d.Ports > 0 {
+			fprintln(w, "  Ephemeral ports:\t%s, %s TCP in use, %s in TIME_WAIT", d.PortRange, unknown(d.TcpInUse), unknown(d.TimeWait))
+		}
+		if d.ConntrackMax > 0 {
+			fprintln(w, "  Conntrack:\t%d of %d", d.Conntrack, d.ConntrackMax)
+		}
+		for _, finding := range d.Findings {
+			fprintln(w, "  - %s", finding)
+		}
+	}
+}
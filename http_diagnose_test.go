@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiagnose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	b := &StressWorker{RequestParams: &StressParameters{Url: srv.URL + "/api", C: 1000, Timeout: 1000}}
+	result := &StressResult{ErrorDist: map[string]int{
+		`Get "` + srv.URL + `/api": dial tcp ` + srv.Listener.Addr().String() + `: i/o timeout`:                   90,
+		`Get "` + srv.URL + `/api": dial tcp ` + srv.Listener.Addr().String() + `: connect: connection timed out`: 10,
+		`Get "` + srv.URL + `/api": EOF`: 3,
+	}}
+	if n := dialTimeouts(result.ErrorDist); n != 100 {
+		t.Errorf("dialTimeouts = %d, expect: 100", n)
+	}
+	result.Diagnosis = b.diagnose(result)
+	if len(result.Diagnosis) != 1 {
+		t.Fatalf("diagnose = %+v", result.Diagnosis)
+	}
+	d := result.Diagnosis[0]
+	if d.DialTimeouts != 100 || d.Target != srv.Listener.Addr().String() || len(d.ResolvedIPs) != 1 || len(d.Probes) != diagnoseProbes ||
+		d.ProbeErr != "" || len(d.Findings) <= 0 || !strings.Contains(d.Findings[0], "succeed") {
+		t.Errorf("diagnosis = %+v", d)
+	}
+
+	var buf bytes.Buffer
+	result.printErrors(&buf)
+	result.printDiagnosis(&buf)
+	for _, line := range []string{
+		"  [100]\tdial timeout (2 distinct errors, see the connection diagnosis)",
+		"  [3]\tGet \"" + srv.URL + "/api\": EOF",
+		"  Target:\t" + d.Target + " -> 127.0.0.1",
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("printErrors and printDiagnosis expect %q, got:\n%s", line, buf.String())
+		}
+	}
+
+	delete(result.ErrorDist, `Get "`+srv.URL+`/api": dial tcp `+srv.Listener.Addr().String()+`: i/o timeout`)
+	if diagnosis := b.diagnose(result); diagnosis != nil {
+		t.Errorf("diagnose below threshold = %+v, expect nil", diagnosis)
+	}
+}
+
+func TestDiagnosisFindings(t *testing.T) {
+	if r, n := portRange("32768\t60999"); r != "32768-60999" || n != 28232 {
+		t.Errorf("portRange = %s, %d", r, n)
+	}
+	if inuse, tw := sockstat("sockets: used 300\nTCP: inuse 5 orphan 0 tw 28000 alloc 7 mem 1\nUDP: inuse 2 mem 0"); inuse != 5 || tw != 28000 {
+		t.Errorf("sockstat = %d, %d", inuse, tw)
+	}
+
+	d := &StressDiagnosis{
+		Target:       "10.0.0.1:443",
+		Concurrency:  1000,
+		Probes:       []float64{-1, -1, -1},
+		ProbeErr:     "dial tcp 10.0.0.1:443: i/o timeout",
+		OpenFiles:    1020,
+		FileLimit:    1024,
+		Ports:        28232,
+		TimeWait:     28000,
+		Conntrack:    65000,
+		ConntrackMax: 65536,
+	}
+	findings := strings.Join(d.findings(), "\n")
+	for _, expect := range []string{
+		"TCP connect to 10.0.0.1:443 fails from the generator",
+		"open files limit 1024 is low for 1000 connections",
+		"28000 sockets in TIME_WAIT of 28232 ephemeral ports",
+		"conntrack table nearly full (65000 of 65536)",
+	} {
+		if !strings.Contains(findings, expect) {
+			t.Errorf("findings expect %q, got:\n%s", expect, findings)
+		}
+	}
+}
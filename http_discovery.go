@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const discoveryTimeout = 5 * time.Second // timeout of a DNS SRV lookup or etcd request
+
+// workerDiscovery fleet of workers of -worker-config, resolved at start and again before each run
+type workerDiscovery struct {
+	static     []string // -W and "workers" of the config
+	srv        []string // DNS SRV names, "region=name" labels the targets with the region
+	etcd       string   // endpoint of etcd v3, e.g. http://127.0.0.1:2379
+	etcdPrefix string   // key prefix of etcd, each value is a worker like -W
+	scheme     string   // scheme of the discovered workers, http or https
+	lookupSRV  func(ctx context.Context, name string) ([]*net.SRV, error)
+}
+
+var discovery *workerDiscovery // nil without -worker-config
+
+// loadWorkerConfig parse the worker config file, static workers of -W are kept
+func loadWorkerConfig(file string, static []string) (*workerDiscovery, error) {
+	entries, err := parseYamlFile(file)
+	if err != nil {
+		return nil, err
+	}
+	d := &workerDiscovery{
+		static:     append([]string(nil), static...),
+		etcdPrefix: "/http_bench/workers/",
+		scheme:     "http",
+		lookupSRV: func(ctx context.Context, name string) ([]*net.SRV, error) {
+			_, addrs, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+			return addrs, err
+		},
+	}
+	for _, entry := range entries {
+		switch entry.key {
+		case "workers":
+			d.static = append(d.static, entry.values...)
+		case "srv":
+			d.srv = append(d.srv, entry.values...)
+		case "etcd", "etcd-prefix", "scheme":
+			if len(entry.values) != 1 {
+				return nil, fmt.Errorf("%s: %s expects one value", file, entry.key)
+			}
+			switch value := entry.values[0]; entry.key {
+			case "etcd":
+				d.etcd = strings.TrimSuffix(value, "/")
+			case "etcd-prefix":
+				d.etcdPrefix = value
+			case "scheme":
+				if value != "http" && value != "https" {
+					return nil, fmt.Errorf("%s: invalid scheme %q, expect http or https", file, value)
+				}
+				d.scheme = value
+			}
+		default:
+			return nil, fmt.Errorf("%s: unknown key %q, supports workers, srv, etcd, etcd-prefix and scheme", file, entry.key)
+		}
+	}
+	return d, nil
+}
+
+// resolve the workers of the static list, DNS SRV records and etcd, duplicates are removed
+func (d *workerDiscovery) resolve() ([]string, error) {
+	workers := append([]string(nil), d.static...)
+	for _, v := range d.srv {
+		region, name := splitWorkerRegion(v)
+		ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+		addrs, err := d.lookupSRV(ctx, name)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("lookup srv %s: %v", name, err)
+		}
+		for _, addr := range addrs {
+			worker := d.scheme + "://" + net.JoinHostPort(strings.TrimSuffix(addr.Target, "."), strconv.Itoa(int(addr.Port)))
+			if region != "" {
+				worker = region + "=" + worker
+			}
+			workers = append(workers, worker)
+		}
+	}
+	if d.etcd != "" {
+		values, err := d.etcdWorkers()
+		if err != nil {
+			return nil, fmt.Errorf("etcd %s: %v", d.etcd, err)
+		}
+		workers = append(workers, values...)
+	}
+
+	seen := make(map[string]bool, len(workers))
+	fleet := make(flagSlice, 0, len(workers))
+	for _, v := range workers {
+		if v = strings.TrimSpace(v); v != "" && !seen[v] {
+			seen[v] = true
+			fleet = append(fleet, v)
+		}
+	}
+	if len(fleet) <= 0 {
+		return nil, fmt.Errorf("no worker discovered")
+	}
+	return fleet, nil
+}
+
+// etcdWorkers values of the keys of etcdPrefix by the json gateway of etcd v3
+func (d *workerDiscovery) etcdWorkers() ([]string, error) {
+	// the range of the prefix ends at the prefix with the last byte increased
+	end := []byte(d.etcdPrefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end = append(end[:i], end[i]+1)
+			break
+		}
+	}
+	body, _ := json.Marshal(map[string]string{
+		"key":       base64.StdEncoding.EncodeToString([]byte(d.etcdPrefix)),
+		"range_end": base64.StdEncoding.EncodeToString(end),
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", d.etcd+"/v3/kv/range", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", httpContentTypeJSON)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var reply struct {
+		Kvs []struct {
+			Value string `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, err
+	}
+	var workers []string
+	for _, kv := range reply.Kvs {
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		workers = append(workers, string(value))
+	}
+	return workers, nil
+}
+
+// refreshWorkers resolve the fleet again before a run or a retry of unresponsive workers, the previous
+// workers are kept if it fails or the fleet does not fit -region or -c of -worker-split total, and
+// return whether the fleet changed
+func refreshWorkers(c int) bool {
+	if discovery == nil {
+		return false
+	}
+	fleet, err := discovery.resolve()
+	if err == nil && len(regionWeights) > 0 {
+		err = checkWorkerRegions(regionWeights, fleet)
+	}
+	if err == nil && workerSplit == splitTotal && c < len(fleet) {
+		err = fmt.Errorf("-c %d is fewer than the %d workers of -worker-split total", c, len(fleet))
+	}
+	if err != nil {
+		verbosePrint(vERROR, "resolve workers of -worker-config: %v, keep %d workers", err, len(workerList))
+		return false
+	}
+	changed := strings.Join(fleet, ",") != strings.Join(workerList, ",")
+	workerList = fleet
+	verbosePrint(vINFO, "%d workers of -worker-config", len(workerList))
+	return changed
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWorkerDiscovery(t *testing.T) {
+	var etcdKeys []string
+	etcd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Key      string `json:"key"`
+			RangeEnd string `json:"range_end"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		key, _ := base64.StdEncoding.DecodeString(req.Key)
+		end, _ := base64.StdEncoding.DecodeString(req.RangeEnd)
+		if r.URL.Path != "/v3/kv/range" || string(key) != "/bench/" || string(end) != "/bench0" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var kvs []map[string]string
+		for _, v := range etcdKeys {
+			kvs = append(kvs, map[string]string{"value": base64.StdEncoding.EncodeToString([]byte(v))})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"kvs": kvs})
+	}))
+	defer etcd.Close()
+
+	file := filepath.Join(t.TempDir(), "workers.yaml")
+	os.WriteFile(file, []byte(`# fleet of the load test
+workers:
+  - 10.0.0.1:12710
+  - eu=10.0.0.2:12710
+srv:
+  - _bench._tcp.example.com
+  - eu=_bench._tcp.eu.example.com
+etcd: `+etcd.URL+`/
+etcd-prefix: /bench/
+scheme: https
+`), 0644)
+
+	d, err := loadWorkerConfig(file, []string{"10.0.0.9:12710", "10.0.0.1:12710"})
+	if err != nil {
+		t.Fatalf("loadWorkerConfig err: %v", err)
+	}
+	srv := map[string][]*net.SRV{
+		"_bench._tcp.example.com":    {{Target: "w1.example.com.", Port: 12710}, {Target: "w2.example.com.", Port: 12711}},
+		"_bench._tcp.eu.example.com": {{Target: "w3.example.com.", Port: 12710}},
+	}
+	d.lookupSRV = func(ctx context.Context, name string) ([]*net.SRV, error) {
+		if addrs, ok := srv[name]; ok {
+			return addrs, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	etcdKeys = []string{"10.0.0.3:12710", "10.0.0.1:12710"}
+
+	workers, err := d.resolve()
+	expect := []string{"10.0.0.9:12710", "10.0.0.1:12710", "eu=10.0.0.2:12710", "https://w1.example.com:12710",
+		"https://w2.example.com:12711", "eu=https://w3.example.com:12710", "10.0.0.3:12710"}
+	if err != nil || !reflect.DeepEqual([]string(workers), expect) {
+		t.Errorf("resolve = %v, %v, expect %v", workers, err, expect)
+	}
+
+	// the fleet is re-resolved before the next run, and kept if resolving fails
+	savedWorkers, savedDiscovery := workerList, discovery
+	defer func() { workerList, discovery = savedWorkers, savedDiscovery }()
+	discovery, workerList = d, nil
+	etcdKeys = []string{"10.0.0.4:12710"}
+	if !refreshWorkers(10) || len(workerList) != 7 || workerList[6] != "10.0.0.4:12710" {
+		t.Errorf("refreshed workers = %v", workerList)
+	}
+	if refreshWorkers(10) {
+		t.Errorf("refresh of the same fleet reports a change")
+	}
+
+	// a fleet exceeding -c of -worker-split total is not taken
+	defer func(split string) { workerSplit = split }(workerSplit)
+	workerSplit = splitTotal
+	etcdKeys = []string{"10.0.0.4:12710", "10.0.0.5:12710"}
+	if refreshWorkers(7) || len(workerList) != 7 {
+		t.Errorf("workers after a fleet of 8 for -c 7 = %v, expect the previous", workerList)
+	}
+	if !refreshWorkers(8) || len(workerList) != 8 {
+		t.Errorf("workers of -c 8 = %v", workerList)
+	}
+
+	delete(srv, "_bench._tcp.example.com")
+	if refreshWorkers(8) || len(workerList) != 8 {
+		t.Errorf("workers after failed resolve = %v, expect the previous", workerList)
+	}
+
+	for _, content := range []string{"worker:\n  - 10.0.0.1:12710\n", "scheme: ftp\n", "etcd:\n  - a\n  - b\n"} {
+		os.WriteFile(file, []byte(content), 0644)
+		if _, err := loadWorkerConfig(file, nil); err == nil {
+			t.Errorf("loadWorkerConfig(%q) expect err", content)
+		}
+	}
+	os.WriteFile(file, []byte("workers:\n"), 0644)
+	if d, err := loadWorkerConfig(file, nil); err != nil {
+		t.Errorf("loadWorkerConfig of empty list err: %v", err)
+	} else if _, err := d.resolve(); err == nil {
+		t.Errorf("resolve of no worker expect err")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+const (
+	expectContinued = "continued" // server replied 100 Continue within the threshold
+	expectFinal     = "final"     // server replied final status without 100 Continue, the body is not sent
+	expectDelayed   = "delayed"   // no reply within the threshold, the body is sent after the delay
+)
+
+// StressExpect replies of server to "Expect: 100-continue" of -expect-continue
+type StressExpect struct {
+	Threshold int64 `json:"threshold"`  // ms to wait for 100 Continue before sending the body
+	Continued int64 `json:"continued"`  // 100 Continue within the threshold
+	Final     int64 `json:"final"`      // final status immediately without 100 Continue
+	Delayed   int64 `json:"delayed"`    // no reply within the threshold
+	WaitTotal int64 `json:"wait_total"` // sum of time to 100 Continue in ms, scaled by scaleNum
+}
+
+// expectTrace time of request headers written, and the first reply of server
+type expectTrace struct {
+	wroteHeaders time.Time
+	got100       time.Time
+	firstByte    time.Time
+}
+
+// traceExpect set "Expect: 100-continue" and trace the reply of server
+func traceExpect(req *http.Request) (*http.Request, *expectTrace) {
+	trace := &expectTrace{}
+	req.Header = req.Header.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Header.Set("Expect", "100-continue")
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		WroteHeaders:         func() { trace.wroteHeaders = time.Now() },
+		Got100Continue:       func() { trace.got100 = time.Now() },
+		GotFirstResponseByte: func() { trace.firstByte = time.Now() },
+	})), trace
+}
+
+// classify the reply of server, the first response byte of 100 Continue is also the first byte,
+// and 100 Continue after the threshold is too late, the body is already sent
+func (trace *expectTrace) classify(res *result, threshold time.Duration) {
+	switch {
+	case trace.wroteHeaders.IsZero():
+		return // not sent
+	case !trace.got100.IsZero() && trace.got100.Sub(trace.wroteHeaders) < threshold:
+		res.expect, res.expectWait = expectContinued, trace.got100.Sub(trace.wroteHeaders)
+	case !trace.firstByte.IsZero() && trace.firstByte.Sub(trace.wroteHeaders) < threshold:
+		res.expect = expectFinal
+	default:
+		res.expect = expectDelayed
+	}
+}
+
+func (expect *StressExpect) append(res *result) {
+	switch res.expect {
+	case expectContinued:
+		expect.Continued++
+		expect.WaitTotal += int64(res.expectWait.Seconds() * scaleNum)
+	case expectFinal:
+		expect.Final++
+	case expectDelayed:
+		expect.Delayed++
+	}
+}
+
+func (expect *StressExpect) merge(v *StressExpect) {
+	expect.Threshold = v.Threshold
+	expect.Continued += v.Continued
+	expect.Final += v.Final
+	expect.Delayed += v.Delayed
+	expect.WaitTotal += v.WaitTotal
+}
+
+// printExpect Print replies of server to "Expect: 100-continue"
+func (result *StressResult) printExpect(w io.Writer) {
+	e := result.Expect
+	fprintln(w, "\nExpect 100-continue (threshold %v):", time.Duration(e.Threshold)*time.Millisecond)
+	if e.Continued > 0 {
+		fprintln(w, "  100 Continue:\t%d requests, average wait %4.3f secs", e.Continued, float64(e.WaitTotal)/float64(e.Continued)/scaleNum)
+	} else {
+		fprintln(w, "  100 Continue:\t0 requests")
+	}
+	fprintln(w, "  Final status immediately:\t%d requests, the body is not sent", e.Final)
+	fprintln(w, "  No reply within threshold:\t%d requests, the body is sent after the delay", e.Delayed)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExpectContinue(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/reject":
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+		}
+		// reading the body replies 100 Continue
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		path                      string
+		continued, final, delayed bool
+	}{
+		{"/upload", true, false, false},
+		{"/reject", false, true, false},
+		{"/slow", false, false, true},
+	} {
+		_, result := executeStress(StressParameters{
+			SequenceId:     time.Now().UnixNano(),
+			Cmd:            cmdStart,
+			RequestType:    typeHttp1,
+			RequestMethod:  "POST",
+			RequestBody:    "data",
+			Url:            srv.URL + tt.path,
+			C:              1,
+			N:              4,
+			Duration:       10,
+			Timeout:        2000,
+			ExpectContinue: 50,
+		})
+		e := result.Expect
+		if e == nil || e.Threshold != 50 || result.LatsTotal < 4 {
+			t.Fatalf("%s: expect = %+v, %d responses, errors: %v", tt.path, e, result.LatsTotal, result.ErrorDist)
+		}
+		count := func(ok bool) int64 {
+			if ok {
+				return result.LatsTotal
+			}
+			return 0
+		}
+		if e.Continued != count(tt.continued) || e.Final != count(tt.final) || e.Delayed != count(tt.delayed) {
+			t.Errorf("%s: expect = %+v of %d responses", tt.path, e, result.LatsTotal)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// responseExtract value captured from responses into a variable of the connection, {{ .Vars.name }} in
+// templates of the following requests, e.g. "token=jsonpath:$.data.token", "session=header:X-Session"
+type responseExtract struct {
+	name     string
+	jsonPath []interface{}
+	header   string
+}
+
+// parseExtract parse "name=jsonpath:<path>" or "name=header:<name>"
+func parseExtract(expr string) (*responseExtract, error) {
+	name, source, ok := strings.Cut(expr, "=")
+	e := &responseExtract{name: strings.TrimSpace(name)}
+	if !ok || e.name == "" {
+		return nil, fmt.Errorf("invalid -extract %q, expect \"name=jsonpath:$.path\" or \"name=header:Name\"", expr)
+	}
+	kind, value, _ := strings.Cut(strings.TrimSpace(source), ":")
+	switch value = strings.TrimSpace(value); strings.ToLower(kind) {
+	case "jsonpath":
+		path, err := parseJsonPath(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -extract %q, %v", expr, err)
+		}
+		e.jsonPath = path
+	case "header":
+		if value == "" {
+			return nil, fmt.Errorf("invalid -extract %q, empty header name", expr)
+		}
+		e.header = http.CanonicalHeaderKey(value)
+	default:
+		return nil, fmt.Errorf("invalid -extract %q, expect jsonpath or header, e.g. \"token=jsonpath:$.data.token\"", expr)
+	}
+	return e, nil
+}
+
+// value of the extract in the response, false if not found
+func (e *responseExtract) value(r *assertResponse) (string, bool) {
+	if e.jsonPath != nil {
+		return r.jsonValue(e.jsonPath)
+	}
+	if v := r.header.Get(e.header); v != "" {
+		return v, true
+	}
+	return "", false
+}
+
+// onBody the extract needs the response body
+func (e *responseExtract) onBody() bool {
+	return e.jsonPath != nil
+}
+
+// extractVars store the values found in the response into vars, the previous values are kept when not found
+func extractVars(extracts []*responseExtract, r *assertResponse, vars map[string]string) {
+	for _, e := range extracts {
+		if v, ok := e.value(r); ok {
+			vars[e.name] = v
+		} else {
+			verbosePrint(vDEBUG, "extract %s not found in response", e.name)
+		}
+	}
+}
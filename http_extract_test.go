@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseExtract(t *testing.T) {
+	for _, v := range []struct {
+		expr, header string
+		body         bool
+	}{
+		{expr: "token=jsonpath:$.data.token", body: true},
+		{expr: " session = header: x-session", header: "X-Session"},
+	} {
+		e, err := parseExtract(v.expr)
+		if err != nil || e.header != v.header || e.onBody() != v.body {
+			t.Errorf("parseExtract(%q) = %+v, %v", v.expr, e, err)
+		}
+	}
+	for expr, expect := range map[string]string{
+		"token":                  "expect",
+		"=jsonpath:$.token":      "expect",
+		"token=jsonpath:token":   "json path starts with $",
+		"token=header:":          "empty header name",
+		"token=regex:token=(.*)": "expect jsonpath or header",
+	} {
+		if _, err := parseExtract(expr); err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("parseExtract(%q) err = %v, expect: %s", expr, err, expect)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	var authorized, unauthorized int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer": // trailing space trimmed
+			atomic.AddInt64(&unauthorized, 1)
+			w.Write([]byte(`{"data": {"token": "t1"}}`))
+		case "Bearer t1":
+			atomic.AddInt64(&authorized, 1)
+			w.Write([]byte(`{"code": 0}`)) // no token, the last one is kept
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL,
+		Headers:       map[string][]string{"Authorization": {"Bearer {{ .Vars.token }}"}},
+		C:             1,
+		N:             5,
+		Timeout:       3000,
+		Extracts:      []string{"token=jsonpath:$.data.token"},
+	})
+	if result == nil || len(result.ErrorDist) > 0 || result.StatusCodeDist[http.StatusOK] < 5 {
+		t.Fatalf("result of extract = %+v", result)
+	}
+	if u, a := atomic.LoadInt64(&unauthorized), atomic.LoadInt64(&authorized); u != 1 || a < 4 {
+		t.Errorf("requests without token = %d, with token = %d, expect the token extracted once and reused", u, a)
+	}
+}
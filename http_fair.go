@@ -0,0 +1,154 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	shareMaxConns int // connections of the worker shared by concurrent jobs, set by -worker-max-c, 0 is unlimited
+	shareMaxQps   int // requests per second of the worker shared by concurrent jobs, set by -worker-max-q, 0 is unlimited
+
+	shareMu   sync.Mutex
+	shareJobs = make(map[*StressWorker]bool) // running jobs of the worker
+)
+
+// StressContention jobs running at the same time on a worker, and the share of connections and qps granted
+// to the job by -weight, so that overlapping jobs are reported instead of degrading each other silently
+type StressContention struct {
+	Generator string `json:"generator"` // hostname of the worker
+	Weight    int    `json:"weight"`    // weight of the job
+	Jobs      int    `json:"jobs"`      // concurrent jobs at most, including this one
+	C         int    `json:"c"`         // connections requested by -c
+	MinC      int    `json:"min_c"`     // fewest connections granted
+	Qps       int    `json:"qps"`       // rate requested by -q, 0 is unlimited
+	MinQps    int    `json:"min_qps"`   // lowest rate granted, 0 is unlimited
+	Changes   int    `json:"changes"`   // adjustments while running as jobs came and went
+
+	grantC, grantQps int
+}
+
+// jobWeight weight of the job of -weight, at least 1
+func (b *StressWorker) jobWeight() int {
+	if b.RequestParams.Weight < 1 {
+		return 1
+	}
+	return b.RequestParams.Weight
+}
+
+// shareGrant the share of budget by weight, capped by what the job wants, want 0 is unlimited
+func shareGrant(budget, weight, total, want int) int {
+	if budget <= 0 {
+		return want
+	}
+	grant := budget * weight / total
+	if grant < 1 {
+		grant = 1
+	}
+	if want > 0 && want < grant {
+		return want
+	}
+	return grant
+}
+
+// joinShare register the job to the worker and grant its share of connections and qps before the clients start,
+// the connections of -n jobs are fixed at start so that -n is kept, the others are adjusted while running
+func (b *StressWorker) joinShare() {
+	shareMu.Lock()
+	defer shareMu.Unlock()
+
+	p := b.RequestParams
+	b.contention = &StressContention{Weight: b.jobWeight(), C: p.C, MinC: p.C, Qps: p.Qps, MinQps: p.Qps, grantC: p.C, grantQps: p.Qps}
+	shareJobs[b] = true
+	rebalanceShare(b)
+}
+
+// leaveShare unregister the finished job, the share of the others grows back
+func (b *StressWorker) leaveShare() {
+	shareMu.Lock()
+	defer shareMu.Unlock()
+
+	delete(shareJobs, b)
+	rebalanceShare(nil)
+}
+
+// rebalanceShare grant the share of each running job by weight, the joining job is set directly, the
+// running ones are adjusted as with PUT /api/jobs/{sequence id}/rate, must hold shareMu
+func rebalanceShare(joining *StressWorker) {
+	var total int
+	for b := range shareJobs {
+		total += b.jobWeight()
+	}
+	for b := range shareJobs {
+		s := b.contention
+		if len(shareJobs) > s.Jobs {
+			s.Jobs = len(shareJobs)
+		}
+		c := shareGrant(shareMaxConns, s.Weight, total, s.C)
+		qps := shareGrant(shareMaxQps, s.Weight, total, s.Qps)
+		if b == joining {
+			b.RequestParams.C, s.grantC = c, c
+			if qps != s.Qps {
+				atomic.StoreInt64(&b.liveQps, int64(qps))
+			}
+			s.grantQps = qps
+		} else {
+			var req rateRequest
+			if c != s.grantC && b.RequestParams.N <= 0 && b.RequestParams.ArrivalRate <= 0 {
+				req.C = c
+			}
+			if qps != s.grantQps {
+				req.Qps = qps
+			}
+			if req == (rateRequest{}) || !b.adjustLoad(req) {
+				continue
+			}
+			s.Changes++
+			if req.C > 0 {
+				s.grantC = c
+			}
+			if req.Qps > 0 {
+				s.grantQps = qps
+			}
+		}
+		if s.grantC < s.MinC {
+			s.MinC = s.grantC
+		}
+		if s.grantQps > 0 && (s.MinQps <= 0 || s.grantQps < s.MinQps) {
+			s.MinQps = s.grantQps
+		}
+	}
+}
+
+// contentionStats the share of the job after it left, nil if it ran alone with what it requested
+func (b *StressWorker) contentionStats() *StressContention {
+	s := b.contention
+	if s == nil || s.Jobs <= 1 && s.MinC == s.C && s.MinQps == s.Qps {
+		return nil
+	}
+	stats := *s
+	stats.Generator, _ = os.Hostname()
+	return &stats
+}
+
+// printContention Print jobs overlapping on the workers and the share granted to this one
+func (result *StressResult) printContention(w io.Writer) {
+	for _, s := range result.Contention {
+		fprintln(w, "\nContention of %s:", s.Generator)
+		fprintln(w, "  Jobs:\t%d concurrent at most, weight %d", s.Jobs, s.Weight)
+		if s.MinC < s.C {
+			fprintln(w, "  Connections:\t%d of %d granted at least", s.MinC, s.C)
+		}
+		switch {
+		case s.MinQps > 0 && s.Qps <= 0:
+			fprintln(w, "  Qps:\t%d granted at least, unlimited requested", s.MinQps)
+		case s.MinQps < s.Qps:
+			fprintln(w, "  Qps:\t%d of %d granted at least", s.MinQps, s.Qps)
+		}
+		if s.Changes > 0 {
+			fprintln(w, "  Adjusted:\t%d times as jobs came and went", s.Changes)
+		}
+	}
+}
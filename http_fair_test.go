@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShareGrant(t *testing.T) {
+	for _, v := range []struct {
+		budget, weight, total, want int
+		expect                      int
+	}{
+		{budget: 0, weight: 1, total: 2, want: 50, expect: 50},
+		{budget: 10, weight: 1, total: 2, want: 50, expect: 5},
+		{budget: 10, weight: 3, total: 4, want: 50, expect: 7},
+		{budget: 10, weight: 1, total: 2, want: 3, expect: 3},
+		{budget: 10, weight: 1, total: 2, want: 0, expect: 5},
+		{budget: 1, weight: 1, total: 3, want: 5, expect: 1},
+	} {
+		if got := shareGrant(v.budget, v.weight, v.total, v.want); got != v.expect {
+			t.Errorf("shareGrant(%d, %d, %d, %d) = %d, expect %d", v.budget, v.weight, v.total, v.want, got, v.expect)
+		}
+	}
+}
+
+func TestStressContention(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	shareMaxConns = 6
+	defer func() { shareMaxConns = 0 }()
+
+	params := StressParameters{
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL,
+		C:             6,
+		Duration:      1,
+		Timeout:       3000,
+	}
+	var (
+		wg           sync.WaitGroup
+		long, second *StressResult
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p := params
+		p.SequenceId = time.Now().UnixNano()
+		_, long = executeStress(p)
+	}()
+	time.Sleep(200 * time.Millisecond)
+	p := params
+	p.SequenceId, p.Duration, p.N, p.Weight = time.Now().UnixNano(), 0, 40, 2
+	_, second = executeStress(p)
+	wg.Wait()
+
+	if long == nil || len(long.Contention) != 1 || second == nil || len(second.Contention) != 1 {
+		t.Fatalf("contention = %+v and %+v", long, second)
+	}
+	a, b := long.Contention[0], second.Contention[0]
+	if a.Jobs != 2 || a.Weight != 1 || a.C != 6 || a.MinC != 2 || a.Changes < 2 {
+		t.Errorf("contention of -d job = %+v, expect 2 of 6 connections while the other job runs", a)
+	}
+	if b.Jobs != 2 || b.Weight != 2 || b.C != 6 || b.MinC != 4 || b.Changes != 0 {
+		t.Errorf("contention of -n job = %+v, expect 4 of 6 connections", b)
+	}
+	// each connection sends its part of -n, which is kept with fewer connections
+	if total := second.LatsTotal + second.ErrTotal(); total < 40 || total > 40+int64(b.MinC) {
+		t.Errorf("requests of -n job = %d, expect 40 of -n with fewer connections", total)
+	}
+
+	result := &StressResult{Contention: []StressContention{{Generator: "node1", Weight: 1, Jobs: 2, C: 6, MinC: 2, MinQps: 50, Changes: 2}}}
+	var buf bytes.Buffer
+	result.printContention(&buf)
+	for _, line := range []string{"Contention of node1:", "  Jobs:\t2 concurrent at most, weight 1", "  Connections:\t2 of 6 granted at least",
+		"  Qps:\t50 granted at least, unlimited requested", "  Adjusted:\t2 times as jobs came and went"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printContention expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	gourl "net/url"
+	"strings"
+)
+
+const (
+	familyIPv4 = "IPv4"
+	familyIPv6 = "IPv6"
+)
+
+// familyNetworks network to dial of ip family
+var familyNetworks = map[string]string{familyIPv4: "tcp4", familyIPv6: "tcp6"}
+
+// resolveFamilies ip families of -compare-ip-family, the host of url must resolve to both
+func resolveFamilies(url string) ([]string, error) {
+	host := url
+	if u, err := gourl.Parse(url); err == nil && u.Host != "" {
+		host = u.Hostname()
+	} else if h, _, err := net.SplitHostPort(url); err == nil {
+		host = h // address of -p tcp
+	}
+	if strings.Contains(host, "{{") {
+		return nil, fmt.Errorf("host of url is a template: %s", url)
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	var v4, v6 bool
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = true
+		} else {
+			v6 = true
+		}
+	}
+	switch {
+	case !v4:
+		return nil, fmt.Errorf("%s has no IPv4 address", host)
+	case !v6:
+		return nil, fmt.Errorf("%s has no IPv6 address", host)
+	}
+	return []string{familyIPv4, familyIPv6}, nil
+}
+
+// ipFamily ip family of connection id, connections alternate between the families so that both run
+// interleaved under the same load, empty if not comparing
+func (b *StressWorker) ipFamily(id int) string {
+	if len(b.families) <= 0 {
+		return ""
+	}
+	return b.families[id%len(b.families)]
+}
+
+// printFamilies Print latency and errors of IPv4 and IPv6 side by side
+func (result *StressResult) printFamilies(w io.Writer) {
+	fprintln(w, "\nIP family comparison (interleaved connections to the same host):")
+	p50s := make(map[string]float64)
+	p99s := make(map[string]float64)
+	for _, family := range []string{familyIPv4, familyIPv6} {
+		p := result.FamilyDist[family]
+		if p == nil {
+			continue
+		}
+		errRate := float64(p.ErrTotal) * 100 / float64(p.LatsTotal+p.ErrTotal)
+		if p.LatsTotal <= 0 {
+			fprintln(w, "  [%s]\t0 responses, %d failed (%.2f%%)", family, p.ErrTotal, errRate)
+			continue
+		}
+		data := latsPercentiles(p.Lats, p.LatsTotal, []int{50, 90, 99})
+		p50s[family], p99s[family] = data[0], data[2]
+		fprintln(w, "  [%s]\t%d responses, %d failed (%.2f%%), p50 %4.3f secs, p90 %4.3f secs, p99 %4.3f secs",
+			family, p.LatsTotal, p.ErrTotal, errRate, data[0], data[1], data[2])
+	}
+	if p50s[familyIPv4] > 0 && p99s[familyIPv4] > 0 && p50s[familyIPv6] > 0 {
+		fprintln(w, "  IPv6 p50 %.2fx, p99 %.2fx of IPv4", p50s[familyIPv6]/p50s[familyIPv4], p99s[familyIPv6]/p99s[familyIPv4])
+	}
+}
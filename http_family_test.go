@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareIpFamily(t *testing.T) {
+	if _, err := resolveFamilies("http://127.0.0.1:8080/"); err == nil || !strings.Contains(err.Error(), "no IPv6 address") {
+		t.Errorf("resolveFamilies of IPv4 address err = %v", err)
+	}
+	if _, err := resolveFamilies("[::1]:8080"); err == nil || !strings.Contains(err.Error(), "no IPv4 address") {
+		t.Errorf("resolveFamilies of IPv6 address err = %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	b := &StressWorker{RequestParams: &StressParameters{RequestType: typeHttp1, Url: srv.URL}}
+	b.families = []string{familyIPv4, familyIPv6}
+	if b.ipFamily(0) != familyIPv4 || b.ipFamily(1) != familyIPv6 || b.ipFamily(2) != familyIPv4 {
+		t.Errorf("ipFamily of connections = %s, %s, %s", b.ipFamily(0), b.ipFamily(1), b.ipFamily(2))
+	}
+	addr := srv.Listener.Addr().String()
+	conn, err := b.dialContext(0, &net.Dialer{})(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatalf("dial IPv4 connection err: %v", err)
+	}
+	conn.Close()
+	if _, err := b.dialContext(1, &net.Dialer{})(context.Background(), "tcp", addr); err == nil {
+		t.Errorf("dial IPv6 connection to %s expect err", addr)
+	}
+
+	stats := GetStressResult()
+	for i := 0; i < 10; i++ {
+		res := &result{duration: time.Duration(i+1) * time.Millisecond, statusCode: 200, family: b.ipFamily(i)}
+		if i == 9 {
+			res.err = net.ErrClosed
+		}
+		stats.append(res)
+	}
+	v4, v6 := stats.FamilyDist[familyIPv4], stats.FamilyDist[familyIPv6]
+	if v4 == nil || v6 == nil || v4.LatsTotal != 5 || v6.LatsTotal != 4 || v6.ErrTotal != 1 {
+		t.Fatalf("family dist = %+v, %+v", v4, v6)
+	}
+
+	var buf bytes.Buffer
+	stats.printFamilies(&buf)
+	for _, line := range []string{
+		"IP family comparison (interleaved connections to the same host):",
+		"  [IPv4]\t5 responses, 0 failed (0.00%), p50",
+		"  [IPv6]\t4 responses, 1 failed (20.00%), p50",
+		"  IPv6 p50",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printFamilies expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestStressCompareIpFamily(t *testing.T) {
+	ln6, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback not available: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(ln6.Addr().String())
+	ln4, err := net.Listen("tcp4", "127.0.0.1:"+port)
+	if err != nil {
+		ln6.Close()
+		t.Skipf("listen IPv4 port %s: %v", port, err)
+	}
+	url := "http://localhost:" + port + "/"
+	if _, err := resolveFamilies(url); err != nil {
+		ln4.Close()
+		ln6.Close()
+		t.Skipf("localhost is not dual-stack: %v", err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	srv4, srv6 := &http.Server{Handler: handler}, &http.Server{Handler: handler}
+	go srv4.Serve(ln4)
+	go srv6.Serve(ln6)
+	defer srv4.Close()
+	defer srv6.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:      time.Now().UnixNano(),
+		Cmd:             cmdStart,
+		RequestType:     typeHttp1,
+		RequestMethod:   "GET",
+		Url:             url,
+		C:               2,
+		N:               20,
+		Timeout:         3000,
+		CompareIpFamily: true,
+	})
+	if result == nil {
+		t.Fatalf("result of compare ip family is nil")
+	}
+	v4, v6 := result.FamilyDist[familyIPv4], result.FamilyDist[familyIPv6]
+	if v4 == nil || v6 == nil || v4.LatsTotal <= 0 || v6.LatsTotal <= 0 || len(result.ErrorDist) > 0 {
+		t.Errorf("family dist = %+v, %+v, errors: %v", v4, v6, result.ErrorDist)
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// FlowStep request of the -flow file, each connection runs the steps in order as a virtual user, e.g.
+// login, then call the api with the token extracted from the login response
+type FlowStep struct {
+	Name    string   `json:"name"`
+	Method  string   `json:"method"`  // default GET
+	Url     string   `json:"url"`     // template
+	Headers []string `json:"headers"` // "key: value" templates, replace the headers of -H
+	Body    string   `json:"body"`    // template
+	Extract []string `json:"extract"` // "name=template" on the response, e.g. token={{ jsonGet .Body "$.token" }}
+}
+
+// StressFlowStep requests of a step of -flow
+type StressFlowStep struct {
+	Index     int   `json:"index"`      // order of the step, starts from 0
+	Requests  int64 `json:"requests"`   // requests sent
+	Failed    int64 `json:"failed"`     // errors, status >= 400 or extract failed, the virtual user starts over
+	LatsTotal int64 `json:"lats_total"` // sum of latency, scaled by scaleNum
+}
+
+// flowStep step of -flow with parsed templates
+type flowStep struct {
+	name            string
+	index           int
+	method          string
+	url, body       *template.Template
+	header          http.Header
+	headerTemplates []headerTemplate
+	extract         []flowExtract
+}
+
+// flowExtract variable extracted from the response of the step
+type flowExtract struct {
+	name     string
+	template *template.Template
+}
+
+// flowResponse data of extract templates, e.g. {{ jsonGet .Body "$.token" }}, {{ .Header.Get "X-Token" }}
+type flowResponse struct {
+	Status int
+	Header http.Header
+	Body   string
+	Vars   map[string]string // variables extracted by the previous steps
+}
+
+// parseFlow parse the flow file, a json array of steps when the file is .json, otherwise yaml of step names
+// with lists of fields, e.g.
+//
+//	login:
+//	  - method: POST
+//	  - url: http://127.0.0.1/login
+//	  - body: '{"user": "u{{ .WorkerID }}"}'
+//	  - extract: token={{ jsonGet .Body "$.token" }}
+//	profile:
+//	  - url: http://127.0.0.1/profile
+//	  - header: "Authorization: Bearer {{ .Vars.token }}"
+func parseFlow(path string) ([]FlowStep, error) {
+	var steps []FlowStep
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(content, &steps); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	} else {
+		entries, err := parseYamlFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			step := FlowStep{Name: entry.key}
+			for _, value := range entry.values {
+				key, v, ok := strings.Cut(value, ":")
+				if !ok {
+					return nil, fmt.Errorf("%s: %s: expect \"field: value\", got: %s", path, entry.key, value)
+				}
+				switch v = yamlValue(v); strings.TrimSpace(key) {
+				case "method":
+					step.Method = v
+				case "url":
+					step.Url = v
+				case "header":
+					step.Headers = append(step.Headers, v)
+				case "body":
+					step.Body = v
+				case "extract":
+					step.Extract = append(step.Extract, v)
+				default:
+					return nil, fmt.Errorf("%s: %s: unknown field %q", path, entry.key, key)
+				}
+			}
+			steps = append(steps, step)
+		}
+	}
+
+	if len(steps) <= 0 {
+		return nil, fmt.Errorf("%s: no steps", path)
+	}
+	names := make(map[string]bool, len(steps))
+	for i := range steps {
+		step := &steps[i]
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step-%d", i+1)
+		}
+		if names[step.Name] {
+			return nil, fmt.Errorf("%s: duplicate step %s", path, step.Name)
+		}
+		names[step.Name] = true
+		if step.Url == "" {
+			return nil, fmt.Errorf("%s: %s: url is empty", path, step.Name)
+		}
+		if step.Method = strings.ToUpper(step.Method); step.Method == "" {
+			step.Method = "GET"
+		}
+		for _, e := range step.Extract {
+			if name, _, ok := strings.Cut(e, "="); !ok || strings.TrimSpace(name) == "" {
+				return nil, fmt.Errorf("%s: %s: expect extract \"name=template\", got: %s", path, step.Name, e)
+			}
+		}
+	}
+	return steps, nil
+}
+
+// parseFlowSteps parse templates of steps, headers of the step replace the same headers of -H
+func parseFlowSteps(steps []FlowStep, headers http.Header) ([]flowStep, error) {
+	var parsed []flowStep
+	for i, s := range steps {
+		step := flowStep{name: s.Name, index: i, method: s.Method, header: headers.Clone()}
+		if step.header == nil {
+			step.header = make(http.Header)
+		}
+		var err error
+		if step.url, err = template.New("FLOW-URL-" + s.Name).Funcs(fnMap).Parse(s.Url); err != nil {
+			return nil, fmt.Errorf("invalid step %s url template: %v", s.Name, err)
+		}
+		if step.body, err = template.New("FLOW-BODY-" + s.Name).Funcs(fnMap).Parse(s.Body); err != nil {
+			return nil, fmt.Errorf("invalid step %s body template: %v", s.Name, err)
+		}
+		if err = parseHeaders(step.header, s.Headers, true); err != nil {
+			return nil, fmt.Errorf("invalid step %s headers: %v", s.Name, err)
+		}
+		if step.headerTemplates, err = parseHeaderTemplates(step.header); err != nil {
+			return nil, fmt.Errorf("invalid step %s: %v", s.Name, err)
+		}
+		for _, e := range s.Extract {
+			name, value, _ := strings.Cut(e, "=")
+			tpl, err := template.New("FLOW-EXTRACT-" + s.Name).Funcs(fnMap).Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid step %s extract %s: %v", s.Name, name, err)
+			}
+			step.extract = append(step.extract, flowExtract{name: strings.TrimSpace(name), template: tpl})
+		}
+		parsed = append(parsed, step)
+	}
+	return parsed, nil
+}
+
+// validateFlow parse templates of steps and execute the request templates with sample context
+func validateFlow(steps []FlowStep, headers http.Header) []string {
+	parsed, err := parseFlowSteps(steps, headers)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var (
+		errs []string
+		ctx  = *sampleContext
+	)
+	ctx.Vars = make(map[string]string) // variables extracted by any step, a variable never extracted is an error
+	for _, step := range parsed {
+		for _, e := range step.extract {
+			ctx.Vars[e.name] = ""
+		}
+	}
+	for _, step := range parsed {
+		if err := step.url.Execute(io.Discard, &ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid step %s url template: %v", step.name, err))
+		}
+		if err := step.body.Execute(io.Discard, &ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid step %s body template: %v", step.name, err))
+		}
+		for _, ht := range step.headerTemplates {
+			for _, tpl := range ht.values {
+				if err := tpl.Execute(io.Discard, &ctx); err != nil {
+					errs = append(errs, fmt.Sprintf("invalid step %s header %s template: %v", step.name, ht.key, err))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// clone step with templates of per worker functions
+func (step flowStep) clone(funcs template.FuncMap) flowStep {
+	step.url, step.body = cloneTemplate(step.url, funcs), cloneTemplate(step.body, funcs)
+	hts := step.headerTemplates
+	step.headerTemplates = nil
+	for _, ht := range hts {
+		clientHt := headerTemplate{key: ht.key}
+		for _, tpl := range ht.values {
+			clientHt.values = append(clientHt.values, cloneTemplate(tpl, funcs))
+		}
+		step.headerTemplates = append(step.headerTemplates, clientHt)
+	}
+	return step
+}
+
+// doFlow send the next step of the virtual user of the connection, the variables extracted from the
+// response are kept for the following steps, the flow starts over with no variables after the last step
+// or a failed step
+func (b *StressWorker) doFlow(client *StressClient, res *result) {
+	if client.flowPos == 0 {
+		client.vars = make(map[string]string)
+		client.row = b.pickRow(client) // the row is kept by the steps of the iteration
+		if client.httpClient.Jar != nil {
+			client.resetCookies() // a new session of the virtual user
+		}
+	}
+	step := &client.flowSteps[client.flowPos]
+	res.flowStep, res.flowIndex = step.name, step.index
+	client.flowPos = 0 // until the step succeeds
+
+	var ctx = &requestContext{
+		Method:    step.method,
+		WorkerID:  client.id,
+		Iteration: client.iteration,
+		Now:       time.Now(),
+		Vars:      client.vars,
+		Data:      client.row,
+
+		WorkerIndex: b.RequestParams.WorkerIndex,
+		WorkerCount: b.RequestParams.WorkerCount,
+	}
+	if ctx.WorkerCount < 1 {
+		ctx.WorkerCount = 1
+	}
+	client.iteration++
+
+	var urlBytes, bodyBytes bytes.Buffer
+	step.url.Execute(&urlBytes, ctx)
+	ctx.URL = urlBytes.String()
+	step.body.Execute(&bodyBytes, ctx)
+
+	req, reqErr := http.NewRequest(step.method, ctx.URL, bytes.NewReader(bodyBytes.Bytes()))
+	if reqErr != nil {
+		res.err = errors.New("request err: " + reqErr.Error())
+		res.statusCode = -1 // has errors
+		return
+	}
+	req.Header = renderHeaders(step.header, step.headerTemplates, ctx)
+	if !b.RequestParams.DisableCompression && req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	resp, respErr := client.httpClient.Do(req) // the header is rendered per request, so the jar adds cookies to it
+	b.takeRedirects(client, resp, res)
+	if client.httpClient.Jar != nil {
+		client.trackCookies(req, resp, res)
+	}
+	if respErr != nil {
+		res.err = respErr
+		res.statusCode = -99 // has errors
+		return
+	}
+	defer resp.Body.Close()
+	res.statusCode = resp.StatusCode
+
+	var (
+		capture = limitWriter{max: assertBodyLimit}
+		w       io.Writer
+	)
+	if len(step.extract) > 0 {
+		w = &capture
+	}
+	res.contentLength, res.wireLength, res.truncated = readBody(resp, b.RequestParams.MaxBodyRead, w)
+	if resp.StatusCode >= http.StatusBadRequest {
+		return
+	}
+
+	data := &flowResponse{Status: resp.StatusCode, Header: resp.Header, Body: string(capture.buf), Vars: client.vars}
+	var value bytes.Buffer
+	for _, e := range step.extract {
+		value.Reset()
+		if err := e.template.Execute(&value, data); err != nil {
+			res.err = fmt.Errorf("step %s extract %s failed", step.name, e.name)
+			verbosePrint(vDEBUG, "step %s extract %s: %v", step.name, e.name, err)
+			return
+		}
+		client.vars[e.name] = value.String()
+	}
+	client.flowPos = (step.index + 1) % len(client.flowSteps)
+}
+
+func (s *StressFlowStep) append(res *result) {
+	s.Index = res.flowIndex
+	s.Requests++
+	if res.err != nil || res.statusCode < 0 || res.statusCode >= http.StatusBadRequest {
+		s.Failed++
+	}
+	s.LatsTotal += int64(res.duration.Seconds() * scaleNum)
+}
+
+func (s *StressFlowStep) merge(v *StressFlowStep) {
+	s.Index = v.Index
+	s.Requests += v.Requests
+	s.Failed += v.Failed
+	s.LatsTotal += v.LatsTotal
+}
+
+// printFlow Print requests of steps of -flow in order
+func (result *StressResult) printFlow(w io.Writer) {
+	names := make([]string, 0, len(result.FlowDist))
+	for name := range result.FlowDist {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return result.FlowDist[names[i]].Index < result.FlowDist[names[j]].Index })
+
+	fprintln(w, "\nFlow steps:")
+	for _, name := range names {
+		s := result.FlowDist[name]
+		avg := 0.0
+		if s.Requests > 0 {
+			avg = float64(s.LatsTotal) / float64(s.Requests) / scaleNum
+		}
+		fprintln(w, "  [%d] %s\t%d requests, %4.4f secs average, %d failed", s.Index+1, name, s.Requests, avg, s.Failed)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFlow(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "flow.yaml")
+	os.WriteFile(yamlFile, []byte(`# login then call the api
+login:
+  - method: post
+  - url: http://127.0.0.1/login
+  - header: "Content-Type: application/json"
+  - body: '{"user": "u{{ .WorkerID }}"}'
+  - extract: token={{ jsonGet .Body "$.token" }}
+profile:
+  - url: http://127.0.0.1/profile
+  - header: "Authorization: Bearer {{ .Vars.token }}"
+`), 0644)
+	steps, err := parseFlow(yamlFile)
+	if err != nil {
+		t.Fatalf("parseFlow err: %v", err)
+	}
+	if len(steps) != 2 || steps[0].Name != "login" || steps[0].Method != "POST" || steps[0].Body != `{"user": "u{{ .WorkerID }}"}` ||
+		steps[0].Extract[0] != `token={{ jsonGet .Body "$.token" }}` || steps[1].Method != "GET" ||
+		steps[1].Headers[0] != "Authorization: Bearer {{ .Vars.token }}" {
+		t.Errorf("parseFlow = %+v", steps)
+	}
+
+	jsonFile := filepath.Join(dir, "flow.json")
+	os.WriteFile(jsonFile, []byte(`[{"url": "http://127.0.0.1/a"}, {"name": "b", "url": "http://127.0.0.1/b"}]`), 0644)
+	if steps, err = parseFlow(jsonFile); err != nil || len(steps) != 2 || steps[0].Name != "step-1" || steps[1].Name != "b" {
+		t.Errorf("parseFlow json = %+v, err: %v", steps, err)
+	}
+
+	for content, expect := range map[string]string{
+		"a:\n  - url: http://127.0.0.1/\n  - timeout: 1s\n":                `unknown field "timeout"`,
+		"a:\n  - method: GET\n":                                            "url is empty",
+		"a:\n  - url: http://127.0.0.1/\n  - extract: {{ .Body }}\n":       "expect extract",
+		"a:\n  - url: http://127.0.0.1/\na:\n  - url: http://127.0.0.1/\n": "duplicate step a",
+	} {
+		os.WriteFile(yamlFile, []byte(content), 0644)
+		if _, err := parseFlow(yamlFile); err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("parseFlow(%q) err = %v, expect: %s", content, err, expect)
+		}
+	}
+
+	if errs := validateFlow([]FlowStep{
+		{Name: "a", Url: "http://127.0.0.1/", Extract: []string{"token={{ .Body }}"}},
+		{Name: "b", Url: "http://127.0.0.1/{{ escape .Vars.token }}", Body: "{{ escape .Vars.missing }}"},
+	}, nil); len(errs) != 1 || !strings.Contains(errs[0], "invalid step b body template") {
+		t.Errorf("validateFlow errs = %v, expect the variable never extracted", errs)
+	}
+}
+
+func TestFlow(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"token": "t-` + r.URL.Query().Get("user") + `"}}`))
+	})
+	mux.HandleFunc("/profile", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t-u0" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL + "/login",
+		C:             1,
+		N:             6,
+		Timeout:       3000,
+		Flow: []FlowStep{
+			{Name: "login", Method: "POST", Url: srv.URL + "/login?user=u{{ .WorkerID }}",
+				Extract: []string{`token={{ jsonGet .Body "$.data.token" }}`}},
+			{Name: "profile", Method: "GET", Url: srv.URL + "/profile",
+				Headers: []string{"Authorization: Bearer {{ .Vars.token }}"}},
+		},
+	})
+	if result == nil {
+		t.Fatal("result of flow is nil")
+	}
+	login, profile := result.FlowDist["login"], result.FlowDist["profile"]
+	if login == nil || profile == nil || login.Requests < 3 || profile.Requests < 2 ||
+		login.Failed != 0 || profile.Failed != 0 || profile.Index != 1 {
+		t.Errorf("flow dist = %+v, %+v", login, profile)
+	}
+
+	var buf bytes.Buffer
+	result.printFlow(&buf)
+	if lines := strings.Split(buf.String(), "\n"); len(lines) < 4 || !strings.HasPrefix(lines[2], "  [1] login\t") ||
+		!strings.HasPrefix(lines[3], "  [2] profile\t") {
+		t.Errorf("printFlow steps not in order:\n%s", buf.String())
+	}
+}
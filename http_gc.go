@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	gcTuningAuto = "auto"
+	gcTuningOff  = "off"
+
+	gcBallastPerConn   = 256 << 10 // heap ballast per connection
+	gcBallastMin       = 64 << 20
+	gcBallastMax       = 1 << 30
+	gcHighRps          = 10000 // expected rps considered high throughput, -q 0 is unlimited
+	gcPercentHigh      = 400
+	gcPercentDefault   = 200
+	gcMemoryLimitScale = 8        // soft memory limit of the ballast, guards the raised GOGC from OOM
+	gcMemoryLimitMin   = 2 << 30  // min soft memory limit
+	gcPauseNoticeRatio = 0.01     // gc pause of the run duration printed without -gc-tuning
+	gcPauseNoticeMin   = 10 * 1e6 // ns, min gc pause printed without -gc-tuning
+)
+
+// StressGC garbage collection of the load generator during the run
+type StressGC struct {
+	Tuning      string `json:"tuning"`       // -gc-tuning, empty is not tuned
+	Percent     int    `json:"percent"`      // GOGC of the run
+	Ballast     int64  `json:"ballast"`      // bytes of heap ballast
+	MemoryLimit int64  `json:"memory_limit"` // soft memory limit in bytes, 0 is not set
+	Count       int64  `json:"count"`        // gc cycles during the run
+	PauseTotal  int64  `json:"pause_total"`  // ns of stop-the-world pauses during the run
+}
+
+// gcTuner GOGC, ballast and memory limit are process wide, runs overlapping on one worker share the
+// tuning of the first run, the previous settings are restored when the last run finishes
+var gcTuner struct {
+	sync.Mutex
+	runs                   int
+	ballast                []byte
+	percent                int
+	prevPercent            int
+	memoryLimit, prevLimit int64
+}
+
+// autoGCTuning GOGC, ballast and memory limit of -gc-tuning auto, the ballast grows with connections as
+// the heap of in-flight requests does, and high or unlimited rps raises GOGC to fewer cycles
+func autoGCTuning(c, qps int) (percent int, ballast, memoryLimit int64) {
+	ballast = int64(c) * gcBallastPerConn
+	if ballast < gcBallastMin {
+		ballast = gcBallastMin
+	}
+	if ballast > gcBallastMax {
+		ballast = gcBallastMax
+	}
+	percent = gcPercentDefault
+	if qps <= 0 || qps >= gcHighRps {
+		percent = gcPercentHigh
+	}
+	memoryLimit = ballast * gcMemoryLimitScale
+	if memoryLimit < gcMemoryLimitMin {
+		memoryLimit = gcMemoryLimitMin
+	}
+	return
+}
+
+// tuneGC apply -gc-tuning of the run, the returned stats are collected by finish
+func (b *StressWorker) tuneGC() *StressGC {
+	gc := &StressGC{Tuning: b.RequestParams.GcTuning}
+	if gc.Tuning == gcTuningAuto {
+		gcTuner.Lock()
+		if gcTuner.runs == 0 {
+			percent, ballast, memoryLimit := autoGCTuning(b.RequestParams.C, b.RequestParams.Qps)
+			gcTuner.ballast = make([]byte, ballast)
+			gcTuner.percent, gcTuner.memoryLimit = percent, memoryLimit
+			gcTuner.prevPercent = debug.SetGCPercent(percent)
+			if prev, ok := setMemoryLimit(memoryLimit); ok {
+				gcTuner.prevLimit = prev
+			} else {
+				gcTuner.memoryLimit = 0
+			}
+		}
+		gcTuner.runs++
+		gc.Percent, gc.Ballast, gc.MemoryLimit = gcTuner.percent, int64(len(gcTuner.ballast)), gcTuner.memoryLimit
+		gcTuner.Unlock()
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	gc.Count, gc.PauseTotal = -int64(mem.NumGC), -int64(mem.PauseTotalNs)
+	return gc
+}
+
+// finish count gc cycles and pauses since tuneGC, and restore the settings after the last tuned run
+func (gc *StressGC) finish() {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	gc.Count += int64(mem.NumGC)
+	gc.PauseTotal += int64(mem.PauseTotalNs)
+
+	if gc.Tuning != gcTuningAuto {
+		return
+	}
+	gcTuner.Lock()
+	defer gcTuner.Unlock()
+	if gcTuner.runs--; gcTuner.runs == 0 {
+		debug.SetGCPercent(gcTuner.prevPercent)
+		if gcTuner.memoryLimit > 0 {
+			setMemoryLimit(gcTuner.prevLimit)
+		}
+		gcTuner.ballast = nil
+	}
+}
+
+func (gc *StressGC) merge(v *StressGC) {
+	if gc.Tuning == "" {
+		gc.Tuning, gc.Percent, gc.Ballast, gc.MemoryLimit = v.Tuning, v.Percent, v.Ballast, v.MemoryLimit
+	}
+	gc.Count += v.Count
+	gc.PauseTotal += v.PauseTotal
+}
+
+// notable gc is tuned or the pauses are long enough to interfere with the measured latencies
+func (gc *StressGC) notable(duration int64) bool {
+	return gc.Tuning != "" ||
+		(gc.PauseTotal >= gcPauseNoticeMin && float64(gc.PauseTotal) >= float64(duration)*1e9*gcPauseNoticeRatio)
+}
+
+func (result *StressResult) printGC(w io.Writer) {
+	gc := result.GC
+	fprintln(w, "\nGenerator GC:")
+	if gc.Tuning != "" {
+		fprintln(w, "  Tuning:\t%s, GOGC %d, ballast %s, memory limit %s", gc.Tuning, gc.Percent,
+			toByteSizeStr(float64(gc.Ballast)), toByteSizeStr(float64(gc.MemoryLimit)))
+	}
+	fprintln(w, "  Cycles:\t%d", gc.Count)
+	fprintln(w, "  Pause total:\t%4.4f secs", time.Duration(gc.PauseTotal).Seconds())
+}
+
+// checkGCTuning validate -gc-tuning
+func checkGCTuning(s string) error {
+	switch s {
+	case "", gcTuningOff, gcTuningAuto:
+		return nil
+	}
+	return fmt.Errorf("invalid -gc-tuning %q, expect auto or off", s)
+}
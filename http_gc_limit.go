@@ -0,0 +1,10 @@
+//go:build go1.19
+
+package main
+
+import "runtime/debug"
+
+// setMemoryLimit set the soft memory limit of the runtime, return the previous limit
+func setMemoryLimit(limit int64) (int64, bool) {
+	return debug.SetMemoryLimit(limit), true
+}
@@ -0,0 +1,8 @@
+//go:build !go1.19
+
+package main
+
+// setMemoryLimit isn't support before go 1.19, the memory limit is not set
+func setMemoryLimit(limit int64) (int64, bool) {
+	return 0, false
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAutoGCTuning(t *testing.T) {
+	for _, v := range []struct {
+		c, qps               int
+		percent              int
+		ballast, memoryLimit int64
+	}{
+		{1, 0, gcPercentHigh, gcBallastMin, gcMemoryLimitMin},
+		{1000, 100, gcPercentDefault, 1000 * gcBallastPerConn, gcMemoryLimitMin},
+		{2000, 20000, gcPercentHigh, 2000 * gcBallastPerConn, 2000 * gcBallastPerConn * gcMemoryLimitScale},
+		{100000, 0, gcPercentHigh, gcBallastMax, gcBallastMax * gcMemoryLimitScale},
+	} {
+		percent, ballast, memoryLimit := autoGCTuning(v.c, v.qps)
+		if percent != v.percent || ballast != v.ballast || memoryLimit != v.memoryLimit {
+			t.Errorf("autoGCTuning(%d, %d) = %d, %d, %d, expect: %d, %d, %d", v.c, v.qps,
+				percent, ballast, memoryLimit, v.percent, v.ballast, v.memoryLimit)
+		}
+	}
+	if checkGCTuning("auto") != nil || checkGCTuning("off") != nil || checkGCTuning("fast") == nil {
+		t.Errorf("checkGCTuning of auto, off and fast")
+	}
+}
+
+func TestStressGCTuning(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	prevPercent := debug.SetGCPercent(100)
+	defer debug.SetGCPercent(prevPercent)
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL,
+		C:             2,
+		N:             20,
+		Timeout:       3000,
+		GcTuning:      gcTuningAuto,
+	})
+	if result == nil || result.GC == nil {
+		t.Fatalf("result of gc tuning = %+v", result)
+	}
+	gc := result.GC
+	if gc.Tuning != gcTuningAuto || gc.Percent != gcPercentHigh || gc.Ballast != gcBallastMin || gc.Count < 0 || gc.PauseTotal < 0 {
+		t.Errorf("gc = %+v", gc)
+	}
+	if percent := debug.SetGCPercent(100); percent != 100 {
+		t.Errorf("GOGC after the run = %d, expect restored 100", percent)
+	}
+	if gcTuner.runs != 0 || gcTuner.ballast != nil {
+		t.Errorf("ballast is not released after the run")
+	}
+
+	var buf bytes.Buffer
+	result.write(&buf, "")
+	if !strings.Contains(buf.String(), "Generator GC:") || !strings.Contains(buf.String(), "GOGC 400") {
+		t.Errorf("summary of gc tuning:\n%s", buf.String())
+	}
+}
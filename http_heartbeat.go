@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	failureContinue = "continue" // merge the partial result of a dead worker and wait for the others
+	failureAbort    = "abort"    // stop the other workers when a worker dies, and fail the run
+
+	heartbeatMisses = 3 // heartbeats missed in a row of a dead worker
+)
+
+var (
+	heartbeatEvery = 5 * time.Second                    // interval of heartbeats to workers, 0 disables
+	failurePolicy  = failureContinue                    // -worker-failure-policy
+	deadWorkers    = make(map[int64][]StressDeadWorker) // dead workers of the running sequence ids
+	deadMu         sync.Mutex
+)
+
+// StressDeadWorker worker lost during the run, by the failed start command or missed heartbeats
+type StressDeadWorker struct {
+	Worker   string `json:"worker"`   // address with the password hidden
+	Error    string `json:"error"`    // why the worker is dead
+	Partial  bool   `json:"partial"`  // the metrics of the last heartbeat are merged as its result
+	Requests int64  `json:"requests"` // requests of the partial result
+}
+
+// workerHeartbeat heartbeats of a worker running the start command, the metrics of each heartbeat
+// are kept as the partial result if the worker dies
+type workerHeartbeat struct {
+	worker string
+	body   []byte             // metrics command of the sequence id
+	cancel context.CancelFunc // cancel the start command when the worker is dead
+	stop   chan struct{}
+	done   chan struct{}
+	mu     sync.Mutex
+	last   *StressResult // metrics of the last heartbeat
+	err    error         // set when heartbeatMisses are missed in a row
+}
+
+// startHeartbeat send metrics command of params to the worker every heartbeatEvery, and cancel the
+// start command after heartbeatMisses in a row
+func startHeartbeat(worker string, params StressParameters, cancel context.CancelFunc) *workerHeartbeat {
+	params.Cmd = cmdMetrics
+	body, _ := json.Marshal(params)
+	h := &workerHeartbeat{worker: worker, body: body, cancel: cancel, stop: make(chan struct{}), done: make(chan struct{})}
+	go h.run()
+	return h
+}
+
+func (h *workerHeartbeat) run() {
+	defer close(h.done)
+	ticker := time.NewTicker(heartbeatEvery)
+	defer ticker.Stop()
+	var misses int
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), heartbeatEvery)
+		result, err := executeWorkerReqContext(ctx, workerUrl(h.worker, httpWorkerApiPath), h.body)
+		cancel()
+		if err == nil && result.ErrCode == 0 {
+			h.mu.Lock()
+			h.last, misses = result, 0
+			h.mu.Unlock()
+			continue
+		}
+		if misses++; misses >= heartbeatMisses {
+			h.mu.Lock()
+			h.err = fmt.Errorf("%d heartbeats missed, last error: %v", misses, err)
+			h.mu.Unlock()
+			h.cancel()
+			return
+		}
+	}
+}
+
+// finish stop the heartbeats, and the dead worker of the failed start command with the partial result
+// of the last heartbeat, nil if the worker is alive
+func (h *workerHeartbeat) finish(startErr error) (*StressDeadWorker, *StressResult) {
+	close(h.stop)
+	<-h.done
+	if startErr == nil {
+		return nil, nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	dead := &StressDeadWorker{Worker: redactWorker(h.worker), Error: startErr.Error()}
+	if h.err != nil {
+		dead.Error = h.err.Error()
+	}
+	if h.last != nil {
+		dead.Partial, dead.Requests = true, h.last.LatsTotal+h.last.ErrTotal()
+	}
+	return dead, h.last
+}
+
+// markDeadWorker record the dead worker of the sequence id, merged into the result of the run
+func markDeadWorker(seqId int64, dead StressDeadWorker) {
+	verbosePrint(vERROR, "worker %s is dead: %s", dead.Worker, dead.Error)
+	deadMu.Lock()
+	deadWorkers[seqId] = append(deadWorkers[seqId], dead)
+	deadMu.Unlock()
+}
+
+// takeDeadWorkers dead workers of the sequence id, which are forgotten
+func takeDeadWorkers(seqId int64) []StressDeadWorker {
+	deadMu.Lock()
+	defer deadMu.Unlock()
+	dead := deadWorkers[seqId]
+	delete(deadWorkers, seqId)
+	return dead
+}
+
+// stopWorkers send the stop command of params to the workers, for -worker-failure-policy abort
+func stopWorkers(params StressParameters) {
+	params.Cmd = cmdStop
+	body, _ := json.Marshal(params)
+	var wg sync.WaitGroup
+	for _, v := range workerList {
+		wg.Add(1)
+		go func(uri string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			executeWorkerReqContext(ctx, uri, body)
+		}(workerUrl(v, httpWorkerApiPath))
+	}
+	wg.Wait()
+}
+
+// printDeadWorkers Print the workers lost during the run
+func (result *StressResult) printDeadWorkers(w io.Writer) {
+	fprintln(w, "\nDead workers:")
+	for _, dead := range result.DeadWorkers {
+		if dead.Partial {
+			fprintln(w, "  %s\t%s, partial result of %d requests", dead.Worker, dead.Error, dead.Requests)
+		} else {
+			fprintln(w, "  %s\t%s, no result", dead.Worker, dead.Error)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeWorker worker which replies start with 10 requests after the delay or a stop command, and
+// dies after the first heartbeat if dying
+func fakeWorker(delay time.Duration, dying bool) (*httptest.Server, *int64) {
+	var heartbeats int64
+	stopped := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var params StressParameters
+		json.Unmarshal(body, &params)
+		switch params.Cmd {
+		case cmdStart:
+			select {
+			case <-time.After(delay):
+			case <-stopped:
+			case <-r.Context().Done():
+				return
+			}
+			b, _ := (&StressResult{LatsTotal: 10, Lats: map[string]int64{"0.001": 10}}).marshal()
+			w.Write(b)
+		case cmdStop:
+			close(stopped)
+		case cmdMetrics:
+			if atomic.AddInt64(&heartbeats, 1) > 1 && dying {
+				<-r.Context().Done() // unreachable
+				return
+			}
+			b, _ := (&StressResult{LatsTotal: 5, Lats: map[string]int64{"0.001": 5}}).marshal()
+			w.Write(b)
+		}
+	}))
+	return srv, &heartbeats
+}
+
+func TestWorkerHeartbeat(t *testing.T) {
+	savedWorkers, savedEvery, savedPolicy := workerList, heartbeatEvery, failurePolicy
+	defer func() { workerList, heartbeatEvery, failurePolicy = savedWorkers, savedEvery, savedPolicy }()
+	heartbeatEvery = 50 * time.Millisecond
+
+	for _, policy := range []string{failureContinue, failureAbort} {
+		failurePolicy = policy
+		// the healthy worker finishes by itself, or is stopped by the abort
+		delay := 500 * time.Millisecond
+		if policy == failureAbort {
+			delay = time.Hour
+		}
+		dying, _ := fakeWorker(time.Hour, true)
+		healthy, heartbeats := fakeWorker(delay, false)
+		workerList = flagSlice{dying.URL, healthy.URL}
+
+		start := time.Now()
+		_, result := executeStress(StressParameters{SequenceId: time.Now().UnixNano(), Cmd: cmdStart, C: 1, N: 10})
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%s: run of dead worker took %v", policy, elapsed)
+		}
+		if result == nil || len(result.DeadWorkers) != 1 {
+			t.Fatalf("%s: result = %+v", policy, result)
+		}
+		dead := result.DeadWorkers[0]
+		if dead.Worker != dying.URL || !dead.Partial || dead.Requests != 5 || !strings.Contains(dead.Error, "3 heartbeats missed") {
+			t.Errorf("%s: dead worker = %+v", policy, dead)
+		}
+		// the partial result of the dead worker is merged
+		if result.LatsTotal != 15 || atomic.LoadInt64(heartbeats) <= 0 {
+			t.Errorf("%s: requests = %d, heartbeats of healthy worker %d", policy, result.LatsTotal, atomic.LoadInt64(heartbeats))
+		}
+		if expect := map[string]int{failureContinue: exitOK, failureAbort: exitInternal}[policy]; result.exitCode() != expect {
+			t.Errorf("%s: exit code = %d, expect %d, %s", policy, result.exitCode(), expect, result.ErrMsg)
+		}
+		dying.Close()
+		healthy.Close()
+	}
+
+	var buf bytes.Buffer
+	(&StressResult{DeadWorkers: []StressDeadWorker{{Worker: "10.0.0.1:12710", Error: "EOF", Partial: true, Requests: 5},
+		{Worker: "10.0.0.2:12710", Error: "connection refused"}}}).printDeadWorkers(&buf)
+	for _, line := range []string{"Dead workers:", "  10.0.0.1:12710\tEOF, partial result of 5 requests",
+		"  10.0.0.2:12710\tconnection refused, no result"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printDeadWorkers expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLatsDigits = 3  // significant digits of latency buckets, 0.1% precision
+	maxLatsDigits     = 5  // finer buckets do not add precision over the clock
+	histogramBuckets  = 10 // bars of the histogram in the summary
+	histogramWidth    = 40 // chars of the longest bar
+	histogramBar      = "∎"
+)
+
+// latsKey key of the latency bucket of d in the Lats distribution, d is rounded to the significant digits of
+// microseconds as HDR histogram, so buckets are bounded per order of magnitude and percentiles of fast requests
+// keep their precision. Keys are secs with at least 3 decimals, the same as the former millisecond keys.
+func latsKey(d time.Duration, digits int) string {
+	if digits <= 0 {
+		digits = defaultLatsDigits
+	}
+	us, limit := d.Microseconds(), int64(1)
+	for i := 0; i < digits; i++ {
+		limit *= 10
+	}
+	if us >= limit {
+		unit := int64(1)
+		for us/unit >= limit {
+			unit *= 10
+		}
+		us = (us + unit/2) / unit * unit
+	}
+
+	key := fmt.Sprintf("%d.%06d", us/1000000, us%1000000)
+	for strings.HasSuffix(key, "0") && len(key)-strings.Index(key, ".") > 4 {
+		key = key[:len(key)-1]
+	}
+	return key
+}
+
+// printHistogram Print counts of successful requests in linear buckets between the fastest and slowest
+func (result *StressResult) printHistogram(w io.Writer) {
+	fastest, slowest := float64(result.Fastest)/scaleNum, float64(result.Slowest)/scaleNum
+	if result.LatsTotal <= 0 || slowest < fastest {
+		return
+	}
+
+	bounds := make([]float64, histogramBuckets+1)
+	for i := range bounds {
+		bounds[i] = fastest + (slowest-fastest)*float64(i)/histogramBuckets
+	}
+	counts := make([]int64, len(bounds))
+	for lats, c := range result.Lats {
+		v, err := strconv.ParseFloat(lats, 64)
+		if err != nil {
+			continue
+		}
+		if i := sort.SearchFloat64s(bounds, v); i < len(bounds) {
+			counts[i] += c
+		} else {
+			counts[len(bounds)-1] += c // rounded up over the slowest
+		}
+	}
+	var max int64
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+	}
+
+	format := "  %4.3f [%d]\t|%s"
+	if slowest > fastest && slowest-fastest < histogramBuckets*0.001 {
+		format = "  %4.4f [%d]\t|%s" // sub-millisecond buckets
+	}
+	fprintln(w, "\nResponse time histogram:")
+	for i, c := range counts {
+		if i > 0 && bounds[i] == bounds[i-1] {
+			continue // all requests in one bucket
+		}
+		fprintln(w, format, bounds[i], c, strings.Repeat(histogramBar, int(c*histogramWidth/max)))
+	}
+}
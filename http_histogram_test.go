@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatsKey(t *testing.T) {
+	for _, tt := range []struct {
+		d      time.Duration
+		digits int
+		key    string
+	}{
+		{10 * time.Millisecond, 3, "0.010"},
+		{123 * time.Microsecond, 3, "0.000123"},
+		{850 * time.Microsecond, 0, "0.00085"},
+		{1234567 * time.Microsecond, 3, "1.230"},
+		{1234567 * time.Microsecond, 5, "1.2346"},
+		{1234567 * time.Microsecond, 1, "1.000"},
+		{9996 * time.Microsecond, 3, "0.010"},
+		{12 * time.Second, 2, "12.000"},
+		{0, 3, "0.000"},
+	} {
+		if key := latsKey(tt.d, tt.digits); key != tt.key {
+			t.Errorf("latsKey(%s, %d) = %s, expect: %s", tt.d, tt.digits, key, tt.key)
+		}
+	}
+
+	stats := GetStressResult()
+	for i := 0; i < 10000; i++ {
+		stats.append(&result{statusCode: 200, duration: time.Duration(i) * time.Millisecond, start: time.Unix(0, 0)})
+	}
+	if len(stats.Lats) > 3*900+10 {
+		t.Errorf("buckets of 3 digits = %d, expect at most 900 per order of magnitude", len(stats.Lats))
+	}
+	if p := stats.Percentile(50); p < 4.99 || p > 5.01 {
+		t.Errorf("p50 = %f, expect 5 secs", p)
+	}
+}
+
+func TestPrintHistogram(t *testing.T) {
+	stats := GetStressResult()
+	for _, d := range []time.Duration{10, 10, 10, 10, 20, 100} {
+		stats.append(&result{statusCode: 200, duration: d * time.Millisecond, start: time.Unix(0, 0)})
+	}
+
+	var buf bytes.Buffer
+	stats.printHistogram(&buf)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != histogramBuckets+2 || lines[0] != "Response time histogram:" {
+		t.Fatalf("histogram:\n%s", buf.String())
+	}
+	if expect := "  0.010 [4]\t|" + strings.Repeat(histogramBar, histogramWidth); lines[1] != expect {
+		t.Errorf("fastest bucket = %q, expect: %q", lines[1], expect)
+	}
+	if expect := "  0.028 [1]\t|" + strings.Repeat(histogramBar, histogramWidth/4); lines[3] != expect {
+		t.Errorf("0.020 in bucket = %q, expect: %q", lines[3], expect)
+	}
+	if expect := "  0.100 [1]\t|" + strings.Repeat(histogramBar, histogramWidth/4); lines[11] != expect {
+		t.Errorf("slowest bucket = %q, expect: %q", lines[11], expect)
+	}
+
+	buf.Reset()
+	single := GetStressResult()
+	single.append(&result{statusCode: 200, duration: 5 * time.Millisecond, start: time.Unix(0, 0)})
+	single.printHistogram(&buf)
+	if out := buf.String(); strings.Count(out, "\n  ") != 1 || !strings.Contains(out, "0.005 [1]") {
+		t.Errorf("histogram of one latency:\n%s", out)
+	}
+}
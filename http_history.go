@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const historyLimit = 100 // runs listed by default
+
+var (
+	historyDb      *bolt.DB // finished runs of -history-db, nil if disabled
+	historyRuns    = []byte("runs")
+	historySummary = []byte("jobs")
+)
+
+// historyRun finished run persisted in -history-db
+type historyRun struct {
+	SequenceId int64         `json:"sequence_id"`
+	Url        string        `json:"url"`
+	StartTime  time.Time     `json:"start_time"`
+	FinishTime time.Time     `json:"finish_time"`
+	Result     *StressResult `json:"result"`
+}
+
+// historyKey sequence id in big endian, so that the runs are ordered by sequence id
+func historyKey(seqId int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(seqId))
+	return key
+}
+
+// openHistory open or create the bolt file of -history-db, which is locked by one process
+func openHistory(file string) error {
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("open -history-db %s: %v", file, err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(historyRuns); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(historySummary)
+		return err
+	}); err != nil {
+		db.Close()
+		return fmt.Errorf("open -history-db %s: %v", file, err)
+	}
+	historyDb = db
+	return nil
+}
+
+// saveHistory persist the finished run, and the summary listed without reading the result
+func saveHistory(run historyRun) {
+	if historyDb == nil {
+		return
+	}
+	resultRdMutex.RLock()
+	body, err := json.Marshal(run)
+	resultRdMutex.RUnlock()
+	if err == nil {
+		summary, _ := json.Marshal(newStressJob(run.SequenceId, jobFinished, run.Url, run.StartTime, run.FinishTime, run.Result))
+		err = historyDb.Update(func(tx *bolt.Tx) error {
+			if err := tx.Bucket(historyRuns).Put(historyKey(run.SequenceId), body); err != nil {
+				return err
+			}
+			return tx.Bucket(historySummary).Put(historyKey(run.SequenceId), summary)
+		})
+	}
+	if err != nil {
+		verbosePrint(vERROR, "save -history-db sequence id %d: %v", run.SequenceId, err)
+	}
+}
+
+// loadHistory the persisted run of sequence id
+func loadHistory(seqId int64) (*historyRun, error) {
+	if historyDb == nil {
+		return nil, fmt.Errorf("-history-db is disabled")
+	}
+	var run historyRun
+	err := historyDb.View(func(tx *bolt.Tx) error {
+		body := tx.Bucket(historyRuns).Get(historyKey(seqId))
+		if body == nil {
+			return fmt.Errorf("sequence id %d not found", seqId)
+		}
+		return json.Unmarshal(body, &run)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// listHistory summaries of the latest limit runs, newest first
+func listHistory(limit int) ([]stressJob, error) {
+	jobs := make([]stressJob, 0)
+	if historyDb == nil {
+		return jobs, nil
+	}
+	err := historyDb.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(historySummary).Cursor()
+		for k, v := c.Last(); k != nil && len(jobs) < limit; k, v = c.Prev() {
+			var job stressJob
+			if err := json.Unmarshal(v, &job); err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+// serveHistory GET /api/history?limit=N lists the persisted runs, /api/history/{sequence id} is the
+// html report of a run, "?output=json" for the result in json, and /api/history/compare?base=A&target=B
+// compares two runs by the markdown table of -baseline
+func serveHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "GET":
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if historyDb == nil {
+		http.Error(w, "-history-db is disabled", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	switch id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, httpWorkerHistoryPath), "/"); id {
+	case "":
+		limit := historyLimit
+		if n, err := strconv.Atoi(query.Get("limit")); err == nil && n > 0 {
+			limit = n
+		}
+		jobs, err := listHistory(limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		wbody, _ := json.Marshal(jobs)
+		w.Header().Set("Content-Type", httpContentTypeJSON)
+		w.Write(wbody)
+	case "compare":
+		var runs [2]*historyRun
+		for i, key := range []string{"base", "target"} {
+			seqId, err := strconv.ParseInt(query.Get(key), 10, 64)
+			if err != nil {
+				http.Error(w, "invalid "+key+", expect the sequence id", http.StatusBadRequest)
+				return
+			}
+			if runs[i], err = loadHistory(seqId); err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		fmt.Fprintf(w, "Sequence id %d (%s) compared with baseline %d (%s)\n\n", runs[1].SequenceId,
+			runs[1].FinishTime.Format(time.RFC3339), runs[0].SequenceId, runs[0].FinishTime.Format(time.RFC3339))
+		runs[1].Result.printMarkdown(w, runs[0].Result)
+	default:
+		seqId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		run, err := loadHistory(seqId)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if query.Get("output") == outputJson {
+			wbody, _ := json.Marshal(run)
+			w.Header().Set("Content-Type", httpContentTypeJSON)
+			w.Write(wbody)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := run.Result.printHtml(w); err != nil {
+			verbosePrint(vERROR, "history report of sequence id %d: %v", seqId, err)
+		}
+	}
+}
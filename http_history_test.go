@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	if err := openHistory(filepath.Join(t.TempDir(), "history.db")); err != nil {
+		t.Fatalf("openHistory err: %v", err)
+	}
+	defer func() {
+		historyDb.Close()
+		historyDb = nil
+	}()
+
+	base := time.Now().UnixNano()
+	for i, rps := range []int64{100, 150} {
+		result := GetStressResult()
+		result.LatsTotal, result.Rps, result.Lats = 10, rps*scaleNum, map[string]int64{"0.010": 10}
+		result.StatusCodeDist[200] = 10
+		storeCollectResult(&StressWorker{RequestParams: &StressParameters{SequenceId: base + int64(i),
+			Url: "http://127.0.0.1/"}, startTime: time.Now()}, result)
+	}
+
+	jobs, err := listHistory(1)
+	if err != nil || len(jobs) != 1 || jobs[0].SequenceId != base+1 || jobs[0].Rps != 150 || jobs[0].State != jobFinished {
+		t.Errorf("listHistory = %+v, %v, expect the newest", jobs, err)
+	}
+
+	// the expired results are collected from the history
+	collectResults.Delete(base)
+	if result := collectStress(StressParameters{SequenceId: base}); result.ErrCode != 0 || result.LatsTotal != 10 {
+		t.Errorf("collect of expired result = %+v", result)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(serveHistory))
+	defer srv.Close()
+	get := func(path string) (int, string) {
+		resp, err := http.Get(srv.URL + httpWorkerHistoryPath + path)
+		if err != nil {
+			t.Fatalf("get %s err: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if code, body := get("?limit=10"); code != http.StatusOK {
+		t.Errorf("list history = %d", code)
+	} else if json.Unmarshal([]byte(body), &jobs); len(jobs) != 2 || jobs[0].SequenceId != base+1 {
+		t.Errorf("list history = %s", body)
+	}
+	if code, body := get(fmt.Sprintf("/%d", base)); code != http.StatusOK || !strings.Contains(body, "<html") {
+		t.Errorf("history report = %d, %s", code, body)
+	}
+	var run historyRun
+	if code, body := get(fmt.Sprintf("/%d?output=json", base)); code != http.StatusOK ||
+		json.Unmarshal([]byte(body), &run) != nil || run.Url != "http://127.0.0.1/" || run.Result.LatsTotal != 10 {
+		t.Errorf("history json = %d, %s", code, body)
+	}
+	if code, body := get(fmt.Sprintf("/compare?base=%d&target=%d", base, base+1)); code != http.StatusOK ||
+		!strings.Contains(body, "| Metric | Baseline | Value | Change | |") || !strings.Contains(body, "+50.0%") {
+		t.Errorf("history compare = %d, %s", code, body)
+	}
+	for _, path := range []string{"/1", "/compare?base=x&target=1", "/x"} {
+		if code, _ := get(path); code == http.StatusOK {
+			t.Errorf("history %s expect error", path)
+		}
+	}
+}
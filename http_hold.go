@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	gourl "net/url"
+	"sync"
+	"time"
+)
+
+const holdDialers = 64 // connections of -hold-connections dialed at the same time
+
+// StressHold idle connections of -hold-connections, which are held without requests alongside the load
+type StressHold struct {
+	Target      int64  `json:"target"`       // connections to hold
+	Opened      int64  `json:"opened"`       // connections opened
+	Failed      int64  `json:"failed"`       // dial or tls handshake failed
+	Peak        int64  `json:"peak"`         // max connections held at the same time, summed over workers
+	Closed      int64  `json:"closed"`       // connections closed by server while held
+	ClosedAfter int64  `json:"closed_after"` // sum of time held before closed by server in ms
+	LastErr     string `json:"last_err"`     // reason of the last failed connection
+}
+
+// holdConns idle connections held by worker
+type holdConns struct {
+	stats StressHold
+	conns map[net.Conn]time.Time // held connections and the time opened
+	live  int64
+	done  chan struct{}
+	once  sync.Once
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+}
+
+// startHold open -hold-connections idle connections to the host of url, the connections are held
+// until -hold-duration or the load ends
+func (b *StressWorker) startHold() {
+	p := b.RequestParams
+	h := &holdConns{
+		stats: StressHold{Target: int64(p.HoldConnections)},
+		conns: make(map[net.Conn]time.Time, p.HoldConnections),
+		done:  make(chan struct{}),
+	}
+	b.hold = h
+
+	u, err := gourl.Parse(p.Url)
+	if err != nil {
+		h.stats.Failed, h.stats.LastErr = h.stats.Target, err.Error()
+		return
+	}
+	addr, secure := u.Host, u.Scheme == "https" || u.Scheme == "wss"
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), map[bool]string{true: "443", false: "80"}[secure])
+	}
+	var tlsConfig *tls.Config
+	if secure {
+		tlsConfig = b.tlsConfig()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		if p.RequestType == typeHttp2 {
+			tlsConfig.NextProtos = []string{"h2"}
+		}
+	}
+
+	if p.HoldDuration > 0 {
+		time.AfterFunc(time.Duration(p.HoldDuration)*time.Millisecond, h.close)
+	}
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		sem := make(chan struct{}, holdDialers)
+		for i := 0; i < p.HoldConnections; i++ {
+			select {
+			case <-h.done:
+				return
+			case sem <- struct{}{}:
+			}
+			h.wg.Add(1)
+			go func(id int) {
+				defer h.wg.Done()
+				conn, err := b.dialHold(id, addr, tlsConfig)
+				<-sem
+				h.held(conn, err)
+			}(i)
+		}
+	}()
+}
+
+// dialHold dial idle connection id, with tls handshake for https and wss
+func (b *StressWorker) dialHold(id int, addr string, tlsConfig *tls.Config) (net.Conn, error) {
+	timeout := time.Duration(b.RequestParams.Timeout) * time.Millisecond
+	dial := b.dialContext(id, &net.Dialer{Timeout: timeout})
+	conn, err := dial(context.Background(), "tcp", addr)
+	if err != nil || tlsConfig == nil {
+		return conn, err
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	tlsConn.SetDeadline(time.Now().Add(timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// held hold the connection until closed by server or by close, the data from server is discarded
+func (h *holdConns) held(conn net.Conn, err error) {
+	h.mu.Lock()
+	if err != nil {
+		h.stats.Failed++
+		h.stats.LastErr = err.Error()
+		h.mu.Unlock()
+		return
+	}
+	select {
+	case <-h.done:
+		h.mu.Unlock()
+		conn.Close()
+		return
+	default:
+	}
+	opened := time.Now()
+	h.conns[conn] = opened
+	h.stats.Opened++
+	if h.live++; h.live > h.stats.Peak {
+		h.stats.Peak = h.live
+	}
+	h.mu.Unlock()
+
+	io.Copy(io.Discard, conn)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.conns[conn]; ok {
+		// not closed by close, the server closed the connection
+		delete(h.conns, conn)
+		h.stats.Closed++
+		h.stats.ClosedAfter += time.Since(opened).Milliseconds()
+		conn.Close()
+	}
+	h.live--
+}
+
+// close close the held connections and stop opening more
+func (h *holdConns) close() {
+	h.once.Do(func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		close(h.done)
+		for conn := range h.conns {
+			delete(h.conns, conn)
+			conn.Close()
+		}
+	})
+}
+
+// stopHold close the held connections and wait until all are closed
+func (b *StressWorker) stopHold() {
+	if b.hold != nil {
+		b.hold.close()
+		b.hold.wg.Wait()
+	}
+}
+
+// holdStats snapshot of idle connections, nil without -hold-connections
+func (b *StressWorker) holdStats() *StressHold {
+	if b.hold == nil {
+		return nil
+	}
+	b.hold.mu.Lock()
+	defer b.hold.mu.Unlock()
+	stats := b.hold.stats
+	return &stats
+}
+
+func (hold *StressHold) merge(v *StressHold) {
+	hold.Target += v.Target
+	hold.Opened += v.Opened
+	hold.Failed += v.Failed
+	hold.Peak += v.Peak
+	hold.Closed += v.Closed
+	hold.ClosedAfter += v.ClosedAfter
+	if v.LastErr != "" {
+		hold.LastErr = v.LastErr
+	}
+}
+
+// printHold Print idle connections held alongside the load
+func (result *StressResult) printHold(w io.Writer) {
+	h := result.Hold
+	fprintln(w, "\nIdle connections (held without requests):")
+	fprintln(w, "  Opened:\t%d of %d, peak %d held at the same time", h.Opened, h.Target, h.Peak)
+	if h.Failed > 0 {
+		fprintln(w, "  Failed:\t%d, last: %s", h.Failed, h.LastErr)
+	}
+	if h.Closed > 0 {
+		fprintln(w, "  Closed by server:\t%d, held %4.3f secs on average before closed", h.Closed,
+			float64(h.ClosedAfter)/float64(h.Closed)/1000)
+	} else {
+		fprintln(w, "  Closed by server:\t0")
+	}
+}
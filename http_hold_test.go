@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHoldConnections(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	// idle connections without requests are closed by the server
+	srv.Config.ReadHeaderTimeout = 200 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:      time.Now().UnixNano(),
+		Cmd:             cmdStart,
+		RequestType:     typeHttp1,
+		RequestMethod:   "GET",
+		Url:             srv.URL,
+		C:               1,
+		N:               10,
+		Qps:             10,
+		Duration:        10,
+		Timeout:         2000,
+		HoldConnections: 20,
+	})
+	h := result.Hold
+	if h == nil || h.Target != 20 || h.Opened != 20 || h.Failed != 0 || h.Peak < 1 {
+		t.Fatalf("hold = %+v, expect 20 connections opened", h)
+	}
+	if h.Closed != 20 || h.ClosedAfter < 20*150 {
+		t.Errorf("hold = %+v, expect 20 connections closed by server after about 200ms", h)
+	}
+}
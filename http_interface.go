@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync/atomic"
+)
+
+// StressInterface traffic of local network interface of -interface
+type StressInterface struct {
+	Conns     int64 `json:"conns"`
+	BytesSent int64 `json:"bytes_sent"`
+	BytesRecv int64 `json:"bytes_recv"`
+}
+
+// localInterface local address to bind connections of -interface
+type localInterface struct {
+	name  string
+	ip    net.IP
+	stats *StressInterface
+}
+
+// resolveInterface local ip of interface name, or the ip itself, ipv4 is preferred
+func resolveInterface(name string) (net.IP, error) {
+	if ip := net.ParseIP(name); ip != nil {
+		return ip, nil
+	}
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var found net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+		if found == nil {
+			found = ipNet.IP
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("interface %s has no address", name)
+	}
+	return found, nil
+}
+
+// countingConn count bytes sent and received of the interface, including tls
+type countingConn struct {
+	net.Conn
+	stats *StressInterface
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	atomic.AddInt64(&c.stats.BytesRecv, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(&c.stats.BytesSent, int64(n))
+	return n, err
+}
+
+// interfaceDist traffic of interfaces
+func (b *StressWorker) interfaceDist() map[string]*StressInterface {
+	dist := make(map[string]*StressInterface, len(b.interfaces))
+	for _, iface := range b.interfaces {
+		dist[iface.name] = &StressInterface{
+			Conns:     atomic.LoadInt64(&iface.stats.Conns),
+			BytesSent: atomic.LoadInt64(&iface.stats.BytesSent),
+			BytesRecv: atomic.LoadInt64(&iface.stats.BytesRecv),
+		}
+	}
+	return dist
+}
+
+// printInterfaces Print traffic of local network interfaces
+func (result *StressResult) printInterfaces(w io.Writer) {
+	names := make([]string, 0, len(result.InterfaceDist))
+	for name := range result.InterfaceDist {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fprintln(w, "\nInterface distribution:")
+	for _, name := range names {
+		i := result.InterfaceDist[name]
+		fprintln(w, "  [%s]\t%d connections, sent %s, received %s", name, i.Conns,
+			toByteSizeStr(float64(i.BytesSent)), toByteSizeStr(float64(i.BytesRecv)))
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResolveInterface(t *testing.T) {
+	if ip, err := resolveInterface("127.0.0.2"); err != nil || ip.String() != "127.0.0.2" {
+		t.Errorf("resolve ip = %v, %v", ip, err)
+	}
+	if ip, err := resolveInterface("lo"); err == nil && !ip.IsLoopback() {
+		t.Errorf("resolve lo = %v, expect loopback", ip)
+	}
+	if _, err := resolveInterface("no-such-iface0"); err == nil {
+		t.Errorf("resolve unknown interface, expect err")
+	}
+}
+
+func TestInterfaceDist(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	b := &StressWorker{RequestParams: &StressParameters{RequestType: typeHttp1, RequestMethod: "GET",
+		Url: srv.URL, Timeout: 1000, Interfaces: []string{"127.0.0.1", "127.0.0.2"}}}
+	b.prepare()
+	for id := 0; id < 4; id++ {
+		client := b.newClient(id)
+		res := &result{start: time.Now()}
+		b.doClient(client, res)
+		if res.err != nil {
+			t.Fatalf("request err: %v", res.err)
+		}
+		b.closeClient(client)
+	}
+
+	dist := b.interfaceDist()
+	for _, name := range []string{"127.0.0.1", "127.0.0.2"} {
+		if i := dist[name]; i == nil || i.Conns != 2 || i.BytesSent <= 0 || i.BytesRecv <= 0 {
+			t.Errorf("interface %s = %+v, expect 2 connections with traffic", name, i)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	jobRunning  = "running"
+	jobFinished = "finished"
+)
+
+var (
+	collectResults  sync.Map         // finished results of sequence id
+	collectKeepTime = 24 * time.Hour // keep finished results for collecting, set by -result-ttl
+)
+
+// collectResult finished result held by worker
+type collectResult struct {
+	url        string
+	result     *StressResult
+	startTime  time.Time
+	finishTime time.Time
+}
+
+// stressJob summary of stress test known by worker
+type stressJob struct {
+	SequenceId int64   `json:"sequence_id"`
+	State      string  `json:"state"` // running or finished
+	Url        string  `json:"url"`
+	StartTime  int64   `json:"start_time"`  // unix seconds
+	FinishTime int64   `json:"finish_time"` // unix seconds, 0 is running
+	Requests   int64   `json:"requests"`
+	Errors     int64   `json:"errors"`
+	Rps        float64 `json:"rps"`
+	Average    float64 `json:"average"` // secs
+}
+
+// runningResult snapshot of the result of running stress test, nil if not started
+func (b *StressWorker) runningResult() *StressResult {
+	resultRdMutex.RLock()
+	defer resultRdMutex.RUnlock()
+
+	if b.curResult == nil {
+		return nil
+	}
+	running := *b.curResult
+	running.Duration = int64(time.Since(b.windowStart).Seconds())
+	return calMutliStressResult(nil, running)
+}
+
+// started start time of running stress test, zero if not started
+func (b *StressWorker) started() time.Time {
+	resultRdMutex.RLock()
+	defer resultRdMutex.RUnlock()
+
+	return b.startTime
+}
+
+// collectStress collect the results of sequence id held by workers, the running ones are partial
+func collectStress(params StressParameters) *StressResult {
+	if len(workerList) > 0 {
+		jsonBody, _ := json.Marshal(params)
+		var resultList []StressResult
+		for _, v := range waitWorkerListReq(jsonBody) {
+			if v.ErrCode != 0 {
+				verbosePrint(vERROR, "collect err: %s", v.ErrMsg)
+				continue
+			}
+			resultList = append(resultList, v)
+		}
+		eprintln("collected %d of %d workers, sequence id: %d", len(resultList), len(workerList), params.SequenceId)
+		return calMutliStressResult(nil, resultList...)
+	}
+
+	if v, ok := collectResults.Load(params.SequenceId); ok {
+		return calMutliStressResult(nil, *v.(*collectResult).result)
+	}
+	if v, ok := stressList.Load(params.SequenceId); ok {
+		if result := v.(*StressWorker).runningResult(); result != nil {
+			return result
+		}
+	}
+	if run, err := loadHistory(params.SequenceId); err == nil {
+		return calMutliStressResult(nil, *run.Result) // expired, or held before the restart
+	}
+	return &StressResult{ErrCode: -1, ErrMsg: fmt.Sprintf("sequence id %d not found", params.SequenceId)}
+}
+
+// storeCollectResult hold the finished result for collecting, and persist it in -history-db
+func storeCollectResult(b *StressWorker, result *StressResult) {
+	expireCollectResults()
+	finishTime := time.Now()
+	collectResults.Store(b.RequestParams.SequenceId, &collectResult{
+		url:        b.RequestParams.Url,
+		result:     result,
+		startTime:  b.startTime,
+		finishTime: finishTime,
+	})
+	saveHistory(historyRun{SequenceId: b.RequestParams.SequenceId, Url: b.RequestParams.Url,
+		StartTime: b.startTime, FinishTime: finishTime, Result: result})
+}
+
+// expireCollectResults remove the finished results older than collectKeepTime
+func expireCollectResults() {
+	now := time.Now()
+	collectResults.Range(func(k, v interface{}) bool {
+		if now.Sub(v.(*collectResult).finishTime) > collectKeepTime {
+			collectResults.Delete(k)
+		}
+		return true
+	})
+}
+
+func newStressJob(seqId int64, state, url string, startTime, finishTime time.Time, result *StressResult) stressJob {
+	job := stressJob{
+		SequenceId: seqId,
+		State:      state,
+		Url:        url,
+		Requests:   result.LatsTotal,
+		Rps:        float64(result.Rps) / scaleNum,
+		Average:    float64(result.Average) / scaleNum,
+	}
+	if !startTime.IsZero() {
+		job.StartTime = startTime.Unix()
+	}
+	if !finishTime.IsZero() {
+		job.FinishTime = finishTime.Unix()
+	}
+	job.Errors = result.ErrTotal()
+	job.Requests += job.Errors
+	return job
+}
+
+// listJobs list running and finished stress tests, sorted by sequence id
+func listJobs() []stressJob {
+	jobs := make([]stressJob, 0)
+
+	expireCollectResults()
+	collectResults.Range(func(k, v interface{}) bool {
+		c := v.(*collectResult)
+		jobs = append(jobs, newStressJob(k.(int64), jobFinished, c.url, c.startTime, c.finishTime, c.result))
+		return true
+	})
+	stressList.Range(func(k, v interface{}) bool {
+		b := v.(*StressWorker)
+		result := b.runningResult()
+		if _, ok := collectResults.Load(k); ok || result == nil {
+			return true
+		}
+		jobs = append(jobs, newStressJob(k.(int64), jobRunning, b.RequestParams.Url, b.started(), time.Time{}, result))
+		return true
+	})
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].SequenceId < jobs[j].SequenceId })
+	return jobs
+}
+
+func serveJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "GET":
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	wbody, err := json.Marshal(listJobs())
+	if err != nil {
+		verbosePrint(vERROR, "marshal jobs: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", httpContentTypeJSON)
+	w.Write(wbody)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJobs(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	srv := httptest.NewServer(http.HandlerFunc(serveJobs))
+	defer srv.Close()
+
+	finished := time.Now().UnixNano()
+	result := GetStressResult()
+	result.LatsTotal, result.Rps, result.Average = 10, 20*scaleNum, scaleNum/100
+	result.ErrorDist["timeout"] = 2
+	storeCollectResult(&StressWorker{RequestParams: &StressParameters{SequenceId: finished, Url: target.URL},
+		startTime: time.Now().Add(-time.Second)}, result)
+
+	running := finished + 1
+	done := make(chan struct{})
+	go func() {
+		executeStress(StressParameters{SequenceId: running, Cmd: cmdStart, RequestType: typeHttp1, RequestMethod: "GET",
+			Url: target.URL, C: 1, Qps: 20, Duration: 1, Timeout: 1000})
+		close(done)
+	}()
+
+	find := func(jobs []stressJob, seqId int64) *stressJob {
+		for i := range jobs {
+			if jobs[i].SequenceId == seqId {
+				return &jobs[i]
+			}
+		}
+		return nil
+	}
+	var job *stressJob
+	for i := 0; i < 50 && job == nil; i++ {
+		time.Sleep(20 * time.Millisecond)
+		job = find(listJobs(), running)
+	}
+	if job == nil || job.State != jobRunning || job.Url != target.URL || job.StartTime <= 0 || job.FinishTime != 0 {
+		t.Errorf("running job = %+v", job)
+	}
+
+	resp, err := http.Get(srv.URL + httpWorkerJobsPath)
+	if err != nil {
+		t.Fatalf("get jobs err: %v", err)
+	}
+	var jobs []stressJob
+	json.NewDecoder(resp.Body).Decode(&jobs)
+	resp.Body.Close()
+	if job := find(jobs, finished); job == nil || job.State != jobFinished || job.Requests != 12 || job.Errors != 2 ||
+		job.Rps != 20 || job.Average != 0.01 || job.FinishTime <= 0 {
+		t.Errorf("finished job = %+v", job)
+	}
+	<-done
+	if job := find(listJobs(), running); job == nil || job.State != jobFinished || job.Requests <= 0 {
+		t.Errorf("job after the run = %+v", job)
+	}
+
+	if resp, err := http.Post(srv.URL+httpWorkerJobsPath, httpContentTypeJSON, nil); err != nil ||
+		resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("post jobs = %v, %v", resp, err)
+	}
+}
+
+func TestResultTTL(t *testing.T) {
+	defer func(ttl time.Duration) { collectKeepTime = ttl }(collectKeepTime)
+	collectKeepTime = time.Minute
+
+	seqId := time.Now().UnixNano()
+	storeCollectResult(&StressWorker{RequestParams: &StressParameters{SequenceId: seqId}, startTime: time.Now()},
+		GetStressResult())
+	if result := collectStress(StressParameters{SequenceId: seqId}); result.ErrCode != 0 {
+		t.Errorf("collect within -result-ttl = %+v", result)
+	}
+
+	// expired once finished longer than -result-ttl ago
+	v, _ := collectResults.Load(seqId)
+	v.(*collectResult).finishTime = time.Now().Add(-2 * time.Minute)
+	for _, job := range listJobs() {
+		if job.SequenceId == seqId {
+			t.Errorf("expired job listed: %+v", job)
+		}
+	}
+	if _, ok := collectResults.Load(seqId); ok {
+		t.Errorf("expired result is held")
+	}
+	if result := collectStress(StressParameters{SequenceId: seqId}); result.ErrCode != -1 {
+		t.Errorf("collect of expired result = %+v", result)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// rateRequest body of PUT /api/jobs/{sequence id}/rate, 0 keeps the current value
+type rateRequest struct {
+	Qps int `json:"qps"` // requests per second of each worker, -1 is unlimited
+	C   int `json:"c"`   // connections of each worker
+}
+
+func (req rateRequest) String() string {
+	var changes []string
+	if req.C > 0 {
+		changes = append(changes, fmt.Sprintf("c %d", req.C))
+	}
+	if req.Qps > 0 {
+		changes = append(changes, fmt.Sprintf("qps %d", req.Qps))
+	} else if req.Qps < 0 {
+		changes = append(changes, "qps unlimited")
+	}
+	return "load: " + strings.Join(changes, ", ")
+}
+
+// setConcurrency start or stop connections to c, false if the clients are finished
+func (b *StressWorker) setConcurrency(c int) bool {
+	b.clientsMu.Lock()
+	defer b.clientsMu.Unlock()
+
+	if b.clients == nil || b.clientsDone || b.IsStop() || b.RequestParams.ArrivalRate > 0 {
+		return false
+	}
+	atomic.StoreInt64(&b.concurrency, int64(c))
+	for id := 0; id < c; id++ {
+		if _, ok := b.clients[id]; !ok {
+			b.clientGen++
+			b.clients[id] = b.clientGen
+			b.clientWg.Add(1)
+			go b.runClient(id, b.clientGen)
+		}
+	}
+	return true
+}
+
+// leave the connection of id exits when the concurrency is decreased
+func (b *StressWorker) leave(id int) bool {
+	if int64(id) < atomic.LoadInt64(&b.concurrency) {
+		return false
+	}
+
+	b.clientsMu.Lock()
+	defer b.clientsMu.Unlock()
+	if int64(id) < atomic.LoadInt64(&b.concurrency) {
+		return false
+	}
+	delete(b.clients, id) // the id can be started again before the connection is closed
+	return true
+}
+
+// clientExit remove the connection of id and generation, the clients are finished when all exit
+func (b *StressWorker) clientExit(id int, gen int64) {
+	b.clientsMu.Lock()
+	defer b.clientsMu.Unlock()
+
+	if b.clients[id] == gen {
+		delete(b.clients, id)
+	}
+	if len(b.clients) == 0 {
+		b.clientsDone = true
+	}
+}
+
+// adjustLoad change rate limit and concurrency of running stress test, and annotate the change
+func (b *StressWorker) adjustLoad(req rateRequest) bool {
+	if req.C > 0 && !b.setConcurrency(req.C) {
+		return false
+	}
+	if req.Qps != 0 {
+		qps := int64(req.Qps)
+		if qps < 0 {
+			qps = -1
+		}
+		atomic.StoreInt64(&b.liveQps, qps)
+	}
+
+	resultRdMutex.Lock()
+	b.curResult.Annotations = append(b.curResult.Annotations, StressAnnotation{Time: time.Now().UnixMilli(), Msg: req.String()})
+	resultRdMutex.Unlock()
+	return true
+}
+
+// adjust change load of running stress test, and forward to workers
+func adjust(seqId int64, req rateRequest) int {
+	var count int
+	if v, ok := stressList.Load(seqId); ok && len(workerList) <= 0 && !v.(*StressWorker).started().IsZero() {
+		if v.(*StressWorker).adjustLoad(req) {
+			count++
+		}
+	}
+
+	if len(workerList) > 0 {
+		body, _ := json.Marshal(req)
+		path := fmt.Sprintf("%s/%d/rate", httpWorkerJobsPath, seqId)
+		for _, v := range workerList {
+			addr := workerUrl(v, path)
+			resp, err := doWorker(context.Background(), "PUT", addr, body)
+			if err != nil {
+				verbosePrint(vERROR, "adjust addr(%s) err: %s", addr, err.Error())
+				continue
+			}
+			resp.Body.Close()
+			count++
+		}
+	}
+
+	verbosePrint(vINFO, "adjust %d stress tests, %s", count, req)
+	return count
+}
+
+// serveJobRate PUT /api/jobs/{sequence id}/rate
+func serveJobRate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "PUT, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "PUT":
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, httpWorkerJobsPath+"/"), "/")
+	seqId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || action != "rate" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var req rateRequest
+	result := map[string]interface{}{"err_code": 0, "err_msg": ""}
+	if reqStr, err := io.ReadAll(r.Body); err != nil {
+		result["err_code"], result["err_msg"] = -1, err.Error()
+	} else if err := json.Unmarshal(reqStr, &req); err != nil {
+		result["err_code"], result["err_msg"] = -1, err.Error()
+	} else if req.C < 0 || (req.C == 0 && req.Qps == 0) {
+		result["err_code"], result["err_msg"] = -1, "expect qps or c"
+	} else {
+		result["adjusted"] = adjust(seqId, req)
+	}
+
+	wbody, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", httpContentTypeJSON)
+	w.Write(wbody)
+}
+
+// watchStepSignal add -c connections to the stress test of sequence id on each step signal
+func watchStepSignal(seqId int64, step int, sig chan os.Signal) {
+	c := step
+	for range sig {
+		c += step
+		adjust(seqId, rateRequest{C: c})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdjustLoad(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	params := StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL,
+		C:             1,
+		Duration:      2,
+		Timeout:       1000,
+	}
+	done := make(chan *StressResult)
+	go func() {
+		_, result := executeStress(params)
+		done <- result
+	}()
+
+	running := func() int {
+		v, ok := stressList.Load(params.SequenceId)
+		if !ok {
+			return -1
+		}
+		b := v.(*StressWorker)
+		b.clientsMu.Lock()
+		defer b.clientsMu.Unlock()
+		return len(b.clients)
+	}
+	waitRunning := func(expect int) {
+		for i := 0; i < 50 && running() != expect; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if c := running(); c != expect {
+			t.Errorf("running connections = %d, expect: %d", c, expect)
+		}
+	}
+
+	waitRunning(1)
+	if adjust(params.SequenceId, rateRequest{C: 3, Qps: 50}) != 1 {
+		t.Fatalf("adjust running stress test failed")
+	}
+	waitRunning(3)
+	adjust(params.SequenceId, rateRequest{C: 2})
+	waitRunning(2)
+
+	result := <-done
+	if len(result.Annotations) != 2 || result.Annotations[0].Msg != "load: c 3, qps 50" {
+		t.Errorf("annotations = %+v", result.Annotations)
+	}
+}
+
+func TestPace(t *testing.T) {
+	b := &StressWorker{RequestParams: &StressParameters{}, liveQps: 40, concurrency: 2}
+	client := &StressClient{}
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		b.pace(client)
+	}
+	// 2 connections of 40 qps, each sends every 50ms
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > 400*time.Millisecond {
+		t.Errorf("pace 5 requests in %s, expect about 200ms", elapsed)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+const maxLocalIps = 65536 // addresses of -local-ip and -local-ip-range, e.g. a /16
+
+// StressLocalIp connections of the source address pool of -local-ip and -local-ip-range
+type StressLocalIp struct {
+	Addrs      int64 `json:"addrs"`       // addresses of the pool
+	Used       int64 `json:"used"`        // addresses with connections
+	Conns      int64 `json:"conns"`       // connections bound to the pool
+	BindErrors int64 `json:"bind_errors"` // dials failed as the address is not available on the host
+}
+
+// localIpPool source addresses rotated by the dials of connections
+type localIpPool struct {
+	ips   []net.IP
+	conns []int64 // connections per address
+	next  uint64
+	stats StressLocalIp
+}
+
+// parseLocalIps parse ips of -local-ip and cidrs of -local-ip-range, the network and broadcast
+// addresses of ipv4 ranges are skipped
+func parseLocalIps(list []string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid -local-ip %s", s)
+			}
+			ips = append(ips, ip)
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -local-ip-range %s", s)
+		}
+		ones, bits := ipNet.Mask.Size()
+		if bits-ones > 16 {
+			return nil, fmt.Errorf("-local-ip-range %s has more than %d addresses", s, maxLocalIps)
+		}
+		first, n := new(big.Int).SetBytes(ipNet.IP), int64(1)<<(bits-ones)
+		start, end := int64(0), n
+		if bits == 32 && n > 2 {
+			start, end = 1, n-1
+		}
+		for i := start; i < end; i++ {
+			ip := make(net.IP, len(ipNet.IP))
+			new(big.Int).Add(first, big.NewInt(i)).FillBytes(ip)
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) > maxLocalIps {
+		return nil, fmt.Errorf("-local-ip and -local-ip-range have more than %d addresses", maxLocalIps)
+	}
+	return ips, nil
+}
+
+// checkLocalIps bind the first and last address of the pool, the addresses must be assigned to the host,
+// or routed to it, e.g. ip route add local 10.0.0.0/24 dev lo
+func checkLocalIps(ips []net.IP) error {
+	for _, ip := range []net.IP{ips[0], ips[len(ips)-1]} {
+		conn, err := net.ListenPacket("udp", net.JoinHostPort(ip.String(), "0"))
+		if err != nil {
+			return fmt.Errorf("local ip %s is not available: %v", ip, err)
+		}
+		conn.Close()
+	}
+	return nil
+}
+
+func newLocalIpPool(ips []net.IP) *localIpPool {
+	if len(ips) <= 0 {
+		return nil
+	}
+	return &localIpPool{ips: ips, conns: make([]int64, len(ips))}
+}
+
+// dialContext dial from the next address of the pool, each source address has its own ephemeral ports
+func (p *localIpPool) dialContext(ctx context.Context, d net.Dialer, network, addr string) (net.Conn, error) {
+	i := (atomic.AddUint64(&p.next, 1) - 1) % uint64(len(p.ips))
+	d.LocalAddr = &net.TCPAddr{IP: p.ips[i]}
+	conn, err := d.DialContext(ctx, network, addr)
+	switch {
+	case err == nil:
+		atomic.AddInt64(&p.conns[i], 1)
+	case errors.Is(err, syscall.EADDRNOTAVAIL):
+		atomic.AddInt64(&p.stats.BindErrors, 1)
+	}
+	return conn, err
+}
+
+// localIpStats connections of the pool, nil if no pool
+func (b *StressWorker) localIpStats() *StressLocalIp {
+	p := b.localIps
+	if p == nil {
+		return nil
+	}
+	stats := &StressLocalIp{Addrs: int64(len(p.ips)), BindErrors: atomic.LoadInt64(&p.stats.BindErrors)}
+	for i := range p.conns {
+		if n := atomic.LoadInt64(&p.conns[i]); n > 0 {
+			stats.Used++
+			stats.Conns += n
+		}
+	}
+	return stats
+}
+
+func (s *StressLocalIp) merge(v *StressLocalIp) {
+	s.Addrs += v.Addrs
+	s.Used += v.Used
+	s.Conns += v.Conns
+	s.BindErrors += v.BindErrors
+}
+
+// printLocalIp Print connections of the source address pool
+func (result *StressResult) printLocalIp(w io.Writer) {
+	s := result.LocalIp
+	fprintln(w, "\nSource addresses:")
+	fprintln(w, "  Pool:\t%d addresses, %d used", s.Addrs, s.Used)
+	if s.Used > 0 {
+		fprintln(w, "  Connections:\t%d, %4.2f per address", s.Conns, float64(s.Conns)/float64(s.Used))
+	}
+	if s.BindErrors > 0 {
+		fprintln(w, "  Bind errors:\t%d, the address is not available on the host", s.BindErrors)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseLocalIps(t *testing.T) {
+	for _, v := range []struct {
+		list   []string
+		expect string
+	}{
+		{[]string{"10.0.0.5"}, "10.0.0.5"},
+		{[]string{"10.0.0.5", "10.0.1.0/30"}, "10.0.0.5 10.0.1.1 10.0.1.2"},
+		{[]string{"10.0.1.0/31"}, "10.0.1.0 10.0.1.1"},
+		{[]string{"fd00::/127"}, "fd00:: fd00::1"},
+		{[]string{"10.0.0"}, ""},
+		{[]string{"10.0.0.0/33"}, ""},
+		{[]string{"10.0.0.0/8"}, ""},
+	} {
+		ips, err := parseLocalIps(v.list)
+		var got []string
+		for _, ip := range ips {
+			got = append(got, ip.String())
+		}
+		if (v.expect == "") != (err != nil) || strings.Join(got, " ") != v.expect {
+			t.Errorf("parseLocalIps(%v) = %v, %v, expect: %s", v.list, got, err, v.expect)
+		}
+	}
+	if err := checkLocalIps([]net.IP{net.ParseIP("192.0.2.1")}); err == nil {
+		t.Errorf("checkLocalIps of an address not on the host, expect err")
+	}
+}
+
+func TestStressLocalIp(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		sources = make(map[string]int)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		mu.Lock()
+		sources[host]++
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:        time.Now().UnixNano(),
+		Cmd:               cmdStart,
+		RequestType:       typeHttp1,
+		RequestMethod:     "GET",
+		Url:               srv.URL,
+		C:                 2,
+		N:                 10,
+		Timeout:           3000,
+		DisableKeepAlives: true,
+		LocalIps:          []string{"127.0.0.0/30"},
+	})
+	if result == nil || len(result.ErrorDist) > 0 || result.LocalIp == nil {
+		t.Fatalf("result of local ip = %+v", result)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sources) != 2 || sources["127.0.0.1"] == 0 || sources["127.0.0.2"] == 0 {
+		t.Errorf("source addresses of requests = %v, expect 127.0.0.1 and 127.0.0.2", sources)
+	}
+	if l := result.LocalIp; l.Addrs != 2 || l.Used != 2 || l.Conns != result.LatsTotal || l.BindErrors != 0 {
+		t.Errorf("local ip = %+v, responses: %d", l, result.LatsTotal)
+	}
+
+	var buf bytes.Buffer
+	result.printLocalIp(&buf)
+	for _, line := range []string{"Source addresses:", "  Pool:\t2 addresses, 2 used", "  Connections:\t"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printLocalIp expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
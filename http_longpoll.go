@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+const (
+	pollNotified = "notified" // server answered with data
+	pollEmpty    = "empty"    // server timed out without data, e.g. 204 or an empty body
+	pollOther    = "other"    // other status, e.g. 502 of a proxy timed out before the server
+)
+
+// StressLongPoll cycles of -longpoll, each poll waits until data arrives or the server times out,
+// and is re-issued immediately
+type StressLongPoll struct {
+	Timeout       int64            `json:"timeout"`       // ms the server holds a poll
+	Notifications int64            `json:"notifications"` // polls answered with data
+	Empty         int64            `json:"empty"`         // polls timed out on server without data
+	EmptyWait     int64            `json:"empty_wait"`    // sum of wait of empty polls, scaled by scaleNum
+	Other         int64            `json:"other"`         // polls answered with other status
+	WaitLats      map[string]int64 `json:"wait_lats"`     // time until data arrived of notifications
+}
+
+// pollTrace time of the poll written, the wait for data starts after
+type pollTrace struct {
+	wrote time.Time
+}
+
+// tracePoll trace the time of the poll written
+func tracePoll(req *http.Request) (*http.Request, *pollTrace) {
+	trace := &pollTrace{}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) { trace.wrote = time.Now() },
+	})), trace
+}
+
+// classify the answer of the poll after the body is read, the connection latency is until the poll
+// written, and the wait is the rest
+func (trace *pollTrace) classify(res *result) {
+	wrote := trace.wrote
+	if wrote.IsZero() {
+		wrote = res.start
+	}
+	res.pollConnect, res.pollWait = wrote.Sub(res.start), time.Since(wrote)
+	switch {
+	case res.statusCode == http.StatusNoContent || res.statusCode == http.StatusNotModified,
+		res.statusCode/100 == 2 && res.contentLength <= 0:
+		res.longpoll = pollEmpty
+	case res.statusCode/100 == 2:
+		res.longpoll = pollNotified
+	default:
+		res.longpoll = pollOther
+	}
+}
+
+func (poll *StressLongPoll) append(res *result, digits int) {
+	switch res.longpoll {
+	case pollNotified:
+		poll.Notifications++
+		poll.WaitLats[latsKey(res.pollWait, digits)]++
+	case pollEmpty:
+		poll.Empty++
+		poll.EmptyWait += int64(res.pollWait.Seconds() * scaleNum)
+	case pollOther:
+		poll.Other++
+	}
+}
+
+func (poll *StressLongPoll) merge(v *StressLongPoll) {
+	if poll.WaitLats == nil {
+		poll.WaitLats = make(map[string]int64)
+	}
+	poll.Timeout = v.Timeout
+	poll.Notifications += v.Notifications
+	poll.Empty += v.Empty
+	poll.EmptyWait += v.EmptyWait
+	poll.Other += v.Other
+	for lats, c := range v.WaitLats {
+		poll.WaitLats[lats] += c
+	}
+}
+
+// printLongPoll Print notifications and wait time distribution of long-poll cycles
+func (result *StressResult) printLongPoll(w io.Writer) {
+	p := result.LongPoll
+	fprintln(w, "\nLong-poll (server timeout %v):", time.Duration(p.Timeout)*time.Millisecond)
+	if result.Duration > 0 {
+		fprintln(w, "  Notifications:\t%d, %4.3f/sec", p.Notifications, float64(p.Notifications)/float64(result.Duration))
+	} else {
+		fprintln(w, "  Notifications:\t%d", p.Notifications)
+	}
+	if p.Empty > 0 {
+		fprintln(w, "  Empty polls:\t%d timed out on server, %4.3f secs average", p.Empty, float64(p.EmptyWait)/float64(p.Empty)/scaleNum)
+	} else {
+		fprintln(w, "  Empty polls:\t0")
+	}
+	if p.Other > 0 {
+		fprintln(w, "  Other status:\t%d polls", p.Other)
+	}
+	if p.Notifications > 0 {
+		data := latsPercentiles(p.WaitLats, p.Notifications, pctls)
+		fprintln(w, "  Wait until data:")
+		for i := 0; i < len(pctls); i++ {
+			fprintln(w, "  %v%% in %4.3f secs", pctls[i], data[i])
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLongPoll(t *testing.T) {
+	var polls int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt64(&polls, 1) % 3 {
+		case 0:
+			time.Sleep(100 * time.Millisecond)
+			w.WriteHeader(http.StatusNoContent) // server timeout
+		case 1:
+			time.Sleep(50 * time.Millisecond)
+			w.Write([]byte(`{"event": "update"}`))
+		case 2:
+			time.Sleep(50 * time.Millisecond)
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL,
+		C:             1,
+		N:             6,
+		Timeout:       3100,
+		LongPoll:      100,
+	})
+	if result == nil || result.LongPoll == nil {
+		t.Fatalf("result of long-poll = %+v", result)
+	}
+	p := result.LongPoll
+	if n := atomic.LoadInt64(&polls); p.Timeout != 100 || p.Notifications < 2 || p.Empty < 2 || p.Other < 2 ||
+		p.Notifications+p.Empty+p.Other != n {
+		t.Errorf("long-poll = %+v, expect notifications, empty and other of %d polls", p, n)
+	}
+	if wait := latsPercentiles(p.WaitLats, p.Notifications, []int{50})[0]; wait < 0.05 {
+		t.Errorf("wait until data = %f, expect at least 0.05 secs", wait)
+	}
+	if slowest := float64(result.Slowest) / scaleNum; slowest >= 0.05 {
+		t.Errorf("connection latency = %f, expect without the wait", slowest)
+	}
+
+	result.Duration, p.Notifications, p.Other = 2, 2, 2
+	var buf bytes.Buffer
+	result.printLongPoll(&buf)
+	for _, line := range []string{
+		"Long-poll (server timeout 100ms):",
+		"  Notifications:\t2, 1.000/sec",
+		"  Other status:\t2 polls",
+		"  Wait until data:",
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("printLongPoll expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
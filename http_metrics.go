@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// metricsBuckets upper bounds of latency histogram in secs
+var metricsBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metricsRun running stress test exported by /metrics
+type metricsRun struct {
+	seqId    int64
+	url      string
+	inflight int64 // -1 if unknown, e.g. the controller of workers
+	result   *StressResult
+}
+
+// runningMetrics results of running stress tests, the workers are asked for their metrics by the controller
+func runningMetrics() []metricsRun {
+	var runs []metricsRun
+	stressList.Range(func(k, v interface{}) bool {
+		b := v.(*StressWorker)
+		run := metricsRun{seqId: k.(int64), url: b.RequestParams.Url, inflight: -1}
+		if run.result = b.runningResult(); run.result != nil {
+			run.inflight = atomic.LoadInt64(&b.inflight)
+		} else if len(workerList) > 0 {
+			_, run.result = executeStress(StressParameters{Cmd: cmdMetrics, SequenceId: run.seqId})
+		}
+		if run.result != nil {
+			runs = append(runs, run)
+		}
+		return true
+	})
+	sort.Slice(runs, func(i, j int) bool { return runs[i].seqId < runs[j].seqId })
+	return runs
+}
+
+// metricsLabel escape label value of prometheus text format
+func metricsLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// writeMetrics write the metrics of runs in prometheus text format, live rps is the requests started
+// in the last complete second
+func writeMetrics(w io.Writer, runs []metricsRun, now time.Time) {
+	family := func(name, typ, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	labels := make([]string, len(runs))
+	for i, run := range runs {
+		labels[i] = fmt.Sprintf(`sequence_id="%d",url="%s"`, run.seqId, metricsLabel(run.url))
+	}
+
+	family("http_bench_requests_total", "counter", "Requests completed, including errors.")
+	for i, run := range runs {
+		fmt.Fprintf(w, "http_bench_requests_total{%s} %d\n", labels[i], run.result.LatsTotal+run.result.ErrTotal())
+	}
+	family("http_bench_errors_total", "counter", "Requests failed.")
+	for i, run := range runs {
+		fmt.Fprintf(w, "http_bench_errors_total{%s} %d\n", labels[i], run.result.ErrTotal())
+	}
+	family("http_bench_responses_total", "counter", "Responses by status code.")
+	for i, run := range runs {
+		codes := make([]int, 0, len(run.result.StatusCodeDist))
+		for code := range run.result.StatusCodeDist {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(w, "http_bench_responses_total{%s,code=\"%d\"} %d\n", labels[i], code, run.result.StatusCodeDist[code])
+		}
+	}
+	family("http_bench_requests_per_second", "gauge", "Requests started in the last complete second.")
+	for i, run := range runs {
+		var rps int64
+		if p := run.result.TimeSeries[now.Unix()-1]; p != nil {
+			rps = p.LatsTotal + p.ErrTotal
+		}
+		fmt.Fprintf(w, "http_bench_requests_per_second{%s} %d\n", labels[i], rps)
+	}
+	family("http_bench_in_flight_requests", "gauge", "Requests sent and not yet answered.")
+	for i, run := range runs {
+		if run.inflight >= 0 {
+			fmt.Fprintf(w, "http_bench_in_flight_requests{%s} %d\n", labels[i], run.inflight)
+		}
+	}
+	family("http_bench_request_duration_seconds", "histogram", "Latency of successful requests.")
+	for i, run := range runs {
+		counts := make([]int64, len(metricsBuckets))
+		for lats, c := range run.result.Lats {
+			secs, err := strconv.ParseFloat(strings.TrimSpace(lats), 64)
+			if err != nil {
+				continue
+			}
+			for j, le := range metricsBuckets {
+				if secs <= le {
+					counts[j] += c
+				}
+			}
+		}
+		for j, le := range metricsBuckets {
+			fmt.Fprintf(w, "http_bench_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels[i],
+				strconv.FormatFloat(le, 'g', -1, 64), counts[j])
+		}
+		fmt.Fprintf(w, "http_bench_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels[i], run.result.LatsTotal)
+		fmt.Fprintf(w, "http_bench_request_duration_seconds_sum{%s} %s\n", labels[i],
+			strconv.FormatFloat(float64(run.result.AvgTotal)/scaleNum, 'f', -1, 64))
+		fmt.Fprintf(w, "http_bench_request_duration_seconds_count{%s} %d\n", labels[i], run.result.LatsTotal)
+	}
+}
+
+// serveMetrics GET /metrics metrics of running stress tests in prometheus text format
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetrics(w, runningMetrics(), time.Now())
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	result := GetStressResult()
+	result.LatsTotal, result.AvgTotal = 3, int64(0.0065*scaleNum)
+	result.Lats = map[string]int64{"0.001": 1, "0.002": 1, "0.004": 1}
+	result.ErrorDist["timeout"] = 2
+	result.StatusCodeDist = map[int]int{200: 2, 500: 1}
+	result.TimeSeries[now.Unix()-1] = &StressPoint{LatsTotal: 3, ErrTotal: 1}
+	result.TimeSeries[now.Unix()] = &StressPoint{LatsTotal: 7}
+
+	var buf bytes.Buffer
+	writeMetrics(&buf, []metricsRun{{seqId: 7, url: `http://a/"q"`, inflight: 4, result: result}}, now)
+	out := buf.String()
+	labels := `{sequence_id="7",url="http://a/\"q\""`
+	for _, line := range []string{
+		"# TYPE http_bench_requests_total counter",
+		"http_bench_requests_total" + labels + "} 5",
+		"http_bench_errors_total" + labels + "} 2",
+		"http_bench_responses_total" + labels + `,code="500"} 1`,
+		"http_bench_requests_per_second" + labels + "} 4",
+		"http_bench_in_flight_requests" + labels + "} 4",
+		"# TYPE http_bench_request_duration_seconds histogram",
+		"http_bench_request_duration_seconds_bucket" + labels + `,le="0.001"} 1`,
+		"http_bench_request_duration_seconds_bucket" + labels + `,le="0.0025"} 2`,
+		"http_bench_request_duration_seconds_bucket" + labels + `,le="0.005"} 3`,
+		"http_bench_request_duration_seconds_bucket" + labels + `,le="+Inf"} 3`,
+		"http_bench_request_duration_seconds_sum" + labels + "} 0.0065",
+		"http_bench_request_duration_seconds_count" + labels + "} 3",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("metrics expect line %q, got:\n%s", line, out)
+		}
+	}
+
+	buf.Reset()
+	writeMetrics(&buf, []metricsRun{{seqId: 8, inflight: -1, result: GetStressResult()}}, now)
+	if strings.Contains(buf.String(), "http_bench_in_flight_requests{") {
+		t.Errorf("metrics of unknown in-flight requests:\n%s", buf.String())
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	params := StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL,
+		C:             1,
+		Qps:           50,
+		Duration:      1,
+		Timeout:       1000,
+	}
+	done := make(chan struct{})
+	go func() {
+		executeStress(params)
+		close(done)
+	}()
+	defer func() { <-done }()
+
+	time.Sleep(300 * time.Millisecond)
+	w := httptest.NewRecorder()
+	serveMetrics(w, httptest.NewRequest("GET", httpMetricsPath, nil))
+	if out := w.Body.String(); !strings.Contains(out, `http_bench_in_flight_requests{sequence_id=`) ||
+		!strings.Contains(out, `url="`+srv.URL+`"} `) {
+		t.Errorf("metrics of running stress test:\n%s", out)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// MixOp verb of -mix with its weight, and the url and body of -mix-url and -mix-body
+type MixOp struct {
+	Method string `json:"method"`
+	Weight int    `json:"weight"`
+	Url    string `json:"url"`  // template, empty is -url
+	Body   string `json:"body"` // template, empty is -body for verbs with a body
+}
+
+// mixOp verb of -mix with parsed templates, nil templates use -url or -body
+type mixOp struct {
+	method    string
+	weight    int
+	url, body *template.Template
+	noBody    bool // sent without -body, e.g. GET
+}
+
+// mixNoBody verbs sent without -body unless -mix-body is set
+var mixNoBody = map[string]bool{"GET": true, "HEAD": true, "DELETE": true, "OPTIONS": true}
+
+// parseMix parse -mix "GET:80,POST:20", and the url and body of verbs as "METHOD=value", a body of
+// "@file" is read from the file
+func parseMix(mix string, urls, bodies []string) ([]MixOp, error) {
+	var ops []MixOp
+	index := make(map[string]int)
+	for _, field := range strings.Split(mix, ",") {
+		method, weight, ok := strings.Cut(strings.TrimSpace(field), ":")
+		method = strings.ToUpper(strings.TrimSpace(method))
+		w, err := strconv.Atoi(strings.TrimSpace(weight))
+		if !ok || method == "" || err != nil || w <= 0 {
+			return nil, fmt.Errorf("invalid -mix %s, expect METHOD:weight, e.g. GET:80,POST:20", field)
+		}
+		if _, dup := index[method]; dup {
+			return nil, fmt.Errorf("duplicate method %s of -mix", method)
+		}
+		index[method] = len(ops)
+		ops = append(ops, MixOp{Method: method, Weight: w})
+	}
+
+	for _, v := range []struct {
+		name   string
+		values []string
+		set    func(op *MixOp, value string) error
+	}{
+		{"-mix-url", urls, func(op *MixOp, value string) error { op.Url = value; return nil }},
+		{"-mix-body", bodies, func(op *MixOp, value string) error {
+			if strings.HasPrefix(value, "@") {
+				content, err := os.ReadFile(value[1:])
+				if err != nil {
+					return err
+				}
+				value = string(content)
+			}
+			op.Body = value
+			return nil
+		}},
+	} {
+		for _, s := range v.values {
+			method, value, ok := strings.Cut(s, "=")
+			i, found := index[strings.ToUpper(strings.TrimSpace(method))]
+			if !ok || !found {
+				return nil, fmt.Errorf("invalid %s %s, expect METHOD=value of a method of -mix", v.name, s)
+			}
+			if err := v.set(&ops[i], value); err != nil {
+				return nil, fmt.Errorf("%s %s: %v", v.name, s, err)
+			}
+		}
+	}
+	return ops, nil
+}
+
+// parseMixOps parse templates of the verbs of -mix
+func parseMixOps(ops []MixOp) ([]mixOp, error) {
+	var parsed []mixOp
+	for _, op := range ops {
+		m := mixOp{method: op.Method, weight: op.Weight, noBody: mixNoBody[op.Method] && op.Body == ""}
+		var err error
+		if op.Url != "" {
+			if m.url, err = template.New("MIX-URL-" + op.Method).Funcs(fnMap).Parse(op.Url); err != nil {
+				return nil, fmt.Errorf("invalid -mix-url of %s: %v", op.Method, err)
+			}
+		}
+		if op.Body != "" {
+			if m.body, err = template.New("MIX-BODY-" + op.Method).Funcs(fnMap).Parse(op.Body); err != nil {
+				return nil, fmt.Errorf("invalid -mix-body of %s: %v", op.Method, err)
+			}
+		}
+		parsed = append(parsed, m)
+	}
+	return parsed, nil
+}
+
+// validateMix execute the templates of -mix with sample context
+func validateMix(ops []MixOp) []string {
+	parsed, err := parseMixOps(ops)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var errs []string
+	for _, op := range parsed {
+		if op.url != nil {
+			if err := op.url.Execute(io.Discard, sampleContext); err != nil {
+				errs = append(errs, fmt.Sprintf("invalid -mix-url of %s: %v", op.method, err))
+			}
+		}
+		if op.body != nil {
+			if err := op.body.Execute(io.Discard, sampleContext); err != nil {
+				errs = append(errs, fmt.Sprintf("invalid -mix-body of %s: %v", op.method, err))
+			}
+		}
+	}
+	return errs
+}
+
+// pickMix pick a verb of -mix randomly by weight
+func pickMix(ops []mixOp, r *rand.Rand) *mixOp {
+	total := 0
+	for _, op := range ops {
+		total += op.weight
+	}
+	n := r.Intn(total)
+	for i := range ops {
+		if n -= ops[i].weight; n < 0 {
+			return &ops[i]
+		}
+	}
+	return &ops[len(ops)-1]
+}
+
+// printMix Print responses, errors and latency of each verb of -mix
+func (result *StressResult) printMix(w io.Writer) {
+	methods := make([]string, 0, len(result.MixDist))
+	var total int64
+	for method, p := range result.MixDist {
+		methods = append(methods, method)
+		total += p.LatsTotal + p.ErrTotal
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		pi, pj := result.MixDist[methods[i]], result.MixDist[methods[j]]
+		return pi.LatsTotal+pi.ErrTotal > pj.LatsTotal+pj.ErrTotal
+	})
+
+	fprintln(w, "\nTraffic mix:")
+	for _, method := range methods {
+		p := result.MixDist[method]
+		requests := p.LatsTotal + p.ErrTotal
+		share := float64(requests) * 100 / float64(total)
+		if p.LatsTotal <= 0 {
+			fprintln(w, "  [%s]\t%d requests (%.2f%%), 0 responses, %d failed", method, requests, share, p.ErrTotal)
+			continue
+		}
+		data := latsPercentiles(p.Lats, p.LatsTotal, []int{50, 90, 99})
+		fprintln(w, "  [%s]\t%d requests (%.2f%%), %d failed, p50 %4.3f secs, p90 %4.3f secs, p99 %4.3f secs",
+			method, requests, share, p.ErrTotal, data[0], data[1], data[2])
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseMix(t *testing.T) {
+	bodyFile := filepath.Join(t.TempDir(), "order.json")
+	os.WriteFile(bodyFile, []byte(`{"id": 1}`), 0644)
+
+	ops, err := parseMix("get:80, POST:20,PUT:5", []string{"POST=http://127.0.0.1/orders"}, []string{"put=@" + bodyFile})
+	if err != nil || len(ops) != 3 {
+		t.Fatalf("parseMix = %+v, %v", ops, err)
+	}
+	if ops[0] != (MixOp{Method: "GET", Weight: 80}) || ops[1] != (MixOp{Method: "POST", Weight: 20, Url: "http://127.0.0.1/orders"}) ||
+		ops[2] != (MixOp{Method: "PUT", Weight: 5, Body: `{"id": 1}`}) {
+		t.Errorf("parseMix = %+v", ops)
+	}
+	for _, v := range []struct {
+		mix          string
+		urls, bodies []string
+	}{
+		{"GET", nil, nil},
+		{"GET:0", nil, nil},
+		{"GET:80,GET:20", nil, nil},
+		{"GET:80", []string{"POST=http://127.0.0.1/"}, nil},
+		{"GET:80", nil, []string{"GET"}},
+		{"POST:80", nil, []string{"POST=@" + bodyFile + ".missing"}},
+	} {
+		if _, err := parseMix(v.mix, v.urls, v.bodies); err == nil {
+			t.Errorf("parseMix(%s, %v, %v) expect err", v.mix, v.urls, v.bodies)
+		}
+	}
+
+	parsed, _ := parseMixOps([]MixOp{{Method: "GET", Weight: 3}, {Method: "POST", Weight: 1}})
+	r := rand.New(rand.NewSource(1))
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		counts[pickMix(parsed, r).method]++
+	}
+	if counts["GET"] < 2700 || counts["GET"] > 3300 || counts["GET"]+counts["POST"] != 4000 {
+		t.Errorf("pickMix counts = %v, expect 3:1", counts)
+	}
+	if errs := validateMix([]MixOp{{Method: "POST", Weight: 1, Url: "{{ .Missing }}", Body: "{{ randomNum 4 }}"}}); len(errs) != 1 {
+		t.Errorf("validateMix errs = %v", errs)
+	}
+	if errs := validateMix([]MixOp{{Method: "POST", Weight: 1, Body: "{{ randomNum"}}); len(errs) != 1 {
+		t.Errorf("validateMix errs = %v", errs)
+	}
+}
+
+func TestStressMix(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests = make(map[string][]string)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path] = append(requests[r.Method+" "+r.URL.Path], string(body))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL + "/items",
+		RequestBody:   "default",
+		C:             2,
+		N:             200,
+		Timeout:       3000,
+		Seed:          1,
+		Mix: []MixOp{
+			{Method: "GET", Weight: 3},
+			{Method: "POST", Weight: 1, Url: srv.URL + "/orders", Body: `{{ randomNum 4 }}`},
+			{Method: "PUT", Weight: 1},
+		},
+	})
+	if result == nil || len(result.ErrorDist) > 0 {
+		t.Fatalf("result of mix = %+v", result)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	gets, posts, puts := requests["GET /items"], requests["POST /orders"], requests["PUT /items"]
+	if len(gets)+len(posts)+len(puts) != int(result.LatsTotal) || len(gets) <= len(posts) || len(posts) == 0 || len(puts) == 0 {
+		t.Fatalf("requests = %d GET, %d POST, %d PUT, responses: %d", len(gets), len(posts), len(puts), result.LatsTotal)
+	}
+	if gets[0] != "" || len(posts[0]) != 4 || puts[0] != "default" {
+		t.Errorf("bodies of GET %q, POST %q, PUT %q", gets[0], posts[0], puts[0])
+	}
+	if p := result.MixDist["GET"]; p == nil || p.LatsTotal != int64(len(gets)) || result.MixDist["POST"].LatsTotal != int64(len(posts)) {
+		t.Errorf("mix dist = %+v", result.MixDist)
+	}
+
+	var buf bytes.Buffer
+	result.printMix(&buf)
+	for _, line := range []string{"Traffic mix:", "  [GET]\t", "  [POST]\t", "  [PUT]\t"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printMix expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const bodyMultipart = "multipart" // multipart/form-data body of -form
+
+// MultipartField part of the multipart/form-data body of -form, a file part if FileName is set
+type MultipartField struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`        // template of a text field
+	FileName    string `json:"file_name"`    // base name of the file sent in Content-Disposition
+	ContentType string `json:"content_type"` // of the file, by -form "file=@path;type=..." or the extension
+	Content     []byte `json:"content"`      // of the file, read once so distributed workers send the same file
+}
+
+// multipartField part of -form with parsed value template
+type multipartField struct {
+	MultipartField
+	template *template.Template // nil of file parts
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// parseMultipartField parse "name=value" or "name=@path" of -form, the file part may set its content type by
+// "name=@path;type=image/png"
+func parseMultipartField(field string) (MultipartField, error) {
+	name, value, ok := strings.Cut(field, "=")
+	if !ok || name == "" {
+		return MultipartField{}, fmt.Errorf("invalid -form: %s, expect name=value or name=@file", field)
+	}
+	if !strings.HasPrefix(value, "@") {
+		return MultipartField{Name: name, Value: value}, nil
+	}
+
+	fileName, contentType, _ := strings.Cut(value[1:], ";type=")
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return MultipartField{}, fmt.Errorf("invalid -form: %s, %v", field, err)
+	}
+	if contentType == "" {
+		if contentType = mime.TypeByExtension(filepath.Ext(fileName)); contentType == "" {
+			contentType = "application/octet-stream"
+		}
+	}
+	return MultipartField{Name: name, FileName: filepath.Base(fileName), ContentType: contentType, Content: content}, nil
+}
+
+func parseMultipartFields(fields []MultipartField, sequenceId int64) ([]multipartField, error) {
+	parsed := make([]multipartField, 0, len(fields))
+	for i, field := range fields {
+		f := multipartField{MultipartField: field}
+		if field.FileName == "" {
+			var err error
+			name := fmt.Sprintf("MULTIPART-%d-%d", sequenceId, i)
+			if f.template, err = template.New(name).Funcs(fnMap).Parse(field.Value); err != nil {
+				return nil, fmt.Errorf("invalid form %s: %v", field.Name, err)
+			}
+		}
+		parsed = append(parsed, f)
+	}
+	return parsed, nil
+}
+
+// isStaticMultipart all text fields are static, so the body is rendered once
+func isStaticMultipart(fields []multipartField) bool {
+	for _, f := range fields {
+		if f.template != nil && !isStaticTemplate(f.template) {
+			return false
+		}
+	}
+	return true
+}
+
+// renderMultipart render the parts of -form with the boundary of the worker, the value templates are
+// rendered per request and the files are sent as is
+func renderMultipart(w *bytes.Buffer, boundary string, fields []multipartField, ctx *requestContext) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+	var value bytes.Buffer
+	for _, f := range fields {
+		if f.template != nil {
+			value.Reset()
+			f.template.Execute(&value, ctx)
+			if err := mw.WriteField(f.Name, value.String()); err != nil {
+				return err
+			}
+			continue
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(f.Name), quoteEscaper.Replace(f.FileName)))
+		h.Set("Content-Type", f.ContentType)
+		part, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		part.Write(f.Content)
+	}
+	return mw.Close()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseMultipartField(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "photo.png"), []byte("png"), 0644)
+	os.WriteFile(filepath.Join(dir, "data.bin"), []byte("bin"), 0644)
+	for _, v := range []struct {
+		field  string
+		expect MultipartField
+		isErr  bool
+	}{
+		{field: "title={{ randomString 8 }}", expect: MultipartField{Name: "title", Value: "{{ randomString 8 }}"}},
+		{field: "empty=", expect: MultipartField{Name: "empty"}},
+		{field: "file=@" + filepath.Join(dir, "photo.png"),
+			expect: MultipartField{Name: "file", FileName: "photo.png", ContentType: "image/png", Content: []byte("png")}},
+		{field: "file=@" + filepath.Join(dir, "data.bin") + ";type=text/csv",
+			expect: MultipartField{Name: "file", FileName: "data.bin", ContentType: "text/csv", Content: []byte("bin")}},
+		{field: "file=@" + filepath.Join(dir, "missing.png"), isErr: true},
+		{field: "title", isErr: true},
+		{field: "=value", isErr: true},
+	} {
+		f, err := parseMultipartField(v.field)
+		if (err != nil) != v.isErr {
+			t.Fatalf("parseMultipartField(%s) err: %v, expect err: %v", v.field, err, v.isErr)
+		}
+		if f.Name != v.expect.Name || f.Value != v.expect.Value || f.FileName != v.expect.FileName ||
+			f.ContentType != v.expect.ContentType || string(f.Content) != string(v.expect.Content) {
+			t.Errorf("parseMultipartField(%s) = %+v, expect %+v", v.field, f, v.expect)
+		}
+	}
+}
+
+func TestStressMultipart(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		titles = make(map[string]bool)
+		bad    []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseMultipartForm(1 << 20)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			bad = append(bad, err.Error())
+			return
+		}
+		titles[r.FormValue("title")] = true
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			bad = append(bad, err.Error())
+			return
+		}
+		defer file.Close()
+		content, _ := io.ReadAll(file)
+		if string(content) != "\x89PNG\x00" || header.Filename != `a "b".png` ||
+			header.Header.Get("Content-Type") != "image/png" || r.FormValue("kind") != "photo" {
+			bad = append(bad, header.Filename+" "+header.Header.Get("Content-Type")+" "+string(content))
+		}
+	}))
+	defer srv.Close()
+
+	for _, title := range []string{"{{ randomString 8 }}", "static"} {
+		mu.Lock()
+		titles, bad = make(map[string]bool), nil
+		mu.Unlock()
+		_, result := executeStress(StressParameters{
+			SequenceId:      time.Now().UnixNano(),
+			Cmd:             cmdStart,
+			RequestType:     typeHttp1,
+			RequestMethod:   "POST",
+			Url:             srv.URL,
+			RequestBodyType: bodyMultipart,
+			MultipartForm: []MultipartField{
+				{Name: "title", Value: title},
+				{Name: "file", FileName: `a "b".png`, ContentType: "image/png", Content: []byte("\x89PNG\x00")},
+				{Name: "kind", Value: "photo"},
+			},
+			C:       2,
+			N:       20,
+			Timeout: 3000,
+		})
+		if result == nil || result.ErrTotal() > 0 || result.LatsTotal <= 0 {
+			t.Fatalf("result of title %s = %+v", title, result)
+		}
+		mu.Lock()
+		if len(bad) > 0 || title == "static" && (len(titles) != 1 || !titles["static"]) || title != "static" && len(titles) < 2 {
+			t.Errorf("titles of %s = %v, bad: %v", title, titles, bad)
+		}
+		mu.Unlock()
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	outputSummary = "summary"
+	outputHtml    = "html"
+)
+
+// outputSpec output type and file of -o, e.g. "json:run.json", stdout if the file is empty
+type outputSpec struct {
+	output string
+	file   string
+}
+
+var (
+	outputSpecs    []outputSpec    // outputs of the controller, workers only print -o to stdout
+	outputsWritten map[string]bool // files are truncated by the first result and appended by later ones
+)
+
+// parseOutputSpec parse "type" or "type:file" of -o
+func parseOutputSpec(s string) (outputSpec, error) {
+	output, file, _ := strings.Cut(s, ":")
+	switch output {
+	case outputSummary, outputCsv, outputLatsOverTime, outputTemplate, outputMarkdown, outputJson, outputHtml:
+	default:
+		return outputSpec{}, fmt.Errorf("invalid output type %q; only summary, csv, latencies-over-time, template, markdown, json and html are supported", output)
+	}
+	if strings.Contains(s, ":") && file == "" {
+		return outputSpec{}, fmt.Errorf("empty output file of %q", s)
+	}
+	return outputSpec{output: output, file: file}, nil
+}
+
+// stdoutOutput output type to print on stdout, which is also used by workers, summary is empty
+func stdoutOutput(specs []outputSpec) string {
+	for _, spec := range specs {
+		if spec.file == "" && spec.output != outputSummary {
+			return spec.output
+		}
+	}
+	return ""
+}
+
+// writeOutputs write the result in all outputs of -o, the summary is printed on stdout if no
+// output is printed on stdout
+func (result *StressResult) writeOutputs(specs []outputSpec) {
+	resultRdMutex.RLock()
+	defer resultRdMutex.RUnlock()
+
+	var toStdout bool
+	for _, spec := range specs {
+		if spec.file == "" {
+			toStdout = true
+			result.write(os.Stdout, spec.output)
+			continue
+		}
+
+		flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if outputsWritten[spec.file] {
+			flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		f, err := os.OpenFile(spec.file, flag, 0644)
+		if err != nil {
+			verbosePrint(vERROR, "open output file err: %v", err)
+			continue
+		}
+		result.write(f, spec.output)
+		f.Close()
+		if outputsWritten == nil {
+			outputsWritten = make(map[string]bool, 0)
+		}
+		outputsWritten[spec.file] = true
+		eprintln("%s output written to %s", spec.output, spec.file)
+	}
+	if !toStdout {
+		result.write(os.Stdout, "")
+	}
+}
+
+const htmlReport = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>http_bench report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+th { background: #f4f4f4; }
+.bar { background: #4a90d9; height: 12px; }
+.warn { color: #b00; }
+</style>
+</head>
+<body>
+<h1>http_bench report</h1>
+{{ if .Invalid }}<p class="warn">Statistically invalid: {{ .Invalid }}</p>{{ end }}
+{{ if .ErrMsg }}<p class="warn">Stopped: {{ .ErrMsg }}</p>{{ end }}
+<h2>Summary</h2>
+<table>
+<tr><th>Total</th><td>{{ printf "%4.3f" (float .Duration) }} secs</td></tr>
+<tr><th>Requests</th><td>{{ .LatsTotal }}</td></tr>
+<tr><th>Errors</th><td>{{ .ErrTotal }}</td></tr>
+<tr><th>Requests/sec</th><td>{{ secs .Rps }}</td></tr>
+<tr><th>Slowest</th><td>{{ secs .Slowest }} secs</td></tr>
+<tr><th>Fastest</th><td>{{ secs .Fastest }} secs</td></tr>
+<tr><th>Average</th><td>{{ secs .Average }} secs</td></tr>
+<tr><th>Total data</th><td>{{ byteSize .SizeTotal }}</td></tr>
+</table>
+{{ if not .Invalid }}
+<h2>Latency distribution</h2>
+<table>
+<tr><th>Percentile</th><th>Latency</th></tr>
+{{ range pctls }}<tr><td>{{ . }}%</td><td>{{ printf "%4.3f" ($.Percentile .) }} secs</td></tr>
+{{ end }}</table>
+{{ end }}
+<h2>Status code distribution</h2>
+<table>
+<tr><th>Status</th><th>Responses</th><th></th></tr>
+{{ range $code, $num := .StatusCodeDist }}<tr><td>{{ $code }}</td><td>{{ $num }}</td><td><div class="bar" style="width: {{ barWidth $num $.LatsTotal }}px"></div></td></tr>
+{{ end }}</table>
+{{ with timings . }}
+<h2>Latency phases</h2>
+<table>
+<tr><th>Phase</th><th>Count</th><th>p50</th><th>p90</th><th>p99</th></tr>
+{{ range . }}<tr><td>{{ .Title }}</td><td>{{ .Total }} {{ .Unit }}</td><td>{{ printf "%4.4f" .P50 }} secs</td><td>{{ printf "%4.4f" .P90 }} secs</td><td>{{ printf "%4.4f" .P99 }} secs</td></tr>
+{{ end }}</table>
+{{ end }}
+{{ if .TimeSeries }}
+<h2>Requests over time</h2>
+<table>
+<tr><th>Second</th><th>Responses</th><th>Errors</th><th></th></tr>
+{{ range timeSeries . }}<tr><td>{{ .Second }}</td><td>{{ .LatsTotal }}</td><td>{{ .ErrTotal }}</td><td><div class="bar" style="width: {{ barWidth .LatsTotal .Max }}px"></div></td></tr>
+{{ end }}</table>
+{{ end }}
+{{ if .ErrorDist }}
+<h2>Error distribution</h2>
+<table>
+<tr><th>Count</th><th>Error</th></tr>
+{{ range $err, $num := .ErrorDist }}<tr><td>{{ $num }}</td><td>{{ $err }}</td></tr>
+{{ end }}</table>
+{{ end }}
+{{ if .Annotations }}
+<h2>Annotations</h2>
+<table>
+{{ range .Annotations }}<tr><td>{{ .Time }}</td><td>{{ .Msg }}</td></tr>
+{{ end }}</table>
+{{ end }}
+{{ with .TargetMetrics }}
+<h2>Target metrics</h2>
+<p>{{ .Url }}, {{ .Scrapes }} scrapes, {{ .Errors }} failed{{ if .LastError }} ({{ .LastError }}){{ end }}</p>
+{{ if .Points }}<table>
+<tr><th>Second</th><th>CPU</th><th>Process CPU</th><th>Memory</th><th>Process RSS</th><th>Network rx</th><th>Network tx</th></tr>
+{{ $start := (index .Points 0).Time }}{{ range .Points }}<tr><td>{{ offset .Time $start }}</td><td>{{ usage .Cpu "%" }}</td><td>{{ usage .Cores "cores" }}</td><td>{{ usage .Memory "bytes" }}</td><td>{{ usage .Rss "bytes" }}</td><td>{{ usage .RxPerSec "bytes/s" }}</td><td>{{ usage .TxPerSec "bytes/s" }}</td></tr>
+{{ end }}</table>{{ end }}
+{{ end }}
+</body>
+</html>
+`
+
+// htmlPoint row of requests over time in html report
+type htmlPoint struct {
+	Second              int64
+	LatsTotal, ErrTotal int64
+	Max                 int64
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"float":    func(v int64) float64 { return float64(v) },
+	"secs":     func(v int64) string { return strconv.FormatFloat(float64(v)/scaleNum, 'f', 3, 64) },
+	"byteSize": func(v int64) string { return toByteSizeStr(float64(v)) },
+	"pctls":    func() []int { return pctls },
+	"offset":   func(t, start int64) string { return strconv.FormatFloat(float64(t-start)/1000, 'f', 1, 64) }, // unix ms
+	"timings":  func(result *StressResult) []timingRow { return result.timingRows() },
+	"barWidth": func(v interface{}, max int64) int64 {
+		n, _ := strconv.ParseInt(fmt.Sprint(v), 10, 64)
+		if max <= 0 {
+			return 0
+		}
+		return n * 400 / max
+	},
+	"timeSeries": func(result *StressResult) []htmlPoint {
+		var points []htmlPoint
+		var max int64
+		for sec, p := range result.TimeSeries {
+			points = append(points, htmlPoint{Second: sec, LatsTotal: p.LatsTotal, ErrTotal: p.ErrTotal})
+			if p.LatsTotal > max {
+				max = p.LatsTotal
+			}
+		}
+		sort.Slice(points, func(i, j int) bool { return points[i].Second < points[j].Second })
+		for i := len(points) - 1; i >= 0; i-- {
+			points[i].Second -= points[0].Second
+			points[i].Max = max
+		}
+		return points
+	},
+	"usage": func(v float64, unit string) string {
+		switch {
+		case v < 0:
+			return "-" // not exported by the target
+		case unit == "bytes":
+			return toByteSizeStr(v)
+		case unit == "bytes/s":
+			return toByteSizeStr(v) + "/s"
+		case unit == "%":
+			return fmt.Sprintf("%.2f%%", v)
+		}
+		return fmt.Sprintf("%.2f %s", v, unit)
+	},
+}).Parse(htmlReport))
+
+// printHtml Print self-contained html report
+func (result *StressResult) printHtml(w io.Writer) error {
+	return htmlReportTemplate.Execute(w, result)
+}
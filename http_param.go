@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// appendQuery append the encoded query of -param to the url, after an existing query and before the fragment
+func appendQuery(url string, query []byte) string {
+	if len(query) <= 0 {
+		return url
+	}
+	url, fragment, hasFragment := strings.Cut(url, "#")
+	var buf strings.Builder
+	buf.Grow(len(url) + len(query) + len(fragment) + 2)
+	buf.WriteString(url)
+	switch {
+	case !strings.Contains(url, "?"):
+		buf.WriteByte('?')
+	case !strings.HasSuffix(url, "?") && !strings.HasSuffix(url, "&"):
+		buf.WriteByte('&')
+	}
+	buf.Write(query)
+	if hasFragment {
+		buf.WriteByte('#')
+		buf.WriteString(fragment)
+	}
+	return buf.String()
+}
+
+// renderParams render the query parameters of -param and append them to the url, values are escaped per request
+func renderParams(url string, fields []formField, ctx *requestContext) string {
+	if len(fields) <= 0 {
+		return url
+	}
+	var query bytes.Buffer
+	renderForm(&query, fields, ctx)
+	return appendQuery(url, query.Bytes())
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAppendQuery(t *testing.T) {
+	for _, v := range []struct {
+		url, query, expect string
+	}{
+		{url: "http://a/b", query: "x=1", expect: "http://a/b?x=1"},
+		{url: "http://a/b?y=2", query: "x=1", expect: "http://a/b?y=2&x=1"},
+		{url: "http://a/b?", query: "x=1", expect: "http://a/b?x=1"},
+		{url: "http://a/b?y=2&", query: "x=1", expect: "http://a/b?y=2&x=1"},
+		{url: "http://a/b#top", query: "x=1", expect: "http://a/b?x=1#top"},
+		{url: "http://a/b?y=2#top", query: "x=1", expect: "http://a/b?y=2&x=1#top"},
+		{url: "http://a/b", query: "", expect: "http://a/b"},
+	} {
+		if got := appendQuery(v.url, []byte(v.query)); got != v.expect {
+			t.Errorf("appendQuery(%q, %q) = %q, expect %q", v.url, v.query, got, v.expect)
+		}
+	}
+}
+
+func TestStressParams(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		pages = make(map[string]bool)
+		bad   []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		mu.Lock()
+		defer mu.Unlock()
+		page, err := strconv.Atoi(q.Get("page"))
+		if q.Get("id") != "7" || q.Get("q") != "a b&c=d" || q.Get("sort") != "asc" || err != nil || page < 1 || page > 100 {
+			bad = append(bad, r.URL.RawQuery)
+		}
+		pages[q.Get("page")] = true
+	}))
+	defer srv.Close()
+
+	for _, url := range []string{srv.URL + "/?id=7", srv.URL + "/?id={{ intSum 3 4 }}"} {
+		mu.Lock()
+		pages, bad = make(map[string]bool), nil
+		mu.Unlock()
+		_, result := executeStress(StressParameters{
+			SequenceId:    time.Now().UnixNano(),
+			Cmd:           cmdStart,
+			RequestType:   typeHttp1,
+			RequestMethod: "GET",
+			Url:           url,
+			QueryParams:   []string{"page={{ random 1 100 }}", "q=a b&c=d", "sort=asc"},
+			C:             2,
+			N:             20,
+			Timeout:       3000,
+		})
+		if result == nil || len(result.ErrorDist) > 0 || result.LatsTotal <= 0 {
+			t.Fatalf("result of %s = %+v", url, result)
+		}
+		mu.Lock()
+		if len(bad) > 0 || len(pages) < 2 {
+			t.Errorf("params of %s, bad queries: %v, pages: %d", url, bad, len(pages))
+		}
+		mu.Unlock()
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// bodyBase base json document of -body-base, the rendered -body-patch of each request is merged
+// into it as a JSON merge patch (RFC 7386)
+type bodyBase struct {
+	doc interface{}
+}
+
+func parseBodyBase(content string) (*bodyBase, error) {
+	doc, err := decodeJsonNumber([]byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid json of -body-base: %v", err)
+	}
+	return &bodyBase{doc: doc}, nil
+}
+
+// decodeJsonNumber decode json keeping numbers as json.Number, so that large ids are not rounded
+func decodeJsonNumber(data []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, errors.New("unexpected data after the json value")
+	}
+	return v, nil
+}
+
+// patch merge the rendered patch into the base and encode the document, the base is not changed
+func (base *bodyBase) patch(patch []byte) ([]byte, error) {
+	p, err := decodeJsonNumber(patch)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json of -body-patch: %v", err)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(mergePatch(base.doc, p)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// mergePatch apply the merge patch to target, objects are merged recursively, null removes the key,
+// and other values replace the target, maps of target on the merged paths are copied
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, _ := target.(map[string]interface{})
+	merged := make(map[string]interface{}, len(t)+len(p))
+	for k, v := range t {
+		merged[k] = v
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(merged, k)
+		} else {
+			merged[k] = mergePatch(merged[k], v)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMergePatch(t *testing.T) {
+	base, err := parseBodyBase(`{"id":12345678901234567890,"user":{"id":"0","name":"a<b"},"items":[1,2],"tmp":true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for patch, expect := range map[string]string{
+		`{}`:                            `{"id":12345678901234567890,"items":[1,2],"tmp":true,"user":{"id":"0","name":"a<b"}}`,
+		`{"user":{"id":"42"}}`:          `{"id":12345678901234567890,"items":[1,2],"tmp":true,"user":{"id":"42","name":"a<b"}}`,
+		`{"tmp":null,"items":[3]}`:      `{"id":12345678901234567890,"items":[3],"user":{"id":"0","name":"a<b"}}`,
+		`{"user":"x","new":{"a":null}}`: `{"id":12345678901234567890,"items":[1,2],"new":{},"tmp":true,"user":"x"}`,
+		`[1]`:                           `[1]`,
+	} {
+		body, err := base.patch([]byte(patch))
+		if err != nil || string(body) != expect {
+			t.Errorf("patch %s = %s, %v, expect: %s", patch, body, err, expect)
+		}
+	}
+	if _, err := base.patch([]byte(`{"user":`)); err == nil {
+		t.Errorf("patch of invalid json expect err")
+	}
+	if body, _ := base.patch([]byte(`{}`)); !strings.Contains(string(body), `"user":{"id":"0"`) {
+		t.Errorf("base is changed by patches: %s", body)
+	}
+	if _, err := parseBodyBase(`{"a":1} {}`); err == nil {
+		t.Errorf("parseBodyBase of two documents expect err")
+	}
+}
+
+func TestStressBodyPatch(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:      time.Now().UnixNano(),
+		Cmd:             cmdStart,
+		RequestType:     typeHttp1,
+		RequestMethod:   "POST",
+		Url:             srv.URL,
+		RequestBody:     `{"user":{"id":"{{ randomNum 8 }}"}}`,
+		RequestBodyType: bodyJson,
+		BodyBase:        `{"order":{"sku":"A1","qty":1},"user":{"id":"","name":"bob"}}`,
+		C:               1,
+		N:               3,
+		Timeout:         3000,
+	})
+	if result == nil || len(result.ErrorDist) > 0 {
+		t.Fatalf("result of body patch = %+v", result)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) < 3 {
+		t.Fatalf("bodies = %v", bodies)
+	}
+	for _, body := range bodies {
+		if !strings.HasPrefix(body, `{"order":{"qty":1,"sku":"A1"},"user":{"id":"`) || !strings.HasSuffix(body, `","name":"bob"}}`) ||
+			len(body) != len(`{"order":{"qty":1,"sku":"A1"},"user":{"id":"12345678","name":"bob"}}`) {
+			t.Errorf("patched body = %s", body)
+		}
+	}
+	if bodies[0] == bodies[1] && bodies[1] == bodies[2] {
+		t.Errorf("patched bodies are the same: %v", bodies)
+	}
+}
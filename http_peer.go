@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	workerAuthHeader = "X-Http-Bench-Auth" // signature of the controller request, or proof of the worker response
+	workerAuthSkew   = 5 * time.Minute     // clock difference of controller and worker accepted
+	workerMaxBody    = 64 << 20            // max body of a signed request, the body is read before the signature is verified
+)
+
+var (
+	workerToken  []byte           // secret shared by the controller and workers of -worker-token
+	workerClient = &http.Client{} // requests to workers, https workers are verified by -worker-ca
+	workerNonces sync.Map         // nonces of the requests accepted within workerAuthSkew, rejects replays
+)
+
+// loadWorkerCA verify the certificates of https workers by the PEM file of CA instead of the system roots
+func loadWorkerCA(file string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("-worker-ca file read error(%v)", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return fmt.Errorf("invalid -worker-ca: no certificate in %s", file)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	workerClient = &http.Client{Transport: transport}
+	return nil
+}
+
+// workerMac hmac of the parts by -worker-token
+func workerMac(parts ...string) string {
+	h := hmac.New(sha256.New, workerToken)
+	h.Write([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func bodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// signWorkerRequest sign the method, path with query, time, a random nonce and the body of req by -worker-token,
+// the token itself is never sent, and the nonce is returned to verify the proof of the worker
+func signWorkerRequest(req *http.Request, body []byte) string {
+	if len(workerToken) <= 0 {
+		return ""
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	nonce, ts := hex.EncodeToString(b), strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(workerAuthHeader, ts+":"+nonce+":"+workerMac("request", req.Method, req.URL.RequestURI(), ts, nonce, bodyHash(body)))
+	return nonce
+}
+
+// verifyWorkerRequest check the signature of -worker-token of r, a request is accepted once within
+// workerAuthSkew, and the response proves the worker knows the token too
+func verifyWorkerRequest(w http.ResponseWriter, r *http.Request) bool {
+	ts, rest, _ := strings.Cut(r.Header.Get(workerAuthHeader), ":")
+	nonce, mac, ok := strings.Cut(rest, ":")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if _, hexErr := hex.DecodeString(nonce); !ok || err != nil || len(nonce) != 32 || hexErr != nil {
+		return false
+	}
+	signed := time.Unix(sec, 0)
+	if d := time.Since(signed); d > workerAuthSkew || d < -workerAuthSkew {
+		verbosePrint(vDEBUG, "worker request signed at %v, out of %v", signed, workerAuthSkew)
+		return false
+	}
+	if _, replayed := workerNonces.Load(nonce); replayed {
+		verbosePrint(vDEBUG, "worker request replayed: %s", nonce)
+		return false
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, workerMaxBody+1))
+	if err != nil || len(body) > workerMaxBody {
+		verbosePrint(vDEBUG, "worker request body exceeds %d bytes", workerMaxBody)
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if !hmac.Equal([]byte(mac), []byte(workerMac("request", r.Method, r.URL.RequestURI(), ts, nonce, bodyHash(body)))) {
+		return false
+	}
+	if _, replayed := workerNonces.LoadOrStore(nonce, signed); replayed {
+		verbosePrint(vDEBUG, "worker request replayed: %s", nonce)
+		return false
+	}
+	w.Header().Set(workerAuthHeader, workerMac("response", nonce))
+	return true
+}
+
+// sweepWorkerNonces forget the nonces out of workerAuthSkew periodically, they are rejected by the time
+func sweepWorkerNonces() {
+	ticker := time.NewTicker(workerAuthSkew)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		workerNonces.Range(func(k, v interface{}) bool {
+			if now.Sub(v.(time.Time)) > 2*workerAuthSkew {
+				workerNonces.Delete(k)
+			}
+			return true
+		})
+	}
+}
+
+// doWorker send the json body to the worker, signed by -worker-token, and the worker must prove the
+// token by the response
+func doWorker(ctx context.Context, method, uri string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", httpContentTypeJSON)
+	nonce := signWorkerRequest(req, body)
+	resp, err := workerClient.Do(req)
+	if err != nil || nonce == "" || resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return resp, err
+	}
+	if proof := resp.Header.Get(workerAuthHeader); !hmac.Equal([]byte(proof), []byte(workerMac("response", nonce))) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("worker %s is not authenticated by -worker-token", redactWorker(uri))
+	}
+	return resp, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorkerToken(t *testing.T) {
+	defer func(token []byte) { workerToken = token }(workerToken)
+	workerToken = []byte("0123456789abcdef")
+
+	auth, _ := newListenAuth(nil, "", "", true)
+	var served int
+	srv := httptest.NewServer(auth.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		w.Write([]byte(`{}`))
+	})))
+	defer srv.Close()
+
+	// signed by the same token, and the worker proves the token by the response
+	resp, err := doWorker(context.Background(), "POST", srv.URL+httpWorkerApiPath, []byte(`{"cmd": 0}`))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("doWorker with token = %v, %v", resp, err)
+	}
+	resp.Body.Close()
+
+	// unsigned, tampered or replayed commands are rejected
+	start := `{"cmd": 0, "url": "http://127.0.0.1/"}`
+	req, _ := http.NewRequest("POST", srv.URL+httpWorkerApiPath, strings.NewReader(start))
+	signWorkerRequest(req, []byte(start))
+	signature := req.Header.Get(workerAuthHeader)
+	for _, tt := range []struct {
+		name, signature, body string
+		code                  int
+	}{
+		{"unsigned", "", start, http.StatusUnauthorized},
+		{"tampered", signature, `{"cmd": 0, "url": "http://evil/"}`, http.StatusUnauthorized},
+		{"signed", signature, start, http.StatusOK},
+		{"replayed", signature, start, http.StatusUnauthorized},
+		{"expired", "1:00:00", start, http.StatusUnauthorized},
+	} {
+		req, _ := http.NewRequest("POST", srv.URL+httpWorkerApiPath, strings.NewReader(tt.body))
+		if tt.signature != "" {
+			req.Header.Set(workerAuthHeader, tt.signature)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s request err: %v", tt.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.code {
+			t.Errorf("%s request = %d, expect %d", tt.name, resp.StatusCode, tt.code)
+		}
+	}
+	if served != 2 {
+		t.Errorf("served %d requests, expect 2", served)
+	}
+
+	// the query is signed, and bodies over workerMaxBody are rejected before the signature is checked
+	req, _ = http.NewRequest("GET", srv.URL+httpWorkerJobsPath+"?seqid=1", nil)
+	signWorkerRequest(req, nil)
+	req.URL.RawQuery = "seqid=2"
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("request of tampered query = %v, %v", resp, err)
+	}
+	for _, tt := range []struct {
+		path   string
+		signed bool
+		code   int
+	}{
+		{httpWorkerApiPath, false, http.StatusRequestEntityTooLarge},
+		{httpWorkerApiPath, true, http.StatusRequestEntityTooLarge},
+		{httpWorkerJobsPath, true, http.StatusUnauthorized},
+	} {
+		req, _ = http.NewRequest("POST", srv.URL+tt.path, io.LimitReader(zeroReader{}, workerMaxBody+1))
+		if tt.signed {
+			signWorkerRequest(req, nil)
+		}
+		if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != tt.code {
+			t.Errorf("request of oversized body to %s = %v, %v, expect %d", tt.path, resp, err, tt.code)
+		}
+	}
+
+	// a worker without the token does not prove it
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer plain.Close()
+	if _, err := executeWorkerReq(plain.URL+httpWorkerApiPath, []byte(`{"cmd": 0}`)); err == nil ||
+		!strings.Contains(err.Error(), "not authenticated by -worker-token") {
+		t.Errorf("executeWorkerReq of worker without token err = %v", err)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestWorkerCA(t *testing.T) {
+	defer func(client *http.Client) { workerClient = client }(workerClient)
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	if _, err := executeWorkerReq(srv.URL+httpWorkerApiPath, []byte(`{}`)); err == nil {
+		t.Errorf("executeWorkerReq of untrusted https worker expect err")
+	}
+
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0644)
+	if err := loadWorkerCA(file); err != nil {
+		t.Fatalf("loadWorkerCA err: %v", err)
+	}
+	if _, err := executeWorkerReq(srv.URL+httpWorkerApiPath, []byte(`{}`)); err != nil {
+		t.Errorf("executeWorkerReq of https worker with -worker-ca err: %v", err)
+	}
+
+	os.WriteFile(file, []byte("not a certificate"), 0644)
+	if err := loadWorkerCA(file); err == nil {
+		t.Errorf("loadWorkerCA of invalid file expect err")
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	phaseWarmup    = "warm-up"   // requests are excluded from the result within -warmup
+	phaseRampUp    = "ramp-up"   // connections are still starting within -start-jitter
+	phaseSteady    = "steady"    // all connections started at the offered rate
+	phaseThrottled = "throttled" // the offered rate is lowered by -polite or paused by Retry-After
+)
+
+// elapsed whole seconds since the load of the worker started, e.g. {{ if gt elapsed 60 }}
+func (b *StressWorker) elapsed() int64 {
+	return int64(time.Since(b.startTime) / time.Second)
+}
+
+// phase load phase of the worker, e.g. {{ if eq phase "steady" }}
+func (b *StressWorker) phase() string {
+	now := time.Now()
+	if b.RequestParams.Warmup > 0 && now.Before(b.warmupEnd()) {
+		return phaseWarmup
+	}
+	if jitter := time.Duration(b.RequestParams.StartJitter) * time.Millisecond; jitter > 0 && now.Sub(b.startTime) < jitter {
+		return phaseRampUp
+	}
+	if atomic.LoadInt64(&b.politeRps) > 0 || atomic.LoadInt64(&b.retryUntil) > now.UnixNano() {
+		return phaseThrottled
+	}
+	return phaseSteady
+}
+
+// noElapsed, noPhase functions of fnMap outside of a running worker, e.g. to validate templates
+func noElapsed() int64 { return 0 }
+
+func noPhase() string { return phaseSteady }
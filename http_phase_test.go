@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestPhase(t *testing.T) {
+	b := &StressWorker{RequestParams: &StressParameters{StartJitter: 5000}, startTime: time.Now().Add(-90 * time.Second)}
+	tpl := template.Must(template.New("BODY").Funcs(fnMap).Parse(`{{ elapsed }} {{ phase }}{{ if gt elapsed 60 }} heavy{{ end }}`))
+	tpl = cloneTemplate(tpl, template.FuncMap{"elapsed": b.elapsed, "phase": b.phase})
+
+	for _, v := range []struct {
+		start     time.Duration // ago
+		politeRps int64
+		expect    string
+	}{
+		{start: 90 * time.Second, expect: "90 steady heavy"},
+		{start: 2 * time.Second, expect: "2 ramp-up"},
+		{start: 10 * time.Second, politeRps: 50, expect: "10 throttled"},
+	} {
+		b.startTime, b.politeRps = time.Now().Add(-v.start), v.politeRps
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, sampleContext); err != nil || buf.String() != v.expect {
+			t.Errorf("template = %q, %v, expect: %q", buf.String(), err, v.expect)
+		}
+	}
+
+	b.politeRps, b.retryUntil = 0, time.Now().Add(time.Second).UnixNano()
+	if phase := b.phase(); phase != phaseThrottled {
+		t.Errorf("phase paused by Retry-After = %s, expect: %s", phase, phaseThrottled)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	errPipelineClosed   = errors.New("pipelined connection closed")
+	errPipelineRejected = errors.New("connection closed by server, the pipelined requests behind are lost")
+)
+
+// pipelineCall request written to the pipelined connection, waiting for its response in order
+type pipelineCall struct {
+	req  *http.Request
+	resp *http.Response
+	err  error
+	done chan struct{}
+}
+
+// pipelineConn http/1.1 connection which writes requests without waiting for responses, and reads
+// the responses in the order of requests
+type pipelineConn struct {
+	conn     net.Conn
+	bw       *bufio.Writer
+	calls    chan *pipelineCall
+	inflight int32 // requests written and not answered
+	closed   bool
+	closeErr error // reason of the following calls failed
+	mu       sync.Mutex
+}
+
+// send write request and queue the call, return position of the request in the pipeline,
+// 0 is no request ahead
+func (pc *pipelineConn) send(call *pipelineCall) (int, error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.closed {
+		return 0, errPipelineClosed
+	}
+	pos := int(atomic.AddInt32(&pc.inflight, 1) - 1)
+	if err := call.req.Write(pc.bw); err != nil {
+		return pos, err
+	}
+	if err := pc.bw.Flush(); err != nil {
+		return pos, err
+	}
+	pc.calls <- call
+	return pos, nil
+}
+
+func (pc *pipelineConn) close(err error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if !pc.closed {
+		pc.closed, pc.closeErr = true, err
+		pc.conn.Close()
+		close(pc.calls)
+	}
+}
+
+func (pc *pipelineConn) isClosed() bool {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return pc.closed
+}
+
+// readLoop read responses in order, the body is read fully before the next response
+func (pc *pipelineConn) readLoop() {
+	br := bufio.NewReader(pc.conn)
+	for call := range pc.calls {
+		if pc.isClosed() {
+			call.err = pc.closeErr
+			close(call.done)
+			continue
+		}
+		call.resp, call.err = http.ReadResponse(br, call.req)
+		if call.err == nil {
+			var body []byte
+			body, call.err = io.ReadAll(call.resp.Body)
+			call.resp.Body.Close()
+			call.resp.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		atomic.AddInt32(&pc.inflight, -1)
+		switch {
+		case call.err != nil:
+			pc.close(errPipelineClosed) // the following responses are lost
+		case call.resp.Close:
+			pc.close(errPipelineRejected)
+		}
+		close(call.done)
+	}
+}
+
+// pipelineTransport round tripper of -pipeline, all lanes of a connection share one pipelined
+// connection, which is dialed again after it is closed
+type pipelineTransport struct {
+	dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+	tlsConfig *tls.Config
+	depth     int
+	pc        *pipelineConn
+	mu        sync.Mutex
+}
+
+func (t *pipelineTransport) conn(req *http.Request) (*pipelineConn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pc != nil && !t.pc.isClosed() {
+		return t.pc, nil
+	}
+
+	port := req.URL.Port()
+	if port == "" {
+		port = "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	conn, err := t.dial(req.Context(), "tcp", net.JoinHostPort(req.URL.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+	if req.URL.Scheme == "https" {
+		cfg := t.tlsConfig.Clone()
+		cfg.NextProtos = []string{"http/1.1"}
+		if cfg.ServerName == "" {
+			cfg.ServerName = req.URL.Hostname()
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(req.Context()); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	t.pc = &pipelineConn{conn: conn, bw: bufio.NewWriter(conn), calls: make(chan *pipelineCall, t.depth)}
+	go t.pc.readLoop()
+	return t.pc, nil
+}
+
+// roundTrip send request on the pipelined connection, and return the response with the position
+// of the request in the pipeline
+func (t *pipelineTransport) roundTrip(req *http.Request) (*http.Response, int, error) {
+	pc, err := t.conn(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	call := &pipelineCall{req: req, done: make(chan struct{})}
+	pos, err := pc.send(call)
+	if err != nil {
+		pc.close(errPipelineClosed)
+		return nil, pos, err
+	}
+	select {
+	case <-call.done:
+		return call.resp, pos, call.err
+	case <-req.Context().Done():
+		pc.close(errPipelineClosed) // the order of responses is lost
+		return nil, pos, req.Context().Err()
+	}
+}
+
+func (t *pipelineTransport) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pc != nil {
+		t.pc.close(errPipelineClosed)
+	}
+}
+
+// pipelineLane round tripper of one lane of the pipelined connection, which records the position
+// of its last request
+type pipelineLane struct {
+	transport *pipelineTransport
+	client    *StressClient
+}
+
+func (l *pipelineLane) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, pos, err := l.transport.roundTrip(req)
+	l.client.pipelinePos = pos
+	return resp, err
+}
+
+// executePipeline run -pipeline lanes on the connection of client, each lane sends requests one by one,
+// so that at most -pipeline requests are in flight on the connection
+func (b *StressWorker) executePipeline(n, sleep int, client *StressClient) {
+	depth := b.RequestParams.Pipeline
+	transport := &pipelineTransport{
+		dial:      b.dialContext(client.id, &net.Dialer{Timeout: time.Duration(b.RequestParams.Timeout) * time.Millisecond}),
+		tlsConfig: b.tlsConfig(),
+		depth:     depth,
+	}
+	defer transport.close()
+
+	lanes := []*StressClient{client}
+	for i := 1; i < depth; i++ {
+		lane := b.newClient(client.id)
+		if lane == nil {
+			return
+		}
+		defer b.closeClient(lane)
+		lanes = append(lanes, lane)
+	}
+
+	var wg sync.WaitGroup
+	for _, lane := range lanes {
+		lane.httpClient = &http.Client{
+			Timeout:   time.Duration(b.RequestParams.Timeout) * time.Millisecond,
+			Transport: &pipelineLane{transport: transport, client: lane},
+		}
+		wg.Add(1)
+		go func(lane *StressClient) {
+			defer wg.Done()
+			b.execute(n/depth, sleep, lane)
+		}(lane)
+	}
+	wg.Wait()
+}
+
+// printPipeline Print latency by position in the pipeline, position 0 has no request ahead like
+// a normal keep-alive request, later positions wait for the responses ahead (head-of-line blocking)
+func (result *StressResult) printPipeline(w io.Writer) {
+	positions := make([]int, 0, len(result.PipelineDist))
+	for pos := range result.PipelineDist {
+		positions = append(positions, pos)
+	}
+	sort.Ints(positions)
+
+	fprintln(w, "\nPipeline distribution (latency by requests ahead on the connection):")
+	var base float64
+	for _, pos := range positions {
+		p := result.PipelineDist[pos]
+		if p.LatsTotal <= 0 {
+			fprintln(w, "  [%d ahead]\t0 responses, %d failed", pos, p.ErrTotal)
+			continue
+		}
+		data := latsPercentiles(p.Lats, p.LatsTotal, []int{50, 99})
+		line := ""
+		if pos == 0 {
+			base = data[0]
+			line = ", like a keep-alive request"
+		} else if base > 0 {
+			line = fmt.Sprintf(", p50 %.2fx of no request ahead", data[0]/base)
+		}
+		fprintln(w, "  [%d ahead]\t%d responses, p50 %4.3f secs, p99 %4.3f secs%s", pos, p.LatsTotal, data[0], data[1], line)
+	}
+}
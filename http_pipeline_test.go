@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	var requests int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL,
+		C:             1,
+		N:             40,
+		Duration:      10,
+		Timeout:       2000,
+		Pipeline:      4,
+	})
+	if result.ErrTotal() > 0 || result.LatsTotal < 40 {
+		t.Fatalf("pipeline result = %d responses, errors: %v", result.LatsTotal, result.ErrorDist)
+	}
+
+	var total int64
+	for _, p := range result.PipelineDist {
+		total += p.LatsTotal
+	}
+	if total != result.LatsTotal || result.PipelineDist[0] == nil || len(result.PipelineDist) < 2 {
+		t.Fatalf("pipeline dist = %v, expect requests ahead on the connection", result.PipelineDist)
+	}
+	// the server handles pipelined requests one by one, so requests behind wait longer
+	last := len(result.PipelineDist) - 1
+	head := latsPercentiles(result.PipelineDist[0].Lats, result.PipelineDist[0].LatsTotal, []int{50})[0]
+	tail := latsPercentiles(result.PipelineDist[last].Lats, result.PipelineDist[last].LatsTotal, []int{50})[0]
+	if tail <= head {
+		t.Errorf("p50 of %d ahead = %4.3f, expect slower than %4.3f of no request ahead", last, tail, head)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	politeWindow        = time.Second      // window to evaluate error and overload rate
+	politeMaxRetryAfter = 60 * time.Second // cap of Retry-After pause
+)
+
+// StressRate offered rate limit changed by -polite
+type StressRate struct {
+	Time   int64  `json:"time"` // unix ms
+	Rps    int64  `json:"rps"`  // rate limit, 0 is unlimited
+	Reason string `json:"reason"`
+}
+
+// politeStats responses of current window, only accessed by the result collector
+type politeStats struct {
+	total, errs, overload int64
+	ceiling               int64 // observed rps before the first ramp-down
+}
+
+// parsePercent parse "5%" or "0.05" to fraction
+func parsePercent(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err == nil && strings.HasSuffix(s, "%") {
+		v /= 100
+	}
+	if err != nil || v < 0 || v > 1 {
+		return 0, fmt.Errorf("invalid percent: %s", s)
+	}
+	return v, nil
+}
+
+// parseRetryAfter parse Retry-After of seconds or http date, 0 is absent or invalid
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(value); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		d = t.Sub(now)
+	}
+	if d < 0 {
+		return 0
+	}
+	if d > politeMaxRetryAfter {
+		return politeMaxRetryAfter
+	}
+	return d
+}
+
+func isOverload(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
+}
+
+// politeCount count response of window, and pause sending when the server asks to retry later
+func (b *StressWorker) politeCount(res *result) {
+	b.polite.total++
+	if res.err != nil {
+		b.polite.errs++
+	} else if isOverload(res.statusCode) {
+		b.polite.overload++
+	}
+
+	if res.retryAfter > 0 {
+		now := time.Now()
+		if until := now.Add(res.retryAfter).UnixNano(); until > atomic.LoadInt64(&b.retryUntil) {
+			if atomic.LoadInt64(&b.retryUntil) < now.UnixNano() {
+				b.recordRate(now, atomic.LoadInt64(&b.politeRps), fmt.Sprintf("Retry-After %s, paused", res.retryAfter))
+			}
+			atomic.StoreInt64(&b.retryUntil, until)
+		}
+	}
+}
+
+// politeAdjust halve the rate when the error or 429/503 rate of the window exceeds the thresholds,
+// otherwise ramp up by 10% until the rate before overload
+func (b *StressWorker) politeAdjust(now time.Time) {
+	stats := b.polite
+	b.polite = politeStats{ceiling: stats.ceiling}
+	if stats.total == 0 {
+		return
+	}
+
+	var (
+		current      = atomic.LoadInt64(&b.politeRps)
+		errRate      = float64(stats.errs) / float64(stats.total)
+		overloadRate = float64(stats.overload) / float64(stats.total)
+		observed     = int64(float64(stats.total) / politeWindow.Seconds())
+		reason       string
+	)
+	switch {
+	case errRate > b.RequestParams.PoliteErrors:
+		reason = fmt.Sprintf("errors %.1f%%", errRate*100)
+	case overloadRate > b.RequestParams.PoliteOverload:
+		reason = fmt.Sprintf("429/503 %.1f%%", overloadRate*100)
+	}
+
+	if reason != "" {
+		if current == 0 {
+			current = observed
+			b.polite.ceiling = observed
+		}
+		next := current / 2
+		if next < 1 {
+			next = 1
+		}
+		if next != atomic.LoadInt64(&b.politeRps) {
+			b.recordRate(now, next, reason)
+		}
+		atomic.StoreInt64(&b.politeRps, next)
+		return
+	}
+
+	if current == 0 {
+		return
+	}
+	next := current + current/10 + 1
+	if next >= b.polite.ceiling {
+		b.recordRate(now, 0, "recovered")
+		atomic.StoreInt64(&b.politeRps, 0)
+		return
+	}
+	b.recordRate(now, next, "ramp up")
+	atomic.StoreInt64(&b.politeRps, next)
+}
+
+func (b *StressWorker) recordRate(now time.Time, rps int64, reason string) {
+	verbosePrint(vINFO, "polite rate: %d req/s, %s", rps, reason)
+	b.rateCurve = append(b.rateCurve, StressRate{Time: now.UnixMilli(), Rps: rps, Reason: reason})
+}
+
+// pace wait for Retry-After pause, and the rate limit of polite mode and live adjustment,
+// the lower one is used when both are set
+func (b *StressWorker) pace(client *StressClient) {
+	for !b.IsStop() {
+		wait := time.Until(time.Unix(0, atomic.LoadInt64(&b.retryUntil)))
+		rps := atomic.LoadInt64(&b.politeRps)
+		if live := atomic.LoadInt64(&b.liveQps); live > 0 && (rps <= 0 || live < rps) {
+			rps = live
+		}
+		if rps > 0 {
+			c := atomic.LoadInt64(&b.concurrency)
+			if c < 1 {
+				c = 1
+			}
+			interval := time.Duration(c * int64(time.Second) / rps)
+			if w := time.Until(client.lastSend.Add(interval)); w > wait {
+				wait = w
+			}
+		}
+		if wait <= 0 {
+			break
+		}
+		if wait > 100*time.Millisecond {
+			wait = 100 * time.Millisecond // check stop in time
+		}
+		time.Sleep(wait)
+	}
+	client.lastSend = time.Now()
+}
+
+// printRateCurve Print adaptive rate of polite mode
+func (result *StressResult) printRateCurve(w io.Writer) {
+	fprintln(w, "\nPolite rate:")
+	for _, r := range result.RateCurve {
+		rps := "unlimited"
+		if r.Rps > 0 {
+			rps = fmt.Sprintf("%d req/s", r.Rps)
+		}
+		fprintln(w, "  [%s]\t%s\t%s", time.UnixMilli(r.Time).Format("15:04:05.000"), rps, r.Reason)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPoliteAdjust(t *testing.T) {
+	b := &StressWorker{RequestParams: &StressParameters{C: 1, Polite: true, PoliteErrors: 0.05, PoliteOverload: 0.01}}
+	now := time.Now()
+
+	for _, v := range []struct {
+		statusCode int
+		overload   int
+		expect     int64
+	}{
+		{statusCode: http.StatusTooManyRequests, overload: 10, expect: 50}, // observed 100 rps, halve
+		{statusCode: http.StatusServiceUnavailable, overload: 5, expect: 25},
+		{statusCode: http.StatusOK, expect: 28}, // ramp up by 10%
+		{statusCode: http.StatusOK, expect: 31},
+	} {
+		for i := 0; i < 100; i++ {
+			res := &result{statusCode: http.StatusOK}
+			if i < v.overload {
+				res.statusCode = v.statusCode
+			}
+			b.politeCount(res)
+		}
+		b.politeAdjust(now)
+		if b.politeRps != v.expect {
+			t.Errorf("politeAdjust rate = %d, expect: %d", b.politeRps, v.expect)
+		}
+	}
+
+	b.politeRps = 95
+	b.politeCount(&result{statusCode: http.StatusOK})
+	b.politeAdjust(now)
+	if b.politeRps != 0 || b.rateCurve[len(b.rateCurve)-1].Reason != "recovered" {
+		t.Errorf("politeAdjust near ceiling = %d, expect unlimited", b.politeRps)
+	}
+
+	if d := parseRetryAfter("3", now); d != 3*time.Second {
+		t.Errorf("parseRetryAfter(3) = %s", d)
+	}
+	if d := parseRetryAfter(now.Add(time.Hour).UTC().Format(http.TimeFormat), now); d != politeMaxRetryAfter {
+		t.Errorf("parseRetryAfter(date) = %s, expect: %s", d, politeMaxRetryAfter)
+	}
+	if v, err := parsePercent("5%"); err != nil || v != 0.05 {
+		t.Errorf("parsePercent(5%%) = %v, %v", v, err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const timeWaitSeconds = 60 // TIME_WAIT of linux, a closed connection holds its ephemeral port
+
+// StressPreflight limits of the generator checked before the load, e.g. open files and ephemeral ports
+type StressPreflight struct {
+	Generator string   `json:"generator"` // hostname of the load generator
+	Notes     []string `json:"notes"`     // limits raised and warnings with advice
+}
+
+// maxConns connections the worker opens at most, of -c, -rate and -hold-connections
+func (b *StressWorker) maxConns() int64 {
+	p := b.RequestParams
+	conns := int64(p.C)
+	if p.ArrivalRate > 0 {
+		conns = int64(maxArrivalClients(p.ArrivalRate, p.Timeout, p.C))
+	}
+	return conns + int64(p.HoldConnections)
+}
+
+// preflight check the open files and ephemeral ports of the generator for the connections before the
+// load, the soft limit of open files is raised to the hard limit when too low, nil if nothing to note,
+// so that the run warns once instead of flooding dial errors
+func (b *StressWorker) preflight() *StressPreflight {
+	var (
+		notes []string
+		conns = b.maxConns()
+		need  = conns + diagnoseFdReserve
+	)
+	if limit := fileLimit(); limit > 0 && limit < need {
+		if raised := raiseFileLimit(need); raised > limit {
+			notes = append(notes, fmt.Sprintf("raised open files limit from %d to %d for %d connections", limit, raised, conns))
+			limit = raised
+		}
+		if limit < need {
+			notes = append(notes, fmt.Sprintf("open files limit %d is low for %d connections, dials will fail with \"too many open files\", raise the hard limit with ulimit -Hn or LimitNOFILE of systemd",
+				limit, conns))
+		}
+	}
+
+	ports, count := portRange(readProc("/proc/sys/net/ipv4/ip_local_port_range"))
+	if count > 0 {
+		if b.localIps != nil {
+			count *= int64(len(b.localIps.ips)) // each source address has its own ports
+		}
+		p := b.RequestParams
+		switch {
+		case conns > count:
+			notes = append(notes, fmt.Sprintf("%d connections exceed %d ephemeral ports (%s) to one destination, widen net.ipv4.ip_local_port_range or add source addresses with -local-ip-range",
+				conns, count, ports))
+		case p.DisableKeepAlives && procInt("/proc/sys/net/ipv4/tcp_tw_reuse") != 1 &&
+			(p.Qps <= 0 && p.ArrivalRate <= 0 || p.ArrivalRate > float64(count/timeWaitSeconds)):
+			notes = append(notes, fmt.Sprintf("-disable-keepalive opens a connection per request and the port stays in TIME_WAIT for %ds, more than %d requests/s exhaust %d ephemeral ports, keep-alive or net.ipv4.tcp_tw_reuse=1 avoids it",
+				timeWaitSeconds, count/timeWaitSeconds, count))
+		}
+	}
+	if len(notes) <= 0 {
+		return nil
+	}
+	pf := &StressPreflight{Notes: notes}
+	pf.Generator, _ = os.Hostname()
+	for _, note := range notes {
+		eprintln("preflight: %s", note)
+	}
+	return pf
+}
+
+// printPreflight Print limits of the generators raised or too low for the connections
+func (result *StressResult) printPreflight(w io.Writer) {
+	for _, pf := range result.Preflight {
+		fprintln(w, "\nPreflight of %s:", pf.Generator)
+		for _, note := range pf.Notes {
+			fprintln(w, "  - %s", note)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPreflight(t *testing.T) {
+	limit := fileLimit()
+	if limit <= 0 || readProc("/proc/sys/net/ipv4/ip_local_port_range") == "" {
+		t.Skip("open files limit or ephemeral ports unknown")
+	}
+	_, ports := portRange(readProc("/proc/sys/net/ipv4/ip_local_port_range"))
+
+	b := &StressWorker{RequestParams: &StressParameters{C: 10, Qps: 10}}
+	if pf := b.preflight(); pf != nil {
+		t.Errorf("preflight of 10 connections = %+v, expect nil", pf)
+	}
+	b.RequestParams.C = int(ports + 1)
+	pf := b.preflight()
+	if pf == nil || !strings.Contains(strings.Join(pf.Notes, "\n"), "ephemeral ports") {
+		t.Fatalf("preflight of %d connections = %+v, expect ephemeral ports exceeded", b.RequestParams.C, pf)
+	}
+	if fileLimit() < limit {
+		t.Errorf("open files limit lowered from %d to %d", limit, fileLimit())
+	}
+
+	b.RequestParams.C, b.RequestParams.Qps, b.RequestParams.DisableKeepAlives = 10, 0, true
+	if procInt("/proc/sys/net/ipv4/tcp_tw_reuse") != 1 {
+		if pf := b.preflight(); pf == nil || !strings.Contains(pf.Notes[0], "TIME_WAIT") {
+			t.Errorf("preflight of -disable-keepalive = %+v, expect TIME_WAIT", pf)
+		}
+	}
+
+	result := &StressResult{Preflight: []StressPreflight{{Generator: "gen1", Notes: []string{"raised open files limit from 1024 to 2048 for 1500 connections"}}}}
+	var buf bytes.Buffer
+	result.printPreflight(&buf)
+	for _, line := range []string{"Preflight of gen1:", "  - raised open files limit from 1024 to 2048"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printPreflight expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// StressWorkerMetrics live metrics of a worker in the metrics of the controller, cumulative since the start
+type StressWorkerMetrics struct {
+	Worker string `json:"worker"` // address with the password hidden
+	StressPoint
+}
+
+// tagWorkerMetrics record the metrics of worker in its result of cmdMetrics, the workers of a controller
+// which has workers again are kept
+func tagWorkerMetrics(result *StressResult, worker string) {
+	if len(result.WorkerMetrics) > 0 {
+		return
+	}
+	result.WorkerMetrics = []StressWorkerMetrics{{Worker: redactWorker(worker),
+		StressPoint: StressPoint{LatsTotal: result.LatsTotal, ErrTotal: result.ErrTotal(), Lats: result.Lats}}}
+}
+
+func sortWorkerMetrics(metrics []StressWorkerMetrics) {
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Worker < metrics[j].Worker })
+}
+
+func (m *StressWorkerMetrics) result() *StressResult {
+	result := GetStressResult()
+	result.LatsTotal, result.Lats = m.LatsTotal, m.Lats
+	if m.ErrTotal > 0 {
+		result.ErrorDist["error"] = int(m.ErrTotal)
+	}
+	return result
+}
+
+// watchProgress print interval metrics of -progress until stop, the metrics are collected by cmdMetrics,
+// so that distributed workers are included
+func watchProgress(params StressParameters, every time.Duration, stop chan struct{}) {
+	params.Cmd = cmdMetrics
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	start, prev := time.Now(), GetStressResult()
+	prevWorkers := make(map[string]*StressResult)
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			_, cur := executeStress(params)
+			if cur == nil || cur.ErrCode != 0 {
+				continue
+			}
+			eprintln("%s", progressLine(now.Sub(start), every, prev, cur))
+			prev = cur
+			// breakdown of distributed workers
+			for i := range cur.WorkerMetrics {
+				m := &cur.WorkerMetrics[i]
+				workerPrev, ok := prevWorkers[m.Worker]
+				if !ok {
+					workerPrev = GetStressResult()
+				}
+				eprintln("  %s %s", m.Worker, progressLine(now.Sub(start), every, workerPrev, m.result()))
+				prevWorkers[m.Worker] = m.result()
+			}
+		}
+	}
+}
+
+// progressLine requests, failures and latency percentiles of the interval since prev
+func progressLine(elapsed, interval time.Duration, prev, cur *StressResult) string {
+	requests, failed, lats := intervalDelta(prev, cur)
+	line := fmt.Sprintf("[%v] %d responses, %4.3f requests/sec, %d failed", elapsed.Round(time.Second),
+		requests, float64(requests+failed)/interval.Seconds(), failed)
+
+	if requests > 0 {
+		data := latsPercentiles(lats, requests, []int{50, 90, 99})
+		line += fmt.Sprintf(", p50 %4.3f secs, p90 %4.3f secs, p99 %4.3f secs", data[0], data[1], data[2])
+	}
+	return line + fmt.Sprintf(" (total %d responses, %d failed)", cur.LatsTotal, cur.ErrTotal())
+}
+
+// intervalDelta responses, failures and latency distribution since prev, the window of continuous
+// mode restarts the counts
+func intervalDelta(prev, cur *StressResult) (requests, failed int64, lats map[string]int64) {
+	if cur.LatsTotal < prev.LatsTotal || cur.ErrTotal() < prev.ErrTotal() {
+		prev = GetStressResult()
+	}
+	lats = make(map[string]int64, len(cur.Lats))
+	for k, c := range cur.Lats {
+		if c -= prev.Lats[k]; c > 0 {
+			lats[k] = c
+		}
+	}
+	return cur.LatsTotal - prev.LatsTotal, cur.ErrTotal() - prev.ErrTotal(), lats
+}
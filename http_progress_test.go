@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressLine(t *testing.T) {
+	prev, cur := GetStressResult(), GetStressResult()
+	prev.LatsTotal, prev.Lats["0.010"] = 10, 10
+	prev.ErrorDist["timeout"] = 1
+	cur.LatsTotal, cur.Lats["0.010"], cur.Lats["0.100"] = 30, 20, 10
+	cur.ErrorDist["timeout"] = 3
+
+	line := progressLine(10*time.Second, 5*time.Second, prev, cur)
+	for _, expect := range []string{
+		"[10s] 20 responses, 4.400 requests/sec, 2 failed",
+		"p50 0.010 secs",
+		"p99 0.100 secs",
+		"(total 30 responses, 3 failed)",
+	} {
+		if !strings.Contains(line, expect) {
+			t.Errorf("progress = %q, expect %q", line, expect)
+		}
+	}
+
+	// the window of continuous mode restarts the counts
+	line = progressLine(15*time.Second, 5*time.Second, cur, prev)
+	if !strings.HasPrefix(line, "[15s] 10 responses, 2.200 requests/sec, 1 failed") {
+		t.Errorf("progress after window = %q", line)
+	}
+}
+
+func TestWorkerMetrics(t *testing.T) {
+	savedWorkers := workerList
+	defer func() { workerList = savedWorkers }()
+
+	a, _ := fakeWorker(time.Hour, false)
+	defer a.Close()
+	b, _ := fakeWorker(time.Hour, false)
+	defer b.Close()
+	workerList = flagSlice{b.URL, a.URL}
+
+	_, result := executeStress(StressParameters{SequenceId: time.Now().UnixNano(), Cmd: cmdMetrics})
+	if result == nil || result.LatsTotal != 10 || len(result.WorkerMetrics) != 2 {
+		t.Fatalf("metrics of workers = %+v", result)
+	}
+	for i, m := range result.WorkerMetrics {
+		if i > 0 && m.Worker < result.WorkerMetrics[i-1].Worker {
+			t.Errorf("worker metrics not sorted: %+v", result.WorkerMetrics)
+		}
+		if (m.Worker != a.URL && m.Worker != b.URL) || m.LatsTotal != 5 || m.Lats["0.001"] != 5 {
+			t.Errorf("worker metrics = %+v", m)
+		}
+	}
+
+	// a controller with workers again keeps the metrics of its workers
+	nested := &StressResult{WorkerMetrics: []StressWorkerMetrics{{Worker: "10.0.0.1:12710"}}}
+	tagWorkerMetrics(nested, "10.0.0.2:12710")
+	if len(nested.WorkerMetrics) != 1 || nested.WorkerMetrics[0].Worker != "10.0.0.1:12710" {
+		t.Errorf("worker metrics of nested controller = %+v", nested.WorkerMetrics)
+	}
+
+	m := StressWorkerMetrics{Worker: "w", StressPoint: StressPoint{LatsTotal: 20, ErrTotal: 2, Lats: map[string]int64{"0.010": 20}}}
+	line := progressLine(10*time.Second, 5*time.Second, GetStressResult(), m.result())
+	if !strings.HasPrefix(line, "[10s] 20 responses, 4.400 requests/sec, 2 failed, p50 0.010 secs") {
+		t.Errorf("progress of worker = %q", line)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	projectScenario   = "scenario.yaml"
+	projectAssertions = "assertions.yaml"
+	projectFeeds      = "feeds"
+	projectOutput     = "output"
+)
+
+var projectAsserts []assertion // assertions of "http_bench run", checked after each url
+
+// assertion threshold of assertions.yaml
+type assertion struct {
+	name  string  // max-error-rate, availability, min-rps or latency percentile, e.g. p99
+	value float64 // rate, requests per second or seconds
+}
+
+// yamlEntry key and values of the yaml subset of project files
+type yamlEntry struct {
+	key    string
+	values []string
+}
+
+// parseYaml parse the flat subset of yaml used by project files, "key: value" and lists of
+// "- value" under "key:", lines starting with # are comments, and quoted keys may contain ":"
+func parseYaml(r io.Reader) ([]yamlEntry, error) {
+	var entries []yamlEntry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if item := strings.TrimPrefix(text, "-"); item != text {
+			if len(entries) <= 0 {
+				return nil, fmt.Errorf("line %d: list item without key", line)
+			}
+			last := &entries[len(entries)-1]
+			last.values = append(last.values, yamlValue(item))
+			continue
+		}
+		key, value, ok := strings.Cut(text, ":")
+		if q := text[0]; q == '"' || q == '\'' {
+			if end := strings.IndexByte(text[1:], q); end >= 0 {
+				key, value, ok = text[:end+2], "", false
+				if rest := strings.TrimSpace(text[end+2:]); strings.HasPrefix(rest, ":") {
+					value, ok = rest[1:], true
+				}
+				key = yamlValue(key)
+			}
+		}
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("line %d: expect \"key: value\", got: %s", line, text)
+		}
+		entry := yamlEntry{key: strings.TrimSpace(key)}
+		if value = strings.TrimSpace(value); value != "" {
+			entry.values = []string{yamlValue(value)}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// yamlValue unquote "value" or 'value'
+func yamlValue(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		if v, err := strconv.Unquote(s); err == nil {
+			return v
+		}
+	}
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	}
+	return s
+}
+
+func parseYamlFile(path string) ([]yamlEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := parseYaml(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return entries, nil
+}
+
+// scenarioArgs command line options of scenario.yaml, keys are the options without "-"
+func scenarioArgs(entries []yamlEntry) ([]string, error) {
+	var args []string
+	for _, entry := range entries {
+		if flag.Lookup(entry.key) == nil {
+			return nil, fmt.Errorf("%s: unknown option %q", projectScenario, entry.key)
+		}
+		for _, value := range entry.values {
+			args = append(args, "-"+entry.key+"="+value)
+		}
+	}
+	return args, nil
+}
+
+// parseAssertions parse thresholds of assertions file, e.g. "max-error-rate: 1%", "availability: 99.9%",
+// "min-rps: 100", "p99: 500ms"
+func parseAssertions(file string, entries []yamlEntry) ([]assertion, error) {
+	var asserts []assertion
+	for _, entry := range entries {
+		if len(entry.values) != 1 {
+			return nil, fmt.Errorf("%s: %s expects one value", file, entry.key)
+		}
+		a, value := assertion{name: entry.key}, entry.values[0]
+		var err error
+		switch {
+		case a.name == "max-error-rate", a.name == "availability":
+			a.value, err = parsePercent(value)
+		case a.name == "min-rps":
+			a.value, err = strconv.ParseFloat(value, 64)
+		case strings.HasPrefix(a.name, "p"):
+			var p int
+			var d time.Duration
+			if p, err = strconv.Atoi(a.name[1:]); err == nil && (p <= 0 || p > 100) {
+				err = fmt.Errorf("percentile out of range")
+			}
+			if err == nil {
+				d, err = time.ParseDuration(value)
+				a.value = d.Seconds()
+			}
+		default:
+			return nil, fmt.Errorf("%s: unknown assertion %q, supports max-error-rate, availability, min-rps and "+
+				"percentiles, e.g. p99", file, a.name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid %s: %s", file, a.name, value)
+		}
+		asserts = append(asserts, a)
+	}
+	return asserts, nil
+}
+
+// checkAssertion actual value of the assertion in the result, and whether the assertion holds
+func (result *StressResult) checkAssertion(a assertion) (string, bool) {
+	total := result.LatsTotal + result.ErrTotal()
+	switch a.name {
+	case "max-error-rate":
+		var rate float64
+		if total > 0 {
+			rate = float64(result.ErrTotal()) / float64(total)
+		}
+		return fmt.Sprintf("%.2f%%", rate*100), rate <= a.value
+	case "availability":
+		// failed requests and 5xx responses are unavailable
+		var rate float64
+		if total > 0 {
+			available := result.LatsTotal
+			for code, c := range result.StatusCodeDist {
+				if code >= 500 && code < 600 {
+					available -= int64(c)
+				}
+			}
+			rate = float64(available) / float64(total)
+		}
+		return fmt.Sprintf("%.2f%%", rate*100), rate >= a.value
+	case "min-rps":
+		rps := float64(result.Rps) / scaleNum
+		return fmt.Sprintf("%4.3f", rps), rps >= a.value
+	default:
+		p, _ := strconv.Atoi(a.name[1:])
+		v := result.Percentile(p)
+		return fmt.Sprintf("%4.3f secs", v), v <= a.value
+	}
+}
+
+// checkAssertions failed assertions of the result
+func (result *StressResult) checkAssertions(asserts []assertion) []string {
+	var failed []string
+	for _, a := range asserts {
+		actual, ok := result.checkAssertion(a)
+		if ok {
+			continue
+		}
+		switch a.name {
+		case "max-error-rate":
+			failed = append(failed, fmt.Sprintf("error rate %s > %.2f%%", actual, a.value*100))
+		case "availability":
+			failed = append(failed, fmt.Sprintf("availability %s < %.2f%%", actual, a.value*100))
+		case "min-rps":
+			failed = append(failed, fmt.Sprintf("requests/sec %s < %4.3f", actual, a.value))
+		default:
+			failed = append(failed, fmt.Sprintf("%s %s > %4.3f secs", a.name, actual, a.value))
+		}
+	}
+	return failed
+}
+
+// loadProject change to the project directory and return the options of scenario.yaml, the
+// summary and a json result in output/ are written unless "o" is set
+func loadProject(dir string) ([]string, error) {
+	if err := os.Chdir(dir); err != nil {
+		return nil, err
+	}
+	entries, err := parseYamlFile(projectScenario)
+	if err != nil {
+		return nil, err
+	}
+	args, err := scenarioArgs(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	var hasOutput bool
+	for _, entry := range entries {
+		hasOutput = hasOutput || entry.key == "o"
+	}
+	if !hasOutput {
+		if err := os.MkdirAll(projectOutput, 0755); err != nil {
+			return nil, err
+		}
+		file := filepath.Join(projectOutput, "result-"+time.Now().Format("20060102-150405")+".json")
+		args = append(args, "-o="+outputSummary, "-o="+outputJson+":"+file)
+	}
+
+	if entries, err = parseYamlFile(projectAssertions); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if projectAsserts, err = parseAssertions(projectAssertions, entries); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+const (
+	scenarioTemplate = `# Benchmark scenario of "http_bench run", keys are the options of http_bench without "-",
+# and repeated options are lists. Relative paths are resolved in the project directory,
+# e.g. "url-file: feeds/urls.txt". The summary and a json result are written to output/
+# unless "o" is set.
+url: http://127.0.0.1:8080/
+m: GET
+c: 10
+d: 10s
+t: 3000
+H:
+  - "Accept: application/json"
+# url-file: feeds/urls.txt
+`
+	assertionsTemplate = `# Assertions checked after "http_bench run", a failed assertion exits with 3.
+# max-error-rate: max rate of failed requests, e.g. 1%
+# availability: min rate of requests neither failed nor 5xx, e.g. 99.9%
+# min-rps: min requests per second
+# p50, p90, p95, p99: max latency of the percentile, e.g. 500ms
+max-error-rate: 1%
+p99: 1s
+`
+	feedTemplate = `GET http://127.0.0.1:8080/
+`
+	outputIgnore = `*
+!.gitignore
+`
+)
+
+// initProject scaffold a benchmark project directory of scenario, assertions, feeds and output
+func initProject(dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, projectScenario)); err == nil {
+		return fmt.Errorf("%s already exists", filepath.Join(dir, projectScenario))
+	}
+	for _, sub := range []string{projectFeeds, projectOutput} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return err
+		}
+	}
+	for name, content := range map[string]string{
+		projectScenario:                            scenarioTemplate,
+		projectAssertions:                          assertionsTemplate,
+		filepath.Join(projectFeeds, "urls.txt"):    feedTemplate,
+		filepath.Join(projectOutput, ".gitignore"): outputIgnore,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYaml(t *testing.T) {
+	entries, err := parseYaml(strings.NewReader(`# scenario
+url: http://127.0.0.1:8080/?a=1
+c: 10
+H:
+  - "Accept: application/json"
+  - 'X-Name: it''s'
+`))
+	if err != nil {
+		t.Fatalf("parseYaml err: %v", err)
+	}
+	expect := []yamlEntry{
+		{key: "url", values: []string{"http://127.0.0.1:8080/?a=1"}},
+		{key: "c", values: []string{"10"}},
+		{key: "H", values: []string{"Accept: application/json", "X-Name: it's"}},
+	}
+	if !reflect.DeepEqual(entries, expect) {
+		t.Errorf("parseYaml = %+v, expect: %+v", entries, expect)
+	}
+
+	if args, err := scenarioArgs(entries[:2]); err != nil || !reflect.DeepEqual(args, []string{"-url=http://127.0.0.1:8080/?a=1", "-c=10"}) {
+		t.Errorf("scenarioArgs = %v, %v", args, err)
+	}
+	if _, err := scenarioArgs([]yamlEntry{{key: "no-such-option", values: []string{"1"}}}); err == nil {
+		t.Errorf("scenarioArgs of unknown option, expect err")
+	}
+	if _, err := parseYaml(strings.NewReader("- orphan\n")); err == nil {
+		t.Errorf("parseYaml list item without key, expect err")
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	asserts, err := parseAssertions(projectAssertions, []yamlEntry{
+		{key: "max-error-rate", values: []string{"10%"}},
+		{key: "min-rps", values: []string{"100"}},
+		{key: "p99", values: []string{"500ms"}},
+	})
+	if err != nil {
+		t.Fatalf("parseAssertions err: %v", err)
+	}
+	for _, invalid := range []yamlEntry{{key: "p101", values: []string{"1s"}}, {key: "p99", values: []string{"fast"}}, {key: "max-latency", values: []string{"1s"}}} {
+		if _, err := parseAssertions(projectAssertions, []yamlEntry{invalid}); err == nil {
+			t.Errorf("parseAssertions(%+v), expect err", invalid)
+		}
+	}
+
+	result := StressResult{LatsTotal: 90, Rps: 200 * scaleNum, Lats: map[string]int64{"0.100": 80, "0.600": 10},
+		ErrorDist: map[string]int{"timeout": 10}}
+	if failed := result.checkAssertions(asserts); len(failed) != 1 || !strings.HasPrefix(failed[0], "p99") {
+		t.Errorf("checkAssertions = %v, expect p99 failed", failed)
+	}
+	result.Lats, result.Rps = map[string]int64{"0.100": 90}, 50*scaleNum
+	result.ErrorDist["timeout"] = 20
+	if failed := result.checkAssertions(asserts); len(failed) != 2 {
+		t.Errorf("checkAssertions = %v, expect error rate and rps failed", failed)
+	}
+}
+
+func TestInitProject(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "bench")
+	if err := initProject(dir); err != nil {
+		t.Fatalf("initProject err: %v", err)
+	}
+	if err := initProject(dir); err == nil {
+		t.Errorf("initProject existing project, expect err")
+	}
+	if _, err := parseYamlFile(filepath.Join(dir, projectScenario)); err != nil {
+		t.Errorf("parse scenario err: %v", err)
+	}
+	entries, err := parseYamlFile(filepath.Join(dir, projectAssertions))
+	if err != nil {
+		t.Fatalf("parse assertions err: %v", err)
+	}
+	if _, err := parseAssertions(projectAssertions, entries); err != nil {
+		t.Errorf("parseAssertions err: %v", err)
+	}
+	if entries, errs, err := parseUrlFile(filepath.Join(dir, projectFeeds, "urls.txt")); err != nil || len(errs) > 0 || len(entries) != 1 {
+		t.Errorf("parse feed = %v, %v, %v", entries, errs, err)
+	}
+}
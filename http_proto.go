@@ -0,0 +1,681 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A tiny protobuf support which parses messages and enums of a single .proto file
+// (no imports, groups and extensions), and encodes JSON to protobuf binary.
+
+const (
+	protoWireVarint = 0
+	protoWire64     = 1
+	protoWireBytes  = 2
+	protoWire32     = 5
+)
+
+var protoScalarWires = map[string]int{
+	"int32": protoWireVarint, "int64": protoWireVarint, "uint32": protoWireVarint, "uint64": protoWireVarint,
+	"sint32": protoWireVarint, "sint64": protoWireVarint, "bool": protoWireVarint,
+	"fixed64": protoWire64, "sfixed64": protoWire64, "double": protoWire64,
+	"fixed32": protoWire32, "sfixed32": protoWire32, "float": protoWire32,
+	"string": protoWireBytes, "bytes": protoWireBytes,
+}
+
+type protoField struct {
+	name     string
+	jsonName string
+	number   int
+	typ      string // scalar type or full name of message/enum after resolved
+	repeated bool
+	message  *protoMessage    // message or map entry type
+	enum     map[string]int64 // enum type
+	isMap    bool
+}
+
+type protoMessage struct {
+	fullName string
+	fields   []*protoField
+}
+
+type protoSchema struct {
+	messages map[string]*protoMessage
+	enums    map[string]map[string]int64
+	scopes   map[*protoField]string // scope of field to resolve type
+}
+
+type protoParser struct {
+	tokens []string
+	pos    int
+	schema *protoSchema
+}
+
+// parseProto parse .proto content and return message of msgName(full name, e.g. my.pkg.Request)
+func parseProto(content, msgName string) (*protoMessage, error) {
+	p := &protoParser{
+		tokens: protoTokenize(content),
+		schema: &protoSchema{
+			messages: make(map[string]*protoMessage, 0),
+			enums:    make(map[string]map[string]int64, 0),
+			scopes:   make(map[*protoField]string, 0),
+		},
+	}
+	if err := p.parseFile(); err != nil {
+		return nil, err
+	}
+	if err := p.schema.resolve(); err != nil {
+		return nil, err
+	}
+
+	msg, ok := p.schema.messages[strings.TrimPrefix(msgName, ".")]
+	if !ok {
+		return nil, fmt.Errorf("proto message %s not found", msgName)
+	}
+	return msg, nil
+}
+
+func protoTokenize(content string) []string {
+	var tokens []string
+	for i := 0; i < len(content); {
+		ch := content[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n':
+			i++
+		case strings.HasPrefix(content[i:], "//"):
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(content[i:], "/*"):
+			if end := strings.Index(content[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(content)
+			}
+		case ch == '"' || ch == '\'':
+			j := i + 1
+			for j < len(content) && content[j] != ch {
+				if content[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(content) {
+				j++
+			}
+			tokens = append(tokens, content[i:j])
+			i = j
+		case isProtoIdentChar(ch) || ch == '-':
+			j := i + 1
+			for j < len(content) && isProtoIdentChar(content[j]) {
+				j++
+			}
+			tokens = append(tokens, content[i:j])
+			i = j
+		default:
+			tokens = append(tokens, string(ch))
+			i++
+		}
+	}
+	return tokens
+}
+
+func isProtoIdentChar(ch byte) bool {
+	return ch == '_' || ch == '.' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
+
+func (p *protoParser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+func (p *protoParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *protoParser) expect(token string) error {
+	if v := p.next(); v != token {
+		return fmt.Errorf("proto expect %q but got %q", token, v)
+	}
+	return nil
+}
+
+// skipStatement skip tokens until ";" or a balanced "{...}" block
+func (p *protoParser) skipStatement() {
+	depth := 0
+	for p.pos < len(p.tokens) {
+		switch p.next() {
+		case "{":
+			depth++
+		case "}":
+			if depth--; depth <= 0 {
+				return
+			}
+		case ";":
+			if depth <= 0 {
+				return
+			}
+		}
+	}
+}
+
+func (p *protoParser) parseFile() error {
+	pkg := ""
+	for p.pos < len(p.tokens) {
+		switch p.peek() {
+		case "package":
+			p.next()
+			pkg = p.next()
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		case "message":
+			if err := p.parseMessage(pkg); err != nil {
+				return err
+			}
+		case "enum":
+			if err := p.parseEnum(pkg); err != nil {
+				return err
+			}
+		case ";":
+			p.next()
+		default: // syntax, import, option, service...
+			p.skipStatement()
+		}
+	}
+	return nil
+}
+
+func joinProtoName(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func (p *protoParser) parseMessage(scope string) error {
+	p.next() // message
+	msg := &protoMessage{fullName: joinProtoName(scope, p.next())}
+	p.schema.messages[msg.fullName] = msg
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	return p.parseMessageBody(msg, false)
+}
+
+func (p *protoParser) parseMessageBody(msg *protoMessage, isOneof bool) error {
+	for {
+		switch tok := p.peek(); tok {
+		case "":
+			return fmt.Errorf("proto message %s not closed", msg.fullName)
+		case "}":
+			p.next()
+			return nil
+		case ";":
+			p.next()
+		case "message":
+			if err := p.parseMessage(msg.fullName); err != nil {
+				return err
+			}
+		case "enum":
+			if err := p.parseEnum(msg.fullName); err != nil {
+				return err
+			}
+		case "oneof":
+			p.next()
+			p.next() // name
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+			if err := p.parseMessageBody(msg, true); err != nil {
+				return err
+			}
+		case "option", "reserved", "extensions", "extend":
+			p.skipStatement()
+		default:
+			if err := p.parseField(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *protoParser) parseField(msg *protoMessage) error {
+	field := &protoField{}
+	switch p.peek() {
+	case "repeated":
+		field.repeated = true
+		p.next()
+	case "optional", "required":
+		p.next()
+	}
+
+	if p.peek() == "map" {
+		p.next()
+		entry := &protoMessage{}
+		keyField, valueField := &protoField{name: "key", jsonName: "key", number: 1},
+			&protoField{name: "value", jsonName: "value", number: 2}
+		if err := p.expect("<"); err != nil {
+			return err
+		}
+		keyField.typ = p.next()
+		if err := p.expect(","); err != nil {
+			return err
+		}
+		valueField.typ = p.next()
+		if err := p.expect(">"); err != nil {
+			return err
+		}
+		entry.fields = []*protoField{keyField, valueField}
+		p.schema.scopes[valueField] = msg.fullName
+		field.typ, field.message, field.isMap, field.repeated = "map", entry, true, true
+	} else {
+		field.typ = p.next()
+	}
+
+	field.name = p.next()
+	field.jsonName = protoJsonName(field.name)
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	number, err := strconv.Atoi(p.next())
+	if err != nil {
+		return fmt.Errorf("proto field %s.%s invalid number: %v", msg.fullName, field.name, err)
+	}
+	field.number = number
+	if p.peek() == "[" {
+		for p.pos < len(p.tokens) && p.next() != "]" {
+		}
+	}
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+
+	if !field.isMap {
+		p.schema.scopes[field] = msg.fullName
+	}
+	msg.fields = append(msg.fields, field)
+	return nil
+}
+
+func (p *protoParser) parseEnum(scope string) error {
+	p.next() // enum
+	name := joinProtoName(scope, p.next())
+	values := make(map[string]int64, 0)
+	p.schema.enums[name] = values
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		switch tok := p.next(); tok {
+		case "":
+			return fmt.Errorf("proto enum %s not closed", name)
+		case "}":
+			return nil
+		case ";":
+		case "option", "reserved":
+			p.pos--
+			p.skipStatement()
+		default:
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			v, err := strconv.ParseInt(p.next(), 0, 32)
+			if err != nil {
+				return fmt.Errorf("proto enum %s.%s invalid value: %v", name, tok, err)
+			}
+			values[tok] = v
+			if p.peek() == "[" {
+				for p.pos < len(p.tokens) && p.next() != "]" {
+				}
+			}
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// resolve resolve message and enum types of fields by protobuf scope rules
+func (s *protoSchema) resolve() error {
+	for field, scope := range s.scopes {
+		if _, ok := protoScalarWires[field.typ]; ok {
+			continue
+		}
+
+		name := field.typ
+		candidates := []string{strings.TrimPrefix(name, ".")}
+		if !strings.HasPrefix(name, ".") {
+			candidates = nil
+			for sc := scope; ; {
+				candidates = append(candidates, joinProtoName(sc, name))
+				if sc == "" {
+					break
+				}
+				if i := strings.LastIndex(sc, "."); i >= 0 {
+					sc = sc[:i]
+				} else {
+					sc = ""
+				}
+			}
+		}
+
+		resolved := false
+		for _, candidate := range candidates {
+			if msg, ok := s.messages[candidate]; ok {
+				field.typ, field.message, resolved = candidate, msg, true
+				break
+			}
+			if enum, ok := s.enums[candidate]; ok {
+				field.typ, field.enum, resolved = candidate, enum, true
+				break
+			}
+		}
+		if !resolved {
+			return fmt.Errorf("proto type %s not found in %s", name, scope)
+		}
+	}
+	return nil
+}
+
+func protoJsonName(name string) string {
+	var b strings.Builder
+	upper := false
+	for i := 0; i < len(name); i++ {
+		if name[i] == '_' {
+			upper = true
+			continue
+		}
+		if upper && name[i] >= 'a' && name[i] <= 'z' {
+			b.WriteByte(name[i] - 'a' + 'A')
+		} else {
+			b.WriteByte(name[i])
+		}
+		upper = false
+	}
+	return b.String()
+}
+
+// encodeJSON encode JSON body to protobuf binary
+func (m *protoMessage) encodeJSON(body []byte) ([]byte, error) {
+	var obj map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&obj); err != nil {
+		return nil, errors.New("invalid protobuf json body: " + err.Error())
+	}
+	return m.encode(nil, obj)
+}
+
+func (m *protoMessage) encode(buf []byte, obj map[string]interface{}) ([]byte, error) {
+	used := 0
+	for _, field := range m.fields {
+		v, ok := obj[field.jsonName]
+		if !ok {
+			v, ok = obj[field.name]
+		}
+		if !ok || v == nil {
+			continue
+		}
+		used++
+
+		var err error
+		switch {
+		case field.isMap:
+			buf, err = field.encodeMap(buf, v)
+		case field.repeated:
+			buf, err = field.encodeRepeated(buf, v)
+		default:
+			buf, err = field.encodeValue(buf, v)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if used < len(obj) {
+		for key := range obj {
+			if m.field(key) == nil {
+				return nil, fmt.Errorf("unknown field %s of %s", key, m.fullName)
+			}
+		}
+	}
+	return buf, nil
+}
+
+func (m *protoMessage) field(name string) *protoField {
+	for _, field := range m.fields {
+		if field.name == name || field.jsonName == name {
+			return field
+		}
+	}
+	return nil
+}
+
+func (f *protoField) wireType() int {
+	if f.message != nil {
+		return protoWireBytes
+	}
+	if f.enum != nil {
+		return protoWireVarint
+	}
+	return protoScalarWires[f.typ]
+}
+
+func (f *protoField) encodeMap(buf []byte, v interface{}) ([]byte, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("field %s expect object", f.name)
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		entry, err := f.message.encode(nil, map[string]interface{}{"key": key, "value": obj[key]})
+		if err != nil {
+			return nil, err
+		}
+		buf = appendProtoTag(buf, f.number, protoWireBytes)
+		buf = appendProtoVarint(buf, uint64(len(entry)))
+		buf = append(buf, entry...)
+	}
+	return buf, nil
+}
+
+func (f *protoField) encodeRepeated(buf []byte, v interface{}) ([]byte, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("field %s expect array", f.name)
+	}
+
+	if f.wireType() == protoWireBytes {
+		for _, item := range list {
+			var err error
+			if buf, err = f.encodeValue(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+
+	// packed scalar numeric values
+	var packed []byte
+	for _, item := range list {
+		var err error
+		if packed, err = f.appendScalar(packed, item); err != nil {
+			return nil, err
+		}
+	}
+	buf = appendProtoTag(buf, f.number, protoWireBytes)
+	buf = appendProtoVarint(buf, uint64(len(packed)))
+	return append(buf, packed...), nil
+}
+
+func (f *protoField) encodeValue(buf []byte, v interface{}) ([]byte, error) {
+	if f.message != nil {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("field %s expect object", f.name)
+		}
+		msg, err := f.message.encode(nil, obj)
+		if err != nil {
+			return nil, err
+		}
+		buf = appendProtoTag(buf, f.number, protoWireBytes)
+		buf = appendProtoVarint(buf, uint64(len(msg)))
+		return append(buf, msg...), nil
+	}
+
+	buf = appendProtoTag(buf, f.number, f.wireType())
+	return f.appendScalar(buf, v)
+}
+
+func (f *protoField) appendScalar(buf []byte, v interface{}) ([]byte, error) {
+	if f.enum != nil {
+		if name, ok := v.(string); ok {
+			value, ok := f.enum[name]
+			if !ok {
+				return nil, fmt.Errorf("field %s unknown enum value %s", f.name, name)
+			}
+			return appendProtoVarint(buf, uint64(value)), nil
+		}
+		value, err := protoInt(v, 32)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", f.name, err)
+		}
+		return appendProtoVarint(buf, uint64(value)), nil
+	}
+
+	var err error
+	switch f.typ {
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("field %s expect string", f.name)
+		}
+		buf = appendProtoVarint(buf, uint64(len(s)))
+		return append(buf, s...), nil
+	case "bytes":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("field %s expect base64 string", f.name)
+		}
+		data, decodeErr := base64.StdEncoding.DecodeString(s)
+		if decodeErr != nil {
+			if data, decodeErr = base64.URLEncoding.DecodeString(s); decodeErr != nil {
+				return nil, fmt.Errorf("field %s invalid base64: %v", f.name, decodeErr)
+			}
+		}
+		buf = appendProtoVarint(buf, uint64(len(data)))
+		return append(buf, data...), nil
+	case "bool":
+		b, ok := v.(bool)
+		if !ok {
+			b, err = strconv.ParseBool(fmt.Sprint(v))
+		}
+		if b {
+			return append(buf, 1), err
+		}
+		return append(buf, 0), err
+	case "int32", "int64":
+		var i int64
+		i, err = protoInt(v, map[string]int{"int32": 32, "int64": 64}[f.typ])
+		buf = appendProtoVarint(buf, uint64(i))
+	case "uint32", "uint64":
+		var u uint64
+		u, err = strconv.ParseUint(fmt.Sprint(v), 10, map[string]int{"uint32": 32, "uint64": 64}[f.typ])
+		buf = appendProtoVarint(buf, u)
+	case "sint32", "sint64":
+		var i int64
+		i, err = protoInt(v, map[string]int{"sint32": 32, "sint64": 64}[f.typ])
+		buf = appendProtoVarint(buf, uint64(i<<1)^uint64(i>>63))
+	case "fixed32":
+		var u uint64
+		u, err = strconv.ParseUint(fmt.Sprint(v), 10, 32)
+		buf = appendProtoFixed32(buf, uint32(u))
+	case "sfixed32":
+		var i int64
+		i, err = protoInt(v, 32)
+		buf = appendProtoFixed32(buf, uint32(i))
+	case "fixed64":
+		var u uint64
+		u, err = strconv.ParseUint(fmt.Sprint(v), 10, 64)
+		buf = appendProtoFixed64(buf, u)
+	case "sfixed64":
+		var i int64
+		i, err = protoInt(v, 64)
+		buf = appendProtoFixed64(buf, uint64(i))
+	case "float":
+		var fv float64
+		fv, err = protoFloat(v)
+		buf = appendProtoFixed32(buf, math.Float32bits(float32(fv)))
+	case "double":
+		var fv float64
+		fv, err = protoFloat(v)
+		buf = appendProtoFixed64(buf, math.Float64bits(fv))
+	default:
+		err = fmt.Errorf("unsupported type %s", f.typ)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %v", f.name, err)
+	}
+	return buf, nil
+}
+
+func protoInt(v interface{}, bitSize int) (int64, error) {
+	return strconv.ParseInt(fmt.Sprint(v), 10, bitSize)
+}
+
+func protoFloat(v interface{}) (float64, error) {
+	switch s := fmt.Sprint(v); s {
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	default:
+		return strconv.ParseFloat(s, 64)
+	}
+}
+
+func appendProtoVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+func appendProtoFixed32(buf []byte, v uint32) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	return append(buf, b[:]...)
+}
+
+func appendProtoFixed64(buf []byte, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(buf, b[:]...)
+}
+
+func appendProtoTag(buf []byte, number, wireType int) []byte {
+	return appendProtoVarint(buf, uint64(number)<<3|uint64(wireType))
+}
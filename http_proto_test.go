@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+const testProto = `
+syntax = "proto3";
+package my.pkg;
+
+// Request test message
+message Request {
+	int32 a = 1;
+	string b = 2;
+	Inner c = 3;
+	repeated int32 d = 4 [packed = true];
+	Status status = 5;
+	map<string, int32> tags = 6;
+	oneof kind {
+		sint32 e = 7;
+		bytes f = 8;
+	}
+	repeated string user_names = 9;
+
+	message Inner {
+		/* nested */
+		int32 a = 1;
+	}
+}
+
+enum Status {
+	UNKNOWN = 0;
+	OK = 1;
+}
+`
+
+func TestProtoEncodeJSON(t *testing.T) {
+	msg, err := parseProto(testProto, "my.pkg.Request")
+	if err != nil {
+		t.Fatalf("parseProto err: %v", err)
+	}
+
+	for _, v := range []struct {
+		body   string
+		expect string
+		isErr  bool
+	}{
+		{body: `{"a": 150}`, expect: "089601"},
+		{body: `{"b": "testing"}`, expect: "120774657374696e67"},
+		{body: `{"c": {"a": 150}}`, expect: "1a03089601"},
+		{body: `{"d": [3, 270, 86942]}`, expect: "2206038e029ea705"},
+		{body: `{"status": "OK"}`, expect: "2801"},
+		{body: `{"tags": {"k": 1}}`, expect: "3205" + "0a016b" + "1001"},
+		{body: `{"e": -1, "f": "AQI="}`, expect: "3801" + "42020102"},
+		{body: `{"userNames": ["a"]}`, expect: "4a0161"},
+		{body: `{"unknown": 1}`, isErr: true},
+		{body: `{"a": "x"}`, isErr: true},
+	} {
+		data, err := msg.encodeJSON([]byte(v.body))
+		if (err != nil) != v.isErr {
+			t.Fatalf("encodeJSON(%s) err: %v, expect err: %v", v.body, err, v.isErr)
+		}
+		if got := hex.EncodeToString(data); !v.isErr && got != v.expect {
+			t.Errorf("encodeJSON(%s) = %s, expect: %s", v.body, got, v.expect)
+		}
+	}
+
+	if _, err := parseProto(testProto, "my.pkg.None"); err == nil {
+		t.Errorf("parseProto not exist message expect err")
+	}
+}
+
+const testProtoTypes = `
+syntax = "proto3";
+package t;
+
+message Outer {
+	message Middle {
+		message Leaf {
+			string id = 1;
+		}
+		Leaf leaf = 1;
+		repeated Leaf leaves = 2;
+	}
+	Middle middle = 1;
+	oneof choice {
+		string name = 2;
+		Middle.Leaf leaf = 3;
+	}
+	map<int32, Middle.Leaf> items = 4;
+	map<string, Kind> kinds = 5;
+	enum Kind {
+		A = 0;
+		B = 1;
+		C = -1;
+	}
+	repeated Kind kind_list = 6;
+	repeated sint64 deltas = 7;
+	repeated double points = 8;
+	repeated fixed32 ids = 9;
+	repeated bool flags = 10;
+	.t.Outer.Kind kind = 11;
+}
+`
+
+func TestProtoEncodeTypes(t *testing.T) {
+	msg, err := parseProto(testProtoTypes, ".t.Outer")
+	if err != nil {
+		t.Fatalf("parseProto err: %v", err)
+	}
+
+	for _, v := range []struct {
+		body   string
+		expect string
+	}{
+		// nested messages and repeated messages
+		{body: `{"middle": {"leaf": {"id": "x"}, "leaves": [{"id": "a"}, {"id": "b"}]}}`,
+			expect: "0a0f" + "0a030a0178" + "12030a0161" + "12030a0162"},
+		// oneof members
+		{body: `{"name": "n"}`, expect: "12016e"},
+		{body: `{"leaf": {"id": "y"}}`, expect: "1a030a0179"},
+		// map entries sorted by key, message and enum values
+		{body: `{"items": {"2": {"id": "z"}, "10": {"id": ""}}}`, expect: "2206080a12020a00" + "2207080212030a017a"},
+		{body: `{"kinds": {"b": "B", "c": -1}}`, expect: "2a050a01621001" + "2a0e0a016310ffffffffffffffffff01"},
+		// packed repeated enums and scalars
+		{body: `{"kindList": ["B", "A", 1]}`, expect: "3203010001"},
+		{body: `{"kind_list": ["B"]}`, expect: "320101"},
+		{body: `{"deltas": [-1, 1, -64]}`, expect: "3a0301027f"},
+		{body: `{"points": [1.5]}`, expect: "4208000000000000f83f"},
+		{body: `{"ids": [1, 256]}`, expect: "4a080100000000010000"},
+		{body: `{"flags": [true, false, true]}`, expect: "5203010001"},
+		// enum of a fully qualified type
+		{body: `{"kind": "C"}`, expect: "58ffffffffffffffffff01"},
+		{body: `{"kind": null}`, expect: ""},
+	} {
+		data, err := msg.encodeJSON([]byte(v.body))
+		if err != nil {
+			t.Fatalf("encodeJSON(%s) err: %v", v.body, err)
+		}
+		if got := hex.EncodeToString(data); got != v.expect {
+			t.Errorf("encodeJSON(%s) = %s, expect: %s", v.body, got, v.expect)
+		}
+	}
+
+	for _, v := range []struct {
+		body string
+		err  string
+	}{
+		{body: `not json`, err: "invalid protobuf json body"},
+		{body: `{"middle": 1}`, err: "field middle expect object"},
+		{body: `{"middle": {"leaves": {}}}`, err: "field leaves expect array"},
+		{body: `{"middle": {"leaf": {"uid": "x"}}}`, err: "unknown field uid of t.Outer.Middle.Leaf"},
+		{body: `{"items": []}`, err: "field items expect object"},
+		{body: `{"items": {"x": {}}}`, err: "field key"},
+		{body: `{"kindList": ["D"]}`, err: "field kind_list unknown enum value D"},
+		{body: `{"kind": 4294967296}`, err: "field kind"},
+		{body: `{"deltas": [1.5]}`, err: "field deltas"},
+		{body: `{"points": ["x"]}`, err: "field points"},
+		{body: `{"ids": [-1]}`, err: "field ids"},
+		{body: `{"flags": ["maybe"]}`, err: "invalid syntax"},
+		{body: `{"name": 1}`, err: "field name expect string"},
+	} {
+		if _, err := msg.encodeJSON([]byte(v.body)); err == nil || !strings.Contains(err.Error(), v.err) {
+			t.Errorf("encodeJSON(%s) err: %v, expect: %s", v.body, err, v.err)
+		}
+	}
+}
+
+func TestParseProtoErrors(t *testing.T) {
+	for _, v := range []struct {
+		content string
+		err     string
+	}{
+		{content: `message A { int32 a = 1;`, err: "proto message A not closed"},
+		{content: `message A { int32 a = x; }`, err: "proto field A.a invalid number"},
+		{content: `message A { int32 a 1; }`, err: `proto expect "=" but got "1"`},
+		{content: `message A { int32 a = 1 }`, err: `proto expect ";" but got "}"`},
+		{content: `message A { map<string int32> m = 1; }`, err: `proto expect "," but got "int32"`},
+		{content: `message A { B b = 1; }`, err: "proto type B not found in A"},
+		{content: `message A { .A.B b = 1; message B {} } message C { B b = 1; }`, err: "proto type B not found in C"},
+		{content: `enum E { X = 0;`, err: "proto enum E not closed"},
+		{content: `enum E { X = y; }`, err: "proto enum E.X invalid value"},
+	} {
+		if _, err := parseProto(v.content, "A"); err == nil || !strings.Contains(err.Error(), v.err) {
+			t.Errorf("parseProto(%s) err: %v, expect: %s", v.content, err, v.err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const rateLimitEpoch = 1e9 // reset values above are unix seconds instead of delta seconds
+
+// StressRateLimit quota advertised by rate limit headers of responses, and requests throttled by 429
+type StressRateLimit struct {
+	Responses      int64            `json:"responses"`       // responses with rate limit headers
+	Limits         map[string]int64 `json:"limits"`          // responses per advertised limit, e.g. "100/60s"
+	MinRemaining   int64            `json:"min_remaining"`   // lowest remaining quota, -1 if not advertised
+	Exhausted      int64            `json:"exhausted"`       // responses with remaining quota of 0
+	MaxReset       int64            `json:"max_reset"`       // longest seconds until the quota resets
+	Throttled      int64            `json:"throttled"`       // 429 responses
+	FirstThrottled int64            `json:"first_throttled"` // unix ms of the first 429, 0 if not throttled
+}
+
+// rateLimit quota of a response, -1 if not advertised
+type rateLimit struct {
+	limit, remaining, reset, window int64
+}
+
+// parseRateLimit parse X-RateLimit-* and RateLimit-* headers, and RateLimit and RateLimit-Policy of
+// the structured fields draft, e.g. RateLimit: "default";r=50;t=30 and RateLimit-Policy: "default";q=100;w=60
+func parseRateLimit(h http.Header, now time.Time) (rateLimit, bool) {
+	rl := rateLimit{limit: -1, remaining: -1, reset: -1, window: -1}
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		if rl.limit < 0 {
+			rl.limit, rl.window = rateLimitValue(h.Get(prefix+"Limit"), "w")
+		}
+		if rl.remaining < 0 {
+			rl.remaining, _ = rateLimitValue(h.Get(prefix+"Remaining"), "")
+		}
+		if rl.reset < 0 {
+			rl.reset, _ = rateLimitValue(h.Get(prefix+"Reset"), "")
+		}
+	}
+	if v := h.Get("RateLimit"); v != "" {
+		params := rateLimitParams(v)
+		if rl.limit < 0 {
+			rl.limit = firstParam(params, "limit", "q")
+		}
+		if rl.remaining < 0 {
+			rl.remaining = firstParam(params, "remaining", "r")
+		}
+		if rl.reset < 0 {
+			rl.reset = firstParam(params, "reset", "t")
+		}
+	}
+	if v := h.Get("RateLimit-Policy"); v != "" {
+		params := rateLimitParams(v)
+		if rl.limit < 0 {
+			rl.limit = firstParam(params, "q")
+			if rl.limit < 0 {
+				rl.limit, _ = rateLimitValue(v, "")
+			}
+		}
+		if rl.window < 0 {
+			rl.window = firstParam(params, "w")
+		}
+	}
+	if rl.reset > rateLimitEpoch {
+		if rl.reset -= now.Unix(); rl.reset < 0 {
+			rl.reset = 0
+		}
+	}
+	return rl, rl.limit >= 0 || rl.remaining >= 0 || rl.reset >= 0
+}
+
+// rateLimitValue first number of a header like "100, 100;w=60, 1000;w=3600", and the parameter of the
+// first item of the same number, e.g. the window of the quota policy
+func rateLimitValue(v, param string) (int64, int64) {
+	n, p := int64(-1), int64(-1)
+	for i, item := range strings.Split(v, ",") {
+		num, rest, _ := strings.Cut(item, ";")
+		value, err := strconv.ParseInt(strings.TrimSpace(num), 10, 64)
+		switch {
+		case i == 0 && (err != nil || value < 0):
+			return -1, -1
+		case i == 0:
+			n = value
+		case value != n:
+			continue
+		}
+		if param != "" && p < 0 {
+			p = firstParam(rateLimitParams(rest), param)
+		}
+	}
+	return n, p
+}
+
+// rateLimitParams key=value parameters of the first item, separated by ";" or ","
+func rateLimitParams(v string) map[string]string {
+	params := make(map[string]string)
+	for _, field := range strings.FieldsFunc(v, func(r rune) bool { return r == ';' || r == ',' }) {
+		if key, value, ok := strings.Cut(strings.TrimSpace(field), "="); ok {
+			key = strings.ToLower(key)
+			if _, dup := params[key]; !dup {
+				params[key] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return params
+}
+
+func firstParam(params map[string]string, keys ...string) int64 {
+	for _, key := range keys {
+		if n, err := strconv.ParseInt(params[key], 10, 64); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return -1
+}
+
+// hasRateLimit response advertises a rate limit or is throttled
+func hasRateLimit(res *result) bool {
+	return res.rateLimit != nil || res.statusCode == http.StatusTooManyRequests
+}
+
+func (r *StressRateLimit) append(res *result) {
+	if res.statusCode == http.StatusTooManyRequests {
+		r.Throttled++
+		if ms := res.start.UnixMilli(); r.FirstThrottled == 0 || ms < r.FirstThrottled {
+			r.FirstThrottled = ms
+		}
+	}
+	rl := res.rateLimit
+	if rl == nil {
+		return
+	}
+	r.Responses++
+	if rl.limit >= 0 {
+		key := strconv.FormatInt(rl.limit, 10)
+		if rl.window > 0 {
+			key += "/" + (time.Duration(rl.window) * time.Second).String()
+		}
+		r.Limits[key]++
+	}
+	if rl.remaining >= 0 && (r.MinRemaining < 0 || rl.remaining < r.MinRemaining) {
+		r.MinRemaining = rl.remaining
+	}
+	if rl.remaining == 0 {
+		r.Exhausted++
+	}
+	if rl.reset > r.MaxReset {
+		r.MaxReset = rl.reset
+	}
+}
+
+func (r *StressRateLimit) merge(v *StressRateLimit) {
+	r.Responses += v.Responses
+	for limit, n := range v.Limits {
+		r.Limits[limit] += n
+	}
+	if v.MinRemaining >= 0 && (r.MinRemaining < 0 || v.MinRemaining < r.MinRemaining) {
+		r.MinRemaining = v.MinRemaining
+	}
+	r.Exhausted += v.Exhausted
+	if v.MaxReset > r.MaxReset {
+		r.MaxReset = v.MaxReset
+	}
+	r.Throttled += v.Throttled
+	if v.FirstThrottled > 0 && (r.FirstThrottled == 0 || v.FirstThrottled < r.FirstThrottled) {
+		r.FirstThrottled = v.FirstThrottled
+	}
+}
+
+// printRateLimit Print quota advertised by the server and how often the requests were throttled
+func (result *StressResult) printRateLimit(w io.Writer) {
+	r := result.RateLimit
+	total := result.LatsTotal
+	fprintln(w, "\nRate limits of server:")
+	if r.Responses > 0 {
+		fprintln(w, "  Advertised:\t%d of %d responses", r.Responses, total)
+		limits := make([]string, 0, len(r.Limits))
+		for limit := range r.Limits {
+			limits = append(limits, limit)
+		}
+		sort.Strings(limits)
+		for _, limit := range limits {
+			fprintln(w, "  Limit %s:\t%d responses", limit, r.Limits[limit])
+		}
+		if r.MinRemaining >= 0 {
+			fprintln(w, "  Min remaining:\t%d, exhausted in %d responses", r.MinRemaining, r.Exhausted)
+		}
+		if r.MaxReset > 0 {
+			fprintln(w, "  Max reset:\t%v", time.Duration(r.MaxReset)*time.Second)
+		}
+	}
+	throttled := fmt.Sprintf("%d responses", r.Throttled)
+	if total > 0 {
+		throttled += fmt.Sprintf(" (%4.2f%%)", float64(r.Throttled)*100/float64(total))
+	}
+	if start := result.startSecond(); r.FirstThrottled > 0 && start > 0 {
+		throttled += fmt.Sprintf(", the first at %ds of the run", r.FirstThrottled/1e3-start)
+	}
+	fprintln(w, "  Throttled 429:\t%s", throttled)
+}
+
+// startSecond unix seconds of the first request, 0 if no time series
+func (result *StressResult) startSecond() int64 {
+	var start int64
+	for sec := range result.TimeSeries {
+		if start == 0 || sec < start {
+			start = sec
+		}
+	}
+	return start
+}
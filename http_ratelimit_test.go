@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for _, v := range []struct {
+		header http.Header
+		expect rateLimit
+		ok     bool
+	}{
+		{http.Header{}, rateLimit{-1, -1, -1, -1}, false},
+		{http.Header{"X-Ratelimit-Limit": {"100"}, "X-Ratelimit-Remaining": {"42"}, "X-Ratelimit-Reset": {"1700000030"}},
+			rateLimit{100, 42, 30, -1}, true},
+		{http.Header{"Ratelimit-Limit": {"100, 100;w=60, 1000;w=3600"}, "Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"7"}},
+			rateLimit{100, 0, 7, 60}, true},
+		{http.Header{"Ratelimit": {`"default";r=50;t=30`}, "Ratelimit-Policy": {`"default";q=100;w=60`}},
+			rateLimit{100, 50, 30, 60}, true},
+		{http.Header{"Ratelimit": {"limit=10, remaining=9, reset=1"}}, rateLimit{10, 9, 1, -1}, true},
+		{http.Header{"X-Ratelimit-Limit": {"many"}}, rateLimit{-1, -1, -1, -1}, false},
+	} {
+		rl, ok := parseRateLimit(v.header, now)
+		if ok != v.ok || rl != v.expect {
+			t.Errorf("parseRateLimit(%v) = %+v, %v, expect: %+v, %v", v.header, rl, ok, v.expect, v.ok)
+		}
+	}
+}
+
+func TestStressRateLimit(t *testing.T) {
+	var count int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&count, 1)
+		remaining := 5 - n
+		if remaining < 0 {
+			remaining = 0
+		}
+		w.Header().Set("X-RateLimit-Limit", "5")
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		w.Header().Set("X-RateLimit-Reset", "10")
+		if n > 5 {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL,
+		C:             1,
+		N:             8,
+		Timeout:       3000,
+	})
+	if result == nil || result.RateLimit == nil {
+		t.Fatalf("result of rate limit = %+v", result)
+	}
+	r := result.RateLimit
+	if r.Responses != result.LatsTotal || r.Limits["5"] != r.Responses || r.MinRemaining != 0 ||
+		r.Exhausted != r.Responses-4 || r.MaxReset != 10 || r.Throttled != r.Responses-5 || r.FirstThrottled <= 0 {
+		t.Errorf("rate limit = %+v, responses: %d", r, result.LatsTotal)
+	}
+
+	var buf bytes.Buffer
+	result.printRateLimit(&buf)
+	for _, line := range []string{"Rate limits of server:", "  Limit 5:\t", "  Min remaining:\t0", "  Max reset:\t10s", "  Throttled 429:\t"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printRateLimit expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// recordSample line of the ndjson file of -record, one per request
+type recordSample struct {
+	SequenceId int64  `json:"seq"`
+	Start      int64  `json:"t"`    // unix nano of the request start
+	Duration   int64  `json:"d"`    // latency in nano
+	StatusCode int    `json:"code"` // 0 if failed before the response
+	Size       int64  `json:"size"` // decompressed body size
+	Err        string `json:"err,omitempty"`
+	Timeout    bool   `json:"timeout,omitempty"`
+	Warmup     bool   `json:"warmup,omitempty"` // excluded by -warmup
+}
+
+// sampleRecorder writer of -record, shared by the stress tests of the process
+type sampleRecorder struct {
+	mutex sync.Mutex
+	file  *os.File
+	w     *bufio.Writer
+	enc   *json.Encoder
+	err   error // first write error, the following samples are dropped
+}
+
+var recorder *sampleRecorder // nil if -record is disabled
+
+// openRecord create the file of -record, an existing file is truncated
+func openRecord(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("open -record %s err: %v", file, err)
+	}
+	w := bufio.NewWriterSize(f, 64*1024)
+	recorder = &sampleRecorder{file: f, w: w, enc: json.NewEncoder(w)}
+	return nil
+}
+
+// record append the sample of res
+func (r *sampleRecorder) record(seqId int64, res *result, warmup bool) {
+	sample := recordSample{SequenceId: seqId, Start: res.start.UnixNano(), Duration: int64(res.duration),
+		StatusCode: res.statusCode, Size: res.contentLength, Timeout: res.timeout, Warmup: warmup}
+	if res.err != nil {
+		sample.Err = res.err.Error()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err == nil {
+		if r.err = r.enc.Encode(&sample); r.err != nil {
+			verbosePrint(vERROR, "write -record err: %v", r.err)
+		}
+	}
+}
+
+// flush write the buffered samples, called when a stress test finishes
+func (r *sampleRecorder) flush() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = err
+		verbosePrint(vERROR, "write -record err: %v", err)
+	}
+}
+
+func (r *sampleRecorder) close() error {
+	r.flush()
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	return r.err
+}
+
+// replayRecord regenerate the result from the samples of -record, samples of -warmup are skipped and
+// seqId selects the stress test if the file has several, 0 is all
+func replayRecord(file string, seqId int64, digits int) (*StressResult, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s file read error(%v)", file, err)
+	}
+	defer f.Close()
+
+	stats := GetStressResult()
+	stats.digits = digits
+	var first, last time.Time
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var sample recordSample
+		if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid sample: %v", file, line, err)
+		}
+		if sample.Warmup || (seqId > 0 && sample.SequenceId != seqId) {
+			continue
+		}
+		res := &result{statusCode: sample.StatusCode, start: time.Unix(0, sample.Start),
+			duration: time.Duration(sample.Duration), contentLength: sample.Size, timeout: sample.Timeout}
+		if sample.Err != "" {
+			res.err = errors.New(sample.Err)
+		}
+		stats.append(res)
+		if first.IsZero() || res.start.Before(first) {
+			first = res.start
+		}
+		if end := res.start.Add(res.duration); end.After(last) {
+			last = end
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s file read error(%v)", file, err)
+	}
+	if stats.LatsTotal+stats.ErrTotal() <= 0 {
+		return nil, fmt.Errorf("no samples in %s", file)
+	}
+
+	result := GetStressResult()
+	// round the span up to whole seconds, a truncated one overstates the rps
+	if result.Duration = int64(math.Ceil(last.Sub(first).Seconds())); result.Duration < 1 {
+		result.Duration = 1
+	}
+	return calMutliStressResult(result, *stats), nil
+}
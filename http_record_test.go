@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordReplay(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "samples.ndjson")
+	if err := openRecord(file); err != nil {
+		t.Fatalf("openRecord err: %v", err)
+	}
+	defer func() { recorder = nil }()
+
+	live := GetStressResult()
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 100; i++ {
+		res := &result{statusCode: 200, start: start.Add(time.Duration(i) * 50 * time.Millisecond),
+			duration: time.Duration(i+1) * time.Millisecond, contentLength: 10}
+		if i%10 == 0 {
+			res = &result{start: res.start, duration: time.Second, err: errors.New("timeout"), timeout: true}
+		}
+		live.append(res)
+		recorder.record(1, res, false)
+	}
+	recorder.record(1, &result{statusCode: 500, start: start, duration: time.Millisecond}, true)
+	recorder.record(2, &result{statusCode: 200, start: start, duration: time.Millisecond}, false)
+	if err := recorder.close(); err != nil {
+		t.Fatalf("close err: %v", err)
+	}
+
+	replay, err := replayRecord(file, 1, defaultLatsDigits)
+	if err != nil {
+		t.Fatalf("replayRecord err: %v", err)
+	}
+	live.Duration = 6 // 5.5s of samples, rounded up
+	live = calMutliStressResult(nil, *live)
+	if replay.LatsTotal != 90 || replay.ErrTotal() != 10 || replay.TimeoutTotal != 10 || replay.Duration != 6 ||
+		replay.StatusCodeDist[200] != 90 || replay.SizeTotal != 900 {
+		t.Errorf("replay = %+v", replay)
+	}
+	for _, p := range []int{50, 90, 99} {
+		if replay.Percentile(p) != live.Percentile(p) {
+			t.Errorf("replay p%d = %v, expect %v", p, replay.Percentile(p), live.Percentile(p))
+		}
+	}
+	if replay.Rps != live.Rps || replay.Average != live.Average {
+		t.Errorf("replay rps %d, average %d, expect %d, %d", replay.Rps, replay.Average, live.Rps, live.Average)
+	}
+
+	// a sub-second record still has rps
+	short := filepath.Join(t.TempDir(), "short.ndjson")
+	if err := openRecord(short); err != nil {
+		t.Fatalf("openRecord err: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		recorder.record(1, &result{statusCode: 200, start: start.Add(time.Duration(i) * 10 * time.Millisecond),
+			duration: time.Millisecond}, false)
+	}
+	if err := recorder.close(); err != nil {
+		t.Fatalf("close err: %v", err)
+	}
+	if r, err := replayRecord(short, 1, defaultLatsDigits); err != nil || r.Duration != 1 || r.Rps != 10*scaleNum {
+		t.Errorf("replay of short record = %+v, %v", r, err)
+	}
+
+	// all runs of the file without -seqid
+	if all, err := replayRecord(file, 0, defaultLatsDigits); err != nil || all.LatsTotal != 91 {
+		t.Errorf("replay of all = %+v, %v", all, err)
+	}
+	if _, err := replayRecord(file, 3, defaultLatsDigits); err == nil {
+		t.Errorf("replay of unknown sequence id expect error")
+	}
+	os.WriteFile(file, []byte("{\"seq\": 1}\nnot json\n"), 0644)
+	if _, err := replayRecord(file, 0, defaultLatsDigits); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("replay of invalid line err = %v", err)
+	}
+}
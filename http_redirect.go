@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+)
+
+const defaultMaxRedirects = 10 // same as net/http
+
+// StressRedirects 3xx hops of the requests, counted apart from the final responses
+type StressRedirects struct {
+	Follow     bool          `json:"follow"`     // redirects followed, -follow-redirects
+	Max        int           `json:"max"`        // hops of a request at most, -max-redirects
+	Redirected int64         `json:"redirected"` // requests which followed redirects
+	Hops       int64         `json:"hops"`       // 3xx responses followed
+	MaxHops    int64         `json:"max_hops"`   // most hops of a request
+	Codes      map[int]int64 `json:"codes"`      // hops of each 3xx status
+	Exceeded   int64         `json:"exceeded"`   // requests failed at -max-redirects
+	Returned   int64         `json:"returned"`   // 3xx responses returned without following
+}
+
+// checkRedirect record the hops of the request of client, not following returns the 3xx response, and
+// more than -max-redirects fails the request
+func (b *StressWorker) checkRedirect(client *StressClient) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if b.RequestParams.NoRedirects {
+			return http.ErrUseLastResponse
+		}
+		if max := b.maxRedirects(); len(via) > max {
+			client.exceeded = true
+			return fmt.Errorf("stopped after %d redirects", max)
+		}
+		client.hops = append(client.hops, req.Response.StatusCode)
+		return nil
+	}
+}
+
+// maxRedirects hops of a request at most of -max-redirects
+func (b *StressWorker) maxRedirects() int {
+	if b.RequestParams.MaxRedirects <= 0 {
+		return defaultMaxRedirects
+	}
+	return b.RequestParams.MaxRedirects
+}
+
+// takeRedirects move the hops of the last request of client to res, resp is nil if the request failed
+func (b *StressWorker) takeRedirects(client *StressClient, resp *http.Response, res *result) {
+	res.hops, res.hopsExceeded = client.hops, client.exceeded
+	client.hops, client.exceeded = nil, false
+	res.unfollowed = resp != nil && b.RequestParams.NoRedirects && isRedirect(resp.StatusCode)
+}
+
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect,
+		http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+func (r *StressRedirects) append(res *result) {
+	if len(res.hops) > 0 {
+		r.Redirected++
+		r.Hops += int64(len(res.hops))
+		if int64(len(res.hops)) > r.MaxHops {
+			r.MaxHops = int64(len(res.hops))
+		}
+		for _, code := range res.hops {
+			r.Codes[code]++
+		}
+	}
+	if res.hopsExceeded {
+		r.Exceeded++
+	}
+	if res.unfollowed {
+		r.Returned++
+	}
+}
+
+func (r *StressRedirects) merge(v *StressRedirects) {
+	r.Follow, r.Max = v.Follow, v.Max
+	r.Redirected += v.Redirected
+	r.Hops += v.Hops
+	if v.MaxHops > r.MaxHops {
+		r.MaxHops = v.MaxHops
+	}
+	for code, n := range v.Codes {
+		r.Codes[code] += n
+	}
+	r.Exceeded += v.Exceeded
+	r.Returned += v.Returned
+}
+
+// printRedirects Print the 3xx hops followed or returned of the requests
+func (result *StressResult) printRedirects(w io.Writer) {
+	r := result.Redirects
+	if !r.Follow {
+		fprintln(w, "\nRedirects (not followed):")
+		fprintln(w, "  Returned:\t%d 3xx responses", r.Returned)
+		return
+	}
+	fprintln(w, "\nRedirects (followed, max %d):", r.Max)
+	fprintln(w, "  Redirected:\t%d requests, %d hops (max %d of a request)", r.Redirected, r.Hops, r.MaxHops)
+	codes := make([]int, 0, len(r.Codes))
+	for code := range r.Codes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fprintln(w, "  [%d]\t%d hops", code, r.Codes[code])
+	}
+	if r.Exceeded > 0 {
+		fprintln(w, "  Exceeded:\t%d requests failed after %d redirects", r.Exceeded, r.Max)
+	}
+}
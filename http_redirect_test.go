@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStressRedirects(t *testing.T) {
+	// /hop/N redirects N times before the final response, alternating 302 and 301
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hop/"))
+		switch {
+		case n <= 0:
+			w.WriteHeader(http.StatusOK)
+		case n%2 == 0:
+			http.Redirect(w, r, fmt.Sprintf("/hop/%d", n-1), http.StatusFound)
+		default:
+			http.Redirect(w, r, fmt.Sprintf("/hop/%d", n-1), http.StatusMovedPermanently)
+		}
+	}))
+	defer srv.Close()
+
+	for _, v := range []struct {
+		hops        int
+		noRedirects bool
+		max         int
+		code        int
+		expect      StressRedirects
+	}{
+		{hops: 2, code: 200, expect: StressRedirects{Follow: true, Max: 10, Hops: 2, MaxHops: 2,
+			Codes: map[int]int64{301: 1, 302: 1}}},
+		{hops: 2, noRedirects: true, code: 302, expect: StressRedirects{Max: 10, Returned: 1}},
+		{hops: 4, max: 3, expect: StressRedirects{Follow: true, Max: 3, Hops: 3, MaxHops: 3,
+			Codes: map[int]int64{301: 1, 302: 2}, Exceeded: 1}},
+		{hops: 0, code: 200},
+	} {
+		_, result := executeStress(StressParameters{
+			SequenceId:    time.Now().UnixNano(),
+			Cmd:           cmdStart,
+			RequestType:   typeHttp1,
+			RequestMethod: "GET",
+			Url:           fmt.Sprintf("%s/hop/%d", srv.URL, v.hops),
+			NoRedirects:   v.noRedirects,
+			MaxRedirects:  v.max,
+			C:             2,
+			N:             10,
+			Timeout:       3000,
+		})
+		if result == nil {
+			t.Fatalf("result of %d hops is nil", v.hops)
+		}
+		requests := result.LatsTotal + result.ErrTotal()
+		if v.code != 0 && int64(result.StatusCodeDist[v.code]) != requests {
+			t.Errorf("status of %d hops = %v, expect %d", v.hops, result.StatusCodeDist, v.code)
+		}
+		r := result.Redirects
+		if v.hops == 0 {
+			if r != nil {
+				t.Errorf("redirects of no hops = %+v, expect nil", r)
+			}
+			continue
+		}
+		if r == nil || r.Follow != v.expect.Follow || r.Max != v.expect.Max || r.MaxHops != v.expect.MaxHops {
+			t.Fatalf("redirects of %d hops = %+v, expect %+v", v.hops, r, v.expect)
+		}
+		// per request counts
+		if r.Hops != v.expect.Hops*requests || r.Returned != v.expect.Returned*requests ||
+			r.Exceeded != v.expect.Exceeded*requests || len(r.Codes) != len(v.expect.Codes) {
+			t.Errorf("redirects of %d hops = %+v, requests %d, expect %+v per request", v.hops, r, requests, v.expect)
+		}
+		for code, n := range v.expect.Codes {
+			if r.Codes[code] != n*requests {
+				t.Errorf("hops of %d of %d hops = %d, expect %d", code, v.hops, r.Codes[code], n*requests)
+			}
+		}
+		if v.expect.Exceeded > 0 && result.ErrTotal() != requests {
+			t.Errorf("errors of exceeded = %v, expect %d", result.ErrorDist, requests)
+		}
+	}
+
+	var buf bytes.Buffer
+	(&StressResult{Redirects: &StressRedirects{Follow: true, Max: 3, Redirected: 4, Hops: 7, MaxHops: 2,
+		Codes: map[int]int64{301: 3, 302: 4}, Exceeded: 1}}).printRedirects(&buf)
+	for _, line := range []string{"Redirects (followed, max 3):", "  Redirected:\t4 requests, 7 hops (max 2 of a request)",
+		"  [301]\t3 hops\n  [302]\t4 hops", "  Exceeded:\t1 requests failed after 3 redirects"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printRedirects expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
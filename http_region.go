@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	splitPerWorker = "per-worker" // -c, -n, -q and -rate are the load of each worker
+	splitTotal     = "total"      // -c, -n, -q and -rate are the load of all workers, divided across them
+)
+
+var (
+	regionWeights map[string]float64 // load weight of each region of -region, normalized to sum 1
+	workerSplit   = splitPerWorker   // -worker-split
+)
+
+// StressRegion record per region result of distributed workers
+type StressRegion struct {
+	Workers   int              `json:"workers"`
+	LatsTotal int64            `json:"lats_total"`
+	ErrTotal  int64            `json:"err_total"`
+	AvgTotal  int64            `json:"avg_total"`
+	Lats      map[string]int64 `json:"lats"`
+}
+
+// splitWorkerRegion split region label of worker, e.g. "eu=127.0.0.1:12710"
+func splitWorkerRegion(v string) (region, addr string) {
+	if i := strings.Index(v, "="); i > 0 && !strings.ContainsAny(v[:i], ":/") {
+		return v[:i], v[i+1:]
+	}
+	return "", v
+}
+
+// parseRegionWeights parse weights of regions, e.g. "eu=50%,us=30%,ap=20%"
+func parseRegionWeights(s string) (map[string]float64, error) {
+	var (
+		weights = make(map[string]float64, 0)
+		sum     float64
+	)
+	for _, kv := range strings.Split(s, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid region weight: %s", kv)
+		}
+		v, err := parsePercent(weight)
+		if err != nil {
+			return nil, err
+		}
+		weights[name] = v
+		sum += v
+	}
+	if sum <= 0 {
+		return nil, fmt.Errorf("region weights are all zero: %s", s)
+	}
+	for name := range weights {
+		weights[name] /= sum
+	}
+	return weights, nil
+}
+
+// checkWorkerRegions every worker is labeled with a weighted region, and every weighted region has workers
+func checkWorkerRegions(weights map[string]float64, workers []string) error {
+	counts := make(map[string]int, 0)
+	for _, v := range workers {
+		region, addr := splitWorkerRegion(v)
+		if _, ok := weights[region]; !ok {
+			return fmt.Errorf("worker %s has no region of -region, e.g. -W eu=%s", addr, addr)
+		}
+		counts[region]++
+	}
+	for region, weight := range weights {
+		if weight > 0 && counts[region] == 0 {
+			return fmt.Errorf("region %s has no worker", region)
+		}
+	}
+	return nil
+}
+
+// regionShares fraction of the load of each worker, the weight of region is split evenly by its workers
+func regionShares(weights map[string]float64, workers []string) []float64 {
+	counts := make(map[string]int, 0)
+	for _, v := range workers {
+		region, _ := splitWorkerRegion(v)
+		counts[region]++
+	}
+	shares := make([]float64, len(workers))
+	for i, v := range workers {
+		region, _ := splitWorkerRegion(v)
+		shares[i] = weights[region] / float64(counts[region])
+	}
+	return shares
+}
+
+// splitLoad split total by shares with the largest remainder method, and each part is at least min
+func splitLoad(total int, shares []float64, min int) []int {
+	var (
+		parts  = make([]int, len(shares))
+		order  = make([]int, len(shares))
+		remain = total
+	)
+	for i, share := range shares {
+		parts[i] = int(math.Floor(float64(total) * share))
+		remain -= parts[i]
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		fi := float64(total)*shares[order[i]] - float64(parts[order[i]])
+		fj := float64(total)*shares[order[j]] - float64(parts[order[j]])
+		return fi > fj
+	})
+	for i := 0; i < remain && i < len(order); i++ {
+		parts[order[i]]++
+	}
+	for i := range parts {
+		if parts[i] < min {
+			parts[i] = min
+		}
+	}
+	return parts
+}
+
+// regionParams set -c, -n, -q and -rate of worker index by its share of the total load, the total is
+// divided evenly, or by the weight of the region of worker with -region. The total of -worker-split
+// per-worker is the load times the workers, so that it is the same as without -region
+func regionParams(paramsJson []byte, index int) []byte {
+	var params StressParameters
+	if len(regionWeights) <= 0 && workerSplit != splitTotal {
+		return paramsJson
+	}
+	if err := json.Unmarshal(paramsJson, &params); err != nil || (params.Cmd != cmdStart && params.Cmd != cmdValidate) {
+		return paramsJson
+	}
+
+	var (
+		count  = len(workerList)
+		shares = make([]float64, count)
+		scale  = count // per-worker load times the workers is the total
+	)
+	if len(regionWeights) > 0 {
+		shares = regionShares(regionWeights, workerList)
+	} else {
+		for i := range shares {
+			shares[i] = 1 / float64(count)
+		}
+	}
+	if workerSplit == splitTotal {
+		scale = 1
+	}
+	params.C = splitLoad(params.C*scale, shares, 1)[index]
+	if params.N > 0 {
+		params.N = splitLoad(params.N*scale, shares, params.C)[index]
+	}
+	if params.Qps > 0 {
+		params.Qps = splitLoad(params.Qps*scale, shares, 1)[index]
+	}
+	if params.ArrivalRate > 0 {
+		params.ArrivalRate = params.ArrivalRate * float64(scale) * shares[index]
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return paramsJson
+	}
+	return body
+}
+
+// regionResult label result of worker with its region
+func regionResult(result *StressResult, region string) {
+	stats := &StressRegion{
+		Workers:   1,
+		LatsTotal: result.LatsTotal,
+		ErrTotal:  result.ErrTotal(),
+		AvgTotal:  result.AvgTotal,
+		Lats:      make(map[string]int64, len(result.Lats)),
+	}
+	for lats, c := range result.Lats {
+		stats.Lats[lats] = c
+	}
+	result.RegionDist = map[string]*StressRegion{region: stats}
+}
+
+// printRegions Print per region latency and errors of distributed workers
+func (result *StressResult) printRegions(w io.Writer) {
+	regions := make([]string, 0, len(result.RegionDist))
+	for region := range result.RegionDist {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	fprintln(w, "\nRegion distribution:")
+	for _, region := range regions {
+		r := result.RegionDist[region]
+		var average float32
+		if r.LatsTotal > 0 {
+			average = float32(r.AvgTotal/r.LatsTotal) / scaleNum
+		}
+		p := latsPercentiles(r.Lats, r.LatsTotal, []int{50, 99})
+		fprintln(w, "  [%s]\t%d workers, %d responses, %d errors, %4.3f secs average, %4.3f secs p50, %4.3f secs p99",
+			region, r.Workers, r.LatsTotal, r.ErrTotal, average, p[0], p[1])
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRegionParams(t *testing.T) {
+	weights, err := parseRegionWeights("eu=50%,us=30%,ap=20%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	workers := []string{"eu=127.0.0.1:12710", "eu=127.0.0.1:12711", "us=127.0.0.1:12712", "ap=http://127.0.0.1:12713"}
+	if err := checkWorkerRegions(weights, workers); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkWorkerRegions(weights, append(workers, "127.0.0.1:12714")); err == nil {
+		t.Errorf("checkWorkerRegions of unlabeled worker expect err")
+	}
+	if err := checkWorkerRegions(weights, workers[:3]); err == nil {
+		t.Errorf("checkWorkerRegions of region without worker expect err")
+	}
+
+	savedWeights, savedWorkers := regionWeights, workerList
+	defer func() { regionWeights, workerList = savedWeights, savedWorkers }()
+	regionWeights, workerList = weights, workers
+
+	params, _ := json.Marshal(StressParameters{Cmd: cmdStart, C: 10, N: 100})
+	var cs, ns []int
+	for i := range workers {
+		var p StressParameters
+		json.Unmarshal(regionParams(params, i), &p)
+		cs, ns = append(cs, p.C), append(ns, p.N)
+	}
+	if expect := []int{10, 10, 12, 8}; !reflect.DeepEqual(cs, expect) {
+		t.Errorf("regionParams c = %v, expect: %v", cs, expect)
+	}
+	if expect := []int{100, 100, 120, 80}; !reflect.DeepEqual(ns, expect) {
+		t.Errorf("regionParams n = %v, expect: %v", ns, expect)
+	}
+
+	if url := workerUrl(workers[3], httpWorkerApiPath); url != "http://127.0.0.1:12713"+httpWorkerApiPath {
+		t.Errorf("workerUrl = %s", url)
+	}
+
+	eu := StressResult{LatsTotal: 2, AvgTotal: 4, Lats: map[string]int64{"0.002": 2}}
+	us := StressResult{LatsTotal: 1, AvgTotal: 3, ErrorDist: map[string]int{"timeout": 1}}
+	regionResult(&eu, "eu")
+	regionResult(&us, "us")
+	result := calMutliStressResult(nil, eu, eu, us)
+	if r := result.RegionDist["eu"]; r == nil || r.Workers != 2 || r.LatsTotal != 4 || r.Lats["0.002"] != 4 {
+		t.Errorf("merged region eu = %+v", r)
+	}
+	if r := result.RegionDist["us"]; r == nil || r.Workers != 1 || r.ErrTotal != 1 {
+		t.Errorf("merged region us = %+v", r)
+	}
+}
+
+func TestWorkerSplit(t *testing.T) {
+	savedWeights, savedWorkers, savedSplit := regionWeights, workerList, workerSplit
+	defer func() { regionWeights, workerList, workerSplit = savedWeights, savedWorkers, savedSplit }()
+
+	params, _ := json.Marshal(StressParameters{Cmd: cmdStart, C: 10, N: 100, Qps: 50, ArrivalRate: 30})
+	for _, v := range []struct {
+		split   string
+		weights map[string]float64
+		c, n, q []int
+		rate    []float64
+	}{
+		{split: splitPerWorker, c: []int{10, 10, 10}, n: []int{100, 100, 100}, q: []int{50, 50, 50}, rate: []float64{30, 30, 30}},
+		{split: splitTotal, c: []int{4, 3, 3}, n: []int{34, 33, 33}, q: []int{17, 17, 16}, rate: []float64{10, 10, 10}},
+		{split: splitTotal, weights: map[string]float64{"eu": 0.5, "us": 0.5}, c: []int{5, 3, 2}, n: []int{50, 25, 25},
+			q: []int{25, 13, 12}, rate: []float64{15, 7.5, 7.5}},
+	} {
+		workerSplit, regionWeights, workerList = v.split, v.weights, []string{"127.0.0.1:12710", "127.0.0.1:12711", "127.0.0.1:12712"}
+		if v.weights != nil {
+			workerList = []string{"eu=127.0.0.1:12710", "us=127.0.0.1:12711", "us=127.0.0.1:12712"}
+		}
+		var cs, ns, qs []int
+		var rates []float64
+		for i := range workerList {
+			var p StressParameters
+			json.Unmarshal(regionParams(params, i), &p)
+			cs, ns, qs, rates = append(cs, p.C), append(ns, p.N), append(qs, p.Qps), append(rates, p.ArrivalRate)
+		}
+		if !reflect.DeepEqual(cs, v.c) || !reflect.DeepEqual(ns, v.n) || !reflect.DeepEqual(qs, v.q) || !reflect.DeepEqual(rates, v.rate) {
+			t.Errorf("split %s %v = c %v, n %v, q %v, rate %v, expect c %v, n %v, q %v, rate %v", v.split, v.weights,
+				cs, ns, qs, rates, v.c, v.n, v.q, v.rate)
+		}
+	}
+}
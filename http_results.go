@@ -3,15 +3,35 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"text/template"
+	"time"
 )
 
-const scaleNum = 10000
+const (
+	scaleNum = 10000
+
+	outputCsv          = "csv"
+	outputLatsOverTime = "latencies-over-time"
+	outputTemplate     = "template"
+	outputMarkdown     = "markdown"
+	outputJson         = "json"
+)
 
 var pctls = []int{10, 25, 50, 75, 90, 95, 99}
+
+// latsBuckets upper bounds(secs) of latency buckets for latencies over time
+var latsBuckets = []float64{0.001, 0.002, 0.005, 0.01, 0.02, 0.05, 0.1, 0.2, 0.5, 1, 2, 5}
 var resultRdMutex sync.RWMutex
 
+// baselineResult result of previous run to compare in markdown output
+var baselineResult *StressResult
+
 // StressResult record result
 type StressResult struct {
 	ErrCode  int    `json:"err_code"`
@@ -26,9 +46,128 @@ type StressResult struct {
 	StatusCodeDist map[int]int      `json:"status_code_dist"`
 	Lats           map[string]int64 `json:"lats"`
 	LatsTotal      int64            `json:"lats_total"`
-	SizeTotal      int64            `json:"size_total"`
+	SizeTotal      int64            `json:"size_total"`      // Decompressed body size
+	WireSizeTotal  int64            `json:"wire_size_total"` // On-the-wire body size
+	TruncatedTotal int64            `json:"truncated_total"` // Responses exceed max body read
 	Duration       int64            `json:"duration"`
 	Output         string           `json:"output"`
+	OutputTemplate string           `json:"-"` // Template of output, not sent back by workers
+
+	CpuUsage   int64                  `json:"cpu_usage"`   // Generator cpu usage(%)
+	Paced      bool                   `json:"paced"`       // Rate limited by -q, -rate, -polite or a live qps change
+	GC         *StressGC              `json:"gc"`          // Generator gc during the run
+	Arrival    *StressArrival         `json:"arrival"`     // Requests of the open model of -rate, nil if closed-loop
+	Warmup     *StressWarmup          `json:"warmup"`      // Requests excluded by -warmup, nil if not warming up
+	TimeSeries map[int64]*StressPoint `json:"time_series"` // Per second metrics, key is unix seconds
+
+	EndpointDist  map[string]*StressEndpoint `json:"endpoint_dist"`  // Per endpoint metrics when fallback url is set
+	FailoverUrl   string                     `json:"failover_url"`   // Switched to fallback url
+	FailoverAfter int64                      `json:"failover_after"` // Switched to fallback url after start in ms
+
+	Annotations []StressAnnotation `json:"annotations"` // External events, e.g. deploys
+
+	TlsInfo map[string]*StressTls `json:"tls_info"` // Tls and certificate chain per host
+
+	RateCurve []StressRate `json:"rate_curve"` // Rate limit changes of polite mode
+
+	RateLimit *StressRateLimit `json:"rate_limit"` // Rate limit headers of responses and 429, nil if none
+
+	Invalid string `json:"invalid"` // Reason the latency statistics are invalid, e.g. too few samples
+
+	RegionDist map[string]*StressRegion `json:"region_dist"` // Per region metrics of labeled workers
+
+	Dedup *StressDedup `json:"dedup"` // Duplicate processing of request ids, nil if not enabled
+
+	InterfaceDist map[string]*StressInterface `json:"interface_dist"` // Traffic per local interface of -interface
+
+	Socket *StressSocket `json:"socket"` // Socket options of the run
+
+	LocalIp *StressLocalIp `json:"local_ip"` // Connections of the source address pool of -local-ip, nil if not enabled
+
+	PipelineDist map[int]*StressPoint `json:"pipeline_dist"` // Latency by requests ahead in the pipeline of -pipeline
+
+	FamilyDist map[string]*StressPoint `json:"family_dist"` // Latency per ip family of -compare-ip-family
+
+	MixDist map[string]*StressPoint `json:"mix_dist"` // Latency per verb of -mix
+
+	UrlStrategy string                  `json:"url_strategy"` // Strategy of -url-strategy, empty if not enabled
+	UrlDist     map[string]*StressPoint `json:"url_dist"`     // Hits and latency per url of -url-strategy
+
+	TimingDist map[string]*StressPoint `json:"timing_dist"` // Latency of dns, connect, tls, ttfb and transfer phases of http1 and http2
+
+	ConnReuse *StressConnReuse `json:"conn_reuse"` // Requests on new and reused connections of http1 and http2, nil if not traced
+
+	FlowDist map[string]*StressFlowStep `json:"flow_dist"` // Requests per step of -flow
+
+	Batch *StressBatch `json:"batch"` // Items of -batch, nil if not batched
+
+	Asserts []StressAssert `json:"asserts"` // Responses checked by -assert, in the order of assertions
+
+	Hold *StressHold `json:"hold"` // Idle connections of -hold-connections, nil if not enabled
+
+	WebSocket *StressWebSocket `json:"websocket"` // Handshakes of websocket connections, nil if not websocket
+
+	Expect *StressExpect `json:"expect"` // Replies to "Expect: 100-continue" of -expect-continue, nil if not enabled
+
+	LongPoll *StressLongPoll `json:"longpoll"` // Cycles of -longpoll, nil if not long-poll
+
+	Schema *StressSchema `json:"json_schema"` // Responses validated by -assert-json-schema, nil if not enabled
+
+	Resume *StressResume `json:"resume"` // Downloads aborted and resumed of -resume, nil if not enabled
+
+	Abort *StressAbort `json:"abort"` // Downloads cancelled of -abort-after-bytes and -abort-after, nil if not enabled
+
+	Udp *StressUdp `json:"udp"` // Datagrams of -p udp, nil if not udp
+
+	Tcp *StressTcp `json:"tcp"` // Requests of -p tcp, nil if not tcp
+
+	Cookies *StressCookies `json:"cookies"` // Sessions of -enable-cookies, nil if not enabled
+
+	Redirects *StressRedirects `json:"redirects"` // 3xx hops followed or returned, nil if no redirects
+
+	Think *StressThink `json:"think"` // Pauses of -think-time and the offered rate, nil if not enabled
+
+	DeadWorkers []StressDeadWorker `json:"dead_workers"` // Workers lost during the distributed run
+
+	WorkerMetrics []StressWorkerMetrics `json:"worker_metrics,omitempty"` // Live metrics of each worker, only of cmdMetrics
+
+	Diagnosis []StressDiagnosis `json:"diagnosis"` // Diagnostics of generators with many dial timeouts
+
+	Preflight []StressPreflight `json:"preflight"` // Limits of generators raised or too low for the connections before the load
+
+	Contention []StressContention `json:"contention"` // Jobs overlapping on workers and the share granted to this one
+
+	TargetMetrics *StressTargetMetrics `json:"target_metrics"` // Resource usage of the target of -target-metrics-url, nil if not enabled
+
+	Clocks []StressClock `json:"clocks"` // Clock offsets of workers to the controller
+
+	Effective *StressEffective `json:"effective,omitempty"` // Effective parameters echoed by worker before load starts
+
+	TimeoutLats   map[string]int64 `json:"timeout_lats"`    // Elapsed time of timed out requests, capped at the timeout
+	TimeoutTotal  int64            `json:"timeout_total"`   // Requests aborted by timeout, also counted in errors
+	NearMissTotal int64            `json:"near_miss_total"` // Successful responses close to the timeout
+
+	digits int // Significant digits of latency buckets of -latency-resolution
+}
+
+// StressPoint record per second result
+type StressPoint struct {
+	LatsTotal int64            `json:"lats_total"`
+	ErrTotal  int64            `json:"err_total"`
+	Lats      map[string]int64 `json:"lats"`
+}
+
+// StressAnnotation external event marked in time series
+type StressAnnotation struct {
+	Time int64  `json:"time"` // unix ms
+	Msg  string `json:"msg"`
+}
+
+// StressEndpoint record per endpoint result
+type StressEndpoint struct {
+	LatsTotal int64 `json:"lats_total"`
+	ErrTotal  int64 `json:"err_total"`
+	AvgTotal  int64 `json:"avg_total"`
 }
 
 func toByteSizeStr(size float64) string {
@@ -47,11 +186,32 @@ func println(vfmt string, args ...interface{}) {
 	fmt.Printf(vfmt+"\n", args...)
 }
 
+func fprintln(w io.Writer, vfmt string, args ...interface{}) {
+	fmt.Fprintf(w, vfmt+"\n", args...)
+}
+
+// eprintln print progress to stderr, keep stdout for the output to redirect
+func eprintln(vfmt string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, vfmt+"\n", args...)
+}
+
 func GetStressResult() *StressResult {
 	return &StressResult{
 		ErrorDist:      make(map[string]int, 0),
 		StatusCodeDist: make(map[int]int, 0),
 		Lats:           make(map[string]int64, 0),
+		TimeSeries:     make(map[int64]*StressPoint, 0),
+		EndpointDist:   make(map[string]*StressEndpoint, 0),
+		TlsInfo:        make(map[string]*StressTls, 0),
+		RegionDist:     make(map[string]*StressRegion, 0),
+		InterfaceDist:  make(map[string]*StressInterface, 0),
+		PipelineDist:   make(map[int]*StressPoint, 0),
+		FamilyDist:     make(map[string]*StressPoint, 0),
+		MixDist:        make(map[string]*StressPoint, 0),
+		UrlDist:        make(map[string]*StressPoint, 0),
+		TimingDist:     make(map[string]*StressPoint, 0),
+		FlowDist:       make(map[string]*StressFlowStep, 0),
+		TimeoutLats:    make(map[string]int64, 0),
 		Slowest:        int64(IntMin),
 		Fastest:        int64(IntMax),
 	}
@@ -61,68 +221,426 @@ func (result *StressResult) print() {
 	resultRdMutex.RLock()
 	defer resultRdMutex.RUnlock()
 
-	switch result.Output {
-	case "csv":
-		println("Duration,Count")
+	result.write(os.Stdout, result.Output)
+}
+
+// write Write the result in output type to w, the summary if output type is empty
+func (result *StressResult) write(w io.Writer, output string) {
+	switch output {
+	case outputCsv, outputLatsOverTime, outputTemplate:
+		if result.Invalid != "" {
+			eprintln("statistically invalid: %s", result.Invalid) // keep stdout parseable
+		}
+	}
+
+	switch output {
+	case outputCsv:
+		fprintln(w, "Duration,Count")
 		for duration, val := range result.Lats {
-			println("%s,%d", duration, val/scaleNum)
+			fprintln(w, "%s,%d", duration, val)
+		}
+		return
+	case outputLatsOverTime:
+		result.printLatenciesOverTime(w)
+		return
+	case outputTemplate:
+		if err := result.printTemplate(w); err != nil {
+			verbosePrint(vERROR, "output template err: %v", err)
+		}
+		return
+	case outputMarkdown:
+		result.printMarkdown(w, baselineResult)
+		return
+	case outputJson:
+		if body, err := json.Marshal(result); err == nil {
+			fprintln(w, "%s", body)
+		}
+		return
+	case outputHtml:
+		if err := result.printHtml(w); err != nil {
+			verbosePrint(vERROR, "output html err: %v", err)
 		}
 		return
 	}
 	if len(result.Lats) > 0 {
-		println("Summary:")
-		println("  Total:\t%4.3f secs", float32(result.Duration))
-		println("  Slowest:\t%4.3f secs", float32(result.Slowest)/scaleNum)
-		println("  Fastest:\t%4.3f secs", float32(result.Fastest)/scaleNum)
-		println("  Average:\t%4.3f secs", float32(result.Average)/scaleNum)
-		println("  Requests/sec:\t%4.3f", float32(result.Rps)/scaleNum)
-		println("  Total data:\t%s", toByteSizeStr(float64(result.SizeTotal)))
-		println("  Size/request:\t%d bytes", result.SizeTotal/result.LatsTotal)
-		result.printStatusCodes()
-		result.printLatencies()
+		fprintln(w, "Summary:")
+		fprintln(w, "  Total:\t%4.3f secs", float32(result.Duration))
+		fprintln(w, "  Slowest:\t%4.3f secs", float32(result.Slowest)/scaleNum)
+		fprintln(w, "  Fastest:\t%4.3f secs", float32(result.Fastest)/scaleNum)
+		fprintln(w, "  Average:\t%4.3f secs", float32(result.Average)/scaleNum)
+		fprintln(w, "  Requests/sec:\t%4.3f", float32(result.Rps)/scaleNum)
+		if result.Batch != nil {
+			result.printBatch(w)
+		}
+		fprintln(w, "  Total data:\t%s", toByteSizeStr(float64(result.SizeTotal)))
+		if result.WireSizeTotal > 0 && result.WireSizeTotal != result.SizeTotal {
+			fprintln(w, "  Wire data:\t%s", toByteSizeStr(float64(result.WireSizeTotal)))
+			fprintln(w, "  Compression:\t%4.3f", float64(result.SizeTotal)/float64(result.WireSizeTotal))
+		}
+		fprintln(w, "  Size/request:\t%d bytes", result.SizeTotal/result.LatsTotal)
+		if result.TruncatedTotal > 0 {
+			fprintln(w, "  Truncated:\t%d responses", result.TruncatedTotal)
+		}
+		result.printStatusCodes(w)
+		if result.Invalid != "" {
+			fprintln(w, "\nLatency distribution: statistically invalid, %s", result.Invalid)
+		} else {
+			result.printLatencies(w)
+			result.printHistogram(w)
+		}
+		if len(result.TimingDist) > 0 {
+			result.printTimings(w)
+		}
+	}
+	if result.ConnReuse != nil {
+		result.printConnReuse(w)
+	}
+	if result.Arrival != nil {
+		result.printArrival(w)
+	}
+	if result.Warmup != nil {
+		result.printWarmup(w)
+	}
+	if result.TimeoutTotal > 0 || result.NearMissTotal > 0 {
+		result.printTimeouts(w)
+	}
+	if len(result.EndpointDist) > 0 {
+		result.printEndpoints(w)
+	}
+	if len(result.RegionDist) > 0 {
+		result.printRegions(w)
+	}
+	if len(result.Clocks) > 0 {
+		result.printClocks(w)
+	}
+	if len(result.InterfaceDist) > 0 {
+		result.printInterfaces(w)
+	}
+	if result.LocalIp != nil {
+		result.printLocalIp(w)
+	}
+	if len(result.PipelineDist) > 0 {
+		result.printPipeline(w)
+	}
+	if len(result.FamilyDist) > 0 {
+		result.printFamilies(w)
+	}
+	if len(result.MixDist) > 0 {
+		result.printMix(w)
+	}
+	if len(result.UrlDist) > 0 {
+		result.printUrls(w)
+	}
+	if len(result.FlowDist) > 0 {
+		result.printFlow(w)
+	}
+	if result.Expect != nil {
+		result.printExpect(w)
+	}
+	if result.LongPoll != nil {
+		result.printLongPoll(w)
+	}
+	if result.Schema != nil {
+		result.printSchema(w)
+	}
+	if result.Resume != nil {
+		result.printResume(w)
+	}
+	if result.Abort != nil {
+		result.printAbort(w)
+	}
+	if result.Udp != nil {
+		result.printUdp(w)
+	}
+	if result.Tcp != nil {
+		result.printTcp(w)
+	}
+	if result.Cookies != nil {
+		result.printCookies(w)
+	}
+	if result.Redirects != nil {
+		result.printRedirects(w)
+	}
+	if result.Think != nil {
+		result.printThink(w)
+	}
+	if len(result.DeadWorkers) > 0 {
+		result.printDeadWorkers(w)
+	}
+	if len(result.Asserts) > 0 {
+		result.printAsserts(w)
+	}
+	if result.Hold != nil {
+		result.printHold(w)
+	}
+	if result.WebSocket != nil {
+		result.printWebSocket(w)
+	}
+	if result.Socket != nil && result.Socket.tuned() {
+		result.printSocket(w)
+	}
+	if result.Dedup != nil {
+		result.printDedup(w)
+	}
+	if len(result.TlsInfo) > 0 {
+		result.printTls(w)
+	}
+	if result.GC != nil && result.GC.notable(result.Duration) {
+		result.printGC(w)
 	}
 	if len(result.ErrorDist) > 0 {
-		result.printErrors()
+		result.printErrors(w)
+	}
+	if len(result.Diagnosis) > 0 {
+		result.printDiagnosis(w)
+	}
+	if len(result.Preflight) > 0 {
+		result.printPreflight(w)
+	}
+	if len(result.Contention) > 0 {
+		result.printContention(w)
+	}
+	if result.TargetMetrics != nil {
+		result.printTargetMetrics(w)
+	}
+	if result.ErrMsg != "" {
+		fprintln(w, "\nStopped: %s", result.ErrMsg)
+	}
+	if result.RateLimit != nil {
+		result.printRateLimit(w)
+	}
+	if len(result.RateCurve) > 0 {
+		result.printRateCurve(w)
 	}
+	if len(result.Annotations) > 0 {
+		result.printAnnotations(w)
+	}
+	result.printHints(w)
 }
 
-// printLatencies Print latency distribution.
-func (result *StressResult) printLatencies() {
-	data := make([]string, len(pctls))
-	durationLats := make([]string, 0)
-	for duration := range result.Lats {
-		durationLats = append(durationLats, duration)
+// latsPercentiles calculate latency(secs) of pctls from lats distribution
+func latsPercentiles(lats map[string]int64, total int64, pctls []int) []float64 {
+	data := make([]float64, len(pctls))
+	if total <= 0 {
+		return data
+	}
+
+	type latsCount struct {
+		duration float64
+		count    int64
 	}
 
-	sort.Strings(durationLats)
+	durationLats := make([]latsCount, 0, len(lats))
+	for duration, count := range lats {
+		if v, err := strconv.ParseFloat(duration, 64); err == nil {
+			durationLats = append(durationLats, latsCount{v, count})
+		}
+	}
+
+	sort.Slice(durationLats, func(i, j int) bool {
+		return durationLats[i].duration < durationLats[j].duration
+	})
 
 	for i, j, dCounts := 0, 0, int64(0); i < len(durationLats) && j < len(pctls); i = i + 1 {
-		dCounts = dCounts + result.Lats[durationLats[i]]
-		if int(dCounts*100/result.LatsTotal) >= pctls[j] {
-			data[j] = durationLats[i]
+		dCounts = dCounts + durationLats[i].count
+		for j < len(pctls) && int(dCounts*100/total) >= pctls[j] {
+			data[j] = durationLats[i].duration
 			j++
 		}
 	}
 
-	println("\nLatency distribution:")
+	return data
+}
+
+// exitCode exit code of the result, positive ErrCode of workers is the exit code
+func (result *StressResult) exitCode() int {
+	switch {
+	case result.ErrCode > 0:
+		return result.ErrCode
+	case result.ErrCode < 0:
+		return exitInternal
+	case result.LatsTotal <= 0 && result.ErrTotal() > 0:
+		return exitUnreachable
+	case result.Invalid != "", result.Dedup != nil && result.Dedup.violated(), result.assertsViolated(),
+		result.Schema != nil && result.Schema.Violated > 0:
+		return exitAssertion
+	}
+	return exitOK
+}
+
+// checkSamples flag the result statistically invalid when successful samples are fewer than min
+func (result *StressResult) checkSamples(min int64) {
+	if min > 0 && result.LatsTotal < min {
+		result.Invalid = fmt.Sprintf("only %d successful samples, fewer than -min-samples %d", result.LatsTotal, min)
+	}
+}
+
+// printLatencies Print latency distribution.
+func (result *StressResult) printLatencies(w io.Writer) {
+	data := latsPercentiles(result.Lats, result.LatsTotal, pctls)
+
+	fprintln(w, "\nLatency distribution:")
 	for i := 0; i < len(pctls); i++ {
-		println("  %v%% in %s secs", pctls[i], data[i])
+		fprintln(w, "  %v%% in %4.3f secs", pctls[i], data[i])
 	}
 }
 
+// printLatenciesOverTime Print (second, latency bucket) counts matrix in csv format.
+func (result *StressResult) printLatenciesOverTime(w io.Writer) {
+	seconds := make([]int64, 0, len(result.TimeSeries))
+	for sec := range result.TimeSeries {
+		seconds = append(seconds, sec)
+	}
+	sort.Slice(seconds, func(i, j int) bool { return seconds[i] < seconds[j] })
+
+	header := []string{"Second"}
+	for _, bucket := range latsBuckets {
+		header = append(header, fmt.Sprintf("<=%4.3f", bucket))
+	}
+	header = append(header, fmt.Sprintf(">%4.3f", latsBuckets[len(latsBuckets)-1]), "Errors")
+	fprintln(w, strings.Join(header, ","))
+
+	for _, sec := range seconds {
+		point := result.TimeSeries[sec]
+		counts := make([]int64, len(latsBuckets)+1)
+		for duration, c := range point.Lats {
+			v, err := strconv.ParseFloat(duration, 64)
+			if err != nil {
+				continue
+			}
+			counts[sort.SearchFloat64s(latsBuckets, v)] += c
+		}
+
+		row := []string{strconv.FormatInt(sec-seconds[0], 10)}
+		for _, c := range counts {
+			row = append(row, strconv.FormatInt(c, 10))
+		}
+		row = append(row, strconv.FormatInt(point.ErrTotal, 10))
+		fprintln(w, strings.Join(row, ","))
+	}
+}
+
+// ErrRate ratio of errors in all requests
+func (result *StressResult) ErrRate() float64 {
+	errTotal := result.ErrTotal()
+	if errTotal+result.LatsTotal <= 0 {
+		return 0
+	}
+	return float64(errTotal) / float64(errTotal+result.LatsTotal)
+}
+
+// printMarkdown Print compact markdown table for pull request comments, compare to baseline if not nil
+// as "http_bench compare -o markdown" without thresholds
+func (result *StressResult) printMarkdown(w io.Writer, baseline *StressResult) {
+	if baseline != nil {
+		printCompare(w, compareResults(baseline, result, compareThresholds{Latency: -1, Rps: -1, ErrRate: -1}), true)
+	} else {
+		rows := []struct {
+			name   string
+			value  float64
+			format string
+		}{
+			{"Requests/sec", float64(result.Rps) / scaleNum, "%.1f"},
+			{"p50", result.Percentile(50), "%4.3f secs"},
+			{"p95", result.Percentile(95), "%4.3f secs"},
+			{"p99", result.Percentile(99), "%4.3f secs"},
+			{"Error rate", result.ErrRate() * 100, "%.2f%%"},
+		}
+		fmt.Fprintln(w, "| Metric | Value |")
+		fmt.Fprintln(w, "|---|---:|")
+		for _, row := range rows {
+			if strings.HasPrefix(row.name, "p") && result.Invalid != "" {
+				continue // percentiles of too few samples are misleading
+			}
+			fmt.Fprintf(w, "| %s | "+row.format+" |\n", row.name, row.value)
+		}
+	}
+	if result.Invalid != "" {
+		fmt.Fprintf(w, "\n> Statistically invalid: %s\n", result.Invalid)
+	}
+	if baseline != nil && baseline.Invalid != "" {
+		fmt.Fprintf(w, "\n> Baseline statistically invalid: %s\n", baseline.Invalid)
+	}
+}
+
+// outputFnMap functions for output template besides fnMap
+var outputFnMap = template.FuncMap{
+	"secs":     func(v int64) float64 { return float64(v) / scaleNum },
+	"byteSize": func(v int64) string { return toByteSizeStr(float64(v)) },
+}
+
+// parseOutputTemplate parse output template with functions
+func parseOutputTemplate(text string) (*template.Template, error) {
+	return template.New("OUTPUT").Funcs(fnMap).Funcs(outputFnMap).Parse(text)
+}
+
+// printTemplate Print result with user template, the data is the full result
+func (result *StressResult) printTemplate(w io.Writer) error {
+	tpl, err := parseOutputTemplate(result.OutputTemplate)
+	if err != nil {
+		return err
+	}
+	return tpl.Execute(w, result)
+}
+
+// Percentile latency(secs) of percentile p, for output template
+func (result *StressResult) Percentile(p int) float64 {
+	return latsPercentiles(result.Lats, result.LatsTotal, []int{p})[0]
+}
+
+// ErrTotal total number of errors, for output template
+func (result *StressResult) ErrTotal() int64 {
+	var total int64
+	for _, c := range result.ErrorDist {
+		total += int64(c)
+	}
+	return total
+}
+
 // printStatusCodes Print status code distribution.
-func (result *StressResult) printStatusCodes() {
-	println("\nStatus code distribution:")
+func (result *StressResult) printStatusCodes(w io.Writer) {
+	fprintln(w, "\nStatus code distribution:")
 	for code, num := range result.StatusCodeDist {
-		println("  [%d]\t%d responses", code, num)
+		fprintln(w, "  [%d]\t%d responses", code, num)
+	}
+}
+
+// printEndpoints Print per endpoint distribution and failover
+func (result *StressResult) printEndpoints(w io.Writer) {
+	fprintln(w, "\nEndpoint distribution:")
+	for endpoint, e := range result.EndpointDist {
+		var average float32
+		if e.LatsTotal > 0 {
+			average = float32(e.AvgTotal/e.LatsTotal) / scaleNum
+		}
+		fprintln(w, "  [%s]\t%d responses, %d errors, %4.3f secs average", endpoint, e.LatsTotal, e.ErrTotal, average)
+	}
+	if result.FailoverUrl != "" {
+		fprintln(w, "  Failover:\tswitched to %s after %4.3f secs", result.FailoverUrl, float32(result.FailoverAfter)/1000)
+	}
+}
+
+// printAnnotations Print external events
+func (result *StressResult) printAnnotations(w io.Writer) {
+	fprintln(w, "\nAnnotations:")
+	for _, a := range result.Annotations {
+		fprintln(w, "  [%s]\t%s", time.UnixMilli(a.Time).Format("15:04:05.000"), a.Msg)
 	}
 }
 
 // printErrors Print response errors
-func (result *StressResult) printErrors() {
-	println("\nError distribution:")
+func (result *StressResult) printErrors(w io.Writer) {
+	fprintln(w, "\nError distribution:")
+	var dialTimeouts, distinct int
 	for err, num := range result.ErrorDist {
-		fmt.Printf("  [%d]\t%s", num, err)
+		if len(result.Diagnosis) > 0 && isDialTimeout(err) {
+			dialTimeouts, distinct = dialTimeouts+num, distinct+1 // see the connection diagnosis
+			continue
+		}
+		fprintln(w, "  [%d]\t%s", num, err)
+	}
+	if dialTimeouts > 0 {
+		fprintln(w, "  [%d]\tdial timeout (%d distinct errors, see the connection diagnosis)", dialTimeouts, distinct)
 	}
 }
 
@@ -137,10 +655,186 @@ func (result *StressResult) append(res *result) {
 	resultRdMutex.Lock()
 	defer resultRdMutex.Unlock()
 
+	point := result.TimeSeries[res.start.Unix()]
+	if point == nil {
+		point = &StressPoint{Lats: make(map[string]int64, 0)}
+		result.TimeSeries[res.start.Unix()] = point
+	}
+
+	if res.tls != nil {
+		result.TlsInfo[res.tls.Host] = res.tls
+	}
+
+	var endpoint *StressEndpoint
+	if res.endpoint != "" {
+		if endpoint = result.EndpointDist[res.endpoint]; endpoint == nil {
+			endpoint = &StressEndpoint{}
+			result.EndpointDist[res.endpoint] = endpoint
+		}
+	}
+
+	if res.timeout {
+		result.TimeoutLats[fmt.Sprintf("%4.3f", res.duration.Seconds())]++
+		result.TimeoutTotal++
+	}
+	if res.nearMiss {
+		result.NearMissTotal++
+	}
+
+	if len(res.asserts) > 0 {
+		result.appendAsserts(res.asserts)
+	}
+	if res.items > 0 && res.err == nil {
+		if result.Batch == nil {
+			result.Batch = &StressBatch{}
+		}
+		result.Batch.Size = res.items
+		result.Batch.Items += int64(res.items)
+	}
+	if res.expect != "" {
+		if result.Expect == nil {
+			result.Expect = &StressExpect{}
+		}
+		result.Expect.append(res)
+	}
+	if res.longpoll != "" {
+		if result.LongPoll == nil {
+			result.LongPoll = &StressLongPoll{WaitLats: make(map[string]int64, 0)}
+		}
+		result.LongPoll.append(res, result.digits)
+	}
+	if res.schemaChecked {
+		if result.Schema == nil {
+			result.Schema = &StressSchema{}
+		}
+		result.Schema.append(res)
+	}
+	if res.udp {
+		if result.Udp == nil {
+			result.Udp = &StressUdp{}
+		}
+		result.Udp.append(res)
+	}
+	if res.tcp {
+		if result.Tcp == nil {
+			result.Tcp = &StressTcp{}
+		}
+		result.Tcp.append(res)
+	}
+	if res.cookies {
+		if result.Cookies == nil {
+			result.Cookies = &StressCookies{}
+		}
+		result.Cookies.append(res)
+	}
+	if len(res.hops) > 0 || res.hopsExceeded || res.unfollowed {
+		if result.Redirects == nil {
+			result.Redirects = &StressRedirects{Codes: make(map[int]int64)}
+		}
+		result.Redirects.append(res)
+	}
+	if res.abort != "" {
+		if result.Abort == nil {
+			result.Abort = &StressAbort{}
+		}
+		result.Abort.append(res)
+	}
+	if res.resume != "" {
+		if result.Resume == nil {
+			result.Resume = &StressResume{ResumeLats: make(map[string]int64, 0)}
+		}
+		result.Resume.append(res, result.digits)
+	}
+	if hasRateLimit(res) {
+		if result.RateLimit == nil {
+			result.RateLimit = &StressRateLimit{Limits: make(map[string]int64, 0), MinRemaining: -1}
+		}
+		result.RateLimit.append(res)
+	}
+	if res.flowStep != "" {
+		step := result.FlowDist[res.flowStep]
+		if step == nil {
+			step = &StressFlowStep{}
+			result.FlowDist[res.flowStep] = step
+		}
+		step.append(res)
+	}
+
+	var pipelinePoint *StressPoint
+	if res.pipelined {
+		if pipelinePoint = result.PipelineDist[res.pipelinePos]; pipelinePoint == nil {
+			pipelinePoint = &StressPoint{Lats: make(map[string]int64, 0)}
+			result.PipelineDist[res.pipelinePos] = pipelinePoint
+		}
+	}
+	result.appendTimings(res)
+	if res.conn != 0 {
+		if result.ConnReuse == nil {
+			result.ConnReuse = &StressConnReuse{}
+		}
+		result.ConnReuse.append(res)
+	}
+	var familyPoint *StressPoint
+	if res.family != "" {
+		if familyPoint = result.FamilyDist[res.family]; familyPoint == nil {
+			familyPoint = &StressPoint{Lats: make(map[string]int64, 0)}
+			result.FamilyDist[res.family] = familyPoint
+		}
+	}
+	var mixPoint *StressPoint
+	if res.mixMethod != "" {
+		if mixPoint = result.MixDist[res.mixMethod]; mixPoint == nil {
+			mixPoint = &StressPoint{Lats: make(map[string]int64, 0)}
+			result.MixDist[res.mixMethod] = mixPoint
+		}
+	}
+	var urlPoint *StressPoint
+	if res.target != "" {
+		if urlPoint = result.UrlDist[res.target]; urlPoint == nil {
+			urlPoint = &StressPoint{Lats: make(map[string]int64, 0)}
+			result.UrlDist[res.target] = urlPoint
+		}
+	}
+
 	if res.err != nil {
 		result.ErrorDist[res.err.Error()]++
+		point.ErrTotal++
+		if endpoint != nil {
+			endpoint.ErrTotal++
+		}
+		if pipelinePoint != nil {
+			pipelinePoint.ErrTotal++
+		}
+		if familyPoint != nil {
+			familyPoint.ErrTotal++
+		}
+		if mixPoint != nil {
+			mixPoint.ErrTotal++
+		}
+		if urlPoint != nil {
+			urlPoint.ErrTotal++
+		}
 	} else {
-		result.Lats[fmt.Sprintf("%4.3f", res.duration.Seconds())]++
+		lats := latsKey(res.duration, result.digits)
+		result.Lats[lats]++
+		point.Lats[lats]++
+		point.LatsTotal++
+		if pipelinePoint != nil {
+			pipelinePoint.Lats[lats]++
+			pipelinePoint.LatsTotal++
+		}
+		if familyPoint != nil {
+			familyPoint.Lats[lats]++
+			familyPoint.LatsTotal++
+		}
+		if mixPoint != nil {
+			mixPoint.Lats[lats]++
+			mixPoint.LatsTotal++
+		}
+		if urlPoint != nil {
+			urlPoint.Lats[lats]++
+			urlPoint.LatsTotal++
+		}
 		duration := int64(res.duration.Seconds() * scaleNum)
 		result.LatsTotal++
 		if result.Slowest < duration {
@@ -150,10 +844,20 @@ func (result *StressResult) append(res *result) {
 			result.Fastest = duration
 		}
 		result.AvgTotal += duration
+		if endpoint != nil {
+			endpoint.LatsTotal++
+			endpoint.AvgTotal += duration
+		}
 		result.StatusCodeDist[res.statusCode]++
 		if res.contentLength > 0 {
 			result.SizeTotal += res.contentLength
 		}
+		if res.wireLength > 0 {
+			result.WireSizeTotal += res.wireLength
+		}
+		if res.truncated {
+			result.TruncatedTotal++
+		}
 	}
 }
 
@@ -166,6 +870,9 @@ func calMutliStressResult(result *StressResult, resultList ...StressResult) *Str
 	var duration int64 = result.Duration
 
 	for _, v := range resultList {
+		if result.ErrCode == 0 && v.ErrCode != 0 {
+			result.ErrCode, result.ErrMsg = v.ErrCode, v.ErrMsg
+		}
 		if result.Slowest < v.Slowest {
 			result.Slowest = v.Slowest
 		}
@@ -178,12 +885,219 @@ func calMutliStressResult(result *StressResult, resultList ...StressResult) *Str
 			result.StatusCodeDist[code] += c
 		}
 		result.SizeTotal += v.SizeTotal
+		result.WireSizeTotal += v.WireSizeTotal
+		result.TruncatedTotal += v.TruncatedTotal
 		for code, c := range v.ErrorDist {
 			result.ErrorDist[code] += c
 		}
 		for lats, c := range v.Lats {
 			result.Lats[lats] += c
 		}
+		for lats, c := range v.TimeoutLats {
+			result.TimeoutLats[lats] += c
+		}
+		result.TimeoutTotal += v.TimeoutTotal
+		result.NearMissTotal += v.NearMissTotal
+		mergePoints(result.TimeSeries, v.TimeSeries)
+		mergePoints(result.PipelineDist, v.PipelineDist)
+		mergePoints(result.FamilyDist, v.FamilyDist)
+		mergePoints(result.MixDist, v.MixDist)
+		if v.UrlStrategy != "" {
+			result.UrlStrategy = v.UrlStrategy
+		}
+		mergePoints(result.UrlDist, v.UrlDist)
+		mergePoints(result.TimingDist, v.TimingDist)
+		if result.CpuUsage < v.CpuUsage {
+			result.CpuUsage = v.CpuUsage
+		}
+		result.Paced = result.Paced || v.Paced
+		if v.RateLimit != nil {
+			if result.RateLimit == nil {
+				result.RateLimit = &StressRateLimit{Limits: make(map[string]int64, 0), MinRemaining: -1}
+			}
+			result.RateLimit.merge(v.RateLimit)
+		}
+		if v.Arrival != nil {
+			if result.Arrival == nil {
+				result.Arrival = &StressArrival{}
+			}
+			result.Arrival.merge(v.Arrival)
+		}
+		if v.ConnReuse != nil {
+			if result.ConnReuse == nil {
+				result.ConnReuse = &StressConnReuse{}
+			}
+			result.ConnReuse.merge(v.ConnReuse)
+		}
+		if v.LocalIp != nil {
+			if result.LocalIp == nil {
+				result.LocalIp = &StressLocalIp{}
+			}
+			result.LocalIp.merge(v.LocalIp)
+		}
+		if v.Warmup != nil {
+			if result.Warmup == nil {
+				result.Warmup = &StressWarmup{}
+			}
+			result.Warmup.merge(v.Warmup)
+		}
+		if v.GC != nil {
+			if result.GC == nil {
+				result.GC = &StressGC{}
+			}
+			result.GC.merge(v.GC)
+		}
+		for name, e := range v.EndpointDist {
+			endpoint := result.EndpointDist[name]
+			if endpoint == nil {
+				endpoint = &StressEndpoint{}
+				result.EndpointDist[name] = endpoint
+			}
+			endpoint.LatsTotal += e.LatsTotal
+			endpoint.ErrTotal += e.ErrTotal
+			endpoint.AvgTotal += e.AvgTotal
+		}
+		if v.FailoverUrl != "" && (result.FailoverUrl == "" || result.FailoverAfter > v.FailoverAfter) {
+			result.FailoverUrl, result.FailoverAfter = v.FailoverUrl, v.FailoverAfter
+		}
+		for name, r := range v.RegionDist {
+			region := result.RegionDist[name]
+			if region == nil {
+				region = &StressRegion{Lats: make(map[string]int64, 0)}
+				result.RegionDist[name] = region
+			}
+			region.Workers += r.Workers
+			region.LatsTotal += r.LatsTotal
+			region.ErrTotal += r.ErrTotal
+			region.AvgTotal += r.AvgTotal
+			for lats, c := range r.Lats {
+				region.Lats[lats] += c
+			}
+		}
+		if v.Dedup != nil {
+			if result.Dedup == nil {
+				result.Dedup = &StressDedup{}
+			}
+			result.Dedup.merge(v.Dedup)
+		}
+		result.mergeAsserts(v.Asserts)
+		if v.Batch != nil {
+			if result.Batch == nil {
+				result.Batch = &StressBatch{}
+			}
+			result.Batch.merge(v.Batch)
+		}
+		if v.Hold != nil {
+			if result.Hold == nil {
+				result.Hold = &StressHold{}
+			}
+			result.Hold.merge(v.Hold)
+		}
+		if v.WebSocket != nil {
+			if result.WebSocket == nil {
+				result.WebSocket = &StressWebSocket{}
+			}
+			result.WebSocket.merge(v.WebSocket)
+		}
+		if v.Expect != nil {
+			if result.Expect == nil {
+				result.Expect = &StressExpect{}
+			}
+			result.Expect.merge(v.Expect)
+		}
+		if v.LongPoll != nil {
+			if result.LongPoll == nil {
+				result.LongPoll = &StressLongPoll{}
+			}
+			result.LongPoll.merge(v.LongPoll)
+		}
+		if v.Schema != nil {
+			if result.Schema == nil {
+				result.Schema = &StressSchema{}
+			}
+			result.Schema.merge(v.Schema)
+		}
+		if v.Abort != nil {
+			if result.Abort == nil {
+				result.Abort = &StressAbort{}
+			}
+			result.Abort.merge(v.Abort)
+		}
+		if v.Resume != nil {
+			if result.Resume == nil {
+				result.Resume = &StressResume{}
+			}
+			result.Resume.merge(v.Resume)
+		}
+		if v.Udp != nil {
+			if result.Udp == nil {
+				result.Udp = &StressUdp{}
+			}
+			result.Udp.merge(v.Udp)
+		}
+		if v.Tcp != nil {
+			if result.Tcp == nil {
+				result.Tcp = &StressTcp{}
+			}
+			result.Tcp.merge(v.Tcp)
+		}
+		if v.Cookies != nil {
+			if result.Cookies == nil {
+				result.Cookies = &StressCookies{}
+			}
+			result.Cookies.merge(v.Cookies)
+		}
+		if v.Think != nil {
+			if result.Think == nil {
+				result.Think = &StressThink{}
+			}
+			result.Think.merge(v.Think)
+		}
+		if v.Redirects != nil {
+			if result.Redirects == nil {
+				result.Redirects = &StressRedirects{Codes: make(map[int]int64)}
+			}
+			result.Redirects.merge(v.Redirects)
+		}
+		if result.Socket == nil && v.Socket != nil {
+			result.Socket = v.Socket
+		}
+		for name, s := range v.FlowDist {
+			step := result.FlowDist[name]
+			if step == nil {
+				step = &StressFlowStep{}
+				result.FlowDist[name] = step
+			}
+			step.merge(s)
+		}
+		for name, i := range v.InterfaceDist {
+			iface := result.InterfaceDist[name]
+			if iface == nil {
+				iface = &StressInterface{}
+				result.InterfaceDist[name] = iface
+			}
+			iface.Conns += i.Conns
+			iface.BytesSent += i.BytesSent
+			iface.BytesRecv += i.BytesRecv
+		}
+		for host, info := range v.TlsInfo {
+			result.TlsInfo[host] = info
+		}
+		result.RateCurve = append(result.RateCurve, v.RateCurve...)
+		result.Clocks = append(result.Clocks, v.Clocks...)
+		result.Diagnosis = append(result.Diagnosis, v.Diagnosis...)
+		result.Preflight = append(result.Preflight, v.Preflight...)
+		result.Contention = append(result.Contention, v.Contention...)
+		result.DeadWorkers = append(result.DeadWorkers, v.DeadWorkers...)
+		result.WorkerMetrics = append(result.WorkerMetrics, v.WorkerMetrics...)
+		if v.TargetMetrics != nil {
+			result.TargetMetrics = v.TargetMetrics
+		}
+		for _, a := range v.Annotations {
+			if !containsAnnotation(result.Annotations, a) {
+				result.Annotations = append(result.Annotations, a)
+			}
+		}
 
 		if duration < v.Duration {
 			duration = v.Duration
@@ -199,5 +1113,38 @@ func calMutliStressResult(result *StressResult, resultList ...StressResult) *Str
 		result.Average = result.AvgTotal / result.LatsTotal
 	}
 
+	sort.Slice(result.Annotations, func(i, j int) bool {
+		return result.Annotations[i].Time < result.Annotations[j].Time
+	})
+	sort.SliceStable(result.RateCurve, func(i, j int) bool {
+		return result.RateCurve[i].Time < result.RateCurve[j].Time
+	})
+
 	return result
 }
+
+// mergePoints add the points of src to dst of the same key
+func mergePoints[K comparable](dst, src map[K]*StressPoint) {
+	for k, p := range src {
+		point := dst[k]
+		if point == nil {
+			point = &StressPoint{Lats: make(map[string]int64, 0)}
+			dst[k] = point
+		}
+		point.LatsTotal += p.LatsTotal
+		point.ErrTotal += p.ErrTotal
+		for lats, c := range p.Lats {
+			point.Lats[lats] += c
+		}
+	}
+}
+
+// containsAnnotation the same annotation is forwarded to all workers
+func containsAnnotation(annotations []StressAnnotation, a StressAnnotation) bool {
+	for _, v := range annotations {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintTemplate(t *testing.T) {
+	result := GetStressResult()
+	result.LatsTotal = 100
+	result.Lats = map[string]int64{"0.010": 50, "0.100": 50}
+	result.ErrorDist = map[string]int{"timeout": 2, "refused": 1}
+	result.Average = 550
+	result.SizeTotal = 2048
+	result.OutputTemplate = `{{ .LatsTotal }}|{{ .ErrTotal }}|{{ .Percentile 99 }}|{{ secs .Average }}|{{ byteSize .SizeTotal }}`
+
+	var buf bytes.Buffer
+	if err := result.printTemplate(&buf); err != nil {
+		t.Fatalf("printTemplate err: %v", err)
+	}
+	if expect := "100|3|0.1|0.055|2.000 KB"; buf.String() != expect {
+		t.Errorf("printTemplate = %s, expect: %s", buf.String(), expect)
+	}
+
+	result.OutputTemplate = `{{ .None }}`
+	if err := result.printTemplate(&buf); err == nil {
+		t.Errorf("printTemplate not exist field expect err")
+	}
+}
+
+func TestPrintMarkdown(t *testing.T) {
+	result := GetStressResult()
+	result.LatsTotal, result.Rps = 99, 1100*scaleNum
+	result.Lats = map[string]int64{"0.010": 99}
+	result.ErrorDist = map[string]int{"timeout": 1}
+
+	baseline := GetStressResult()
+	baseline.LatsTotal, baseline.Rps = 100, 1000*scaleNum
+	baseline.Lats = map[string]int64{"0.020": 100}
+
+	var buf bytes.Buffer
+	result.printMarkdown(&buf, nil)
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 7 || lines[0] != "| Metric | Value |" {
+		t.Errorf("printMarkdown without baseline = %s", buf.String())
+	}
+
+	buf.Reset()
+	result.printMarkdown(&buf, baseline)
+	for _, expect := range []string{
+		"| Metric | Baseline | Value | Change | |",
+		"| Requests/sec | 1000.0 | 1100.0 | +10.0% |  |",
+		"| Average | 0.000 secs | 0.000 secs | - |  |",
+		"| p90 | 0.020 secs | 0.010 secs | -50.0% |  |",
+		"| p99 | 0.020 secs | 0.010 secs | -50.0% |  |",
+		"| Error rate | 0.00% | 1.00% | +1.00pp |  |",
+	} {
+		if !strings.Contains(buf.String(), expect) {
+			t.Errorf("printMarkdown = %s, expect contains: %s", buf.String(), expect)
+		}
+	}
+}
+
+func TestCheckSamples(t *testing.T) {
+	result := GetStressResult()
+	result.LatsTotal = 12
+	result.Lats = map[string]int64{"0.010": 12}
+
+	result.checkSamples(0)
+	if result.Invalid != "" {
+		t.Errorf("checkSamples(0) = %s, expect valid", result.Invalid)
+	}
+
+	result.checkSamples(100)
+	if result.Invalid == "" {
+		t.Fatalf("checkSamples(100) of 12 samples expect invalid")
+	}
+	var buf bytes.Buffer
+	result.printMarkdown(&buf, nil)
+	if strings.Contains(buf.String(), "| p99 |") || !strings.Contains(buf.String(), "> Statistically invalid: only 12") {
+		t.Errorf("printMarkdown of invalid result = %s", buf.String())
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	for _, v := range []struct {
+		result StressResult
+		expect int
+	}{
+		{result: StressResult{LatsTotal: 10}, expect: exitOK},
+		{result: StressResult{ErrorDist: map[string]int{"refused": 3}}, expect: exitUnreachable},
+		{result: StressResult{}, expect: exitOK}, // stopped before any request
+		{result: StressResult{LatsTotal: 10, Invalid: "too few"}, expect: exitAssertion},
+		{result: StressResult{LatsTotal: 10, ErrCode: exitCircuitBreaker}, expect: exitCircuitBreaker},
+		{result: StressResult{ErrCode: -1}, expect: exitInternal},
+	} {
+		if code := v.result.exitCode(); code != v.expect {
+			t.Errorf("exitCode(%+v) = %d, expect: %d", v.result, code, v.expect)
+		}
+	}
+}
+
+func TestWriteOutputs(t *testing.T) {
+	if _, err := parseOutputSpec("yaml:run.yaml"); err == nil {
+		t.Errorf("parseOutputSpec(yaml) expect err")
+	}
+	if _, err := parseOutputSpec("json:"); err == nil {
+		t.Errorf("parseOutputSpec(json:) expect err")
+	}
+
+	dir := t.TempDir()
+	var specs []outputSpec
+	for _, v := range []string{"json:" + filepath.Join(dir, "run.json"), "csv:" + filepath.Join(dir, "lat.csv"),
+		"html:" + filepath.Join(dir, "report.html"), "summary"} {
+		spec, err := parseOutputSpec(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		specs = append(specs, spec)
+	}
+	if output := stdoutOutput(specs); output != "" {
+		t.Errorf("stdoutOutput = %q, expect summary", output)
+	}
+
+	result := GetStressResult()
+	result.LatsTotal, result.Duration = 10, 1
+	result.Lats = map[string]int64{"0.010": 10}
+	result.StatusCodeDist = map[int]int{200: 10}
+	result.TimeSeries = map[int64]*StressPoint{100: {LatsTotal: 4}, 101: {LatsTotal: 6}}
+	result.writeOutputs(specs)
+
+	var decoded StressResult
+	content, _ := os.ReadFile(filepath.Join(dir, "run.json"))
+	if err := json.Unmarshal(content, &decoded); err != nil || decoded.LatsTotal != 10 {
+		t.Errorf("json output = %s, %v", content, err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "lat.csv")); string(content) != "Duration,Count\n0.010,10\n" {
+		t.Errorf("csv output = %q", content)
+	}
+	content, _ = os.ReadFile(filepath.Join(dir, "report.html"))
+	for _, expect := range []string{"<td>200</td><td>10</td>", "<td>1</td><td>6</td>", "50%</td><td>0.010 secs"} {
+		if !strings.Contains(string(content), expect) {
+			t.Errorf("html output expect %q, got: %s", expect, content)
+		}
+	}
+}
+
+func TestPrintLatenciesOverTime(t *testing.T) {
+	stats := GetStressResult()
+	start := time.Unix(1700000000, 0)
+	for _, res := range []*result{
+		{statusCode: 200, start: start, duration: 500 * time.Microsecond},
+		{statusCode: 200, start: start.Add(300 * time.Millisecond), duration: 3 * time.Millisecond},
+		{start: start.Add(600 * time.Millisecond), duration: time.Second, err: errors.New("timeout")},
+		{statusCode: 200, start: start.Add(2 * time.Second), duration: 100 * time.Millisecond},
+		{statusCode: 200, start: start.Add(2 * time.Second), duration: 10 * time.Second},
+	} {
+		stats.append(res)
+	}
+
+	// a row per second with samples, seconds from the first, and the errors in the last column
+	var buf bytes.Buffer
+	stats.write(&buf, outputLatsOverTime)
+	expect := "Second,<=0.001,<=0.002,<=0.005,<=0.010,<=0.020,<=0.050,<=0.100,<=0.200,<=0.500,<=1.000,<=2.000,<=5.000,>5.000,Errors\n" +
+		"0,1,0,1,0,0,0,0,0,0,0,0,0,0,1\n" +
+		"2,0,0,0,0,0,0,1,0,0,0,0,0,1,0\n"
+	if buf.String() != expect {
+		t.Errorf("latencies over time:\n%s\nexpect:\n%s", buf.String(), expect)
+	}
+}
+
+func TestMergePoints(t *testing.T) {
+	dst := map[int]*StressPoint{1: {LatsTotal: 1, Lats: map[string]int64{"0.01": 1}}}
+	mergePoints(dst, map[int]*StressPoint{
+		1: {LatsTotal: 2, ErrTotal: 1, Lats: map[string]int64{"0.01": 1, "0.02": 1}},
+		2: {LatsTotal: 1, Lats: map[string]int64{"0.03": 1}},
+	})
+	if p := dst[1]; p.LatsTotal != 3 || p.ErrTotal != 1 || p.Lats["0.01"] != 2 || p.Lats["0.02"] != 1 {
+		t.Errorf("merged point = %+v", p)
+	}
+	if p := dst[2]; p == nil || p.LatsTotal != 1 || p.Lats["0.03"] != 1 {
+		t.Errorf("new point = %+v", p)
+	}
+}
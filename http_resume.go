@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	resumeResumed    = "resumed"    // 206 with the remainder from the offset
+	resumeIgnored    = "ignored"    // Range ignored, the full content sent again with 200
+	resumeMismatched = "mismatched" // 206 with wrong Content-Range or length of the remainder
+	resumeShort      = "short"      // content not longer than the offset, nothing to resume
+)
+
+// StressResume downloads of -resume, each download is aborted after the offset and resumed with Range
+type StressResume struct {
+	Offset     int64            `json:"offset"`      // bytes read before the download is aborted
+	Resumed    int64            `json:"resumed"`     // resumed with 206 and the expected remainder
+	Ignored    int64            `json:"ignored"`     // Range ignored by server
+	Mismatched int64            `json:"mismatched"`  // wrong Content-Range or length of the remainder
+	Short      int64            `json:"short"`       // content not longer than the offset
+	ResumeLats map[string]int64 `json:"resume_lats"` // time to the response of resumed requests
+}
+
+// resumeTrace interrupted download of -resume
+type resumeTrace struct {
+	offset int64
+	total  int64 // content length of the first response, -1 is unknown
+	read   int64 // bytes read before aborted
+	wait   time.Duration
+}
+
+// resume read the first bytes of the download and abort it, then request the rest with Range from
+// the offset, If-Range makes the server send the full content again if it changed in between
+func (trace *resumeTrace) resume(c *http.Client, req *http.Request, resp *http.Response, res *result) (*http.Response, error) {
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil // counted by status code
+	}
+	trace.total = resp.ContentLength
+	trace.read, _ = io.CopyN(io.Discard, resp.Body, trace.offset)
+	resp.Body.Close() // abort the download
+	if trace.read < trace.offset {
+		res.statusCode, res.resume = resp.StatusCode, resumeShort
+		res.contentLength, res.wireLength = trace.read, trace.read
+		return nil, fmt.Errorf("resume offset %d beyond content length %d", trace.offset, trace.read)
+	}
+
+	next := req.Clone(req.Context())
+	if next.Header == nil {
+		next.Header = make(http.Header)
+	}
+	next.Header.Set("Range", fmt.Sprintf("bytes=%d-", trace.offset))
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		next.Header.Set("If-Range", etag)
+	} else if modified := resp.Header.Get("Last-Modified"); modified != "" {
+		next.Header.Set("If-Range", modified)
+	}
+	sent := time.Now()
+	resumed, err := c.Do(next)
+	if err != nil {
+		res.statusCode = -99 // has errors
+		return nil, err
+	}
+	trace.wait = time.Since(sent)
+	return resumed, nil
+}
+
+// classify the resumed response after the body is read, the sizes of result include the bytes read
+// before the abort
+func (trace *resumeTrace) classify(res *result, resp *http.Response) {
+	if trace.wait == 0 {
+		return // not resumed
+	}
+	res.resumeWait = trace.wait
+	res.contentLength += trace.read
+	res.wireLength += trace.read
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if err := trace.verify(resp.Header.Get("Content-Range"), res.contentLength-trace.read, res.truncated); err != nil {
+			res.resume, res.err = resumeMismatched, err
+			return
+		}
+		res.resume = resumeResumed
+	case http.StatusOK:
+		res.resume, res.err = resumeIgnored, fmt.Errorf("resume ignored: status 200 without Range")
+	}
+}
+
+// verify Content-Range "bytes first-last/total" of the remainder starts at the offset, matches the
+// content length of the first response and the length of the body read
+func (trace *resumeTrace) verify(contentRange string, length int64, truncated bool) error {
+	var first, last, total int64 = -1, -1, -1
+	spec := strings.TrimPrefix(contentRange, "bytes ")
+	if i := strings.IndexByte(spec, '/'); i > 0 {
+		if spec[i+1:] != "*" {
+			total, _ = strconv.ParseInt(spec[i+1:], 10, 64)
+		}
+		if j := strings.IndexByte(spec[:i], '-'); j > 0 {
+			first, _ = strconv.ParseInt(spec[:j], 10, 64)
+			last, _ = strconv.ParseInt(spec[j+1:i], 10, 64)
+		}
+	}
+	switch {
+	case first < 0 || last < first:
+		return fmt.Errorf("resume mismatch: invalid Content-Range %q", contentRange)
+	case first != trace.offset:
+		return fmt.Errorf("resume mismatch: Content-Range starts at %d, expected %d", first, trace.offset)
+	case trace.total >= 0 && total >= 0 && total != trace.total:
+		return fmt.Errorf("resume mismatch: total %d, expected %d", total, trace.total)
+	case !truncated && length != last-first+1:
+		return fmt.Errorf("resume mismatch: %d bytes of Content-Range %q", length, contentRange)
+	}
+	return nil
+}
+
+func (r *StressResume) append(res *result, digits int) {
+	switch res.resume {
+	case resumeResumed:
+		r.Resumed++
+		r.ResumeLats[latsKey(res.resumeWait, digits)]++
+	case resumeIgnored:
+		r.Ignored++
+	case resumeMismatched:
+		r.Mismatched++
+	case resumeShort:
+		r.Short++
+	}
+}
+
+func (r *StressResume) merge(v *StressResume) {
+	if r.ResumeLats == nil {
+		r.ResumeLats = make(map[string]int64)
+	}
+	r.Offset = v.Offset
+	r.Resumed += v.Resumed
+	r.Ignored += v.Ignored
+	r.Mismatched += v.Mismatched
+	r.Short += v.Short
+	for lats, c := range v.ResumeLats {
+		r.ResumeLats[lats] += c
+	}
+}
+
+// printResume Print outcomes of resumed downloads and the latency distribution of resume
+func (result *StressResult) printResume(w io.Writer) {
+	r := result.Resume
+	fprintln(w, "\nResume (offset %s):", toByteSizeStr(float64(r.Offset)))
+	fprintln(w, "  Resumed:\t%d with 206", r.Resumed)
+	if r.Ignored > 0 {
+		fprintln(w, "  Ignored:\t%d, Range ignored with 200", r.Ignored)
+	}
+	if r.Mismatched > 0 {
+		fprintln(w, "  Mismatched:\t%d, wrong Content-Range or length", r.Mismatched)
+	}
+	if r.Short > 0 {
+		fprintln(w, "  Short:\t%d, content not longer than the offset", r.Short)
+	}
+	if r.Resumed > 0 {
+		data := latsPercentiles(r.ResumeLats, r.Resumed, pctls)
+		fprintln(w, "  Resume latency:")
+		for i := 0; i < len(pctls); i++ {
+			fprintln(w, "  %v%% in %4.3f secs", pctls[i], data[i])
+		}
+	}
+}
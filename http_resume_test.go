@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 4096) // 64KB
+	var ranges int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt64(&ranges, 1)
+		}
+		switch r.URL.Path {
+		case "/ignore":
+			w.Write(content) // Range not supported
+		case "/small":
+			w.Write(content[:100])
+		default:
+			w.Header().Set("ETag", `"v1"`)
+			http.ServeContent(w, r, "media.bin", time.Time{}, bytes.NewReader(content))
+		}
+	}))
+	defer srv.Close()
+
+	stress := func(path string) *StressResult {
+		_, result := executeStress(StressParameters{
+			SequenceId:         time.Now().UnixNano(),
+			Cmd:                cmdStart,
+			RequestType:        typeHttp1,
+			RequestMethod:      "GET",
+			Url:                srv.URL + path,
+			C:                  1,
+			N:                  4,
+			Timeout:            3000,
+			DisableCompression: true,
+			Resume:             16 << 10,
+		})
+		if result == nil || result.Resume == nil {
+			t.Fatalf("result of resume %s = %+v", path, result)
+		}
+		return result
+	}
+
+	result := stress("/media")
+	r := result.Resume
+	if r.Offset != 16<<10 || r.Resumed < 4 || r.Ignored+r.Mismatched+r.Short != 0 || len(result.ErrorDist) > 0 ||
+		int64(result.StatusCodeDist[http.StatusPartialContent]) != r.Resumed || result.SizeTotal != r.Resumed*int64(len(content)) {
+		t.Errorf("resume = %+v, result: %+v", r, result)
+	}
+	if n := atomic.LoadInt64(&ranges); n != r.Resumed {
+		t.Errorf("requests with Range = %d, expect: %d", n, r.Resumed)
+	}
+
+	if r := stress("/ignore").Resume; r.Ignored < 4 || r.Resumed != 0 {
+		t.Errorf("resume of Range ignored = %+v", r)
+	}
+	result = stress("/small")
+	if r := result.Resume; r.Short < 4 || result.ErrorDist["resume offset 16384 beyond content length 100"] != int(r.Short) {
+		t.Errorf("resume of short content = %+v, errors: %v", r, result.ErrorDist)
+	}
+
+	trace := &resumeTrace{offset: 100, total: 1000}
+	for contentRange, expect := range map[string]string{
+		"bytes 100-999/1000": "",
+		"bytes 100-999/*":    "",
+		"bytes 0-999/1000":   "starts at 0, expected 100",
+		"bytes 100-999/2000": "total 2000, expected 1000",
+		"bytes 100-499/1000": "900 bytes of Content-Range",
+		"100-999":            "invalid Content-Range",
+	} {
+		err := trace.verify(contentRange, 900, false)
+		if (expect == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), expect)) {
+			t.Errorf("verify(%s) = %v, expect: %s", contentRange, err, expect)
+		}
+	}
+
+	var buf bytes.Buffer
+	result.Resume = &StressResume{Offset: 2 << 20, Resumed: 1, Ignored: 2, ResumeLats: map[string]int64{latsKey(time.Millisecond, 3): 1}}
+	result.printResume(&buf)
+	for _, line := range []string{
+		"Resume (offset 2.000 MB):",
+		"  Resumed:\t1 with 206",
+		"  Ignored:\t2, Range ignored with 200",
+		"  Resume latency:",
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("printResume expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io"
+	"net/http/httptrace"
+	"sync/atomic"
+)
+
+const (
+	connNone   = -1 // the request failed before getting a connection
+	connNew    = 1  // the request opened a new connection
+	connReused = 2  // the request reused a connection of earlier requests
+)
+
+// StressConnReuse requests on new and reused connections of http1 and http2, to validate keep-alive
+type StressConnReuse struct {
+	New    int64 `json:"new"`     // requests on a new connection, the connections opened
+	Reused int64 `json:"reused"`  // requests on a connection of earlier requests
+	Idle   int64 `json:"idle"`    // reused connections which were idle in the pool
+	NoConn int64 `json:"no_conn"` // requests failed before getting a connection, e.g. dial timeout
+}
+
+// gotConn record whether the connection of the request is new or reused
+func (trace *timingTrace) gotConn(info httptrace.GotConnInfo) {
+	state := int32(connNew)
+	if info.Reused {
+		state = connReused
+	}
+	if info.WasIdle {
+		atomic.StoreInt32(&trace.idle, 1)
+	}
+	atomic.StoreInt32(&trace.conn, state)
+}
+
+// recordConn record the connection of the request into the result, also of failed requests
+func (trace *timingTrace) recordConn(res *result) {
+	res.conn = int8(atomic.LoadInt32(&trace.conn))
+	if res.conn == 0 {
+		res.conn = connNone
+	}
+	res.connIdle = atomic.LoadInt32(&trace.idle) == 1
+}
+
+func (r *StressConnReuse) append(res *result) {
+	switch res.conn {
+	case connNew:
+		r.New++
+	case connReused:
+		r.Reused++
+		if res.connIdle {
+			r.Idle++
+		}
+	case connNone:
+		r.NoConn++
+	}
+}
+
+func (r *StressConnReuse) merge(v *StressConnReuse) {
+	r.New += v.New
+	r.Reused += v.Reused
+	r.Idle += v.Idle
+	r.NoConn += v.NoConn
+}
+
+// printConnReuse Print requests on new and reused connections, many new connections are churn
+// of keep-alive, e.g. closed by the server or -disable-keepalive
+func (result *StressResult) printConnReuse(w io.Writer) {
+	r := result.ConnReuse
+	total := r.New + r.Reused + r.NoConn
+	if total <= 0 {
+		return
+	}
+	fprintln(w, "\nConnection reuse:")
+	fprintln(w, "  New:\t%d requests (%4.2f%%), %d connections opened", r.New, float64(r.New)*100/float64(total), r.New)
+	fprintln(w, "  Reused:\t%d requests (%4.2f%%), %d of idle connections", r.Reused, float64(r.Reused)*100/float64(total), r.Idle)
+	if r.NoConn > 0 {
+		fprintln(w, "  No connection:\t%d requests (%4.2f%%) failed to dial or wait for a connection", r.NoConn,
+			float64(r.NoConn)*100/float64(total))
+	}
+	if r.New > 0 {
+		fprintln(w, "  Requests/conn:\t%4.2f", float64(r.New+r.Reused)/float64(r.New))
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStressConnReuse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("close") != "" {
+			w.Header().Set("Connection", "close")
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	// a listener closed right away refuses the dials
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	refused := "http://" + ln.Addr().String()
+	ln.Close()
+
+	for _, v := range []struct {
+		url                  string
+		expectNew, expectOld bool
+		expectNoConn         bool
+	}{
+		{url: srv.URL, expectNew: true, expectOld: true},
+		{url: srv.URL + "/?close=1", expectNew: true},
+		{url: refused, expectNoConn: true},
+	} {
+		_, result := executeStress(StressParameters{
+			SequenceId:    time.Now().UnixNano(),
+			Cmd:           cmdStart,
+			RequestType:   typeHttp1,
+			RequestMethod: "GET",
+			Url:           v.url,
+			C:             2,
+			N:             10,
+			Timeout:       3000,
+		})
+		if result == nil || result.ConnReuse == nil {
+			t.Fatalf("result of %s = %+v", v.url, result)
+		}
+		r := result.ConnReuse
+		if (r.New > 0) != v.expectNew || (r.Reused > 0) != v.expectOld || (r.NoConn > 0) != v.expectNoConn ||
+			r.New+r.Reused != result.LatsTotal {
+			t.Errorf("conn reuse of %s = %+v, responses: %d", v.url, r, result.LatsTotal)
+		}
+		if v.expectOld && (r.New > 2 || r.Idle != r.Reused) {
+			t.Errorf("conn reuse of keep-alive = %+v, expect at most 2 connections of -c", r)
+		}
+	}
+
+	result := &StressResult{ConnReuse: &StressConnReuse{New: 2, Reused: 18, Idle: 18, NoConn: 1}}
+	var buf bytes.Buffer
+	result.printConnReuse(&buf)
+	for _, line := range []string{"Connection reuse:", "  New:\t2 requests (9.52%), 2 connections opened",
+		"  Reused:\t18 requests (85.71%), 18 of idle connections", "  No connection:\t1 requests", "  Requests/conn:\t10.00"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printConnReuse expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const schemaExampleBody = 512 // max body bytes kept in an example of violation
+
+// StressSchema responses validated by -assert-json-schema, violations are content errors
+type StressSchema struct {
+	Sample   float64               `json:"sample"`   // fraction of 2xx responses validated
+	Checked  int64                 `json:"checked"`  // responses validated
+	Violated int64                 `json:"violated"` // responses violated the schema
+	Examples []StressSchemaExample `json:"examples"` // first violations of each worker
+}
+
+// StressSchemaExample violation of -assert-json-schema
+type StressSchemaExample struct {
+	Status  int    `json:"status"`
+	Path    string `json:"path"` // json path of the violated value, e.g. $.data.items[0].id
+	Message string `json:"message"`
+	Body    string `json:"body"` // body truncated to schemaExampleBody bytes
+}
+
+// jsonSchema subset of JSON Schema: type, enum, const, properties, required, additionalProperties,
+// items, min/maxItems, minimum, maximum, exclusiveMinimum, exclusiveMaximum, min/maxLength, pattern,
+// allOf, anyOf, oneOf, not and local $ref, the other keywords, e.g. format, are ignored
+type jsonSchema struct {
+	root     interface{}
+	patterns map[string]*regexp.Regexp
+}
+
+// schemaChecker validate sampled responses of worker
+type schemaChecker struct {
+	schema   *jsonSchema
+	sample   float64
+	examples int64 // max examples kept by the worker
+	seen     int64 // 2xx responses
+	violated int64
+}
+
+// parseJsonSchema parse schema document, and check patterns and references
+func parseJsonSchema(content []byte) (*jsonSchema, error) {
+	s := &jsonSchema{patterns: make(map[string]*regexp.Regexp)}
+	if err := json.Unmarshal(content, &s.root); err != nil {
+		return nil, fmt.Errorf("invalid json schema: %v", err)
+	}
+	if err := s.compile(s.root, "#"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *jsonSchema) compile(node interface{}, at string) error {
+	switch n := node.(type) {
+	case bool:
+		return nil
+	case map[string]interface{}:
+		if ref, ok := n["$ref"].(string); ok {
+			if _, err := s.resolve(ref); err != nil {
+				return fmt.Errorf("invalid json schema at %s: %v", at, err)
+			}
+		}
+		if pattern, ok := n["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid json schema at %s: %v", at, err)
+			}
+			s.patterns[pattern] = re
+		}
+		keys := make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			switch k {
+			case "properties", "definitions", "$defs":
+				children, _ := n[k].(map[string]interface{})
+				for name, child := range children {
+					if err := s.compile(child, at+"/"+k+"/"+name); err != nil {
+						return err
+					}
+				}
+			case "items", "additionalProperties", "not":
+				if err := s.compile(n[k], at+"/"+k); err != nil {
+					return err
+				}
+			case "allOf", "anyOf", "oneOf":
+				children, _ := n[k].([]interface{})
+				for i, child := range children {
+					if err := s.compile(child, fmt.Sprintf("%s/%s/%d", at, k, i)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	case nil:
+		return nil
+	}
+	return fmt.Errorf("invalid json schema at %s: expect an object or boolean", at)
+}
+
+// resolve local reference of json pointer, e.g. #/definitions/item or #/$defs/item
+func (s *jsonSchema) resolve(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("only local $ref is supported, got: %s", ref)
+	}
+	node := s.root
+	for _, token := range strings.Split(strings.TrimPrefix(ref[1:], "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolved $ref %s", ref)
+		}
+		if node, ok = m[token]; !ok {
+			return nil, fmt.Errorf("unresolved $ref %s", ref)
+		}
+	}
+	return node, nil
+}
+
+// validate value against the schema, return the path and reason of the first violation
+func (s *jsonSchema) validate(v interface{}) (string, string, bool) {
+	return s.check(s.root, v, "$", 0)
+}
+
+func (s *jsonSchema) check(node, v interface{}, path string, depth int) (string, string, bool) {
+	if depth > 64 {
+		return path, "schema references nested too deep", false
+	}
+	n, ok := node.(map[string]interface{})
+	if !ok {
+		if b, isBool := node.(bool); isBool && !b {
+			return path, "not allowed", false
+		}
+		return "", "", true
+	}
+
+	if ref, ok := n["$ref"].(string); ok {
+		target, _ := s.resolve(ref)
+		if p, msg, ok := s.check(target, v, path, depth+1); !ok {
+			return p, msg, false
+		}
+	}
+	if t, ok := n["type"]; ok && !matchType(t, v) {
+		return path, fmt.Sprintf("expected %s, got %s", typeNames(t), jsonType(v)), false
+	}
+	if enum, ok := n["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if found = reflect.DeepEqual(e, v); found {
+				break
+			}
+		}
+		if !found {
+			return path, fmt.Sprintf("%s is not one of enum", jsonText(v)), false
+		}
+	}
+	if c, ok := n["const"]; ok && !reflect.DeepEqual(c, v) {
+		return path, fmt.Sprintf("expected const %s, got %s", jsonText(c), jsonText(v)), false
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if required, ok := n["required"].([]interface{}); ok {
+			for _, r := range required {
+				if name, _ := r.(string); name != "" {
+					if _, ok := value[name]; !ok {
+						return path, "missing required property " + name, false
+					}
+				}
+			}
+		}
+		props, _ := n["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := path + "." + k
+			if prop, ok := props[k]; ok {
+				if p, msg, ok := s.check(prop, value[k], child, depth+1); !ok {
+					return p, msg, false
+				}
+			} else if additional, ok := n["additionalProperties"]; ok {
+				if b, isBool := additional.(bool); isBool && !b {
+					return child, "additional property not allowed", false
+				}
+				if p, msg, ok := s.check(additional, value[k], child, depth+1); !ok {
+					return p, msg, false
+				}
+			}
+		}
+	case []interface{}:
+		if min, ok := n["minItems"].(float64); ok && float64(len(value)) < min {
+			return path, fmt.Sprintf("%d items, expected at least %v", len(value), min), false
+		}
+		if max, ok := n["maxItems"].(float64); ok && float64(len(value)) > max {
+			return path, fmt.Sprintf("%d items, expected at most %v", len(value), max), false
+		}
+		if items, ok := n["items"]; ok {
+			for i, item := range value {
+				if p, msg, ok := s.check(items, item, fmt.Sprintf("%s[%d]", path, i), depth+1); !ok {
+					return p, msg, false
+				}
+			}
+		}
+	case string:
+		length := float64(len([]rune(value)))
+		if min, ok := n["minLength"].(float64); ok && length < min {
+			return path, fmt.Sprintf("length %v, expected at least %v", length, min), false
+		}
+		if max, ok := n["maxLength"].(float64); ok && length > max {
+			return path, fmt.Sprintf("length %v, expected at most %v", length, max), false
+		}
+		if pattern, ok := n["pattern"].(string); ok && !s.patterns[pattern].MatchString(value) {
+			return path, fmt.Sprintf("%s does not match pattern %s", strconv.Quote(value), pattern), false
+		}
+	case float64:
+		if min, ok := n["minimum"].(float64); ok && value < min {
+			return path, fmt.Sprintf("%v is less than minimum %v", value, min), false
+		}
+		if max, ok := n["maximum"].(float64); ok && value > max {
+			return path, fmt.Sprintf("%v is greater than maximum %v", value, max), false
+		}
+		if min, ok := n["exclusiveMinimum"].(float64); ok && value <= min {
+			return path, fmt.Sprintf("%v is not greater than exclusiveMinimum %v", value, min), false
+		}
+		if max, ok := n["exclusiveMaximum"].(float64); ok && value >= max {
+			return path, fmt.Sprintf("%v is not less than exclusiveMaximum %v", value, max), false
+		}
+	}
+
+	if all, ok := n["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			if p, msg, ok := s.check(sub, v, path, depth+1); !ok {
+				return p, msg, false
+			}
+		}
+	}
+	if anyOf, ok := n["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if _, _, matched = s.check(sub, v, path, depth+1); matched {
+				break
+			}
+		}
+		if !matched {
+			return path, "matches none of anyOf", false
+		}
+	}
+	if oneOf, ok := n["oneOf"].([]interface{}); ok {
+		matched := 0
+		for _, sub := range oneOf {
+			if _, _, ok := s.check(sub, v, path, depth+1); ok {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return path, fmt.Sprintf("matches %d of oneOf, expected exactly 1", matched), false
+		}
+	}
+	if not, ok := n["not"]; ok {
+		if _, _, ok := s.check(not, v, path, depth+1); ok {
+			return path, "matches the schema of not", false
+		}
+	}
+	return "", "", true
+}
+
+// matchType match type of schema, a name or a list of names
+func matchType(t, v interface{}) bool {
+	if names, ok := t.([]interface{}); ok {
+		for _, name := range names {
+			if matchType(name, v) {
+				return true
+			}
+		}
+		return false
+	}
+	name, _ := t.(string)
+	switch actual := jsonType(v); {
+	case name == actual:
+		return true
+	case name == "number" && actual == "integer":
+		return true
+	}
+	return false
+}
+
+func typeNames(t interface{}) string {
+	if names, ok := t.([]interface{}); ok {
+		var s []string
+		for _, name := range names {
+			s = append(s, fmt.Sprint(name))
+		}
+		return strings.Join(s, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+// jsonType type name of decoded json value, integer for numbers without fraction
+func jsonType(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func jsonText(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// check validate the 2xx response when sampled, the example is kept for the first violations of worker
+func (c *schemaChecker) check(r *assertResponse) (bool, *StressSchemaExample) {
+	if r.status/100 != 2 {
+		return false, nil
+	}
+	if n := atomic.AddInt64(&c.seen, 1); c.sample < 1 && int64(float64(n)*c.sample) == int64(float64(n-1)*c.sample) {
+		return false, nil
+	}
+
+	var path, msg string
+	var ok bool
+	if !r.jsonDone {
+		r.jsonDone, r.jsonErr = true, json.Unmarshal(r.body, &r.json)
+	}
+	if r.jsonErr != nil {
+		path, msg = "$", "invalid json: "+r.jsonErr.Error()
+	} else if path, msg, ok = c.schema.validate(r.json); ok {
+		return true, nil
+	}
+
+	example := &StressSchemaExample{Status: r.status, Path: path, Message: msg}
+	if atomic.AddInt64(&c.violated, 1) > c.examples {
+		return true, example // counted without the body
+	}
+	body := r.body
+	if len(body) > schemaExampleBody {
+		body = body[:schemaExampleBody]
+	}
+	example.Body = string(body)
+	return true, example
+}
+
+func (schema *StressSchema) append(res *result) {
+	schema.Checked++
+	if res.schemaExample != nil {
+		schema.Violated++
+		if res.schemaExample.Body != "" {
+			schema.Examples = append(schema.Examples, *res.schemaExample)
+		}
+	}
+}
+
+func (schema *StressSchema) merge(v *StressSchema) {
+	schema.Sample = v.Sample
+	schema.Checked += v.Checked
+	schema.Violated += v.Violated
+	schema.Examples = append(schema.Examples, v.Examples...)
+}
+
+// printSchema Print responses validated by -assert-json-schema and the examples of violations
+func (result *StressResult) printSchema(w io.Writer) {
+	s := result.Schema
+	fprintln(w, "\nJSON schema:")
+	fprintln(w, "  Checked:\t%d responses (%.2f%% of 2xx sampled)", s.Checked, s.Sample*100)
+	if s.Checked > 0 {
+		fprintln(w, "  Violated:\t%d (%.2f%%)", s.Violated, float64(s.Violated)*100/float64(s.Checked))
+	}
+	for i, e := range s.Examples {
+		fprintln(w, "  [%d] status %d, %s: %s", i+1, e.Status, e.Path, e.Message)
+		fprintln(w, "      %s", strings.Join(strings.Fields(e.Body), " "))
+	}
+}
+
+// newSchemaChecker checker of -assert-json-schema, nil if not enabled
+func newSchemaChecker(p *StressParameters) (*schemaChecker, error) {
+	if p.JsonSchema == "" {
+		return nil, nil
+	}
+	schema, err := parseJsonSchema([]byte(p.JsonSchema))
+	if err != nil {
+		return nil, err
+	}
+	c := &schemaChecker{schema: schema, sample: p.JsonSchemaSample, examples: int64(p.JsonSchemaExamples)}
+	if c.sample <= 0 || c.sample > 1 {
+		c.sample = 1 // all responses
+	}
+	return c, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testUserSchema = `{
+	"type": "object",
+	"required": ["id", "name"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"name": {"type": "string", "minLength": 1, "pattern": "^[a-z]+$"},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "maxItems": 2, "items": {"$ref": "#/$defs/tag"}},
+		"score": {"type": ["number", "null"], "exclusiveMaximum": 100}
+	},
+	"$defs": {"tag": {"type": "string", "maxLength": 3}}
+}`
+
+func TestJsonSchema(t *testing.T) {
+	schema, err := parseJsonSchema([]byte(testUserSchema))
+	if err != nil {
+		t.Fatalf("parseJsonSchema err: %v", err)
+	}
+	for _, v := range []struct {
+		body, path, msg string
+	}{
+		{body: `{"id": 1, "name": "bob", "role": "admin", "tags": ["a", "b"], "score": 9.5}`},
+		{body: `{"id": 2, "name": "amy", "score": null}`},
+		{body: `{"id": "1", "name": "bob"}`, path: "$.id", msg: "expected integer, got string"},
+		{body: `{"id": 1.5, "name": "bob"}`, path: "$.id", msg: "expected integer, got number"},
+		{body: `{"id": 0, "name": "bob"}`, path: "$.id", msg: "0 is less than minimum 1"},
+		{body: `{"name": "bob"}`, path: "$", msg: "missing required property id"},
+		{body: `{"id": 1, "name": "Bob"}`, path: "$.name", msg: `"Bob" does not match pattern ^[a-z]+$`},
+		{body: `{"id": 1, "name": "bob", "role": "root"}`, path: "$.role", msg: `"root" is not one of enum`},
+		{body: `{"id": 1, "name": "bob", "tags": ["long"]}`, path: "$.tags[0]", msg: "length 4, expected at most 3"},
+		{body: `{"id": 1, "name": "bob", "tags": ["a", "b", "c"]}`, path: "$.tags", msg: "3 items, expected at most 2"},
+		{body: `{"id": 1, "name": "bob", "score": 100}`, path: "$.score", msg: "100 is not less than exclusiveMaximum 100"},
+		{body: `{"id": 1, "name": "bob", "extra": true}`, path: "$.extra", msg: "additional property not allowed"},
+		{body: `[]`, path: "$", msg: "expected object, got array"},
+	} {
+		var doc interface{}
+		json.Unmarshal([]byte(v.body), &doc)
+		path, msg, ok := schema.validate(doc)
+		if ok != (v.msg == "") || path != v.path || msg != v.msg {
+			t.Errorf("validate(%s) = %q, %q, %v, expect: %q, %q", v.body, path, msg, ok, v.path, v.msg)
+		}
+	}
+
+	for content, expect := range map[string]string{
+		`{"type": "object"`:                      "invalid json schema",
+		`{"properties": {"a": {"$ref": "#/x"}}}`: "unresolved $ref #/x",
+		`{"$ref": "other.json#/a"}`:              "only local $ref",
+		`{"pattern": "("}`:                       "missing closing )",
+		`{"anyOf": [{"type": "string"}, 1]}`:     "expect an object or boolean",
+	} {
+		if _, err := parseJsonSchema([]byte(content)); err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("parseJsonSchema(%s) err = %v, expect: %s", content, err, expect)
+		}
+	}
+}
+
+func TestAssertJsonSchema(t *testing.T) {
+	var requests int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt64(&requests, 1) % 4 {
+		case 0:
+			w.Write([]byte(`{"id": "7", "name": "bob"}`)) // id serialized as string
+		case 1:
+			w.WriteHeader(http.StatusNotFound) // not validated
+		default:
+			w.Write([]byte(`{"id": 7, "name": "bob"}`))
+		}
+	}))
+	defer srv.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:         time.Now().UnixNano(),
+		Cmd:                cmdStart,
+		RequestType:        typeHttp1,
+		RequestMethod:      "GET",
+		Url:                srv.URL,
+		C:                  1,
+		N:                  8,
+		Timeout:            3000,
+		JsonSchema:         testUserSchema,
+		JsonSchemaExamples: 1,
+	})
+	if result == nil || result.Schema == nil {
+		t.Fatalf("result of json schema = %+v", result)
+	}
+	s := result.Schema
+	if s.Sample != 1 || s.Checked < 6 || s.Violated < 2 || len(s.Examples) != 1 || s.Examples[0].Path != "$.id" ||
+		s.Examples[0].Body != `{"id": "7", "name": "bob"}` || result.ErrorDist["json schema violation"] != int(s.Violated) {
+		t.Errorf("json schema = %+v, errors: %v", s, result.ErrorDist)
+	}
+	if result.exitCode() != exitAssertion {
+		t.Errorf("exit code = %d, expect: %d", result.exitCode(), exitAssertion)
+	}
+
+	var buf bytes.Buffer
+	result.printSchema(&buf)
+	for _, line := range []string{
+		fmt.Sprintf("  Checked:\t%d responses (100.00%% of 2xx sampled)", s.Checked),
+		"  [1] status 200, $.id: expected integer, got string",
+		`      {"id": "7", "name": "bob"}`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("printSchema expect %q, got:\n%s", line, buf.String())
+		}
+	}
+
+	c := &schemaChecker{schema: &jsonSchema{root: true}, sample: 0.25}
+	var checked int
+	for i := 0; i < 100; i++ {
+		if ok, _ := c.check(&assertResponse{status: 200, body: []byte(`{}`)}); ok {
+			checked++
+		}
+	}
+	if checked != 25 {
+		t.Errorf("sampled %d of 100 responses, expect: 25", checked)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const scrapeMaxBody = 16 << 20 // metrics of a node exporter are usually less than 1MB
+
+// StressTargetMetrics resource usage of the target scraped from its Prometheus endpoint of -target-metrics-url
+// while running, so that the latency is read alongside the cpu, memory and network of the server
+type StressTargetMetrics struct {
+	Url       string              `json:"url"`
+	Interval  int64               `json:"interval"` // ms between scrapes
+	Scrapes   int64               `json:"scrapes"`  // successful scrapes
+	Errors    int64               `json:"errors"`   // failed scrapes
+	LastError string              `json:"last_error"`
+	Points    []StressTargetPoint `json:"points"` // usage between two scrapes
+}
+
+// StressTargetPoint usage of the target between two scrapes, -1 if the metrics are not exported
+type StressTargetPoint struct {
+	Time     int64   `json:"time"`       // unix ms of the scrape
+	Cpu      float64 `json:"cpu"`        // busy percent of all cpus of node_cpu_seconds_total
+	Cores    float64 `json:"cores"`      // cpu cores used by the process of process_cpu_seconds_total
+	Memory   float64 `json:"memory"`     // bytes used of node_memory_MemTotal_bytes minus MemAvailable_bytes
+	Rss      float64 `json:"rss"`        // bytes of process_resident_memory_bytes
+	RxPerSec float64 `json:"rx_per_sec"` // bytes received per second of node_network_receive_bytes_total
+	TxPerSec float64 `json:"tx_per_sec"` // bytes sent per second of node_network_transmit_bytes_total
+}
+
+// scrapeSample counters of one scrape, summed over cpus and network devices except lo
+type scrapeSample struct {
+	time                   time.Time
+	cpuIdle, cpuTotal      float64
+	processCpu             float64
+	memTotal, memAvailable float64
+	rss                    float64
+	rx, tx                 float64
+	has                    map[string]bool // metric names exported
+}
+
+// parseScrape parse the metrics of Prometheus text format used for the usage
+func parseScrape(r io.Reader) (*scrapeSample, error) {
+	s := &scrapeSample{has: make(map[string]bool)}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		name, labels := line, ""
+		if i := strings.IndexAny(line, "{ "); i > 0 && line[i] == '{' {
+			end := strings.LastIndexByte(line, '}')
+			if end < i {
+				return nil, fmt.Errorf("invalid metric line: %s", line)
+			}
+			name, labels, line = line[:i], line[i+1:end], line[end+1:]
+		} else if i > 0 {
+			name, line = line[:i], line[i:]
+		}
+		fields := strings.Fields(line)
+		if len(fields) <= 0 {
+			return nil, fmt.Errorf("invalid metric line: %s", name)
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		switch name {
+		case "node_cpu_seconds_total":
+			s.cpuTotal += value
+			if scrapeLabel(labels, "mode") == "idle" || scrapeLabel(labels, "mode") == "iowait" {
+				s.cpuIdle += value
+			}
+		case "process_cpu_seconds_total":
+			s.processCpu += value
+		case "node_memory_MemTotal_bytes":
+			s.memTotal += value
+		case "node_memory_MemAvailable_bytes":
+			s.memAvailable += value
+		case "process_resident_memory_bytes":
+			s.rss += value
+		case "node_network_receive_bytes_total", "node_network_transmit_bytes_total":
+			if scrapeLabel(labels, "device") == "lo" {
+				continue
+			}
+			if name == "node_network_receive_bytes_total" {
+				s.rx += value
+			} else {
+				s.tx += value
+			}
+		default:
+			continue
+		}
+		s.has[name] = true
+	}
+	return s, scanner.Err()
+}
+
+// scrapeLabel value of the label name of `a="1",b="2"`
+func scrapeLabel(labels, name string) string {
+	for _, pair := range strings.Split(labels, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(k) == name {
+			if s, err := strconv.Unquote(strings.TrimSpace(v)); err == nil {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// usage of the target between the samples prev and cur
+func (cur *scrapeSample) usage(prev *scrapeSample) StressTargetPoint {
+	p := StressTargetPoint{Time: cur.time.UnixMilli(), Cpu: -1, Cores: -1, Memory: -1, Rss: -1, RxPerSec: -1, TxPerSec: -1}
+	secs := cur.time.Sub(prev.time).Seconds()
+	if secs <= 0 {
+		return p
+	}
+	if cur.has["node_cpu_seconds_total"] && cur.cpuTotal > prev.cpuTotal {
+		p.Cpu = 100 * (1 - (cur.cpuIdle-prev.cpuIdle)/(cur.cpuTotal-prev.cpuTotal))
+	}
+	if cur.has["process_cpu_seconds_total"] && prev.has["process_cpu_seconds_total"] {
+		p.Cores = (cur.processCpu - prev.processCpu) / secs
+	}
+	if cur.has["node_memory_MemTotal_bytes"] && cur.has["node_memory_MemAvailable_bytes"] {
+		p.Memory = cur.memTotal - cur.memAvailable
+	}
+	if cur.has["process_resident_memory_bytes"] {
+		p.Rss = cur.rss
+	}
+	if cur.has["node_network_receive_bytes_total"] && prev.has["node_network_receive_bytes_total"] {
+		p.RxPerSec = (cur.rx - prev.rx) / secs
+	}
+	if cur.has["node_network_transmit_bytes_total"] && prev.has["node_network_transmit_bytes_total"] {
+		p.TxPerSec = (cur.tx - prev.tx) / secs
+	}
+	return p
+}
+
+// metricsScraper scrape -target-metrics-url every interval while running, and once more at the end
+type metricsScraper struct {
+	metrics *StressTargetMetrics
+	client  *http.Client
+	prev    *scrapeSample
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newMetricsScraper(url string, interval time.Duration) *metricsScraper {
+	return &metricsScraper{
+		metrics: &StressTargetMetrics{Url: url, Interval: interval.Milliseconds()},
+		client:  &http.Client{Timeout: interval},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (s *metricsScraper) scrape() {
+	sample, err := func() (*scrapeSample, error) {
+		resp, err := s.client.Get(s.metrics.Url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("status %d", resp.StatusCode)
+		}
+		return parseScrape(io.LimitReader(resp.Body, scrapeMaxBody))
+	}()
+	if err != nil {
+		s.metrics.Errors++
+		s.metrics.LastError = err.Error()
+		verbosePrint(vDEBUG, "scrape %s err: %v", s.metrics.Url, err)
+		return
+	}
+	sample.time = time.Now()
+	s.metrics.Scrapes++
+	if s.prev != nil {
+		s.metrics.Points = append(s.metrics.Points, sample.usage(s.prev))
+	}
+	s.prev = sample
+}
+
+// run scrape until finish
+func (s *metricsScraper) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(time.Duration(s.metrics.Interval) * time.Millisecond)
+	defer ticker.Stop()
+
+	s.scrape()
+	for {
+		select {
+		case <-s.stop:
+			s.scrape() // the end of the run
+			return
+		case <-ticker.C:
+			s.scrape()
+		}
+	}
+}
+
+// finish stop scraping and return the usage of the run
+func (s *metricsScraper) finish() *StressTargetMetrics {
+	close(s.stop)
+	<-s.done
+	return s.metrics
+}
+
+// targetSummary average and max of the usage of points, ok is false if not exported
+func targetSummary(points []StressTargetPoint, value func(p StressTargetPoint) float64) (avg, max float64, ok bool) {
+	var n int
+	for _, p := range points {
+		v := value(p)
+		if v < 0 {
+			continue
+		}
+		avg += v
+		if n == 0 || v > max {
+			max = v
+		}
+		n++
+	}
+	if n <= 0 {
+		return 0, 0, false
+	}
+	return avg / float64(n), max, true
+}
+
+// printTargetMetrics Print average and max usage of the target while running
+func (result *StressResult) printTargetMetrics(w io.Writer) {
+	m := result.TargetMetrics
+	fprintln(w, "\nTarget metrics of %s (%d scrapes every %v, %d failed):", m.Url, m.Scrapes,
+		time.Duration(m.Interval)*time.Millisecond, m.Errors)
+	if m.Errors > 0 && m.Scrapes <= 0 {
+		fprintln(w, "  Error:\t%s", m.LastError)
+		return
+	}
+	var printed bool
+	for _, v := range []struct {
+		name  string
+		value func(p StressTargetPoint) float64
+		str   func(v float64) string
+	}{
+		{"CPU", func(p StressTargetPoint) float64 { return p.Cpu }, func(v float64) string { return fmt.Sprintf("%.2f%%", v) }},
+		{"Process CPU", func(p StressTargetPoint) float64 { return p.Cores }, func(v float64) string { return fmt.Sprintf("%.2f cores", v) }},
+		{"Memory", func(p StressTargetPoint) float64 { return p.Memory }, toByteSizeStr},
+		{"Process RSS", func(p StressTargetPoint) float64 { return p.Rss }, toByteSizeStr},
+		{"Network rx", func(p StressTargetPoint) float64 { return p.RxPerSec }, func(v float64) string { return toByteSizeStr(v) + "/s" }},
+		{"Network tx", func(p StressTargetPoint) float64 { return p.TxPerSec }, func(v float64) string { return toByteSizeStr(v) + "/s" }},
+	} {
+		if avg, max, ok := targetSummary(m.Points, v.value); ok {
+			fprintln(w, "  %s:\tavg %s, max %s", v.name, v.str(avg), v.str(max))
+			printed = true
+		}
+	}
+	if !printed {
+		fprintln(w, "  No cpu, memory or network metrics of node exporter or process collector")
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseScrape(t *testing.T) {
+	s, err := parseScrape(strings.NewReader(`# HELP node_cpu_seconds_total Seconds the CPUs spent in each mode.
+# TYPE node_cpu_seconds_total counter
+node_cpu_seconds_total{cpu="0",mode="idle"} 80
+node_cpu_seconds_total{cpu="0",mode="user"} 20
+node_cpu_seconds_total{cpu="1",mode="idle"} 90
+node_cpu_seconds_total{cpu="1",mode="system"} 10
+node_memory_MemTotal_bytes 8e+09
+node_memory_MemAvailable_bytes 6e+09
+node_network_receive_bytes_total{device="eth0"} 1000
+node_network_receive_bytes_total{device="lo"} 99999
+node_network_transmit_bytes_total{device="eth0"} 2000
+process_resident_memory_bytes 1.048576e+06 1700000000000
+go_gc_duration_seconds{quantile="0.5"} NaN
+`))
+	if err != nil {
+		t.Fatalf("parseScrape err: %v", err)
+	}
+	if s.cpuTotal != 200 || s.cpuIdle != 170 || s.memTotal != 8e9 || s.memAvailable != 6e9 || s.rx != 1000 ||
+		s.tx != 2000 || s.rss != 1<<20 || s.has["process_cpu_seconds_total"] {
+		t.Errorf("parseScrape = %+v", s)
+	}
+
+	prev := &scrapeSample{time: time.Unix(100, 0), cpuTotal: 100, cpuIdle: 90, rx: 500, tx: 1000,
+		has: map[string]bool{"node_network_receive_bytes_total": true, "node_network_transmit_bytes_total": true}}
+	s.time = time.Unix(110, 0)
+	p := s.usage(prev)
+	if fmt.Sprintf("%.2f", p.Cpu) != "20.00" || p.Memory != 2e9 || p.Rss != 1<<20 || p.RxPerSec != 50 || p.TxPerSec != 100 || p.Cores != -1 {
+		t.Errorf("usage = %+v", p)
+	}
+}
+
+func TestMetricsScraper(t *testing.T) {
+	var n int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := atomic.AddInt64(&n, 1)
+		fmt.Fprintf(w, "node_cpu_seconds_total{cpu=\"0\",mode=\"idle\"} %d\n", i*50)
+		fmt.Fprintf(w, "node_cpu_seconds_total{cpu=\"0\",mode=\"user\"} %d\n", i*50)
+		fmt.Fprintf(w, "node_network_transmit_bytes_total{device=\"eth0\"} %d\n", i*1000)
+	}))
+	defer srv.Close()
+
+	s := newMetricsScraper(srv.URL, 100*time.Millisecond)
+	go s.run()
+	time.Sleep(350 * time.Millisecond)
+	m := s.finish()
+	if m.Scrapes < 3 || m.Errors > 0 || len(m.Points) != int(m.Scrapes)-1 {
+		t.Fatalf("metrics = %+v", m)
+	}
+	for _, p := range m.Points {
+		if p.Cpu != 50 || p.TxPerSec <= 0 || p.Memory != -1 {
+			t.Errorf("point = %+v", p)
+		}
+	}
+
+	var buf bytes.Buffer
+	(&StressResult{TargetMetrics: m}).printTargetMetrics(&buf)
+	for _, line := range []string{"Target metrics of " + srv.URL, "  CPU:\tavg 50.00%, max 50.00%", "  Network tx:\tavg "} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printTargetMetrics expect %q, got:\n%s", line, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "Memory") {
+		t.Errorf("printTargetMetrics expect no memory, got:\n%s", buf.String())
+	}
+
+	failed := newMetricsScraper("http://127.0.0.1:1/metrics", 100*time.Millisecond)
+	go failed.run()
+	if m := failed.finish(); m.Scrapes > 0 || m.Errors < 1 || m.LastError == "" {
+		t.Errorf("metrics of unreachable target = %+v", m)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const shareRefresh = 2 // secs to refresh the shared view of running stress test
+
+var shareTokens sync.Map // sequence id of read-only share token
+
+// shareRequest body of POST /api/share
+type shareRequest struct {
+	SequenceId int64 `json:"sequence_id"`
+}
+
+// newShareToken random token of read-only view of the stress test
+func newShareToken(seqId int64) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	shareTokens.Store(token, seqId)
+	return token, nil
+}
+
+// sharedResult result of the shared stress test, running ones are the live metrics and refreshed,
+// false if the result is expired or not started
+func sharedResult(seqId int64) (result *StressResult, running, ok bool) {
+	expireCollectResults()
+	if v, ok := collectResults.Load(seqId); ok {
+		return v.(*collectResult).result, false, true
+	}
+	if _, ok := stressList.Load(seqId); ok {
+		_, result = executeStress(StressParameters{Cmd: cmdMetrics, SequenceId: seqId})
+		return result, true, result != nil
+	}
+	return nil, false, false
+}
+
+// serveShare POST /api/share create the read-only share link of the stress test of sequence id
+func serveShare(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "POST":
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req shareRequest
+	result := map[string]interface{}{"err_code": 0, "err_msg": ""}
+	if reqStr, err := io.ReadAll(r.Body); err != nil {
+		result["err_code"], result["err_msg"] = -1, err.Error()
+	} else if err := json.Unmarshal(reqStr, &req); err != nil {
+		result["err_code"], result["err_msg"] = -1, err.Error()
+	} else if _, _, ok := sharedResult(req.SequenceId); !ok {
+		result["err_code"], result["err_msg"] = -1, fmt.Sprintf("sequence id %d not found", req.SequenceId)
+	} else if token, err := newShareToken(req.SequenceId); err != nil {
+		result["err_code"], result["err_msg"] = -1, err.Error()
+	} else {
+		result["token"], result["path"] = token, httpSharePath+token
+	}
+
+	wbody, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", httpContentTypeJSON)
+	w.Write(wbody)
+}
+
+// serveShareView GET /share/{token} read-only html report of the shared stress test, which is
+// refreshed while running, "?output=json" for the result in json
+func serveShareView(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(r.URL.Path, httpSharePath)
+	v, ok := shareTokens.Load(token)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	result, running, ok := sharedResult(v.(int64))
+	if !ok {
+		shareTokens.Delete(token) // the result is expired
+		http.NotFound(w, r)
+		return
+	}
+
+	resultRdMutex.RLock()
+	defer resultRdMutex.RUnlock()
+	if r.URL.Query().Get("output") == outputJson {
+		wbody, _ := json.Marshal(result)
+		w.Header().Set("Content-Type", httpContentTypeJSON)
+		w.Write(wbody)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := result.printHtml(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page := buf.String()
+	if running {
+		page = strings.Replace(page, "<head>", fmt.Sprintf("<head>\n<meta http-equiv=\"refresh\" content=\"%d\">", shareRefresh), 1)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(page))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShareView(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	params := StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL,
+		C:             1,
+		Qps:           50,
+		Duration:      1,
+		Timeout:       1000,
+	}
+	done := make(chan *StressResult)
+	go func() {
+		_, result := executeStress(params)
+		done <- result
+	}()
+
+	share := func(seqId int64) map[string]interface{} {
+		w := httptest.NewRecorder()
+		serveShare(w, httptest.NewRequest("POST", httpWorkerSharePath, strings.NewReader(fmt.Sprintf(`{"sequence_id": %d}`, seqId))))
+		var reply map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &reply)
+		return reply
+	}
+	view := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		serveShareView(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	if reply := share(params.SequenceId + 1); reply["err_code"] != float64(-1) {
+		t.Errorf("share unknown sequence id = %v, expect err", reply)
+	}
+
+	var reply map[string]interface{}
+	for i := 0; i < 50; i++ {
+		if reply = share(params.SequenceId); reply["err_code"] == float64(0) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	path, _ := reply["path"].(string)
+	if !strings.HasPrefix(path, httpSharePath) {
+		t.Fatalf("share = %v, expect path of share view", reply)
+	}
+	if w := view(path); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `http-equiv="refresh"`) {
+		t.Errorf("view of running = %d %q, expect refreshed report", w.Code, w.Body.String())
+	}
+
+	<-done
+	w := view(path)
+	if body := w.Body.String(); w.Code != http.StatusOK || strings.Contains(body, "refresh") || !strings.Contains(body, "http_bench report") {
+		t.Errorf("view of finished = %d %q, expect report", w.Code, body)
+	}
+	var result StressResult
+	if err := json.Unmarshal(view(path+"?output=json").Body.Bytes(), &result); err != nil || result.LatsTotal <= 0 {
+		t.Errorf("json view = %+v, %v", result, err)
+	}
+	if w := view(httpSharePath + "0123"); w.Code != http.StatusNotFound {
+		t.Errorf("view of unknown token = %d, expect 404", w.Code)
+	}
+	w = httptest.NewRecorder()
+	if serveShareView(w, httptest.NewRequest("POST", path, nil)); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST share view = %d, expect 405", w.Code)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// hmacSign request signing options of -sign-hmac
+type hmacSign struct {
+	Header   string   `json:"header"`   // Header to set signature
+	Key      string   `json:"key"`      // Resolved secret key
+	Payload  []string `json:"payload"`  // Parts of request to sign: method, host, path, query, url, body
+	Sep      string   `json:"sep"`      // Separator between payload parts
+	Algo     string   `json:"algo"`     // md5, sha1, sha256, sha512
+	Encoding string   `json:"encoding"` // hex or base64
+	Prefix   string   `json:"prefix"`   // Prefix of signature, e.g. "sha256="
+}
+
+var hmacAlgos = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// parseHmacSign parse -sign-hmac "header=X-Signature;key=env:SECRET;payload=method+path+body;algo=sha256",
+// key supports env:NAME, file:path or the literal key, sep supports escapes like \n
+func parseHmacSign(spec string) (*hmacSign, error) {
+	sign := &hmacSign{Payload: []string{"body"}, Algo: "sha256", Encoding: "hex"}
+	for _, opt := range strings.Split(spec, ";") {
+		if strings.TrimSpace(opt) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(opt, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid option: %s, expect name=value", opt)
+		}
+		switch name = strings.TrimSpace(name); name {
+		case "header":
+			sign.Header = http.CanonicalHeaderKey(strings.TrimSpace(value))
+		case "key":
+			switch {
+			case strings.HasPrefix(value, "env:"):
+				if sign.Key = os.Getenv(value[len("env:"):]); sign.Key == "" {
+					return nil, fmt.Errorf("key env %s is empty", value[len("env:"):])
+				}
+			case strings.HasPrefix(value, "file:"):
+				content, err := os.ReadFile(value[len("file:"):])
+				if err != nil {
+					return nil, err
+				}
+				sign.Key = strings.TrimRight(string(content), "\r\n")
+			default:
+				sign.Key = value
+			}
+		case "payload":
+			sign.Payload = strings.Split(value, "+")
+			for _, part := range sign.Payload {
+				switch part {
+				case "method", "host", "path", "query", "url", "body":
+				default:
+					return nil, fmt.Errorf("invalid payload part: %s", part)
+				}
+			}
+		case "sep":
+			sep, err := strconv.Unquote(`"` + value + `"`)
+			if err != nil {
+				return nil, fmt.Errorf("invalid sep: %s", value)
+			}
+			sign.Sep = sep
+		case "algo":
+			if _, ok := hmacAlgos[value]; !ok {
+				return nil, fmt.Errorf("invalid algo: %s", value)
+			}
+			sign.Algo = value
+		case "encoding":
+			if value != "hex" && value != "base64" {
+				return nil, fmt.Errorf("invalid encoding: %s", value)
+			}
+			sign.Encoding = value
+		case "prefix":
+			sign.Prefix = value
+		default:
+			return nil, fmt.Errorf("invalid option: %s", name)
+		}
+	}
+
+	if sign.Header == "" || sign.Key == "" {
+		return nil, errors.New("header and key are required")
+	}
+	return sign, nil
+}
+
+// sign compute signature of the final request and body
+func (s *hmacSign) sign(req *http.Request, body []byte) string {
+	mac := hmac.New(hmacAlgos[s.Algo], []byte(s.Key))
+	for i, part := range s.Payload {
+		if i > 0 {
+			mac.Write([]byte(s.Sep))
+		}
+		switch part {
+		case "method":
+			mac.Write([]byte(req.Method))
+		case "host":
+			mac.Write([]byte(req.URL.Host))
+		case "path":
+			mac.Write([]byte(req.URL.EscapedPath()))
+		case "query":
+			mac.Write([]byte(req.URL.RawQuery))
+		case "url":
+			mac.Write([]byte(req.URL.String()))
+		case "body":
+			mac.Write(body)
+		}
+	}
+
+	if s.Encoding == "base64" {
+		return s.Prefix + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	return s.Prefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// bodyHeader header computed from the final rendered body of -auto-header
+type bodyHeader struct {
+	header string
+	value  func(body []byte) string
+}
+
+var autoHeaders = map[string]bodyHeader{
+	"content-md5": {"Content-MD5", func(body []byte) string {
+		sum := md5.Sum(body)
+		return base64.StdEncoding.EncodeToString(sum[:])
+	}},
+	"digest-sha256": {"Digest", func(body []byte) string {
+		sum := sha256.Sum256(body)
+		return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+	}},
+	"digest-sha512": {"Digest", func(body []byte) string {
+		sum := sha512.Sum512(body)
+		return "SHA-512=" + base64.StdEncoding.EncodeToString(sum[:])
+	}},
+	"content-digest-sha256": {"Content-Digest", func(body []byte) string {
+		sum := sha256.Sum256(body)
+		return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+	}},
+	"content-sha256": {"X-Amz-Content-Sha256", func(body []byte) string {
+		sum := sha256.Sum256(body)
+		return hex.EncodeToString(sum[:])
+	}},
+}
+
+// checkAutoHeaders check names of -auto-header
+func checkAutoHeaders(names []string) error {
+	for _, name := range names {
+		if _, ok := autoHeaders[name]; !ok {
+			return fmt.Errorf("invalid auto header: %s, expect content-md5, digest-sha256, digest-sha512, "+
+				"content-digest-sha256 or content-sha256", name)
+		}
+	}
+	return nil
+}
+
+// setAutoHeaders set headers computed from body, the header name is kept as is, e.g. Content-MD5
+func setAutoHeaders(header http.Header, names []string, body []byte) {
+	for _, name := range names {
+		h := autoHeaders[name]
+		header.Del(h.header)
+		header[h.header] = []string{h.value(body)}
+	}
+}
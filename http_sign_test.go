@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHmacSign(t *testing.T) {
+	t.Setenv("TEST_HMAC_SECRET", "key")
+
+	req, _ := http.NewRequest("POST", "http://127.0.0.1/a/b?c=1", nil)
+	body := []byte("The quick brown fox jumps over the lazy dog")
+	for _, v := range []struct {
+		spec   string
+		expect string
+	}{
+		{spec: "header=x-signature;key=env:TEST_HMAC_SECRET",
+			expect: "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{spec: "header=X-Signature;key=key;algo=sha1;encoding=base64;prefix=sha1=",
+			expect: "sha1=3nybhbi3iqa8ino29wqQcBydtNk="},
+		{spec: `header=X-Signature;key=key;payload=method+path+query;sep=\n`,
+			expect: hmacSignOf("key", "POST\n/a/b\nc=1")},
+	} {
+		sign, err := parseHmacSign(v.spec)
+		if err != nil {
+			t.Fatalf("parseHmacSign(%s) err: %v", v.spec, err)
+		}
+		if sign.Header != "X-Signature" {
+			t.Errorf("parseHmacSign(%s) header = %s", v.spec, sign.Header)
+		}
+		if got := sign.sign(req, body); got != v.expect {
+			t.Errorf("sign(%s) = %s, expect: %s", v.spec, got, v.expect)
+		}
+	}
+
+	for _, spec := range []string{
+		"key=key",
+		"header=X-Signature;key=env:TEST_HMAC_NONE",
+		"header=X-Signature;key=key;algo=sha3",
+		"header=X-Signature;key=key;payload=method+cookie",
+		"header=X-Signature;key=key;unknown=1",
+	} {
+		if _, err := parseHmacSign(spec); err == nil {
+			t.Errorf("parseHmacSign(%s) expect err", spec)
+		}
+	}
+}
+
+func hmacSignOf(key, payload string) string {
+	req, _ := http.NewRequest("GET", "http://127.0.0.1/", nil)
+	sign := &hmacSign{Header: "X", Key: key, Payload: []string{"body"}, Algo: "sha256", Encoding: "hex"}
+	return sign.sign(req, []byte(payload))
+}
+
+func TestAutoHeaders(t *testing.T) {
+	if err := checkAutoHeaders([]string{"content-md5", "crc32"}); err == nil {
+		t.Errorf("checkAutoHeaders(crc32) expect err")
+	}
+
+	header := http.Header{"Digest": {"stale"}}
+	setAutoHeaders(header, []string{"content-md5", "digest-sha256", "content-digest-sha256"}, []byte("hello"))
+	for name, expect := range map[string]string{
+		"Content-MD5":    "XUFAKrxLKna5cZ2REBfFkg==",
+		"Digest":         "SHA-256=LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=",
+		"Content-Digest": "sha-256=:LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=:",
+	} {
+		if v := header[name]; len(v) != 1 || v[0] != expect {
+			t.Errorf("auto header %s = %v, expect: %s", name, v, expect)
+		}
+	}
+}
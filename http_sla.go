@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+var slaAsserts []assertion // thresholds of -sla-p99 and -sla-error-rate, checked after each url
+
+// parseSla assertions of -sla-p99 and -sla-error-rate, empty is not checked
+func parseSla(p99, errorRate string) ([]assertion, error) {
+	var asserts []assertion
+	if p99 != "" {
+		d, err := time.ParseDuration(p99)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid -sla-p99: %s, e.g. 200ms", p99)
+		}
+		asserts = append(asserts, assertion{name: "p99", value: d.Seconds()})
+	}
+	if errorRate != "" {
+		v, err := parsePercent(errorRate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -sla-error-rate: %s, e.g. 1%%", errorRate)
+		}
+		asserts = append(asserts, assertion{name: "max-error-rate", value: v})
+	}
+	return asserts, nil
+}
+
+// checkSla print the failed SLA of the result to w, and whether all hold
+func (result *StressResult) checkSla(w io.Writer) bool {
+	failed := result.checkAssertions(slaAsserts)
+	for _, f := range failed {
+		fprintln(w, "SLA failed: %s", f)
+	}
+	return len(failed) == 0
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSla(t *testing.T) {
+	for _, v := range []struct {
+		p99, errorRate string
+		expect         []assertion
+		err            bool
+	}{
+		{p99: "200ms", errorRate: "1%", expect: []assertion{{"p99", 0.2}, {"max-error-rate", 0.01}}},
+		{errorRate: "0.5", expect: []assertion{{"max-error-rate", 0.5}}},
+		{},
+		{p99: "200", err: true},
+		{p99: "-1s", err: true},
+		{errorRate: "120%", err: true},
+	} {
+		asserts, err := parseSla(v.p99, v.errorRate)
+		if (err != nil) != v.err || len(asserts) != len(v.expect) {
+			t.Errorf("parseSla(%q, %q) = %v, %v", v.p99, v.errorRate, asserts, err)
+			continue
+		}
+		for i := range asserts {
+			if asserts[i] != v.expect[i] {
+				t.Errorf("parseSla(%q, %q) = %v, expect %v", v.p99, v.errorRate, asserts, v.expect)
+			}
+		}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer srv.Close()
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL,
+		C:             2,
+		N:             20,
+		Timeout:       3000,
+	})
+	if result == nil {
+		t.Fatal("result is nil")
+	}
+
+	defer func(asserts []assertion) { slaAsserts = asserts }(slaAsserts)
+	for _, v := range []struct {
+		p99, errorRate string
+		failed         []string
+	}{
+		{p99: "1s", errorRate: "0%"},
+		{p99: "1ms", errorRate: "1%", failed: []string{"SLA failed: p99 "}},
+	} {
+		slaAsserts, _ = parseSla(v.p99, v.errorRate)
+		var buf bytes.Buffer
+		if ok := result.checkSla(&buf); ok != (len(v.failed) == 0) {
+			t.Errorf("checkSla of p99 %s, error rate %s = %v, output:\n%s", v.p99, v.errorRate, ok, buf.String())
+		}
+		for _, line := range v.failed {
+			if !strings.Contains(buf.String(), line) || !strings.Contains(buf.String(), "> 0.001 secs") {
+				t.Errorf("checkSla expect %q, got:\n%s", line, buf.String())
+			}
+		}
+	}
+
+	slaAsserts, _ = parseSla("", "1%")
+	var buf bytes.Buffer
+	if (&StressResult{LatsTotal: 90, ErrorDist: map[string]int{"timeout": 10}}).checkSla(&buf) ||
+		!strings.Contains(buf.String(), "SLA failed: error rate 10.00% > 1.00%") {
+		t.Errorf("checkSla of error rate, got:\n%s", buf.String())
+	}
+}
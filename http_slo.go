@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	gourl "net/url"
+	"strings"
+	"text/tabwriter"
+)
+
+var sloTargets []*sloTarget // targets of -slo, evaluated after all urls
+
+// sloTarget targets of url pattern of -slo, evaluated on the results of the matched urls
+type sloTarget struct {
+	pattern string
+	asserts []assertion
+	urls    int
+	result  *StressResult // merged results of the matched urls
+}
+
+// parseSlo parse the slo file, url patterns with lists of targets, e.g.
+//
+//	"/api/users/*":
+//	  - p99: 300ms
+//	  - max-error-rate: 1%
+//	  - availability: 99.9%
+func parseSlo(path string) ([]*sloTarget, error) {
+	entries, err := parseYamlFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []*sloTarget
+	for _, entry := range entries {
+		if len(entry.values) <= 0 {
+			return nil, fmt.Errorf("%s: %s expects a list of targets, e.g. \"- p99: 300ms\"", path, entry.key)
+		}
+		var targetEntries []yamlEntry
+		for _, value := range entry.values {
+			key, v, ok := strings.Cut(value, ":")
+			if !ok {
+				return nil, fmt.Errorf("%s: %s: expect \"target: value\", got: %s", path, entry.key, value)
+			}
+			targetEntries = append(targetEntries, yamlEntry{key: strings.TrimSpace(key), values: []string{yamlValue(v)}})
+		}
+		asserts, err := parseAssertions(path, targetEntries)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range asserts {
+			if a.name == "min-rps" {
+				return nil, fmt.Errorf("%s: min-rps is not supported per url pattern", path)
+			}
+		}
+		targets = append(targets, &sloTarget{pattern: entry.key, asserts: asserts})
+	}
+	return targets, nil
+}
+
+// matchWildcard match s with pattern, * matches any characters including "/"
+func matchWildcard(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 {
+			return strings.HasSuffix(s, part)
+		}
+		idx := strings.Index(s, part)
+		if idx < 0 {
+			return false
+		}
+		s = s[idx+len(part):]
+	}
+	return s == ""
+}
+
+// match the pattern starting with "/" matches the path of url, others match the whole url
+func (t *sloTarget) match(url string) bool {
+	if strings.HasPrefix(t.pattern, "/") {
+		u, err := gourl.Parse(url)
+		return err == nil && matchWildcard(t.pattern, u.Path)
+	}
+	return matchWildcard(t.pattern, url)
+}
+
+// recordSlo merge the result of url into the matched targets
+func recordSlo(targets []*sloTarget, url string, result *StressResult) {
+	for _, t := range targets {
+		if t.match(url) {
+			t.urls++
+			t.result = calMutliStressResult(t.result, *result)
+		}
+	}
+}
+
+// printSlo Print the pass/fail matrix of url patterns and targets, return false if any target failed
+func printSlo(w io.Writer, targets []*sloTarget) bool {
+	var names []string
+	seen := make(map[string]bool)
+	for _, t := range targets {
+		for _, a := range t.asserts {
+			if !seen[a.name] {
+				seen[a.name] = true
+				names = append(names, a.name)
+			}
+		}
+	}
+
+	passed := true
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SLO:")
+	fmt.Fprintf(tw, "  Pattern\tUrls\t%s\n", strings.Join(names, "\t"))
+	for _, t := range targets {
+		if t.urls <= 0 {
+			fmt.Fprintf(tw, "  %s\t0\tno url matched\n", t.pattern)
+			continue
+		}
+		cells := make([]string, len(names))
+		for i, name := range names {
+			cells[i] = "-"
+			for _, a := range t.asserts {
+				if a.name != name {
+					continue
+				}
+				actual, ok := t.result.checkAssertion(a)
+				cells[i] = "pass (" + actual + ")"
+				if !ok {
+					cells[i], passed = "FAIL ("+actual+")", false
+				}
+			}
+		}
+		fmt.Fprintf(tw, "  %s\t%d\t%s\n", t.pattern, t.urls, strings.Join(cells, "\t"))
+	}
+	tw.Flush()
+	return passed
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatchWildcard(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"/api/users", "/api/users", true},
+		{"/api/users", "/api/users/1", false},
+		{"/api/*", "/api/users/1", true},
+		{"/api/*/orders", "/api/users/1/orders", true},
+		{"/api/*/orders", "/api/users/1/items", false},
+		{"*.json", "/data/a.json", true},
+		{"http://127.0.0.1/*", "http://127.0.0.1/x?a=1", true},
+	} {
+		if match := matchWildcard(tt.pattern, tt.s); match != tt.match {
+			t.Errorf("matchWildcard(%q, %q) = %v, expect %v", tt.pattern, tt.s, match, tt.match)
+		}
+	}
+}
+
+func TestSlo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slo.yaml")
+	os.WriteFile(path, []byte(`# targets per url pattern
+"http://127.0.0.1:8080/health":
+  - p99: 50ms
+/api/*:
+  - p99: 300ms
+  - max-error-rate: 1%
+  - availability: 99.5%
+/none:
+  - p50: 1s
+`), 0644)
+	targets, err := parseSlo(path)
+	if err != nil || len(targets) != 3 || targets[0].pattern != "http://127.0.0.1:8080/health" || len(targets[1].asserts) != 3 {
+		t.Fatalf("parseSlo = %+v, err: %v", targets, err)
+	}
+
+	recordSlo(targets, "http://127.0.0.1:8080/health", &StressResult{LatsTotal: 10, Lats: map[string]int64{"0.010": 10}})
+	// 5xx responses are unavailable
+	recordSlo(targets, "http://127.0.0.1:8080/api/users", &StressResult{LatsTotal: 100, Lats: map[string]int64{"0.100": 100},
+		StatusCodeDist: map[int]int{200: 98, 503: 2}})
+	recordSlo(targets, "http://127.0.0.1:8080/api/orders", &StressResult{LatsTotal: 100, Lats: map[string]int64{"0.200": 100},
+		StatusCodeDist: map[int]int{200: 100}})
+	if targets[0].urls != 1 || targets[1].urls != 2 || targets[2].urls != 0 {
+		t.Fatalf("matched urls = %d, %d, %d", targets[0].urls, targets[1].urls, targets[2].urls)
+	}
+
+	var buf bytes.Buffer
+	if printSlo(&buf, targets) {
+		t.Errorf("printSlo passed, expect availability failed")
+	}
+	out := buf.String()
+	for _, expect := range []string{"pass (0.010 secs)", "pass (0.200 secs)", "FAIL (99.00%)", "no url matched"} {
+		if !strings.Contains(out, expect) {
+			t.Errorf("slo matrix = %s, expect %q", out, expect)
+		}
+	}
+
+	for _, invalid := range []string{"/api/*: 300ms\n", "/api/*:\n  - p99 300ms\n", "/api/*:\n  - min-rps: 100\n"} {
+		os.WriteFile(path, []byte(invalid), 0644)
+		if _, err := parseSlo(path); err == nil {
+			t.Errorf("parseSlo(%q), expect err", invalid)
+		}
+	}
+}
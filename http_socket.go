@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+)
+
+// StressSocket socket options of the run, which change small request latency a lot
+type StressSocket struct {
+	NoDelay    bool  `json:"nodelay"`     // TCP_NODELAY, false is Nagle's algorithm
+	KeepAlive  int64 `json:"keepalive"`   // TCP keepalive period in ms, 0 is default, -1 is disabled
+	SendBuffer int64 `json:"send_buffer"` // SO_SNDBUF in bytes, 0 is default of the system
+	RecvBuffer int64 `json:"recv_buffer"` // SO_RCVBUF in bytes, 0 is default of the system
+}
+
+// socket socket options of -tcp-nodelay, -tcp-keepalive, -send-buffer and -recv-buffer
+func (b *StressWorker) socket() *StressSocket {
+	return &StressSocket{
+		NoDelay:    !b.RequestParams.DisableNoDelay,
+		KeepAlive:  b.RequestParams.TcpKeepAlive,
+		SendBuffer: b.RequestParams.SendBuffer,
+		RecvBuffer: b.RequestParams.RecvBuffer,
+	}
+}
+
+// tuned socket options differ from the default of go
+func (s *StressSocket) tuned() bool {
+	return !s.NoDelay || s.KeepAlive != 0 || s.SendBuffer > 0 || s.RecvBuffer > 0
+}
+
+// tuneConn set socket options of tcp connection
+func (s *StressSocket) tuneConn(conn net.Conn) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	if !s.NoDelay {
+		tcpConn.SetNoDelay(false)
+	}
+	if s.SendBuffer > 0 {
+		tcpConn.SetWriteBuffer(int(s.SendBuffer))
+	}
+	if s.RecvBuffer > 0 {
+		tcpConn.SetReadBuffer(int(s.RecvBuffer))
+	}
+}
+
+// dialContext dial function of connection id with socket options, connections are round-robined
+// across -interface, dials rotate the source addresses of -local-ip, and dial the address pinned by
+// -resolve instead of the host
+func (b *StressWorker) dialContext(id int, dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	d, socket := *dialer, b.socket()
+	switch {
+	case socket.KeepAlive > 0:
+		d.KeepAlive = time.Duration(socket.KeepAlive) * time.Millisecond
+	case socket.KeepAlive < 0:
+		d.KeepAlive = -1
+	}
+
+	var iface *localInterface
+	if len(b.interfaces) > 0 {
+		iface = &b.interfaces[id%len(b.interfaces)]
+		d.LocalAddr = &net.TCPAddr{IP: iface.ip}
+	}
+	family := b.ipFamily(id)
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if family != "" && network == "tcp" {
+			network = familyNetworks[family]
+		}
+		if pinned, ok := b.resolves[addr]; ok {
+			addr = pinned
+		}
+		var (
+			conn net.Conn
+			err  error
+		)
+		if b.localIps != nil {
+			conn, err = b.localIps.dialContext(ctx, d, network, addr)
+		} else {
+			conn, err = d.DialContext(ctx, network, addr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		socket.tuneConn(conn)
+		if iface == nil {
+			return conn, nil
+		}
+		atomic.AddInt64(&iface.stats.Conns, 1)
+		return &countingConn{Conn: conn, stats: iface.stats}, nil
+	}
+}
+
+// dialTLSContext tls dial function of http2 connection id, the handshake is reported to the
+// client trace like http1, as the http2 transport does not
+func (b *StressWorker) dialTLSContext(id int, dialer *net.Dialer) func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+	dial := b.dialContext(id, dialer)
+	return func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		trace := httptrace.ContextClientTrace(ctx)
+		if trace != nil && trace.TLSHandshakeStart != nil {
+			trace.TLSHandshakeStart()
+		}
+		tlsConn := tls.Client(conn, cfg)
+		err = tlsConn.HandshakeContext(ctx)
+		if trace != nil && trace.TLSHandshakeDone != nil {
+			trace.TLSHandshakeDone(tlsConn.ConnectionState(), err)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
+
+// printSocket Print socket options of the run
+func (result *StressResult) printSocket(w io.Writer) {
+	s := result.Socket
+	onOff := map[bool]string{true: "on", false: "off"}
+	fprintln(w, "\nSocket options:")
+	fprintln(w, "  TCP_NODELAY:\t%s", onOff[s.NoDelay])
+	switch {
+	case s.KeepAlive > 0:
+		fprintln(w, "  Keepalive:\t%v", time.Duration(s.KeepAlive)*time.Millisecond)
+	case s.KeepAlive < 0:
+		fprintln(w, "  Keepalive:\toff")
+	default:
+		fprintln(w, "  Keepalive:\tdefault")
+	}
+	for _, buf := range []struct {
+		name string
+		size int64
+	}{{"Send buffer", s.SendBuffer}, {"Recv buffer", s.RecvBuffer}} {
+		if buf.size > 0 {
+			fprintln(w, "  %s:\t%s", buf.name, toByteSizeStr(float64(buf.size)))
+		} else {
+			fprintln(w, "  %s:\tdefault", buf.name)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSocketOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	b := &StressWorker{RequestParams: &StressParameters{RequestType: typeHttp1, RequestMethod: "GET",
+		Url: srv.URL, Timeout: 1000}}
+	if b.socket().tuned() {
+		t.Errorf("default socket = %+v, expect not tuned", b.socket())
+	}
+
+	b.RequestParams.DisableNoDelay = true
+	b.RequestParams.TcpKeepAlive = -1
+	b.RequestParams.SendBuffer, b.RequestParams.RecvBuffer = 64<<10, 128<<10
+	b.prepare()
+
+	conn, err := b.dialContext(0, &net.Dialer{})(context.Background(), "tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial err: %v", err)
+	}
+	if _, ok := conn.(*net.TCPConn); !ok {
+		t.Errorf("dialed conn = %T, expect *net.TCPConn", conn)
+	}
+	conn.Close()
+
+	client := b.newClient(0)
+	res := &result{start: time.Now()}
+	b.doClient(client, res)
+	if res.err != nil || res.statusCode != http.StatusOK {
+		t.Fatalf("request status = %d, err: %v", res.statusCode, res.err)
+	}
+	b.closeClient(client)
+
+	var buf bytes.Buffer
+	stats := GetStressResult()
+	stats.Socket = b.socket()
+	calMutliStressResult(nil, *stats).printSocket(&buf)
+	for _, expect := range []string{"TCP_NODELAY:\toff", "Keepalive:\toff", "64.000 KB", "128.000 KB"} {
+		if !strings.Contains(buf.String(), expect) {
+			t.Errorf("socket output = %q, expect %q", buf.String(), expect)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	stageSetup    = "setup"
+	stageTeardown = "teardown"
+)
+
+var errStageStatus = errors.New("unexpected status code")
+
+// stageExitCode exit code of failed stage, request errors are unreachable and status codes are assertion failures
+func stageExitCode(err error) int {
+	if errors.Is(err, errStageStatus) {
+		return exitAssertion
+	}
+	return exitUnreachable
+}
+
+// runStage run requests of setup or teardown stage once in order within the time box,
+// the responses are not counted in the result, and it stops at the first failed request
+func runStage(name string, entries []urlEntry, params StressParameters, timebox time.Duration) error {
+	deadline := time.Now().Add(timebox)
+	for _, entry := range entries {
+		remain := time.Until(deadline)
+		if remain <= 0 {
+			return fmt.Errorf("%s stage exceeds %s", name, timebox)
+		}
+
+		p := params
+		p.Url, p.RequestBody, p.RequestBodyType, p.RequestForm, p.QueryParams = entry.url, entry.body, bodyString, nil, nil
+		p.RequestMethod, p.BodyStream, p.MultipartForm = "GET", "", nil
+		if entry.method != "" {
+			p.RequestMethod = entry.method
+		}
+		if len(entry.headers) > 0 {
+			p.Headers = make(map[string][]string, 0)
+			for k, v := range params.Headers {
+				p.Headers[k] = v
+			}
+			parseHeaders(p.Headers, entry.headers, true)
+		}
+		if remain < time.Duration(p.Timeout)*time.Millisecond {
+			p.Timeout = int(remain.Milliseconds()) + 1
+		}
+
+		b := &StressWorker{RequestParams: &p}
+		b.prepare()
+		client := b.newClient(0)
+		if client == nil {
+			return fmt.Errorf("%s stage: create client of %s", name, p.Url)
+		}
+		res := &result{start: time.Now()}
+		b.doClient(client, res)
+		b.closeClient(client)
+
+		if res.err != nil {
+			return fmt.Errorf("%s stage: line %d %s %s: %v", name, entry.line, p.RequestMethod, p.Url, res.err)
+		}
+		if res.statusCode >= 400 {
+			return fmt.Errorf("%s stage: line %d %s %s: %w %d", name, entry.line, p.RequestMethod, p.Url, errStageStatus, res.statusCode)
+		}
+		eprintln("%s: %s %s %d in %4.3f secs", name, p.RequestMethod, p.Url, res.statusCode, time.Since(res.start).Seconds())
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunStage(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.Header.Get("X-K")+" "+string(body))
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	params := StressParameters{RequestType: typeHttp1, RequestMethod: "PUT", Timeout: 1000,
+		Headers: map[string][]string{"X-K": {"a"}}}
+	var entries []urlEntry
+	for i, line := range []string{
+		`POST ` + srv.URL + `/items -H "X-K: b" -body '{"n": {{ intSum 1 2 }}}'`,
+		srv.URL + `/items`,
+		srv.URL + `/fail`,
+		srv.URL + `/none`,
+	} {
+		entry, err := parseUrlEntry(i+1, line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+
+	err := runStage(stageSetup, entries, params, time.Second)
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("runStage err: %v, expect failed at line 3", err)
+	}
+	if expect := []string{`POST /items b {"n": 3}`, "GET /items a ", "GET /fail a "}; strings.Join(requests, "|") != strings.Join(expect, "|") {
+		t.Errorf("runStage requests = %q, expect: %q", requests, expect)
+	}
+	if params.Headers["X-K"][0] != "a" {
+		t.Errorf("runStage changed headers of params: %v", params.Headers)
+	}
+}
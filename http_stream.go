@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+var listenStreamFiles []string // -listen-body-stream, files of body_stream accepted from controllers
+
+// checkRemoteStream reject body_stream of parameters received by -listen unless the worker allows the
+// file by -listen-body-stream, otherwise any file of the worker could be uploaded to any url
+func checkRemoteStream(fileName string) error {
+	if fileName == "" {
+		return nil
+	}
+	if path, err := filepath.Abs(fileName); err == nil {
+		for _, v := range listenStreamFiles {
+			if allowed, err := filepath.Abs(v); err == nil && allowed == path {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("body_stream %s is not allowed by -listen-body-stream of the worker", fileName)
+}
+
+// bodyStream file of -body-stream, each request reads it from disk by offset, so multi-GB uploads are
+// never held in memory and connections share one file descriptor
+type bodyStream struct {
+	file *os.File
+	size int64
+}
+
+func openBodyStream(fileName string) (*bodyStream, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a regular file", fileName)
+	}
+	return &bodyStream{file: file, size: info.Size()}, nil
+}
+
+func (s *bodyStream) reader() io.ReadCloser {
+	return io.NopCloser(io.NewSectionReader(s.file, 0, s.size))
+}
+
+// attach stream the file as the body of req, GetBody rewinds it for redirects and retries
+func (s *bodyStream) attach(req *http.Request) {
+	req.Body, req.ContentLength = s.reader(), s.size
+	req.GetBody = func() (io.ReadCloser, error) { return s.reader(), nil }
+	if s.size == 0 {
+		req.Body, req.GetBody = http.NoBody, nil
+	}
+}
+
+func (s *bodyStream) close() {
+	if s != nil {
+		s.file.Close()
+	}
+}
+
+// chunkBody hide the length of the body of req, so http1 sends it with Transfer-Encoding: chunked and
+// http2 and http3 without Content-Length
+func chunkBody(req *http.Request) {
+	if req.Body != nil && req.Body != http.NoBody {
+		req.ContentLength = -1
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStressBodyStream(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 256*1024) // 4MB
+	fileName := filepath.Join(t.TempDir(), "big.bin")
+	if err := os.WriteFile(fileName, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect := sha256.Sum256(content)
+
+	var (
+		mu      sync.Mutex
+		uploads []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := sha256.New()
+		n, _ := io.Copy(h, r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		upload := strings.Join(r.TransferEncoding, ",")
+		if n != int64(len(content)) || !bytes.Equal(h.Sum(nil), expect[:]) {
+			upload = "corrupted"
+		} else if r.ContentLength == int64(len(content)) {
+			upload = "length"
+		}
+		uploads = append(uploads, upload)
+	}))
+	defer srv.Close()
+
+	for _, v := range []struct {
+		chunked bool
+		upload  string
+	}{
+		{chunked: false, upload: "length"},
+		{chunked: true, upload: "chunked"},
+	} {
+		mu.Lock()
+		uploads = nil
+		mu.Unlock()
+		_, result := executeStress(StressParameters{
+			SequenceId:    time.Now().UnixNano(),
+			Cmd:           cmdStart,
+			RequestType:   typeHttp1,
+			RequestMethod: "POST",
+			Url:           srv.URL,
+			BodyStream:    fileName,
+			Chunked:       v.chunked,
+			C:             2,
+			N:             8,
+			Timeout:       10000,
+		})
+		if result == nil || result.ErrTotal() > 0 || result.LatsTotal <= 0 {
+			t.Fatalf("result of chunked %v = %+v", v.chunked, result)
+		}
+		mu.Lock()
+		for _, upload := range uploads {
+			if upload != v.upload {
+				t.Errorf("uploads of chunked %v = %v, expect %s", v.chunked, uploads, v.upload)
+				break
+			}
+		}
+		mu.Unlock()
+	}
+
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "POST",
+		Url:           srv.URL,
+		BodyStream:    filepath.Join(t.TempDir(), "missing.bin"),
+		C:             1,
+		N:             2,
+		Timeout:       3000,
+	})
+	if result == nil || result.ErrTotal() <= 0 || result.LatsTotal > 0 {
+		t.Errorf("result of missing file = %+v", result)
+	}
+}
+
+func TestRemoteBodyStream(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "big.bin")
+	os.WriteFile(fileName, []byte("body"), 0644)
+	defer func(files []string) { listenStreamFiles = files }(listenStreamFiles)
+	listenStreamFiles = nil
+
+	worker := httptest.NewServer(http.HandlerFunc(serveWorker))
+	defer worker.Close()
+	params := StressParameters{SequenceId: time.Now().UnixNano(), Cmd: cmdStart, RequestType: typeHttp1,
+		RequestMethod: "POST", Url: "http://127.0.0.1:1/", BodyStream: "/etc/passwd", C: 1, N: 1, Timeout: 1000}
+	body, _ := json.Marshal(params)
+	if result, err := executeWorkerReq(worker.URL+httpWorkerApiPath, body); err != nil || result.ErrCode != -1 ||
+		!strings.Contains(result.ErrMsg, "-listen-body-stream") {
+		t.Errorf("worker result of body_stream not allowed = %+v, %v", result, err)
+	}
+	if _, code, err := startTest(params); code != http.StatusForbidden || err == nil {
+		t.Errorf("start of body_stream not allowed = %d, %v", code, err)
+	}
+
+	listenStreamFiles = []string{fileName}
+	if err := checkRemoteStream(filepath.Join(filepath.Dir(fileName), ".", "big.bin")); err != nil {
+		t.Errorf("allowed file err: %v", err)
+	}
+	if err := checkRemoteStream(fileName + ".bak"); err == nil {
+		t.Errorf("other file expect err")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"text/template"
+)
+
+const (
+	urlSequential = "sequential" // each url of -url-file is a separate run in order
+	urlRandom     = "random"     // each request picks a url randomly
+	urlRoundRobin = "roundrobin" // requests of all connections take the urls in turn
+	urlWeighted   = "weighted"   // each request picks a url randomly by the weight of the line
+)
+
+// UrlTarget url of -url-file picked by -url-strategy in one run
+type UrlTarget struct {
+	Name    string   `json:"name"`    // label of the hits, "METHOD url" and the line for duplicates
+	Method  string   `json:"method"`  // empty is -m
+	Url     string   `json:"url"`     // template
+	Headers []string `json:"headers"` // "key: value" templates, replace the headers of -H
+	Body    string   `json:"body"`    // template, empty is -body
+	Weight  int      `json:"weight"`  // weight of weighted strategy, default 1
+}
+
+// urlTarget url of -url-file with parsed templates, a nil body uses -body
+type urlTarget struct {
+	name, method    string
+	weight          int
+	url, body       *template.Template
+	header          http.Header
+	headerTemplates []headerTemplate
+}
+
+// newUrlTargets targets of the entries of -url-file, the lines of duplicate urls are labeled
+func newUrlTargets(entries []urlEntry, method string) []UrlTarget {
+	var (
+		targets = make([]UrlTarget, 0, len(entries))
+		names   = make(map[string]int)
+	)
+	for _, entry := range entries {
+		m := entry.method
+		if m == "" {
+			m = method
+		}
+		names[m+" "+entry.url]++
+	}
+	for _, entry := range entries {
+		m := entry.method
+		if m == "" {
+			m = method
+		}
+		t := UrlTarget{Name: m + " " + entry.url, Method: entry.method, Url: entry.url, Headers: entry.headers,
+			Body: entry.body, Weight: entry.weight}
+		if names[t.Name] > 1 {
+			t.Name = fmt.Sprintf("%s (line %d)", t.Name, entry.line)
+		}
+		if t.Weight < 1 {
+			t.Weight = 1
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// parseUrlTargets parse templates of the targets, the inline headers replace the headers of -H
+func parseUrlTargets(targets []UrlTarget, headers http.Header) ([]urlTarget, error) {
+	var parsed []urlTarget
+	for i, t := range targets {
+		target := urlTarget{name: t.Name, method: t.Method, weight: t.Weight, header: headers}
+		var err error
+		if target.url, err = template.New(fmt.Sprintf("TARGET-URL-%d", i)).Funcs(fnMap).Parse(t.Url); err != nil {
+			return nil, fmt.Errorf("invalid url %s template: %v", t.Name, err)
+		}
+		if t.Body != "" {
+			if target.body, err = template.New(fmt.Sprintf("TARGET-BODY-%d", i)).Funcs(fnMap).Parse(t.Body); err != nil {
+				return nil, fmt.Errorf("invalid url %s body template: %v", t.Name, err)
+			}
+		}
+		if len(t.Headers) > 0 {
+			if target.header = headers.Clone(); target.header == nil {
+				target.header = make(http.Header)
+			}
+			if err = parseHeaders(target.header, t.Headers, true); err != nil {
+				return nil, fmt.Errorf("invalid url %s headers: %v", t.Name, err)
+			}
+		}
+		if target.headerTemplates, err = parseHeaderTemplates(target.header); err != nil {
+			return nil, fmt.Errorf("invalid url %s: %v", t.Name, err)
+		}
+		parsed = append(parsed, target)
+	}
+	return parsed, nil
+}
+
+// clone the templates of target with per worker functions
+func (t urlTarget) clone(fnWorker template.FuncMap) urlTarget {
+	t.url, t.body = cloneTemplate(t.url, fnWorker), cloneTemplate(t.body, fnWorker)
+	hts := make([]headerTemplate, 0, len(t.headerTemplates))
+	for _, ht := range t.headerTemplates {
+		clientHt := headerTemplate{key: ht.key}
+		for _, tpl := range ht.values {
+			clientHt.values = append(clientHt.values, cloneTemplate(tpl, fnWorker))
+		}
+		hts = append(hts, clientHt)
+	}
+	t.headerTemplates = hts
+	return t
+}
+
+// pickTarget pick the url of the request by -url-strategy
+func (b *StressWorker) pickTarget(client *StressClient) *urlTarget {
+	targets := client.targets
+	switch b.RequestParams.UrlStrategy {
+	case urlRoundRobin:
+		return &targets[(atomic.AddUint64(&b.targetNext, 1)-1)%uint64(len(targets))]
+	case urlWeighted:
+		total := 0
+		for _, t := range targets {
+			total += t.weight
+		}
+		n := client.random.Intn(total)
+		for i := range targets {
+			if n -= targets[i].weight; n < 0 {
+				return &targets[i]
+			}
+		}
+		return &targets[len(targets)-1]
+	default:
+		return &targets[client.random.Intn(len(targets))]
+	}
+}
+
+// printUrls Print hits, errors and latency of each url of -url-strategy
+func (result *StressResult) printUrls(w io.Writer) {
+	names := make([]string, 0, len(result.UrlDist))
+	var total int64
+	for name, p := range result.UrlDist {
+		names = append(names, name)
+		total += p.LatsTotal + p.ErrTotal
+	}
+	sort.Slice(names, func(i, j int) bool {
+		pi, pj := result.UrlDist[names[i]], result.UrlDist[names[j]]
+		if hi, hj := pi.LatsTotal+pi.ErrTotal, pj.LatsTotal+pj.ErrTotal; hi != hj {
+			return hi > hj
+		}
+		return names[i] < names[j]
+	})
+
+	fprintln(w, "\nUrls (%s):", result.UrlStrategy)
+	for _, name := range names {
+		p := result.UrlDist[name]
+		hits := p.LatsTotal + p.ErrTotal
+		share := float64(hits) * 100 / float64(total)
+		if p.LatsTotal <= 0 {
+			fprintln(w, "  [%s]\t%d hits (%.2f%%), 0 responses, %d failed", name, hits, share, p.ErrTotal)
+			continue
+		}
+		data := latsPercentiles(p.Lats, p.LatsTotal, []int{50, 90, 99})
+		fprintln(w, "  [%s]\t%d hits (%.2f%%), %d failed, p50 %4.3f secs, p90 %4.3f secs, p99 %4.3f secs",
+			name, hits, share, p.ErrTotal, data[0], data[1], data[2])
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewUrlTargets(t *testing.T) {
+	var entries []urlEntry
+	for i, line := range []string{`http://127.0.0.1/a 5`, `POST http://127.0.0.1/b -body '{}'`, `http://127.0.0.1/a`} {
+		entry, err := parseUrlEntry(i+1, line)
+		if err != nil {
+			t.Fatalf("parseUrlEntry(%s) err: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	targets := newUrlTargets(entries, "GET")
+	for i, expect := range []UrlTarget{
+		{Name: "GET http://127.0.0.1/a (line 1)", Url: "http://127.0.0.1/a", Weight: 5},
+		{Name: "POST http://127.0.0.1/b", Method: "POST", Url: "http://127.0.0.1/b", Body: "{}", Weight: 1},
+		{Name: "GET http://127.0.0.1/a (line 3)", Url: "http://127.0.0.1/a", Weight: 1},
+	} {
+		if got := targets[i]; got.Name != expect.Name || got.Method != expect.Method || got.Url != expect.Url ||
+			got.Body != expect.Body || got.Weight != expect.Weight {
+			t.Errorf("target %d = %+v, expect %+v", i, got, expect)
+		}
+	}
+}
+
+func TestStressUrlStrategy(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		hits = make(map[string]int64)
+		bad  []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		hits[r.Method+" "+r.URL.Path]++
+		if r.URL.Path == "/b" && (string(body) != "b" || r.Header.Get("X-Url") != "b") ||
+			r.URL.Path != "/b" && (r.Header.Get("X-Url") != "all" || len(body) > 0) {
+			bad = append(bad, r.Method+" "+r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	targets := []UrlTarget{
+		{Name: "a", Url: srv.URL + "/a", Weight: 3},
+		{Name: "b", Method: "POST", Url: srv.URL + "/b", Headers: []string{"X-Url: b"}, Body: "b", Weight: 1},
+		{Name: "c", Url: srv.URL + "/c{{ intSum 1 }}", Weight: 1},
+	}
+	for _, strategy := range []string{urlRandom, urlRoundRobin, urlWeighted} {
+		mu.Lock()
+		hits, bad = make(map[string]int64), nil
+		mu.Unlock()
+		_, result := executeStress(StressParameters{
+			SequenceId:    time.Now().UnixNano(),
+			Cmd:           cmdStart,
+			RequestType:   typeHttp1,
+			RequestMethod: "GET",
+			Url:           srv.URL + "/a",
+			Headers:       map[string][]string{"X-Url": {"all"}},
+			UrlStrategy:   strategy,
+			Targets:       targets,
+			C:             2,
+			N:             600,
+			Timeout:       3000,
+		})
+		if result == nil || result.ErrTotal() > 0 || result.UrlStrategy != strategy || len(result.UrlDist) != 3 {
+			t.Fatalf("result of %s = %+v", strategy, result)
+		}
+		mu.Lock()
+		if len(bad) > 0 || hits["GET /a"] != result.UrlDist["a"].LatsTotal || hits["POST /b"] != result.UrlDist["b"].LatsTotal ||
+			hits["GET /c1"] != result.UrlDist["c"].LatsTotal {
+			t.Errorf("hits of %s = %v, url dist: a %d, b %d, c %d, bad: %v", strategy, hits, result.UrlDist["a"].LatsTotal,
+				result.UrlDist["b"].LatsTotal, result.UrlDist["c"].LatsTotal, bad)
+		}
+		mu.Unlock()
+		a, b := result.UrlDist["a"].LatsTotal, result.UrlDist["b"].LatsTotal
+		switch strategy {
+		case urlRoundRobin:
+			if a-b > 1 || b-a > 1 {
+				t.Errorf("hits of roundrobin: a %d, b %d, expect in turn", a, b)
+			}
+		case urlWeighted:
+			if a < 2*b {
+				t.Errorf("hits of weighted: a %d, b %d, expect about 3 times", a, b)
+			}
+		}
+	}
+
+	result := &StressResult{UrlStrategy: urlWeighted, UrlDist: map[string]*StressPoint{
+		"GET http://host/a": {LatsTotal: 3, Lats: map[string]int64{"0.010": 3}},
+		"GET http://host/b": {ErrTotal: 1},
+	}}
+	var buf bytes.Buffer
+	result.printUrls(&buf)
+	for _, line := range []string{"Urls (weighted):", "  [GET http://host/a]\t3 hits (75.00%), 0 failed, p50 0.010 secs",
+		"  [GET http://host/b]\t1 hits (25.00%), 0 responses, 1 failed"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printUrls expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+var (
+	errTcpTimeout = fmt.Errorf("tcp response timeout: %w", os.ErrDeadlineExceeded)
+	errTcpClosed  = errors.New("tcp connection closed by server")
+)
+
+// StressTcp requests of -p tcp
+type StressTcp struct {
+	Sent       int64 `json:"sent"`       // requests sent
+	BytesSent  int64 `json:"bytes_sent"` // bytes of requests sent
+	Received   int64 `json:"received"`   // responses received
+	BytesRecv  int64 `json:"bytes_recv"` // bytes of responses
+	Reconnects int64 `json:"reconnects"` // connections dialed again after an error or with -disable-keepalive
+	Timeouts   int64 `json:"timeouts"`   // responses not received within the timeout
+}
+
+type ConnOption struct {
+	timeout           time.Duration                                                     // deadline of each request, 0 is none
+	disableKeepAlives bool                                                              // a new connection per request
+	responseSize      int64                                                             // bytes of a response, 0 reads what one read returns
+	dial              func(ctx context.Context, network, addr string) (net.Conn, error) // default net.Dial
+}
+
+// tcpConn tcp connection of -p tcp, a request writes the body and reads a response, the connection
+// is dialed again by the next request after an error
+type tcpConn struct {
+	tcpClient net.Conn
+	uri       string
+	option    ConnOption
+	dials     int64 // connections dialed
+}
+
+func DialTCP(uri string, option ConnOption) (*tcpConn, error) {
+	tcp := &tcpConn{uri: uri, option: option}
+	if err := tcp.connect(); err != nil {
+		verbosePrint(vERROR, "DialTCP Dial err: %v", err)
+		return nil, err
+	}
+	return tcp, nil
+}
+
+func (tcp *tcpConn) connect() error {
+	dial := tcp.option.dial
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: tcp.option.timeout}).DialContext
+	}
+	conn, err := dial(context.Background(), "tcp", tcp.uri)
+	if err != nil {
+		return err
+	}
+	tcp.tcpClient = conn
+	tcp.dials++
+	return nil
+}
+
+// Do write the body and read the response, return bytes sent and received
+func (tcp *tcpConn) Do(body []byte) (int64, int64, error) {
+	if tcp.tcpClient == nil {
+		if tcp.dials <= 0 {
+			return 0, 0, ErrInitTcpClient
+		}
+		if err := tcp.connect(); err != nil {
+			return 0, 0, err
+		}
+	}
+	if tcp.option.timeout > 0 {
+		tcp.tcpClient.SetDeadline(time.Now().Add(tcp.option.timeout))
+	}
+
+	sent, err := tcp.tcpClient.Write(body)
+	var recv int64
+	if err == nil {
+		if tcp.option.responseSize > 0 {
+			recv, err = io.CopyN(io.Discard, tcp.tcpClient, tcp.option.responseSize)
+		} else if recv, err = fastRead(tcp.tcpClient, false); err == nil && recv <= 0 {
+			err = errTcpClosed
+		}
+	}
+	switch {
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		err = errTcpTimeout // the same error of all connections
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		err = errTcpClosed
+	}
+	if err != nil || tcp.option.disableKeepAlives {
+		tcp.tcpClient.Close()
+		tcp.tcpClient = nil
+	}
+	return int64(sent), recv, err
+}
+
+func (tcp *tcpConn) Close() error {
+	if tcp.tcpClient == nil {
+		return ErrInitTcpClient
+	}
+
+	err := tcp.tcpClient.Close()
+	tcp.tcpClient = nil
+	tcp.dials = 0
+	return err
+}
+
+func (t *StressTcp) append(res *result) {
+	t.Sent++
+	t.BytesSent += res.tcpSent
+	if res.tcpReconnect {
+		t.Reconnects++
+	}
+	switch {
+	case res.err == nil:
+		t.Received++
+		t.BytesRecv += res.contentLength
+	case errors.Is(res.err, errTcpTimeout):
+		t.Timeouts++
+	}
+}
+
+func (t *StressTcp) merge(v *StressTcp) {
+	t.Sent += v.Sent
+	t.BytesSent += v.BytesSent
+	t.Received += v.Received
+	t.BytesRecv += v.BytesRecv
+	t.Reconnects += v.Reconnects
+	t.Timeouts += v.Timeouts
+}
+
+// printTcp Print requests sent and received of -p tcp
+func (result *StressResult) printTcp(w io.Writer) {
+	t := result.Tcp
+	fprintln(w, "\nTCP:")
+	fprintln(w, "  Sent:\t%d requests, %s", t.Sent, toByteSizeStr(float64(t.BytesSent)))
+	fprintln(w, "  Received:\t%d responses, %s", t.Received, toByteSizeStr(float64(t.BytesRecv)))
+	fprintln(w, "  Reconnects:\t%d", t.Reconnects)
+	fprintln(w, "  Timeouts:\t%d (%.2f%% of sent)", t.Timeouts, float64(t.Timeouts)*100/float64(t.Sent))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTcpReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var conns, requests int64
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt64(&conns, 1)
+			go func(conn net.Conn) {
+				defer conn.Close()
+				buf := make([]byte, 4)
+				for {
+					if _, err := io.ReadFull(conn, buf); err != nil {
+						return
+					}
+					if atomic.AddInt64(&requests, 1)%3 == 0 {
+						return // closed without response
+					}
+					conn.Write(append([]byte("recv: "), buf...))
+				}
+			}(conn)
+		}
+	}()
+
+	params := StressParameters{
+		SequenceId:      time.Now().UnixNano(),
+		Cmd:             cmdStart,
+		RequestType:     typeTCP,
+		RequestBody:     "70696e67", // ping
+		RequestBodyType: bodyHex,
+		Url:             ln.Addr().String(),
+		C:               1,
+		N:               9,
+		Timeout:         1000,
+		TcpResponseSize: 10,
+	}
+	_, result := executeStress(params)
+	if result == nil || result.Tcp == nil {
+		t.Fatalf("result of tcp = %+v", result)
+	}
+	tcp := result.Tcp
+	n := atomic.LoadInt64(&requests)
+	if tcp.Sent != n || tcp.BytesSent != 4*n || tcp.Received != n-n/3 || tcp.BytesRecv != 10*tcp.Received ||
+		int64(result.ErrorDist[errTcpClosed.Error()]) != n/3 {
+		t.Errorf("tcp = %+v of %d requests, errors: %v", tcp, n, result.ErrorDist)
+	}
+	if c := atomic.LoadInt64(&conns); tcp.Reconnects != c-1 || tcp.Reconnects < n/3-1 {
+		t.Errorf("reconnects = %d of %d connections", tcp.Reconnects, c)
+	}
+
+	var buf bytes.Buffer
+	result.printTcp(&buf)
+	for _, line := range []string{"  Sent:\t", "  Received:\t", "  Reconnects:\t", "  Timeouts:\t0 (0.00% of sent)"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printTcp expect %q, got:\n%s", line, buf.String())
+		}
+	}
+
+	atomic.StoreInt64(&conns, 0)
+	atomic.StoreInt64(&requests, 0)
+	params.SequenceId, params.N, params.DisableKeepAlives = time.Now().UnixNano(), 2, true
+	_, result = executeStress(params)
+	if result == nil || result.Tcp == nil || result.Tcp.Received < 2 || atomic.LoadInt64(&conns) != result.Tcp.Sent {
+		t.Errorf("result of tcp without keep-alive = %+v, connections: %d", result, atomic.LoadInt64(&conns))
+	}
+}
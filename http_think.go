@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// StressThink pauses of the virtual users between requests of -think-time or -think-time-random
+type StressThink struct {
+	Min     int64   `json:"min"`     // ms
+	Max     int64   `json:"max"`     // ms, equal to min if not random
+	Thinks  int64   `json:"thinks"`  // pauses between requests
+	Total   int64   `json:"total"`   // ms paused
+	Offered float64 `json:"offered"` // requests per second of the connections if the responses were instant
+}
+
+// parseThinkRange parse "100ms-500ms" of -think-time-random
+func parseThinkRange(s string) (min, max time.Duration, err error) {
+	from, to, ok := strings.Cut(s, "-")
+	if ok {
+		if min, err = time.ParseDuration(strings.TrimSpace(from)); err == nil {
+			max, err = time.ParseDuration(strings.TrimSpace(to))
+		}
+	}
+	if !ok || err != nil || min < 0 || max < min {
+		return 0, 0, fmt.Errorf("invalid -think-time-random: %s, expect min-max, e.g. 100ms-500ms", s)
+	}
+	return min, max, nil
+}
+
+// think pause the virtual user of client after a request, a random time between the min and max of
+// -think-time-random
+func (b *StressWorker) think(client *StressClient) {
+	min, max := b.RequestParams.ThinkTime, b.RequestParams.ThinkTimeMax
+	if max <= 0 {
+		return
+	}
+	d := time.Duration(min) * time.Millisecond
+	if max > min {
+		d += time.Duration(client.random.Int63n(max-min+1)) * time.Millisecond
+	}
+	start := time.Now()
+	for end := start.Add(d); !b.IsStop(); {
+		wait := time.Until(end)
+		if wait <= 0 {
+			break
+		}
+		if wait > 100*time.Millisecond {
+			wait = 100 * time.Millisecond // check stop in time
+		}
+		time.Sleep(wait)
+	}
+	atomic.AddInt64(&b.thinks, 1)
+	atomic.AddInt64(&b.thinkTotal, int64(time.Since(start)))
+}
+
+// thinkStats pauses since the last call, the pauses of a -window are reset when it rolls
+func (b *StressWorker) thinkStats() *StressThink {
+	t := &StressThink{
+		Min:    b.RequestParams.ThinkTime,
+		Max:    b.RequestParams.ThinkTimeMax,
+		Thinks: atomic.SwapInt64(&b.thinks, 0),
+		Total:  time.Duration(atomic.SwapInt64(&b.thinkTotal, 0)).Milliseconds(),
+	}
+	if avg := float64(t.Min+t.Max) / 2; avg > 0 {
+		t.Offered = float64(b.RequestParams.C) * 1000 / avg
+	}
+	return t
+}
+
+func (t *StressThink) merge(v *StressThink) {
+	t.Min, t.Max = v.Min, v.Max
+	t.Thinks += v.Thinks
+	t.Total += v.Total
+	t.Offered += v.Offered
+}
+
+// printThink Print pauses of the virtual users, and the offered rate against the achieved rate
+func (result *StressResult) printThink(w io.Writer) {
+	t := result.Think
+	if t.Min == t.Max {
+		fprintln(w, "\nThink time (%v):", time.Duration(t.Min)*time.Millisecond)
+	} else {
+		fprintln(w, "\nThink time (random %v-%v):", time.Duration(t.Min)*time.Millisecond, time.Duration(t.Max)*time.Millisecond)
+	}
+	if t.Thinks > 0 {
+		fprintln(w, "  Paused:\t%d times, avg %v", t.Thinks, (time.Duration(t.Total) * time.Millisecond / time.Duration(t.Thinks)).Round(time.Millisecond))
+	}
+	achieved := float64(result.Rps) / scaleNum
+	if t.Offered > 0 {
+		fprintln(w, "  Offered:\t%.1f req/s, the connections with instant responses", t.Offered)
+		fprintln(w, "  Achieved:\t%.1f req/s (%.2f%% of offered)", achieved, achieved*100/t.Offered)
+	} else {
+		fprintln(w, "  Achieved:\t%.1f req/s", achieved)
+	}
+}
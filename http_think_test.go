@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseThinkRange(t *testing.T) {
+	for _, v := range []struct {
+		s        string
+		min, max time.Duration
+		err      bool
+	}{
+		{s: "100ms-500ms", min: 100 * time.Millisecond, max: 500 * time.Millisecond},
+		{s: "1s - 2s", min: time.Second, max: 2 * time.Second},
+		{s: "200ms-200ms", min: 200 * time.Millisecond, max: 200 * time.Millisecond},
+		{s: "500ms-100ms", err: true},
+		{s: "100ms", err: true},
+		{s: "a-1s", err: true},
+		{s: "1s-b", err: true},
+	} {
+		min, max, err := parseThinkRange(v.s)
+		if (err != nil) != v.err || min != v.min || max != v.max {
+			t.Errorf("parseThinkRange(%q) = %v, %v, %v, expect %v, %v, error %v", v.s, min, max, err, v.min, v.max, v.err)
+		}
+	}
+}
+
+func TestStressThink(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	start := time.Now()
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL,
+		ThinkTime:     20,
+		ThinkTimeMax:  40,
+		C:             2,
+		N:             10,
+		Timeout:       3000,
+	})
+	elapsed := time.Since(start)
+	if result == nil || result.ErrTotal() > 0 {
+		t.Fatalf("result of think time = %+v", result)
+	}
+	// 6 requests of each connection with 5 pauses of 20ms at least
+	if elapsed < 100*time.Millisecond {
+		t.Errorf("elapsed with think time = %v, expect at least 100ms", elapsed)
+	}
+	th := result.Think
+	if th == nil || th.Min != 20 || th.Max != 40 || th.Thinks < 10 || th.Total < th.Thinks*20 || th.Offered != 2*1000/30.0 {
+		t.Fatalf("think = %+v", th)
+	}
+
+	var buf bytes.Buffer
+	(&StressResult{Rps: 50 * scaleNum, Think: &StressThink{Min: 100, Max: 500, Thinks: 4, Total: 1200, Offered: 100}}).printThink(&buf)
+	for _, line := range []string{"Think time (random 100ms-500ms):", "  Paused:\t4 times, avg 300ms",
+		"  Offered:\t100.0 req/s", "  Achieved:\t50.0 req/s (50.00% of offered)"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printThink expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+const nearMissRatio = 0.8 // successful responses slower than the ratio of timeout are near misses
+
+// isTimeout the request is aborted by timeout or context deadline
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// markTimeout record elapsed time of timed out request capped at the timeout, and mark near misses
+func (b *StressWorker) markTimeout(res *result) {
+	timeout := time.Duration(b.RequestParams.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		return
+	}
+	switch {
+	case res.err != nil && isTimeout(res.err):
+		res.timeout = true
+		if res.duration > timeout {
+			res.duration = timeout
+		}
+	case res.err == nil && res.duration >= time.Duration(float64(timeout)*nearMissRatio):
+		res.nearMiss = true
+	}
+}
+
+// printTimeouts Print elapsed time distribution of timed out requests and near misses
+func (result *StressResult) printTimeouts(w io.Writer) {
+	fprintln(w, "\nTimeout distribution:")
+	if result.TimeoutTotal > 0 {
+		data := latsPercentiles(result.TimeoutLats, result.TimeoutTotal, pctls)
+		fprintln(w, "  %d requests timed out, elapsed:", result.TimeoutTotal)
+		for i := 0; i < len(pctls); i++ {
+			fprintln(w, "  %v%% in %4.3f secs", pctls[i], data[i])
+		}
+	}
+	if result.NearMissTotal > 0 {
+		fprintln(w, "  Near miss:\t%d responses slower than %d%% of timeout", result.NearMissTotal, int(nearMissRatio*100))
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTimeoutAccounting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ms, _ := strconv.Atoi(r.URL.Query().Get("sleep"))
+		time.Sleep(time.Duration(ms) * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	stats := GetStressResult()
+	for _, sleep := range []string{"0", "170", "400"} {
+		b := &StressWorker{RequestParams: &StressParameters{RequestType: typeHttp1, RequestMethod: "GET",
+			Url: srv.URL + "/?sleep=" + sleep, Timeout: 200}}
+		b.prepare()
+		client := b.newClient(0)
+		res := &result{start: time.Now()}
+		b.doClient(client, res)
+		res.duration = time.Since(res.start)
+		b.markTimeout(res)
+		stats.append(res)
+		b.closeClient(client)
+	}
+
+	if stats.TimeoutTotal != 1 || stats.TimeoutLats["0.200"] != 1 {
+		t.Errorf("timeout = %d, lats: %v, expect 1 capped at 0.200", stats.TimeoutTotal, stats.TimeoutLats)
+	}
+	if stats.NearMissTotal != 1 {
+		t.Errorf("near miss = %d, expect: 1", stats.NearMissTotal)
+	}
+	if merged := calMutliStressResult(nil, *stats, *stats); merged.TimeoutTotal != 2 || merged.TimeoutLats["0.200"] != 2 {
+		t.Errorf("merged timeout = %d, lats: %v", merged.TimeoutTotal, merged.TimeoutLats)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	timingDNS      = "dns"      // lookup of the host, only of new connections to a host name
+	timingConnect  = "connect"  // tcp connect, only of new connections
+	timingTLS      = "tls"      // tls handshake, only of new tls connections
+	timingTTFB     = "ttfb"     // from the start of the request to the first response byte
+	timingTransfer = "transfer" // from the first response byte to the end of the body
+)
+
+// timingPhases phases of the latency in the order of a request
+var timingPhases = []struct{ name, title, unit string }{
+	{timingDNS, "DNS", "lookups"},
+	{timingConnect, "Connect", "connections"},
+	{timingTLS, "TLS", "handshakes"},
+	{timingTTFB, "TTFB", "responses"},
+	{timingTransfer, "Transfer", "responses"},
+}
+
+// timingRow percentiles of a phase in the summary and html report
+type timingRow struct {
+	Title, Unit   string
+	Total         int64
+	P50, P90, P99 float64 // secs
+}
+
+// timingTrace time of the phases of a request, zero if the phase did not happen, e.g. a reused connection,
+// the durations are atomic as the transport may still be dialing when the request returns
+type timingTrace struct {
+	start, dnsStart, connectStart, tlsStart time.Time
+	dns, connect, tls, ttfb                 int64
+	conn, idle                              int32 // connNew or connReused, and the reused connection was idle
+}
+
+// traceTiming trace the dns, connect, tls and first byte of the request
+func traceTiming(req *http.Request) (*http.Request, *timingTrace) {
+	trace := &timingTrace{start: time.Now()}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { trace.dnsStart = time.Now() },
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if info.Err == nil {
+				atomic.StoreInt64(&trace.dns, int64(time.Since(trace.dnsStart)))
+			}
+		},
+		ConnectStart: func(string, string) { trace.connectStart = time.Now() },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				atomic.StoreInt64(&trace.connect, int64(time.Since(trace.connectStart)))
+			}
+		},
+		TLSHandshakeStart: func() { trace.tlsStart = time.Now() },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				atomic.StoreInt64(&trace.tls, int64(time.Since(trace.tlsStart)))
+			}
+		},
+		GotConn:              trace.gotConn,
+		GotFirstResponseByte: func() { atomic.StoreInt64(&trace.ttfb, int64(time.Since(trace.start))) },
+	})), trace
+}
+
+// record durations of the phases into the result of the request after the body is read, in the order
+// of timingPhases
+func (trace *timingTrace) record(res *result) {
+	for i, d := range []*int64{&trace.dns, &trace.connect, &trace.tls, &trace.ttfb} {
+		res.timings[i] = time.Duration(atomic.LoadInt64(d))
+	}
+	if ttfb := res.timings[3]; ttfb > 0 {
+		res.timings[4] = time.Since(trace.start) - ttfb
+	}
+}
+
+// appendTimings add durations of the phases of the request to the result
+func (result *StressResult) appendTimings(res *result) {
+	for i, phase := range timingPhases {
+		if res.timings[i] <= 0 {
+			continue
+		}
+		point := result.TimingDist[phase.name]
+		if point == nil {
+			point = &StressPoint{Lats: make(map[string]int64, 0)}
+			result.TimingDist[phase.name] = point
+		}
+		point.Lats[latsKey(res.timings[i], result.digits)]++
+		point.LatsTotal++
+	}
+}
+
+// parseResolve parse -resolve host:port:addr like curl, e.g. example.com:443:10.0.0.1 or
+// example.com:443:[::1], return host:port and the address to dial instead
+func parseResolve(s string) (string, string, error) {
+	fields := strings.SplitN(s, ":", 3)
+	if len(fields) != 3 || fields[0] == "" || fields[1] == "" {
+		return "", "", fmt.Errorf("invalid -resolve %s, expect host:port:addr", s)
+	}
+	ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(fields[2], "["), "]"))
+	if ip == nil {
+		return "", "", fmt.Errorf("invalid address of -resolve %s", s)
+	}
+	return net.JoinHostPort(fields[0], fields[1]), net.JoinHostPort(ip.String(), fields[1]), nil
+}
+
+// parseResolves addresses to dial of host:port pinned by -resolve
+func parseResolves(list []string) (map[string]string, error) {
+	if len(list) <= 0 {
+		return nil, nil
+	}
+	resolves := make(map[string]string, len(list))
+	for _, s := range list {
+		hostport, addr, err := parseResolve(s)
+		if err != nil {
+			return nil, err
+		}
+		resolves[hostport] = addr
+	}
+	return resolves, nil
+}
+
+// timingRows percentiles of the phases which happened, in the order of timingPhases
+func (result *StressResult) timingRows() []timingRow {
+	var rows []timingRow
+	for _, phase := range timingPhases {
+		p := result.TimingDist[phase.name]
+		if p == nil || p.LatsTotal <= 0 {
+			continue
+		}
+		data := latsPercentiles(p.Lats, p.LatsTotal, []int{50, 90, 99})
+		rows = append(rows, timingRow{Title: phase.title, Unit: phase.unit, Total: p.LatsTotal,
+			P50: data[0], P90: data[1], P99: data[2]})
+	}
+	return rows
+}
+
+// printTimings Print latency of each phase of requests, the phases of connections only happen for
+// new connections
+func (result *StressResult) printTimings(w io.Writer) {
+	fprintln(w, "\nLatency phases:")
+	for _, row := range result.timingRows() {
+		fprintln(w, "  %s:\t%d %s, p50 %4.4f secs, p90 %4.4f secs, p99 %4.4f secs", row.Title, row.Total, row.Unit,
+			row.P50, row.P90, row.P99)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseResolve(t *testing.T) {
+	for s, expect := range map[string][2]string{
+		"example.com:443:10.0.0.1": {"example.com:443", "10.0.0.1:443"},
+		"example.com:80:[::1]":     {"example.com:80", "[::1]:80"},
+		"example.com:80:::1":       {"example.com:80", "[::1]:80"},
+		"example.com:443":          {},
+		"example.com:443:host":     {},
+		":443:10.0.0.1":            {},
+	} {
+		hostport, addr, err := parseResolve(s)
+		if (expect[0] == "") != (err != nil) || hostport != expect[0] || addr != expect[1] {
+			t.Errorf("parseResolve(%s) = %s, %s, %v, expect: %v", s, hostport, addr, err, expect)
+		}
+	}
+}
+
+func TestTimingPhases(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Millisecond)
+		w.Write([]byte(r.Host))
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	for _, requestType := range []string{typeHttp1, typeHttp2} {
+		_, result := executeStress(StressParameters{
+			SequenceId:    time.Now().UnixNano(),
+			Cmd:           cmdStart,
+			RequestType:   requestType,
+			RequestMethod: "GET",
+			Url:           "https://pinned.test:" + port + "/",
+			C:             1,
+			N:             4,
+			Timeout:       3000,
+			Resolve:       []string{"pinned.test:" + port + ":127.0.0.1"},
+		})
+		if result == nil || len(result.ErrorDist) > 0 {
+			t.Fatalf("result of %s = %+v", requestType, result)
+		}
+		timings := result.TimingDist
+		if timings[timingDNS] != nil || timings[timingConnect] == nil || timings[timingConnect].LatsTotal != 1 ||
+			timings[timingTLS] == nil || timings[timingTLS].LatsTotal != 1 || timings[timingTTFB] == nil ||
+			timings[timingTTFB].LatsTotal != result.LatsTotal || timings[timingTransfer] == nil ||
+			timings[timingTransfer].LatsTotal != result.LatsTotal {
+			t.Errorf("timings of %s = %+v", requestType, timings)
+		}
+
+		var buf bytes.Buffer
+		result.printTimings(&buf)
+		for _, line := range []string{"Latency phases:", "  Connect:\t1 connections", "  TLS:\t1 handshakes", "  TTFB:\t",
+			"  Transfer:\t"} {
+			if !strings.Contains(buf.String(), line) {
+				t.Errorf("printTimings of %s expect %q, got:\n%s", requestType, line, buf.String())
+			}
+		}
+		buf.Reset()
+		if err := result.printHtml(&buf); err != nil || !strings.Contains(buf.String(), "<h2>Latency phases</h2>") ||
+			!strings.Contains(buf.String(), "<td>Transfer</td>") {
+			t.Errorf("html report of %s expect latency phases, err: %v", requestType, err)
+		}
+	}
+
+	_, result := executeStress(StressParameters{
+		SequenceId:     time.Now().UnixNano(),
+		Cmd:            cmdStart,
+		RequestType:    typeHttp1,
+		RequestMethod:  "GET",
+		Url:            "https://localhost:" + port + "/",
+		C:              1,
+		N:              4,
+		Timeout:        3000,
+		DnsEachRequest: true,
+	})
+	if result == nil || len(result.ErrorDist) > 0 {
+		t.Fatalf("result of dns each request = %+v", result)
+	}
+	if dns := result.TimingDist[timingDNS]; dns == nil || dns.LatsTotal != result.LatsTotal ||
+		result.TimingDist[timingTLS].LatsTotal != result.LatsTotal {
+		t.Errorf("timings of dns each request = %+v, responses: %d", result.TimingDist, result.LatsTotal)
+	}
+}
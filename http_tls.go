@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const tlsExpireWarnDays = 30 // warn when certificate expires soon
+
+var errCertRevoked = errors.New("certificate revoked by stapled OCSP")
+
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLSv1.0",
+	tls.VersionTLS11: "TLSv1.1",
+	tls.VersionTLS12: "TLSv1.2",
+	tls.VersionTLS13: "TLSv1.3",
+}
+
+// StressTls negotiated tls and server certificate chain of host
+type StressTls struct {
+	Host    string       `json:"host"`
+	Version string       `json:"version"`
+	Cipher  string       `json:"cipher"`
+	Proto   string       `json:"proto"` // negotiated ALPN protocol
+	Chain   []StressCert `json:"chain"`
+}
+
+// StressCert certificate in chain
+type StressCert struct {
+	Subject  string `json:"subject"`
+	Issuer   string `json:"issuer"`
+	NotAfter int64  `json:"not_after"` // unix seconds
+	KeyType  string `json:"key_type"`
+}
+
+func newStressTls(host string, state *tls.ConnectionState) *StressTls {
+	info := &StressTls{
+		Host:    host,
+		Version: tlsVersions[state.Version],
+		Cipher:  tls.CipherSuiteName(state.CipherSuite),
+		Proto:   state.NegotiatedProtocol,
+	}
+	if info.Version == "" {
+		info.Version = fmt.Sprintf("0x%04x", state.Version)
+	}
+	for _, cert := range state.PeerCertificates {
+		info.Chain = append(info.Chain, StressCert{
+			Subject:  cert.Subject.String(),
+			Issuer:   cert.Issuer.String(),
+			NotAfter: cert.NotAfter.Unix(),
+			KeyType:  certKeyType(cert),
+		})
+	}
+	return info
+}
+
+func certKeyType(cert *x509.Certificate) string {
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", pub.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + pub.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return cert.PublicKeyAlgorithm.String()
+}
+
+// captureTls capture tls of host only once
+func (b *StressWorker) captureTls(host string, state *tls.ConnectionState) *StressTls {
+	if _, loaded := b.tlsHosts.LoadOrStore(host, true); loaded {
+		return nil
+	}
+	info := newStressTls(host, state)
+	eprintln("tls %s: %s %s %s", host, info.Version, info.Cipher, info.Proto)
+	return info
+}
+
+// printTls Print tls and certificate chain of hosts, and warn the certificates expire soon
+func (result *StressResult) printTls(w io.Writer) {
+	fprintln(w, "\nTLS:")
+	var warnings []string
+	for host, info := range result.TlsInfo {
+		fprintln(w, "  [%s]\t%s %s %s", host, info.Version, info.Cipher, info.Proto)
+		for i, cert := range info.Chain {
+			notAfter := time.Unix(cert.NotAfter, 0)
+			days := int(time.Until(notAfter).Hours() / 24)
+			fprintln(w, "    %d: %s, issuer: %s, %s, expires: %s (%d days)",
+				i, cert.Subject, cert.Issuer, cert.KeyType, notAfter.Format("2006-01-02"), days)
+			if days < tlsExpireWarnDays {
+				warnings = append(warnings, fmt.Sprintf("certificate %s of %s expires in %d days", cert.Subject, host, days))
+			}
+		}
+	}
+	for _, warning := range warnings {
+		fprintln(w, "  Warning: %s", warning)
+	}
+}
+
+// tlsConfig client tls config of -tls-min-version, -tls-ciphers and -sni, verify certificates and
+// stapled OCSP with -tls-verify or -insecure=false
+func (b *StressWorker) tlsConfig() *tls.Config {
+	p := b.RequestParams
+	cfg := &tls.Config{
+		MinVersion:   p.TlsMinVersion,
+		CipherSuites: p.TlsCiphers,
+		ServerName:   p.TlsServerName,
+	}
+	if !p.TlsVerify {
+		cfg.InsecureSkipVerify = true
+	} else {
+		cfg.VerifyConnection = verifyStapledOCSP
+	}
+	return cfg
+}
+
+// parseTlsVersion parse version of -tls-min-version, e.g. 1.2 or TLSv1.2
+func parseTlsVersion(s string) (uint16, error) {
+	name := strings.TrimSpace(s)
+	if !strings.HasPrefix(strings.ToUpper(name), "TLS") {
+		name = "TLSv" + name
+	}
+	for version, v := range tlsVersions {
+		if strings.EqualFold(v, name) {
+			return version, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown tls version %s, expect 1.0, 1.1, 1.2 or 1.3", s)
+}
+
+// parseTlsCiphers parse comma separated cipher suite names of -tls-ciphers, e.g.
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256
+func parseTlsCiphers(s string) ([]uint16, error) {
+	suites := make(map[string]uint16)
+	for _, c := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		suites[c.Name] = c.ID
+	}
+	var ids []uint16
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.ToUpper(strings.TrimSpace(name)); name == "" {
+			continue
+		}
+		id, ok := suites[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown tls cipher suite %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// verifyStapledOCSP fail the handshake when the stapled OCSP response revokes the certificate
+func verifyStapledOCSP(cs tls.ConnectionState) error {
+	if len(cs.OCSPResponse) == 0 || len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) < 2 {
+		return nil
+	}
+	resp, err := ocsp.ParseResponseForCert(cs.OCSPResponse, cs.VerifiedChains[0][0], cs.VerifiedChains[0][1])
+	if err != nil {
+		verbosePrint(vDEBUG, "parse stapled OCSP err: %v", err)
+		return nil
+	}
+	if resp.Status == ocsp.Revoked {
+		return errCertRevoked
+	}
+	return nil
+}
+
+// classifyTlsError category of certificate verification error, so that it is counted separately
+func classifyTlsError(err error) (string, bool) {
+	var (
+		invalidErr  x509.CertificateInvalidError
+		hostnameErr x509.HostnameError
+		unknownErr  x509.UnknownAuthorityError
+	)
+	switch {
+	case errors.Is(err, errCertRevoked):
+		return "revoked (stapled OCSP)", true
+	case errors.As(err, &hostnameErr):
+		return "hostname mismatch", true
+	case errors.As(err, &unknownErr):
+		return "unknown CA", true
+	case errors.As(err, &invalidErr):
+		if invalidErr.Reason == x509.Expired {
+			return "expired", true
+		}
+		return "invalid certificate", true
+	}
+	return "", false
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestCaptureTls(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	b := &StressWorker{}
+	info := b.captureTls("127.0.0.1", resp.TLS)
+	if info == nil || info.Host != "127.0.0.1" || !strings.HasPrefix(info.Version, "TLSv1.") || info.Cipher == "" {
+		t.Fatalf("captureTls = %+v", info)
+	}
+	if len(info.Chain) != 1 || info.Chain[0].KeyType != "RSA 2048" || info.Chain[0].NotAfter <= 0 {
+		t.Errorf("captureTls chain = %+v", info.Chain)
+	}
+	if again := b.captureTls("127.0.0.1", resp.TLS); again != nil {
+		t.Errorf("captureTls the same host again = %+v, expect nil", again)
+	}
+}
+
+func TestClassifyTlsError(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	b := &StressWorker{RequestParams: &StressParameters{TlsVerify: true}}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: b.tlsConfig()}}
+	_, err := client.Get(srv.URL)
+	if category, ok := classifyTlsError(err); !ok || category != "unknown CA" {
+		t.Errorf("classifyTlsError(%v) = %s, expect: unknown CA", err, category)
+	}
+
+	tlsConfig := b.tlsConfig()
+	tlsConfig.RootCAs = x509.NewCertPool()
+	tlsConfig.RootCAs.AddCert(srv.Certificate())
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	_, err = client.Get(strings.Replace(srv.URL, "127.0.0.1", "localhost", 1))
+	if category, ok := classifyTlsError(err); !ok || category != "hostname mismatch" {
+		t.Errorf("classifyTlsError(%v) = %s, expect: hostname mismatch", err, category)
+	}
+
+	err = &url.Error{Op: "Get", URL: srv.URL, Err: x509.CertificateInvalidError{Reason: x509.Expired}}
+	if category, ok := classifyTlsError(err); !ok || category != "expired" {
+		t.Errorf("classifyTlsError(%v) = %s, expect: expired", err, category)
+	}
+	if _, ok := classifyTlsError(errors.New("connection refused")); ok {
+		t.Errorf("classifyTlsError not tls error expect false")
+	}
+}
+
+func TestVerifyStapledOCSP(t *testing.T) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDer, _ := x509.CreateCertificate(rand.Reader, caTpl, caTpl, &caKey.PublicKey, caKey)
+	ca, _ := x509.ParseCertificate(caDer)
+
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafTpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	leafDer, _ := x509.CreateCertificate(rand.Reader, leafTpl, ca, &leafKey.PublicKey, caKey)
+	leaf, _ := x509.ParseCertificate(leafDer)
+
+	for _, status := range []int{ocsp.Good, ocsp.Revoked} {
+		staple, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+			Status:       status,
+			SerialNumber: leaf.SerialNumber,
+			ThisUpdate:   time.Now(),
+			RevokedAt:    time.Now(),
+		}, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = verifyStapledOCSP(tls.ConnectionState{OCSPResponse: staple, VerifiedChains: [][]*x509.Certificate{{leaf, ca}}})
+		if (status == ocsp.Revoked) != errors.Is(err, errCertRevoked) {
+			t.Errorf("verifyStapledOCSP status %d err: %v", status, err)
+		}
+	}
+}
+
+func TestTlsOptions(t *testing.T) {
+	var serverName string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverName = r.TLS.ServerName
+	}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	defer srv.Close()
+
+	ciphers, err := parseTlsCiphers("tls_ecdhe_rsa_with_chacha20_poly1305_sha256, ")
+	if err != nil || len(ciphers) != 1 {
+		t.Fatalf("parseTlsCiphers = %v, %v", ciphers, err)
+	}
+	b := &StressWorker{RequestParams: &StressParameters{TlsCiphers: ciphers, TlsServerName: "api.example.com"}}
+	resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: b.tlsConfig()}}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.TLS.CipherSuite != tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256 || serverName != "api.example.com" {
+		t.Errorf("cipher = %s, server name = %s", tls.CipherSuiteName(resp.TLS.CipherSuite), serverName)
+	}
+
+	version, err := parseTlsVersion("TLSv1.3")
+	if err != nil || version != tls.VersionTLS13 {
+		t.Fatalf("parseTlsVersion = %x, %v", version, err)
+	}
+	b.RequestParams = &StressParameters{TlsMinVersion: version}
+	if _, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: b.tlsConfig()}}).Get(srv.URL); err == nil {
+		t.Errorf("TLS 1.3 client to TLS 1.2 server expect handshake error")
+	}
+
+	if v, err := parseTlsVersion("1.2"); err != nil || v != tls.VersionTLS12 {
+		t.Errorf("parseTlsVersion(1.2) = %x, %v", v, err)
+	}
+	if _, err := parseTlsVersion("1.4"); err == nil {
+		t.Errorf("parseTlsVersion(1.4) expect error")
+	}
+	if _, err := parseTlsCiphers("TLS_AES_128_GCM_SHA256,NO_SUCH_CIPHER"); err == nil || !strings.Contains(err.Error(), "NO_SUCH_CIPHER") {
+		t.Errorf("parseTlsCiphers err = %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+const udpMaxDatagram = 65535 // max size of an udp datagram
+
+var errUdpTimeout = fmt.Errorf("udp response timeout: %w", os.ErrDeadlineExceeded)
+
+// StressUdp datagrams of -p udp
+type StressUdp struct {
+	Sent      int64 `json:"sent"`       // datagrams sent
+	BytesSent int64 `json:"bytes_sent"` // bytes of datagrams sent
+	Received  int64 `json:"received"`   // response datagrams received
+	BytesRecv int64 `json:"bytes_recv"` // bytes of response datagrams
+	Timeouts  int64 `json:"timeouts"`   // responses not received within the timeout
+}
+
+// udpConn connected udp socket, a request sends one datagram and reads one response datagram
+// unless -udp-response=false
+type udpConn struct {
+	conn       net.Conn
+	timeout    time.Duration
+	noResponse bool
+	buf        []byte
+}
+
+func DialUDP(addr string, timeout time.Duration, noResponse bool) (*udpConn, error) {
+	conn, err := (&net.Dialer{Timeout: timeout}).DialContext(context.Background(), "udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &udpConn{conn: conn, timeout: timeout, noResponse: noResponse}
+	if !noResponse {
+		c.buf = make([]byte, udpMaxDatagram)
+	}
+	return c, nil
+}
+
+// Do send the datagram and read the response datagram, return bytes sent and received
+func (c *udpConn) Do(body []byte) (int64, int64, error) {
+	if c.conn == nil {
+		return 0, 0, ErrInitTcpClient
+	}
+	sent, err := c.conn.Write(body)
+	if err != nil || c.noResponse {
+		return int64(sent), 0, err
+	}
+
+	if c.timeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+	n, err := c.conn.Read(c.buf)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = errUdpTimeout // the same error of all connections
+	}
+	return int64(sent), int64(n), err
+}
+
+func (c *udpConn) Close() error {
+	if c.conn == nil {
+		return ErrInitTcpClient
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (u *StressUdp) append(res *result) {
+	u.Sent++
+	u.BytesSent += res.udpSent
+	switch {
+	case res.err == nil && res.udpReply:
+		u.Received++
+		u.BytesRecv += res.contentLength
+	case errors.Is(res.err, errUdpTimeout):
+		u.Timeouts++
+	}
+}
+
+func (u *StressUdp) merge(v *StressUdp) {
+	u.Sent += v.Sent
+	u.BytesSent += v.BytesSent
+	u.Received += v.Received
+	u.BytesRecv += v.BytesRecv
+	u.Timeouts += v.Timeouts
+}
+
+// printUdp Print datagrams sent and received of -p udp
+func (result *StressResult) printUdp(w io.Writer) {
+	u := result.Udp
+	fprintln(w, "\nUDP:")
+	fprintln(w, "  Sent:\t%d datagrams, %s", u.Sent, toByteSizeStr(float64(u.BytesSent)))
+	if u.Received > 0 || u.Timeouts > 0 {
+		fprintln(w, "  Received:\t%d datagrams, %s", u.Received, toByteSizeStr(float64(u.BytesRecv)))
+		fprintln(w, "  Timeouts:\t%d (%.2f%% of sent)", u.Timeouts, float64(u.Timeouts)*100/float64(u.Sent))
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStressUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var datagrams int64
+	go func() {
+		buf := make([]byte, udpMaxDatagram)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if atomic.AddInt64(&datagrams, 1)%2 == 0 {
+				continue // dropped
+			}
+			conn.WriteTo(append([]byte("recv: "), buf[:n]...), addr)
+		}
+	}()
+
+	params := StressParameters{
+		SequenceId:  time.Now().UnixNano(),
+		Cmd:         cmdStart,
+		RequestType: typeUDP,
+		RequestBody: "ping",
+		Url:         conn.LocalAddr().String(),
+		C:           1,
+		N:           4,
+		Timeout:     200,
+	}
+	_, result := executeStress(params)
+	if result == nil || result.Udp == nil {
+		t.Fatalf("result of udp = %+v", result)
+	}
+	u := result.Udp
+	if n := atomic.LoadInt64(&datagrams); u.Sent != n || u.BytesSent != 4*n || u.Received != (n+1)/2 ||
+		u.BytesRecv != 10*u.Received || u.Timeouts != n/2 || int64(result.ErrorDist[errUdpTimeout.Error()]) != u.Timeouts {
+		t.Errorf("udp = %+v of %d datagrams, errors: %v", u, n, result.ErrorDist)
+	}
+	if result.TimeoutTotal != u.Timeouts {
+		t.Errorf("timeouts = %d, expect: %d", result.TimeoutTotal, u.Timeouts)
+	}
+
+	var buf bytes.Buffer
+	result.printUdp(&buf)
+	if !strings.Contains(buf.String(), "  Received:\t") || !strings.Contains(buf.String(), "  Timeouts:\t") {
+		t.Errorf("printUdp got:\n%s", buf.String())
+	}
+
+	atomic.StoreInt64(&datagrams, 0)
+	params.SequenceId, params.UdpNoResponse = time.Now().UnixNano(), true
+	_, result = executeStress(params)
+	if result == nil || result.Udp == nil || result.Udp.Sent < 4 || result.Udp.Received != 0 || len(result.ErrorDist) > 0 {
+		t.Errorf("result of udp without response = %+v", result)
+	}
+}
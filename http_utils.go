@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/hex"
+	"encoding/xml"
 	"errors"
 	"flag"
 	"fmt"
@@ -9,14 +12,29 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"net"
+	"net/http"
 	gourl "net/url"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"text/template"
+	"text/template/parse"
 	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// exit codes, see "Exit codes" of usage
+const (
+	exitOK             = 0
+	exitUsage          = 1 // invalid options
+	exitUnreachable    = 2 // no successful response, e.g. connection refused
+	exitAssertion      = 3 // SLO or assertion failure, e.g. statistically invalid result
+	exitCircuitBreaker = 4 // stopped by -abort-after-errors
+	exitInternal       = 5 // internal error, e.g. listen failure
 )
 
 func usageAndExit(msg string) {
@@ -25,7 +43,7 @@ func usageAndExit(msg string) {
 	}
 	flag.Usage()
 	fmt.Println("")
-	os.Exit(1)
+	os.Exit(exitUsage)
 }
 
 type flagSlice []string
@@ -68,8 +86,16 @@ const (
 	letterBytes    = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 	letterNumBytes = "0123456789"
 
-	httpContentTypeJSON = "application/json"
-	httpWorkerApiPath   = "/api"
+	httpContentTypeJSON    = "application/json"
+	httpWorkerApiPath      = "/api"
+	httpWorkerJobsPath     = "/api/jobs"
+	httpWorkerAnnotatePath = "/api/annotate"
+	httpWorkerSharePath    = "/api/share"
+	httpWorkerLivePath     = "/api/live"
+	httpWorkerHistoryPath  = "/api/history"
+	httpTestsPath          = "/api/tests"
+	httpSharePath          = "/share/"
+	httpMetricsPath        = "/metrics"
 )
 
 var (
@@ -80,24 +106,107 @@ var (
 )
 
 var (
-	fnSrc = rand.NewSource(time.Now().UnixNano()) // for functions
-	fnMap = template.FuncMap{
+	fnRandom = newRandomFns(rand.New(&lockedSource{src: rand.NewSource(time.Now().UnixNano())})) // shared by templates of fnMap
+	fnMap    = template.FuncMap{
 		"intSum":       intSum,
-		"random":       random,
-		"randomDate":   randomDate,
-		"randomString": randomString,
-		"randomNum":    randomNum,
+		"random":       fnRandom.random,
+		"randomDate":   fnRandom.randomDate,
+		"randomString": fnRandom.randomString,
+		"randomNum":    fnRandom.randomNum,
 		"date":         date,
-		"UUID":         uuid,
+		"UUID":         fnRandom.uuid,
 		"escape":       escape,
 		"getEnv":       getEnv,
 		"hexToString":  hexToString,
 		"stringToHex":  stringToHex,
 		"toString":     toString,
+		"fileLine":     fnRandom.fileLine,
+		"fileLineSeq":  newFileLineSeq(0, 1),
+		"sequence":     newSequence(0, 1),
+		"xmlEncode":    xmlEncode,
+		"xmlGet":       xmlGet,
+		"jsonGet":      jsonGet,
+		"randomIP":     randomIP,
+		"elapsed":      noElapsed,
+		"phase":        noPhase,
 	}
-	fnUUID = randomString(10)
+
+	fileLinesCache sync.Map // file name -> *fileLines
 )
 
+// workerFnMap template functions with per worker state, override fnMap for each worker,
+// feeds are partitioned by index of distributed workers, so that data never collides across machines,
+// and random data comes from the own source of the connection
+func workerFnMap(index, count int, r *rand.Rand) template.FuncMap {
+	fns := newRandomFns(r)
+	return template.FuncMap{
+		"fileLineSeq":  newFileLineSeq(index, count),
+		"random":       fns.random,
+		"randomDate":   fns.randomDate,
+		"randomString": fns.randomString,
+		"randomNum":    fns.randomNum,
+		"UUID":         fns.uuid,
+		"fileLine":     fns.fileLine,
+		"randomIP":     fns.randomIP,
+	}
+}
+
+// lockedSource rand source safe for concurrent use, for the shared functions of fnMap
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// randomFns random functions of templates with own rand source, so that connections don't contend
+// on a shared source, and the data of each connection is reproducible with -seed
+type randomFns struct {
+	r    *rand.Rand
+	uuid func() string
+}
+
+func newRandomFns(r *rand.Rand) *randomFns {
+	f := &randomFns{r: r}
+	id := f.randomString(10)
+	f.uuid = func() string { return id }
+	return f
+}
+
+// partitionRange rows [start, end) of n rows for worker index of count, at least one row
+func partitionRange(n, index, count int) (int, int) {
+	if count <= 1 {
+		return 0, n
+	}
+	start, end := index*n/count, (index+1)*n/count
+	if start >= end {
+		return index % n, index%n + 1 // fewer rows than workers
+	}
+	return start, end
+}
+
+// newSequence return function which return unique number across connections and distributed workers,
+// worker index of count gets index, index+count, index+2*count...
+func newSequence(index, count int) func() int64 {
+	var next int64 = -1
+	if count < 1 {
+		count = 1
+	}
+	return func() int64 {
+		return atomic.AddInt64(&next, 1)*int64(count) + int64(index)
+	}
+}
+
 // template functions
 func intSum(v ...int64) int64 {
 	var r int64
@@ -107,9 +216,8 @@ func intSum(v ...int64) int64 {
 	return r
 }
 
-func random(min, max int64) int64 {
-	rand.Seed(time.Now().UnixNano())
-	return rand.Int63n(max-min) + min
+func (f *randomFns) random(min, max int64) int64 {
+	return f.r.Int63n(max-min) + min
 }
 
 func formatTime(now time.Time, fmt string) string {
@@ -128,19 +236,19 @@ func date(fmt string) string {
 	return formatTime(time.Now(), fmt)
 }
 
-func randomDate(fmt string) string {
-	return formatTime(time.Unix(rand.Int63n(time.Now().Unix()-94608000)+94608000, 0), fmt)
+func (f *randomFns) randomDate(fmt string) string {
+	return formatTime(time.Unix(f.r.Int63n(time.Now().Unix()-94608000)+94608000, 0), fmt)
 }
 
 func escape(u string) string {
 	return gourl.QueryEscape(u)
 }
 
-func randomN(n int, letter string) string {
+func (f *randomFns) randomN(n int, letter string) string {
 	b := make([]byte, n)
-	for i, cache, remain := n-1, fnSrc.Int63(), letterIdxMax; i >= 0; {
+	for i, cache, remain := n-1, f.r.Int63(), letterIdxMax; i >= 0; {
 		if remain == 0 {
-			cache, remain = fnSrc.Int63(), letterIdxMax
+			cache, remain = f.r.Int63(), letterIdxMax
 		}
 		if idx := int(cache & letterIdxMask); idx < len(letter) {
 			b[i] = letter[idx]
@@ -152,16 +260,12 @@ func randomN(n int, letter string) string {
 	return string(b)
 }
 
-func randomString(n int) string {
-	return randomN(n, letterBytes)
-}
-
-func randomNum(n int) string {
-	return randomN(n, letterNumBytes)
+func (f *randomFns) randomString(n int) string {
+	return f.randomN(n, letterBytes)
 }
 
-func uuid() string {
-	return fnUUID
+func (f *randomFns) randomNum(n int) string {
+	return f.randomN(n, letterNumBytes)
 }
 
 func getEnv(key string) string {
@@ -182,6 +286,269 @@ func toString(args ...interface{}) string {
 	return fmt.Sprintf(`"%v"`, args...)
 }
 
+// cloneTemplate clone template and override functions, e.g. functions with per worker state
+func cloneTemplate(t *template.Template, funcs template.FuncMap) *template.Template {
+	if t == nil {
+		return nil
+	}
+	c, err := t.Clone()
+	if err != nil {
+		return t
+	}
+	return c.Funcs(funcs)
+}
+
+// isStaticTemplate check template only contains text without actions
+func isStaticTemplate(t *template.Template) bool {
+	if t.Tree == nil || t.Tree.Root == nil {
+		return true
+	}
+	for _, node := range t.Tree.Root.Nodes {
+		if node.Type() != parse.NodeText {
+			return false
+		}
+	}
+	return true
+}
+
+// requestContext data of request templates, e.g. {{ .URL }}, {{ .WorkerID }}
+type requestContext struct {
+	URL       string // rendered url, the raw url in url template
+	Method    string
+	WorkerID  int   // index of connection in worker
+	Iteration int64 // requests sent by the connection, starts from 0
+	Now       time.Time
+
+	WorkerIndex int // index of distributed worker, 0 when not distributed
+	WorkerCount int // number of distributed workers, 1 when not distributed
+
+	RequestId string            // unique request id of -dedup-header or -dedup-verify
+	Item      int               // index of item in the batch of -batch, 0 when not batched
+	Vars      map[string]string // variables extracted by the previous steps of -flow
+	Data      map[string]string // row of -data, e.g. {{ .Data.username }}
+}
+
+// sampleContext context to validate templates before running, Vars has the variables of -extract
+var sampleContext = &requestContext{URL: "http://127.0.0.1/", Method: "GET", Now: time.Now(), WorkerCount: 1, Vars: make(map[string]string)}
+
+// headerTemplate header values with template actions, rendered per request
+type headerTemplate struct {
+	key    string
+	values []*template.Template
+}
+
+// parseHeaderTemplates parse header values as templates, only return the headers with actions
+func parseHeaderTemplates(headers map[string][]string) ([]headerTemplate, error) {
+	var hts []headerTemplate
+	for key, values := range headers {
+		ht, isStatic := headerTemplate{key: key}, true
+		for _, v := range values {
+			tpl, err := template.New("HEADER-" + key).Funcs(fnMap).Parse(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid header %s template: %v", key, err)
+			}
+			isStatic = isStatic && isStaticTemplate(tpl)
+			ht.values = append(ht.values, tpl)
+		}
+		if !isStatic {
+			hts = append(hts, ht)
+		}
+	}
+	return hts, nil
+}
+
+// renderHeaders render header templates to a copy of headers
+func renderHeaders(headers http.Header, hts []headerTemplate, ctx *requestContext) http.Header {
+	headers = headers.Clone()
+	var value bytes.Buffer
+	for _, ht := range hts {
+		values := make([]string, 0, len(ht.values))
+		for _, tpl := range ht.values {
+			value.Reset()
+			tpl.Execute(&value, ctx)
+			values = append(values, value.String())
+		}
+		headers[ht.key] = values
+	}
+	return headers
+}
+
+// validateHeaderTemplates parse and execute header templates with sample context
+func validateHeaderTemplates(headers map[string][]string) []string {
+	hts, err := parseHeaderTemplates(headers)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var errs []string
+	for _, ht := range hts {
+		for _, tpl := range ht.values {
+			if err := tpl.Execute(io.Discard, sampleContext); err != nil {
+				errs = append(errs, fmt.Sprintf("invalid header %s template: %v", ht.key, err))
+			}
+		}
+	}
+	return errs
+}
+
+// xmlEncode escape string for xml text or attribute
+func xmlEncode(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// xmlGet return text of the first element matched path, e.g. "a/b/c",
+// and "a/b/@id" for attribute
+func xmlGet(xmlStr, path string) (string, error) {
+	var (
+		names   = strings.Split(strings.Trim(path, "/"), "/")
+		attr    string
+		stack   []string
+		text    strings.Builder
+		matched bool
+	)
+
+	if last := names[len(names)-1]; strings.HasPrefix(last, "@") {
+		attr, names = last[1:], names[:len(names)-1]
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(xmlStr))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+			if !matched && strings.Join(stack, "/") == strings.Join(names, "/") {
+				if attr != "" {
+					for _, a := range t.Attr {
+						if a.Name.Local == attr {
+							return a.Value, nil
+						}
+					}
+					continue
+				}
+				matched = true
+			}
+		case xml.CharData:
+			if matched {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if matched && len(stack) == len(names) {
+				return text.String(), nil
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// jsonGet return value of json path, e.g. "$.data.token", strings are unquoted and the others are json
+func jsonGet(jsonStr, path string) (string, error) {
+	steps, err := parseJsonPath(path)
+	if err != nil {
+		return "", err
+	}
+	v, ok := (&assertResponse{body: []byte(jsonStr)}).jsonValue(steps)
+	if !ok {
+		return "", fmt.Errorf("json path %s not found", path)
+	}
+	return v, nil
+}
+
+type fileLines struct {
+	once  sync.Once
+	lines []string
+	err   error
+}
+
+// loadFileLines load lines of file only once and shared by all workers
+func loadFileLines(fileName string) ([]string, error) {
+	v, _ := fileLinesCache.LoadOrStore(fileName, &fileLines{})
+	f := v.(*fileLines)
+	f.once.Do(func() {
+		if f.lines, f.err = parseFile(fileName, []rune{'\r', '\n'}); f.err == nil && len(f.lines) <= 0 {
+			f.err = fmt.Errorf("%s is empty", fileName)
+		}
+	})
+	return f.lines, f.err
+}
+
+// fileLine return a random line of file
+func (f *randomFns) fileLine(fileName string) (string, error) {
+	lines, err := loadFileLines(fileName)
+	if err != nil {
+		return "", err
+	}
+	return lines[f.r.Intn(len(lines))], nil
+}
+
+// newFileLineSeq return function which return lines of file sequentially, in the partition of worker index of count
+func newFileLineSeq(index, count int) func(string) (string, error) {
+	var (
+		mu   sync.Mutex
+		next = make(map[string]int, 0)
+	)
+	return func(fileName string) (string, error) {
+		lines, err := loadFileLines(fileName)
+		if err != nil {
+			return "", err
+		}
+		start, end := partitionRange(len(lines), index, count)
+		mu.Lock()
+		i := next[fileName]
+		next[fileName] = (i + 1) % (end - start)
+		mu.Unlock()
+		return lines[start+i], nil
+	}
+}
+
+// parseCidrs parse networks, default any IPv4 address
+func parseCidrs(cidrs []string) ([]*net.IPNet, error) {
+	if len(cidrs) <= 0 {
+		cidrs = []string{"0.0.0.0/0"}
+	}
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// randomIPIn random IP address in one of nets
+func randomIPIn(r *rand.Rand, nets []*net.IPNet) string {
+	n := nets[r.Intn(len(nets))]
+	ip := make(net.IP, len(n.IP))
+	for i := range ip {
+		ip[i] = n.IP[i] | (byte(r.Intn(256)) &^ n.Mask[i])
+	}
+	return ip.String()
+}
+
+// randomIP random IP address in networks, default networks of -spoof-cidr
+func randomIP(cidrs ...string) (string, error) {
+	return fnRandom.randomIP(cidrs...)
+}
+
+func (f *randomFns) randomIP(cidrs ...string) (string, error) {
+	nets, err := parseCidrs(cidrs)
+	if err != nil {
+		return "", err
+	}
+	return randomIPIn(f.r, nets), nil
+}
+
 func parseTime(timeStr string) int64 {
 	var multi int64 = 1
 	if timeStrLen := len(timeStr) - 1; timeStrLen > 0 {
@@ -198,13 +565,36 @@ func parseTime(timeStr string) int64 {
 	}
 
 	t, err := strconv.ParseInt(timeStr, 10, 64)
-	if err != nil || t <= 0 {
-		usageAndExit("Duration parse err: " + err.Error())
+	if err != nil || t < 0 {
+		usageAndExit("Duration parse err: invalid duration " + timeStr)
 	}
 
 	return multi * t
 }
 
+// parseSize parse size string, e.g. 100, 100B, 64KB, 1MB, 2GB
+func parseSize(sizeStr string) (int64, error) {
+	var multi int64 = 1
+	s := strings.ToUpper(strings.TrimSpace(sizeStr))
+	for _, unit := range []struct {
+		suffix string
+		multi  int64
+	}{
+		{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s, multi = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), unit.multi
+			break
+		}
+	}
+
+	size, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size: %s", sizeStr)
+	}
+	return size * multi, nil
+}
+
 type byteBlock struct {
 	block []byte
 	cap   int
@@ -249,6 +639,61 @@ func fastRead(r io.Reader, cycleRead bool) (int64, error) {
 	}
 }
 
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// readBody read response body at most maxRead(<=0 is unlimited) bytes on the wire, the decompressed
+// body is also written to capture if not nil, return decompressed size, on-the-wire size and whether
+// the body is truncated
+func readBody(resp *http.Response, maxRead int64, capture io.Writer) (int64, int64, bool) {
+	var (
+		n    int64
+		body io.Reader = resp.Body
+	)
+
+	if maxRead > 0 {
+		body = io.LimitReader(resp.Body, maxRead)
+	}
+
+	wire := &countReader{r: body}
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		if gz, err := gzip.NewReader(wire); err == nil {
+			n, _ = fastRead(teeReader(gz, capture), true)
+		}
+		fastRead(wire, true) // drain the rest of the wire
+	} else {
+		n, _ = fastRead(teeReader(wire, capture), true)
+		if n <= 0 && resp.ContentLength > 0 {
+			return resp.ContentLength, resp.ContentLength, false
+		}
+	}
+
+	truncated := false
+	if maxRead > 0 && wire.n >= maxRead {
+		var one [1]byte
+		if m, _ := resp.Body.Read(one[:]); m > 0 {
+			truncated = true
+		}
+	}
+	return n, wire.n, truncated
+}
+
+// teeReader r which also writes to w if not nil
+func teeReader(r io.Reader, w io.Writer) io.Reader {
+	if w == nil {
+		return r
+	}
+	return io.TeeReader(r, w)
+}
+
 func parseInputWithRegexp(input, regx string) ([]string, error) {
 	re := regexp.MustCompile(regx)
 	matches := re.FindStringSubmatch(input)
@@ -258,6 +703,40 @@ func parseInputWithRegexp(input, regx string) ([]string, error) {
 	return matches, nil
 }
 
+// parseHeaders parse "key: value" list into headers with canonical keys, repeated keys
+// append values unless replace, all invalid headers are reported at once
+func parseHeaders(headers map[string][]string, list []string, replace bool) error {
+	var errs []string
+	replaced := make(map[string]bool, 0)
+	for _, h := range list {
+		match, err := parseInputWithRegexp(h, headerRegexp)
+		if err != nil {
+			errs = append(errs, "invalid header: "+strconv.Quote(h))
+			continue
+		}
+		if !httpguts.ValidHeaderFieldName(match[1]) {
+			errs = append(errs, "invalid header name: "+strconv.Quote(match[1]))
+			continue
+		}
+		if !httpguts.ValidHeaderFieldValue(match[2]) {
+			errs = append(errs, "invalid header value: "+strconv.Quote(match[2]))
+			continue
+		}
+
+		key := http.CanonicalHeaderKey(match[1])
+		if replace && !replaced[key] {
+			headers[key] = nil
+			replaced[key] = true
+		}
+		headers[key] = append(headers[key], match[2])
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
 func parseFile(fileName string, delimiter []rune) ([]string, error) {
 	var contentList []string
 	file, err := os.Open(fileName)
@@ -294,57 +773,168 @@ func parseFile(fileName string, delimiter []rune) ([]string, error) {
 	return contentList, nil
 }
 
-type ConnOption struct {
-	timeout           time.Duration
-	disableKeepAlives bool
+// urlEntry request url with optional inline method, weight, headers and body, the format is:
+// [METHOD] URL [WEIGHT] [-H "Key: Value"]... [-body 'body']
+type urlEntry struct {
+	line    int
+	method  string
+	url     string
+	weight  int // weight of -url-strategy weighted, 0 is 1
+	headers []string
+	body    string
 }
 
-type tcpConn struct {
-	tcpClient net.Conn
-	uri       string
-	option    ConnOption
-}
+// splitFields split line by whitespace, but keep template actions and quoted strings, in double or single quotes
+func splitFields(line string) []string {
+	var (
+		fields  []string
+		field   strings.Builder
+		quote   byte // current quote char, 0 is not quoted
+		inFunc  bool
+		hasChar bool
+	)
 
-func DialTCP(uri string, option ConnOption) (*tcpConn, error) {
-	conn, err := net.Dial("tcp", uri)
-	if err != nil {
-		verbosePrint(vERROR, "DialTCP Dial err: %v", err)
-		return nil, err
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case quote == 0 && strings.HasPrefix(line[i:], "{{"):
+			inFunc = true
+		case inFunc && strings.HasPrefix(line[i:], "}}"):
+			inFunc = false
+			field.WriteString("}}")
+			i++
+			continue
+		case !inFunc && quote == 0 && (ch == '"' || ch == '\''):
+			quote = ch
+			hasChar = true
+			continue
+		case !inFunc && ch == quote:
+			quote = 0
+			continue
+		case !inFunc && quote == 0 && (ch == ' ' || ch == '\t'):
+			if hasChar {
+				fields = append(fields, field.String())
+				field.Reset()
+				hasChar = false
+			}
+			continue
+		}
+		field.WriteByte(ch)
+		hasChar = true
 	}
 
-	err = conn.SetDeadline(time.Now().Add(time.Millisecond * time.Duration(option.timeout)))
-	if err != nil {
-		verbosePrint(vERROR, "DialTCP SetDeadline err: %v", err)
-		return nil, err
+	if hasChar {
+		fields = append(fields, field.String())
 	}
+	return fields
+}
 
-	tcp := &tcpConn{
-		tcpClient: conn,
-		uri:       uri,
-		option:    option,
+func isMethod(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'A' || s[i] > 'Z' {
+			return false
+		}
 	}
-	return tcp, nil
+	return len(s) > 0
 }
 
-func (tcp *tcpConn) Do(body []byte) (int64, error) {
-	if tcp.tcpClient == nil {
-		return 0, ErrInitTcpClient
+func parseUrlEntry(line int, text string) (urlEntry, error) {
+	entry := urlEntry{line: line}
+	fields := splitFields(text)
+	if len(fields) > 1 && !strings.HasPrefix(fields[1], "-") && isMethod(fields[0]) {
+		entry.method, fields = fields[0], fields[1:]
+	}
+	if len(fields) <= 0 {
+		return entry, errors.New("empty url")
 	}
 
-	_, err := tcp.tcpClient.Write(body)
+	entry.url, fields = fields[0], fields[1:]
+	if len(fields) > 0 && !strings.HasPrefix(fields[0], "-") {
+		weight, err := strconv.Atoi(fields[0])
+		if err != nil || weight <= 0 {
+			return entry, fmt.Errorf("invalid weight %s, expect a positive integer after url", strconv.Quote(fields[0]))
+		}
+		entry.weight, fields = weight, fields[1:]
+	}
+	for i := 0; i < len(fields); i++ {
+		if (fields[i] != "-H" && fields[i] != "-body") || i+1 >= len(fields) {
+			return entry, fmt.Errorf("unexpected %s, only support -H \"Key: Value\" and -body after url", strconv.Quote(fields[i]))
+		}
+		if fields[i] == "-body" {
+			entry.body = fields[i+1]
+		} else {
+			entry.headers = append(entry.headers, fields[i+1])
+		}
+		i++
+	}
+	return entry, nil
+}
+
+// parseUrlFile parse url file with line number, return all invalid lines at once
+func parseUrlFile(fileName string) ([]urlEntry, []string, error) {
+	var (
+		entries []urlEntry
+		errs    []string
+	)
+
+	content, err := os.ReadFile(fileName)
 	if err != nil {
-		return 0, err
+		return nil, nil, err
 	}
 
-	return fastRead(tcp.tcpClient, false) // !tcp.option.disableKeepAlives
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) <= 0 {
+			continue
+		}
+		entry, err := parseUrlEntry(i+1, line)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s:%d: %s", fileName, i+1, err.Error()))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, errs, nil
 }
 
-func (tcp *tcpConn) Close() error {
-	if tcp.tcpClient == nil {
-		return ErrInitTcpClient
+// validateUrlEntry check method, url template and headers of entry before running
+func validateUrlEntry(entry urlEntry, requestType string) []string {
+	var errs []string
+
+	if entry.method != "" && !httpguts.ValidHeaderFieldName(entry.method) {
+		errs = append(errs, "invalid method: "+strconv.Quote(entry.method))
+	}
+
+	var urlBytes bytes.Buffer
+	if tpl, err := template.New("URL").Funcs(fnMap).Parse(entry.url); err != nil {
+		errs = append(errs, "invalid url template: "+err.Error())
+	} else if err := tpl.Execute(&urlBytes, sampleContext); err != nil {
+		errs = append(errs, "invalid url template: "+err.Error())
+	} else if requestType == typeTCP || requestType == typeUDP {
+		if _, _, err := net.SplitHostPort(urlBytes.String()); err != nil {
+			errs = append(errs, "invalid address: "+err.Error())
+		}
+	} else if u, err := gourl.Parse(urlBytes.String()); err != nil {
+		errs = append(errs, "invalid url: "+err.Error())
+	} else if u.Scheme == "" || u.Host == "" {
+		errs = append(errs, "invalid url: "+strconv.Quote(urlBytes.String())+" missing scheme or host")
+	}
+
+	headers := make(map[string][]string, 0)
+	if err := parseHeaders(headers, entry.headers, false); err != nil {
+		errs = append(errs, strings.Split(err.Error(), "\n")...)
+	} else {
+		errs = append(errs, validateHeaderTemplates(headers)...)
+	}
+
+	if entry.body != "" {
+		if tpl, err := template.New("BODY").Funcs(fnMap).Parse(entry.body); err != nil {
+			errs = append(errs, "invalid body template: "+err.Error())
+		} else if err := tpl.Execute(io.Discard, sampleContext); err != nil {
+			errs = append(errs, "invalid body template: "+err.Error())
+		}
 	}
 
-	err := tcp.tcpClient.Close()
-	tcp.tcpClient = nil
-	return err
+	return errs
 }
@@ -0,0 +1,26 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// processCPUTime isn't support on windows, js, wasip1 and plan9
+func processCPUTime() time.Duration {
+	return 0
+}
+
+// notifyStepSignal isn't support on windows, js, wasip1 and plan9
+func notifyStepSignal(c chan os.Signal) {}
+
+// fileLimit isn't support on windows, js, wasip1 and plan9
+func fileLimit() int64 {
+	return -1
+}
+
+// raiseFileLimit isn't support on windows, js, wasip1 and plan9
+func raiseFileLimit(n int64) int64 {
+	return -1
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestIsStaticTemplate(t *testing.T) {
+	for _, v := range []struct {
+		text     string
+		isStatic bool
+	}{
+		{text: "", isStatic: true},
+		{text: `{"a": 1, "b": "c"}`, isStatic: true},
+		{text: `{"a": 1{{/* comment */}}}`, isStatic: true},
+		{text: `{"a": {{ randomNum 10 }}}`, isStatic: false},
+		{text: `http://127.0.0.1/?data={{ UUID | escape }}`, isStatic: false},
+	} {
+		tpl, err := template.New("test").Funcs(fnMap).Parse(v.text)
+		if err != nil {
+			t.Fatalf("parse %s err: %v", v.text, err)
+		}
+		if isStatic := isStaticTemplate(tpl); isStatic != v.isStatic {
+			t.Errorf("isStaticTemplate(%s) = %v, expect: %v", v.text, isStatic, v.isStatic)
+		}
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers := make(map[string][]string, 0)
+	if err := parseHeaders(headers, []string{"x-k: a", "X-K: b", "Accept: text/html"}, false); err != nil {
+		t.Fatalf("parseHeaders err: %v", err)
+	}
+	if err := parseHeaders(headers, []string{"accept: application/json"}, true); err != nil {
+		t.Fatalf("parseHeaders replace err: %v", err)
+	}
+	if v := headers["X-K"]; len(v) != 2 || v[0] != "a" || v[1] != "b" {
+		t.Errorf("X-K = %v, expect: [a b]", v)
+	}
+	if v := headers["Accept"]; len(v) != 1 || v[0] != "application/json" {
+		t.Errorf("Accept = %v, expect: [application/json]", v)
+	}
+
+	err := parseHeaders(headers, []string{"X(K): a", "X-K: a\x00b", "invalid"}, false)
+	if err == nil || len(strings.Split(err.Error(), "\n")) != 3 {
+		t.Errorf("parseHeaders invalid err: %v, expect 3 errors", err)
+	}
+}
+
+func TestParseUrlEntry(t *testing.T) {
+	for _, v := range []struct {
+		line    string
+		method  string
+		url     string
+		weight  int
+		headers []string
+		body    string
+		isErr   bool
+	}{
+		{line: `http://127.0.0.1/todo?data={{ randomString 10 }}`, url: `http://127.0.0.1/todo?data={{ randomString 10 }}`},
+		{line: `http://127.0.0.1/?d={{ date "YMD" }}`, url: `http://127.0.0.1/?d={{ date "YMD" }}`},
+		{line: `POST http://127.0.0.1/ -H "X-K: a b" -H X-V:c`, method: "POST", url: "http://127.0.0.1/",
+			headers: []string{"X-K: a b", "X-V:c"}},
+		{line: `POST http://127.0.0.1/ -body '{"id": {{ randomNum 3 }}}'`, method: "POST", url: "http://127.0.0.1/",
+			body: `{"id": {{ randomNum 3 }}}`},
+		{line: `http://127.0.0.1/a 5`, url: "http://127.0.0.1/a", weight: 5},
+		{line: `POST http://127.0.0.1/ 2 -H X-V:c`, method: "POST", url: "http://127.0.0.1/", weight: 2, headers: []string{"X-V:c"}},
+		{line: `http://127.0.0.1/ extra`, isErr: true},
+		{line: `http://127.0.0.1/ 0`, isErr: true},
+		{line: `http://127.0.0.1/ -H`, isErr: true},
+	} {
+		entry, err := parseUrlEntry(1, v.line)
+		if (err != nil) != v.isErr {
+			t.Fatalf("parseUrlEntry(%s) err: %v, expect err: %v", v.line, err, v.isErr)
+		}
+		if v.isErr {
+			continue
+		}
+		if entry.method != v.method || entry.url != v.url || entry.weight != v.weight || strings.Join(entry.headers, "|") != strings.Join(v.headers, "|") ||
+			entry.body != v.body {
+			t.Errorf("parseUrlEntry(%s) = %+v", v.line, entry)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for _, v := range []struct {
+		size  string
+		value int64
+		isErr bool
+	}{
+		{size: "100", value: 100},
+		{size: "100B", value: 100},
+		{size: "64KB", value: 64 << 10},
+		{size: "1mb", value: 1 << 20},
+		{size: "2G", value: 2 << 30},
+		{size: "-1KB", isErr: true},
+		{size: "KB", isErr: true},
+	} {
+		value, err := parseSize(v.size)
+		if (err != nil) != v.isErr || value != v.value {
+			t.Errorf("parseSize(%s) = %d, %v, expect: %d", v.size, value, err, v.value)
+		}
+	}
+}
+
+func TestFileLineSeq(t *testing.T) {
+	fileName := t.TempDir() + "/tokens.txt"
+	if err := os.WriteFile(fileName, []byte("a\r\nb\n\nc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	seq1, seq2 := newFileLineSeq(0, 1), newFileLineSeq(0, 1)
+	for i, expect := range []string{"a", "b", "c", "a"} {
+		if line, err := seq1(fileName); err != nil || line != expect {
+			t.Errorf("fileLineSeq %d = %s, %v, expect: %s", i, line, err, expect)
+		}
+	}
+	if line, _ := seq2(fileName); line != "a" {
+		t.Errorf("fileLineSeq of another worker = %s, expect: a", line)
+	}
+	for index, expect := range []string{"a", "a", "b", "c"} { // 3 lines of 4 workers, collided
+		if line, _ := newFileLineSeq(index, 4)(fileName); line != expect {
+			t.Errorf("fileLineSeq of worker %d/4 = %s, expect: %s", index, line, expect)
+		}
+	}
+	seq3 := newFileLineSeq(1, 2) // lines [1, 3)
+	for i, expect := range []string{"b", "c", "b"} {
+		if line, _ := seq3(fileName); line != expect {
+			t.Errorf("fileLineSeq %d of worker 1/2 = %s, expect: %s", i, line, expect)
+		}
+	}
+	if _, err := fnRandom.fileLine(fileName + ".none"); err == nil {
+		t.Errorf("fileLine of not exist file expect err")
+	}
+}
+
+func TestSequence(t *testing.T) {
+	seen := make(map[int64]bool, 0)
+	for index := 0; index < 3; index++ {
+		seq := newSequence(index, 3)
+		for i := 0; i < 10; i++ {
+			v := seq()
+			if seen[v] {
+				t.Fatalf("sequence of worker %d/3 = %d, collided", index, v)
+			}
+			seen[v] = true
+		}
+	}
+
+	params, _ := json.Marshal(StressParameters{WorkerIndex: 1, WorkerCount: 2})
+	var sub StressParameters
+	json.Unmarshal(workerParams(params, 2, 3), &sub)
+	if sub.WorkerIndex != 5 || sub.WorkerCount != 6 {
+		t.Errorf("workerParams of worker 2/3 of worker 1/2 = %d/%d, expect: 5/6", sub.WorkerIndex, sub.WorkerCount)
+	}
+}
+
+func TestRandomIP(t *testing.T) {
+	nets, err := parseCidrs([]string{"10.1.0.0/16", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		ip := net.ParseIP(randomIPIn(r, nets))
+		if ip == nil || (!nets[0].Contains(ip) && !nets[1].Contains(ip)) {
+			t.Fatalf("randomIPIn = %v, expect in %v", ip, nets)
+		}
+	}
+
+	if v, err := randomIP(); err != nil || net.ParseIP(v).To4() == nil {
+		t.Errorf("randomIP() = %s, %v, expect IPv4", v, err)
+	}
+	if _, err := parseCidrs([]string{"10.0.0.0/33"}); err == nil {
+		t.Errorf("parseCidrs(10.0.0.0/33) expect err")
+	}
+}
+
+func TestXmlFunctions(t *testing.T) {
+	if v := xmlEncode(`<a href="x">&</a>`); v != "&lt;a href=&#34;x&#34;&gt;&amp;&lt;/a&gt;" {
+		t.Errorf("xmlEncode = %s", v)
+	}
+
+	doc := `<resp><code>0</code><data id="7"><token>abc</token></data></resp>`
+	for _, v := range []struct {
+		path   string
+		expect string
+	}{
+		{path: "resp/code", expect: "0"},
+		{path: "/resp/data/token", expect: "abc"},
+		{path: "resp/data/@id", expect: "7"},
+		{path: "resp/none", expect: ""},
+	} {
+		if value, err := xmlGet(doc, v.path); err != nil || value != v.expect {
+			t.Errorf("xmlGet(%s) = %s, %v, expect: %s", v.path, value, err, v.expect)
+		}
+	}
+}
+
+func TestHeaderTemplates(t *testing.T) {
+	headers := map[string][]string{
+		"Accept": {"text/html"},
+		"X-Sig":  {"{{ .Method }} {{ .URL }}", "w{{ .WorkerID }}-{{ .Iteration }}"},
+	}
+	hts, err := parseHeaderTemplates(headers)
+	if err != nil || len(hts) != 1 || hts[0].key != "X-Sig" {
+		t.Fatalf("parseHeaderTemplates = %v, %v, expect only X-Sig", hts, err)
+	}
+
+	ctx := &requestContext{URL: "http://127.0.0.1/a", Method: "POST", WorkerID: 2, Iteration: 7}
+	rendered := renderHeaders(headers, hts, ctx)
+	if v := rendered["X-Sig"]; len(v) != 2 || v[0] != "POST http://127.0.0.1/a" || v[1] != "w2-7" {
+		t.Errorf("renderHeaders X-Sig = %v", v)
+	}
+	if v := headers["X-Sig"][0]; v != "{{ .Method }} {{ .URL }}" {
+		t.Errorf("renderHeaders changed the origin headers: %s", v)
+	}
+
+	if errs := validateHeaderTemplates(map[string][]string{"X-A": {"{{ .None }}"}, "X-B": {"{{"}}); len(errs) != 1 {
+		t.Errorf("validateHeaderTemplates = %v, expect 1 error", errs)
+	}
+}
+
+func TestClientRandomSeed(t *testing.T) {
+	render := func(seed int64, id int) string {
+		b := &StressWorker{RequestParams: &StressParameters{RequestType: typeHttp1, RequestMethod: "GET",
+			Url: "http://127.0.0.1/?s={{ randomString 8 }}&n={{ random 1 1000000 }}&u={{ UUID }}&ip={{ randomIP }}", Seed: seed}}
+		b.prepare()
+		client := b.newClient(id)
+		defer b.closeClient(client)
+		var url strings.Builder
+		if err := client.urlTemplate.Execute(&url, &requestContext{}); err != nil {
+			t.Fatal(err)
+		}
+		return url.String()
+	}
+
+	if a, b := render(42, 0), render(42, 0); a != b {
+		t.Errorf("same seed and connection = %s, %s, expect the same data", a, b)
+	}
+	if a, b := render(42, 0), render(42, 1); a == b {
+		t.Errorf("connections of seed = %s, %s, expect different data", a, b)
+	}
+	if a, b := render(0, 0), render(0, 0); a == b {
+		t.Errorf("random seed = %s, %s, expect different data", a, b)
+	}
+}
+
+func TestJsonGet(t *testing.T) {
+	body := `{"data": {"token": "abc", "ids": [1, 2]}}`
+	for path, expect := range map[string]string{"$.data.token": "abc", "$.data.ids[1]": "2", "$.data.ids": "[1,2]"} {
+		if v, err := jsonGet(body, path); err != nil || v != expect {
+			t.Errorf("jsonGet(%s) = %q, %v, expect: %s", path, v, err, expect)
+		}
+	}
+	if _, err := jsonGet(body, "$.data.missing"); err == nil {
+		t.Errorf("jsonGet missing path expect error")
+	}
+}
+
+func TestResponseSizes(t *testing.T) {
+	body := bytes.Repeat([]byte("compressible "), 1000)
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write(body)
+	gz.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") == "gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped.Bytes())
+			return
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	for _, disabled := range []bool{false, true} {
+		_, result := executeStress(StressParameters{
+			SequenceId:         time.Now().UnixNano(),
+			Cmd:                cmdStart,
+			RequestType:        typeHttp1,
+			RequestMethod:      "GET",
+			Url:                srv.URL,
+			C:                  1,
+			N:                  4,
+			Timeout:            3000,
+			DisableCompression: disabled,
+		})
+		if result == nil || result.LatsTotal <= 0 {
+			t.Fatalf("result of compression disabled %v = %+v", disabled, result)
+		}
+		wire := int64(gzipped.Len())
+		if disabled {
+			wire = int64(len(body))
+		}
+		if result.SizeTotal != result.LatsTotal*int64(len(body)) || result.WireSizeTotal != result.LatsTotal*wire {
+			t.Errorf("compression disabled %v, size %d, wire size %d of %d responses, expect %d and %d per response",
+				disabled, result.SizeTotal, result.WireSizeTotal, result.LatsTotal, len(body), wire)
+		}
+		var out bytes.Buffer
+		result.write(&out, "")
+		if strings.Contains(out.String(), "Compression:") == disabled {
+			t.Errorf("compression disabled %v, summary:\n%s", disabled, out.String())
+		}
+	}
+}
@@ -0,0 +1,48 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package main
+
+import (
+	"math"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// processCPUTime return user and system cpu time used by current process
+func processCPUTime() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
+
+// notifyStepSignal relay SIGUSR2 to step up load
+func notifyStepSignal(c chan os.Signal) {
+	signal.Notify(c, syscall.SIGUSR2)
+}
+
+// fileLimit soft limit of open files of current process, -1 is unknown or unlimited
+func fileLimit() int64 {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil || uint64(rl.Cur) > math.MaxInt64 {
+		return -1
+	}
+	return int64(rl.Cur)
+}
+
+// raiseFileLimit raise the soft limit of open files to the hard limit when lower than n, return the
+// soft limit after, -1 is unknown
+func raiseFileLimit(n int64) int64 {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return -1
+	}
+	if limit := fileLimit(); limit >= 0 && limit < n && rl.Cur < rl.Max {
+		rl.Cur = rl.Max
+		syscall.Setrlimit(syscall.RLIMIT_NOFILE, &rl)
+	}
+	return fileLimit()
+}
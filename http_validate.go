@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// StressEffective effective parameters of worker after defaulting and clamping
+type StressEffective struct {
+	RequestType string   `json:"request_type"`
+	C           int      `json:"c"`          // connections
+	N           int      `json:"n"`          // requests, rounded down to a multiple of connections
+	Qps         int      `json:"qps"`        // rate of worker after rounding of the send interval, 0 is unlimited
+	Duration    int64    `json:"duration"`   // seconds, 0 runs until stopped
+	Timeout     int      `json:"timeout"`    // ms
+	Cpus        int      `json:"cpus"`       // GOMAXPROCS of worker
+	Interfaces  []string `json:"interfaces"` // interfaces resolved by worker
+}
+
+// effective parameters which the worker runs with
+func (b *StressWorker) effective() *StressEffective {
+	p := b.RequestParams
+	eff := &StressEffective{
+		RequestType: p.RequestType,
+		C:           p.C,
+		N:           p.N,
+		Qps:         p.Qps,
+		Duration:    p.Duration,
+		Timeout:     p.Timeout,
+		Cpus:        runtime.GOMAXPROCS(-1),
+	}
+	if p.C > 0 {
+		eff.N = p.N / p.C * p.C
+		if p.Qps > 0 {
+			// same send interval in us of runClient
+			if sleep := 1e6 / (p.C * p.Qps); sleep > 0 {
+				eff.Qps = 1e6 / (p.C * sleep)
+			} else {
+				eff.Qps = 0
+			}
+		}
+	}
+	b.prepare()
+	for _, iface := range b.interfaces {
+		eff.Interfaces = append(eff.Interfaces, iface.name)
+	}
+	return eff
+}
+
+// mismatches parameters of the worker differ from the requested ones
+func (eff *StressEffective) mismatches(p *StressParameters) []string {
+	var diffs []string
+	for _, v := range []struct {
+		name            string
+		request, actual interface{}
+	}{
+		{"http", p.RequestType, eff.RequestType},
+		{"c", p.C, eff.C},
+		{"n", p.N, eff.N},
+		{"q", p.Qps, eff.Qps},
+		{"d", p.Duration, eff.Duration},
+		{"t", p.Timeout, eff.Timeout},
+		{"interface", strings.Join(p.Interfaces, ","), strings.Join(eff.Interfaces, ",")},
+	} {
+		if v.request != v.actual {
+			diffs = append(diffs, fmt.Sprintf("-%s %v runs as %v", v.name, orNone(v.request), orNone(v.actual)))
+		}
+	}
+	return diffs
+}
+
+func orNone(v interface{}) interface{} {
+	if v == "" {
+		return "none"
+	}
+	return v
+}
+
+// workerBody parameters sent to worker of index
+func workerBody(paramsJson []byte, index int) []byte {
+	return regionParams(workerParams(paramsJson, index, len(workerList)), index)
+}
+
+// validateWorkers send the parameters to workers before load starts, and print the effective
+// parameters echoed by each worker, mismatches are marked, and return the number of workers without response
+func validateWorkers(params StressParameters) int {
+	params.Cmd = cmdValidate
+	paramsJson, err := json.Marshal(params)
+	if err != nil {
+		return 0
+	}
+
+	var (
+		wg       sync.WaitGroup
+		requests = make([]StressParameters, len(workerList))
+		results  = make([]*StressResult, len(workerList))
+	)
+	for i, worker := range workerList {
+		body := workerBody(paramsJson, i)
+		json.Unmarshal(body, &requests[i])
+		wg.Add(1)
+		go func(i int, uri string, body []byte) {
+			defer wg.Done()
+			if result, err := executeWorkerReq(uri, body); err == nil {
+				results[i] = result
+			}
+		}(i, workerUrl(worker, httpWorkerApiPath), body)
+	}
+	wg.Wait()
+
+	var diffs []string
+	missing := 0
+	tw := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Workers:")
+	fmt.Fprintln(tw, "  Worker\tHttp\tC\tN\tQps\tDuration\tTimeout\tCpus\tInterfaces")
+	for i, worker := range workerList {
+		worker = redactWorker(worker)
+		var eff *StressEffective
+		if results[i] != nil {
+			eff = results[i].Effective
+		}
+		if eff == nil {
+			fmt.Fprintf(tw, "  %s\tno response\n", worker)
+			missing++
+			continue
+		}
+		mark := ""
+		for _, diff := range eff.mismatches(&requests[i]) {
+			mark, diffs = " *", append(diffs, worker+": "+diff)
+		}
+		fmt.Fprintf(tw, "  %s%s\t%s\t%d\t%d\t%d\t%v\t%dms\t%d\t%v\n", worker, mark, eff.RequestType, eff.C, eff.N,
+			eff.Qps, time.Duration(eff.Duration)*time.Second, eff.Timeout, eff.Cpus, orNone(strings.Join(eff.Interfaces, ",")))
+	}
+	tw.Flush()
+	for _, diff := range diffs {
+		eprintln("  * %s", diff)
+	}
+	return missing
+}
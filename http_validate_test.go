@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEffectiveParams(t *testing.T) {
+	params := StressParameters{Cmd: cmdValidate, RequestType: typeHttp1, Url: "http://127.0.0.1/", C: 7, N: 100,
+		Qps: 1000, Duration: 10, Timeout: 3000, Interfaces: []string{"127.0.0.1", "no-such-iface0"}}
+	_, result := executeStress(params)
+	eff := result.Effective
+	if eff == nil {
+		t.Fatalf("effective params not echoed")
+	}
+	if eff.C != 7 || eff.N != 98 || eff.Qps != 1006 || eff.Cpus <= 0 {
+		t.Errorf("effective = %+v, expect c 7, n 98, qps 1006", eff)
+	}
+
+	diffs := strings.Join(eff.mismatches(&params), "\n")
+	for _, expect := range []string{"-n 100 runs as 98", "-q 1000 runs as 1006", "-interface 127.0.0.1,no-such-iface0 runs as 127.0.0.1"} {
+		if !strings.Contains(diffs, expect) {
+			t.Errorf("mismatches = %q, expect %q", diffs, expect)
+		}
+	}
+
+	params.N, params.Qps, params.Interfaces = 70, 100, nil
+	if _, result = executeStress(params); len(result.Effective.mismatches(&params)) > 0 {
+		t.Errorf("mismatches = %v, expect none", result.Effective.mismatches(&params))
+	}
+}
+
+func TestValidateWorkers(t *testing.T) {
+	worker := httptest.NewServer(http.HandlerFunc(serveWorker))
+	defer worker.Close()
+	down, _ := net.Listen("tcp", "127.0.0.1:0")
+	down.Close()
+
+	defer func(workers flagSlice) { workerList = workers }(workerList)
+	workerList = flagSlice{worker.URL, down.Addr().String()}
+	params := StressParameters{SequenceId: time.Now().UnixNano(), RequestType: typeHttp1, Url: "http://127.0.0.1/",
+		C: 2, N: 10, Duration: 10, Timeout: 1000}
+	if missing := validateWorkers(params); missing != 1 {
+		t.Errorf("workers without response = %d, expect 1", missing)
+	}
+}
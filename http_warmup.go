@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io"
+	"time"
+)
+
+// StressWarmup requests of -warmup, executed but excluded from the statistics
+type StressWarmup struct {
+	Duration int64 `json:"duration"` // ms
+	Requests int64 `json:"requests"`
+	Errors   int64 `json:"errors"`
+}
+
+// warmupEnd end of -warmup of the worker, the start if not warming up
+func (b *StressWorker) warmupEnd() time.Time {
+	return b.startTime.Add(time.Duration(b.RequestParams.Warmup) * time.Millisecond)
+}
+
+// inWarmup request started within -warmup, the latency includes cold connections and tls handshakes
+func (b *StressWorker) inWarmup(res *result) bool {
+	return b.warmup != nil && res.start.Before(b.warmupEnd())
+}
+
+func (w *StressWarmup) append(res *result) {
+	w.Requests++
+	if res.err != nil {
+		w.Errors++
+	}
+}
+
+func (w *StressWarmup) merge(v *StressWarmup) {
+	if v.Duration > w.Duration {
+		w.Duration = v.Duration
+	}
+	w.Requests += v.Requests
+	w.Errors += v.Errors
+}
+
+// printWarmup Print requests excluded from the statistics by -warmup
+func (result *StressResult) printWarmup(w io.Writer) {
+	wu := result.Warmup
+	fprintln(w, "\nWarm-up (excluded):")
+	fprintln(w, "  Duration:\t%v", time.Duration(wu.Duration)*time.Millisecond)
+	fprintln(w, "  Requests:\t%d, %d failed", wu.Requests, wu.Errors)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStressWarmup(t *testing.T) {
+	var count int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first requests are cold, the last of them starts at 200ms within the warm-up
+		if atomic.AddInt64(&count, 1) <= 3 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	_, result := executeStress(StressParameters{
+		SequenceId:    time.Now().UnixNano(),
+		Cmd:           cmdStart,
+		RequestType:   typeHttp1,
+		RequestMethod: "GET",
+		Url:           srv.URL,
+		C:             1,
+		N:             50,
+		Duration:      10,
+		Timeout:       3000,
+		Warmup:        250,
+	})
+	if result == nil || len(result.ErrorDist) > 0 || result.Warmup == nil {
+		t.Fatalf("result of warmup = %+v", result)
+	}
+	wu := result.Warmup
+	if wu.Duration != 250 || wu.Requests < 3 || wu.Errors != 0 || wu.Requests+result.LatsTotal != atomic.LoadInt64(&count) {
+		t.Errorf("warmup = %+v, responses: %d", wu, result.LatsTotal)
+	}
+	if slowest := time.Duration(result.Slowest) * time.Second / scaleNum; slowest >= 100*time.Millisecond {
+		t.Errorf("slowest %v of the result includes the cold requests of the warm-up", slowest)
+	}
+
+	var buf bytes.Buffer
+	result.printWarmup(&buf)
+	for _, line := range []string{"Warm-up (excluded):", "  Duration:\t250ms", "  Requests:\t"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("printWarmup expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
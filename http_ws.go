@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// StressWebSocket handshakes of websocket connections and what the server negotiated
+type StressWebSocket struct {
+	Subprotocols []string         `json:"subprotocols"` // offered by -ws-subprotocol
+	Compression  bool             `json:"compression"`  // permessage-deflate offered by -ws-compression
+	Opened       int64            `json:"opened"`       // connections upgraded
+	Failed       int64            `json:"failed"`       // dial or handshake failed
+	Negotiated   map[string]int64 `json:"negotiated"`   // connections by subprotocol selected by server, "" is none
+	Extensions   map[string]int64 `json:"extensions"`   // connections by Sec-WebSocket-Extensions of server, "" is none
+	LastErr      string           `json:"last_err"`     // reason of the last failed handshake
+}
+
+// wsConns handshakes of websocket connections of worker
+type wsConns struct {
+	stats StressWebSocket
+	mu    sync.Mutex
+}
+
+// dialWs open websocket connection with the subprotocols, compression and origin of parameters
+func (b *StressWorker) dialWs(id int) (*websocket.Conn, error) {
+	p := b.RequestParams
+	dialer := *websocket.DefaultDialer
+	dialer.NetDialContext = b.dialContext(id, &net.Dialer{})
+	dialer.TLSClientConfig = b.tlsConfig()
+	dialer.Subprotocols = p.WsSubprotocols
+	dialer.EnableCompression = p.WsCompression
+
+	header := http.Header(p.Headers).Clone()
+	if p.WsOrigin != "" {
+		if header == nil {
+			header = make(http.Header)
+		}
+		header.Set("Origin", p.WsOrigin)
+	}
+	c, resp, err := dialer.Dial(p.Url, header)
+	if err != nil && resp != nil {
+		err = fmt.Errorf("%w (status %d)", err, resp.StatusCode) // e.g. subprotocol rejected by server
+	}
+	if b.ws != nil {
+		b.ws.handshake(c, resp, err)
+	}
+	return c, err
+}
+
+func (ws *wsConns) handshake(c *websocket.Conn, resp *http.Response, err error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if err != nil || c == nil {
+		ws.stats.Failed++
+		if err != nil {
+			ws.stats.LastErr = err.Error()
+		}
+		return
+	}
+	ws.stats.Opened++
+	ws.stats.Negotiated[c.Subprotocol()]++
+	ws.stats.Extensions[resp.Header.Get("Sec-WebSocket-Extensions")]++
+}
+
+// startWs record handshakes of websocket connections
+func (b *StressWorker) startWs() {
+	b.ws = &wsConns{stats: StressWebSocket{
+		Subprotocols: b.RequestParams.WsSubprotocols,
+		Compression:  b.RequestParams.WsCompression,
+		Negotiated:   make(map[string]int64),
+		Extensions:   make(map[string]int64),
+	}}
+}
+
+// wsStats snapshot of websocket handshakes, nil if not websocket
+func (b *StressWorker) wsStats() *StressWebSocket {
+	if b.ws == nil {
+		return nil
+	}
+	b.ws.mu.Lock()
+	defer b.ws.mu.Unlock()
+	stats := &StressWebSocket{}
+	stats.merge(&b.ws.stats)
+	return stats
+}
+
+func (ws *StressWebSocket) merge(v *StressWebSocket) {
+	if ws.Negotiated == nil {
+		ws.Negotiated, ws.Extensions = make(map[string]int64), make(map[string]int64)
+	}
+	ws.Subprotocols, ws.Compression = v.Subprotocols, v.Compression
+	ws.Opened += v.Opened
+	ws.Failed += v.Failed
+	for name, c := range v.Negotiated {
+		ws.Negotiated[name] += c
+	}
+	for name, c := range v.Extensions {
+		ws.Extensions[name] += c
+	}
+	if v.LastErr != "" {
+		ws.LastErr = v.LastErr
+	}
+}
+
+// printWebSocket Print handshakes of websocket connections and what the server negotiated
+func (result *StressResult) printWebSocket(w io.Writer) {
+	ws := result.WebSocket
+	none := func(v string) string {
+		if v == "" {
+			return "none"
+		}
+		return v
+	}
+	counts := func(name string, m map[string]int64) {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fprintln(w, "  %s:\t[%s] %d connections", name, none(k), m[k])
+		}
+	}
+
+	fprintln(w, "\nWebSocket:")
+	fprintln(w, "  Opened:\t%d connections", ws.Opened)
+	if ws.Failed > 0 {
+		fprintln(w, "  Failed:\t%d, last: %s", ws.Failed, ws.LastErr)
+	}
+	if len(ws.Subprotocols) > 0 || ws.Compression {
+		offered := append([]string(nil), ws.Subprotocols...)
+		if ws.Compression {
+			offered = append(offered, "permessage-deflate")
+		}
+		fprintln(w, "  Offered:\t%s", strings.Join(offered, ", "))
+	}
+	counts("Subprotocol", ws.Negotiated)
+	counts("Extensions", ws.Extensions)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestDialWs(t *testing.T) {
+	upgrader := websocket.Upgrader{
+		Subprotocols:      []string{"graphql-ws"},
+		EnableCompression: true,
+		CheckOrigin:       func(r *http.Request) bool { return r.Header.Get("Origin") == "https://example.com" },
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := upgrader.Upgrade(w, r, nil); err == nil {
+			c.Close()
+		}
+	}))
+	defer srv.Close()
+	url := strings.Replace(srv.URL, "http://", "ws://", 1)
+
+	b := &StressWorker{RequestParams: &StressParameters{
+		Url:            url,
+		RequestType:    typeWs,
+		WsSubprotocols: []string{"graphql-transport-ws", "graphql-ws"},
+		WsCompression:  true,
+		WsOrigin:       "https://example.com",
+	}}
+	b.startWs()
+	c, err := b.dialWs(0)
+	if err != nil {
+		t.Fatalf("dialWs err: %v", err)
+	}
+	c.Close()
+	if c.Subprotocol() != "graphql-ws" {
+		t.Errorf("subprotocol = %q, expect: graphql-ws", c.Subprotocol())
+	}
+
+	b.RequestParams.WsOrigin = "https://evil.com"
+	if _, err := b.dialWs(1); err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Errorf("dialWs with wrong origin err = %v, expect status 403", err)
+	}
+
+	stats := b.wsStats()
+	if stats.Opened != 1 || stats.Failed != 1 || stats.Negotiated["graphql-ws"] != 1 ||
+		!strings.HasPrefix(findKey(stats.Extensions), "permessage-deflate") {
+		t.Errorf("wsStats = %+v", stats)
+	}
+
+	result := GetStressResult()
+	result = calMutliStressResult(result, StressResult{WebSocket: stats}, StressResult{WebSocket: stats})
+	var buf bytes.Buffer
+	result.printWebSocket(&buf)
+	for _, line := range []string{
+		"  Opened:\t2 connections",
+		"  Failed:\t2, last: websocket: bad handshake (status 403)",
+		"  Offered:\tgraphql-transport-ws, graphql-ws, permessage-deflate",
+		"  Subprotocol:\t[graphql-ws] 2 connections",
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("printWebSocket expect %q, got:\n%s", line, buf.String())
+		}
+	}
+}
+
+// findKey the only key of m
+func findKey(m map[string]int64) string {
+	for k := range m {
+		return k
+	}
+	return ""
+}